@@ -0,0 +1,469 @@
+package alerts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/bilinearlabs/eth-metrics/epochtime"
+	"github.com/bilinearlabs/eth-metrics/telegram"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+type AlertKind string
+
+const (
+	MissedProposal     AlertKind = "missed_proposal"
+	MissedAttestations AlertKind = "missed_attestations"
+	ValidatorSlashed   AlertKind = "validator_slashed"
+	WrongFeeRecipient  AlertKind = "wrong_fee_recipient"
+	MEVUnderpaid       AlertKind = "mev_underpaid"
+	LateProposal       AlertKind = "late_proposal"
+	VoluntaryExit      AlertKind = "voluntary_exit"
+	BLSChange          AlertKind = "bls_change"
+	// Execution layer requests, since electra
+	WithdrawalRequest    AlertKind = "withdrawal_request"
+	ConsolidationRequest AlertKind = "consolidation_request"
+	Doppelganger         AlertKind = "doppelganger"
+	SLABreach            AlertKind = "sla_breach"
+	// Not problems, but duties to not restart the nodes before
+	UpcomingProposal      AlertKind = "upcoming_proposal"
+	UpcomingSyncCommittee AlertKind = "upcoming_sync_committee"
+)
+
+type Severity string
+
+const (
+	Info     Severity = "info"
+	Warning  Severity = "warning"
+	Critical Severity = "critical"
+)
+
+// Higher is more severe
+var severityLevels = map[Severity]int{
+	Info:     0,
+	Warning:  1,
+	Critical: 2,
+}
+
+// Alerts that risk or already caused a slashing
+var criticalKinds = map[AlertKind]bool{
+	ValidatorSlashed: true,
+	Doppelganger:     true,
+}
+
+// Alerts of changes that the operator usually made on purpose. The rest are
+// warnings
+var infoKinds = map[AlertKind]bool{
+	VoluntaryExit:         true,
+	BLSChange:             true,
+	WithdrawalRequest:     true,
+	ConsolidationRequest:  true,
+	UpcomingProposal:      true,
+	UpcomingSyncCommittee: true,
+}
+
+func (k AlertKind) Severity() Severity {
+	if criticalKinds[k] {
+		return Critical
+	}
+	if infoKinds[k] {
+		return Info
+	}
+	return Warning
+}
+
+// Returns true if s is as severe as min or more
+func (s Severity) AtLeast(min Severity) bool {
+	return severityLevels[s] >= severityLevels[min]
+}
+
+type Alert struct {
+	Kind     AlertKind `json:"kind"`
+	Severity Severity  `json:"severity"`
+	Epoch    uint64    `json:"epoch"`
+	// Start of the epoch, set when sent
+	Time     time.Time `json:"time,omitzero"`
+	PoolName string    `json:"pool"`
+	Message  string    `json:"message"`
+}
+
+type webhookFormat string
+
+const (
+	formatGeneric  webhookFormat = "generic"
+	formatSlack    webhookFormat = "slack"
+	formatDiscord  webhookFormat = "discord"
+	formatTelegram webhookFormat = "telegram"
+)
+
+// Overridden by the tests
+var telegramApiUrl = telegram.ApiUrl
+
+type webhook struct {
+	url    string
+	format webhookFormat
+	// Only for telegram, whose url is the same for all chats
+	chatId int64
+	// Tenant whose pools are only alerted to the webhook, empty for the ones
+	// of the operator, which get the alerts of all pools
+	tenant string
+}
+
+// Sends the alerts of a pool, or of all of them if "*", from a severity on
+// to a webhook
+type route struct {
+	poolName    string
+	minSeverity Severity
+	format      webhookFormat
+	tenant      string
+}
+
+// Alerts that are not sent, from the given time on or forever if Until is
+// zero. Empty or "*" pool and kind match any.
+type Silence struct {
+	Id       uint64    `json:"id"`
+	PoolName string    `json:"pool"`
+	Kind     AlertKind `json:"kind"`
+	Until    time.Time `json:"until,omitzero"`
+	Comment  string    `json:"comment,omitempty"`
+}
+
+func (s Silence) matches(alert Alert, now time.Time) bool {
+	if !s.Until.IsZero() && !now.Before(s.Until) {
+		return false
+	}
+	return (s.PoolName == "" || s.PoolName == "*" || s.PoolName == alert.PoolName) &&
+		(s.Kind == "" || s.Kind == "*" || s.Kind == alert.Kind)
+}
+
+// Last time that an alert was sent, and how many were not sent since then
+type lastSent struct {
+	time       time.Time
+	suppressed int
+}
+
+type Alerts struct {
+	httpClient     *http.Client
+	webhooks       []webhook
+	routes         []route
+	poolTenants    map[string]string
+	repeatInterval time.Duration
+	config         *config.Config
+	clock          *epochtime.Clock
+	now            func() time.Time
+
+	// Protects the silences and the sent alerts, changed by the api
+	mutex         sync.Mutex
+	silences      []Silence
+	nextSilenceId uint64
+	sent          map[string]*lastSent
+}
+
+// The clock converts the epochs of the alerts to time, if not nil
+func NewAlerts(config *config.Config, clock *epochtime.Clock) (*Alerts, error) {
+	webhooks := make([]webhook, 0)
+	if config.AlertWebhook != "" {
+		webhooks = append(webhooks, webhook{url: config.AlertWebhook, format: formatGeneric})
+	}
+	if config.AlertSlackWebhook != "" {
+		webhooks = append(webhooks, webhook{url: config.AlertSlackWebhook, format: formatSlack})
+	}
+	if config.AlertDiscordWebhook != "" {
+		webhooks = append(webhooks, webhook{url: config.AlertDiscordWebhook, format: formatDiscord})
+	}
+	if config.TelegramToken != "" {
+		webhooks = append(webhooks, webhook{
+			url:    telegramApiUrl + "/bot" + config.TelegramToken + "/sendMessage",
+			format: formatTelegram,
+			chatId: config.TelegramChatId,
+		})
+	}
+
+	routes, err := parseRoutes(config.AlertRoutes, webhooks)
+	if err != nil {
+		return nil, err
+	}
+
+	poolTenants := make(map[string]string)
+	for _, tenant := range config.Tenants {
+		tenantWebhooks := tenantWebhooks(tenant)
+		tenantRoutes, err := parseRoutes(tenant.AlertRoutes, tenantWebhooks)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid routes of tenant "+tenant.Id)
+		}
+		for i := range tenantRoutes {
+			tenantRoutes[i].tenant = tenant.Id
+		}
+		webhooks = append(webhooks, tenantWebhooks...)
+		routes = append(routes, tenantRoutes...)
+		for _, poolName := range tenant.Pools {
+			poolTenants[poolName] = tenant.Id
+		}
+	}
+
+	a := &Alerts{
+		httpClient:     &http.Client{Timeout: 10 * time.Second},
+		webhooks:       webhooks,
+		routes:         routes,
+		poolTenants:    poolTenants,
+		repeatInterval: config.AlertRepeatInterval,
+		config:         config,
+		clock:          clock,
+		now:            time.Now,
+		silences:       make([]Silence, 0),
+		nextSilenceId:  1,
+		sent:           make(map[string]*lastSent),
+	}
+	for _, value := range config.AlertSilences {
+		poolName, kind, found := strings.Cut(value, ":")
+		if !found || poolName == "" || kind == "" {
+			return nil, errors.New("--alert-silence must be pool:kind: " + value)
+		}
+		a.AddSilence(Silence{PoolName: poolName, Kind: AlertKind(kind), Comment: "--alert-silence"})
+	}
+	return a, nil
+}
+
+// Webhooks of a tenant, that only get the alerts of its pools
+func tenantWebhooks(tenant config.Tenant) []webhook {
+	webhooks := make([]webhook, 0)
+	if tenant.AlertWebhook != "" {
+		webhooks = append(webhooks, webhook{url: tenant.AlertWebhook, format: formatGeneric, tenant: tenant.Id})
+	}
+	if tenant.AlertSlackWebhook != "" {
+		webhooks = append(webhooks, webhook{url: tenant.AlertSlackWebhook, format: formatSlack, tenant: tenant.Id})
+	}
+	if tenant.AlertDiscordWebhook != "" {
+		webhooks = append(webhooks, webhook{url: tenant.AlertDiscordWebhook, format: formatDiscord, tenant: tenant.Id})
+	}
+	return webhooks
+}
+
+// Parses routes defined as pool:severity:webhook, e.g. *:critical:slack. The
+// webhook must be configured.
+func parseRoutes(values []string, webhooks []webhook) ([]route, error) {
+	routes := make([]route, 0)
+	for _, value := range values {
+		parts := strings.Split(value, ":")
+		if len(parts) != 3 || parts[0] == "" {
+			return nil, errors.New("--alert-route must be pool:severity:webhook: " + value)
+		}
+		severity := Severity(parts[1])
+		if _, ok := severityLevels[severity]; !ok {
+			return nil, errors.New("severity not supported in --alert-route: " + value + ", expected info|warning|critical")
+		}
+		format := webhookFormat(parts[2])
+		configured := false
+		for _, w := range webhooks {
+			configured = configured || w.format == format
+		}
+		if !configured {
+			return nil, errors.New("webhook of --alert-route is not configured: " + value + ", expected generic|slack|discord|telegram")
+		}
+		routes = append(routes, route{poolName: parts[0], minSeverity: severity, format: format})
+	}
+	return routes, nil
+}
+
+// Returns true if at least one webhook is configured
+func (a *Alerts) Enabled() bool {
+	return len(a.webhooks) > 0
+}
+
+// Silences the alerts that match, and returns the silence with its id
+func (a *Alerts) AddSilence(silence Silence) Silence {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	silence.Id = a.nextSilenceId
+	a.nextSilenceId++
+	a.silences = append(a.silences, silence)
+	return silence
+}
+
+// Removes the silence with the given id, returning false if there is none
+func (a *Alerts) RemoveSilence(id uint64) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for i, silence := range a.silences {
+		if silence.Id == id {
+			a.silences = append(a.silences[:i], a.silences[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the silences that did not expire yet
+func (a *Alerts) Silences() []Silence {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	now := a.now()
+	active := make([]Silence, 0, len(a.silences))
+	for _, silence := range a.silences {
+		if silence.Until.IsZero() || now.Before(silence.Until) {
+			active = append(active, silence)
+		}
+	}
+	// Expired ones are not needed anymore
+	a.silences = active
+	return append([]Silence(nil), active...)
+}
+
+// Sends the alert to the webhooks of its routes, or to all webhooks if there
+// are no routes, the ones of a tenant only if the pool is of the tenant, unless it is silenced or was already sent within the repeat
+// interval. Alerts are always logged. Errors are logged and not returned,
+// since a failing webhook shall not stop the metrics processing. Returns the
+// alert with its severity and time.
+func (a *Alerts) Send(alert Alert) Alert {
+	alert.Severity = alert.Kind.Severity()
+	if a.clock != nil && alert.Time.IsZero() {
+		alert.Time = a.clock.EpochStart(alert.Epoch)
+	}
+	entry := log.WithFields(log.Fields{
+		"Kind":     alert.Kind,
+		"Epoch":    alert.Epoch,
+		"PoolName": alert.PoolName,
+	})
+	switch alert.Severity {
+	case Critical:
+		entry.Error(alert.Message)
+	case Warning:
+		entry.Warn(alert.Message)
+	default:
+		entry.Info(alert.Message)
+	}
+
+	sent := alert
+	if !a.shouldSend(&sent) {
+		return alert
+	}
+	for _, w := range a.webhooks {
+		if !a.routed(w, sent) {
+			continue
+		}
+		if err := a.post(w, sent); err != nil {
+			log.Error("could not send alert to ", w.format, " webhook: ", err)
+		}
+	}
+	return alert
+}
+
+// Returns false if the alert is silenced or is a repetition. Repetitions are
+// alerts of the same kind and pool, or with the same message if critical, so
+// that every validator at risk of being slashed is alerted. The number of
+// repetitions that were not sent is added to the message of the next alert.
+func (a *Alerts) shouldSend(alert *Alert) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	now := a.now()
+	for _, silence := range a.silences {
+		if silence.matches(*alert, now) {
+			log.WithField("SilenceId", silence.Id).Debug("Alert silenced")
+			return false
+		}
+	}
+	if a.repeatInterval <= 0 {
+		return true
+	}
+
+	key := string(alert.Kind) + "/" + alert.PoolName
+	if alert.Severity == Critical {
+		key += "/" + alert.Message
+	}
+	last, ok := a.sent[key]
+	if ok && now.Sub(last.time) < a.repeatInterval {
+		last.suppressed++
+		log.Debug("Alert repeated within ", a.repeatInterval, ", not sent")
+		return false
+	}
+	if ok && last.suppressed > 0 {
+		alert.Message += fmt.Sprintf(" (%d repeated alerts not sent since %s)", last.suppressed, last.time.UTC().Format("2006-01-02 15:04 MST"))
+	}
+	a.sent[key] = &lastSent{time: now}
+
+	// The ones with repetitions are kept to be counted in the next alert
+	for key, last := range a.sent {
+		if last.suppressed == 0 && now.Sub(last.time) >= a.repeatInterval {
+			delete(a.sent, key)
+		}
+	}
+	return true
+}
+
+// Returns true if the alert goes to the webhook according to the routes of its
+// tenant, or of the operator. The webhooks of a tenant only get the alerts of
+// its pools.
+func (a *Alerts) routed(w webhook, alert Alert) bool {
+	if w.tenant != "" && a.poolTenants[alert.PoolName] != w.tenant {
+		return false
+	}
+	routes := make([]route, 0, len(a.routes))
+	for _, r := range a.routes {
+		if r.tenant == w.tenant {
+			routes = append(routes, r)
+		}
+	}
+	if len(routes) == 0 {
+		return true
+	}
+	for _, r := range routes {
+		if r.format == w.format &&
+			(r.poolName == "*" || r.poolName == alert.PoolName) &&
+			alert.Severity.AtLeast(r.minSeverity) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Alerts) post(w webhook, alert Alert) error {
+	body, err := json.Marshal(formatPayload(w, alert))
+	if err != nil {
+		return errors.Wrap(err, "could not marshal alert")
+	}
+
+	resp, err := a.httpClient.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// The urls of the webhooks are secrets
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return errors.Wrap(err, "could not post alert")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.New(fmt.Sprintf("non-2xx status: %d", resp.StatusCode))
+	}
+	return nil
+}
+
+func formatPayload(w webhook, alert Alert) interface{} {
+	epoch := fmt.Sprintf("epoch %d", alert.Epoch)
+	if !alert.Time.IsZero() {
+		epoch += " (" + alert.Time.Format("2006-01-02 15:04 MST") + ")"
+	}
+	text := fmt.Sprintf("[%s] pool %s, %s: %s", alert.Kind, alert.PoolName, epoch, alert.Message)
+	if alert.Severity == Critical {
+		text = "[CRITICAL] " + text
+	}
+	switch w.format {
+	case formatSlack:
+		return map[string]string{"text": text}
+	case formatDiscord:
+		return map[string]string{"content": text}
+	case formatTelegram:
+		return map[string]interface{}{"chat_id": w.chatId, "text": text}
+	default:
+		return alert
+	}
+}
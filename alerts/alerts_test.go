@@ -0,0 +1,284 @@
+package alerts
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/bilinearlabs/eth-metrics/epochtime"
+	"github.com/bilinearlabs/eth-metrics/telegram"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Send(t *testing.T) {
+	received := make(map[string]map[string]interface{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		received[r.URL.Path] = payload
+	}))
+	defer server.Close()
+
+	a, err := NewAlerts(&config.Config{
+		AlertWebhook:        server.URL + "/generic",
+		AlertSlackWebhook:   server.URL + "/slack",
+		AlertDiscordWebhook: server.URL + "/discord",
+	}, nil)
+	require.NoError(t, err)
+	require.True(t, a.Enabled())
+
+	a.Send(Alert{
+		Kind:     MissedProposal,
+		Epoch:    10,
+		PoolName: "pool_a",
+		Message:  "missed block at slot 320",
+	})
+
+	require.Equal(t, "missed_proposal", received["/generic"]["kind"])
+	require.Equal(t, "pool_a", received["/generic"]["pool"])
+	require.Equal(t, float64(10), received["/generic"]["epoch"])
+	require.Equal(t, "[missed_proposal] pool pool_a, epoch 10: missed block at slot 320", received["/slack"]["text"])
+	require.Equal(t, "[missed_proposal] pool pool_a, epoch 10: missed block at slot 320", received["/discord"]["content"])
+	require.NotContains(t, received["/generic"], "time")
+}
+
+func Test_SendWithClock(t *testing.T) {
+	received := make(map[string]map[string]interface{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		received[r.URL.Path] = payload
+	}))
+	defer server.Close()
+
+	a, err := NewAlerts(&config.Config{
+		AlertWebhook:      server.URL + "/generic",
+		AlertSlackWebhook: server.URL + "/slack",
+	}, epochtime.New(time.Unix(1606824023, 0), 12*time.Second, 32))
+	require.NoError(t, err)
+
+	a.Send(Alert{Kind: MissedProposal, Epoch: 10, PoolName: "pool_a", Message: "missed"})
+	require.Equal(t, "2020-12-01T13:04:23Z", received["/generic"]["time"])
+	require.Equal(t, "[missed_proposal] pool pool_a, epoch 10 (2020-12-01 13:04 UTC): missed", received["/slack"]["text"])
+}
+
+func Test_SendCritical(t *testing.T) {
+	received := make(map[string]map[string]interface{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		received[r.URL.Path] = payload
+	}))
+	defer server.Close()
+
+	a, err := NewAlerts(&config.Config{
+		AlertWebhook:      server.URL + "/generic",
+		AlertSlackWebhook: server.URL + "/slack",
+	}, nil)
+	require.NoError(t, err)
+
+	a.Send(Alert{Kind: Doppelganger, Epoch: 10, PoolName: "pool_a", Message: "double vote"})
+	require.Equal(t, "critical", received["/generic"]["severity"])
+	require.Equal(t, "[CRITICAL] [doppelganger] pool pool_a, epoch 10: double vote", received["/slack"]["text"])
+
+	a.Send(Alert{Kind: MissedProposal, Epoch: 10, PoolName: "pool_a", Message: "missed"})
+	require.Equal(t, "warning", received["/generic"]["severity"])
+
+	a.Send(Alert{Kind: VoluntaryExit, Epoch: 10, PoolName: "pool_a", Message: "exit"})
+	require.Equal(t, "info", received["/generic"]["severity"])
+}
+
+func Test_NoWebhooks(t *testing.T) {
+	a, err := NewAlerts(&config.Config{}, nil)
+	require.NoError(t, err)
+	require.False(t, a.Enabled())
+
+	// Does nothing
+	a.Send(Alert{Kind: ValidatorSlashed})
+}
+
+func Test_SendRoutes(t *testing.T) {
+	received := make(map[string][]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		received[r.URL.Path] = append(received[r.URL.Path], payload["text"].(string))
+	}))
+	defer server.Close()
+
+	a, err := NewAlerts(&config.Config{
+		AlertSlackWebhook: server.URL + "/slack",
+		AlertWebhook:      server.URL + "/generic",
+		AlertRoutes:       []string{"*:critical:slack", "pool_a:warning:slack"},
+	}, nil)
+	require.NoError(t, err)
+
+	a.Send(Alert{Kind: MissedProposal, Epoch: 10, PoolName: "pool_a", Message: "a"})
+	a.Send(Alert{Kind: MissedProposal, Epoch: 10, PoolName: "pool_b", Message: "b"})
+	a.Send(Alert{Kind: Doppelganger, Epoch: 10, PoolName: "pool_b", Message: "c"})
+	a.Send(Alert{Kind: VoluntaryExit, Epoch: 10, PoolName: "pool_a", Message: "d"})
+	require.Equal(t, []string{
+		"[missed_proposal] pool pool_a, epoch 10: a",
+		"[CRITICAL] [doppelganger] pool pool_b, epoch 10: c",
+	}, received["/slack"])
+	// Without routes to it
+	require.Empty(t, received["/generic"])
+
+	_, err = NewAlerts(&config.Config{AlertRoutes: []string{"*:critical:slack"}}, nil)
+	require.ErrorContains(t, err, "not configured")
+	_, err = NewAlerts(&config.Config{AlertSlackWebhook: server.URL, AlertRoutes: []string{"*:high:slack"}}, nil)
+	require.ErrorContains(t, err, "severity not supported")
+	_, err = NewAlerts(&config.Config{AlertSlackWebhook: server.URL, AlertRoutes: []string{"slack"}}, nil)
+	require.ErrorContains(t, err, "pool:severity:webhook")
+}
+
+func Test_SendTenants(t *testing.T) {
+	received := make(map[string][]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		received[r.URL.Path] = append(received[r.URL.Path], payload["text"].(string))
+	}))
+	defer server.Close()
+
+	a, err := NewAlerts(&config.Config{
+		AlertSlackWebhook: server.URL + "/operator",
+		AlertRoutes:       []string{"*:critical:slack"},
+		Tenants: []config.Tenant{
+			{Id: "tenant_a", Pools: []string{"pool_a"}, AlertSlackWebhook: server.URL + "/tenant_a"},
+			{
+				Id:                  "tenant_b",
+				Pools:               []string{"pool_b", "pool_c"},
+				AlertSlackWebhook:   server.URL + "/tenant_b",
+				AlertDiscordWebhook: server.URL + "/tenant_b_discord",
+				AlertRoutes:         []string{"*:warning:slack"},
+			},
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	a.Send(Alert{Kind: MissedProposal, Epoch: 10, PoolName: "pool_a", Message: "a"})
+	a.Send(Alert{Kind: VoluntaryExit, Epoch: 10, PoolName: "pool_b", Message: "b"})
+	a.Send(Alert{Kind: MissedProposal, Epoch: 10, PoolName: "pool_c", Message: "c"})
+	a.Send(Alert{Kind: Doppelganger, Epoch: 10, PoolName: "pool_d", Message: "d"})
+
+	// The routes of the operator don't apply to the tenants
+	require.Equal(t, []string{"[CRITICAL] [doppelganger] pool pool_d, epoch 10: d"}, received["/operator"])
+	require.Equal(t, []string{"[missed_proposal] pool pool_a, epoch 10: a"}, received["/tenant_a"])
+	require.Equal(t, []string{"[missed_proposal] pool pool_c, epoch 10: c"}, received["/tenant_b"])
+	// Without routes to it
+	require.Empty(t, received["/tenant_b_discord"])
+
+	// The routes of a tenant are checked against its own webhooks
+	_, err = NewAlerts(&config.Config{
+		AlertDiscordWebhook: server.URL,
+		Tenants:             []config.Tenant{{Id: "tenant_a", Pools: []string{"pool_a"}, AlertRoutes: []string{"*:warning:discord"}}},
+	}, nil)
+	require.ErrorContains(t, err, "invalid routes of tenant tenant_a")
+}
+
+func Test_SendRepeated(t *testing.T) {
+	received := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		received = append(received, payload["text"].(string))
+	}))
+	defer server.Close()
+
+	a, err := NewAlerts(&config.Config{
+		AlertSlackWebhook:   server.URL,
+		AlertRepeatInterval: time.Hour,
+	}, nil)
+	require.NoError(t, err)
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+
+	a.Send(Alert{Kind: MissedAttestations, Epoch: 10, PoolName: "pool_a", Message: "10%"})
+	now = now.Add(30 * time.Minute)
+	a.Send(Alert{Kind: MissedAttestations, Epoch: 11, PoolName: "pool_a", Message: "12%"})
+	a.Send(Alert{Kind: MissedAttestations, Epoch: 11, PoolName: "pool_b", Message: "7%"})
+	// Every critical alert is sent
+	a.Send(Alert{Kind: Doppelganger, Epoch: 11, PoolName: "pool_a", Message: "validator 1"})
+	a.Send(Alert{Kind: Doppelganger, Epoch: 11, PoolName: "pool_a", Message: "validator 2"})
+	a.Send(Alert{Kind: Doppelganger, Epoch: 11, PoolName: "pool_a", Message: "validator 2"})
+	now = now.Add(30 * time.Minute)
+	a.Send(Alert{Kind: MissedAttestations, Epoch: 12, PoolName: "pool_a", Message: "9%"})
+
+	require.Equal(t, []string{
+		"[missed_attestations] pool pool_a, epoch 10: 10%",
+		"[missed_attestations] pool pool_b, epoch 11: 7%",
+		"[CRITICAL] [doppelganger] pool pool_a, epoch 11: validator 1",
+		"[CRITICAL] [doppelganger] pool pool_a, epoch 11: validator 2",
+		"[missed_attestations] pool pool_a, epoch 12: 9% (1 repeated alerts not sent since 2024-01-01 10:00 UTC)",
+	}, received)
+}
+
+func Test_Silences(t *testing.T) {
+	received := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		received = append(received, payload["text"].(string))
+	}))
+	defer server.Close()
+
+	a, err := NewAlerts(&config.Config{
+		AlertSlackWebhook: server.URL,
+		AlertSilences:     []string{"pool_a:missed_attestations"},
+	}, nil)
+	require.NoError(t, err)
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+
+	silence := a.AddSilence(Silence{PoolName: "*", Kind: MissedProposal, Until: now.Add(time.Hour)})
+	require.Equal(t, uint64(2), silence.Id)
+	require.Len(t, a.Silences(), 2)
+
+	a.Send(Alert{Kind: MissedAttestations, Epoch: 10, PoolName: "pool_a", Message: "a"})
+	a.Send(Alert{Kind: MissedAttestations, Epoch: 10, PoolName: "pool_b", Message: "b"})
+	a.Send(Alert{Kind: MissedProposal, Epoch: 10, PoolName: "pool_b", Message: "c"})
+	now = now.Add(time.Hour)
+	a.Send(Alert{Kind: MissedProposal, Epoch: 11, PoolName: "pool_b", Message: "d"})
+	require.Equal(t, []string{
+		"[missed_attestations] pool pool_b, epoch 10: b",
+		"[missed_proposal] pool pool_b, epoch 11: d",
+	}, received)
+
+	// The expired silence is not listed
+	require.Len(t, a.Silences(), 1)
+	require.True(t, a.RemoveSilence(1))
+	require.False(t, a.RemoveSilence(1))
+	a.Send(Alert{Kind: MissedAttestations, Epoch: 11, PoolName: "pool_a", Message: "e"})
+	require.Len(t, received, 3)
+
+	_, err = NewAlerts(&config.Config{AlertSilences: []string{"pool_a"}}, nil)
+	require.ErrorContains(t, err, "pool:kind")
+}
+
+func Test_SendTelegram(t *testing.T) {
+	var path string
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+	}))
+	defer server.Close()
+	telegramApiUrl = server.URL
+	defer func() { telegramApiUrl = telegram.ApiUrl }()
+
+	a, err := NewAlerts(&config.Config{
+		TelegramToken:  "123:abc",
+		TelegramChatId: 42,
+		AlertRoutes:    []string{"*:warning:telegram"},
+	}, nil)
+	require.NoError(t, err)
+
+	a.Send(Alert{Kind: MissedProposal, Epoch: 10, PoolName: "pool_a", Message: "missed"})
+	require.Equal(t, "/bot123:abc/sendMessage", path)
+	require.Equal(t, float64(42), payload["chat_id"])
+	require.Equal(t, "[missed_proposal] pool pool_a, epoch 10: missed", payload["text"])
+}
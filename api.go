@@ -0,0 +1,347 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bilinearlabs/eth-metrics/alerts"
+	"github.com/bilinearlabs/eth-metrics/auth"
+	"github.com/bilinearlabs/eth-metrics/config"
+	database "github.com/bilinearlabs/eth-metrics/db"
+	"github.com/bilinearlabs/eth-metrics/metrics"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Validators to add to or remove from a pool, from the given epoch on or from
+// the current one if not set
+type poolKeysRequest struct {
+	Keys  []string `json:"keys"`
+	Epoch *uint64  `json:"epoch"`
+}
+
+// Alerts to silence, for the given duration, e.g. 2h, or until removed if not
+// set. Empty pool or kind match any.
+type silenceRequest struct {
+	PoolName string           `json:"pool"`
+	Kind     alerts.AlertKind `json:"kind"`
+	Duration string           `json:"duration"`
+	Comment  string           `json:"comment"`
+}
+
+// Window in which the pool, or all of them with *, is expected to miss duties,
+// from start or from now if not set, until end or for the given duration, e.g.
+// 2h
+type maintenanceRequest struct {
+	PoolName string     `json:"pool"`
+	Start    *time.Time `json:"start"`
+	End      *time.Time `json:"end"`
+	Duration string     `json:"duration"`
+	Comment  string     `json:"comment"`
+}
+
+// Key of the principal of the request in the gin context
+const principalKey = "principal"
+
+// Registers the admin endpoints, which change the tracked validators, silence
+// alerts and schedule maintenance windows at runtime, if any credential is
+// accepted. Listing requires any role, and changes the admin role. The
+// principals of a tenant only have access to its pools.
+func registerAdminRoutes(r *gin.Engine, m *metrics.Metrics, authn *auth.Authenticator) {
+	if !authn.Enabled() {
+		log.Info("Admin api disabled, set --admin-token, --api-key or --jwt-secret to enable it")
+		return
+	}
+	api := r.Group("/api/v1", authenticate(authn))
+	admin := requireRole(config.RoleAdmin)
+	api.POST("/pools/:pool/keys", admin, poolKeysHandler(m, schemas.MembershipJoin))
+	api.DELETE("/pools/:pool/keys", admin, poolKeysHandler(m, schemas.MembershipLeave))
+	api.GET("/alerts/silences", func(c *gin.Context) {
+		silences := make([]alerts.Silence, 0)
+		for _, silence := range m.Alerts().Silences() {
+			if canAccessPool(c, m, silence.PoolName) {
+				silences = append(silences, silence)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"silences": silences})
+	})
+	api.POST("/alerts/silences", admin, addSilenceHandler(m))
+	api.DELETE("/alerts/silences/:id", admin, removeSilenceHandler(m))
+	api.GET("/maintenance", func(c *gin.Context) {
+		windows := make([]schemas.MaintenanceWindow, 0)
+		for _, window := range m.MaintenanceWindows() {
+			if canAccessPool(c, m, window.PoolName) {
+				windows = append(windows, window)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"windows": windows})
+	})
+	api.POST("/maintenance", admin, addMaintenanceHandler(m))
+	api.DELETE("/maintenance/:id", admin, removeMaintenanceHandler(m))
+}
+
+// Lists the upcoming proposals and sync committee duties of the tracked
+// validators, only of the given pool if set, and of the pools of the tenant
+// if the token is of one
+func upcomingDutiesHandler(m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		duties := make([]schemas.UpcomingDuty, 0)
+		for _, duty := range m.UpcomingDuties(c.Query("pool")) {
+			if canAccessPool(c, m, duty.PoolName) {
+				duties = append(duties, duty)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"duties": duties})
+	}
+}
+
+// Returns the rows of the pool in a table of the epochs, between the from and to
+// epochs, both included, so that the tenants can read the metrics of their
+// pools, since /query exposes all of them
+func poolRowsHandler(m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		poolName := c.Param("pool")
+		if !canAccessPool(c, m, poolName) {
+			forbidden(c, poolName)
+			return
+		}
+		table := c.Param("table")
+		if hasPool, ok := database.EpochTables[table]; !ok || !hasPool {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Table without pools: " + table})
+			return
+		}
+		fromEpoch, fromErr := strconv.ParseUint(c.Query("from"), 10, 64)
+		toEpoch, toErr := strconv.ParseUint(c.Query("to"), 10, 64)
+		if fromErr != nil || toErr != nil || fromEpoch > toEpoch {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from and to epochs"})
+			return
+		}
+
+		columns, rows, err := m.PoolRows(table, fromEpoch, toEpoch, poolName)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"columns": columns, "rows": rows})
+	}
+}
+
+// Sets the principal of the bearer token of the request in the context
+func authenticate(authn *auth.Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, err := authn.Authenticate(c.GetHeader("Authorization"))
+		if err != nil {
+			log.Debug("Unauthorized api request: ", err)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+// Requires the authenticated principal to have the role
+func requireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !principalOf(c).HasRole(role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Requires the " + role + " role"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Rejects the principals of a tenant, for the endpoints that expose all pools
+func operatorOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if principalOf(c).Tenant != "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Not allowed for tenants"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Returns the principal of the request, an admin if authentication is not
+// required
+func principalOf(c *gin.Context) auth.Principal {
+	if principal, ok := c.Get(principalKey); ok {
+		return principal.(auth.Principal)
+	}
+	return auth.Principal{Role: config.RoleAdmin}
+}
+
+// Returns true if the principal is of the operator, or of the tenant of the
+// pool. Tenants can't use the empty or * pool, which match all of them.
+func canAccessPool(c *gin.Context, m *metrics.Metrics, poolName string) bool {
+	tenant := principalOf(c).Tenant
+	return tenant == "" || (poolName != "" && poolName != "*" && m.PoolTenant(poolName) == tenant)
+}
+
+func forbidden(c *gin.Context, poolName string) {
+	c.JSON(http.StatusForbidden, gin.H{"error": "Pool not allowed for the tenant: " + poolName})
+}
+
+// Adds or removes the validators of the pool. They are applied before the
+// next epoch is processed, so the request is only accepted.
+func poolKeysHandler(m *metrics.Metrics, action schemas.MembershipAction) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request poolKeysRequest
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+		if !canAccessPool(c, m, c.Param("pool")) {
+			forbidden(c, c.Param("pool"))
+			return
+		}
+		epoch := m.CurrentEpoch()
+		if request.Epoch != nil {
+			epoch = *request.Epoch
+		}
+
+		changes, err := m.ChangePoolMembership(c.Param("pool"), request.Keys, action, epoch)
+		if errors.Is(err, metrics.ErrKeyOfOtherTenant) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.WithFields(log.Fields{
+			"PoolName": c.Param("pool"),
+			"Action":   action,
+			"Epoch":    epoch,
+		}).Info("Pool membership change of ", len(changes), " validators requested")
+		c.JSON(http.StatusAccepted, gin.H{"pool": c.Param("pool"), "action": action, "epoch": epoch, "keys": len(changes)})
+	}
+}
+
+func addSilenceHandler(m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request silenceRequest
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+		if !canAccessPool(c, m, request.PoolName) {
+			forbidden(c, request.PoolName)
+			return
+		}
+		silence := alerts.Silence{PoolName: request.PoolName, Kind: request.Kind, Comment: request.Comment}
+		if request.Duration != "" {
+			duration, err := time.ParseDuration(request.Duration)
+			if err != nil || duration <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid duration: " + request.Duration})
+				return
+			}
+			silence.Until = time.Now().Add(duration)
+		}
+
+		silence = m.Alerts().AddSilence(silence)
+		log.WithFields(log.Fields{
+			"PoolName": silence.PoolName,
+			"Kind":     silence.Kind,
+			"Until":    silence.Until,
+		}).Info("Alerts silenced: ", silence.Comment)
+		c.JSON(http.StatusCreated, silence)
+	}
+}
+
+func removeSilenceHandler(m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid silence id"})
+			return
+		}
+		// The silences of other tenants are not found
+		for _, silence := range m.Alerts().Silences() {
+			if silence.Id == id && !canAccessPool(c, m, silence.PoolName) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Silence not found"})
+				return
+			}
+		}
+		if !m.Alerts().RemoveSilence(id) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Silence not found"})
+			return
+		}
+		log.WithField("SilenceId", id).Info("Silence removed")
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func addMaintenanceHandler(m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request maintenanceRequest
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+		if !canAccessPool(c, m, request.PoolName) {
+			forbidden(c, request.PoolName)
+			return
+		}
+		window := schemas.MaintenanceWindow{PoolName: request.PoolName, Start: time.Now(), Comment: request.Comment}
+		if request.Start != nil {
+			window.Start = *request.Start
+		}
+		switch {
+		case request.End != nil && request.Duration == "":
+			window.End = *request.End
+		case request.End == nil && request.Duration != "":
+			duration, err := time.ParseDuration(request.Duration)
+			if err != nil || duration <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid duration: " + request.Duration})
+				return
+			}
+			window.End = window.Start.Add(duration)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Either end or duration must be set"})
+			return
+		}
+
+		window, err := m.AddMaintenanceWindow(window)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.WithFields(log.Fields{
+			"PoolName": window.PoolName,
+			"Start":    window.Start,
+			"End":      window.End,
+		}).Info("Maintenance window added: ", window.Comment)
+		c.JSON(http.StatusCreated, window)
+	}
+}
+
+func removeMaintenanceHandler(m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid maintenance window id"})
+			return
+		}
+		for _, window := range m.MaintenanceWindows() {
+			if window.Id == id && !canAccessPool(c, m, window.PoolName) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Maintenance window not found"})
+				return
+			}
+		}
+		removed, err := m.RemoveMaintenanceWindow(id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !removed {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Maintenance window not found"})
+			return
+		}
+		log.WithField("MaintenanceWindowId", id).Info("Maintenance window removed")
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/pkg/errors"
+)
+
+// Who sent a request to the api, and what it can do
+type Principal struct {
+	Role string
+	// Tenant whose pools are the only ones accessible, empty for the operator
+	Tenant string
+}
+
+// Returns true if the principal has the role, admins having all of them
+func (p Principal) HasRole(role string) bool {
+	return p.Role == config.RoleAdmin || p.Role == role
+}
+
+// Claims of the json web tokens accepted by the api
+type Claims struct {
+	Role   string `json:"role"`
+	Tenant string `json:"tenant,omitempty"`
+	// Unix times, expiration being required
+	ExpiresAt int64 `json:"exp"`
+	NotBefore int64 `json:"nbf,omitempty"`
+}
+
+type credential struct {
+	token     []byte
+	principal Principal
+}
+
+// Checks the bearer tokens of the api: the admin token, the tokens of the
+// tenants, the api keys and the json web tokens signed with the secret
+type Authenticator struct {
+	credentials []credential
+	jwtSecret   []byte
+	tenants     map[string]bool
+	// Reading the metrics requires a credential too
+	readRequired bool
+	now          func() time.Time
+}
+
+func NewAuthenticator(cfg *config.Config) (*Authenticator, error) {
+	a := &Authenticator{
+		credentials: make([]credential, 0),
+		jwtSecret:   []byte(cfg.JwtSecret),
+		tenants:     make(map[string]bool),
+		// The admin token alone only protects the admin api, as it always did
+		readRequired: len(cfg.ApiKeys) > 0 || cfg.JwtSecret != "" || len(cfg.Tenants) > 0,
+		now:          time.Now,
+	}
+	if cfg.AdminToken != "" {
+		a.credentials = append(a.credentials, credential{
+			token:     []byte(cfg.AdminToken),
+			principal: Principal{Role: config.RoleAdmin},
+		})
+	}
+	for _, tenant := range cfg.Tenants {
+		a.tenants[tenant.Id] = true
+		a.credentials = append(a.credentials, credential{
+			token:     []byte(tenant.Token),
+			principal: Principal{Role: config.RoleAdmin, Tenant: tenant.Id},
+		})
+	}
+	for _, value := range cfg.ApiKeys {
+		role, key, found := strings.Cut(value, ":")
+		if !found || key == "" {
+			return nil, errors.New("--api-key must be role:key")
+		}
+		if !slices.Contains(config.Roles, role) {
+			return nil, errors.New("unknown role of --api-key: " + role + ", expected one of " + strings.Join(config.Roles, "|"))
+		}
+		a.credentials = append(a.credentials, credential{token: []byte(key), principal: Principal{Role: role}})
+	}
+	return a, nil
+}
+
+// Returns true if reading the metrics requires a credential, which is the case
+// with api keys, json web tokens or tenants
+func (a *Authenticator) ReadRequired() bool {
+	return a.readRequired
+}
+
+// Returns true if any credential is accepted
+func (a *Authenticator) Enabled() bool {
+	return len(a.jwtSecret) > 0 || len(a.credentials) > 0
+}
+
+// Returns the principal of the Authorization header, as 'Bearer <token>'
+func (a *Authenticator) Authenticate(authorization string) (Principal, error) {
+	token, found := strings.CutPrefix(authorization, "Bearer ")
+	if !found || token == "" {
+		return Principal{}, errors.New("missing bearer token")
+	}
+	for _, c := range a.credentials {
+		if subtle.ConstantTimeCompare([]byte(token), c.token) == 1 {
+			return c.principal, nil
+		}
+	}
+	if len(a.jwtSecret) > 0 && strings.Count(token, ".") == 2 {
+		claims, err := VerifyJWT(token, a.jwtSecret, a.now())
+		if err != nil {
+			return Principal{}, err
+		}
+		if !slices.Contains(config.Roles, claims.Role) {
+			return Principal{}, errors.New("unknown role in token: " + claims.Role)
+		}
+		if claims.Tenant != "" && !a.tenants[claims.Tenant] {
+			return Principal{}, errors.New("unknown tenant in token: " + claims.Tenant)
+		}
+		return Principal{Role: claims.Role, Tenant: claims.Tenant}, nil
+	}
+	return Principal{}, errors.New("invalid token")
+}
+
+// Verifies a json web token signed with HS256, returning its claims if the
+// signature is valid and it did not expire
+func VerifyJWT(token string, secret []byte, now time.Time) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Claims{}, errors.Wrap(err, "malformed token header")
+	}
+	// Other algorithms, and none in particular, are never accepted
+	if header.Alg != "HS256" {
+		return Claims{}, errors.New("token algorithm not supported: " + header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, errors.Wrap(err, "malformed token signature")
+	}
+	if !hmac.Equal(signature, sign(parts[0]+"."+parts[1], secret)) {
+		return Claims{}, errors.New("invalid token signature")
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Claims{}, errors.Wrap(err, "malformed token claims")
+	}
+	if claims.ExpiresAt == 0 || !now.Before(time.Unix(claims.ExpiresAt, 0)) {
+		return Claims{}, errors.New("token expired or without expiration")
+	}
+	if claims.NotBefore != 0 && now.Before(time.Unix(claims.NotBefore, 0)) {
+		return Claims{}, errors.New("token not valid yet")
+	}
+	return claims, nil
+}
+
+// Returns a json web token with the claims signed with HS256, e.g. to hand
+// out read-only access to a dashboard. Any claims that marshal to a json
+// object can be signed.
+func SignJWT(claims interface{}, secret []byte) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sign(unsigned, secret)), nil
+}
+
+func sign(unsigned string, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return mac.Sum(nil)
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
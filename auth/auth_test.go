@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Authenticate(t *testing.T) {
+	a, err := NewAuthenticator(&config.Config{
+		AdminToken: "admin",
+		ApiKeys:    []string{"read:reader", "admin:ops"},
+		Tenants:    []config.Tenant{{Id: "tenant_a", Token: "tenant"}},
+	})
+	require.NoError(t, err)
+	require.True(t, a.Enabled())
+	require.True(t, a.ReadRequired())
+
+	principal, err := a.Authenticate("Bearer admin")
+	require.NoError(t, err)
+	require.Equal(t, Principal{Role: config.RoleAdmin}, principal)
+	principal, err = a.Authenticate("Bearer reader")
+	require.NoError(t, err)
+	require.Equal(t, Principal{Role: config.RoleRead}, principal)
+	require.True(t, principal.HasRole(config.RoleRead))
+	require.False(t, principal.HasRole(config.RoleAdmin))
+	principal, err = a.Authenticate("Bearer ops")
+	require.NoError(t, err)
+	require.True(t, principal.HasRole(config.RoleRead))
+	principal, err = a.Authenticate("Bearer tenant")
+	require.NoError(t, err)
+	require.Equal(t, Principal{Role: config.RoleAdmin, Tenant: "tenant_a"}, principal)
+
+	_, err = a.Authenticate("Bearer other")
+	require.ErrorContains(t, err, "invalid token")
+	_, err = a.Authenticate("admin")
+	require.ErrorContains(t, err, "missing bearer token")
+	_, err = a.Authenticate("")
+	require.ErrorContains(t, err, "missing bearer token")
+
+	// The admin token alone does not protect reading the metrics
+	a, err = NewAuthenticator(&config.Config{AdminToken: "admin"})
+	require.NoError(t, err)
+	require.False(t, a.ReadRequired())
+	a, err = NewAuthenticator(&config.Config{})
+	require.NoError(t, err)
+	require.False(t, a.Enabled())
+
+	_, err = NewAuthenticator(&config.Config{ApiKeys: []string{"write:key"}})
+	require.ErrorContains(t, err, "unknown role")
+	_, err = NewAuthenticator(&config.Config{ApiKeys: []string{"key"}})
+	require.ErrorContains(t, err, "role:key")
+}
+
+func Test_AuthenticateJWT(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Unix(1700000000, 0)
+	a, err := NewAuthenticator(&config.Config{
+		JwtSecret: string(secret),
+		Tenants:   []config.Tenant{{Id: "tenant_a", Token: "tenant"}},
+	})
+	require.NoError(t, err)
+	a.now = func() time.Time { return now }
+	require.True(t, a.ReadRequired())
+
+	token := func(claims Claims) string {
+		signed, err := SignJWT(claims, secret)
+		require.NoError(t, err)
+		return "Bearer " + signed
+	}
+	expiresAt := now.Add(time.Hour).Unix()
+
+	principal, err := a.Authenticate(token(Claims{Role: config.RoleRead, ExpiresAt: expiresAt}))
+	require.NoError(t, err)
+	require.Equal(t, Principal{Role: config.RoleRead}, principal)
+	principal, err = a.Authenticate(token(Claims{Role: config.RoleAdmin, Tenant: "tenant_a", ExpiresAt: expiresAt}))
+	require.NoError(t, err)
+	require.Equal(t, Principal{Role: config.RoleAdmin, Tenant: "tenant_a"}, principal)
+
+	_, err = a.Authenticate(token(Claims{Role: config.RoleRead, ExpiresAt: now.Unix()}))
+	require.ErrorContains(t, err, "expired")
+	_, err = a.Authenticate(token(Claims{Role: config.RoleRead}))
+	require.ErrorContains(t, err, "without expiration")
+	_, err = a.Authenticate(token(Claims{Role: config.RoleRead, ExpiresAt: expiresAt, NotBefore: expiresAt}))
+	require.ErrorContains(t, err, "not valid yet")
+	_, err = a.Authenticate(token(Claims{Role: "owner", ExpiresAt: expiresAt}))
+	require.ErrorContains(t, err, "unknown role")
+	_, err = a.Authenticate(token(Claims{Role: config.RoleRead, Tenant: "tenant_b", ExpiresAt: expiresAt}))
+	require.ErrorContains(t, err, "unknown tenant")
+
+	// Signed with another secret
+	signed, err := SignJWT(Claims{Role: config.RoleAdmin, ExpiresAt: expiresAt}, []byte("other"))
+	require.NoError(t, err)
+	_, err = a.Authenticate("Bearer " + signed)
+	require.ErrorContains(t, err, "invalid token signature")
+
+	// Unsigned, with the none algorithm
+	parts := strings.Split(signed, ".")
+	_, err = a.Authenticate("Bearer eyJhbGciOiJub25lIn0." + parts[1] + ".")
+	require.ErrorContains(t, err, "algorithm not supported: none")
+}
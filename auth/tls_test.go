@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Returns a certificate signed by the parent, or self-signed if nil, and its
+// key, in PEM
+func newCert(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, []byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  parent == nil,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return cert, key,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func Test_NewServerTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey, caPem, _ := newCert(t, "ca", nil, nil)
+	_, _, serverPem, serverKeyPem := newCert(t, "localhost", ca, caKey)
+	_, _, clientPem, clientKeyPem := newCert(t, "client", ca, caKey)
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, data, 0600))
+		return path
+	}
+	certFile := write("server.pem", serverPem)
+	keyFile := write("server-key.pem", serverKeyPem)
+	caFile := write("ca.pem", caPem)
+
+	tlsConfig, err := NewServerTLSConfig("", "", "")
+	require.NoError(t, err)
+	require.Nil(t, tlsConfig)
+
+	tlsConfig, err = NewServerTLSConfig(certFile, keyFile, caFile)
+	require.NoError(t, err)
+	require.Equal(t, tls.RequireAndVerifyClientCert, tlsConfig.ClientAuth)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = tlsConfig
+	server.StartTLS()
+	defer server.Close()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(ca)
+	client := func(certificates ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      rootCAs,
+			Certificates: certificates,
+			ServerName:   "localhost",
+		}}}
+	}
+
+	// Without a client certificate the handshake fails
+	_, err = client().Get(server.URL)
+	require.Error(t, err)
+
+	clientCert, err := tls.X509KeyPair(clientPem, clientKeyPem)
+	require.NoError(t, err)
+	resp, err := client(clientCert).Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	_, err = NewServerTLSConfig(certFile, filepath.Join(dir, "missing.pem"), "")
+	require.ErrorContains(t, err, "could not check tls certificate")
+	_, err = NewServerTLSConfig(certFile, keyFile, certFile+"x")
+	require.ErrorContains(t, err, "could not read tls client ca")
+	_, err = NewServerTLSConfig(certFile, keyFile, keyFile)
+	require.ErrorContains(t, err, "no certificates found")
+}
+
+func Test_CertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	_, _, certPem, keyPem := newCert(t, "first", nil, nil)
+	require.NoError(t, os.WriteFile(certFile, certPem, 0600))
+	require.NoError(t, os.WriteFile(keyFile, keyPem, 0600))
+
+	reloader := &certReloader{certFile: certFile, keyFile: keyFile}
+	require.NoError(t, reloader.reload())
+	cert, err := reloader.getCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, "first", cert.Leaf.Subject.CommonName)
+
+	// Only the certificate was renewed yet, so the key doesn't match
+	_, _, certPem, keyPem = newCert(t, "second", nil, nil)
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.WriteFile(certFile, certPem, 0600))
+	require.NoError(t, os.Chtimes(certFile, later, later))
+	cert, err = reloader.getCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, "first", cert.Leaf.Subject.CommonName)
+
+	later = later.Add(time.Minute)
+	require.NoError(t, os.WriteFile(keyFile, keyPem, 0600))
+	require.NoError(t, os.Chtimes(keyFile, later, later))
+	cert, err = reloader.getCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, "second", cert.Leaf.Subject.CommonName)
+}
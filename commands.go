@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/bilinearlabs/eth-metrics/config"
+	database "github.com/bilinearlabs/eth-metrics/db"
+	"github.com/bilinearlabs/eth-metrics/export"
+	"github.com/bilinearlabs/eth-metrics/metrics"
+	"github.com/bilinearlabs/eth-metrics/top"
+	"github.com/pkg/errors"
+)
+
+// Processes the epochs between --from and --to and exits
+func backfill(config *config.Config) error {
+	metrics, err := metrics.NewMetrics(context.Background(), config)
+	if err != nil {
+		return err
+	}
+	return metrics.Backfill(config.FromEpoch, config.ToEpoch)
+}
+
+// Processes a stored epoch again, logging the values that changed
+func reprocess(config *config.Config) error {
+	if _, err := os.Stat(config.DatabasePath); err != nil {
+		return errors.Wrap(err, "could not find database")
+	}
+	metrics, err := metrics.NewMetrics(context.Background(), config)
+	if err != nil {
+		return err
+	}
+	return metrics.Reprocess(config.InspectEpoch, config.InspectPool)
+}
+
+// Opens an existing database, adding the tables that it may lack if it was
+// created by an older version
+func openDatabase(config *config.Config) (*database.Database, error) {
+	if _, err := os.Stat(config.DatabasePath); err != nil {
+		return nil, errors.Wrap(err, "could not find database")
+	}
+	db, err := database.NewWithOptions(config.DatabasePath, database.OptionsFromConfig(config))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open database")
+	}
+	if err := db.CreateTables(); err != nil {
+		return nil, errors.Wrap(err, "could not create tables")
+	}
+	return db, nil
+}
+
+// Writes as json the stored rows of an epoch, optionally only of a pool
+func inspect(config *config.Config, w io.Writer) error {
+	db, err := openDatabase(config)
+	if err != nil {
+		return err
+	}
+
+	tables := make([]string, 0, len(database.EpochTables))
+	for table, hasPool := range database.EpochTables {
+		if config.InspectPool == "" || hasPool {
+			tables = append(tables, table)
+		}
+	}
+	sort.Strings(tables)
+
+	result := make(map[string][]map[string]interface{})
+	for _, table := range tables {
+		columns, rows, err := db.GetEpochRows(table, config.InspectEpoch, config.InspectEpoch, config.InspectPool)
+		if err != nil {
+			return err
+		}
+		if len(rows) > 0 {
+			result[table] = export.ToMaps(columns, rows)
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
+
+// Writes the rows of a table between the given epochs or times as csv, json or
+// parquet
+func exportRows(config *config.Config, w io.Writer) error {
+	db, err := openDatabase(config)
+	if err != nil {
+		return err
+	}
+
+	fromEpoch, toEpoch := config.FromEpoch, config.ToEpoch
+	if !config.ExportFromTime.IsZero() {
+		if fromEpoch, err = export.EpochAt(config.Network, config.ExportFromTime); err != nil {
+			return err
+		}
+	}
+	if !config.ExportToTime.IsZero() {
+		if toEpoch, err = export.EpochAt(config.Network, config.ExportToTime); err != nil {
+			return err
+		}
+	}
+
+	columns, rows, err := db.GetEpochRows(config.ExportTable, fromEpoch, toEpoch, config.InspectPool)
+	if err != nil {
+		return err
+	}
+
+	return export.Write(w, config.ExportFormat, columns, rows)
+}
+
+// Writes the payout statement of each pool between the given epochs or times
+// as csv or json. Amounts are written as strings of wei, so that they are exact.
+func statement(config *config.Config, w io.Writer) error {
+	db, err := openDatabase(config)
+	if err != nil {
+		return err
+	}
+
+	// Each epoch belongs to the period it starts in, so that the statements of
+	// consecutive periods add up
+	fromEpoch, toEpoch := config.FromEpoch, config.ToEpoch
+	if !config.ExportFromTime.IsZero() {
+		if fromEpoch, err = export.FirstEpochFrom(config.Network, config.ExportFromTime); err != nil {
+			return err
+		}
+	}
+	if !config.ExportToTime.IsZero() {
+		nextEpoch, err := export.FirstEpochFrom(config.Network, config.ExportToTime)
+		if err != nil {
+			return err
+		}
+		if nextEpoch <= fromEpoch {
+			return errors.New("--to-time must be after the start of the period")
+		}
+		toEpoch = nextEpoch - 1
+	}
+
+	statements, err := db.GetPoolStatements(fromEpoch, toEpoch, config.InspectPool)
+	if err != nil {
+		return err
+	}
+
+	columns := []string{
+		"pool",
+		"from_epoch",
+		"to_epoch",
+		"n_epochs",
+		"consensus_rewards_wei",
+		"penalties_wei",
+		"proposer_tips_wei",
+		"mev_rewards_wei",
+		"total_wei",
+		"partial_withdrawals_wei",
+		"full_withdrawals_wei",
+		"mev_partial",
+	}
+	rows := make([][]interface{}, 0, len(statements))
+	for _, s := range statements {
+		rows = append(rows, []interface{}{
+			s.PoolName,
+			s.FromEpoch,
+			s.ToEpoch,
+			s.NOfEpochs,
+			s.ConsensusRewards.String(),
+			s.Penalties.String(),
+			s.ProposerTips.String(),
+			s.MEVRewards.String(),
+			s.Total().String(),
+			s.PartialWithdrawals.String(),
+			s.FullWithdrawals.String(),
+			s.MEVPartial,
+		})
+	}
+	return export.Write(w, config.ExportFormat, columns, rows)
+}
+
+// Shows the live view in the terminal until stopped
+func showTop(config *config.Config, w io.Writer) error {
+	db, err := openDatabase(config)
+	if err != nil {
+		return err
+	}
+	t, err := top.New(config, db)
+	if err != nil {
+		return err
+	}
+	return t.Run(w)
+}
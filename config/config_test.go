@@ -0,0 +1,37 @@
+package config
+
+import (
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseHeaders(t *testing.T) {
+	headers, err := parseHeaders("--eth1-header", []string{"x-api-key: key", "X-Other:value: with colon"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"X-Api-Key": "key", "X-Other": "value: with colon"}, headers)
+
+	headers, err = parseHeaders("--eth2-header", nil)
+	require.NoError(t, err)
+	require.Empty(t, headers)
+
+	_, err = parseHeaders("--eth2-header", []string{"x-api-key"})
+	require.ErrorContains(t, err, "--eth2-header must be 'Name: value'")
+	_, err = parseHeaders("--eth2-header", []string{" : value"})
+	require.ErrorContains(t, err, "--eth2-header must be 'Name: value'")
+}
+
+func Test_ParseConfig_LogFormat(t *testing.T) {
+	hooks := len(log.StandardLogger().Hooks[log.InfoLevel])
+	cfg, err := ParseConfig([]string{"--log-format=json"})
+	require.NoError(t, err)
+	require.Equal(t, "json", cfg.LogFormat)
+	_, err = ParseConfig([]string{"--log-format=json"})
+	require.NoError(t, err)
+	// Logging is set up by the caller, so parsing adds no hooks
+	require.Len(t, log.StandardLogger().Hooks[log.InfoLevel], hooks)
+
+	_, err = ParseConfig([]string{"--log-format=xml"})
+	require.ErrorContains(t, err, "log format not supported: xml")
+}
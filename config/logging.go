@@ -0,0 +1,86 @@
+package config
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	zerologger "github.com/rs/zerolog/log"
+	log "github.com/sirupsen/logrus"
+)
+
+var LogFormats = []string{"text", "json"}
+
+// Sets the format of the logs, text or json, and sends the logs of the
+// dependencies using zerolog through logrus so that all of them look the same.
+// Adds a hook to the logger, so it is called once.
+func SetupLogging(format string) error {
+	switch format {
+	case "text":
+		log.SetFormatter(&log.TextFormatter{})
+	case "json":
+		log.SetFormatter(&log.JSONFormatter{})
+	default:
+		return errors.New("log format not supported: " + format)
+	}
+	log.AddHook(&moduleHook{})
+	zerologger.Logger = zerolog.New(&zerologWriter{})
+	return nil
+}
+
+// Adds the module that logged the entry, as package/file, e.g. metrics/blockdata
+type moduleHook struct{}
+
+func (h *moduleHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (h *moduleHook) Fire(entry *log.Entry) error {
+	if _, ok := entry.Data["Module"]; ok {
+		return nil
+	}
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !strings.Contains(frame.Function, "sirupsen/logrus") && frame.File != "" {
+			entry.Data["Module"] = filepath.Base(filepath.Dir(frame.File)) + "/" +
+				strings.TrimSuffix(filepath.Base(frame.File), ".go")
+			return nil
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+// Receives the json events of zerolog and logs them with logrus
+type zerologWriter struct{}
+
+func (w *zerologWriter) Write(p []byte) (int, error) {
+	fields := make(log.Fields)
+	if err := json.Unmarshal(p, &fields); err != nil {
+		log.Warn(strings.TrimSpace(string(p)))
+		return len(p), nil
+	}
+
+	level, err := log.ParseLevel(fieldString(fields, zerolog.LevelFieldName))
+	if err != nil {
+		level = log.InfoLevel
+	}
+	message := fieldString(fields, zerolog.MessageFieldName)
+	delete(fields, zerolog.LevelFieldName)
+	delete(fields, zerolog.MessageFieldName)
+	fields["Module"] = "eth2client"
+
+	log.WithFields(fields).Log(level, message)
+	return len(p), nil
+}
+
+func fieldString(fields log.Fields, key string) string {
+	value, _ := fields[key].(string)
+	return value
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Staking provider served by a shared instance. It owns some of the pools, and
+// gets their alerts in its own webhooks and their metrics in its own sinks. Its
+// token gives access to the admin api of its pools only.
+type Tenant struct {
+	Id                  string   `json:"id"`
+	Token               string   `json:"token"`
+	Pools               []string `json:"pools"`
+	AlertWebhook        string   `json:"alert_webhook"`
+	AlertSlackWebhook   string   `json:"alert_slack_webhook"`
+	AlertDiscordWebhook string   `json:"alert_discord_webhook"`
+	// Same format as --alert-route, for the webhooks of the tenant
+	AlertRoutes []string `json:"alert_routes"`
+	// Same format as --sink
+	Sinks []string `json:"sinks"`
+}
+
+// Loads the tenants of a json file with an array of them. Ids and tokens must
+// be unique, and differ from the admin token and the api keys, given as
+// role:key, and a pool can only belong to one tenant.
+func loadTenants(path string, adminToken string, apiKeys []string) ([]Tenant, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read tenants file")
+	}
+	var tenants []Tenant
+	if err := json.Unmarshal(data, &tenants); err != nil {
+		return nil, errors.Wrap(err, "could not parse tenants file")
+	}
+
+	ids := make(map[string]bool)
+	// Of the operator, so that a tenant is never authenticated as it
+	tokens := map[string]bool{adminToken: true}
+	for _, apiKey := range apiKeys {
+		_, key, _ := strings.Cut(apiKey, ":")
+		tokens[key] = true
+	}
+	poolTenants := make(map[string]string)
+	for _, tenant := range tenants {
+		if tenant.Id == "" {
+			return nil, errors.New("tenant without id in " + path)
+		}
+		if ids[tenant.Id] {
+			return nil, errors.New("duplicate tenant: " + tenant.Id)
+		}
+		ids[tenant.Id] = true
+		if tenant.Token == "" || tokens[tenant.Token] {
+			return nil, errors.New("tenant " + tenant.Id + " requires a token of its own")
+		}
+		tokens[tenant.Token] = true
+		if len(tenant.Pools) == 0 {
+			return nil, errors.New("tenant " + tenant.Id + " has no pools")
+		}
+		for _, poolName := range tenant.Pools {
+			if poolName == "" || poolName == "*" {
+				return nil, errors.New("invalid pool of tenant " + tenant.Id + ": " + poolName)
+			}
+			if other, ok := poolTenants[poolName]; ok {
+				return nil, errors.New("pool " + poolName + " belongs to tenants " + other + " and " + tenant.Id)
+			}
+			poolTenants[poolName] = tenant.Id
+		}
+	}
+	return tenants, nil
+}
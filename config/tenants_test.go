@@ -0,0 +1,34 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LoadTenants(t *testing.T) {
+	load := func(tenants string) ([]Tenant, error) {
+		path := filepath.Join(t.TempDir(), "tenants.json")
+		require.NoError(t, os.WriteFile(path, []byte(tenants), 0o600))
+		return loadTenants(path, "admin", []string{"read:reader", "admin:ops"})
+	}
+
+	tenants, err := load(`[{"id": "tenant_a", "token": "a", "pools": ["pool_a"]}]`)
+	require.NoError(t, err)
+	require.Equal(t, []Tenant{{Id: "tenant_a", Token: "a", Pools: []string{"pool_a"}}}, tenants)
+
+	// Tokens of the operator
+	for _, token := range []string{"", "admin", "reader", "ops"} {
+		_, err = load(`[{"id": "tenant_a", "token": "` + token + `", "pools": ["pool_a"]}]`)
+		require.ErrorContains(t, err, "requires a token of its own")
+	}
+
+	_, err = load(`[{"id": "tenant_a", "token": "a", "pools": ["pool_a"]}, {"id": "tenant_b", "token": "a", "pools": ["pool_b"]}]`)
+	require.ErrorContains(t, err, "requires a token of its own")
+	_, err = load(`[{"id": "tenant_a", "token": "a", "pools": ["pool_a"]}, {"id": "tenant_b", "token": "b", "pools": ["pool_a"]}]`)
+	require.ErrorContains(t, err, "belongs to tenants tenant_a and tenant_b")
+	_, err = load(`[{"id": "tenant_a", "token": "a", "pools": ["*"]}]`)
+	require.ErrorContains(t, err, "invalid pool")
+}
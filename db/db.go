@@ -3,16 +3,41 @@ package db
 import (
 	"context"
 	"database/sql"
-	"sort"
+	"encoding/json"
+	"math/big"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/bilinearlabs/eth-metrics/config"
 	"github.com/bilinearlabs/eth-metrics/schemas"
 	"github.com/pkg/errors"
-	_ "modernc.org/sqlite"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
 )
 
+// Attempts of a write while the database is busy, waiting twice as long after
+// each one
+const (
+	busyRetries      = 5
+	busyRetryBackoff = 100 * time.Millisecond
+)
+
+var dbWriteDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "ethmetrics_db_write_duration_seconds",
+	Help:    "Duration of the writes to each table of the database",
+	Buckets: prometheus.DefBuckets,
+}, []string{"table"})
+
+// Records the duration of a write started at start, meant to be deferred
+func observeWrite(table string, start time.Time) {
+	dbWriteDuration.WithLabelValues(table).Observe(time.Since(start).Seconds())
+}
+
 var createPoolsMetricsTable = `
 CREATE TABLE IF NOT EXISTS t_pools_metrics_summary (
      f_timestamp TIMESTAMPTZ NOT NULL,
@@ -31,8 +56,31 @@ CREATE TABLE IF NOT EXISTS t_pools_metrics_summary (
 	 f_epoch_earned_balance_gwei BIGINT,
 	 f_epoch_lost_balace_gwei BIGINT,
 	 f_epoch_effective_balance_gwei BIGINT,
-	 f_mev_rewards_wei BIGINT,
-	 f_proposer_tips_wei BIGINT,
+	 f_mev_rewards_wei TEXT,
+	 f_proposer_tips_wei TEXT,
+	 f_avg_inclusion_delay FLOAT,
+	 f_max_inclusion_delay BIGINT,
+	 f_n_slashed_validators BIGINT,
+	 f_head_rewards_gwei BIGINT,
+	 f_target_rewards_gwei BIGINT,
+	 f_source_rewards_gwei BIGINT,
+	 f_inclusion_delay_rewards_gwei BIGINT,
+	 f_inactivity_penalties_gwei BIGINT,
+	 f_ideal_attestation_rewards_gwei BIGINT,
+	 f_processing_mode TEXT,
+	 f_eth_price_usd FLOAT,
+	 f_earned_usd FLOAT,
+	 f_mev_rewards_usd FLOAT,
+	 f_mev_partial BOOLEAN,
+	 f_sync_committee_rewards_gwei BIGINT,
+	 f_proposer_rewards_gwei BIGINT,
+	 f_effective_balance_change_gwei BIGINT,
+	 f_n_compounding_validators BIGINT,
+	 f_partial_withdrawals_gwei BIGINT,
+	 f_full_withdrawals_gwei BIGINT,
+	 f_n_full_withdrawals BIGINT,
+	 f_parent_group TEXT,
+	 f_n_pools BIGINT,
 
 	 f_n_scheduled_blocks BIGINT,
 	 f_n_proposed_blocks BIGINT,
@@ -41,12 +89,330 @@ CREATE TABLE IF NOT EXISTS t_pools_metrics_summary (
 );
 `
 
+// Columns that were added after the table was created. CREATE TABLE IF NOT EXISTS
+// does not modify existing tables, so they are added on startup if missing
+var addedColumns = []struct {
+	table      string
+	column     string
+	columnType string
+}{
+	{"t_pools_metrics_summary", "f_avg_inclusion_delay", "FLOAT"},
+	{"t_pools_metrics_summary", "f_max_inclusion_delay", "BIGINT"},
+	{"t_pools_metrics_summary", "f_n_slashed_validators", "BIGINT"},
+	{"t_pools_metrics_summary", "f_head_rewards_gwei", "BIGINT"},
+	{"t_pools_metrics_summary", "f_target_rewards_gwei", "BIGINT"},
+	{"t_pools_metrics_summary", "f_source_rewards_gwei", "BIGINT"},
+	{"t_pools_metrics_summary", "f_inclusion_delay_rewards_gwei", "BIGINT"},
+	{"t_pools_metrics_summary", "f_inactivity_penalties_gwei", "BIGINT"},
+	{"t_pools_metrics_summary", "f_ideal_attestation_rewards_gwei", "BIGINT"},
+	{"t_pools_metrics_summary", "f_processing_mode", "TEXT"},
+	{"t_pools_metrics_summary", "f_eth_price_usd", "FLOAT"},
+	{"t_pools_metrics_summary", "f_earned_usd", "FLOAT"},
+	{"t_pools_metrics_summary", "f_mev_rewards_usd", "FLOAT"},
+	{"t_pools_metrics_summary", "f_mev_partial", "BOOLEAN"},
+	{"t_pools_metrics_summary", "f_sync_committee_rewards_gwei", "BIGINT"},
+	{"t_pools_metrics_daily", "f_sync_committee_rewards_gwei", "BIGINT"},
+	{"t_pools_metrics_summary", "f_proposer_rewards_gwei", "BIGINT"},
+	{"t_pools_metrics_daily", "f_proposer_rewards_gwei", "BIGINT"},
+	{"t_pools_metrics_summary", "f_effective_balance_change_gwei", "BIGINT"},
+	{"t_pools_metrics_summary", "f_n_compounding_validators", "BIGINT"},
+	{"t_pools_metrics_daily", "f_effective_balance_gwei", "BIGINT"},
+	{"t_pools_metrics_daily", "f_n_compounding_validators", "BIGINT"},
+	{"t_pools_metrics_summary", "f_partial_withdrawals_gwei", "BIGINT"},
+	{"t_pools_metrics_summary", "f_full_withdrawals_gwei", "BIGINT"},
+	{"t_pools_metrics_summary", "f_n_full_withdrawals", "BIGINT"},
+	{"t_pools_metrics_daily", "f_partial_withdrawals_gwei", "BIGINT"},
+	{"t_pools_metrics_daily", "f_full_withdrawals_gwei", "BIGINT"},
+	{"t_pools_metrics_daily", "f_n_full_withdrawals", "BIGINT"},
+	{"t_pools_metrics_summary", "f_parent_group", "TEXT"},
+	{"t_pools_metrics_summary", "f_n_pools", "BIGINT"},
+	{"t_relay_stats", "f_skipped", "BOOLEAN"},
+	{"t_proposal_duties", "f_n_missed_empty", "BIGINT"},
+	{"t_proposal_duties", "f_n_missed_orphaned", "BIGINT"},
+	{"t_proposal_duties", "f_n_vanilla_blocks", "BIGINT"},
+	{"t_proposal_duties", "f_vanilla_ratio", "FLOAT"},
+	{"t_network_stats", "f_n_blobs", "BIGINT"},
+	{"t_network_stats", "f_blob_gas_used", "BIGINT"},
+	{"t_network_stats", "f_blob_utilization", "FLOAT"},
+	{"t_network_stats", "f_blob_base_fee_wei", "TEXT"},
+	{"t_proposals", "f_fee_recipient", "TEXT"},
+	{"t_proposals", "f_vanilla", "BOOLEAN"},
+	{"t_proposals", "f_attestations_reward_gwei", "BIGINT"},
+	{"t_proposals", "f_sync_aggregate_reward_gwei", "BIGINT"},
+	{"t_proposals", "f_slashings_reward_gwei", "BIGINT"},
+	// Only the admin api changed the membership before
+	{"t_pool_membership", "f_source", "TEXT DEFAULT 'api'"},
+	{"t_network_stats", "f_source_participation", "FLOAT"},
+	{"t_network_stats", "f_target_participation", "FLOAT"},
+	{"t_network_stats", "f_head_participation", "FLOAT"},
+	{"t_network_stats", "f_attestation_effectiveness", "FLOAT"},
+	{"t_network_stats", "f_n_scheduled_blocks", "BIGINT"},
+	{"t_network_stats", "f_n_proposed_blocks", "BIGINT"},
+	{"t_network_stats", "f_proposal_rate", "FLOAT"},
+	{"t_effectiveness", "f_source_participation_delta", "FLOAT"},
+	{"t_effectiveness", "f_target_participation_delta", "FLOAT"},
+	{"t_effectiveness", "f_head_participation_delta", "FLOAT"},
+	{"t_effectiveness", "f_attestation_delta", "FLOAT"},
+	{"t_effectiveness", "f_proposal_delta", "FLOAT"},
+	{"t_effectiveness", "f_score_delta", "FLOAT"},
+	{"t_effectiveness", "f_maintenance", "BOOLEAN"},
+	{"t_missed_attestations", "f_maintenance", "BOOLEAN"},
+	{"t_proposal_duties", "f_maintenance", "BOOLEAN"},
+}
+
+// Wei columns that were created as BIGINT, which overflows with large amounts,
+// or as FLOAT, which rounds them
+var weiColumns = []struct {
+	table  string
+	column string
+}{
+	{"t_pools_metrics_summary", "f_mev_rewards_wei"},
+	{"t_pools_metrics_summary", "f_proposer_tips_wei"},
+	{"t_pools_metrics_daily", "f_mev_rewards_wei"},
+	{"t_pools_metrics_daily", "f_proposer_tips_wei"},
+}
+
 var createProposalDutiesTable = `
 CREATE TABLE IF NOT EXISTS t_proposal_duties (
 	 f_epoch BIGINT,
 	 f_pool TEXT,
 	 f_n_scheduled_blocks BIGINT,
 	 f_n_proposed_blocks BIGINT,
+	 f_n_missed_empty BIGINT,
+	 f_n_missed_orphaned BIGINT,
+	 f_n_vanilla_blocks BIGINT,
+	 f_vanilla_ratio FLOAT,
+	 f_maintenance BOOLEAN,
+	 PRIMARY KEY (f_epoch, f_pool)
+);
+`
+
+var createValidatorMetricsTable = `
+CREATE TABLE IF NOT EXISTS t_validator_metrics (
+	 f_timestamp TIMESTAMPTZ NOT NULL,
+	 f_epoch BIGINT,
+	 f_pool TEXT,
+	 f_validator_index BIGINT,
+	 f_validator_pubkey TEXT,
+	 f_balance_delta_gwei BIGINT,
+	 f_missed_source BOOLEAN,
+	 f_missed_target BOOLEAN,
+	 f_missed_head BOOLEAN,
+	 f_attestation_included BOOLEAN,
+	 PRIMARY KEY (f_epoch, f_validator_index)
+);
+`
+
+var createMissedAttestationsTable = `
+CREATE TABLE IF NOT EXISTS t_missed_attestations (
+	 f_epoch BIGINT,
+	 f_pool TEXT,
+	 f_validator_index BIGINT,
+	 f_miss_type TEXT,
+	 f_maintenance BOOLEAN,
+	 PRIMARY KEY (f_epoch, f_validator_index, f_miss_type)
+);
+`
+
+// Pools that failed are processed again, up to a few attempts
+var createProcessingStatusTable = `
+CREATE TABLE IF NOT EXISTS t_processing_status (
+	 f_epoch BIGINT,
+	 f_pool TEXT,
+	 f_status TEXT,
+	 f_error TEXT,
+	 f_attempts BIGINT,
+	 f_timestamp TIMESTAMP,
+	 PRIMARY KEY (f_epoch, f_pool)
+);
+`
+
+// Modules that completed for each pool in each epoch. The summary module
+// marks that all the rows of the pool were stored, and the rest the modules
+// whose data they include, since optional modules can fail.
+var createEpochsProcessedTable = `
+CREATE TABLE IF NOT EXISTS t_epochs_processed (
+	 f_epoch BIGINT,
+	 f_pool TEXT,
+	 f_module TEXT,
+	 f_timestamp TIMESTAMP,
+	 PRIMARY KEY (f_epoch, f_pool, f_module)
+);
+`
+
+// Epochs stored by older releases, before the table existed, are complete if
+// the pool has its summary
+var seedEpochsProcessed = `
+INSERT OR IGNORE INTO t_epochs_processed (f_epoch, f_pool, f_module, f_timestamp)
+SELECT DISTINCT f_epoch, f_pool, 'summary', CURRENT_TIMESTAMP
+FROM t_pools_metrics_summary
+WHERE NOT EXISTS (SELECT 1 FROM t_epochs_processed)
+`
+
+const ModuleSummary = "summary"
+
+var createSlashingsTable = `
+CREATE TABLE IF NOT EXISTS t_slashings (
+	 f_epoch BIGINT,
+	 f_slot BIGINT,
+	 f_validator_index BIGINT,
+	 f_pool TEXT,
+	 f_slashing_type TEXT,
+	 PRIMARY KEY (f_slot, f_validator_index, f_slashing_type)
+);
+`
+
+var createVoluntaryExitsTable = `
+CREATE TABLE IF NOT EXISTS t_voluntary_exits (
+	 f_epoch BIGINT,
+	 f_slot BIGINT,
+	 f_validator_index BIGINT,
+	 f_pool TEXT,
+	 f_exit_epoch BIGINT,
+	 PRIMARY KEY (f_slot, f_validator_index)
+);
+`
+
+// Deposits to tracked validators. The activation columns are set once the
+// validator is activated, for the deposits made before
+var createDepositsTable = `
+CREATE TABLE IF NOT EXISTS t_deposits (
+	 f_epoch BIGINT,
+	 f_slot BIGINT,
+	 f_block_number BIGINT,
+	 f_timestamp BIGINT,
+	 f_deposit_index BIGINT,
+	 f_pool TEXT,
+	 f_validator_pubkey TEXT,
+	 f_withdrawal_credentials TEXT,
+	 f_amount_gwei BIGINT,
+	 f_activation_epoch BIGINT,
+	 f_activation_latency_seconds BIGINT,
+	 PRIMARY KEY (f_deposit_index)
+);
+`
+
+var createClientDiversityTable = `
+CREATE TABLE IF NOT EXISTS t_client_diversity (
+	 f_epoch BIGINT,
+	 f_pool TEXT,
+	 f_consensus_client TEXT,
+	 f_execution_client TEXT,
+	 f_n_blocks BIGINT,
+	 PRIMARY KEY (f_epoch, f_pool, f_consensus_client, f_execution_client)
+);
+`
+
+var createBLSChangesTable = `
+CREATE TABLE IF NOT EXISTS t_bls_changes (
+	 f_epoch BIGINT,
+	 f_slot BIGINT,
+	 f_validator_index BIGINT,
+	 f_pool TEXT,
+	 f_execution_address TEXT,
+	 PRIMARY KEY (f_slot, f_validator_index)
+);
+`
+
+// Execution layer requests of tracked validators, since electra. A withdrawal
+// request of 0 gwei is a full exit
+var createWithdrawalRequestsTable = `
+CREATE TABLE IF NOT EXISTS t_withdrawal_requests (
+	 f_epoch BIGINT,
+	 f_slot BIGINT,
+	 f_block_number BIGINT,
+	 f_request_index BIGINT,
+	 f_pool TEXT,
+	 f_source_address TEXT,
+	 f_validator_pubkey TEXT,
+	 f_amount_gwei BIGINT,
+	 PRIMARY KEY (f_slot, f_request_index)
+);
+`
+
+var createConsolidationRequestsTable = `
+CREATE TABLE IF NOT EXISTS t_consolidation_requests (
+	 f_epoch BIGINT,
+	 f_slot BIGINT,
+	 f_block_number BIGINT,
+	 f_request_index BIGINT,
+	 f_pool TEXT,
+	 f_source_address TEXT,
+	 f_source_pubkey TEXT,
+	 f_target_pubkey TEXT,
+	 PRIMARY KEY (f_slot, f_request_index)
+);
+`
+
+// Partial withdrawals requested by tracked validators, by the epoch they left
+// the queue, with the amount requested and the one withdrawn
+var createPartialWithdrawalsTable = `
+CREATE TABLE IF NOT EXISTS t_partial_withdrawals (
+	 f_epoch BIGINT,
+	 f_validator_index BIGINT,
+	 f_pool TEXT,
+	 f_n_requests BIGINT,
+	 f_withdrawable_epoch BIGINT,
+	 f_requested_gwei BIGINT,
+	 f_withdrawn_gwei BIGINT,
+	 PRIMARY KEY (f_epoch, f_validator_index)
+);
+`
+
+var createPendingPartialWithdrawalsTable = `
+CREATE TABLE IF NOT EXISTS t_pending_partial_withdrawals (
+	 f_epoch BIGINT,
+	 f_pool TEXT,
+	 f_n_pending BIGINT,
+	 f_pending_gwei BIGINT,
+	 f_last_withdrawable_epoch BIGINT,
+	 PRIMARY KEY (f_epoch, f_pool)
+);
+`
+
+// Rewards in wei can exceed an int64, so they are stored as text
+var createProposalsTable = `
+CREATE TABLE IF NOT EXISTS t_proposals (
+	 f_epoch BIGINT,
+	 f_slot BIGINT,
+	 f_validator_index BIGINT,
+	 f_pool TEXT,
+	 f_consensus_reward_gwei BIGINT,
+	 f_execution_tip_wei TEXT,
+	 f_mev_reward_wei TEXT,
+	 f_relays TEXT,
+	 f_builder_pubkey TEXT,
+	 f_fee_recipient TEXT,
+	 f_vanilla BOOLEAN,
+	 f_attestations_reward_gwei BIGINT,
+	 f_sync_aggregate_reward_gwei BIGINT,
+	 f_slashings_reward_gwei BIGINT,
+	 PRIMARY KEY (f_slot)
+);
+`
+
+// Block roots of the canonical chain when the epoch was processed, used to
+// detect reorgs once the epoch is finalized
+var createEpochBlockRootsTable = `
+CREATE TABLE IF NOT EXISTS t_epoch_block_roots (
+	 f_epoch BIGINT,
+	 f_block_roots TEXT,
+	 PRIMARY KEY (f_epoch)
+);
+`
+
+var createValidatorStatusTable = `
+CREATE TABLE IF NOT EXISTS t_validator_status (
+	 f_epoch BIGINT,
+	 f_pool TEXT,
+	 f_n_pending BIGINT,
+	 f_n_active BIGINT,
+	 f_n_exiting BIGINT,
+	 f_n_exited BIGINT,
+	 f_n_withdrawable BIGINT,
+	 f_n_slashed BIGINT,
+	 f_activation_wait_epochs BIGINT,
+	 f_exit_wait_epochs BIGINT,
 	 PRIMARY KEY (f_epoch, f_pool)
 );
 `
@@ -65,10 +431,546 @@ CREATE TABLE IF NOT EXISTS t_network_stats (
 	 f_n_active_validators BIGINT,
 	 f_n_exited_validators BIGINT,
 	 f_n_slashed_validators BIGINT,
+	 f_source_participation FLOAT,
+	 f_target_participation FLOAT,
+	 f_head_participation FLOAT,
+	 f_attestation_effectiveness FLOAT,
+	 f_n_scheduled_blocks BIGINT,
+	 f_n_proposed_blocks BIGINT,
+	 f_proposal_rate FLOAT,
+	 f_n_blobs BIGINT,
+	 f_blob_gas_used BIGINT,
+	 f_blob_utilization FLOAT,
+	 f_blob_base_fee_wei TEXT,
+	 PRIMARY KEY (f_epoch)
+);
+`
+
+var createNetworkQueuesTable = `
+CREATE TABLE IF NOT EXISTS t_network_queues (
+	 f_timestamp TIMESTAMPTZ NOT NULL,
+	 f_epoch BIGINT,
+	 f_churn_limit_gwei BIGINT,
+	 f_n_pending_deposits BIGINT,
+	 f_pending_deposits_gwei BIGINT,
+	 f_n_exiting_validators BIGINT,
+	 f_exiting_balance_gwei BIGINT,
+	 f_entry_wait_epochs BIGINT,
+	 f_exit_wait_epochs BIGINT,
+	 PRIMARY KEY (f_epoch)
+);
+`
+
+// Value in wei can exceed an int64, so it is stored as text
+var createRelayStatsTable = `
+CREATE TABLE IF NOT EXISTS t_relay_stats (
+	 f_epoch BIGINT,
+	 f_relay TEXT,
+	 f_n_requests BIGINT,
+	 f_n_errors BIGINT,
+	 f_n_payloads BIGINT,
+	 f_value_wei TEXT,
+	 f_avg_latency_ms FLOAT,
+	 f_skipped BOOLEAN,
+	 PRIMARY KEY (f_epoch, f_relay)
+);
+`
+
+// Raw responses of the relays, so that backfills don't request them again
+var createRelayBidTracesTable = `
+CREATE TABLE IF NOT EXISTS t_relay_bidtraces (
+	 f_relay TEXT,
+	 f_slot BIGINT,
+	 f_payloads TEXT,
+	 PRIMARY KEY (f_relay, f_slot)
+);
+`
+
+// Blobs of the blocks proposed by the pools. Fees in wei can exceed an int64, so
+// they are stored as text
+var createBlockBlobsTable = `
+CREATE TABLE IF NOT EXISTS t_block_blobs (
+	 f_epoch BIGINT,
+	 f_slot BIGINT,
+	 f_validator_index BIGINT,
+	 f_pool TEXT,
+	 f_n_blobs BIGINT,
+	 f_blob_gas_used BIGINT,
+	 f_max_blob_gas BIGINT,
+	 f_excess_blob_gas BIGINT,
+	 f_blob_base_fee_wei TEXT,
+	 f_blob_fee_wei TEXT,
+	 PRIMARY KEY (f_slot)
+);
+`
+
+var createFeeRecipientViolationsTable = `
+CREATE TABLE IF NOT EXISTS t_fee_recipient_violations (
+	 f_epoch BIGINT,
+	 f_slot BIGINT,
+	 f_validator_index BIGINT,
+	 f_pool TEXT,
+	 f_fee_recipient TEXT,
+	 f_expected TEXT,
+	 f_mev BOOLEAN,
+	 PRIMARY KEY (f_slot)
+);
+`
+
+// Value of the MEV blocks reported by the relays and received by the fee
+// recipient, in wei
+var createMEVPayoutsTable = `
+CREATE TABLE IF NOT EXISTS t_mev_payouts (
+	 f_epoch BIGINT,
+	 f_slot BIGINT,
+	 f_validator_index BIGINT,
+	 f_pool TEXT,
+	 f_fee_recipient TEXT,
+	 f_relays TEXT,
+	 f_builder_pubkey TEXT,
+	 f_reported_wei TEXT,
+	 f_paid_wei TEXT,
+	 f_shortfall_wei TEXT,
+	 PRIMARY KEY (f_slot)
+);
+`
+
+// Best relay bid of the vanilla blocks and the tips they paid instead, in wei
+var createMissedMEVTable = `
+CREATE TABLE IF NOT EXISTS t_missed_mev (
+	 f_epoch BIGINT,
+	 f_slot BIGINT,
+	 f_validator_index BIGINT,
+	 f_pool TEXT,
+	 f_relay TEXT,
+	 f_builder_pubkey TEXT,
+	 f_best_bid_wei TEXT,
+	 f_tip_wei TEXT,
+	 f_missed_wei TEXT,
+	 PRIMARY KEY (f_slot)
+);
+`
+
+// When the blocks of the tracked validators were first seen by the beacon
+// node, and their delay from the start of the slot
+var createProposalTimingsTable = `
+CREATE TABLE IF NOT EXISTS t_proposal_timings (
+	 f_epoch BIGINT,
+	 f_slot BIGINT,
+	 f_validator_index BIGINT,
+	 f_pool TEXT,
+	 f_seen_timestamp TIMESTAMP,
+	 f_delay_ms BIGINT,
+	 f_late BOOLEAN,
+	 PRIMARY KEY (f_slot)
+);
+`
+
+// Last epoch processed by the backfill of each range, so that it can be resumed
+var createBackfillProgressTable = `
+CREATE TABLE IF NOT EXISTS t_backfill_progress (
+	 f_from_epoch BIGINT,
+	 f_to_epoch BIGINT,
+	 f_last_epoch BIGINT,
+	 f_timestamp TIMESTAMPTZ NOT NULL,
+	 PRIMARY KEY (f_from_epoch, f_to_epoch)
+);
+`
+
+// Validators that joined or left a pool from an epoch on. Not pruned, since it
+// tells the pool of a validator in any epoch.
+var createPoolMembershipTable = `
+CREATE TABLE IF NOT EXISTS t_pool_membership (
+	 f_epoch BIGINT,
+	 f_pool TEXT,
+	 f_validator_pubkey TEXT,
+	 f_action TEXT,
+	 f_source TEXT DEFAULT 'api',
+	 f_timestamp TIMESTAMPTZ NOT NULL,
+	 PRIMARY KEY (f_pool, f_validator_pubkey, f_epoch)
+);
+`
+
+// Summary reports that were sent, so that they are not sent again after a
+// restart. The start is the day or the monday of the week, in UTC.
+var createReportsSentTable = `
+CREATE TABLE IF NOT EXISTS t_reports_sent (
+	 f_period TEXT,
+	 f_start TEXT,
+	 f_timestamp TIMESTAMPTZ NOT NULL,
+	 PRIMARY KEY (f_period, f_start)
+);
+`
+
+// Effectiveness of each pool in each epoch, from 0 to 1 for each kind of duty
+// and from 0 to 100 overall. Kinds without duties in the epoch are NULL.
+var createEffectivenessTable = `
+CREATE TABLE IF NOT EXISTS t_effectiveness (
+	 f_epoch_timestamp TIMESTAMPTZ NOT NULL,
+	 f_epoch BIGINT,
+	 f_pool TEXT,
+	 f_attestation FLOAT,
+	 f_inclusion FLOAT,
+	 f_proposal FLOAT,
+	 f_sync_committee FLOAT,
+	 f_score FLOAT,
+	 f_source_participation_delta FLOAT,
+	 f_target_participation_delta FLOAT,
+	 f_head_participation_delta FLOAT,
+	 f_attestation_delta FLOAT,
+	 f_proposal_delta FLOAT,
+	 f_score_delta FLOAT,
+	 f_maintenance BOOLEAN,
+	 PRIMARY KEY (f_epoch, f_pool)
+);
+`
+
+// Alerts that were fired, to show the recent ones
+var createAlertsTable = `
+CREATE TABLE IF NOT EXISTS t_alerts (
+	 f_timestamp TIMESTAMPTZ NOT NULL,
+	 f_epoch BIGINT,
+	 f_pool TEXT,
+	 f_kind TEXT,
+	 f_severity TEXT,
+	 f_message TEXT,
+	 PRIMARY KEY (f_epoch, f_pool, f_kind, f_message)
+);
+`
+
+// Windows in which a pool is expected to miss duties, added with the admin api.
+// Kept until removed. Times are TIMESTAMP so that the driver reads them back.
+var createMaintenanceWindowsTable = `
+CREATE TABLE IF NOT EXISTS t_maintenance_windows (
+	 f_id BIGINT,
+	 f_pool TEXT,
+	 f_start TIMESTAMP NOT NULL,
+	 f_end TIMESTAMP NOT NULL,
+	 f_comment TEXT,
+	 PRIMARY KEY (f_id)
+);
+`
+
+// SLA of each pool in each day, week, month and quarter. The longer periods
+// are summed from the days, so that they outlive --retention-epochs.
+var createPoolSLATable = `
+CREATE TABLE IF NOT EXISTS t_pool_sla (
+	 f_period TEXT,
+	 f_start TEXT,
+	 f_pool TEXT,
+	 f_n_epochs BIGINT,
+	 f_n_maintenance_epochs BIGINT,
+	 f_n_attestation_duties BIGINT,
+	 f_n_missed_attestations BIGINT,
+	 f_n_scheduled_blocks BIGINT,
+	 f_n_proposed_blocks BIGINT,
+	 f_attestation_uptime FLOAT,
+	 f_proposal_success FLOAT,
+	 f_attestation_target FLOAT,
+	 f_proposal_target FLOAT,
+	 f_attestation_breached BOOLEAN,
+	 f_proposal_breached BOOLEAN,
+	 f_timestamp TIMESTAMPTZ NOT NULL,
+	 PRIMARY KEY (f_period, f_start, f_pool)
+);
+`
+
+// Tracked keys that were not found or exited when they were last checked
+var createKeyIssuesTable = `
+CREATE TABLE IF NOT EXISTS t_key_issues (
+	 f_epoch BIGINT,
+	 f_pool TEXT,
+	 f_validator_pubkey TEXT,
+	 f_issue TEXT,
+	 f_validator_index BIGINT,
+	 PRIMARY KEY (f_pool, f_validator_pubkey)
+);
+`
+
+// Tenant of each pool in multi-tenant mode, to filter the tables by tenant
+var createTenantPoolsTable = `
+CREATE TABLE IF NOT EXISTS t_tenant_pools (
+	 f_tenant TEXT,
+	 f_pool TEXT,
+	 PRIMARY KEY (f_pool)
+);
+`
+
+// Same as t_effectiveness but of the whole network
+var createNetworkEffectivenessTable = `
+CREATE TABLE IF NOT EXISTS t_network_effectiveness (
+	 f_epoch_timestamp TIMESTAMPTZ NOT NULL,
+	 f_epoch BIGINT,
+	 f_attestation FLOAT,
+	 f_inclusion FLOAT,
+	 f_proposal FLOAT,
+	 f_sync_committee FLOAT,
+	 f_score FLOAT,
 	 PRIMARY KEY (f_epoch)
 );
 `
 
+// Daily aggregates of t_pools_metrics_summary of the epochs that were pruned.
+// Only sums are stored, so that averages can be computed over any period.
+var createPoolsMetricsDailyTable = `
+CREATE TABLE IF NOT EXISTS t_pools_metrics_daily (
+	 f_day TEXT,
+	 f_pool TEXT,
+	 f_last_epoch BIGINT,
+	 f_n_epochs BIGINT,
+	 f_n_active_validators BIGINT,
+	 f_n_total_votes BIGINT,
+	 f_n_incorrect_source BIGINT,
+	 f_n_incorrect_target BIGINT,
+	 f_n_incorrect_head BIGINT,
+	 f_earned_balance_gwei BIGINT,
+	 f_lost_balance_gwei BIGINT,
+	 f_mev_rewards_wei TEXT,
+	 f_proposer_tips_wei TEXT,
+	 f_earned_usd FLOAT,
+	 f_mev_rewards_usd FLOAT,
+	 f_n_slashed_validators BIGINT,
+	 f_n_scheduled_blocks BIGINT,
+	 f_n_proposed_blocks BIGINT,
+	 f_sync_committee_rewards_gwei BIGINT,
+	 f_proposer_rewards_gwei BIGINT,
+	 f_effective_balance_gwei BIGINT,
+	 f_n_compounding_validators BIGINT,
+	 f_partial_withdrawals_gwei BIGINT,
+	 f_full_withdrawals_gwei BIGINT,
+	 f_n_full_withdrawals BIGINT,
+	 PRIMARY KEY (f_day, f_pool)
+);
+`
+
+// Timestamps are stored as formatted by Go, e.g. "2023-11-14 23:13:20 +0100 CET",
+// which the SQLite date functions can't parse, so they are rewritten as
+// "2023-11-14 23:13:20+01:00" and then converted to the UTC day
+var epochDay = `date(substr(f_epoch_timestamp, 1, 19) || substr(f_epoch_timestamp, 21, 3) || ':' || substr(f_epoch_timestamp, 24, 2))`
+
+// Wei overflow BIGINT, so they are stored as text and added exactly by adding
+// the gwei and the rest of the wei as integers
+func weiGwei(value string) string {
+	return "CAST(substr(" + value + ", 1, length(" + value + ") - 9) AS INTEGER)"
+}
+
+func weiRest(value string) string {
+	return "CAST(substr(" + value + ", -9) AS INTEGER)"
+}
+
+// Formats the wei given as gwei and a rest, which may exceed a gwei
+func weiText(gwei string, rest string) string {
+	total := "(" + gwei + " + (" + rest + ") / 1000000000)"
+	rest = "(" + rest + ") % 1000000000"
+	return "CASE WHEN " + total + " = 0 THEN CAST(" + rest + " AS TEXT) " +
+		"ELSE printf('%d%09d', " + total + ", " + rest + ") END"
+}
+
+// Sum of the wei of the column in the rows of the group
+func sumWei(column string) string {
+	return weiText("COALESCE(SUM("+weiGwei(column)+"), 0)", "COALESCE(SUM("+weiRest(column)+"), 0)")
+}
+
+// Sum of the wei of two columns, which may be null
+func addWei(x string, y string) string {
+	x = "COALESCE(" + x + ", '0')"
+	y = "COALESCE(" + y + ", '0')"
+	return weiText(weiGwei(x)+" + "+weiGwei(y), weiRest(x)+" + "+weiRest(y))
+}
+
+// Aggregates t_pools_metrics_summary per day and pool, with the same columns as
+// t_pools_metrics_daily
+var selectPoolsMetricsDaily = `
+SELECT
+	` + epochDay + ` AS f_day,
+	f_pool,
+	MAX(f_epoch) AS f_last_epoch,
+	COUNT(*) AS f_n_epochs,
+	SUM(f_n_active_validators) AS f_n_active_validators,
+	SUM(f_n_total_votes) AS f_n_total_votes,
+	SUM(f_n_incorrect_source) AS f_n_incorrect_source,
+	SUM(f_n_incorrect_target) AS f_n_incorrect_target,
+	SUM(f_n_incorrect_head) AS f_n_incorrect_head,
+	SUM(f_epoch_earned_balance_gwei) AS f_earned_balance_gwei,
+	SUM(f_epoch_lost_balace_gwei) AS f_lost_balance_gwei,
+	` + sumWei("f_mev_rewards_wei") + ` AS f_mev_rewards_wei,
+	` + sumWei("f_proposer_tips_wei") + ` AS f_proposer_tips_wei,
+	TOTAL(f_earned_usd) AS f_earned_usd,
+	TOTAL(f_mev_rewards_usd) AS f_mev_rewards_usd,
+	SUM(f_n_slashed_validators) AS f_n_slashed_validators,
+	SUM(f_n_scheduled_blocks) AS f_n_scheduled_blocks,
+	SUM(f_n_proposed_blocks) AS f_n_proposed_blocks,
+	SUM(f_sync_committee_rewards_gwei) AS f_sync_committee_rewards_gwei,
+	SUM(f_proposer_rewards_gwei) AS f_proposer_rewards_gwei,
+	SUM(f_epoch_effective_balance_gwei) AS f_effective_balance_gwei,
+	SUM(f_n_compounding_validators) AS f_n_compounding_validators,
+	SUM(f_partial_withdrawals_gwei) AS f_partial_withdrawals_gwei,
+	SUM(f_full_withdrawals_gwei) AS f_full_withdrawals_gwei,
+	SUM(f_n_full_withdrawals) AS f_n_full_withdrawals
+FROM t_pools_metrics_summary
+`
+
+// Rolls up the epochs before the given one, skipping the ones that were rolled
+// up before, e.g. if they were backfilled again after being pruned
+var rollupPoolsMetricsDaily = `
+INSERT INTO t_pools_metrics_daily ` + selectPoolsMetricsDaily + `
+WHERE f_epoch < ? AND f_epoch > (SELECT COALESCE(MAX(f_last_epoch), -1) FROM t_pools_metrics_daily)
+GROUP BY f_day, f_pool
+ON CONFLICT (f_day, f_pool)
+DO UPDATE SET
+   f_last_epoch=MAX(f_last_epoch, EXCLUDED.f_last_epoch),
+   f_n_epochs=f_n_epochs+EXCLUDED.f_n_epochs,
+   f_n_active_validators=f_n_active_validators+EXCLUDED.f_n_active_validators,
+   f_n_total_votes=f_n_total_votes+EXCLUDED.f_n_total_votes,
+   f_n_incorrect_source=f_n_incorrect_source+EXCLUDED.f_n_incorrect_source,
+   f_n_incorrect_target=f_n_incorrect_target+EXCLUDED.f_n_incorrect_target,
+   f_n_incorrect_head=f_n_incorrect_head+EXCLUDED.f_n_incorrect_head,
+   f_earned_balance_gwei=f_earned_balance_gwei+EXCLUDED.f_earned_balance_gwei,
+   f_lost_balance_gwei=f_lost_balance_gwei+EXCLUDED.f_lost_balance_gwei,
+   f_mev_rewards_wei=` + addWei("f_mev_rewards_wei", "EXCLUDED.f_mev_rewards_wei") + `,
+   f_proposer_tips_wei=` + addWei("f_proposer_tips_wei", "EXCLUDED.f_proposer_tips_wei") + `,
+   f_earned_usd=f_earned_usd+EXCLUDED.f_earned_usd,
+   f_mev_rewards_usd=f_mev_rewards_usd+EXCLUDED.f_mev_rewards_usd,
+   f_n_slashed_validators=f_n_slashed_validators+EXCLUDED.f_n_slashed_validators,
+   f_n_scheduled_blocks=f_n_scheduled_blocks+EXCLUDED.f_n_scheduled_blocks,
+   f_n_proposed_blocks=f_n_proposed_blocks+EXCLUDED.f_n_proposed_blocks,
+   f_sync_committee_rewards_gwei=COALESCE(f_sync_committee_rewards_gwei, 0)+EXCLUDED.f_sync_committee_rewards_gwei,
+   f_proposer_rewards_gwei=COALESCE(f_proposer_rewards_gwei, 0)+EXCLUDED.f_proposer_rewards_gwei,
+   f_effective_balance_gwei=COALESCE(f_effective_balance_gwei, 0)+EXCLUDED.f_effective_balance_gwei,
+   f_n_compounding_validators=COALESCE(f_n_compounding_validators, 0)+EXCLUDED.f_n_compounding_validators,
+   f_partial_withdrawals_gwei=COALESCE(f_partial_withdrawals_gwei, 0)+EXCLUDED.f_partial_withdrawals_gwei,
+   f_full_withdrawals_gwei=COALESCE(f_full_withdrawals_gwei, 0)+EXCLUDED.f_full_withdrawals_gwei,
+   f_n_full_withdrawals=COALESCE(f_n_full_withdrawals, 0)+EXCLUDED.f_n_full_withdrawals
+`
+
+// Sums of the rolled up days and the days still in t_pools_metrics_summary
+var poolsMetricsSums = `
+	SUM(f_n_epochs) AS f_n_epochs,
+	SUM(f_n_active_validators) * 1.0 / SUM(f_n_epochs) AS f_avg_active_validators,
+	SUM(f_n_total_votes) AS f_n_total_votes,
+	SUM(f_n_incorrect_source) AS f_n_incorrect_source,
+	SUM(f_n_incorrect_target) AS f_n_incorrect_target,
+	SUM(f_n_incorrect_head) AS f_n_incorrect_head,
+	1 - SUM(f_n_incorrect_source) * 1.0 / NULLIF(SUM(f_n_total_votes), 0) AS f_source_participation,
+	SUM(f_earned_balance_gwei) AS f_earned_balance_gwei,
+	SUM(f_lost_balance_gwei) AS f_lost_balance_gwei,
+	` + sumWei("f_mev_rewards_wei") + ` AS f_mev_rewards_wei,
+	` + sumWei("f_proposer_tips_wei") + ` AS f_proposer_tips_wei,
+	SUM(f_earned_usd) AS f_earned_usd,
+	SUM(f_mev_rewards_usd) AS f_mev_rewards_usd,
+	SUM(f_n_slashed_validators) AS f_n_slashed_validators,
+	SUM(f_n_scheduled_blocks) AS f_n_scheduled_blocks,
+	SUM(f_n_proposed_blocks) AS f_n_proposed_blocks,
+	SUM(f_sync_committee_rewards_gwei) AS f_sync_committee_rewards_gwei,
+	SUM(f_proposer_rewards_gwei) AS f_proposer_rewards_gwei,
+	SUM(f_effective_balance_gwei) * 1.0 / SUM(f_n_epochs) AS f_avg_effective_balance_gwei,
+	SUM(f_n_compounding_validators) * 1.0 / SUM(f_n_epochs) AS f_avg_compounding_validators,
+	SUM(f_partial_withdrawals_gwei) AS f_partial_withdrawals_gwei,
+	SUM(f_full_withdrawals_gwei) AS f_full_withdrawals_gwei,
+	SUM(f_n_full_withdrawals) AS f_n_full_withdrawals
+`
+
+// Views for dashboards, e.g. Grafana. They are dropped and created again on
+// startup so that changes to them and to their tables are applied
+var dropPoolsMetricsViews = []string{
+	`DROP VIEW IF EXISTS v_effectiveness_daily`,
+	`DROP VIEW IF EXISTS v_activation_latency`,
+	`DROP VIEW IF EXISTS v_pools_metrics_weekly`,
+	`DROP VIEW IF EXISTS v_pools_metrics_daily`,
+}
+
+var createPoolsMetricsViews = []string{
+	`CREATE VIEW v_pools_metrics_daily AS
+SELECT f_day, f_pool,` + poolsMetricsSums + `
+FROM (
+	SELECT * FROM t_pools_metrics_daily
+	UNION ALL
+	` + selectPoolsMetricsDaily + ` GROUP BY f_day, f_pool
+)
+GROUP BY f_day, f_pool`,
+	// Weeks start on monday
+	`CREATE VIEW v_pools_metrics_weekly AS
+SELECT date(f_day, '-6 days', 'weekday 1') AS f_week, f_pool,` + poolsMetricsSums + `
+FROM (
+	SELECT * FROM t_pools_metrics_daily
+	UNION ALL
+	` + selectPoolsMetricsDaily + ` GROUP BY f_day, f_pool
+)
+GROUP BY f_week, f_pool`,
+	// Time from the first deposit of each validator to its activation, per day
+	// of activation
+	`CREATE VIEW v_activation_latency AS
+SELECT f_day, f_pool,
+	COUNT(*) AS f_n_activations,
+	AVG(f_latency) AS f_avg_latency_seconds,
+	MIN(f_latency) AS f_min_latency_seconds,
+	MAX(f_latency) AS f_max_latency_seconds
+FROM (
+	SELECT f_pool, f_validator_pubkey,
+		date(MIN(f_timestamp) + MAX(f_activation_latency_seconds), 'unixepoch') AS f_day,
+		MAX(f_activation_latency_seconds) AS f_latency
+	FROM t_deposits
+	WHERE f_activation_epoch IS NOT NULL
+	GROUP BY f_pool, f_validator_pubkey
+)
+GROUP BY f_day, f_pool`,
+	// Effectiveness of each pool averaged over the epochs of each day, next to
+	// the one of the network
+	`CREATE VIEW v_effectiveness_daily AS
+SELECT p.*, n.f_network_score
+FROM (
+	SELECT ` + epochDay + ` AS f_day, f_pool,
+		COUNT(*) AS f_n_epochs,
+		AVG(f_attestation) AS f_attestation,
+		AVG(f_inclusion) AS f_inclusion,
+		AVG(f_proposal) AS f_proposal,
+		AVG(f_sync_committee) AS f_sync_committee,
+		AVG(f_score) AS f_score,
+		AVG(f_source_participation_delta) AS f_source_participation_delta,
+		AVG(f_target_participation_delta) AS f_target_participation_delta,
+		AVG(f_head_participation_delta) AS f_head_participation_delta,
+		AVG(f_attestation_delta) AS f_attestation_delta,
+		AVG(f_proposal_delta) AS f_proposal_delta,
+		AVG(f_score_delta) AS f_score_delta
+	FROM t_effectiveness
+	WHERE NOT COALESCE(f_maintenance, FALSE)
+	GROUP BY f_day, f_pool
+) p
+LEFT JOIN (
+	SELECT ` + epochDay + ` AS f_day, AVG(f_score) AS f_network_score
+	FROM t_network_effectiveness
+	GROUP BY f_day
+) n ON n.f_day = p.f_day`,
+}
+
+// Tables with per epoch rows that are pruned. Slashings and fee recipient
+// violations are rare and kept forever, as are voluntary exits, BLS changes,
+// deposits, execution layer requests and processed partial withdrawals, which
+// happen a few times per validator.
+var prunedEpochTables = []string{
+	"t_pools_metrics_summary",
+	"t_proposal_duties",
+	"t_validator_metrics",
+	"t_missed_attestations",
+	"t_processing_status",
+	"t_epochs_processed",
+	"t_proposals",
+	"t_mev_payouts",
+	"t_missed_mev",
+	"t_proposal_timings",
+	"t_validator_status",
+	"t_block_blobs",
+	"t_epoch_block_roots",
+	"t_network_stats",
+	"t_network_queues",
+	"t_pending_partial_withdrawals",
+	"t_relay_stats",
+	"t_client_diversity",
+	"t_effectiveness",
+	"t_network_effectiveness",
+	"t_alerts",
+}
+
 var insertEthPrice = `
 INSERT INTO t_eth_price(
 	f_timestamp,
@@ -80,7 +982,6 @@ DO UPDATE SET
 `
 
 // TODO: Add missing
-// MissedAttestationsKeys []string
 // LostBalanceKeys        []string
 var insertValidatorPerformance = `
 INSERT INTO t_pools_metrics_summary(
@@ -100,8 +1001,29 @@ INSERT INTO t_pools_metrics_summary(
 	f_epoch_earned_balance_gwei,
 	f_epoch_lost_balace_gwei,
 	f_mev_rewards_wei,
-	f_proposer_tips_wei)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	f_proposer_tips_wei,
+	f_avg_inclusion_delay,
+	f_max_inclusion_delay,
+	f_n_slashed_validators,
+	f_head_rewards_gwei,
+	f_target_rewards_gwei,
+	f_source_rewards_gwei,
+	f_inclusion_delay_rewards_gwei,
+	f_inactivity_penalties_gwei,
+	f_ideal_attestation_rewards_gwei,
+	f_processing_mode,
+	f_eth_price_usd,
+	f_earned_usd,
+	f_mev_rewards_usd,
+	f_mev_partial,
+	f_sync_committee_rewards_gwei,
+	f_proposer_rewards_gwei,
+	f_effective_balance_change_gwei,
+	f_n_compounding_validators,
+	f_partial_withdrawals_gwei,
+	f_full_withdrawals_gwei,
+	f_n_full_withdrawals)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 ON CONFLICT (f_epoch, f_pool)
 DO UPDATE SET
    f_timestamp=EXCLUDED.f_timestamp,
@@ -118,21 +1040,676 @@ DO UPDATE SET
 	 f_epoch_earned_balance_gwei=EXCLUDED.f_epoch_earned_balance_gwei,
 	 f_epoch_lost_balace_gwei=EXCLUDED.f_epoch_lost_balace_gwei,
 	 f_mev_rewards_wei=EXCLUDED.f_mev_rewards_wei,
-	 f_proposer_tips_wei=EXCLUDED.f_proposer_tips_wei
+	 f_proposer_tips_wei=EXCLUDED.f_proposer_tips_wei,
+	 f_avg_inclusion_delay=EXCLUDED.f_avg_inclusion_delay,
+	 f_max_inclusion_delay=EXCLUDED.f_max_inclusion_delay,
+	 f_n_slashed_validators=EXCLUDED.f_n_slashed_validators,
+	 f_head_rewards_gwei=EXCLUDED.f_head_rewards_gwei,
+	 f_target_rewards_gwei=EXCLUDED.f_target_rewards_gwei,
+	 f_source_rewards_gwei=EXCLUDED.f_source_rewards_gwei,
+	 f_inclusion_delay_rewards_gwei=EXCLUDED.f_inclusion_delay_rewards_gwei,
+	 f_inactivity_penalties_gwei=EXCLUDED.f_inactivity_penalties_gwei,
+	 f_ideal_attestation_rewards_gwei=EXCLUDED.f_ideal_attestation_rewards_gwei,
+	 f_processing_mode=EXCLUDED.f_processing_mode,
+	 f_eth_price_usd=EXCLUDED.f_eth_price_usd,
+	 f_earned_usd=EXCLUDED.f_earned_usd,
+	 f_mev_rewards_usd=EXCLUDED.f_mev_rewards_usd,
+	 f_mev_partial=EXCLUDED.f_mev_partial,
+	 f_sync_committee_rewards_gwei=EXCLUDED.f_sync_committee_rewards_gwei,
+	 f_proposer_rewards_gwei=EXCLUDED.f_proposer_rewards_gwei,
+	 f_effective_balance_change_gwei=EXCLUDED.f_effective_balance_change_gwei,
+	 f_n_compounding_validators=EXCLUDED.f_n_compounding_validators,
+	 f_partial_withdrawals_gwei=EXCLUDED.f_partial_withdrawals_gwei,
+	 f_full_withdrawals_gwei=EXCLUDED.f_full_withdrawals_gwei,
+	 f_n_full_withdrawals=EXCLUDED.f_n_full_withdrawals
 `
 
-// TODO: Add f_epoch_timestamp
-var insertProposalDuties = `
-INSERT INTO t_proposal_duties(
+// Rolls up the rows of the pools of a group in an epoch into a row of the
+// group. The parent group and the number of pools are set, which tells the
+// rows of groups from the rows of pools. Wei are summed apart, since SQLite
+// sums text as floats.
+var rollupPoolGroupMetrics = `
+INSERT INTO t_pools_metrics_summary(
+	f_timestamp,
 	f_epoch,
 	f_pool,
-	f_n_scheduled_blocks,
-	f_n_proposed_blocks)
-VALUES (?, ?, ?, ?)
+	f_epoch_timestamp,
+	f_n_active_validators,
+	f_n_total_votes,
+	f_n_incorrect_source,
+	f_n_incorrect_target,
+	f_n_incorrect_head,
+	f_n_validating_keys,
+	f_n_valitadors_with_less_balace,
+	f_validator_indexes_with_less_balance,
+	f_epoch_effective_balance_gwei,
+	f_epoch_earned_balance_gwei,
+	f_epoch_lost_balace_gwei,
+	f_mev_rewards_wei,
+	f_proposer_tips_wei,
+	f_avg_inclusion_delay,
+	f_max_inclusion_delay,
+	f_n_slashed_validators,
+	f_head_rewards_gwei,
+	f_target_rewards_gwei,
+	f_source_rewards_gwei,
+	f_inclusion_delay_rewards_gwei,
+	f_inactivity_penalties_gwei,
+	f_ideal_attestation_rewards_gwei,
+	f_processing_mode,
+	f_eth_price_usd,
+	f_earned_usd,
+	f_mev_rewards_usd,
+	f_mev_partial,
+	f_sync_committee_rewards_gwei,
+	f_proposer_rewards_gwei,
+	f_effective_balance_change_gwei,
+	f_n_compounding_validators,
+	f_partial_withdrawals_gwei,
+	f_full_withdrawals_gwei,
+	f_n_full_withdrawals,
+	f_parent_group,
+	f_n_pools)
+SELECT
+	MAX(f_timestamp),
+	f_epoch,
+	?,
+	MAX(f_epoch_timestamp),
+	SUM(f_n_active_validators),
+	SUM(f_n_total_votes),
+	SUM(f_n_incorrect_source),
+	SUM(f_n_incorrect_target),
+	SUM(f_n_incorrect_head),
+	SUM(f_n_validating_keys),
+	SUM(f_n_valitadors_with_less_balace),
+	COALESCE(GROUP_CONCAT(NULLIF(f_validator_indexes_with_less_balance, ''), ','), ''),
+	SUM(f_epoch_effective_balance_gwei),
+	SUM(f_epoch_earned_balance_gwei),
+	SUM(f_epoch_lost_balace_gwei),
+	?,
+	?,
+	COALESCE(SUM(f_avg_inclusion_delay * f_n_total_votes) / NULLIF(SUM(f_n_total_votes), 0), 0),
+	MAX(f_max_inclusion_delay),
+	SUM(f_n_slashed_validators),
+	SUM(f_head_rewards_gwei),
+	SUM(f_target_rewards_gwei),
+	SUM(f_source_rewards_gwei),
+	SUM(f_inclusion_delay_rewards_gwei),
+	SUM(f_inactivity_penalties_gwei),
+	SUM(f_ideal_attestation_rewards_gwei),
+	MAX(f_processing_mode),
+	MAX(f_eth_price_usd),
+	SUM(f_earned_usd),
+	SUM(f_mev_rewards_usd),
+	MAX(f_mev_partial),
+	SUM(f_sync_committee_rewards_gwei),
+	SUM(f_proposer_rewards_gwei),
+	SUM(f_effective_balance_change_gwei),
+	SUM(f_n_compounding_validators),
+	SUM(f_partial_withdrawals_gwei),
+	SUM(f_full_withdrawals_gwei),
+	SUM(f_n_full_withdrawals),
+	NULLIF(?, ''),
+	COUNT(*)
+FROM t_pools_metrics_summary
+WHERE f_epoch = ? AND f_pool IN (SELECT value FROM json_each(?))
+GROUP BY f_epoch
 ON CONFLICT (f_epoch, f_pool)
 DO UPDATE SET
-   f_n_scheduled_blocks=EXCLUDED.f_n_scheduled_blocks,
-   f_n_proposed_blocks=EXCLUDED.f_n_proposed_blocks
+   f_timestamp=EXCLUDED.f_timestamp,
+   f_epoch_timestamp=EXCLUDED.f_epoch_timestamp,
+   f_n_active_validators=EXCLUDED.f_n_active_validators,
+   f_n_total_votes=EXCLUDED.f_n_total_votes,
+   f_n_incorrect_source=EXCLUDED.f_n_incorrect_source,
+   f_n_incorrect_target=EXCLUDED.f_n_incorrect_target,
+   f_n_incorrect_head=EXCLUDED.f_n_incorrect_head,
+   f_n_validating_keys=EXCLUDED.f_n_validating_keys,
+   f_n_valitadors_with_less_balace=EXCLUDED.f_n_valitadors_with_less_balace,
+   f_validator_indexes_with_less_balance=EXCLUDED.f_validator_indexes_with_less_balance,
+   f_epoch_effective_balance_gwei=EXCLUDED.f_epoch_effective_balance_gwei,
+   f_epoch_earned_balance_gwei=EXCLUDED.f_epoch_earned_balance_gwei,
+   f_epoch_lost_balace_gwei=EXCLUDED.f_epoch_lost_balace_gwei,
+   f_mev_rewards_wei=EXCLUDED.f_mev_rewards_wei,
+   f_proposer_tips_wei=EXCLUDED.f_proposer_tips_wei,
+   f_avg_inclusion_delay=EXCLUDED.f_avg_inclusion_delay,
+   f_max_inclusion_delay=EXCLUDED.f_max_inclusion_delay,
+   f_n_slashed_validators=EXCLUDED.f_n_slashed_validators,
+   f_head_rewards_gwei=EXCLUDED.f_head_rewards_gwei,
+   f_target_rewards_gwei=EXCLUDED.f_target_rewards_gwei,
+   f_source_rewards_gwei=EXCLUDED.f_source_rewards_gwei,
+   f_inclusion_delay_rewards_gwei=EXCLUDED.f_inclusion_delay_rewards_gwei,
+   f_inactivity_penalties_gwei=EXCLUDED.f_inactivity_penalties_gwei,
+   f_ideal_attestation_rewards_gwei=EXCLUDED.f_ideal_attestation_rewards_gwei,
+   f_processing_mode=EXCLUDED.f_processing_mode,
+   f_eth_price_usd=EXCLUDED.f_eth_price_usd,
+   f_earned_usd=EXCLUDED.f_earned_usd,
+   f_mev_rewards_usd=EXCLUDED.f_mev_rewards_usd,
+   f_mev_partial=EXCLUDED.f_mev_partial,
+   f_sync_committee_rewards_gwei=EXCLUDED.f_sync_committee_rewards_gwei,
+   f_proposer_rewards_gwei=EXCLUDED.f_proposer_rewards_gwei,
+   f_effective_balance_change_gwei=EXCLUDED.f_effective_balance_change_gwei,
+   f_n_compounding_validators=EXCLUDED.f_n_compounding_validators,
+   f_partial_withdrawals_gwei=EXCLUDED.f_partial_withdrawals_gwei,
+   f_full_withdrawals_gwei=EXCLUDED.f_full_withdrawals_gwei,
+   f_n_full_withdrawals=EXCLUDED.f_n_full_withdrawals,
+   f_parent_group=EXCLUDED.f_parent_group,
+   f_n_pools=EXCLUDED.f_n_pools
+`
+
+var rollupPoolGroupProposalDuties = `
+INSERT INTO t_proposal_duties(
+	f_epoch,
+	f_pool,
+	f_n_scheduled_blocks,
+	f_n_proposed_blocks,
+	f_n_missed_empty,
+	f_n_missed_orphaned,
+	f_n_vanilla_blocks,
+	f_vanilla_ratio)
+SELECT
+	f_epoch,
+	?,
+	SUM(f_n_scheduled_blocks),
+	SUM(f_n_proposed_blocks),
+	SUM(f_n_missed_empty),
+	SUM(f_n_missed_orphaned),
+	SUM(f_n_vanilla_blocks),
+	COALESCE(SUM(f_n_vanilla_blocks) * 1.0 / NULLIF(SUM(f_n_proposed_blocks), 0), 0)
+FROM t_proposal_duties
+WHERE f_epoch = ? AND f_pool IN (SELECT value FROM json_each(?))
+GROUP BY f_epoch
+ON CONFLICT (f_epoch, f_pool)
+DO UPDATE SET
+   f_n_scheduled_blocks=EXCLUDED.f_n_scheduled_blocks,
+   f_n_proposed_blocks=EXCLUDED.f_n_proposed_blocks,
+   f_n_missed_empty=EXCLUDED.f_n_missed_empty,
+   f_n_missed_orphaned=EXCLUDED.f_n_missed_orphaned,
+   f_n_vanilla_blocks=EXCLUDED.f_n_vanilla_blocks,
+   f_vanilla_ratio=EXCLUDED.f_vanilla_ratio
+`
+
+// TODO: Add f_epoch_timestamp
+var insertProposalDuties = `
+INSERT INTO t_proposal_duties(
+	f_epoch,
+	f_pool,
+	f_n_scheduled_blocks,
+	f_n_proposed_blocks,
+	f_n_missed_empty,
+	f_n_missed_orphaned,
+	f_n_vanilla_blocks,
+	f_vanilla_ratio)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (f_epoch, f_pool)
+DO UPDATE SET
+   f_n_scheduled_blocks=EXCLUDED.f_n_scheduled_blocks,
+   f_n_proposed_blocks=EXCLUDED.f_n_proposed_blocks,
+   f_n_missed_empty=EXCLUDED.f_n_missed_empty,
+   f_n_missed_orphaned=EXCLUDED.f_n_missed_orphaned,
+   f_n_vanilla_blocks=EXCLUDED.f_n_vanilla_blocks,
+   f_vanilla_ratio=EXCLUDED.f_vanilla_ratio
+`
+
+var insertValidatorMetrics = `
+INSERT INTO t_validator_metrics(
+	f_timestamp,
+	f_epoch,
+	f_pool,
+	f_validator_index,
+	f_validator_pubkey,
+	f_balance_delta_gwei,
+	f_missed_source,
+	f_missed_target,
+	f_missed_head,
+	f_attestation_included)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (f_epoch, f_validator_index)
+DO UPDATE SET
+   f_timestamp=EXCLUDED.f_timestamp,
+   f_pool=EXCLUDED.f_pool,
+   f_validator_pubkey=EXCLUDED.f_validator_pubkey,
+   f_balance_delta_gwei=EXCLUDED.f_balance_delta_gwei,
+   f_missed_source=EXCLUDED.f_missed_source,
+   f_missed_target=EXCLUDED.f_missed_target,
+   f_missed_head=EXCLUDED.f_missed_head,
+   f_attestation_included=EXCLUDED.f_attestation_included
+`
+
+var insertProcessingStatus = `
+INSERT INTO t_processing_status(
+	f_epoch,
+	f_pool,
+	f_status,
+	f_error,
+	f_attempts,
+	f_timestamp)
+VALUES (?, ?, ?, ?, 1, ?)
+ON CONFLICT (f_epoch, f_pool)
+DO UPDATE SET
+   f_status=EXCLUDED.f_status,
+   f_error=EXCLUDED.f_error,
+   f_attempts=t_processing_status.f_attempts+1,
+   f_timestamp=EXCLUDED.f_timestamp
+`
+
+var insertMissedAttestation = `
+INSERT INTO t_missed_attestations(
+	f_epoch,
+	f_pool,
+	f_validator_index,
+	f_miss_type)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (f_epoch, f_validator_index, f_miss_type)
+DO UPDATE SET
+   f_pool=EXCLUDED.f_pool
+`
+
+var insertSlashing = `
+INSERT INTO t_slashings(
+	f_epoch,
+	f_slot,
+	f_validator_index,
+	f_pool,
+	f_slashing_type)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (f_slot, f_validator_index, f_slashing_type)
+DO UPDATE SET
+   f_epoch=EXCLUDED.f_epoch,
+   f_pool=EXCLUDED.f_pool
+`
+
+var insertVoluntaryExit = `
+INSERT INTO t_voluntary_exits(
+	f_epoch,
+	f_slot,
+	f_validator_index,
+	f_pool,
+	f_exit_epoch)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (f_slot, f_validator_index)
+DO UPDATE SET
+   f_epoch=EXCLUDED.f_epoch,
+   f_pool=EXCLUDED.f_pool,
+   f_exit_epoch=EXCLUDED.f_exit_epoch
+`
+
+var insertDeposit = `
+INSERT INTO t_deposits(
+	f_epoch,
+	f_slot,
+	f_block_number,
+	f_timestamp,
+	f_deposit_index,
+	f_pool,
+	f_validator_pubkey,
+	f_withdrawal_credentials,
+	f_amount_gwei)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (f_deposit_index)
+DO UPDATE SET
+   f_epoch=EXCLUDED.f_epoch,
+   f_slot=EXCLUDED.f_slot,
+   f_block_number=EXCLUDED.f_block_number,
+   f_timestamp=EXCLUDED.f_timestamp,
+   f_pool=EXCLUDED.f_pool,
+   f_validator_pubkey=EXCLUDED.f_validator_pubkey,
+   f_withdrawal_credentials=EXCLUDED.f_withdrawal_credentials,
+   f_amount_gwei=EXCLUDED.f_amount_gwei
+`
+
+var updateDepositActivation = `
+UPDATE t_deposits
+SET f_activation_epoch = ?, f_activation_latency_seconds = ? - f_timestamp
+WHERE f_validator_pubkey = ? AND f_timestamp <= ?
+`
+
+var insertClientDiversity = `
+INSERT INTO t_client_diversity(
+	f_epoch,
+	f_pool,
+	f_consensus_client,
+	f_execution_client,
+	f_n_blocks)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (f_epoch, f_pool, f_consensus_client, f_execution_client)
+DO UPDATE SET
+   f_n_blocks=EXCLUDED.f_n_blocks
+`
+
+var insertBLSChange = `
+INSERT INTO t_bls_changes(
+	f_epoch,
+	f_slot,
+	f_validator_index,
+	f_pool,
+	f_execution_address)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (f_slot, f_validator_index)
+DO UPDATE SET
+   f_epoch=EXCLUDED.f_epoch,
+   f_pool=EXCLUDED.f_pool,
+   f_execution_address=EXCLUDED.f_execution_address
+`
+
+var insertWithdrawalRequest = `
+INSERT INTO t_withdrawal_requests(
+	f_epoch,
+	f_slot,
+	f_block_number,
+	f_request_index,
+	f_pool,
+	f_source_address,
+	f_validator_pubkey,
+	f_amount_gwei)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (f_slot, f_request_index)
+DO UPDATE SET
+   f_epoch=EXCLUDED.f_epoch,
+   f_block_number=EXCLUDED.f_block_number,
+   f_pool=EXCLUDED.f_pool,
+   f_source_address=EXCLUDED.f_source_address,
+   f_validator_pubkey=EXCLUDED.f_validator_pubkey,
+   f_amount_gwei=EXCLUDED.f_amount_gwei
+`
+
+var insertConsolidationRequest = `
+INSERT INTO t_consolidation_requests(
+	f_epoch,
+	f_slot,
+	f_block_number,
+	f_request_index,
+	f_pool,
+	f_source_address,
+	f_source_pubkey,
+	f_target_pubkey)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (f_slot, f_request_index)
+DO UPDATE SET
+   f_epoch=EXCLUDED.f_epoch,
+   f_block_number=EXCLUDED.f_block_number,
+   f_pool=EXCLUDED.f_pool,
+   f_source_address=EXCLUDED.f_source_address,
+   f_source_pubkey=EXCLUDED.f_source_pubkey,
+   f_target_pubkey=EXCLUDED.f_target_pubkey
+`
+
+var insertPartialWithdrawal = `
+INSERT INTO t_partial_withdrawals(
+	f_epoch,
+	f_validator_index,
+	f_pool,
+	f_n_requests,
+	f_withdrawable_epoch,
+	f_requested_gwei,
+	f_withdrawn_gwei)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (f_epoch, f_validator_index)
+DO UPDATE SET
+   f_pool=EXCLUDED.f_pool,
+   f_n_requests=EXCLUDED.f_n_requests,
+   f_withdrawable_epoch=EXCLUDED.f_withdrawable_epoch,
+   f_requested_gwei=EXCLUDED.f_requested_gwei,
+   f_withdrawn_gwei=EXCLUDED.f_withdrawn_gwei
+`
+
+var insertPendingPartialWithdrawals = `
+INSERT INTO t_pending_partial_withdrawals(
+	f_epoch,
+	f_pool,
+	f_n_pending,
+	f_pending_gwei,
+	f_last_withdrawable_epoch)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (f_epoch, f_pool)
+DO UPDATE SET
+   f_n_pending=EXCLUDED.f_n_pending,
+   f_pending_gwei=EXCLUDED.f_pending_gwei,
+   f_last_withdrawable_epoch=EXCLUDED.f_last_withdrawable_epoch
+`
+
+var insertProposal = `
+INSERT INTO t_proposals(
+	f_epoch,
+	f_slot,
+	f_validator_index,
+	f_pool,
+	f_consensus_reward_gwei,
+	f_execution_tip_wei,
+	f_mev_reward_wei,
+	f_relays,
+	f_builder_pubkey,
+	f_fee_recipient,
+	f_vanilla,
+	f_attestations_reward_gwei,
+	f_sync_aggregate_reward_gwei,
+	f_slashings_reward_gwei)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (f_slot)
+DO UPDATE SET
+   f_epoch=EXCLUDED.f_epoch,
+   f_validator_index=EXCLUDED.f_validator_index,
+   f_pool=EXCLUDED.f_pool,
+   f_consensus_reward_gwei=EXCLUDED.f_consensus_reward_gwei,
+   f_execution_tip_wei=EXCLUDED.f_execution_tip_wei,
+   f_mev_reward_wei=EXCLUDED.f_mev_reward_wei,
+   f_relays=EXCLUDED.f_relays,
+   f_builder_pubkey=EXCLUDED.f_builder_pubkey,
+   f_fee_recipient=EXCLUDED.f_fee_recipient,
+   f_vanilla=EXCLUDED.f_vanilla,
+   f_attestations_reward_gwei=EXCLUDED.f_attestations_reward_gwei,
+   f_sync_aggregate_reward_gwei=EXCLUDED.f_sync_aggregate_reward_gwei,
+   f_slashings_reward_gwei=EXCLUDED.f_slashings_reward_gwei
+`
+
+var insertEpochBlockRoots = `
+INSERT INTO t_epoch_block_roots(
+	f_epoch,
+	f_block_roots)
+VALUES (?, ?)
+ON CONFLICT (f_epoch)
+DO UPDATE SET
+   f_block_roots=EXCLUDED.f_block_roots
+`
+
+var insertPoolMembershipChange = `
+INSERT INTO t_pool_membership(
+	f_epoch,
+	f_pool,
+	f_validator_pubkey,
+	f_action,
+	f_source,
+	f_timestamp)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT (f_pool, f_validator_pubkey, f_epoch)
+DO UPDATE SET
+   f_action=EXCLUDED.f_action,
+   f_source=EXCLUDED.f_source,
+   f_timestamp=EXCLUDED.f_timestamp
+`
+
+var insertReportSent = `
+INSERT OR IGNORE INTO t_reports_sent(
+	f_period,
+	f_start,
+	f_timestamp)
+VALUES (?, ?, ?)
+`
+
+// Alerts of an epoch that is processed again are not stored twice
+var insertAlert = `
+INSERT OR IGNORE INTO t_alerts(
+	f_timestamp,
+	f_epoch,
+	f_pool,
+	f_kind,
+	f_severity,
+	f_message)
+VALUES (?, ?, ?, ?, ?, ?)
+`
+
+var insertBackfillProgress = `
+INSERT INTO t_backfill_progress(
+	f_from_epoch,
+	f_to_epoch,
+	f_last_epoch,
+	f_timestamp)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (f_from_epoch, f_to_epoch)
+DO UPDATE SET
+   f_last_epoch=EXCLUDED.f_last_epoch,
+   f_timestamp=EXCLUDED.f_timestamp
+`
+
+var insertRelayBidTraces = `
+INSERT INTO t_relay_bidtraces(
+	f_relay,
+	f_slot,
+	f_payloads)
+VALUES (?, ?, ?)
+ON CONFLICT (f_relay, f_slot)
+DO UPDATE SET
+   f_payloads=EXCLUDED.f_payloads
+`
+
+var insertBlockBlobs = `
+INSERT INTO t_block_blobs(
+	f_epoch,
+	f_slot,
+	f_validator_index,
+	f_pool,
+	f_n_blobs,
+	f_blob_gas_used,
+	f_max_blob_gas,
+	f_excess_blob_gas,
+	f_blob_base_fee_wei,
+	f_blob_fee_wei)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (f_slot)
+DO UPDATE SET
+   f_epoch=EXCLUDED.f_epoch,
+   f_validator_index=EXCLUDED.f_validator_index,
+   f_pool=EXCLUDED.f_pool,
+   f_n_blobs=EXCLUDED.f_n_blobs,
+   f_blob_gas_used=EXCLUDED.f_blob_gas_used,
+   f_max_blob_gas=EXCLUDED.f_max_blob_gas,
+   f_excess_blob_gas=EXCLUDED.f_excess_blob_gas,
+   f_blob_base_fee_wei=EXCLUDED.f_blob_base_fee_wei,
+   f_blob_fee_wei=EXCLUDED.f_blob_fee_wei
+`
+
+var insertFeeRecipientViolation = `
+INSERT INTO t_fee_recipient_violations(
+	f_epoch,
+	f_slot,
+	f_validator_index,
+	f_pool,
+	f_fee_recipient,
+	f_expected,
+	f_mev)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (f_slot)
+DO UPDATE SET
+   f_epoch=EXCLUDED.f_epoch,
+   f_validator_index=EXCLUDED.f_validator_index,
+   f_pool=EXCLUDED.f_pool,
+   f_fee_recipient=EXCLUDED.f_fee_recipient,
+   f_expected=EXCLUDED.f_expected,
+   f_mev=EXCLUDED.f_mev
+`
+
+var insertMEVPayout = `
+INSERT INTO t_mev_payouts(
+	f_epoch,
+	f_slot,
+	f_validator_index,
+	f_pool,
+	f_fee_recipient,
+	f_relays,
+	f_builder_pubkey,
+	f_reported_wei,
+	f_paid_wei,
+	f_shortfall_wei)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (f_slot)
+DO UPDATE SET
+   f_epoch=EXCLUDED.f_epoch,
+   f_validator_index=EXCLUDED.f_validator_index,
+   f_pool=EXCLUDED.f_pool,
+   f_fee_recipient=EXCLUDED.f_fee_recipient,
+   f_relays=EXCLUDED.f_relays,
+   f_builder_pubkey=EXCLUDED.f_builder_pubkey,
+   f_reported_wei=EXCLUDED.f_reported_wei,
+   f_paid_wei=EXCLUDED.f_paid_wei,
+   f_shortfall_wei=EXCLUDED.f_shortfall_wei
+`
+
+var insertMissedMEV = `
+INSERT INTO t_missed_mev(
+	f_epoch,
+	f_slot,
+	f_validator_index,
+	f_pool,
+	f_relay,
+	f_builder_pubkey,
+	f_best_bid_wei,
+	f_tip_wei,
+	f_missed_wei)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (f_slot)
+DO UPDATE SET
+   f_epoch=EXCLUDED.f_epoch,
+   f_validator_index=EXCLUDED.f_validator_index,
+   f_pool=EXCLUDED.f_pool,
+   f_relay=EXCLUDED.f_relay,
+   f_builder_pubkey=EXCLUDED.f_builder_pubkey,
+   f_best_bid_wei=EXCLUDED.f_best_bid_wei,
+   f_tip_wei=EXCLUDED.f_tip_wei,
+   f_missed_wei=EXCLUDED.f_missed_wei
+`
+
+var insertProposalTiming = `
+INSERT INTO t_proposal_timings(
+	f_epoch,
+	f_slot,
+	f_validator_index,
+	f_pool,
+	f_seen_timestamp,
+	f_delay_ms,
+	f_late)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (f_slot)
+DO UPDATE SET
+   f_epoch=EXCLUDED.f_epoch,
+   f_validator_index=EXCLUDED.f_validator_index,
+   f_pool=EXCLUDED.f_pool,
+   f_seen_timestamp=EXCLUDED.f_seen_timestamp,
+   f_delay_ms=EXCLUDED.f_delay_ms,
+   f_late=EXCLUDED.f_late
+`
+
+var insertValidatorStatus = `
+INSERT INTO t_validator_status(
+	f_epoch,
+	f_pool,
+	f_n_pending,
+	f_n_active,
+	f_n_exiting,
+	f_n_exited,
+	f_n_withdrawable,
+	f_n_slashed,
+	f_activation_wait_epochs,
+	f_exit_wait_epochs)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (f_epoch, f_pool)
+DO UPDATE SET
+   f_n_pending=EXCLUDED.f_n_pending,
+   f_n_active=EXCLUDED.f_n_active,
+   f_n_exiting=EXCLUDED.f_n_exiting,
+   f_n_exited=EXCLUDED.f_n_exited,
+   f_n_withdrawable=EXCLUDED.f_n_withdrawable,
+   f_n_slashed=EXCLUDED.f_n_slashed,
+   f_activation_wait_epochs=EXCLUDED.f_activation_wait_epochs,
+   f_exit_wait_epochs=EXCLUDED.f_exit_wait_epochs
 `
 
 var insertNetworkStats = `
@@ -141,106 +1718,2035 @@ INSERT INTO t_network_stats(
 	f_epoch,
 	f_n_active_validators,
 	f_n_exited_validators,
-	f_n_slashed_validators)
-VALUES (?, ?, ?, ?, ?)
+	f_n_slashed_validators,
+	f_source_participation,
+	f_target_participation,
+	f_head_participation,
+	f_attestation_effectiveness,
+	f_n_scheduled_blocks,
+	f_n_proposed_blocks,
+	f_proposal_rate,
+	f_n_blobs,
+	f_blob_gas_used,
+	f_blob_utilization,
+	f_blob_base_fee_wei)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 ON CONFLICT (f_epoch)
 DO UPDATE SET
    f_timestamp=EXCLUDED.f_timestamp,
    f_n_active_validators=EXCLUDED.f_n_active_validators,
    f_n_exited_validators=EXCLUDED.f_n_exited_validators,
-   f_n_slashed_validators=EXCLUDED.f_n_slashed_validators
+   f_n_slashed_validators=EXCLUDED.f_n_slashed_validators,
+   f_source_participation=EXCLUDED.f_source_participation,
+   f_target_participation=EXCLUDED.f_target_participation,
+   f_head_participation=EXCLUDED.f_head_participation,
+   f_attestation_effectiveness=EXCLUDED.f_attestation_effectiveness,
+   f_n_scheduled_blocks=EXCLUDED.f_n_scheduled_blocks,
+   f_n_proposed_blocks=EXCLUDED.f_n_proposed_blocks,
+   f_proposal_rate=EXCLUDED.f_proposal_rate,
+   f_n_blobs=EXCLUDED.f_n_blobs,
+   f_blob_gas_used=EXCLUDED.f_blob_gas_used,
+   f_blob_utilization=EXCLUDED.f_blob_utilization,
+   f_blob_base_fee_wei=EXCLUDED.f_blob_base_fee_wei
+`
+
+var insertPoolSLA = `
+INSERT INTO t_pool_sla(
+	f_period,
+	f_start,
+	f_pool,
+	f_n_epochs,
+	f_n_maintenance_epochs,
+	f_n_attestation_duties,
+	f_n_missed_attestations,
+	f_n_scheduled_blocks,
+	f_n_proposed_blocks,
+	f_attestation_uptime,
+	f_proposal_success,
+	f_attestation_target,
+	f_proposal_target,
+	f_attestation_breached,
+	f_proposal_breached,
+	f_timestamp)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (f_period, f_start, f_pool)
+DO UPDATE SET
+   f_n_epochs=EXCLUDED.f_n_epochs,
+   f_n_maintenance_epochs=EXCLUDED.f_n_maintenance_epochs,
+   f_n_attestation_duties=EXCLUDED.f_n_attestation_duties,
+   f_n_missed_attestations=EXCLUDED.f_n_missed_attestations,
+   f_n_scheduled_blocks=EXCLUDED.f_n_scheduled_blocks,
+   f_n_proposed_blocks=EXCLUDED.f_n_proposed_blocks,
+   f_attestation_uptime=EXCLUDED.f_attestation_uptime,
+   f_proposal_success=EXCLUDED.f_proposal_success,
+   f_attestation_target=EXCLUDED.f_attestation_target,
+   f_proposal_target=EXCLUDED.f_proposal_target,
+   f_attestation_breached=EXCLUDED.f_attestation_breached,
+   f_proposal_breached=EXCLUDED.f_proposal_breached,
+   f_timestamp=EXCLUDED.f_timestamp
+`
+
+var insertEffectiveness = `
+INSERT INTO t_effectiveness(
+	f_epoch_timestamp,
+	f_epoch,
+	f_pool,
+	f_attestation,
+	f_inclusion,
+	f_proposal,
+	f_sync_committee,
+	f_score,
+	f_source_participation_delta,
+	f_target_participation_delta,
+	f_head_participation_delta,
+	f_attestation_delta,
+	f_proposal_delta,
+	f_score_delta)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (f_epoch, f_pool)
+DO UPDATE SET
+   f_epoch_timestamp=EXCLUDED.f_epoch_timestamp,
+   f_attestation=EXCLUDED.f_attestation,
+   f_inclusion=EXCLUDED.f_inclusion,
+   f_proposal=EXCLUDED.f_proposal,
+   f_sync_committee=EXCLUDED.f_sync_committee,
+   f_score=EXCLUDED.f_score,
+   f_source_participation_delta=EXCLUDED.f_source_participation_delta,
+   f_target_participation_delta=EXCLUDED.f_target_participation_delta,
+   f_head_participation_delta=EXCLUDED.f_head_participation_delta,
+   f_attestation_delta=EXCLUDED.f_attestation_delta,
+   f_proposal_delta=EXCLUDED.f_proposal_delta,
+   f_score_delta=EXCLUDED.f_score_delta
+`
+
+var insertNetworkEffectiveness = `
+INSERT INTO t_network_effectiveness(
+	f_epoch_timestamp,
+	f_epoch,
+	f_attestation,
+	f_inclusion,
+	f_proposal,
+	f_sync_committee,
+	f_score)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (f_epoch)
+DO UPDATE SET
+   f_epoch_timestamp=EXCLUDED.f_epoch_timestamp,
+   f_attestation=EXCLUDED.f_attestation,
+   f_inclusion=EXCLUDED.f_inclusion,
+   f_proposal=EXCLUDED.f_proposal,
+   f_sync_committee=EXCLUDED.f_sync_committee,
+   f_score=EXCLUDED.f_score
+`
+
+var insertNetworkQueues = `
+INSERT INTO t_network_queues(
+	f_timestamp,
+	f_epoch,
+	f_churn_limit_gwei,
+	f_n_pending_deposits,
+	f_pending_deposits_gwei,
+	f_n_exiting_validators,
+	f_exiting_balance_gwei,
+	f_entry_wait_epochs,
+	f_exit_wait_epochs)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (f_epoch)
+DO UPDATE SET
+   f_timestamp=EXCLUDED.f_timestamp,
+   f_churn_limit_gwei=EXCLUDED.f_churn_limit_gwei,
+   f_n_pending_deposits=EXCLUDED.f_n_pending_deposits,
+   f_pending_deposits_gwei=EXCLUDED.f_pending_deposits_gwei,
+   f_n_exiting_validators=EXCLUDED.f_n_exiting_validators,
+   f_exiting_balance_gwei=EXCLUDED.f_exiting_balance_gwei,
+   f_entry_wait_epochs=EXCLUDED.f_entry_wait_epochs,
+   f_exit_wait_epochs=EXCLUDED.f_exit_wait_epochs
+`
+
+var insertRelayStats = `
+INSERT INTO t_relay_stats(
+	f_epoch,
+	f_relay,
+	f_n_requests,
+	f_n_errors,
+	f_n_payloads,
+	f_value_wei,
+	f_avg_latency_ms,
+	f_skipped)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (f_epoch, f_relay)
+DO UPDATE SET
+   f_n_requests=EXCLUDED.f_n_requests,
+   f_n_errors=EXCLUDED.f_n_errors,
+   f_n_payloads=EXCLUDED.f_n_payloads,
+   f_value_wei=EXCLUDED.f_value_wei,
+   f_avg_latency_ms=EXCLUDED.f_avg_latency_ms,
+   f_skipped=EXCLUDED.f_skipped
 `
 
-type Database struct {
-	db       *sql.DB
-	PoolName string
+type Database struct {
+	db       *sql.DB
+	PoolName string
+
+	// Transaction of the epoch being stored, with the statements prepared in it
+	mu         sync.Mutex
+	epochTx    *sql.Tx
+	epochStmts map[string]*sql.Stmt
+}
+
+// Implemented by both the database and a transaction
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Pragmas set on every connection to the database. Empty or zero values keep
+// the SQLite default.
+type Options struct {
+	JournalMode string
+	Synchronous string
+	BusyTimeout time.Duration
+	// In KiB
+	CacheSize int
+}
+
+// WAL lets dashboards read the database while the epochs are written, and in
+// WAL mode synchronous=NORMAL is still safe against corruption
+func DefaultOptions() Options {
+	return Options{
+		JournalMode: "WAL",
+		Synchronous: "NORMAL",
+		BusyTimeout: 10 * time.Second,
+	}
+}
+
+// Options of the --sqlite-* flags
+func OptionsFromConfig(cfg *config.Config) Options {
+	return Options{
+		JournalMode: cfg.SqliteJournalMode,
+		Synchronous: cfg.SqliteSynchronous,
+		BusyTimeout: time.Duration(cfg.SqliteBusyTimeout) * time.Millisecond,
+		CacheSize:   cfg.SqliteCacheSize,
+	}
+}
+
+func New(dbPath string) (*Database, error) {
+	return NewWithOptions(dbPath, DefaultOptions())
+}
+
+func NewWithOptions(dbPath string, options Options) (*Database, error) {
+	db, err := sql.Open("sqlite", DataSourceName(dbPath, options))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Database{
+		db: db,
+	}, nil
+}
+
+// Adds the pragmas to the path, unless it already has parameters. Modules of
+// the epoch processing store concurrently, so writes wait for each other
+// instead of failing as busy, and transactions take the write lock when they
+// begin, since a read transaction can't wait to become a write one.
+func DataSourceName(dbPath string, options Options) string {
+	if dbPath == ":memory:" || strings.Contains(dbPath, "?") {
+		return dbPath
+	}
+	params := []string{"_txlock=immediate"}
+	if options.BusyTimeout > 0 {
+		params = append(params, "_pragma=busy_timeout("+strconv.FormatInt(options.BusyTimeout.Milliseconds(), 10)+")")
+	}
+	if options.JournalMode != "" {
+		params = append(params, "_pragma=journal_mode("+options.JournalMode+")")
+	}
+	if options.Synchronous != "" {
+		params = append(params, "_pragma=synchronous("+options.Synchronous+")")
+	}
+	if options.CacheSize > 0 {
+		// Negative sizes are in KiB instead of pages
+		params = append(params, "_pragma=cache_size(-"+strconv.Itoa(options.CacheSize)+")")
+	}
+	return dbPath + "?" + strings.Join(params, "&")
+}
+
+// Runs fn again while the database is busy, which can still happen after
+// waiting for the busy timeout if a dashboard keeps reading
+func retryBusy(fn func() error) error {
+	backoff := busyRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if !IsBusy(err) || attempt >= busyRetries {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// Returns true if the error is SQLITE_BUSY or SQLITE_LOCKED, or one of their
+// extended codes
+func IsBusy(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	code := sqliteErr.Code() & 0xff
+	return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+}
+
+// Opens a transaction where all the writes are done until CommitEpoch, so that
+// an epoch that fails or is interrupted midway leaves no rows and is still
+// considered missing. The statements are prepared once per transaction.
+func (a *Database) BeginEpoch() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.epochTx != nil {
+		return errors.New("epoch transaction already open")
+	}
+	var tx *sql.Tx
+	err := retryBusy(func() error {
+		var err error
+		tx, err = a.db.BeginTx(context.Background(), nil)
+		return err
+	})
+	if err != nil {
+		return errors.Wrap(err, "could not begin epoch transaction")
+	}
+	a.epochTx = tx
+	a.epochStmts = make(map[string]*sql.Stmt)
+	return nil
+}
+
+// Commits the writes done since BeginEpoch
+func (a *Database) CommitEpoch() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.epochTx == nil {
+		return errors.New("no epoch transaction open")
+	}
+	defer observeWrite("epoch", time.Now())
+	err := a.epochTx.Commit()
+	a.epochTx = nil
+	a.epochStmts = nil
+	return errors.Wrap(err, "could not commit epoch transaction")
+}
+
+// Discards the writes done since BeginEpoch. Does nothing if they were
+// committed, so that it can be deferred.
+func (a *Database) RollbackEpoch() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.epochTx == nil {
+		return
+	}
+	a.epochTx.Rollback()
+	a.epochTx = nil
+	a.epochStmts = nil
+}
+
+// Marks where the writes of a pool start in the transaction of the epoch, so
+// that RollbackPool discards only them if the pool fails. Does nothing if no
+// epoch transaction is open.
+func (a *Database) BeginPool() error {
+	return a.execSavepoint("SAVEPOINT pool", "could not begin pool savepoint")
+}
+
+// Keeps the writes done since BeginPool in the transaction of the epoch
+func (a *Database) ReleasePool() error {
+	return a.execSavepoint("RELEASE pool", "could not release pool savepoint")
+}
+
+// Discards the writes done since BeginPool, keeping the rest of the epoch
+func (a *Database) RollbackPool() error {
+	if err := a.execSavepoint("ROLLBACK TO pool", "could not roll back pool savepoint"); err != nil {
+		return err
+	}
+	return a.ReleasePool()
+}
+
+func (a *Database) execSavepoint(query string, message string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.epochTx == nil {
+		return nil
+	}
+	_, err := a.epochTx.Exec(query)
+	return errors.Wrap(err, message)
+}
+
+// Returns a handle to the same database that never writes in the transaction
+// of the epoch, for the writers that run alongside the epoch processing, like
+// the api, whose rows must not be discarded if the epoch is rolled back
+func (a *Database) Detached() *Database {
+	return &Database{
+		db:       a.db,
+		PoolName: a.PoolName,
+	}
+}
+
+// Returns the transaction of the epoch if one is open, or the database
+func (a *Database) conn() queryer {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.epochTx != nil {
+		return a.epochTx
+	}
+	return a.db
+}
+
+// Executes the query in the transaction of the epoch with a prepared
+// statement if one is open, or directly in the database otherwise. Retried
+// while busy.
+func (a *Database) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	var result sql.Result
+	err := retryBusy(func() error {
+		var err error
+		result, err = a.execOnce(ctx, query, args...)
+		return err
+	})
+	return result, err
+}
+
+func (a *Database) execOnce(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	a.mu.Lock()
+	if a.epochTx == nil {
+		a.mu.Unlock()
+		return a.db.ExecContext(ctx, query, args...)
+	}
+	stmt, ok := a.epochStmts[query]
+	if !ok {
+		var err error
+		stmt, err = a.epochTx.PrepareContext(ctx, query)
+		if err != nil {
+			a.mu.Unlock()
+			return nil, errors.Wrap(err, "could not prepare statement")
+		}
+		a.epochStmts[query] = stmt
+	}
+	a.mu.Unlock()
+	return stmt.ExecContext(ctx, args...)
+}
+
+// Runs fn in the transaction of the epoch if one is open, or in a new
+// transaction otherwise, which is retried as a whole while busy
+func (a *Database) inTx(fn func(tx *sql.Tx) error) error {
+	a.mu.Lock()
+	tx := a.epochTx
+	a.mu.Unlock()
+	if tx != nil {
+		return fn(tx)
+	}
+
+	return retryBusy(func() error {
+		tx, err := a.db.BeginTx(context.Background(), nil)
+		if err != nil {
+			return errors.Wrap(err, "could not begin transaction")
+		}
+		defer tx.Rollback()
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+func (a *Database) CreateTables() error {
+	if _, err := a.exec(
+		context.Background(),
+		createPoolsMetricsTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createProposalDutiesTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createNetworkStatsTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createNetworkQueuesTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createValidatorMetricsTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createSlashingsTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createVoluntaryExitsTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createBLSChangesTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createDepositsTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createWithdrawalRequestsTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createConsolidationRequestsTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createPartialWithdrawalsTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createPendingPartialWithdrawalsTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createClientDiversityTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createMissedAttestationsTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createProcessingStatusTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createEpochsProcessedTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		seedEpochsProcessed); err != nil {
+		return errors.Wrap(err, "could not seed processed epochs")
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createProposalsTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createEpochBlockRootsTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createValidatorStatusTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createRelayStatsTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createRelayBidTracesTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createFeeRecipientViolationsTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createBlockBlobsTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createMEVPayoutsTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createMissedMEVTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createProposalTimingsTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createBackfillProgressTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createPoolsMetricsDailyTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createPoolMembershipTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createReportsSentTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createEffectivenessTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createNetworkEffectivenessTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createAlertsTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createMaintenanceWindowsTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createPoolSLATable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createKeyIssuesTable); err != nil {
+		return err
+	}
+
+	if _, err := a.exec(
+		context.Background(),
+		createTenantPoolsTable); err != nil {
+		return err
+	}
+
+	for _, c := range addedColumns {
+		if err := a.addColumnIfMissing(c.table, c.column, c.columnType); err != nil {
+			return errors.Wrap(err, "could not add column "+c.column+" to "+c.table)
+		}
+	}
+
+	for _, view := range dropPoolsMetricsViews {
+		if _, err := a.exec(context.Background(), view); err != nil {
+			return errors.Wrap(err, "could not drop views")
+		}
+	}
+
+	for _, c := range weiColumns {
+		if err := a.migrateColumnToText(c.table, c.column); err != nil {
+			return errors.Wrap(err, "could not migrate column "+c.column+" of "+c.table)
+		}
+	}
+
+	for _, view := range createPoolsMetricsViews {
+		if _, err := a.exec(context.Background(), view); err != nil {
+			return errors.Wrap(err, "could not create views")
+		}
+	}
+
+	return nil
+}
+
+func (a *Database) addColumnIfMissing(table string, column string, columnType string) error {
+	rows, err := a.conn().QueryContext(context.Background(), "SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = a.exec(
+		context.Background(),
+		"ALTER TABLE "+table+" ADD COLUMN "+column+" "+columnType)
+	return err
+}
+
+// Changes the type of a column to TEXT, keeping its values, with floats written
+// without exponent. SQLite can't change the type of a column, so it is copied
+// into a new one
+func (a *Database) migrateColumnToText(table string, column string) error {
+	var columnType string
+	err := a.conn().QueryRowContext(
+		context.Background(),
+		"SELECT type FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&columnType)
+	if err == sql.ErrNoRows || columnType == "TEXT" {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	tx, err := a.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		"ALTER TABLE " + table + " RENAME COLUMN " + column + " TO " + column + "_old",
+		"ALTER TABLE " + table + " ADD COLUMN " + column + " TEXT",
+		"UPDATE " + table + " SET " + column + " = CASE WHEN typeof(" + column + "_old) = 'real' " +
+			"THEN printf('%.0f', " + column + "_old) ELSE CAST(" + column + "_old AS TEXT) END",
+		"ALTER TABLE " + table + " DROP COLUMN " + column + "_old",
+	} {
+		if _, err := tx.ExecContext(context.Background(), query); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (a *Database) CreateEthPriceTable() error {
+	if _, err := a.exec(
+		context.Background(),
+		createEthPriceTable); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (a *Database) StoreProposalDuties(
+	epoch uint64,
+	poolName string,
+	scheduledBlocks uint64,
+	proposedBlocks uint64,
+	missedEmpty uint64,
+	missedOrphaned uint64,
+	vanillaBlocks uint64) error {
+	defer observeWrite("t_proposal_duties", time.Now())
+	vanillaRatio := float64(0)
+	if proposedBlocks > 0 {
+		vanillaRatio = float64(vanillaBlocks) / float64(proposedBlocks)
+	}
+	_, err := a.exec(
+		context.Background(),
+		insertProposalDuties,
+		epoch,
+		poolName,
+		scheduledBlocks,
+		proposedBlocks,
+		missedEmpty,
+		missedOrphaned,
+		vanillaBlocks,
+		vanillaRatio)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (a *Database) StoreValidatorPerformance(validatorPerformance schemas.ValidatorPerformanceMetrics) error {
+	defer observeWrite("t_pools_metrics_summary", time.Now())
+	_, err := a.exec(
+		context.Background(),
+		insertValidatorPerformance,
+		validatorPerformance.Time,
+		validatorPerformance.Epoch,
+		validatorPerformance.PoolName,
+		validatorPerformance.Time,
+		validatorPerformance.NOfActiveValidators,
+		validatorPerformance.NOfTotalVotes,
+		validatorPerformance.NOfIncorrectSource,
+		validatorPerformance.NOfIncorrectTarget,
+		validatorPerformance.NOfIncorrectHead,
+		validatorPerformance.NOfValidatingKeys,
+		validatorPerformance.NOfValsWithLessBalance,
+		func(indexes []uint64) string {
+			strs := make([]string, len(indexes))
+			for i, v := range indexes {
+				strs[i] = strconv.FormatUint(v, 10)
+			}
+			return strings.Join(strs, ",")
+		}(validatorPerformance.IndexesLessBalance),
+		validatorPerformance.EffectiveBalance.Int64(),
+		validatorPerformance.EarnedBalance.Int64(),
+		validatorPerformance.LosedBalance.Int64(),
+		Wei{validatorPerformance.MEVRewards},
+		Wei{validatorPerformance.ProposerTips},
+		validatorPerformance.AvgInclusionDelay,
+		validatorPerformance.MaxInclusionDelay,
+		validatorPerformance.NOfSlashedValidators,
+		validatorPerformance.AttestationRewards.Head,
+		validatorPerformance.AttestationRewards.Target,
+		validatorPerformance.AttestationRewards.Source,
+		validatorPerformance.AttestationRewards.InclusionDelay,
+		validatorPerformance.AttestationRewards.Inactivity,
+		validatorPerformance.AttestationRewards.Ideal,
+		validatorPerformance.ProcessingMode,
+		validatorPerformance.EthPriceUsd,
+		validatorPerformance.EarnedUsd,
+		validatorPerformance.MEVRewardsUsd,
+		validatorPerformance.MEVPartial,
+		validatorPerformance.SyncCommitteeRewards,
+		validatorPerformance.ProposerRewards,
+		gwei(validatorPerformance.EffectiveBalanceChange),
+		validatorPerformance.NOfCompoundingValidators,
+		gwei(validatorPerformance.PartialWithdrawals),
+		gwei(validatorPerformance.FullWithdrawals),
+		validatorPerformance.NOfFullWithdrawals,
+	)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Rolls up the metrics and proposal duties of the pools of the group in the
+// epoch into rows of the group, and labels the rows of its members with it.
+// Returns false without storing anything if some pool has no metrics in the
+// epoch, e.g. because it failed, so that the group is not stored incomplete.
+func (a *Database) StorePoolGroupMetrics(epoch uint64, group schemas.PoolGroup) (bool, error) {
+	defer observeWrite("t_pools_metrics_summary", time.Now())
+	pools, err := json.Marshal(group.Pools)
+	if err != nil {
+		return false, err
+	}
+	members, err := json.Marshal(group.Members)
+	if err != nil {
+		return false, err
+	}
+
+	stored := false
+	err = a.inTx(func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(
+			context.Background(),
+			"SELECT f_mev_rewards_wei, f_proposer_tips_wei FROM t_pools_metrics_summary WHERE f_epoch = ? AND f_pool IN (SELECT value FROM json_each(?))",
+			epoch, string(pools))
+		if err != nil {
+			return errors.Wrap(err, "could not get pool metrics")
+		}
+		defer rows.Close()
+		nOfPools := 0
+		mevRewards := big.NewInt(0)
+		proposerTips := big.NewInt(0)
+		for rows.Next() {
+			var mevReward, proposerTip Wei
+			if err := rows.Scan(&mevReward, &proposerTip); err != nil {
+				return err
+			}
+			if mevReward.Int != nil {
+				mevRewards.Add(mevRewards, mevReward.Int)
+			}
+			if proposerTip.Int != nil {
+				proposerTips.Add(proposerTips, proposerTip.Int)
+			}
+			nOfPools++
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+		if nOfPools < len(group.Pools) {
+			return nil
+		}
+
+		if _, err := tx.ExecContext(context.Background(), rollupPoolGroupMetrics,
+			group.Name, Wei{mevRewards}, Wei{proposerTips}, group.Parent, epoch, string(pools)); err != nil {
+			return errors.Wrap(err, "could not roll up pool metrics")
+		}
+		if _, err := tx.ExecContext(context.Background(), rollupPoolGroupProposalDuties,
+			group.Name, epoch, string(pools)); err != nil {
+			return errors.Wrap(err, "could not roll up proposal duties")
+		}
+		if _, err := tx.ExecContext(context.Background(),
+			"UPDATE t_pools_metrics_summary SET f_parent_group = ? WHERE f_epoch = ? AND f_pool IN (SELECT value FROM json_each(?))",
+			group.Name, epoch, string(members)); err != nil {
+			return errors.Wrap(err, "could not label pool group members")
+		}
+		stored = true
+		return nil
+	})
+	return stored, err
+}
+
+// Stores all the per validator rows of a pool in a single transaction, since
+// large pools can contain thousands of them
+func (a *Database) StoreValidatorMetrics(validatorMetrics []schemas.ValidatorMetrics) error {
+	defer observeWrite("t_validator_metrics", time.Now())
+	return a.inTx(func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(context.Background(), insertValidatorMetrics)
+		if err != nil {
+			return errors.Wrap(err, "could not prepare statement")
+		}
+		defer stmt.Close()
+
+		for _, m := range validatorMetrics {
+			_, err := stmt.ExecContext(
+				context.Background(),
+				m.Time,
+				m.Epoch,
+				m.PoolName,
+				m.ValIndex,
+				m.PubKey,
+				m.BalanceDelta.Int64(),
+				m.MissedSource,
+				m.MissedTarget,
+				m.MissedHead,
+				m.AttestationIncluded,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Replaces the missed attestations of a pool in an epoch, so that processing
+// the epoch again after a reorg doesn't keep the misses that no longer apply
+func (a *Database) StoreMissedAttestations(epoch uint64, poolName string, missed []schemas.MissedAttestation) error {
+	defer observeWrite("t_missed_attestations", time.Now())
+	return a.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(
+			context.Background(),
+			"DELETE FROM t_missed_attestations WHERE f_epoch = ? AND f_pool = ?",
+			epoch, poolName); err != nil {
+			return err
+		}
+
+		stmt, err := tx.PrepareContext(context.Background(), insertMissedAttestation)
+		if err != nil {
+			return errors.Wrap(err, "could not prepare statement")
+		}
+		defer stmt.Close()
+
+		for _, m := range missed {
+			_, err := stmt.ExecContext(
+				context.Background(),
+				m.Epoch,
+				m.PoolName,
+				m.ValIndex,
+				m.MissType,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (a *Database) StoreProcessingStatus(status schemas.ProcessingStatus) error {
+	defer observeWrite("t_processing_status", time.Now())
+	state := "ok"
+	if status.Error != "" {
+		state = "failed"
+	}
+	_, err := a.exec(
+		context.Background(),
+		insertProcessingStatus,
+		status.Epoch,
+		status.PoolName,
+		state,
+		status.Error,
+		time.Now(),
+	)
+	return err
+}
+
+// Returns the pools that failed in each epoch and were attempted less than
+// maxAttempts times
+func (a *Database) GetFailedPools(maxAttempts uint64) (map[uint64][]string, error) {
+	rows, err := a.conn().QueryContext(
+		context.Background(),
+		"SELECT f_epoch, f_pool FROM t_processing_status WHERE f_status = 'failed' AND f_attempts < ? ORDER BY f_epoch, f_pool",
+		maxAttempts)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get failed pools")
+	}
+	defer rows.Close()
+
+	failedPools := make(map[uint64][]string)
+	for rows.Next() {
+		var epoch uint64
+		var poolName string
+		if err := rows.Scan(&epoch, &poolName); err != nil {
+			return nil, err
+		}
+		failedPools[epoch] = append(failedPools[epoch], poolName)
+	}
+	return failedPools, rows.Err()
+}
+
+func (a *Database) StoreSlashing(slashing schemas.Slashing) error {
+	defer observeWrite("t_slashings", time.Now())
+	_, err := a.exec(
+		context.Background(),
+		insertSlashing,
+		slashing.Epoch,
+		slashing.Slot,
+		slashing.ValIndex,
+		slashing.PoolName,
+		slashing.SlashingType)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (a *Database) StoreVoluntaryExit(exit schemas.VoluntaryExit) error {
+	defer observeWrite("t_voluntary_exits", time.Now())
+	_, err := a.exec(
+		context.Background(),
+		insertVoluntaryExit,
+		exit.Epoch,
+		exit.Slot,
+		exit.ValIndex,
+		exit.PoolName,
+		exit.ExitEpoch)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (a *Database) StoreDeposit(deposit schemas.Deposit) error {
+	defer observeWrite("t_deposits", time.Now())
+	_, err := a.exec(
+		context.Background(),
+		insertDeposit,
+		deposit.Epoch,
+		deposit.Slot,
+		deposit.BlockNumber,
+		deposit.Timestamp,
+		deposit.DepositIndex,
+		deposit.PoolName,
+		deposit.Pubkey,
+		deposit.WithdrawalCredentials,
+		deposit.Amount)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Sets the activation epoch of the deposits made to the validator before the
+// given activation time, and how long they took to be activated
+func (a *Database) StoreDepositActivation(pubkey string, activationEpoch uint64, activationTime uint64) error {
+	defer observeWrite("t_deposits", time.Now())
+	_, err := a.exec(
+		context.Background(),
+		updateDepositActivation,
+		activationEpoch,
+		activationTime,
+		pubkey,
+		activationTime)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (a *Database) StoreClientDiversity(diversity schemas.ClientDiversity) error {
+	defer observeWrite("t_client_diversity", time.Now())
+	_, err := a.exec(
+		context.Background(),
+		insertClientDiversity,
+		diversity.Epoch,
+		diversity.PoolName,
+		diversity.ConsensusClient,
+		diversity.ExecutionClient,
+		diversity.NOfBlocks)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (a *Database) StoreBLSChange(change schemas.BLSChange) error {
+	defer observeWrite("t_bls_changes", time.Now())
+	_, err := a.exec(
+		context.Background(),
+		insertBLSChange,
+		change.Epoch,
+		change.Slot,
+		change.ValIndex,
+		change.PoolName,
+		change.ExecutionAddress)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (a *Database) StoreWithdrawalRequest(request schemas.WithdrawalRequest) error {
+	defer observeWrite("t_withdrawal_requests", time.Now())
+	_, err := a.exec(
+		context.Background(),
+		insertWithdrawalRequest,
+		request.Epoch,
+		request.Slot,
+		request.BlockNumber,
+		request.RequestIndex,
+		request.PoolName,
+		request.SourceAddress,
+		request.Pubkey,
+		request.Amount)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (a *Database) StoreConsolidationRequest(request schemas.ConsolidationRequest) error {
+	defer observeWrite("t_consolidation_requests", time.Now())
+	_, err := a.exec(
+		context.Background(),
+		insertConsolidationRequest,
+		request.Epoch,
+		request.Slot,
+		request.BlockNumber,
+		request.RequestIndex,
+		request.PoolName,
+		request.SourceAddress,
+		request.SourcePubkey,
+		request.TargetPubkey)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (a *Database) StorePartialWithdrawal(withdrawal schemas.PartialWithdrawal) error {
+	defer observeWrite("t_partial_withdrawals", time.Now())
+	_, err := a.exec(
+		context.Background(),
+		insertPartialWithdrawal,
+		withdrawal.Epoch,
+		withdrawal.ValIndex,
+		withdrawal.PoolName,
+		withdrawal.NOfRequests,
+		withdrawal.WithdrawableEpoch,
+		withdrawal.RequestedGwei,
+		withdrawal.WithdrawnGwei)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (a *Database) StorePendingPartialWithdrawals(pending schemas.PendingPartialWithdrawals) error {
+	defer observeWrite("t_pending_partial_withdrawals", time.Now())
+	_, err := a.exec(
+		context.Background(),
+		insertPendingPartialWithdrawals,
+		pending.Epoch,
+		pending.PoolName,
+		pending.NOfPending,
+		pending.PendingGwei,
+		pending.LastWithdrawableEpoch)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (a *Database) StoreProposal(proposal schemas.Proposal) error {
+	defer observeWrite("t_proposals", time.Now())
+	_, err := a.exec(
+		context.Background(),
+		insertProposal,
+		proposal.Epoch,
+		proposal.Slot,
+		proposal.ProposerIndex,
+		proposal.PoolName,
+		gwei(proposal.ConsensusReward),
+		Wei{proposal.ExecutionTip},
+		Wei{proposal.MEVReward},
+		strings.Join(proposal.Relays, ","),
+		proposal.BuilderPubKey,
+		proposal.FeeRecipient,
+		proposal.Vanilla,
+		gwei(proposal.AttestationsReward),
+		gwei(proposal.SyncAggregateReward),
+		gwei(proposal.SlashingsReward))
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (a *Database) StoreFeeRecipientViolation(violation schemas.FeeRecipientViolation) error {
+	defer observeWrite("t_fee_recipient_violations", time.Now())
+	_, err := a.exec(
+		context.Background(),
+		insertFeeRecipientViolation,
+		violation.Epoch,
+		violation.Slot,
+		violation.ProposerIndex,
+		violation.PoolName,
+		violation.FeeRecipient,
+		strings.Join(violation.Expected, ","),
+		violation.MEV)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (a *Database) StoreBlockBlobs(blobs schemas.BlockBlobs) error {
+	defer observeWrite("t_block_blobs", time.Now())
+	_, err := a.exec(
+		context.Background(),
+		insertBlockBlobs,
+		blobs.Epoch,
+		blobs.Slot,
+		blobs.ProposerIndex,
+		blobs.PoolName,
+		blobs.NOfBlobs,
+		blobs.BlobGasUsed,
+		blobs.MaxBlobGas,
+		blobs.ExcessBlobGas,
+		Wei{blobs.BlobBaseFee},
+		Wei{blobs.BlobFee})
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (a *Database) StoreMEVPayout(payout schemas.MEVPayout) error {
+	defer observeWrite("t_mev_payouts", time.Now())
+	_, err := a.exec(
+		context.Background(),
+		insertMEVPayout,
+		payout.Epoch,
+		payout.Slot,
+		payout.ProposerIndex,
+		payout.PoolName,
+		payout.FeeRecipient,
+		strings.Join(payout.Relays, ","),
+		payout.BuilderPubKey,
+		Wei{payout.Reported},
+		Wei{payout.Paid},
+		Wei{payout.Shortfall})
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (a *Database) StoreMissedMEV(missed schemas.MissedMEV) error {
+	defer observeWrite("t_missed_mev", time.Now())
+	_, err := a.exec(
+		context.Background(),
+		insertMissedMEV,
+		missed.Epoch,
+		missed.Slot,
+		missed.ProposerIndex,
+		missed.PoolName,
+		missed.Relay,
+		missed.BuilderPubKey,
+		Wei{missed.BestBid},
+		Wei{missed.Tip},
+		Wei{missed.Missed})
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (a *Database) StoreProposalTiming(timing schemas.ProposalTiming) error {
+	defer observeWrite("t_proposal_timings", time.Now())
+	_, err := a.exec(
+		context.Background(),
+		insertProposalTiming,
+		timing.Epoch,
+		timing.Slot,
+		timing.ProposerIndex,
+		timing.PoolName,
+		timing.SeenAt,
+		timing.DelayMs,
+		timing.Late)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (a *Database) StoreEpochBlockRoots(epoch uint64, blockRoots []string) error {
+	defer observeWrite("t_epoch_block_roots", time.Now())
+	_, err := a.exec(
+		context.Background(),
+		insertEpochBlockRoots,
+		epoch,
+		strings.Join(blockRoots, ","))
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (a *Database) StoreBackfillProgress(fromEpoch uint64, toEpoch uint64, lastEpoch uint64) error {
+	defer observeWrite("t_backfill_progress", time.Now())
+	_, err := a.exec(
+		context.Background(),
+		insertBackfillProgress,
+		fromEpoch,
+		toEpoch,
+		lastEpoch,
+		time.Now())
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Stores the changes of pool membership in a single transaction, so that a
+// request with many keys is stored all or none. A change of the same
+// validator, pool and epoch replaces the previous one.
+func (a *Database) StorePoolMembershipChanges(changes []schemas.PoolMembershipChange) error {
+	defer observeWrite("t_pool_membership", time.Now())
+	return a.inTx(func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(context.Background(), insertPoolMembershipChange)
+		if err != nil {
+			return errors.Wrap(err, "could not prepare statement")
+		}
+		defer stmt.Close()
+		for _, change := range changes {
+			if _, err := stmt.ExecContext(context.Background(),
+				change.Epoch,
+				change.PoolName,
+				change.PubKey,
+				change.Action,
+				change.Source,
+				change.Time); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Returns all the changes of pool membership, sorted by epoch. Their time is
+// only stored for auditing.
+func (a *Database) GetPoolMembershipChanges() ([]schemas.PoolMembershipChange, error) {
+	rows, err := a.conn().QueryContext(
+		context.Background(),
+		"SELECT f_epoch, f_pool, f_validator_pubkey, f_action, f_source FROM t_pool_membership ORDER BY f_epoch, f_pool, f_validator_pubkey")
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get pool membership")
+	}
+	defer rows.Close()
+
+	changes := make([]schemas.PoolMembershipChange, 0)
+	for rows.Next() {
+		var change schemas.PoolMembershipChange
+		if err := rows.Scan(&change.Epoch, &change.PoolName, &change.PubKey, &change.Action, &change.Source); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// Returns the metrics of each pool in the day or week starting at the given
+// day, e.g. 2024-01-15, from the daily or weekly view. Sums of columns that
+// were not stored are zero. Proposal duties are not rolled up per day, so they
+// are only summed over the epochs that were not pruned.
+func (a *Database) GetPoolsMetricsOfPeriod(period string, start string) ([]schemas.PoolPeriodMetrics, error) {
+	var view, column, days string
+	switch period {
+	case "daily":
+		view, column, days = "v_pools_metrics_daily", "f_day", "+1 days"
+	case "weekly":
+		view, column, days = "v_pools_metrics_weekly", "f_week", "+7 days"
+	default:
+		return nil, errors.New("unknown period: " + period)
+	}
+
+	rows, err := a.conn().QueryContext(
+		context.Background(),
+		`SELECT f_pool,
+			f_n_epochs,
+			COALESCE(f_avg_active_validators, 0),
+			COALESCE(f_n_total_votes, 0),
+			COALESCE(f_n_incorrect_source, 0),
+			COALESCE(f_n_incorrect_target, 0),
+			COALESCE(f_n_incorrect_head, 0),
+			COALESCE(f_earned_balance_gwei, 0),
+			COALESCE(f_lost_balance_gwei, 0),
+			COALESCE(f_sync_committee_rewards_gwei, 0),
+			COALESCE(f_proposer_rewards_gwei, 0),
+			COALESCE(f_mev_rewards_wei, 0),
+			COALESCE(f_proposer_tips_wei, 0),
+			COALESCE(f_earned_usd, 0) + COALESCE(f_mev_rewards_usd, 0),
+			COALESCE(d.f_n_scheduled_blocks, 0),
+			COALESCE(d.f_n_proposed_blocks, 0)
+		FROM `+view+` v
+		LEFT JOIN (
+			SELECT f_pool,
+				SUM(d.f_n_scheduled_blocks) AS f_n_scheduled_blocks,
+				SUM(d.f_n_proposed_blocks) AS f_n_proposed_blocks
+			FROM t_proposal_duties d JOIN t_pools_metrics_summary USING (f_epoch, f_pool)
+			WHERE `+epochDay+` >= ? AND `+epochDay+` < date(?, ?)
+			GROUP BY f_pool
+		) d USING (f_pool)
+		WHERE `+column+` = ? ORDER BY f_pool`,
+		start,
+		start,
+		days,
+		start)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get pools metrics of period")
+	}
+	defer rows.Close()
+
+	metrics := make([]schemas.PoolPeriodMetrics, 0)
+	for rows.Next() {
+		var m schemas.PoolPeriodMetrics
+		if err := rows.Scan(
+			&m.PoolName,
+			&m.NOfEpochs,
+			&m.AvgActiveValidators,
+			&m.NOfTotalVotes,
+			&m.NOfIncorrectSource,
+			&m.NOfIncorrectTarget,
+			&m.NOfIncorrectHead,
+			&m.EarnedBalanceGwei,
+			&m.LostBalanceGwei,
+			&m.SyncCommitteeRewardsGwei,
+			&m.ProposerRewardsGwei,
+			&m.MEVRewardsWei,
+			&m.ProposerTipsWei,
+			&m.ValueUsd,
+			&m.NOfScheduledBlocks,
+			&m.NOfProposedBlocks); err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, rows.Err()
+}
+
+// Returns the payout statement of each pool between the given epochs, both
+// included, optionally only of a pool. Amounts are summed as integers from the
+// rows of each epoch, so it fails if some epoch was pruned.
+func (a *Database) GetPoolStatements(fromEpoch uint64, toEpoch uint64, poolName string) ([]schemas.PoolStatement, error) {
+	var lastRolledUp int64
+	if err := a.conn().QueryRowContext(
+		context.Background(),
+		"SELECT COALESCE(MAX(f_last_epoch), -1) FROM t_pools_metrics_daily").Scan(&lastRolledUp); err != nil {
+		return nil, errors.Wrap(err, "could not get pruned epochs")
+	}
+	if lastRolledUp >= 0 && uint64(lastRolledUp) >= fromEpoch {
+		return nil, errors.Errorf("epochs up to %d were pruned, only their daily sums are kept", lastRolledUp)
+	}
+
+	rows, err := a.conn().QueryContext(
+		context.Background(),
+		`SELECT f_pool, f_epoch,
+			COALESCE(f_epoch_earned_balance_gwei, 0),
+			COALESCE(f_epoch_lost_balace_gwei, 0),
+			COALESCE(f_sync_committee_rewards_gwei, 0),
+			COALESCE(f_proposer_rewards_gwei, 0),
+			f_proposer_tips_wei,
+			f_mev_rewards_wei,
+			COALESCE(f_partial_withdrawals_gwei, 0),
+			COALESCE(f_full_withdrawals_gwei, 0),
+			COALESCE(f_mev_partial, false)
+		FROM t_pools_metrics_summary
+		WHERE f_epoch >= ? AND f_epoch <= ? AND (? = '' OR f_pool = ?)
+		ORDER BY f_pool, f_epoch`,
+		fromEpoch,
+		toEpoch,
+		poolName,
+		poolName)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get pool metrics")
+	}
+	defer rows.Close()
+
+	gweiToWei := big.NewInt(1e9)
+	addGwei := func(sum *big.Int, gwei int64) {
+		sum.Add(sum, new(big.Int).Mul(big.NewInt(gwei), gweiToWei))
+	}
+	statements := make([]schemas.PoolStatement, 0)
+	for rows.Next() {
+		var pool string
+		var epoch uint64
+		var earned, lost, syncCommittee, proposer, partial, full int64
+		var tips, mev Wei
+		var mevPartial bool
+		if err := rows.Scan(&pool, &epoch, &earned, &lost, &syncCommittee, &proposer, &tips, &mev, &partial, &full, &mevPartial); err != nil {
+			return nil, err
+		}
+
+		if len(statements) == 0 || statements[len(statements)-1].PoolName != pool {
+			statements = append(statements, schemas.PoolStatement{
+				PoolName:           pool,
+				FromEpoch:          epoch,
+				ConsensusRewards:   big.NewInt(0),
+				Penalties:          big.NewInt(0),
+				ProposerTips:       big.NewInt(0),
+				MEVRewards:         big.NewInt(0),
+				PartialWithdrawals: big.NewInt(0),
+				FullWithdrawals:    big.NewInt(0),
+			})
+		}
+		s := &statements[len(statements)-1]
+		s.ToEpoch = epoch
+		s.NOfEpochs++
+		addGwei(s.ConsensusRewards, earned+syncCommittee+proposer)
+		// Stored as a negative delta
+		addGwei(s.Penalties, -lost)
+		if tips.Int != nil {
+			s.ProposerTips.Add(s.ProposerTips, tips.Int)
+		}
+		if mev.Int != nil {
+			s.MEVRewards.Add(s.MEVRewards, mev.Int)
+		}
+		addGwei(s.PartialWithdrawals, partial)
+		addGwei(s.FullWithdrawals, full)
+		s.MEVPartial = s.MEVPartial || mevPartial
+	}
+	return statements, rows.Err()
 }
 
-func New(dbPath string) (*Database, error) {
-	db, err := sql.Open("sqlite", dbPath)
+// Returns the metrics of the last epochs stored of a pool, or of every pool if
+// empty, from the newest. Epochs without proposal duties have no blocks.
+func (a *Database) GetRecentPoolMetrics(poolName string, epochs uint64) ([]schemas.PoolEpochSummary, error) {
+	rows, err := a.conn().QueryContext(
+		context.Background(),
+		`SELECT f_pool, f_epoch,
+			COALESCE(f_n_validating_keys, 0),
+			COALESCE(f_n_incorrect_source, 0),
+			COALESCE(f_n_incorrect_target, 0),
+			COALESCE(f_n_incorrect_head, 0),
+			COALESCE(f_epoch_earned_balance_gwei, 0) + COALESCE(f_epoch_lost_balace_gwei, 0) +
+				COALESCE(f_sync_committee_rewards_gwei, 0) + COALESCE(f_proposer_rewards_gwei, 0),
+			COALESCE(d.f_n_scheduled_blocks, 0),
+			COALESCE(d.f_n_proposed_blocks, 0)
+		FROM t_pools_metrics_summary s
+		LEFT JOIN t_proposal_duties d USING (f_epoch, f_pool)
+		WHERE (? = '' OR f_pool = ?) AND f_epoch + ? > (
+			SELECT MAX(f_epoch) FROM t_pools_metrics_summary WHERE ? = '' OR f_pool = ?)
+		ORDER BY f_epoch DESC, f_pool`,
+		poolName,
+		poolName,
+		epochs,
+		poolName,
+		poolName)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrap(err, "could not get recent pool metrics")
 	}
+	defer rows.Close()
 
-	return &Database{
-		db: db,
-	}, nil
+	metrics := make([]schemas.PoolEpochSummary, 0)
+	for rows.Next() {
+		var m schemas.PoolEpochSummary
+		if err := rows.Scan(
+			&m.PoolName,
+			&m.Epoch,
+			&m.NOfValidatingKeys,
+			&m.NOfIncorrectSource,
+			&m.NOfIncorrectTarget,
+			&m.NOfIncorrectHead,
+			&m.RewardsGwei,
+			&m.NOfScheduledBlocks,
+			&m.NOfProposedBlocks); err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, rows.Err()
 }
 
-func (a *Database) CreateTables() error {
-	if _, err := a.db.ExecContext(
+func (a *Database) StoreAlert(alert schemas.SentAlert) error {
+	defer observeWrite("t_alerts", time.Now())
+	_, err := a.exec(
 		context.Background(),
-		createPoolsMetricsTable); err != nil {
-		return err
+		insertAlert,
+		alert.Time,
+		alert.Epoch,
+		alert.PoolName,
+		alert.Kind,
+		alert.Severity,
+		alert.Message)
+	return err
+}
+
+// Returns the last alerts that were fired, from the newest. The time is not
+// read, since the epoch tells it.
+func (a *Database) GetRecentAlerts(limit uint64) ([]schemas.SentAlert, error) {
+	rows, err := a.conn().QueryContext(
+		context.Background(),
+		`SELECT f_epoch, f_pool, f_kind, f_severity, f_message FROM t_alerts
+		ORDER BY f_epoch DESC, rowid DESC LIMIT ?`,
+		limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get recent alerts")
+	}
+	defer rows.Close()
+
+	alerts := make([]schemas.SentAlert, 0)
+	for rows.Next() {
+		var alert schemas.SentAlert
+		if err := rows.Scan(&alert.Epoch, &alert.PoolName, &alert.Kind, &alert.Severity, &alert.Message); err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
 	}
+	return alerts, rows.Err()
+}
 
-	if _, err := a.db.ExecContext(
+func (a *Database) StoreMaintenanceWindow(window schemas.MaintenanceWindow) error {
+	defer observeWrite("t_maintenance_windows", time.Now())
+	_, err := a.exec(
 		context.Background(),
-		createProposalDutiesTable); err != nil {
-		return err
+		"INSERT INTO t_maintenance_windows (f_id, f_pool, f_start, f_end, f_comment) VALUES (?, ?, ?, ?, ?)",
+		window.Id,
+		window.PoolName,
+		window.Start,
+		window.End,
+		window.Comment)
+	return err
+}
+
+// Returns if the window existed
+func (a *Database) DeleteMaintenanceWindow(id uint64) (bool, error) {
+	defer observeWrite("t_maintenance_windows", time.Now())
+	result, err := a.exec(
+		context.Background(),
+		"DELETE FROM t_maintenance_windows WHERE f_id = ?",
+		id)
+	if err != nil {
+		return false, err
 	}
+	deleted, err := result.RowsAffected()
+	return deleted > 0, err
+}
 
-	if _, err := a.db.ExecContext(
+// Returns the stored maintenance windows, sorted by id
+func (a *Database) GetMaintenanceWindows() ([]schemas.MaintenanceWindow, error) {
+	rows, err := a.conn().QueryContext(
 		context.Background(),
-		createNetworkStatsTable); err != nil {
-		return err
+		"SELECT f_id, f_pool, f_start, f_end, f_comment FROM t_maintenance_windows ORDER BY f_id")
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get maintenance windows")
+	}
+	defer rows.Close()
+
+	windows := make([]schemas.MaintenanceWindow, 0)
+	for rows.Next() {
+		var window schemas.MaintenanceWindow
+		if err := rows.Scan(&window.Id, &window.PoolName, &window.Start, &window.End, &window.Comment); err != nil {
+			return nil, err
+		}
+		windows = append(windows, window)
 	}
+	return windows, rows.Err()
+}
 
+// Tags the missed duties and the effectiveness of the pool in the epoch as
+// done in a maintenance window, or untags them, e.g. if the window was removed
+// before the epoch was processed again
+func (a *Database) TagMaintenance(epoch uint64, poolName string, maintenance bool) error {
+	for _, table := range []string{"t_missed_attestations", "t_proposal_duties", "t_effectiveness"} {
+		start := time.Now()
+		_, err := a.exec(
+			context.Background(),
+			"UPDATE "+table+" SET f_maintenance = ? WHERE f_epoch = ? AND f_pool = ?",
+			maintenance,
+			epoch,
+			poolName)
+		observeWrite(table, start)
+		if err != nil {
+			return errors.Wrap(err, "could not tag maintenance in "+table)
+		}
+	}
 	return nil
 }
 
-func (a *Database) CreateEthPriceTable() error {
-	if _, err := a.db.ExecContext(
+// Returns the attestation and proposal duties of each pool in the epochs,
+// both included, and how many were missed. Epochs in maintenance are only
+// counted.
+func (a *Database) GetPoolSLASums(firstEpoch uint64, lastEpoch uint64) ([]schemas.PoolSLA, error) {
+	rows, err := a.conn().QueryContext(
 		context.Background(),
-		createEthPriceTable); err != nil {
-		return err
+		`SELECT s.f_pool,
+			COUNT(*),
+			SUM(CASE WHEN m.f_maintenance THEN 1 ELSE 0 END),
+			SUM(CASE WHEN m.f_maintenance THEN 0 ELSE COALESCE(s.f_n_validating_keys, 0) END),
+			SUM(CASE WHEN m.f_maintenance THEN 0 ELSE COALESCE(s.f_n_incorrect_source, 0) END),
+			SUM(CASE WHEN m.f_maintenance THEN 0 ELSE COALESCE(d.f_n_scheduled_blocks, 0) END),
+			SUM(CASE WHEN m.f_maintenance THEN 0 ELSE COALESCE(d.f_n_proposed_blocks, 0) END)
+		FROM t_pools_metrics_summary s
+		LEFT JOIN t_proposal_duties d ON d.f_epoch = s.f_epoch AND d.f_pool = s.f_pool
+		LEFT JOIN (
+			SELECT f_epoch, f_pool, COALESCE(f_maintenance, FALSE) AS f_maintenance FROM t_effectiveness
+		) m ON m.f_epoch = s.f_epoch AND m.f_pool = s.f_pool
+		WHERE s.f_epoch BETWEEN ? AND ?
+		GROUP BY s.f_pool
+		ORDER BY s.f_pool`,
+		firstEpoch,
+		lastEpoch)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get pool sla sums")
 	}
-	return nil
+	defer rows.Close()
+
+	slas := make([]schemas.PoolSLA, 0)
+	for rows.Next() {
+		var sla schemas.PoolSLA
+		if err := rows.Scan(
+			&sla.PoolName,
+			&sla.NOfEpochs,
+			&sla.NOfMaintenanceEpochs,
+			&sla.NOfAttestationDuties,
+			&sla.NOfMissedAttestations,
+			&sla.NOfScheduledBlocks,
+			&sla.NOfProposedBlocks); err != nil {
+			return nil, err
+		}
+		slas = append(slas, sla)
+	}
+	return slas, rows.Err()
 }
 
-func (a *Database) StoreProposalDuties(epoch uint64, poolName string, scheduledBlocks uint64, proposedBlocks uint64) error {
-	_, err := a.db.ExecContext(
+func (a *Database) StorePoolSLA(sla schemas.PoolSLA) error {
+	defer observeWrite("t_pool_sla", time.Now())
+	_, err := a.exec(
 		context.Background(),
-		insertProposalDuties,
-		epoch,
-		poolName,
-		scheduledBlocks,
-		proposedBlocks)
+		insertPoolSLA,
+		sla.Period,
+		sla.Start,
+		sla.PoolName,
+		sla.NOfEpochs,
+		sla.NOfMaintenanceEpochs,
+		sla.NOfAttestationDuties,
+		sla.NOfMissedAttestations,
+		sla.NOfScheduledBlocks,
+		sla.NOfProposedBlocks,
+		sla.AttestationUptime,
+		sla.ProposalSuccess,
+		sla.AttestationTarget,
+		sla.ProposalTarget,
+		sla.AttestationBreached,
+		sla.ProposalBreached,
+		time.Now())
+	return err
+}
 
+// Returns the SLAs of the pools in the periods that start between the given
+// days, both included, sorted by start and pool
+func (a *Database) GetPoolSLAs(period string, firstStart string, lastStart string) ([]schemas.PoolSLA, error) {
+	rows, err := a.conn().QueryContext(
+		context.Background(),
+		`SELECT f_period, f_start, f_pool,
+			f_n_epochs,
+			f_n_maintenance_epochs,
+			f_n_attestation_duties,
+			f_n_missed_attestations,
+			f_n_scheduled_blocks,
+			f_n_proposed_blocks,
+			f_attestation_uptime,
+			f_proposal_success,
+			f_attestation_target,
+			f_proposal_target,
+			f_attestation_breached,
+			f_proposal_breached
+		FROM t_pool_sla
+		WHERE f_period = ? AND f_start BETWEEN ? AND ?
+		ORDER BY f_start, f_pool`,
+		period,
+		firstStart,
+		lastStart)
 	if err != nil {
-		return err
+		return nil, errors.Wrap(err, "could not get pool slas")
 	}
-	return nil
+	defer rows.Close()
+
+	slas := make([]schemas.PoolSLA, 0)
+	for rows.Next() {
+		var sla schemas.PoolSLA
+		if err := rows.Scan(
+			&sla.Period,
+			&sla.Start,
+			&sla.PoolName,
+			&sla.NOfEpochs,
+			&sla.NOfMaintenanceEpochs,
+			&sla.NOfAttestationDuties,
+			&sla.NOfMissedAttestations,
+			&sla.NOfScheduledBlocks,
+			&sla.NOfProposedBlocks,
+			&sla.AttestationUptime,
+			&sla.ProposalSuccess,
+			&sla.AttestationTarget,
+			&sla.ProposalTarget,
+			&sla.AttestationBreached,
+			&sla.ProposalBreached); err != nil {
+			return nil, err
+		}
+		slas = append(slas, sla)
+	}
+	return slas, rows.Err()
 }
 
-func (a *Database) StoreValidatorPerformance(validatorPerformance schemas.ValidatorPerformanceMetrics) error {
-	_, err := a.db.ExecContext(
+// Replaces the issues of the last check of the tracked keys
+func (a *Database) StoreKeyIssues(issues []schemas.KeyIssue) error {
+	defer observeWrite("t_key_issues", time.Now())
+	return a.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(context.Background(), "DELETE FROM t_key_issues"); err != nil {
+			return err
+		}
+		stmt, err := tx.PrepareContext(
+			context.Background(),
+			"INSERT INTO t_key_issues (f_epoch, f_pool, f_validator_pubkey, f_issue, f_validator_index) VALUES (?, ?, ?, ?, ?)")
+		if err != nil {
+			return errors.Wrap(err, "could not prepare statement")
+		}
+		defer stmt.Close()
+		for _, issue := range issues {
+			if _, err := stmt.ExecContext(context.Background(),
+				issue.Epoch,
+				issue.PoolName,
+				issue.PubKey,
+				issue.Kind,
+				issue.ValIndex); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Returns the issues of the last check of the tracked keys, sorted by pool
+// and key
+func (a *Database) GetKeyIssues() ([]schemas.KeyIssue, error) {
+	rows, err := a.conn().QueryContext(
 		context.Background(),
-		insertValidatorPerformance,
-		validatorPerformance.Time,
-		validatorPerformance.Epoch,
-		validatorPerformance.PoolName,
-		validatorPerformance.Time,
-		validatorPerformance.NOfActiveValidators,
-		validatorPerformance.NOfTotalVotes,
-		validatorPerformance.NOfIncorrectSource,
-		validatorPerformance.NOfIncorrectTarget,
-		validatorPerformance.NOfIncorrectHead,
-		validatorPerformance.NOfValidatingKeys,
-		validatorPerformance.NOfValsWithLessBalance,
-		func(indexes []uint64) string {
-			strs := make([]string, len(indexes))
-			for i, v := range indexes {
-				strs[i] = strconv.FormatUint(v, 10)
+		"SELECT f_epoch, f_pool, f_validator_pubkey, f_issue, f_validator_index FROM t_key_issues ORDER BY f_pool, f_validator_pubkey")
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get key issues")
+	}
+	defer rows.Close()
+
+	issues := make([]schemas.KeyIssue, 0)
+	for rows.Next() {
+		var issue schemas.KeyIssue
+		if err := rows.Scan(&issue.Epoch, &issue.PoolName, &issue.PubKey, &issue.Kind, &issue.ValIndex); err != nil {
+			return nil, err
+		}
+		issues = append(issues, issue)
+	}
+	return issues, rows.Err()
+}
+
+// Replaces the tenant of each pool with the ones of the config
+func (a *Database) StoreTenantPools(poolTenants map[string]string) error {
+	defer observeWrite("t_tenant_pools", time.Now())
+	return a.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(context.Background(), "DELETE FROM t_tenant_pools"); err != nil {
+			return err
+		}
+		stmt, err := tx.PrepareContext(
+			context.Background(),
+			"INSERT INTO t_tenant_pools (f_tenant, f_pool) VALUES (?, ?)")
+		if err != nil {
+			return errors.Wrap(err, "could not prepare statement")
+		}
+		defer stmt.Close()
+		for poolName, tenant := range poolTenants {
+			if _, err := stmt.ExecContext(context.Background(), tenant, poolName); err != nil {
+				return err
 			}
-			return strings.Join(strs, ",")
-		}(validatorPerformance.IndexesLessBalance),
-		validatorPerformance.EffectiveBalance.Int64(),
-		validatorPerformance.EarnedBalance.Int64(),
-		validatorPerformance.LosedBalance.Int64(),
-		validatorPerformance.MEVRewards.Int64(),
-		validatorPerformance.ProposerTips.Int64(),
-	)
+		}
+		return nil
+	})
+}
+
+// Returns the tenant of each pool
+func (a *Database) GetTenantPools() (map[string]string, error) {
+	rows, err := a.conn().QueryContext(
+		context.Background(),
+		"SELECT f_tenant, f_pool FROM t_tenant_pools")
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get tenant pools")
+	}
+	defer rows.Close()
+
+	poolTenants := make(map[string]string)
+	for rows.Next() {
+		var tenant, poolName string
+		if err := rows.Scan(&tenant, &poolName); err != nil {
+			return nil, err
+		}
+		poolTenants[poolName] = tenant
+	}
+	return poolTenants, rows.Err()
+}
+
+// Returns the UTC day of the last epoch with pool metrics, e.g. 2024-01-15
+func (a *Database) GetLastProcessedDay() (string, bool, error) {
+	var day sql.NullString
+	err := a.conn().QueryRowContext(
+		context.Background(),
+		"SELECT "+epochDay+" FROM t_pools_metrics_summary ORDER BY f_epoch DESC LIMIT 1").Scan(&day)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, errors.Wrap(err, "could not get last processed day")
+	}
+	return day.String, day.Valid, nil
+}
+
+func (a *Database) IsReportSent(period string, start string) (bool, error) {
+	var count int
+	err := a.conn().QueryRowContext(
+		context.Background(),
+		"SELECT COUNT(*) FROM t_reports_sent WHERE f_period = ? AND f_start = ?",
+		period,
+		start).Scan(&count)
+	if err != nil {
+		return false, errors.Wrap(err, "could not get sent reports")
+	}
+	return count > 0, nil
+}
+
+func (a *Database) StoreReportSent(period string, start string) error {
+	defer observeWrite("t_reports_sent", time.Now())
+	_, err := a.exec(
+		context.Background(),
+		insertReportSent,
+		period,
+		start,
+		time.Now())
+	return err
+}
+
+// Returns the last epoch processed by a previous backfill of the same range
+func (a *Database) GetBackfillProgress(fromEpoch uint64, toEpoch uint64) (uint64, bool, error) {
+	var lastEpoch uint64
+	err := a.conn().QueryRowContext(
+		context.Background(),
+		"SELECT f_last_epoch FROM t_backfill_progress WHERE f_from_epoch = ? AND f_to_epoch = ?",
+		fromEpoch,
+		toEpoch).Scan(&lastEpoch)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return lastEpoch, true, nil
+}
+
+func (a *Database) StoreValidatorStatus(validatorStatus schemas.ValidatorStatus) error {
+	defer observeWrite("t_validator_status", time.Now())
+	_, err := a.exec(
+		context.Background(),
+		insertValidatorStatus,
+		validatorStatus.Epoch,
+		validatorStatus.PoolName,
+		validatorStatus.NOfPending,
+		validatorStatus.NOfActive,
+		validatorStatus.NOfExiting,
+		validatorStatus.NOfExited,
+		validatorStatus.NOfWithdrawable,
+		validatorStatus.NOfSlashed,
+		validatorStatus.ActivationWaitEpochs,
+		validatorStatus.ExitWaitEpochs)
 
 	if err != nil {
 		return err
@@ -248,11 +3754,33 @@ func (a *Database) StoreValidatorPerformance(validatorPerformance schemas.Valida
 	return nil
 }
 
+// Returns the block roots stored for the epoch and whether the epoch was found
+func (a *Database) GetEpochBlockRoots(epoch uint64) ([]string, bool, error) {
+	var blockRoots string
+	err := a.conn().QueryRowContext(
+		context.Background(),
+		"SELECT f_block_roots FROM t_epoch_block_roots WHERE f_epoch = ?",
+		epoch).Scan(&blockRoots)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if blockRoots == "" {
+		return []string{}, true, nil
+	}
+	return strings.Split(blockRoots, ","), true, nil
+}
+
+// Stored in UTC and in seconds, like the epoch timestamps, so that the
+// samples sort by time
 func (a *Database) StoreEthPrice(ethPriceUsd float32) error {
-	_, err := a.db.ExecContext(
+	defer observeWrite("t_eth_price", time.Now())
+	_, err := a.exec(
 		context.Background(),
 		insertEthPrice,
-		time.Now(), // not really correct
+		time.Now().UTC().Truncate(time.Second),
 		ethPriceUsd)
 
 	if err != nil {
@@ -261,8 +3789,27 @@ func (a *Database) StoreEthPrice(ethPriceUsd float32) error {
 	return nil
 }
 
+// Returns the last price sampled at or before the given time, if it is not
+// older than maxAge
+func (a *Database) GetEthPriceAt(t time.Time, maxAge time.Duration) (float32, bool, error) {
+	var ethPriceUsd float32
+	err := a.conn().QueryRowContext(
+		context.Background(),
+		"SELECT f_eth_price_usd FROM t_eth_price WHERE f_timestamp <= ? AND f_timestamp >= ? ORDER BY f_timestamp DESC LIMIT 1",
+		t.UTC().Truncate(time.Second),
+		t.Add(-maxAge).UTC().Truncate(time.Second)).Scan(&ethPriceUsd)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, errors.Wrap(err, "could not get eth price")
+	}
+	return ethPriceUsd, true, nil
+}
+
 func (a *Database) StoreNetworkMetrics(networkMetrics schemas.NetworkStats) error {
-	_, err := a.db.ExecContext(
+	defer observeWrite("t_network_stats", time.Now())
+	_, err := a.exec(
 		context.Background(),
 		insertNetworkStats,
 		networkMetrics.Time,
@@ -270,6 +3817,17 @@ func (a *Database) StoreNetworkMetrics(networkMetrics schemas.NetworkStats) erro
 		networkMetrics.NOfActiveValidators,
 		networkMetrics.NOfExitedValidators,
 		networkMetrics.NOfSlashedValidators,
+		networkMetrics.SourceParticipation,
+		networkMetrics.TargetParticipation,
+		networkMetrics.HeadParticipation,
+		networkMetrics.AttestationEffectiveness,
+		networkMetrics.NOfScheduledBlocks,
+		networkMetrics.NOfProposedBlocks,
+		networkMetrics.ProposalRate,
+		networkMetrics.NOfBlobs,
+		networkMetrics.BlobGasUsed,
+		networkMetrics.BlobUtilization,
+		Wei{networkMetrics.BlobBaseFee},
 	)
 
 	if err != nil {
@@ -278,42 +3836,304 @@ func (a *Database) StoreNetworkMetrics(networkMetrics schemas.NetworkStats) erro
 	return nil
 }
 
-func (a *Database) GetMissingEpochs(currentEpoch uint64, backfillEpochs uint64) ([]uint64, error) {
-	// Generate the expected range of epochs
-	expectedEpochs := make(map[uint64]bool)
-	for epoch := currentEpoch - backfillEpochs + 1; epoch <= currentEpoch; epoch++ {
-		expectedEpochs[epoch] = true
-	}
+// Replaces the modules completed for the pool in the epoch, so that processing
+// it again drops the modules that failed this time
+func (a *Database) StoreEpochProcessed(epoch uint64, poolName string, modules []string) error {
+	defer observeWrite("t_epochs_processed", time.Now())
+	return a.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(
+			context.Background(),
+			"DELETE FROM t_epochs_processed WHERE f_epoch = ? AND f_pool = ?",
+			epoch, poolName); err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, module := range modules {
+			if _, err := tx.ExecContext(
+				context.Background(),
+				"INSERT INTO t_epochs_processed (f_epoch, f_pool, f_module, f_timestamp) VALUES (?, ?, ?, ?)",
+				epoch, poolName, module, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
 
-	// Query existing epochs in the range
-	query := `
-		SELECT f_epoch
-		FROM t_pools_metrics_summary
-		WHERE f_epoch BETWEEN ? AND ?
-	`
+// Returns the epochs of the last backfillEpochs up to currentEpoch where some
+// of the pools have no complete summary, with the pools that lack it
+func (a *Database) GetMissingEpochs(currentEpoch uint64, backfillEpochs uint64, pools []string) (map[uint64][]string, error) {
+	missingEpochs := make(map[uint64][]string)
+	if backfillEpochs == 0 || len(pools) == 0 {
+		return missingEpochs, nil
+	}
+	fromEpoch := uint64(0)
+	if currentEpoch+1 > backfillEpochs {
+		fromEpoch = currentEpoch - backfillEpochs + 1
+	}
 
-	rows, err := a.db.QueryContext(context.Background(), query, currentEpoch-backfillEpochs+1, currentEpoch)
+	rows, err := a.conn().QueryContext(
+		context.Background(),
+		"SELECT f_epoch, f_pool FROM t_epochs_processed WHERE f_module = ? AND f_epoch BETWEEN ? AND ?",
+		ModuleSummary, fromEpoch, currentEpoch)
 	if err != nil {
-		return nil, errors.Wrap(err, "could not get existing epochs")
+		return nil, errors.Wrap(err, "could not get processed epochs")
 	}
-
 	defer rows.Close()
+
+	processed := make(map[uint64]map[string]bool)
 	for rows.Next() {
 		var epoch uint64
-		if err := rows.Scan(&epoch); err != nil {
+		var poolName string
+		if err := rows.Scan(&epoch, &poolName); err != nil {
 			return nil, err
 		}
-		delete(expectedEpochs, epoch)
+		if processed[epoch] == nil {
+			processed[epoch] = make(map[string]bool)
+		}
+		processed[epoch][poolName] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	// Collect missing epochs
-	missingEpochs := make([]uint64, 0, len(expectedEpochs))
-	for epoch := range expectedEpochs {
-		missingEpochs = append(missingEpochs, epoch)
+	sortedPools := slices.Clone(pools)
+	slices.Sort(sortedPools)
+	for epoch := fromEpoch; epoch <= currentEpoch; epoch++ {
+		for _, poolName := range sortedPools {
+			if !processed[epoch][poolName] {
+				missingEpochs[epoch] = append(missingEpochs[epoch], poolName)
+			}
+		}
 	}
+	return missingEpochs, nil
+}
 
-	// Sort the missing epochs in descending order
-	sort.Slice(missingEpochs, func(i, j int) bool { return missingEpochs[i] < missingEpochs[j] })
+func (a *Database) StoreNetworkQueues(networkQueues schemas.NetworkQueues) error {
+	defer observeWrite("t_network_queues", time.Now())
+	_, err := a.exec(
+		context.Background(),
+		insertNetworkQueues,
+		networkQueues.Time,
+		networkQueues.Epoch,
+		networkQueues.ChurnLimitGwei,
+		networkQueues.NOfPendingDeposits,
+		networkQueues.PendingDepositsGwei,
+		networkQueues.NOfExitingValidators,
+		networkQueues.ExitingBalanceGwei,
+		networkQueues.EntryWaitEpochs,
+		networkQueues.ExitWaitEpochs,
+	)
 
-	return missingEpochs, nil
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Stores the effectiveness of a pool, or of the network if the pool is empty
+func (a *Database) StoreEffectiveness(effectiveness schemas.Effectiveness) error {
+	if effectiveness.PoolName == "" {
+		defer observeWrite("t_network_effectiveness", time.Now())
+		_, err := a.exec(
+			context.Background(),
+			insertNetworkEffectiveness,
+			effectiveness.Time,
+			effectiveness.Epoch,
+			effectiveness.Attestation,
+			effectiveness.Inclusion,
+			effectiveness.Proposal,
+			effectiveness.SyncCommittee,
+			effectiveness.Score)
+		return err
+	}
+
+	defer observeWrite("t_effectiveness", time.Now())
+	_, err := a.exec(
+		context.Background(),
+		insertEffectiveness,
+		effectiveness.Time,
+		effectiveness.Epoch,
+		effectiveness.PoolName,
+		effectiveness.Attestation,
+		effectiveness.Inclusion,
+		effectiveness.Proposal,
+		effectiveness.SyncCommittee,
+		effectiveness.Score,
+		effectiveness.SourceParticipationDelta,
+		effectiveness.TargetParticipationDelta,
+		effectiveness.HeadParticipationDelta,
+		effectiveness.AttestationDelta,
+		effectiveness.ProposalDelta,
+		effectiveness.ScoreDelta)
+	return err
+}
+
+func (a *Database) StoreRelayStats(relayStats schemas.RelayStats) error {
+	defer observeWrite("t_relay_stats", time.Now())
+	_, err := a.exec(
+		context.Background(),
+		insertRelayStats,
+		relayStats.Epoch,
+		relayStats.Relay,
+		relayStats.NOfRequests,
+		relayStats.NOfErrors,
+		relayStats.NOfPayloads,
+		Wei{relayStats.ValueWei},
+		relayStats.AvgLatencyMs,
+		relayStats.Skipped,
+	)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (a *Database) StoreRelayBidTraces(relay string, slot uint64, payloads []byte) error {
+	defer observeWrite("t_relay_bidtraces", time.Now())
+	_, err := a.exec(
+		context.Background(),
+		insertRelayBidTraces,
+		relay,
+		slot,
+		string(payloads))
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (a *Database) GetRelayBidTraces(relay string, slot uint64) ([]byte, bool, error) {
+	var payloads string
+	err := a.conn().QueryRowContext(
+		context.Background(),
+		"SELECT f_payloads FROM t_relay_bidtraces WHERE f_relay = ? AND f_slot = ?",
+		relay,
+		slot).Scan(&payloads)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return []byte(payloads), true, nil
+}
+
+// Tables with rows per epoch that can be exported or inspected, and whether
+// they have a pool column
+var EpochTables = map[string]bool{
+	"t_pools_metrics_summary":       true,
+	"t_proposal_duties":             true,
+	"t_validator_metrics":           true,
+	"t_slashings":                   true,
+	"t_voluntary_exits":             true,
+	"t_bls_changes":                 true,
+	"t_deposits":                    true,
+	"t_withdrawal_requests":         true,
+	"t_consolidation_requests":      true,
+	"t_partial_withdrawals":         true,
+	"t_pending_partial_withdrawals": true,
+	"t_client_diversity":            true,
+	"t_missed_attestations":         true,
+	"t_processing_status":           true,
+	"t_epochs_processed":            true,
+	"t_proposals":                   true,
+	"t_validator_status":            true,
+	"t_fee_recipient_violations":    true,
+	"t_block_blobs":                 true,
+	"t_mev_payouts":                 true,
+	"t_missed_mev":                  true,
+	"t_proposal_timings":            true,
+	"t_epoch_block_roots":           false,
+	"t_network_stats":               false,
+	"t_network_queues":              false,
+	"t_relay_stats":                 false,
+	"t_effectiveness":               true,
+	"t_network_effectiveness":       false,
+	"t_alerts":                      true,
+}
+
+// Returns the columns and rows of the table between the given epochs, both
+// included. If the pool is set, only its rows are returned.
+func (a *Database) GetEpochRows(
+	table string,
+	fromEpoch uint64,
+	toEpoch uint64,
+	pool string) ([]string, [][]interface{}, error) {
+
+	hasPool, ok := EpochTables[table]
+	if !ok {
+		return nil, nil, errors.New("table not supported: " + table)
+	}
+	if pool != "" && !hasPool {
+		return nil, nil, errors.New("table has no pools: " + table)
+	}
+
+	// The table is not user input, since it is one of the known ones
+	query := "SELECT * FROM " + table + " WHERE f_epoch BETWEEN ? AND ?"
+	args := []interface{}{fromEpoch, toEpoch}
+	if pool != "" {
+		query += " AND f_pool = ?"
+		args = append(args, pool)
+	}
+	query += " ORDER BY f_epoch"
+
+	rows, err := a.conn().QueryContext(context.Background(), query, args...)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not get rows of "+table)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := make([][]interface{}, 0)
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, nil, err
+		}
+		for i, value := range values {
+			if b, ok := value.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		result = append(result, values)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	return columns, result, nil
+}
+
+// Deletes the per epoch rows before the given epoch, and the cached relay
+// responses before the given slot. The pool metrics are rolled up per day first.
+func (a *Database) PruneEpochs(beforeEpoch uint64, beforeSlot uint64) error {
+	tx, err := a.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return errors.Wrap(err, "could not begin transaction")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(context.Background(), rollupPoolsMetricsDaily, beforeEpoch); err != nil {
+		return errors.Wrap(err, "could not roll up pool metrics")
+	}
+	for _, table := range prunedEpochTables {
+		if _, err := tx.ExecContext(context.Background(), "DELETE FROM "+table+" WHERE f_epoch < ?", beforeEpoch); err != nil {
+			return errors.Wrap(err, "could not prune "+table)
+		}
+	}
+	if _, err := tx.ExecContext(context.Background(), "DELETE FROM t_relay_bidtraces WHERE f_slot < ?", beforeSlot); err != nil {
+		return errors.Wrap(err, "could not prune t_relay_bidtraces")
+	}
+
+	return tx.Commit()
 }
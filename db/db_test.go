@@ -1,7 +1,11 @@
 package db
 
 import (
+	"database/sql"
+	"errors"
 	"math/big"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,35 +19,1543 @@ func Test_GetMissingEpochs(t *testing.T) {
 
 	db.CreateTables()
 
-	db.StoreValidatorPerformance(schemas.ValidatorPerformanceMetrics{
+	pools := []string{"pool1", "pool2"}
+	epochs, err := db.GetMissingEpochs(200, 4, pools)
+	require.NoError(t, err)
+	require.Equal(t, map[uint64][]string{
+		197: pools, 198: pools, 199: pools, 200: pools,
+	}, epochs)
+
+	// Only the summary module marks the pool as processed
+	require.NoError(t, db.StoreEpochProcessed(197, "pool1", []string{ModuleSummary, "beacon-state"}))
+	require.NoError(t, db.StoreEpochProcessed(197, "pool2", []string{ModuleSummary}))
+	require.NoError(t, db.StoreEpochProcessed(198, "pool1", []string{ModuleSummary}))
+	require.NoError(t, db.StoreEpochProcessed(199, "pool1", []string{"beacon-state"}))
+
+	epochs, err = db.GetMissingEpochs(200, 4, pools)
+	require.NoError(t, err)
+	require.Equal(t, map[uint64][]string{
+		198: {"pool2"}, 199: pools, 200: pools,
+	}, epochs)
+
+	// Processing again replaces the modules
+	require.NoError(t, db.StoreEpochProcessed(198, "pool1", []string{"beacon-state"}))
+	epochs, err = db.GetMissingEpochs(198, 1, pools)
+	require.NoError(t, err)
+	require.Equal(t, map[uint64][]string{198: pools}, epochs)
+
+	epochs, err = db.GetMissingEpochs(200, 0, pools)
+	require.NoError(t, err)
+	require.Empty(t, epochs)
+
+	// Doesn't go below epoch 0
+	epochs, err = db.GetMissingEpochs(1, 4, []string{"pool1"})
+	require.NoError(t, err)
+	require.Equal(t, map[uint64][]string{0: {"pool1"}, 1: {"pool1"}}, epochs)
+}
+
+func Test_SeedEpochsProcessed(t *testing.T) {
+	db, err := New(filepath.Join(t.TempDir(), "db.db"))
+	require.NoError(t, err)
+	require.NoError(t, db.CreateTables())
+
+	// Epochs stored before the table existed are complete
+	_, err = db.db.Exec("DROP TABLE t_epochs_processed")
+	require.NoError(t, err)
+	require.NoError(t, db.StoreValidatorPerformance(schemas.ValidatorPerformanceMetrics{
 		Time:             time.Now(),
 		Epoch:            100,
+		PoolName:         "pool1",
 		EarnedBalance:    big.NewInt(100),
 		LosedBalance:     big.NewInt(100),
 		EffectiveBalance: big.NewInt(100),
-		MEVRewards:       big.NewInt(100),
-		ProposerTips:     big.NewInt(100),
-	})
+	}))
+	require.NoError(t, db.CreateTables())
 
-	epochs, err := db.GetMissingEpochs(200, 4)
+	epochs, err := db.GetMissingEpochs(100, 1, []string{"pool1", "pool2"})
 	require.NoError(t, err)
-	require.Equal(t, []uint64{197, 198, 199, 200}, epochs)
+	require.Equal(t, map[uint64][]string{100: {"pool2"}}, epochs)
 
-	db.StoreValidatorPerformance(schemas.ValidatorPerformanceMetrics{
+	// Only when the table is created
+	require.NoError(t, db.StoreEpochProcessed(101, "pool1", []string{ModuleSummary}))
+	require.NoError(t, db.StoreValidatorPerformance(schemas.ValidatorPerformanceMetrics{
 		Time:             time.Now(),
-		Epoch:            197,
+		Epoch:            102,
+		PoolName:         "pool1",
 		EarnedBalance:    big.NewInt(100),
 		LosedBalance:     big.NewInt(100),
 		EffectiveBalance: big.NewInt(100),
-		MEVRewards:       big.NewInt(100),
-		ProposerTips:     big.NewInt(100),
-	})
+	}))
+	require.NoError(t, db.CreateTables())
+	epochs, err = db.GetMissingEpochs(102, 1, []string{"pool1"})
+	require.NoError(t, err)
+	require.Equal(t, map[uint64][]string{102: {"pool1"}}, epochs)
+}
+
+func Test_StoreValidatorMetrics(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+
+	err = db.CreateTables()
+	require.NoError(t, err)
+
+	validatorMetrics := []schemas.ValidatorMetrics{
+		{Time: time.Now(), Epoch: 100, PoolName: "pool", ValIndex: 1, PubKey: "0x01", BalanceDelta: big.NewInt(-10), MissedSource: true},
+		{Time: time.Now(), Epoch: 100, PoolName: "pool", ValIndex: 2, PubKey: "0x02", BalanceDelta: big.NewInt(10), AttestationIncluded: true},
+	}
+	err = db.StoreValidatorMetrics(validatorMetrics)
+	require.NoError(t, err)
+
+	// Storing the same epoch again overwrites the rows
+	err = db.StoreValidatorMetrics(validatorMetrics)
+	require.NoError(t, err)
+
+	var count int
+	err = db.db.QueryRow("SELECT COUNT(*) FROM t_validator_metrics WHERE f_epoch = 100").Scan(&count)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	var delta int64
+	err = db.db.QueryRow("SELECT f_balance_delta_gwei FROM t_validator_metrics WHERE f_validator_index = 1").Scan(&delta)
+	require.NoError(t, err)
+	require.Equal(t, int64(-10), delta)
+}
+
+func Test_CreateTables_AddsMissingColumns(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+
+	// Table as created by an older release
+	_, err = db.db.Exec(`CREATE TABLE t_pools_metrics_summary (f_epoch BIGINT, f_pool TEXT, PRIMARY KEY (f_epoch, f_pool))`)
+	require.NoError(t, err)
+
+	err = db.CreateTables()
+	require.NoError(t, err)
+
+	// Running it twice is a no-op
+	err = db.CreateTables()
+	require.NoError(t, err)
+
+	_, err = db.db.Exec(`SELECT f_avg_inclusion_delay, f_max_inclusion_delay FROM t_pools_metrics_summary`)
+	require.NoError(t, err)
+}
+
+func Test_StoreSlashing(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+
+	err = db.CreateTables()
+	require.NoError(t, err)
+
+	slashing := schemas.Slashing{Epoch: 10, Slot: 320, ValIndex: 7, PoolName: "pool_a", SlashingType: schemas.ProposerSlashing}
+	require.NoError(t, db.StoreSlashing(slashing))
+	require.NoError(t, db.StoreSlashing(slashing))
+
+	var pool, slashingType string
+	err = db.db.QueryRow("SELECT f_pool, f_slashing_type FROM t_slashings WHERE f_validator_index = 7").Scan(&pool, &slashingType)
+	require.NoError(t, err)
+	require.Equal(t, "pool_a", pool)
+	require.Equal(t, "proposer", slashingType)
+}
+
+func Test_StoreVoluntaryExitAndBLSChange(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+
+	err = db.CreateTables()
+	require.NoError(t, err)
+
+	exit := schemas.VoluntaryExit{Epoch: 10, Slot: 320, ValIndex: 7, PoolName: "pool_a", ExitEpoch: 9}
+	require.NoError(t, db.StoreVoluntaryExit(exit))
+	require.NoError(t, db.StoreVoluntaryExit(exit))
+	require.NoError(t, db.StoreBLSChange(schemas.BLSChange{Epoch: 10, Slot: 321, ValIndex: 8, ExecutionAddress: "0xab"}))
+
+	var pool string
+	var exitEpoch uint64
+	err = db.db.QueryRow("SELECT f_pool, f_exit_epoch FROM t_voluntary_exits WHERE f_validator_index = 7").Scan(&pool, &exitEpoch)
+	require.NoError(t, err)
+	require.Equal(t, "pool_a", pool)
+	require.Equal(t, uint64(9), exitEpoch)
+
+	var address string
+	err = db.db.QueryRow("SELECT f_pool, f_execution_address FROM t_bls_changes WHERE f_validator_index = 8").Scan(&pool, &address)
+	require.NoError(t, err)
+	require.Equal(t, "", pool)
+	require.Equal(t, "0xab", address)
+}
+
+func Test_StoreDeposit(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+
+	err = db.CreateTables()
+	require.NoError(t, err)
+
+	// A deposit and a top up before the activation, and another one after
+	deposits := []schemas.Deposit{
+		{Epoch: 10, Slot: 320, BlockNumber: 100, Timestamp: 1000, DepositIndex: 1, PoolName: "pool_a", Pubkey: "0xaa", Amount: 1000000000},
+		{Epoch: 10, Slot: 321, BlockNumber: 101, Timestamp: 1012, DepositIndex: 2, PoolName: "pool_a", Pubkey: "0xaa", Amount: 31000000000},
+		{Epoch: 20, Slot: 640, BlockNumber: 400, Timestamp: 5000, DepositIndex: 3, PoolName: "pool_a", Pubkey: "0xaa", Amount: 1000000000},
+		{Epoch: 10, Slot: 322, BlockNumber: 102, Timestamp: 1024, DepositIndex: 4, PoolName: "pool_b", Pubkey: "0xbb", Amount: 32000000000},
+	}
+	for _, deposit := range deposits {
+		require.NoError(t, db.StoreDeposit(deposit))
+	}
+	require.NoError(t, db.StoreDeposit(deposits[0]))
+	require.NoError(t, db.StoreDepositActivation("0xaa", 15, 4000))
+
+	var nOfActivated int
+	err = db.db.QueryRow("SELECT COUNT(*) FROM t_deposits WHERE f_activation_epoch = 15").Scan(&nOfActivated)
+	require.NoError(t, err)
+	require.Equal(t, 2, nOfActivated)
+
+	// Measured from the first deposit
+	var pool string
+	var nOfActivations int
+	var avgLatency float64
+	err = db.db.QueryRow("SELECT f_pool, f_n_activations, f_avg_latency_seconds FROM v_activation_latency").Scan(&pool, &nOfActivations, &avgLatency)
+	require.NoError(t, err)
+	require.Equal(t, "pool_a", pool)
+	require.Equal(t, 1, nOfActivations)
+	require.Equal(t, float64(3000), avgLatency)
+}
+
+func Test_StoreExecutionRequests(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+
+	err = db.CreateTables()
+	require.NoError(t, err)
+
+	// Two requests of the same validator in a block
+	withdrawalRequests := []schemas.WithdrawalRequest{
+		{Epoch: 10, Slot: 320, BlockNumber: 100, PoolName: "pool_a", SourceAddress: "0xab", Pubkey: "0xaa", Amount: 1000000000},
+		{Epoch: 10, Slot: 320, BlockNumber: 100, RequestIndex: 1, PoolName: "pool_a", SourceAddress: "0xab", Pubkey: "0xaa"},
+	}
+	for _, request := range withdrawalRequests {
+		require.NoError(t, db.StoreWithdrawalRequest(request))
+		require.NoError(t, db.StoreWithdrawalRequest(request))
+	}
+	require.NoError(t, db.StoreConsolidationRequest(schemas.ConsolidationRequest{
+		Epoch: 10, Slot: 321, BlockNumber: 101, PoolName: "pool_b", SourceAddress: "0xab", SourcePubkey: "0xbb", TargetPubkey: "0xcc",
+	}))
+
+	var nOfRequests int
+	var amount uint64
+	err = db.db.QueryRow("SELECT COUNT(*), SUM(f_amount_gwei) FROM t_withdrawal_requests WHERE f_pool = 'pool_a'").Scan(&nOfRequests, &amount)
+	require.NoError(t, err)
+	require.Equal(t, 2, nOfRequests)
+	require.Equal(t, uint64(1000000000), amount)
+
+	var target string
+	err = db.db.QueryRow("SELECT f_target_pubkey FROM t_consolidation_requests WHERE f_pool = 'pool_b'").Scan(&target)
+	require.NoError(t, err)
+	require.Equal(t, "0xcc", target)
+}
+
+func Test_StorePartialWithdrawals(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+
+	err = db.CreateTables()
+	require.NoError(t, err)
+
+	withdrawal := schemas.PartialWithdrawal{Epoch: 12, ValIndex: 2, PoolName: "pool_a", NOfRequests: 1, WithdrawableEpoch: 11, RequestedGwei: 1000000000, WithdrawnGwei: 500000000}
+	require.NoError(t, db.StorePartialWithdrawal(withdrawal))
+	require.NoError(t, db.StorePartialWithdrawal(withdrawal))
+	pending := schemas.PendingPartialWithdrawals{Epoch: 12, PoolName: "pool_a", NOfPending: 2, PendingGwei: 3000000000, LastWithdrawableEpoch: 21}
+	require.NoError(t, db.StorePendingPartialWithdrawals(pending))
+
+	var requested, withdrawn uint64
+	err = db.db.QueryRow("SELECT f_requested_gwei, f_withdrawn_gwei FROM t_partial_withdrawals WHERE f_pool = 'pool_a'").Scan(&requested, &withdrawn)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1000000000), requested)
+	require.Equal(t, uint64(500000000), withdrawn)
+
+	var nOfPending, pendingGwei uint64
+	err = db.db.QueryRow("SELECT f_n_pending, f_pending_gwei FROM t_pending_partial_withdrawals WHERE f_epoch = 12").Scan(&nOfPending, &pendingGwei)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), nOfPending)
+	require.Equal(t, uint64(3000000000), pendingGwei)
+}
+
+func Test_StoreClientDiversity(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+
+	err = db.CreateTables()
+	require.NoError(t, err)
+
+	diversity := schemas.ClientDiversity{Epoch: 10, PoolName: "pool_a", ConsensusClient: "lighthouse", ExecutionClient: "geth", NOfBlocks: 1}
+	require.NoError(t, db.StoreClientDiversity(diversity))
+	diversity.NOfBlocks = 2
+	require.NoError(t, db.StoreClientDiversity(diversity))
+
+	var nOfBlocks uint64
+	err = db.db.QueryRow("SELECT f_n_blocks FROM t_client_diversity WHERE f_pool = 'pool_a' AND f_consensus_client = 'lighthouse'").Scan(&nOfBlocks)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), nOfBlocks)
+}
+
+func Test_StoreProposal(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+
+	err = db.CreateTables()
+	require.NoError(t, err)
+
+	// Larger than an int64
+	mevReward, _ := big.NewInt(0).SetString("12000000000000000000", 10)
+	proposal := schemas.Proposal{
+		Epoch:           3,
+		Slot:            100,
+		ProposerIndex:   10,
+		PoolName:        "pool_a",
+		ConsensusReward: big.NewInt(40000000),
+		ExecutionTip:    big.NewInt(0),
+		MEVReward:       mevReward,
+		Relays:          []string{"https://relay_a", "https://relay_b"},
+		BuilderPubKey:   "0xbuilder",
+		FeeRecipient:    "0xfee",
+	}
+	require.NoError(t, db.StoreProposal(proposal))
+	require.NoError(t, db.StoreProposal(proposal))
+
+	var pool, mev, relays, feeRecipient string
+	var consensusReward int64
+	var vanilla bool
+	err = db.db.QueryRow("SELECT f_pool, f_consensus_reward_gwei, f_mev_reward_wei, f_relays, f_fee_recipient, f_vanilla FROM t_proposals WHERE f_slot = 100").Scan(&pool, &consensusReward, &mev, &relays, &feeRecipient, &vanilla)
+	require.NoError(t, err)
+	require.Equal(t, "0xfee", feeRecipient)
+	require.False(t, vanilla)
+	require.Equal(t, "pool_a", pool)
+	require.Equal(t, int64(40000000), consensusReward)
+	require.Equal(t, "12000000000000000000", mev)
+	require.Equal(t, "https://relay_a,https://relay_b", relays)
+
+	// Rewards are NULL if unknown
+	var attestationsReward sql.NullInt64
+	err = db.db.QueryRow("SELECT f_attestations_reward_gwei FROM t_proposals WHERE f_slot = 100").Scan(&attestationsReward)
+	require.NoError(t, err)
+	require.False(t, attestationsReward.Valid)
+
+	proposal.AttestationsReward = big.NewInt(39000000)
+	require.NoError(t, db.StoreProposal(proposal))
+	err = db.db.QueryRow("SELECT f_attestations_reward_gwei FROM t_proposals WHERE f_slot = 100").Scan(&attestationsReward)
+	require.NoError(t, err)
+	require.Equal(t, int64(39000000), attestationsReward.Int64)
+}
+
+func Test_EpochBlockRoots(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+
+	err = db.CreateTables()
+	require.NoError(t, err)
+
+	_, found, err := db.GetEpochBlockRoots(10)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, db.StoreEpochBlockRoots(10, []string{"320:0x01", "321:0x02"}))
+	require.NoError(t, db.StoreEpochBlockRoots(11, []string{}))
+
+	roots, found, err := db.GetEpochBlockRoots(10)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []string{"320:0x01", "321:0x02"}, roots)
+
+	roots, found, err = db.GetEpochBlockRoots(11)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []string{}, roots)
+}
+
+func Test_StoreValidatorStatus(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+
+	err = db.CreateTables()
+	require.NoError(t, err)
+
+	status := schemas.ValidatorStatus{
+		Epoch:                100,
+		PoolName:             "pool",
+		NOfPending:           2,
+		NOfActive:            10,
+		NOfExiting:           1,
+		ActivationWaitEpochs: 3,
+		ExitWaitEpochs:       5,
+	}
+	require.NoError(t, db.StoreValidatorStatus(status))
+
+	// Stored again if the epoch is processed twice
+	status.NOfActive = 11
+	require.NoError(t, db.StoreValidatorStatus(status))
+
+	var nOfActive, activationWait uint64
+	err = db.db.QueryRow(
+		"SELECT f_n_active, f_activation_wait_epochs FROM t_validator_status WHERE f_epoch = ? AND f_pool = ?",
+		100, "pool").Scan(&nOfActive, &activationWait)
+	require.NoError(t, err)
+	require.Equal(t, uint64(11), nOfActive)
+	require.Equal(t, uint64(3), activationWait)
+}
+
+func Test_StoreNetworkQueues(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+
+	err = db.CreateTables()
+	require.NoError(t, err)
+
+	require.NoError(t, db.StoreNetworkQueues(schemas.NetworkQueues{
+		Time:               time.Unix(1673308800, 0),
+		Epoch:              100,
+		ChurnLimitGwei:     256000000000,
+		NOfPendingDeposits: 3,
+		EntryWaitEpochs:    7,
+		ExitWaitEpochs:     10,
+	}))
+
+	var churn, entryWait uint64
+	err = db.db.QueryRow(
+		"SELECT f_churn_limit_gwei, f_entry_wait_epochs FROM t_network_queues WHERE f_epoch = ?",
+		100).Scan(&churn, &entryWait)
+	require.NoError(t, err)
+	require.Equal(t, uint64(256000000000), churn)
+	require.Equal(t, uint64(7), entryWait)
+}
+
+func Test_StoreRelayStats(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+
+	err = db.CreateTables()
+	require.NoError(t, err)
+
+	require.NoError(t, db.StoreRelayStats(schemas.RelayStats{
+		Epoch:        100,
+		Relay:        "https://relay_a",
+		NOfRequests:  32,
+		NOfErrors:    2,
+		NOfPayloads:  1,
+		ValueWei:     new(big.Int).Mul(big.NewInt(20), big.NewInt(1000000000000000000)),
+		AvgLatencyMs: 150.5,
+	}))
+
+	var nOfErrors uint64
+	var value string
+	var latency float64
+	err = db.db.QueryRow(
+		"SELECT f_n_errors, f_value_wei, f_avg_latency_ms FROM t_relay_stats WHERE f_epoch = ? AND f_relay = ?",
+		100, "https://relay_a").Scan(&nOfErrors, &value, &latency)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), nOfErrors)
+	require.Equal(t, "20000000000000000000", value)
+	require.Equal(t, 150.5, latency)
+}
+
+func Test_StoreProposalDuties(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+
+	err = db.CreateTables()
+	require.NoError(t, err)
+
+	require.NoError(t, db.StoreProposalDuties(10, "pool_a", 5, 4, 1, 0, 1))
+	require.NoError(t, db.StoreProposalDuties(10, "pool_b", 1, 0, 1, 0, 0))
+
+	var vanillaBlocks uint64
+	var vanillaRatio float64
+	err = db.db.QueryRow("SELECT f_n_vanilla_blocks, f_vanilla_ratio FROM t_proposal_duties WHERE f_epoch = 10 AND f_pool = 'pool_a'").Scan(&vanillaBlocks, &vanillaRatio)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), vanillaBlocks)
+	require.Equal(t, 0.25, vanillaRatio)
+
+	err = db.db.QueryRow("SELECT f_vanilla_ratio FROM t_proposal_duties WHERE f_epoch = 10 AND f_pool = 'pool_b'").Scan(&vanillaRatio)
+	require.NoError(t, err)
+	require.Equal(t, float64(0), vanillaRatio)
+}
+
+func Test_StoreFeeRecipientViolation(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+
+	err = db.CreateTables()
+	require.NoError(t, err)
+
+	require.NoError(t, db.StoreFeeRecipientViolation(schemas.FeeRecipientViolation{
+		Epoch:         3,
+		Slot:          100,
+		ProposerIndex: 10,
+		PoolName:      "pool_a",
+		FeeRecipient:  "0xthief",
+		Expected:      []string{"0xa", "0xb"},
+		MEV:           true,
+	}))
+
+	var pool, feeRecipient, expected string
+	var mev bool
+	err = db.db.QueryRow("SELECT f_pool, f_fee_recipient, f_expected, f_mev FROM t_fee_recipient_violations WHERE f_slot = 100").Scan(&pool, &feeRecipient, &expected, &mev)
+	require.NoError(t, err)
+	require.Equal(t, "pool_a", pool)
+	require.Equal(t, "0xthief", feeRecipient)
+	require.Equal(t, "0xa,0xb", expected)
+	require.True(t, mev)
+}
+
+func Test_StoreBlockBlobs(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+
+	err = db.CreateTables()
+	require.NoError(t, err)
+
+	require.NoError(t, db.StoreBlockBlobs(schemas.BlockBlobs{
+		Epoch:         3,
+		Slot:          100,
+		ProposerIndex: 10,
+		PoolName:      "pool_a",
+		NOfBlobs:      3,
+		BlobGasUsed:   393216,
+		MaxBlobGas:    1179648,
+		ExcessBlobGas: 100000000,
+		BlobBaseFee:   big.NewInt(470442149),
+		BlobFee:       big.NewInt(184985178580992),
+	}))
+
+	var pool, blobBaseFee, blobFee string
+	var nOfBlobs uint64
+	err = db.db.QueryRow("SELECT f_pool, f_n_blobs, f_blob_base_fee_wei, f_blob_fee_wei FROM t_block_blobs WHERE f_slot = 100").Scan(&pool, &nOfBlobs, &blobBaseFee, &blobFee)
+	require.NoError(t, err)
+	require.Equal(t, "pool_a", pool)
+	require.Equal(t, uint64(3), nOfBlobs)
+	require.Equal(t, "470442149", blobBaseFee)
+	require.Equal(t, "184985178580992", blobFee)
+}
+
+func Test_StoreMEVPayout(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+
+	err = db.CreateTables()
+	require.NoError(t, err)
+
+	require.NoError(t, db.StoreMEVPayout(schemas.MEVPayout{
+		Epoch:         3,
+		Slot:          100,
+		ProposerIndex: 10,
+		PoolName:      "pool_a",
+		FeeRecipient:  "0xpool",
+		Relays:        []string{"relay_a", "relay_b"},
+		BuilderPubKey: "0xbuilder",
+		Reported:      big.NewInt(1000),
+		Paid:          big.NewInt(400),
+		Shortfall:     big.NewInt(600),
+	}))
+
+	var pool, relays, reported, paid, shortfall string
+	err = db.db.QueryRow("SELECT f_pool, f_relays, f_reported_wei, f_paid_wei, f_shortfall_wei FROM t_mev_payouts WHERE f_slot = 100").Scan(&pool, &relays, &reported, &paid, &shortfall)
+	require.NoError(t, err)
+	require.Equal(t, "pool_a", pool)
+	require.Equal(t, "relay_a,relay_b", relays)
+	require.Equal(t, "1000", reported)
+	require.Equal(t, "400", paid)
+	require.Equal(t, "600", shortfall)
+}
+
+func Test_StoreMissedMEV(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+
+	err = db.CreateTables()
+	require.NoError(t, err)
+
+	require.NoError(t, db.StoreMissedMEV(schemas.MissedMEV{
+		Epoch:         3,
+		Slot:          100,
+		ProposerIndex: 10,
+		PoolName:      "pool_a",
+		Relay:         "relay_a",
+		BuilderPubKey: "0xbuilder",
+		BestBid:       big.NewInt(1000),
+		Tip:           big.NewInt(300),
+		Missed:        big.NewInt(700),
+	}))
+
+	var pool, relay, bestBid, tip, missed string
+	err = db.db.QueryRow("SELECT f_pool, f_relay, f_best_bid_wei, f_tip_wei, f_missed_wei FROM t_missed_mev WHERE f_slot = 100").Scan(&pool, &relay, &bestBid, &tip, &missed)
+	require.NoError(t, err)
+	require.Equal(t, "pool_a", pool)
+	require.Equal(t, "relay_a", relay)
+	require.Equal(t, "1000", bestBid)
+	require.Equal(t, "300", tip)
+	require.Equal(t, "700", missed)
+}
+
+func Test_StoreProposalTiming(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+
+	err = db.CreateTables()
+	require.NoError(t, err)
+
+	require.NoError(t, db.StoreProposalTiming(schemas.ProposalTiming{
+		Epoch:         3,
+		Slot:          100,
+		ProposerIndex: 10,
+		PoolName:      "pool_a",
+		SeenAt:        time.Unix(1700000000, 0).UTC(),
+		DelayMs:       4500,
+		Late:          true,
+	}))
+
+	var pool string
+	var delayMs int64
+	var late bool
+	err = db.db.QueryRow("SELECT f_pool, f_delay_ms, f_late FROM t_proposal_timings WHERE f_slot = 100").Scan(&pool, &delayMs, &late)
+	require.NoError(t, err)
+	require.Equal(t, "pool_a", pool)
+	require.Equal(t, int64(4500), delayMs)
+	require.True(t, late)
+}
+
+func Test_GetEpochRows(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+
+	err = db.CreateTables()
+	require.NoError(t, err)
+
+	require.NoError(t, db.StoreSlashing(schemas.Slashing{Epoch: 10, Slot: 320, ValIndex: 7, PoolName: "pool_a", SlashingType: schemas.ProposerSlashing}))
+	require.NoError(t, db.StoreSlashing(schemas.Slashing{Epoch: 11, Slot: 352, ValIndex: 8, PoolName: "pool_b", SlashingType: schemas.ProposerSlashing}))
+	require.NoError(t, db.StoreSlashing(schemas.Slashing{Epoch: 12, Slot: 384, ValIndex: 9, PoolName: "pool_a", SlashingType: schemas.ProposerSlashing}))
+
+	columns, rows, err := db.GetEpochRows("t_slashings", 10, 11, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"f_epoch", "f_slot", "f_validator_index", "f_pool", "f_slashing_type"}, columns)
+	require.Equal(t, [][]interface{}{
+		{int64(10), int64(320), int64(7), "pool_a", "proposer"},
+		{int64(11), int64(352), int64(8), "pool_b", "proposer"},
+	}, rows)
+
+	_, rows, err = db.GetEpochRows("t_slashings", 0, 100, "pool_a")
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+
+	_, _, err = db.GetEpochRows("t_network_stats", 0, 100, "pool_a")
+	require.Error(t, err)
+	_, _, err = db.GetEpochRows("t_eth_price", 0, 100, "")
+	require.Error(t, err)
+}
+
+func Test_BackfillProgress(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+
+	err = db.CreateTables()
+	require.NoError(t, err)
+
+	_, found, err := db.GetBackfillProgress(100, 200)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, db.StoreBackfillProgress(100, 200, 100))
+	require.NoError(t, db.StoreBackfillProgress(100, 200, 150))
+	require.NoError(t, db.StoreBackfillProgress(100, 300, 120))
+
+	lastEpoch, found, err := db.GetBackfillProgress(100, 200)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, uint64(150), lastEpoch)
+}
+
+func Test_PruneEpochs(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+
+	err = db.CreateTables()
+	require.NoError(t, err)
+
+	// Two epochs on 2023-11-14 and one on 2023-11-15, UTC. The MEV rewards
+	// overflow an int64 once summed.
+	mevRewards, _ := new(big.Int).SetString("6000000000000000001", 10)
+	day := time.Unix(1700000000, 0).In(time.FixedZone("CET", 3600))
+	for i, ts := range []time.Time{day, day.Add(384 * time.Second), day.Add(24 * time.Hour)} {
+		require.NoError(t, db.StoreValidatorPerformance(schemas.ValidatorPerformanceMetrics{
+			Time:                ts,
+			Epoch:               uint64(100 + i),
+			PoolName:            "pool_a",
+			NOfActiveValidators: 10,
+			NOfTotalVotes:       10,
+			NOfIncorrectSource:  1,
+			EarnedBalance:       big.NewInt(1000),
+			LosedBalance:        big.NewInt(10),
+			EffectiveBalance:    big.NewInt(100),
+			MEVRewards:          mevRewards,
+			ProposerTips:        big.NewInt(999999999),
+		}))
+	}
+	require.NoError(t, db.StoreSlashing(schemas.Slashing{Epoch: 100, Slot: 3200, ValIndex: 7, PoolName: "pool_a", SlashingType: schemas.ProposerSlashing}))
+
+	type period struct {
+		Start     string
+		NEpochs   int64
+		Earned    int64
+		AvgActive float64
+	}
+	getPeriods := func(query string) []period {
+		rows, err := db.db.Query(query)
+		require.NoError(t, err)
+		defer rows.Close()
+		periods := []period{}
+		for rows.Next() {
+			var p period
+			require.NoError(t, rows.Scan(&p.Start, &p.NEpochs, &p.Earned, &p.AvgActive))
+			periods = append(periods, p)
+		}
+		return periods
+	}
+	daily := "SELECT f_day, f_n_epochs, f_earned_balance_gwei, f_avg_active_validators FROM v_pools_metrics_daily ORDER BY f_day"
+	weekly := "SELECT f_week, f_n_epochs, f_earned_balance_gwei, f_avg_active_validators FROM v_pools_metrics_weekly ORDER BY f_week"
+	expectedDays := []period{
+		{"2023-11-14", 2, 2000, 10},
+		{"2023-11-15", 1, 1000, 10},
+	}
+	require.Equal(t, expectedDays, getPeriods(daily))
+
+	// Pruning twice doesn't roll up the same epochs twice
+	require.NoError(t, db.PruneEpochs(102, 102*32))
+	require.NoError(t, db.PruneEpochs(102, 102*32))
+
+	_, rows, err := db.GetEpochRows("t_pools_metrics_summary", 0, 200, "")
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	_, rows, err = db.GetEpochRows("t_slashings", 0, 200, "")
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+
+	require.Equal(t, expectedDays, getPeriods(daily))
+	require.Equal(t, []period{{"2023-11-13", 3, 3000, 10}}, getPeriods(weekly))
+
+	// Averaged over the epochs, also for the rolled up days
+	var avgEffectiveBalance float64
+	require.NoError(t, db.db.QueryRow("SELECT f_avg_effective_balance_gwei FROM v_pools_metrics_weekly").Scan(&avgEffectiveBalance))
+	require.Equal(t, float64(100), avgEffectiveBalance)
+
+	// Wei are summed exactly, in the rolled up days and over them
+	var mev, tips string
+	require.NoError(t, db.db.QueryRow("SELECT f_mev_rewards_wei, f_proposer_tips_wei FROM t_pools_metrics_daily").Scan(&mev, &tips))
+	require.Equal(t, "12000000000000000002", mev)
+	require.Equal(t, "1999999998", tips)
+	require.NoError(t, db.db.QueryRow("SELECT f_mev_rewards_wei, f_proposer_tips_wei FROM v_pools_metrics_weekly").Scan(&mev, &tips))
+	require.Equal(t, "18000000000000000003", mev)
+	require.Equal(t, "2999999997", tips)
+
+	// Also when epochs of a rolled up day are rolled up later
+	require.NoError(t, db.StoreValidatorPerformance(schemas.ValidatorPerformanceMetrics{
+		Time:             day.Add(768 * time.Second),
+		Epoch:            103,
+		PoolName:         "pool_a",
+		EarnedBalance:    big.NewInt(1000),
+		LosedBalance:     big.NewInt(10),
+		EffectiveBalance: big.NewInt(100),
+		MEVRewards:       mevRewards,
+	}))
+	require.NoError(t, db.PruneEpochs(104, 104*32))
+	require.NoError(t, db.db.QueryRow("SELECT f_mev_rewards_wei, f_proposer_tips_wei FROM t_pools_metrics_daily WHERE f_day = '2023-11-14'").Scan(&mev, &tips))
+	require.Equal(t, "18000000000000000003", mev)
+	require.Equal(t, "1999999998", tips)
+}
+
+func Test_CreateTables_MigratesWeiColumns(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+
+	// Wei columns were BIGINT in older releases
+	_, err = db.db.Exec(strings.ReplaceAll(createPoolsMetricsTable, "_wei TEXT", "_wei BIGINT"))
+	require.NoError(t, err)
+	_, err = db.db.Exec(`INSERT INTO t_pools_metrics_summary (f_timestamp, f_epoch_timestamp, f_epoch, f_pool, f_mev_rewards_wei) VALUES (0, 0, 100, 'pool_a', 5000000000000000000)`)
+	require.NoError(t, err)
+	// And FLOAT in the daily rollup
+	_, err = db.db.Exec(strings.ReplaceAll(createPoolsMetricsDailyTable, "_wei TEXT", "_wei FLOAT"))
+	require.NoError(t, err)
+	_, err = db.db.Exec(`INSERT INTO t_pools_metrics_daily (f_day, f_pool, f_mev_rewards_wei) VALUES ('2023-11-14', 'pool_a', 1e20)`)
+	require.NoError(t, err)
+
+	require.NoError(t, db.CreateTables())
+	require.NoError(t, db.CreateTables())
+
+	// 100 ETH overflows an int64
+	mevRewards, _ := new(big.Int).SetString("100000000000000000000", 10)
+	require.NoError(t, db.StoreValidatorPerformance(schemas.ValidatorPerformanceMetrics{
+		Time:             time.Now(),
+		Epoch:            101,
+		PoolName:         "pool_a",
+		EarnedBalance:    big.NewInt(100),
+		LosedBalance:     big.NewInt(100),
+		EffectiveBalance: big.NewInt(100),
+		MEVRewards:       mevRewards,
+	}))
+
+	var mev, tips Wei
+	err = db.db.QueryRow("SELECT f_mev_rewards_wei, f_proposer_tips_wei FROM t_pools_metrics_summary WHERE f_epoch = 100").Scan(&mev, &tips)
+	require.NoError(t, err)
+	require.Equal(t, "5000000000000000000", mev.String())
+	require.Nil(t, tips.Int)
+
+	err = db.db.QueryRow("SELECT f_mev_rewards_wei, f_proposer_tips_wei FROM t_pools_metrics_summary WHERE f_epoch = 101").Scan(&mev, &tips)
+	require.NoError(t, err)
+	require.Equal(t, mevRewards, mev.Int)
+	require.Nil(t, tips.Int)
+
+	err = db.db.QueryRow("SELECT f_mev_rewards_wei FROM t_pools_metrics_daily").Scan(&mev)
+	require.NoError(t, err)
+	require.Equal(t, mevRewards, mev.Int)
+
+	var columnType string
+	err = db.db.QueryRow("SELECT type FROM pragma_table_info('t_pools_metrics_summary') WHERE name = 'f_mev_rewards_wei'").Scan(&columnType)
+	require.NoError(t, err)
+	require.Equal(t, "TEXT", columnType)
+}
+
+func Test_StoreMissedAttestations(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+
+	err = db.CreateTables()
+	require.NoError(t, err)
+
+	require.NoError(t, db.StoreMissedAttestations(100, "pool_a", []schemas.MissedAttestation{
+		{Epoch: 100, PoolName: "pool_a", ValIndex: 1, MissType: schemas.MissedHead},
+		{Epoch: 100, PoolName: "pool_a", ValIndex: 2, MissType: schemas.MissedAllVotes},
+	}))
+	require.NoError(t, db.StoreMissedAttestations(100, "pool_b", []schemas.MissedAttestation{
+		{Epoch: 100, PoolName: "pool_b", ValIndex: 3, MissType: schemas.MissedSource},
+	}))
+	// Processing the epoch again replaces the misses of the pool
+	require.NoError(t, db.StoreMissedAttestations(100, "pool_a", []schemas.MissedAttestation{
+		{Epoch: 100, PoolName: "pool_a", ValIndex: 2, MissType: schemas.MissedAllVotes},
+	}))
+
+	_, rows, err := db.GetEpochRows("t_missed_attestations", 100, 100, "")
+	require.NoError(t, err)
+	require.Equal(t, [][]interface{}{
+		// Not tagged as maintenance until the pool is processed
+		{int64(100), "pool_a", int64(2), "missed", nil},
+		{int64(100), "pool_b", int64(3), "source", nil},
+	}, rows)
+}
+
+func Test_GetFailedPools(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+
+	err = db.CreateTables()
+	require.NoError(t, err)
+
+	require.NoError(t, db.StoreProcessingStatus(schemas.ProcessingStatus{Epoch: 100, PoolName: "pool_a"}))
+	require.NoError(t, db.StoreProcessingStatus(schemas.ProcessingStatus{Epoch: 100, PoolName: "pool_b", Error: "no keys"}))
+	require.NoError(t, db.StoreProcessingStatus(schemas.ProcessingStatus{Epoch: 101, PoolName: "pool_b", Error: "no keys"}))
+
+	failedPools, err := db.GetFailedPools(3)
+	require.NoError(t, err)
+	require.Equal(t, map[uint64][]string{100: {"pool_b"}, 101: {"pool_b"}}, failedPools)
+
+	// Retried pools that succeed are no longer failed, and the ones failing
+	// again are given up after the attempts
+	require.NoError(t, db.StoreProcessingStatus(schemas.ProcessingStatus{Epoch: 100, PoolName: "pool_b"}))
+	require.NoError(t, db.StoreProcessingStatus(schemas.ProcessingStatus{Epoch: 101, PoolName: "pool_b", Error: "no keys"}))
+	failedPools, err = db.GetFailedPools(3)
+	require.NoError(t, err)
+	require.Equal(t, map[uint64][]string{101: {"pool_b"}}, failedPools)
+
+	require.NoError(t, db.StoreProcessingStatus(schemas.ProcessingStatus{Epoch: 101, PoolName: "pool_b", Error: "no keys"}))
+	failedPools, err = db.GetFailedPools(3)
+	require.NoError(t, err)
+	require.Empty(t, failedPools)
+}
+
+func Test_EpochTransaction(t *testing.T) {
+	db, err := New(filepath.Join(t.TempDir(), "db.db"))
+	require.NoError(t, err)
+	require.NoError(t, db.CreateTables())
+
+	store := func(epoch uint64) {
+		err := db.StoreValidatorPerformance(schemas.ValidatorPerformanceMetrics{
+			Time:             time.Now(),
+			Epoch:            epoch,
+			PoolName:         "pool",
+			EarnedBalance:    big.NewInt(100),
+			LosedBalance:     big.NewInt(100),
+			EffectiveBalance: big.NewInt(100),
+		})
+		require.NoError(t, err)
+		err = db.StoreValidatorMetrics([]schemas.ValidatorMetrics{
+			{Time: time.Now(), Epoch: epoch, PoolName: "pool", ValIndex: 1, BalanceDelta: big.NewInt(10)},
+		})
+		require.NoError(t, err)
+		require.NoError(t, db.StoreEpochBlockRoots(epoch, []string{"0x01"}))
+		require.NoError(t, db.StoreEpochProcessed(epoch, "pool", []string{ModuleSummary}))
+	}
+
+	// An epoch interrupted midway leaves no rows
+	require.NoError(t, db.BeginEpoch())
+	require.ErrorContains(t, db.BeginEpoch(), "already open")
+	store(199)
+	db.RollbackEpoch()
+
+	require.NoError(t, db.BeginEpoch())
+	store(200)
+	// Visible in the transaction before the commit
+	_, found, err := db.GetEpochBlockRoots(200)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.NoError(t, db.CommitEpoch())
+	// Does nothing once committed
+	db.RollbackEpoch()
+	require.ErrorContains(t, db.CommitEpoch(), "no epoch transaction")
+
+	epochs, err := db.GetMissingEpochs(200, 2, []string{"pool"})
+	require.NoError(t, err)
+	require.Equal(t, map[uint64][]string{199: {"pool"}}, epochs)
+
+	_, rows, err := db.GetEpochRows("t_validator_metrics", 199, 200, "")
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	_, found, err = db.GetEpochBlockRoots(199)
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func Test_PoolSavepoint(t *testing.T) {
+	db, err := New(filepath.Join(t.TempDir(), "db.db"))
+	require.NoError(t, err)
+	require.NoError(t, db.CreateTables())
+
+	store := func(poolName string) {
+		err := db.StoreValidatorPerformance(schemas.ValidatorPerformanceMetrics{
+			Time:             time.Now(),
+			Epoch:            200,
+			PoolName:         poolName,
+			EarnedBalance:    big.NewInt(100),
+			LosedBalance:     big.NewInt(100),
+			EffectiveBalance: big.NewInt(100),
+		})
+		require.NoError(t, err)
+	}
+
+	// Does nothing without an epoch transaction
+	require.NoError(t, db.BeginPool())
+	require.NoError(t, db.ReleasePool())
+
+	require.NoError(t, db.BeginEpoch())
+	require.NoError(t, db.BeginPool())
+	store("pool_a")
+	require.NoError(t, db.ReleasePool())
+	// Fails after storing its summary
+	require.NoError(t, db.BeginPool())
+	store("pool_b")
+	require.NoError(t, db.RollbackPool())
+	require.NoError(t, db.BeginPool())
+	store("pool_c")
+	require.NoError(t, db.ReleasePool())
+	require.NoError(t, db.CommitEpoch())
+
+	for poolName, expected := range map[string]int{"pool_a": 1, "pool_b": 0, "pool_c": 1} {
+		_, rows, err := db.GetEpochRows("t_pools_metrics_summary", 200, 200, poolName)
+		require.NoError(t, err)
+		require.Len(t, rows, expected, poolName)
+	}
+}
+
+func Test_Detached(t *testing.T) {
+	db, err := New(filepath.Join(t.TempDir(), "db.db"))
+	require.NoError(t, err)
+	require.NoError(t, db.CreateTables())
+
+	// Written while an epoch is open, which is then rolled back
+	require.NoError(t, db.BeginEpoch())
+	require.NoError(t, db.StoreEpochBlockRoots(200, []string{"0x01"}))
+	stored := make(chan error)
+	go func() {
+		stored <- db.Detached().StoreMaintenanceWindow(schemas.MaintenanceWindow{
+			Id:       1,
+			PoolName: "pool",
+			Start:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			End:      time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		})
+	}()
+	time.Sleep(50 * time.Millisecond)
+	db.RollbackEpoch()
+	require.NoError(t, <-stored)
+
+	_, found, err := db.GetEpochBlockRoots(200)
+	require.NoError(t, err)
+	require.False(t, found)
+	windows, err := db.GetMaintenanceWindows()
+	require.NoError(t, err)
+	require.Len(t, windows, 1)
+	require.Equal(t, "pool", windows[0].PoolName)
+}
+
+func Test_DataSourceName(t *testing.T) {
+	require.Equal(t, ":memory:", DataSourceName(":memory:", DefaultOptions()))
+	require.Equal(t, "db.db?mode=ro", DataSourceName("db.db?mode=ro", DefaultOptions()))
+	require.Equal(t,
+		"db.db?_txlock=immediate&_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)",
+		DataSourceName("db.db", DefaultOptions()))
+	require.Equal(t,
+		"db.db?_txlock=immediate&_pragma=cache_size(-65536)",
+		DataSourceName("db.db", Options{CacheSize: 65536}))
+}
+
+func Test_RetryBusy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.db")
+	writer, err := New(path)
+	require.NoError(t, err)
+	require.NoError(t, writer.CreateEthPriceTable())
+
+	var journalMode string
+	require.NoError(t, writer.db.QueryRow("PRAGMA journal_mode").Scan(&journalMode))
+	require.Equal(t, "wal", journalMode)
+
+	// Without a busy timeout the write fails right away while locked
+	other, err := NewWithOptions(path, Options{})
+	require.NoError(t, err)
+	require.NoError(t, writer.BeginEpoch())
+	_, err = other.db.Exec("INSERT INTO t_eth_price (f_timestamp, f_eth_price_usd) VALUES (?, ?)", time.Now(), 1)
+	require.True(t, IsBusy(err))
+
+	// And is retried until the lock is released
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		writer.RollbackEpoch()
+	}()
+	require.NoError(t, other.StoreEthPrice(1))
+	require.False(t, IsBusy(errors.New("other")))
+}
+
+func Test_GetEthPriceAt(t *testing.T) {
+	database, err := New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.CreateEthPriceTable())
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, priceUsd := range []float32{2000, 2100, 2200} {
+		_, err := database.db.Exec("INSERT INTO t_eth_price (f_timestamp, f_eth_price_usd) VALUES (?, ?)",
+			start.Add(time.Duration(i)*30*time.Minute), priceUsd)
+		require.NoError(t, err)
+	}
+
+	// The last sample before the time, regardless of its timezone
+	priceUsd, found, err := database.GetEthPriceAt(start.Add(40*time.Minute).In(time.FixedZone("CET", 3600)), time.Hour)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, float32(2100), priceUsd)
+
+	// Not found if too old or before the first sample
+	_, found, err = database.GetEthPriceAt(start.Add(5*time.Hour), time.Hour)
+	require.NoError(t, err)
+	require.False(t, found)
+	_, found, err = database.GetEthPriceAt(start.Add(-time.Minute), time.Hour)
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func Test_StorePoolGroupMetrics(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, db.CreateTables())
+
+	// Larger than an int64 once summed
+	mevReward, _ := big.NewInt(0).SetString("6000000000000000000", 10)
+	for i, poolName := range []string{"x-lighthouse", "x-teku", "x-nimbus"} {
+		require.NoError(t, db.StoreValidatorPerformance(schemas.ValidatorPerformanceMetrics{
+			Time:                time.Unix(1700000000, 0),
+			Epoch:               100,
+			PoolName:            poolName,
+			NOfActiveValidators: 10,
+			NOfTotalVotes:       uint64(10 * (i + 1)),
+			AvgInclusionDelay:   float64(i + 1),
+			MaxInclusionDelay:   uint64(i + 2),
+			EarnedBalance:       big.NewInt(1000),
+			LosedBalance:        big.NewInt(10),
+			EffectiveBalance:    big.NewInt(100),
+			MEVRewards:          mevReward,
+			ProposerTips:        big.NewInt(5),
+			ProcessingMode:      schemas.ProcessingModeFinalized,
+		}))
+		require.NoError(t, db.StoreProposalDuties(100, poolName, 2, 1, 1, 0, 1))
+	}
+
+	cluster := schemas.PoolGroup{Name: "x_cluster_a", Parent: "operator_x", Members: []string{"x-lighthouse", "x-teku"}, Pools: []string{"x-lighthouse", "x-teku"}}
+	operator := schemas.PoolGroup{Name: "operator_x", Members: []string{"x_cluster_a", "x-nimbus"}, Pools: []string{"x-lighthouse", "x-nimbus", "x-teku"}}
+	// The operator first, so its members are labelled before the row of the cluster exists
+	for _, group := range []schemas.PoolGroup{operator, cluster} {
+		stored, err := db.StorePoolGroupMetrics(100, group)
+		require.NoError(t, err)
+		require.True(t, stored)
+	}
+
+	type row struct {
+		Pool              string
+		Parent            sql.NullString
+		NOfPools          sql.NullInt64
+		NOfActive         int64
+		Earned            int64
+		MEVRewards        Wei
+		ProposerTips      Wei
+		AvgInclusionDelay float64
+		MaxInclusionDelay int64
+		Mode              string
+		Proposed          int64
+		VanillaRatio      float64
+	}
+	rows, err := db.db.Query(`SELECT s.f_pool, s.f_parent_group, s.f_n_pools, s.f_n_active_validators, s.f_epoch_earned_balance_gwei,
+		s.f_mev_rewards_wei, s.f_proposer_tips_wei, s.f_avg_inclusion_delay, s.f_max_inclusion_delay, s.f_processing_mode,
+		d.f_n_proposed_blocks, d.f_vanilla_ratio
+		FROM t_pools_metrics_summary s JOIN t_proposal_duties d USING (f_epoch, f_pool) WHERE f_epoch = 100 ORDER BY s.f_pool`)
+	require.NoError(t, err)
+	defer rows.Close()
+	got := make(map[string]row)
+	for rows.Next() {
+		var r row
+		require.NoError(t, rows.Scan(&r.Pool, &r.Parent, &r.NOfPools, &r.NOfActive, &r.Earned, &r.MEVRewards, &r.ProposerTips,
+			&r.AvgInclusionDelay, &r.MaxInclusionDelay, &r.Mode, &r.Proposed, &r.VanillaRatio))
+		got[r.Pool] = r
+	}
+	require.NoError(t, rows.Err())
+	require.Len(t, got, 5)
+
+	require.Equal(t, "operator_x", got["x-nimbus"].Parent.String)
+	require.Equal(t, "x_cluster_a", got["x-teku"].Parent.String)
+	require.False(t, got["x-teku"].NOfPools.Valid)
+	require.Equal(t, "operator_x", got["x_cluster_a"].Parent.String)
+	require.False(t, got["operator_x"].Parent.Valid)
+
+	require.Equal(t, int64(2), got["x_cluster_a"].NOfPools.Int64)
+	require.Equal(t, int64(20), got["x_cluster_a"].NOfActive)
+	require.Equal(t, int64(3), got["operator_x"].NOfPools.Int64)
+	require.Equal(t, int64(30), got["operator_x"].NOfActive)
+	require.Equal(t, int64(3000), got["operator_x"].Earned)
+	require.Equal(t, "18000000000000000000", got["operator_x"].MEVRewards.String())
+	require.Equal(t, "15", got["operator_x"].ProposerTips.String())
+	// Weighted by the votes of each pool
+	require.InDelta(t, (10.0*1+20*2+30*3)/60, got["operator_x"].AvgInclusionDelay, 1e-9)
+	require.Equal(t, int64(4), got["operator_x"].MaxInclusionDelay)
+	require.Equal(t, "finalized", got["operator_x"].Mode)
+	require.Equal(t, int64(3), got["operator_x"].Proposed)
+	require.Equal(t, float64(1), got["operator_x"].VanillaRatio)
+
+	// Not stored if some pool has no metrics in the epoch
+	stored, err := db.StorePoolGroupMetrics(101, operator)
+	require.NoError(t, err)
+	require.False(t, stored)
+}
+
+func Test_StorePoolMembershipChanges(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, db.CreateTables())
+
+	now := time.Unix(1700000000, 0).UTC()
+	require.NoError(t, db.StorePoolMembershipChanges([]schemas.PoolMembershipChange{
+		{Epoch: 20, PoolName: "pool_a", PubKey: "0xaa", Action: schemas.MembershipLeave, Time: now},
+		{Epoch: 10, PoolName: "pool_a", PubKey: "0xaa", Action: schemas.MembershipJoin, Time: now},
+	}))
+	// Replaces the change of the same epoch
+	require.NoError(t, db.StorePoolMembershipChanges([]schemas.PoolMembershipChange{
+		{Epoch: 20, PoolName: "pool_a", PubKey: "0xaa", Action: schemas.MembershipJoin, Source: schemas.MembershipSourceConfig, Time: now},
+	}))
+
+	changes, err := db.GetPoolMembershipChanges()
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	require.Equal(t, uint64(10), changes[0].Epoch)
+	require.Equal(t, "pool_a", changes[0].PoolName)
+	require.Equal(t, "0xaa", changes[0].PubKey)
+	require.Equal(t, schemas.MembershipJoin, changes[1].Action)
+	require.Equal(t, schemas.MembershipSourceConfig, changes[1].Source)
+}
+
+func Test_GetPoolStatements(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, db.CreateTables())
+
+	// Above the precision of a float64
+	mev, _ := new(big.Int).SetString("1000000000000000001", 10)
+	for i, mevPartial := range []bool{false, true} {
+		require.NoError(t, db.StoreValidatorPerformance(schemas.ValidatorPerformanceMetrics{
+			Time:                 time.Unix(1700000000+int64(i)*384, 0),
+			Epoch:                uint64(100 + i),
+			PoolName:             "pool_a",
+			EarnedBalance:        big.NewInt(3000),
+			LosedBalance:         big.NewInt(-1000),
+			SyncCommitteeRewards: 200,
+			ProposerRewards:      100,
+			EffectiveBalance:     big.NewInt(0),
+			MEVRewards:           mev,
+			ProposerTips:         big.NewInt(7),
+			PartialWithdrawals:   big.NewInt(5),
+			FullWithdrawals:      big.NewInt(0),
+			MEVPartial:           mevPartial,
+		}))
+	}
+
+	statements, err := db.GetPoolStatements(100, 200, "pool_a")
+	require.NoError(t, err)
+	require.Len(t, statements, 1)
+	s := statements[0]
+	require.Equal(t, uint64(100), s.FromEpoch)
+	require.Equal(t, uint64(101), s.ToEpoch)
+	require.Equal(t, uint64(2), s.NOfEpochs)
+	require.Equal(t, "6600000000000", s.ConsensusRewards.String())
+	require.Equal(t, "2000000000000", s.Penalties.String())
+	require.Equal(t, "14", s.ProposerTips.String())
+	require.Equal(t, "2000000000000000002", s.MEVRewards.String())
+	require.Equal(t, "10000000000", s.PartialWithdrawals.String())
+	require.Equal(t, "2000004600000000016", s.Total().String())
+	require.True(t, s.MEVPartial)
+
+	statements, err = db.GetPoolStatements(101, 200, "pool_b")
+	require.NoError(t, err)
+	require.Empty(t, statements)
+
+	// Pruned epochs only have their daily sums
+	require.NoError(t, db.PruneEpochs(101, 101*32))
+	_, err = db.GetPoolStatements(100, 200, "")
+	require.Error(t, err)
+	statements, err = db.GetPoolStatements(101, 200, "")
+	require.NoError(t, err)
+	require.Len(t, statements, 1)
+}
+
+func Test_GetRecentPoolMetrics(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, db.CreateTables())
+
+	store := func(epoch uint64, poolName string) {
+		require.NoError(t, db.StoreValidatorPerformance(schemas.ValidatorPerformanceMetrics{
+			Time:                 time.Unix(1700000000+int64(epoch)*384, 0),
+			Epoch:                epoch,
+			PoolName:             poolName,
+			NOfValidatingKeys:    10,
+			NOfIncorrectSource:   1,
+			EarnedBalance:        big.NewInt(3000),
+			LosedBalance:         big.NewInt(-1000),
+			SyncCommitteeRewards: 200,
+			EffectiveBalance:     big.NewInt(0),
+		}))
+	}
+	store(100, "pool_a")
+	store(101, "pool_a")
+	store(102, "pool_a")
+	store(101, "pool_b")
+	require.NoError(t, db.StoreProposalDuties(102, "pool_a", 2, 1, 1, 0, 0))
+
+	metrics, err := db.GetRecentPoolMetrics("pool_a", 2)
+	require.NoError(t, err)
+	require.Len(t, metrics, 2)
+	require.Equal(t, uint64(102), metrics[0].Epoch)
+	require.Equal(t, uint64(101), metrics[1].Epoch)
+	require.Equal(t, uint64(10), metrics[0].NOfValidatingKeys)
+	require.Equal(t, uint64(1), metrics[0].NOfIncorrectSource)
+	require.Equal(t, int64(2200), metrics[0].RewardsGwei)
+	require.Equal(t, uint64(2), metrics[0].NOfScheduledBlocks)
+	require.Equal(t, uint64(1), metrics[0].NOfProposedBlocks)
+	require.Equal(t, uint64(0), metrics[1].NOfScheduledBlocks)
+
+	// The last epoch of all pools, where pool_b is missing
+	metrics, err = db.GetRecentPoolMetrics("", 1)
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	require.Equal(t, "pool_a", metrics[0].PoolName)
+
+	// Its own last epoch
+	metrics, err = db.GetRecentPoolMetrics("pool_b", 1)
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	require.Equal(t, uint64(101), metrics[0].Epoch)
+
+	metrics, err = db.GetRecentPoolMetrics("pool_c", 10)
+	require.NoError(t, err)
+	require.Empty(t, metrics)
+}
+
+func Test_StoreAlert(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, db.CreateTables())
+
+	alert := schemas.SentAlert{
+		Time:     time.Unix(1700000000, 0),
+		Epoch:    100,
+		PoolName: "pool_a",
+		Kind:     "missed_proposal",
+		Severity: "warning",
+		Message:  "missed",
+	}
+	require.NoError(t, db.StoreAlert(alert))
+	// Fired again when the epoch is processed again
+	require.NoError(t, db.StoreAlert(alert))
+	alert.Epoch = 101
+	alert.Kind = "doppelganger"
+	require.NoError(t, db.StoreAlert(alert))
+
+	alerts, err := db.GetRecentAlerts(10)
+	require.NoError(t, err)
+	require.Len(t, alerts, 2)
+	require.Equal(t, "doppelganger", alerts[0].Kind)
+	require.Equal(t, uint64(100), alerts[1].Epoch)
+	require.Equal(t, "warning", alerts[1].Severity)
+
+	alerts, err = db.GetRecentAlerts(1)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+}
+
+func Test_StoreEffectiveness(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, db.CreateTables())
+
+	score := func(v float64) *float64 { return &v }
+	day := time.Unix(1700000000, 0).UTC()
+	for i, s := range []float64{90, 100} {
+		require.NoError(t, db.StoreEffectiveness(schemas.Effectiveness{
+			Time:        day.Add(time.Duration(i) * 384 * time.Second),
+			Epoch:       uint64(100 + i),
+			PoolName:    "pool_a",
+			Attestation: score(s / 100),
+			Score:       score(s),
+			ScoreDelta:  score(s - 98),
+		}))
+		require.NoError(t, db.StoreEffectiveness(schemas.Effectiveness{
+			Time:        day.Add(time.Duration(i) * 384 * time.Second),
+			Epoch:       uint64(100 + i),
+			Attestation: score(0.98),
+			Proposal:    score(0.99),
+			Score:       score(98),
+		}))
+	}
+
+	columns, rows, err := db.GetEpochRows("t_effectiveness", 100, 100, "pool_a")
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	row := make(map[string]interface{})
+	for i, column := range columns {
+		row[column] = rows[0][i]
+	}
+	require.Equal(t, float64(90), row["f_score"])
+	require.Nil(t, row["f_proposal"])
+
+	var nEpochs int64
+	var avgScore, networkScore, scoreDelta float64
+	var proposal sql.NullFloat64
+	require.NoError(t, db.db.QueryRow(
+		"SELECT f_n_epochs, f_score, f_proposal, f_network_score, f_score_delta FROM v_effectiveness_daily WHERE f_day = '2023-11-14' AND f_pool = 'pool_a'").
+		Scan(&nEpochs, &avgScore, &proposal, &networkScore, &scoreDelta))
+	require.Equal(t, int64(2), nEpochs)
+	require.Equal(t, float64(95), avgScore)
+	require.False(t, proposal.Valid)
+	require.Equal(t, float64(98), networkScore)
+	require.Equal(t, float64(-3), scoreDelta)
+
+	require.NoError(t, db.StoreNetworkMetrics(schemas.NetworkStats{
+		Time:                day,
+		Epoch:               100,
+		NOfActiveValidators: 1000,
+		SourceParticipation: 0.99,
+		NOfScheduledBlocks:  32,
+		NOfProposedBlocks:   31,
+		ProposalRate:        31.0 / 32,
+	}))
+	var sourceParticipation, proposalRate float64
+	require.NoError(t, db.db.QueryRow("SELECT f_source_participation, f_proposal_rate FROM t_network_stats WHERE f_epoch = 100").
+		Scan(&sourceParticipation, &proposalRate))
+	require.Equal(t, 0.99, sourceParticipation)
+	require.Equal(t, 31.0/32, proposalRate)
+}
+
+func Test_MaintenanceWindows(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, db.CreateTables())
+
+	window := schemas.MaintenanceWindow{
+		Id:       1,
+		PoolName: "pool_a",
+		Start:    time.Unix(1700000000, 0).UTC(),
+		End:      time.Unix(1700003600, 0).UTC(),
+		Comment:  "upgrade",
+	}
+	require.NoError(t, db.StoreMaintenanceWindow(window))
+	require.NoError(t, db.StoreMaintenanceWindow(schemas.MaintenanceWindow{Id: 2, PoolName: "pool_b", Start: window.Start, End: window.End}))
+
+	windows, err := db.GetMaintenanceWindows()
+	require.NoError(t, err)
+	require.Len(t, windows, 2)
+	require.Equal(t, window.PoolName, windows[0].PoolName)
+	require.True(t, window.Start.Equal(windows[0].Start))
+	require.True(t, window.End.Equal(windows[0].End))
+	require.Equal(t, "upgrade", windows[0].Comment)
+
+	deleted, err := db.DeleteMaintenanceWindow(1)
+	require.NoError(t, err)
+	require.True(t, deleted)
+	deleted, err = db.DeleteMaintenanceWindow(1)
+	require.NoError(t, err)
+	require.False(t, deleted)
+	windows, err = db.GetMaintenanceWindows()
+	require.NoError(t, err)
+	require.Len(t, windows, 1)
+	require.Equal(t, uint64(2), windows[0].Id)
+}
+
+func Test_TagMaintenance(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, db.CreateTables())
+
+	score := func(v float64) *float64 { return &v }
+	day := time.Unix(1700000000, 0).UTC()
+	for i, s := range []float64{20, 100} {
+		require.NoError(t, db.StoreEffectiveness(schemas.Effectiveness{
+			Time:     day,
+			Epoch:    uint64(100 + i),
+			PoolName: "pool_a",
+			Score:    score(s),
+		}))
+	}
+	require.NoError(t, db.StoreMissedAttestations(100, "pool_a", []schemas.MissedAttestation{{Epoch: 100, PoolName: "pool_a", ValIndex: 1, MissType: "missed"}}))
+
+	// The epoch with the low score is left out of the daily average
+	require.NoError(t, db.TagMaintenance(100, "pool_a", true))
+	var avgScore float64
+	require.NoError(t, db.db.QueryRow("SELECT f_score FROM v_effectiveness_daily WHERE f_pool = 'pool_a'").Scan(&avgScore))
+	require.Equal(t, float64(100), avgScore)
+	var tagged int
+	require.NoError(t, db.db.QueryRow("SELECT COUNT(*) FROM t_missed_attestations WHERE f_maintenance").Scan(&tagged))
+	require.Equal(t, 1, tagged)
+
+	require.NoError(t, db.TagMaintenance(100, "pool_a", false))
+	require.NoError(t, db.db.QueryRow("SELECT f_score FROM v_effectiveness_daily WHERE f_pool = 'pool_a'").Scan(&avgScore))
+	require.Equal(t, float64(60), avgScore)
+}
+
+func Test_PoolSLA(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, db.CreateTables())
+
+	for epoch := uint64(100); epoch <= 102; epoch++ {
+		require.NoError(t, db.StoreValidatorPerformance(schemas.ValidatorPerformanceMetrics{
+			Time:               time.Unix(1700000000+int64(epoch)*384, 0),
+			Epoch:              epoch,
+			PoolName:           "pool_a",
+			NOfValidatingKeys:  10,
+			NOfIncorrectSource: epoch - 100,
+			EarnedBalance:      big.NewInt(0),
+			LosedBalance:       big.NewInt(0),
+			EffectiveBalance:   big.NewInt(0),
+		}))
+		require.NoError(t, db.StoreProposalDuties(epoch, "pool_a", 1, 1, 0, 0, 0))
+		require.NoError(t, db.StoreEffectiveness(schemas.Effectiveness{Time: time.Unix(0, 0), Epoch: epoch, PoolName: "pool_a"}))
+	}
+	// The epoch with the most misses was in maintenance
+	require.NoError(t, db.TagMaintenance(102, "pool_a", true))
+
+	sums, err := db.GetPoolSLASums(100, 102)
+	require.NoError(t, err)
+	require.Equal(t, []schemas.PoolSLA{{
+		PoolName:              "pool_a",
+		NOfEpochs:             3,
+		NOfMaintenanceEpochs:  1,
+		NOfAttestationDuties:  20,
+		NOfMissedAttestations: 1,
+		NOfScheduledBlocks:    2,
+		NOfProposedBlocks:     2,
+	}}, sums)
+
+	uptime, target := 95.0, 99.0
+	sla := sums[0]
+	sla.Period, sla.Start = "day", "2023-11-14"
+	sla.AttestationUptime, sla.AttestationTarget, sla.AttestationBreached = &uptime, &target, true
+	require.NoError(t, db.StorePoolSLA(sla))
+	// Replaced when the day is updated
+	require.NoError(t, db.StorePoolSLA(sla))
+	sla.Start = "2023-11-15"
+	require.NoError(t, db.StorePoolSLA(sla))
+
+	slas, err := db.GetPoolSLAs("day", "2023-11-14", "2023-11-14")
+	require.NoError(t, err)
+	require.Equal(t, []schemas.PoolSLA{{
+		Period:                "day",
+		Start:                 "2023-11-14",
+		PoolName:              "pool_a",
+		NOfEpochs:             3,
+		NOfMaintenanceEpochs:  1,
+		NOfAttestationDuties:  20,
+		NOfMissedAttestations: 1,
+		NOfScheduledBlocks:    2,
+		NOfProposedBlocks:     2,
+		AttestationUptime:     &uptime,
+		AttestationTarget:     &target,
+		AttestationBreached:   true,
+	}}, slas)
+
+	slas, err = db.GetPoolSLAs("day", "2023-11-01", "2023-11-30")
+	require.NoError(t, err)
+	require.Len(t, slas, 2)
+	slas, err = db.GetPoolSLAs("week", "2023-11-01", "2023-11-30")
+	require.NoError(t, err)
+	require.Empty(t, slas)
+}
+
+func Test_StoreKeyIssues(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, db.CreateTables())
+
+	valIndex := uint64(7)
+	require.NoError(t, db.StoreKeyIssues([]schemas.KeyIssue{
+		{Epoch: 100, PoolName: "pool_b", PubKey: "0xaa", Kind: schemas.KeyNotFound},
+		{Epoch: 100, PoolName: "pool_a", PubKey: "0xbb", Kind: schemas.KeyExited, ValIndex: &valIndex},
+	}))
+	issues, err := db.GetKeyIssues()
+	require.NoError(t, err)
+	require.Equal(t, []schemas.KeyIssue{
+		{Epoch: 100, PoolName: "pool_a", PubKey: "0xbb", Kind: schemas.KeyExited, ValIndex: &valIndex},
+		{Epoch: 100, PoolName: "pool_b", PubKey: "0xaa", Kind: schemas.KeyNotFound},
+	}, issues)
+
+	// Only the last check is kept
+	require.NoError(t, db.StoreKeyIssues([]schemas.KeyIssue{
+		{Epoch: 325, PoolName: "pool_b", PubKey: "0xaa", Kind: schemas.KeyNotFound},
+	}))
+	issues, err = db.GetKeyIssues()
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	require.Equal(t, uint64(325), issues[0].Epoch)
+}
+
+func Test_StoreTenantPools(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, db.CreateTables())
 
-	epochs, err = db.GetMissingEpochs(200, 4)
+	require.NoError(t, db.StoreTenantPools(map[string]string{"pool_a": "tenant_a", "pool_b": "tenant_b"}))
+	poolTenants, err := db.GetTenantPools()
 	require.NoError(t, err)
-	require.Equal(t, []uint64{198, 199, 200}, epochs)
+	require.Equal(t, map[string]string{"pool_a": "tenant_a", "pool_b": "tenant_b"}, poolTenants)
 
-	epochs, err = db.GetMissingEpochs(200, 0)
+	// The pools of removed tenants are not kept
+	require.NoError(t, db.StoreTenantPools(map[string]string{"pool_b": "tenant_a"}))
+	poolTenants, err = db.GetTenantPools()
 	require.NoError(t, err)
-	require.Equal(t, []uint64{}, epochs)
+	require.Equal(t, map[string]string{"pool_b": "tenant_a"}, poolTenants)
 }
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"database/sql/driver"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// Amount in wei. It can exceed an int64, so it is stored as a decimal string
+// and NULL if nil
+type Wei struct {
+	*big.Int
+}
+
+// Implements driver.Valuer
+func (w Wei) Value() (driver.Value, error) {
+	if w.Int == nil {
+		return nil, nil
+	}
+	return w.Int.String(), nil
+}
+
+// Implements sql.Scanner. Integers and floats are accepted for the rows that
+// were stored before wei columns were text
+func (w *Wei) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		w.Int = nil
+	case int64:
+		w.Int = big.NewInt(v)
+	case float64:
+		w.Int, _ = big.NewFloat(v).Int(nil)
+	case string:
+		return w.setString(v)
+	case []byte:
+		return w.setString(string(v))
+	default:
+		return errors.Errorf("can't scan %T into wei", src)
+	}
+	return nil
+}
+
+func (w *Wei) setString(s string) error {
+	value, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return errors.New("invalid wei amount: " + s)
+	}
+	w.Int = value
+	return nil
+}
+
+// Amount in gwei, which fits an int64, or NULL if nil
+func gwei(amount *big.Int) interface{} {
+	if amount == nil {
+		return nil
+	}
+	return amount.Int64()
+}
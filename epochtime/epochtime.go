@@ -0,0 +1,76 @@
+package epochtime
+
+import (
+	"time"
+)
+
+// Converts between the epochs, slots and wall clock time of a network. Times
+// are returned in UTC, so that they are stored the same way regardless of the
+// timezone of the host.
+type Clock struct {
+	genesis       time.Time
+	slotDuration  time.Duration
+	slotsPerEpoch uint64
+}
+
+func New(genesis time.Time, slotDuration time.Duration, slotsPerEpoch uint64) *Clock {
+	return &Clock{
+		genesis:       genesis.UTC(),
+		slotDuration:  slotDuration,
+		slotsPerEpoch: slotsPerEpoch,
+	}
+}
+
+func (c *Clock) Genesis() time.Time {
+	return c.genesis
+}
+
+func (c *Clock) SlotsPerEpoch() uint64 {
+	return c.slotsPerEpoch
+}
+
+// Start of the slot
+func (c *Clock) SlotStart(slot uint64) time.Time {
+	return c.genesis.Add(time.Duration(slot) * c.slotDuration)
+}
+
+// Start of the first slot of the epoch
+func (c *Clock) EpochStart(epoch uint64) time.Time {
+	return c.SlotStart(c.FirstSlot(epoch))
+}
+
+func (c *Clock) FirstSlot(epoch uint64) uint64 {
+	return epoch * c.slotsPerEpoch
+}
+
+func (c *Clock) LastSlot(epoch uint64) uint64 {
+	return (epoch+1)*c.slotsPerEpoch - 1
+}
+
+func (c *Clock) EpochOfSlot(slot uint64) uint64 {
+	if c.slotsPerEpoch == 0 {
+		return 0
+	}
+	return slot / c.slotsPerEpoch
+}
+
+// Slot that contains the given time, 0 before genesis
+func (c *Clock) SlotAt(t time.Time) uint64 {
+	if c.slotDuration <= 0 || t.Before(c.genesis) {
+		return 0
+	}
+	return uint64(t.Sub(c.genesis) / c.slotDuration)
+}
+
+// Epoch that contains the given time, 0 before genesis
+func (c *Clock) EpochAt(t time.Time) uint64 {
+	return c.EpochOfSlot(c.SlotAt(t))
+}
+
+func (c *Clock) CurrentSlot() uint64 {
+	return c.SlotAt(time.Now())
+}
+
+func (c *Clock) CurrentEpoch() uint64 {
+	return c.EpochAt(time.Now())
+}
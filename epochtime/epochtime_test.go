@@ -0,0 +1,32 @@
+package epochtime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Clock(t *testing.T) {
+	// Mainnet
+	genesis := time.Unix(1606824023, 0).In(time.FixedZone("CET", 3600))
+	clock := New(genesis, 12*time.Second, 32)
+
+	require.Equal(t, time.UTC, clock.EpochStart(0).Location())
+	require.Equal(t, time.Unix(1606824023, 0).UTC(), clock.EpochStart(0))
+	require.Equal(t, time.Unix(1606824023+100*32*12, 0).UTC(), clock.EpochStart(100))
+	require.Equal(t, time.Unix(1606824023+3201*12, 0).UTC(), clock.SlotStart(3201))
+	require.Equal(t, uint64(3200), clock.FirstSlot(100))
+	require.Equal(t, uint64(3231), clock.LastSlot(100))
+	require.Equal(t, uint64(100), clock.EpochOfSlot(3231))
+
+	// The start of the epoch and its last second belong to it
+	require.Equal(t, uint64(100), clock.EpochAt(clock.EpochStart(100)))
+	require.Equal(t, uint64(100), clock.EpochAt(clock.EpochStart(101).Add(-time.Second)))
+	require.Equal(t, uint64(3201), clock.SlotAt(clock.SlotStart(3201).Add(11*time.Second)))
+
+	// Before genesis
+	require.Equal(t, uint64(0), clock.SlotAt(genesis.Add(-time.Hour)))
+	require.Equal(t, uint64(0), clock.EpochAt(genesis.Add(-time.Hour)))
+	require.Greater(t, clock.CurrentEpoch(), uint64(100000))
+}
@@ -0,0 +1,78 @@
+package execution
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	nethttp "net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bilinearlabs/eth-metrics/auth"
+	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/pkg/errors"
+)
+
+// Size of the secret of the json web tokens of the engine api
+const jwtSecretSize = 32
+
+// Authentication of the requests to the execution endpoints
+type Auth struct {
+	// username:password for basic auth
+	Credentials string
+	// Secret of the tokens signed for every request, as for the engine api
+	JwtSecret []byte
+	// Sent as they are, e.g. the api key of a provider
+	Headers map[string]string
+}
+
+// Returns the authentication of the --eth1-* flags. The credentials of the
+// beacon node are used for basic auth if no other authentication is set, as
+// before the flags existed.
+func AuthFromConfig(cfg *config.Config) (Auth, error) {
+	a := Auth{Credentials: cfg.Eth1Credentials}
+	if cfg.Eth1JwtSecret != "" {
+		secret, err := LoadJwtSecret(cfg.Eth1JwtSecret)
+		if err != nil {
+			return Auth{}, err
+		}
+		a.JwtSecret = secret
+	} else if a.Credentials == "" {
+		a.Credentials = cfg.Credentials
+	}
+	a.Headers = cfg.Eth1Headers
+	return a, nil
+}
+
+// Reads a secret of 32 bytes in hex, with or without 0x, as the jwt.hex files
+// of the execution clients
+func LoadJwtSecret(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read jwt secret")
+	}
+	secret, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(data)), "0x"))
+	if err != nil || len(secret) != jwtSecretSize {
+		return nil, errors.New("jwt secret must be 32 bytes in hex: " + path)
+	}
+	return secret, nil
+}
+
+// Sets the headers of a request. The token is signed with the current time,
+// since execution clients reject the ones issued more than a minute apart.
+func (a Auth) setHeaders(h nethttp.Header, now time.Time) error {
+	if a.Credentials != "" {
+		h.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(a.Credentials)))
+	}
+	if len(a.JwtSecret) > 0 {
+		token, err := auth.SignJWT(map[string]int64{"iat": now.Unix()}, a.JwtSecret)
+		if err != nil {
+			return errors.Wrap(err, "could not sign jwt")
+		}
+		h.Set("Authorization", "Bearer "+token)
+	}
+	for name, value := range a.Headers {
+		h.Set(name, value)
+	}
+	return nil
+}
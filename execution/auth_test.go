@@ -0,0 +1,91 @@
+package execution
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bilinearlabs/eth-metrics/auth"
+	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AuthFromConfig(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "jwt.hex")
+	require.NoError(t, os.WriteFile(secretFile, []byte("0x"+strings.Repeat("ab", 32)+"\n"), 0600))
+
+	a, err := AuthFromConfig(&config.Config{
+		Credentials:   "beacon:pass",
+		Eth1JwtSecret: secretFile,
+		Eth1Headers:   map[string]string{"X-Api-Key": "key"},
+	})
+	require.NoError(t, err)
+	// The credentials of the beacon node are not sent with a token
+	require.Empty(t, a.Credentials)
+	require.Len(t, a.JwtSecret, 32)
+	require.Equal(t, map[string]string{"X-Api-Key": "key"}, a.Headers)
+
+	// Without other authentication, as before the --eth1-* flags
+	a, err = AuthFromConfig(&config.Config{Credentials: "beacon:pass"})
+	require.NoError(t, err)
+	require.Equal(t, "beacon:pass", a.Credentials)
+	a, err = AuthFromConfig(&config.Config{Credentials: "beacon:pass", Eth1Credentials: "eth1:pass"})
+	require.NoError(t, err)
+	require.Equal(t, "eth1:pass", a.Credentials)
+
+	require.NoError(t, os.WriteFile(secretFile, []byte("abcd"), 0600))
+	_, err = AuthFromConfig(&config.Config{Eth1JwtSecret: secretFile})
+	require.ErrorContains(t, err, "32 bytes in hex")
+}
+
+func Test_ClientAuth(t *testing.T) {
+	headers := make(chan http.Header, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+		var req struct {
+			Id json.RawMessage `json:"id"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.Id, "result": "0x01"})
+	}))
+	defer server.Close()
+	to := common.HexToAddress("0x5f4eC3Df9cbd43714FE2740f5E3616155c5b8419")
+
+	client, err := NewClient([]string{server.URL}, Auth{Credentials: "user:pass", Headers: map[string]string{"X-Api-Key": "key"}})
+	require.NoError(t, err)
+	_, err = client.CallContract(context.Background(), ethereum.CallMsg{To: &to}, nil)
+	require.NoError(t, err)
+	h := <-headers
+	require.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte("user:pass")), h.Get("Authorization"))
+	require.Equal(t, "key", h.Get("X-Api-Key"))
+
+	secret := []byte(strings.Repeat("s", 32))
+	client, err = NewClient([]string{server.URL}, Auth{JwtSecret: secret})
+	require.NoError(t, err)
+	_, err = client.CallContract(context.Background(), ethereum.CallMsg{To: &to}, nil)
+	require.NoError(t, err)
+	token, found := strings.CutPrefix((<-headers).Get("Authorization"), "Bearer ")
+	require.True(t, found)
+
+	// Signed with the time of the request
+	var claims struct {
+		IssuedAt int64 `json:"iat"`
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[1])
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(payload, &claims))
+	require.InDelta(t, time.Now().Unix(), claims.IssuedAt, 5)
+	expected, err := auth.SignJWT(map[string]int64{"iat": claims.IssuedAt}, secret)
+	require.NoError(t, err)
+	require.Equal(t, expected, token)
+}
@@ -0,0 +1,144 @@
+package execution
+
+import (
+	"context"
+	"math/big"
+	"strconv"
+	"sync"
+	"time"
+
+	nethttp "net/http"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+)
+
+// The endpoints are labeled by their number in --eth1address, since their urls
+// often contain api keys
+var (
+	executionRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ethmetrics_execution_requests_total",
+		Help: "Requests made to each execution endpoint by method",
+	}, []string{"endpoint", "method"})
+	executionRequestErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ethmetrics_execution_request_errors_total",
+		Help: "Requests to each execution endpoint by method that failed",
+	}, []string{"endpoint", "method"})
+	executionRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ethmetrics_execution_request_duration_seconds",
+		Help:    "Latency of the requests to each execution endpoint by method",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"endpoint", "method"})
+)
+
+// Execution client backed by several endpoints. Requests are sent to the last
+// endpoint that worked, and to the next ones in order if it fails.
+type Client struct {
+	clients []*ethclient.Client
+	current int
+	mu      sync.Mutex
+}
+
+func NewClient(addresses []string, auth Auth) (*Client, error) {
+	if len(addresses) == 0 {
+		return nil, errors.New("no execution client address provided")
+	}
+
+	clients := make([]*ethclient.Client, 0, len(addresses))
+	for _, address := range addresses {
+		rpcClient, err := rpc.DialOptions(
+			context.Background(),
+			address,
+			// Called for every request
+			rpc.WithHTTPAuth(func(h nethttp.Header) error {
+				return auth.setHeaders(h, time.Now())
+			}),
+			rpc.WithHTTPClient(&nethttp.Client{Timeout: 60 * time.Second}),
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "error dialing execution client")
+		}
+		clients = append(clients, ethclient.NewClient(rpcClient))
+	}
+
+	return &Client{
+		clients: clients,
+	}, nil
+}
+
+// Runs the request against each endpoint until one succeeds. The error of the
+// last endpoint is returned unwrapped so that rpc errors can be inspected.
+func request[T any](c *Client, method string, f func(*ethclient.Client) (T, error)) (T, error) {
+	c.mu.Lock()
+	first := c.current
+	c.mu.Unlock()
+
+	var result T
+	var err error
+	for i := range c.clients {
+		idx := (first + i) % len(c.clients)
+		start := time.Now()
+		result, err = f(c.clients[idx])
+		endpoint := strconv.Itoa(idx)
+		executionRequestDuration.WithLabelValues(endpoint, method).Observe(time.Since(start).Seconds())
+		executionRequestsTotal.WithLabelValues(endpoint, method).Inc()
+		if err == nil {
+			if idx != first {
+				log.Warn("Switched to execution client number ", idx)
+				c.mu.Lock()
+				c.current = idx
+				c.mu.Unlock()
+			}
+			return result, nil
+		}
+		executionRequestErrorsTotal.WithLabelValues(endpoint, method).Inc()
+		if len(c.clients) > 1 {
+			log.Warnf("error in %s with execution client number %d: %s", method, idx, err)
+		}
+	}
+	return result, err
+}
+
+func (c *Client) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return request(c, "HeaderByNumber", func(client *ethclient.Client) (*types.Header, error) {
+		return client.HeaderByNumber(ctx, number)
+	})
+}
+
+func (c *Client) BlockReceipts(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) ([]*types.Receipt, error) {
+	return request(c, "BlockReceipts", func(client *ethclient.Client) ([]*types.Receipt, error) {
+		return client.BlockReceipts(ctx, blockNrOrHash)
+	})
+}
+
+func (c *Client) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	return request(c, "TransactionReceipt", func(client *ethclient.Client) (*types.Receipt, error) {
+		return client.TransactionReceipt(ctx, txHash)
+	})
+}
+
+func (c *Client) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	return request(c, "BalanceAt", func(client *ethclient.Client) (*big.Int, error) {
+		return client.BalanceAt(ctx, account, blockNumber)
+	})
+}
+
+// Implements ethereum.ContractCaller
+func (c *Client) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return request(c, "CallContract", func(client *ethclient.Client) ([]byte, error) {
+		return client.CallContract(ctx, msg, blockNumber)
+	})
+}
+
+func (c *Client) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	return request(c, "FilterLogs", func(client *ethclient.Client) ([]types.Log, error) {
+		return client.FilterLogs(ctx, query)
+	})
+}
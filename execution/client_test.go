@@ -0,0 +1,75 @@
+package execution
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// Endpoint that answers every eth_call with the given result, or fails if empty
+func newEndpoint(t *testing.T, result string, nOfRequests *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nOfRequests.Add(1)
+		if result == "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var req struct {
+			Id json.RawMessage `json:"id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("error decoding request: %s", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.Id, "result": result})
+	}))
+}
+
+func Test_ClientFailover(t *testing.T) {
+	var nOfRequestsDown, nOfRequestsUp atomic.Int32
+	down := newEndpoint(t, "", &nOfRequestsDown)
+	defer down.Close()
+	up := newEndpoint(t, "0x01", &nOfRequestsUp)
+	defer up.Close()
+
+	client, err := NewClient([]string{down.URL, up.URL}, Auth{Credentials: "user:pass"})
+	require.NoError(t, err)
+	errorsDown := testutil.ToFloat64(executionRequestErrorsTotal.WithLabelValues("0", "CallContract"))
+	requestsUp := testutil.ToFloat64(executionRequestsTotal.WithLabelValues("1", "CallContract"))
+
+	to := common.HexToAddress("0x5f4eC3Df9cbd43714FE2740f5E3616155c5b8419")
+	for range 3 {
+		output, err := client.CallContract(context.Background(), ethereum.CallMsg{To: &to}, nil)
+		require.NoError(t, err)
+		require.Equal(t, []byte{1}, output)
+	}
+	// Once failed, the endpoint that works is used first
+	require.Equal(t, int32(1), nOfRequestsDown.Load())
+	require.Equal(t, int32(3), nOfRequestsUp.Load())
+	require.Equal(t, errorsDown+1, testutil.ToFloat64(executionRequestErrorsTotal.WithLabelValues("0", "CallContract")))
+	require.Equal(t, requestsUp+3, testutil.ToFloat64(executionRequestsTotal.WithLabelValues("1", "CallContract")))
+}
+
+func Test_ClientAllDown(t *testing.T) {
+	var nOfRequests atomic.Int32
+	down := newEndpoint(t, "", &nOfRequests)
+	defer down.Close()
+
+	client, err := NewClient([]string{down.URL, down.URL}, Auth{})
+	require.NoError(t, err)
+
+	_, err = client.CallContract(context.Background(), ethereum.CallMsg{}, nil)
+	require.Error(t, err)
+	require.Equal(t, int32(2), nOfRequests.Load())
+
+	_, err = NewClient([]string{}, Auth{})
+	require.Error(t, err)
+}
@@ -0,0 +1,210 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/bilinearlabs/eth-metrics/networks"
+	"github.com/parquet-go/parquet-go"
+	"github.com/pkg/errors"
+)
+
+// Returns the epoch of the network that contains the given time
+func EpochAt(network string, t time.Time) (uint64, error) {
+	params, err := networks.Get(network)
+	if err != nil {
+		return 0, err
+	}
+	if t.Before(params.GenesisTime) {
+		return 0, nil
+	}
+	return uint64(t.Sub(params.GenesisTime) / params.EpochDuration()), nil
+}
+
+// Returns the first epoch of the network that starts at or after the given
+// time, so that consecutive periods don't share any epoch
+func FirstEpochFrom(network string, t time.Time) (uint64, error) {
+	params, err := networks.Get(network)
+	if err != nil {
+		return 0, err
+	}
+	if !t.After(params.GenesisTime) {
+		return 0, nil
+	}
+	epochDuration := params.EpochDuration()
+	elapsed := t.Sub(params.GenesisTime)
+	epoch := uint64(elapsed / epochDuration)
+	if elapsed%epochDuration != 0 {
+		epoch++
+	}
+	return epoch, nil
+}
+
+// Writes the rows, as returned by the database, in the given format
+func Write(w io.Writer, format string, columns []string, rows [][]interface{}) error {
+	switch format {
+	case "csv":
+		return writeCsv(w, columns, rows)
+	case "json":
+		return json.NewEncoder(w).Encode(ToMaps(columns, rows))
+	case "parquet":
+		return writeParquet(w, columns, rows)
+	default:
+		return errors.New("export format not supported: " + format)
+	}
+}
+
+func ToMaps(columns []string, rows [][]interface{}) []map[string]interface{} {
+	maps := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		rowMap := make(map[string]interface{})
+		for i, column := range columns {
+			rowMap[column] = row[i]
+		}
+		maps = append(maps, rowMap)
+	}
+	return maps
+}
+
+func writeCsv(w io.Writer, columns []string, rows [][]interface{}) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := make([]string, len(row))
+		for i, value := range row {
+			if value != nil {
+				record[i] = fmt.Sprint(value)
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Kind of the values of a column, from the most to the least specific
+type columnKind int
+
+const (
+	kindNull columnKind = iota
+	kindBool
+	kindInt
+	kindTime
+	kindFloat
+	kindString
+)
+
+func valueKind(value interface{}) columnKind {
+	switch value.(type) {
+	case nil:
+		return kindNull
+	case bool:
+		return kindBool
+	case int64:
+		return kindInt
+	case time.Time:
+		return kindTime
+	case float64:
+		return kindFloat
+	default:
+		return kindString
+	}
+}
+
+// SQLite columns have no strict types, so the type of each parquet column is
+// taken from its values, falling back to string if they are mixed
+func columnKinds(columns []string, rows [][]interface{}) []columnKind {
+	kinds := make([]columnKind, len(columns))
+	for _, row := range rows {
+		for i, value := range row {
+			kind := valueKind(value)
+			if kinds[i] == kindNull {
+				kinds[i] = kind
+			} else if kind != kindNull && kind != kinds[i] {
+				// Integers are also valid floats
+				if (kind == kindInt && kinds[i] == kindFloat) || (kind == kindFloat && kinds[i] == kindInt) {
+					kinds[i] = kindFloat
+				} else {
+					kinds[i] = kindString
+				}
+			}
+		}
+	}
+	return kinds
+}
+
+func parquetNode(kind columnKind) parquet.Node {
+	switch kind {
+	case kindBool:
+		return parquet.Optional(parquet.Leaf(parquet.BooleanType))
+	case kindInt:
+		return parquet.Optional(parquet.Int(64))
+	case kindTime:
+		return parquet.Optional(parquet.Timestamp(parquet.Millisecond))
+	case kindFloat:
+		return parquet.Optional(parquet.Leaf(parquet.DoubleType))
+	default:
+		return parquet.Optional(parquet.String())
+	}
+}
+
+func parquetValue(kind columnKind, value interface{}) parquet.Value {
+	if value == nil {
+		return parquet.NullValue()
+	}
+	switch kind {
+	case kindTime:
+		return parquet.ValueOf(value.(time.Time).UnixMilli())
+	case kindFloat:
+		if v, ok := value.(int64); ok {
+			return parquet.ValueOf(float64(v))
+		}
+		return parquet.ValueOf(value)
+	case kindString:
+		return parquet.ValueOf(fmt.Sprint(value))
+	default:
+		return parquet.ValueOf(value)
+	}
+}
+
+func writeParquet(w io.Writer, columns []string, rows [][]interface{}) error {
+	kinds := columnKinds(columns, rows)
+	group := make(parquet.Group)
+	for i, column := range columns {
+		group[column] = parquetNode(kinds[i])
+	}
+	schema := parquet.NewSchema("metrics", group)
+
+	// The columns of a parquet group are sorted by name
+	columnIndexes := make(map[string]int)
+	for i, path := range schema.Columns() {
+		columnIndexes[path[0]] = i
+	}
+
+	parquetRows := make([]parquet.Row, 0, len(rows))
+	for _, row := range rows {
+		parquetRow := make(parquet.Row, len(columns))
+		for i, value := range row {
+			index := columnIndexes[columns[i]]
+			definitionLevel := 1
+			if value == nil {
+				definitionLevel = 0
+			}
+			parquetRow[index] = parquetValue(kinds[i], value).Level(0, definitionLevel, index)
+		}
+		parquetRows = append(parquetRows, parquetRow)
+	}
+
+	writer := parquet.NewWriter(w, schema)
+	if _, err := writer.WriteRows(parquetRows); err != nil {
+		return errors.Wrap(err, "could not write parquet rows")
+	}
+	return writer.Close()
+}
@@ -0,0 +1,96 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/require"
+)
+
+var testColumns = []string{"f_epoch", "f_pool", "f_ratio", "f_timestamp", "f_vanilla"}
+
+var testRows = [][]interface{}{
+	{int64(10), "pool_a", float64(0.5), time.Unix(1700000000, 0).UTC(), true},
+	{int64(11), nil, int64(1), time.Unix(1700000384, 0).UTC(), false},
+}
+
+func Test_WriteCsv(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, "csv", testColumns, testRows))
+	require.Equal(t, "f_epoch,f_pool,f_ratio,f_timestamp,f_vanilla\n"+
+		"10,pool_a,0.5,2023-11-14 22:13:20 +0000 UTC,true\n"+
+		"11,,1,2023-11-14 22:19:44 +0000 UTC,false\n", buf.String())
+}
+
+func Test_WriteJson(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, "json", testColumns[:2], [][]interface{}{{int64(10), "pool_a"}}))
+	require.Equal(t, `[{"f_epoch":10,"f_pool":"pool_a"}]`+"\n", buf.String())
+
+	require.Error(t, Write(&buf, "xml", testColumns, testRows))
+}
+
+func Test_WriteParquet(t *testing.T) {
+	// Parquet sorts the columns by name
+	columns := []string{"f_vanilla", "f_timestamp", "f_ratio", "f_pool", "f_epoch"}
+	rows := make([][]interface{}, len(testRows))
+	for i, row := range testRows {
+		rows[i] = []interface{}{row[4], row[3], row[2], row[1], row[0]}
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, "parquet", columns, rows))
+
+	type row struct {
+		Epoch     int64     `parquet:"f_epoch"`
+		Pool      *string   `parquet:"f_pool"`
+		Ratio     float64   `parquet:"f_ratio"`
+		Timestamp time.Time `parquet:"f_timestamp,timestamp(millisecond)"`
+		Vanilla   bool      `parquet:"f_vanilla"`
+	}
+	read, err := parquet.Read[row](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	require.Len(t, read, 2)
+
+	require.Equal(t, int64(10), read[0].Epoch)
+	require.Equal(t, "pool_a", *read[0].Pool)
+	require.Equal(t, 0.5, read[0].Ratio)
+	require.Equal(t, int64(1700000000), read[0].Timestamp.Unix())
+	require.True(t, read[0].Vanilla)
+
+	require.Nil(t, read[1].Pool)
+	require.Equal(t, float64(1), read[1].Ratio)
+	require.False(t, read[1].Vanilla)
+}
+
+func Test_EpochAt(t *testing.T) {
+	epoch, err := EpochAt("ethereum", time.Unix(1606824023+384*100+10, 0))
+	require.NoError(t, err)
+	require.Equal(t, uint64(100), epoch)
+
+	epoch, err = EpochAt("ethereum", time.Unix(0, 0))
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), epoch)
+
+	_, err = EpochAt("unknown", time.Now())
+	require.Error(t, err)
+}
+
+func Test_FirstEpochFrom(t *testing.T) {
+	epoch, err := FirstEpochFrom("ethereum", time.Unix(1606824023+384*100, 0))
+	require.NoError(t, err)
+	require.Equal(t, uint64(100), epoch)
+
+	epoch, err = FirstEpochFrom("ethereum", time.Unix(1606824023+384*100+10, 0))
+	require.NoError(t, err)
+	require.Equal(t, uint64(101), epoch)
+
+	epoch, err = FirstEpochFrom("ethereum", time.Unix(0, 0))
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), epoch)
+
+	_, err = FirstEpochFrom("unknown", time.Now())
+	require.Error(t, err)
+}
@@ -0,0 +1,376 @@
+package forkdata
+
+import (
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/electra"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/prysmaticlabs/go-bitfield"
+)
+
+// Attestation included in a block, with the committees it aggregates. Before
+// electra an attestation is for the committee of its data, and since electra
+// for the ones set in its committee bits.
+type Attestation struct {
+	Data             *phase0.AttestationData
+	AggregationBits  bitfield.Bitlist
+	CommitteeIndexes []uint64
+}
+
+func ProposerIndex(block *spec.VersionedSignedBeaconBlock) (uint64, error) {
+	switch {
+	case block.Phase0 != nil:
+		return uint64(block.Phase0.Message.ProposerIndex), nil
+	case block.Altair != nil:
+		return uint64(block.Altair.Message.ProposerIndex), nil
+	case block.Bellatrix != nil:
+		return uint64(block.Bellatrix.Message.ProposerIndex), nil
+	case block.Capella != nil:
+		return uint64(block.Capella.Message.ProposerIndex), nil
+	case block.Deneb != nil:
+		return uint64(block.Deneb.Message.ProposerIndex), nil
+	case block.Electra != nil:
+		return uint64(block.Electra.Message.ProposerIndex), nil
+	case block.Fulu != nil:
+		return uint64(block.Fulu.Message.ProposerIndex), nil
+	}
+	return 0, ErrEmptyBlock
+}
+
+func Graffiti(block *spec.VersionedSignedBeaconBlock) ([32]byte, error) {
+	switch {
+	case block.Phase0 != nil:
+		return block.Phase0.Message.Body.Graffiti, nil
+	case block.Altair != nil:
+		return block.Altair.Message.Body.Graffiti, nil
+	case block.Bellatrix != nil:
+		return block.Bellatrix.Message.Body.Graffiti, nil
+	case block.Capella != nil:
+		return block.Capella.Message.Body.Graffiti, nil
+	case block.Deneb != nil:
+		return block.Deneb.Message.Body.Graffiti, nil
+	case block.Electra != nil:
+		return block.Electra.Message.Body.Graffiti, nil
+	case block.Fulu != nil:
+		return block.Fulu.Message.Body.Graffiti, nil
+	}
+	return [32]byte{}, ErrEmptyBlock
+}
+
+func ProposerSlashings(block *spec.VersionedSignedBeaconBlock) ([]*phase0.ProposerSlashing, error) {
+	switch {
+	case block.Phase0 != nil:
+		return block.Phase0.Message.Body.ProposerSlashings, nil
+	case block.Altair != nil:
+		return block.Altair.Message.Body.ProposerSlashings, nil
+	case block.Bellatrix != nil:
+		return block.Bellatrix.Message.Body.ProposerSlashings, nil
+	case block.Capella != nil:
+		return block.Capella.Message.Body.ProposerSlashings, nil
+	case block.Deneb != nil:
+		return block.Deneb.Message.Body.ProposerSlashings, nil
+	case block.Electra != nil:
+		return block.Electra.Message.Body.ProposerSlashings, nil
+	case block.Fulu != nil:
+		return block.Fulu.Message.Body.ProposerSlashings, nil
+	}
+	return nil, ErrEmptyBlock
+}
+
+// Returns the attesting indexes of both conflicting attestations of each
+// attester slashing of the block
+func AttesterSlashingIndexes(block *spec.VersionedSignedBeaconBlock) ([][2][]uint64, error) {
+	switch {
+	case block.Phase0 != nil:
+		return phase0SlashingIndexes(block.Phase0.Message.Body.AttesterSlashings), nil
+	case block.Altair != nil:
+		return phase0SlashingIndexes(block.Altair.Message.Body.AttesterSlashings), nil
+	case block.Bellatrix != nil:
+		return phase0SlashingIndexes(block.Bellatrix.Message.Body.AttesterSlashings), nil
+	case block.Capella != nil:
+		return phase0SlashingIndexes(block.Capella.Message.Body.AttesterSlashings), nil
+	case block.Deneb != nil:
+		return phase0SlashingIndexes(block.Deneb.Message.Body.AttesterSlashings), nil
+	case block.Electra != nil:
+		return electraSlashingIndexes(block.Electra.Message.Body.AttesterSlashings), nil
+	case block.Fulu != nil:
+		return electraSlashingIndexes(block.Fulu.Message.Body.AttesterSlashings), nil
+	}
+	return nil, ErrEmptyBlock
+}
+
+func phase0SlashingIndexes(slashings []*phase0.AttesterSlashing) [][2][]uint64 {
+	indexes := make([][2][]uint64, 0, len(slashings))
+	for _, slashing := range slashings {
+		indexes = append(indexes, [2][]uint64{slashing.Attestation1.AttestingIndices, slashing.Attestation2.AttestingIndices})
+	}
+	return indexes
+}
+
+func electraSlashingIndexes(slashings []*electra.AttesterSlashing) [][2][]uint64 {
+	indexes := make([][2][]uint64, 0, len(slashings))
+	for _, slashing := range slashings {
+		indexes = append(indexes, [2][]uint64{slashing.Attestation1.AttestingIndices, slashing.Attestation2.AttestingIndices})
+	}
+	return indexes
+}
+
+func Attestations(block *spec.VersionedSignedBeaconBlock) ([]Attestation, error) {
+	switch {
+	case block.Phase0 != nil:
+		return phase0Attestations(block.Phase0.Message.Body.Attestations), nil
+	case block.Altair != nil:
+		return phase0Attestations(block.Altair.Message.Body.Attestations), nil
+	case block.Bellatrix != nil:
+		return phase0Attestations(block.Bellatrix.Message.Body.Attestations), nil
+	case block.Capella != nil:
+		return phase0Attestations(block.Capella.Message.Body.Attestations), nil
+	case block.Deneb != nil:
+		return phase0Attestations(block.Deneb.Message.Body.Attestations), nil
+	case block.Electra != nil:
+		return electraAttestations(block.Electra.Message.Body.Attestations), nil
+	case block.Fulu != nil:
+		return electraAttestations(block.Fulu.Message.Body.Attestations), nil
+	}
+	return nil, ErrEmptyBlock
+}
+
+func phase0Attestations(phase0Atts []*phase0.Attestation) []Attestation {
+	attestations := make([]Attestation, 0, len(phase0Atts))
+	for _, att := range phase0Atts {
+		attestations = append(attestations, Attestation{
+			Data:             att.Data,
+			AggregationBits:  att.AggregationBits,
+			CommitteeIndexes: []uint64{uint64(att.Data.Index)},
+		})
+	}
+	return attestations
+}
+
+func electraAttestations(electraAtts []*electra.Attestation) []Attestation {
+	attestations := make([]Attestation, 0, len(electraAtts))
+	for _, att := range electraAtts {
+		committeeIndexes := make([]uint64, 0)
+		for _, idx := range att.CommitteeBits.BitIndices() {
+			committeeIndexes = append(committeeIndexes, uint64(idx))
+		}
+		attestations = append(attestations, Attestation{
+			Data:             att.Data,
+			AggregationBits:  att.AggregationBits,
+			CommitteeIndexes: committeeIndexes,
+		})
+	}
+	return attestations
+}
+
+func VoluntaryExits(block *spec.VersionedSignedBeaconBlock) ([]*phase0.SignedVoluntaryExit, error) {
+	switch {
+	case block.Phase0 != nil:
+		return block.Phase0.Message.Body.VoluntaryExits, nil
+	case block.Altair != nil:
+		return block.Altair.Message.Body.VoluntaryExits, nil
+	case block.Bellatrix != nil:
+		return block.Bellatrix.Message.Body.VoluntaryExits, nil
+	case block.Capella != nil:
+		return block.Capella.Message.Body.VoluntaryExits, nil
+	case block.Deneb != nil:
+		return block.Deneb.Message.Body.VoluntaryExits, nil
+	case block.Electra != nil:
+		return block.Electra.Message.Body.VoluntaryExits, nil
+	case block.Fulu != nil:
+		return block.Fulu.Message.Body.VoluntaryExits, nil
+	}
+	return nil, ErrEmptyBlock
+}
+
+// BLS to execution changes exist since capella
+func BLSToExecutionChanges(block *spec.VersionedSignedBeaconBlock) ([]*capella.SignedBLSToExecutionChange, error) {
+	switch {
+	case block.Phase0 != nil, block.Altair != nil, block.Bellatrix != nil:
+		return []*capella.SignedBLSToExecutionChange{}, nil
+	case block.Capella != nil:
+		return block.Capella.Message.Body.BLSToExecutionChanges, nil
+	case block.Deneb != nil:
+		return block.Deneb.Message.Body.BLSToExecutionChanges, nil
+	case block.Electra != nil:
+		return block.Electra.Message.Body.BLSToExecutionChanges, nil
+	case block.Fulu != nil:
+		return block.Fulu.Message.Body.BLSToExecutionChanges, nil
+	}
+	return nil, ErrEmptyBlock
+}
+
+// Withdrawals exist since capella
+func Withdrawals(block *spec.VersionedSignedBeaconBlock) ([]*capella.Withdrawal, error) {
+	switch {
+	case block.Phase0 != nil, block.Altair != nil, block.Bellatrix != nil:
+		return []*capella.Withdrawal{}, nil
+	case block.Capella != nil:
+		return block.Capella.Message.Body.ExecutionPayload.Withdrawals, nil
+	case block.Deneb != nil:
+		return block.Deneb.Message.Body.ExecutionPayload.Withdrawals, nil
+	case block.Electra != nil:
+		return block.Electra.Message.Body.ExecutionPayload.Withdrawals, nil
+	case block.Fulu != nil:
+		return block.Fulu.Message.Body.ExecutionPayload.Withdrawals, nil
+	}
+	return nil, ErrEmptyBlock
+}
+
+// Returns whether the block has execution requests, which exist since electra.
+// Before, deposits are only in the logs of the deposit contract.
+func HasExecutionRequests(block *spec.VersionedSignedBeaconBlock) (bool, error) {
+	fork, err := BlockFork(block)
+	if err != nil {
+		return false, err
+	}
+	return fork >= spec.DataVersionElectra, nil
+}
+
+// Execution requests exist since electra, and are empty before
+func ExecutionRequests(block *spec.VersionedSignedBeaconBlock) (*electra.ExecutionRequests, error) {
+	switch {
+	case block.Phase0 != nil, block.Altair != nil, block.Bellatrix != nil, block.Capella != nil, block.Deneb != nil:
+		return &electra.ExecutionRequests{}, nil
+	case block.Electra != nil:
+		return block.Electra.Message.Body.ExecutionRequests, nil
+	case block.Fulu != nil:
+		return block.Fulu.Message.Body.ExecutionRequests, nil
+	}
+	return nil, ErrEmptyBlock
+}
+
+// The execution payload exists since bellatrix, so the transactions of older
+// blocks are empty and their other payload fields are an error
+func Transactions(block *spec.VersionedSignedBeaconBlock) ([]bellatrix.Transaction, error) {
+	switch {
+	case block.Phase0 != nil, block.Altair != nil:
+		return []bellatrix.Transaction{}, nil
+	case block.Bellatrix != nil:
+		return block.Bellatrix.Message.Body.ExecutionPayload.Transactions, nil
+	case block.Capella != nil:
+		return block.Capella.Message.Body.ExecutionPayload.Transactions, nil
+	case block.Deneb != nil:
+		return block.Deneb.Message.Body.ExecutionPayload.Transactions, nil
+	case block.Electra != nil:
+		return block.Electra.Message.Body.ExecutionPayload.Transactions, nil
+	case block.Fulu != nil:
+		return block.Fulu.Message.Body.ExecutionPayload.Transactions, nil
+	}
+	return nil, ErrEmptyBlock
+}
+
+func BlockNumber(block *spec.VersionedSignedBeaconBlock) (uint64, error) {
+	switch {
+	case block.Phase0 != nil:
+		return 0, notInFork("block number", spec.DataVersionPhase0)
+	case block.Altair != nil:
+		return 0, notInFork("block number", spec.DataVersionAltair)
+	case block.Bellatrix != nil:
+		return block.Bellatrix.Message.Body.ExecutionPayload.BlockNumber, nil
+	case block.Capella != nil:
+		return block.Capella.Message.Body.ExecutionPayload.BlockNumber, nil
+	case block.Deneb != nil:
+		return block.Deneb.Message.Body.ExecutionPayload.BlockNumber, nil
+	case block.Electra != nil:
+		return block.Electra.Message.Body.ExecutionPayload.BlockNumber, nil
+	case block.Fulu != nil:
+		return block.Fulu.Message.Body.ExecutionPayload.BlockNumber, nil
+	}
+	return 0, ErrEmptyBlock
+}
+
+func FeeRecipient(block *spec.VersionedSignedBeaconBlock) (bellatrix.ExecutionAddress, error) {
+	switch {
+	case block.Phase0 != nil:
+		return bellatrix.ExecutionAddress{}, notInFork("fee recipient", spec.DataVersionPhase0)
+	case block.Altair != nil:
+		return bellatrix.ExecutionAddress{}, notInFork("fee recipient", spec.DataVersionAltair)
+	case block.Bellatrix != nil:
+		return block.Bellatrix.Message.Body.ExecutionPayload.FeeRecipient, nil
+	case block.Capella != nil:
+		return block.Capella.Message.Body.ExecutionPayload.FeeRecipient, nil
+	case block.Deneb != nil:
+		return block.Deneb.Message.Body.ExecutionPayload.FeeRecipient, nil
+	case block.Electra != nil:
+		return block.Electra.Message.Body.ExecutionPayload.FeeRecipient, nil
+	case block.Fulu != nil:
+		return block.Fulu.Message.Body.ExecutionPayload.FeeRecipient, nil
+	}
+	return bellatrix.ExecutionAddress{}, ErrEmptyBlock
+}
+
+// Returns the base fee per gas in big endian. Before deneb the payload has it
+// in little endian.
+func BaseFeePerGas(block *spec.VersionedSignedBeaconBlock) ([32]byte, error) {
+	switch {
+	case block.Phase0 != nil:
+		return [32]byte{}, notInFork("base fee per gas", spec.DataVersionPhase0)
+	case block.Altair != nil:
+		return [32]byte{}, notInFork("base fee per gas", spec.DataVersionAltair)
+	case block.Bellatrix != nil:
+		return reverse(block.Bellatrix.Message.Body.ExecutionPayload.BaseFeePerGas), nil
+	case block.Capella != nil:
+		return reverse(block.Capella.Message.Body.ExecutionPayload.BaseFeePerGas), nil
+	case block.Deneb != nil:
+		return block.Deneb.Message.Body.ExecutionPayload.BaseFeePerGas.Bytes32(), nil
+	case block.Electra != nil:
+		return block.Electra.Message.Body.ExecutionPayload.BaseFeePerGas.Bytes32(), nil
+	case block.Fulu != nil:
+		return block.Fulu.Message.Body.ExecutionPayload.BaseFeePerGas.Bytes32(), nil
+	}
+	return [32]byte{}, ErrEmptyBlock
+}
+
+func reverse(littleEndian [32]byte) [32]byte {
+	var bigEndian [32]byte
+	for i := range 32 {
+		bigEndian[i] = littleEndian[32-1-i]
+	}
+	return bigEndian
+}
+
+func GasUsed(block *spec.VersionedSignedBeaconBlock) (uint64, error) {
+	switch {
+	case block.Phase0 != nil:
+		return 0, notInFork("gas used", spec.DataVersionPhase0)
+	case block.Altair != nil:
+		return 0, notInFork("gas used", spec.DataVersionAltair)
+	case block.Bellatrix != nil:
+		return block.Bellatrix.Message.Body.ExecutionPayload.GasUsed, nil
+	case block.Capella != nil:
+		return block.Capella.Message.Body.ExecutionPayload.GasUsed, nil
+	case block.Deneb != nil:
+		return block.Deneb.Message.Body.ExecutionPayload.GasUsed, nil
+	case block.Electra != nil:
+		return block.Electra.Message.Body.ExecutionPayload.GasUsed, nil
+	case block.Fulu != nil:
+		return block.Fulu.Message.Body.ExecutionPayload.GasUsed, nil
+	}
+	return 0, ErrEmptyBlock
+}
+
+// Blob gas exists since deneb
+func BlobGas(block *spec.VersionedSignedBeaconBlock) (blobGasUsed uint64, excessBlobGas uint64, err error) {
+	switch {
+	case block.Phase0 != nil:
+		return 0, 0, notInFork("blob gas", spec.DataVersionPhase0)
+	case block.Altair != nil:
+		return 0, 0, notInFork("blob gas", spec.DataVersionAltair)
+	case block.Bellatrix != nil:
+		return 0, 0, notInFork("blob gas", spec.DataVersionBellatrix)
+	case block.Capella != nil:
+		return 0, 0, notInFork("blob gas", spec.DataVersionCapella)
+	case block.Deneb != nil:
+		payload := block.Deneb.Message.Body.ExecutionPayload
+		return payload.BlobGasUsed, payload.ExcessBlobGas, nil
+	case block.Electra != nil:
+		payload := block.Electra.Message.Body.ExecutionPayload
+		return payload.BlobGasUsed, payload.ExcessBlobGas, nil
+	case block.Fulu != nil:
+		payload := block.Fulu.Message.Body.ExecutionPayload
+		return payload.BlobGasUsed, payload.ExcessBlobGas, nil
+	}
+	return 0, 0, ErrEmptyBlock
+}
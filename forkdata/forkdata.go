@@ -0,0 +1,88 @@
+// Package forkdata reads the fields of the versioned blocks and states of the
+// beacon api regardless of their fork. Each accessor handles every fork that
+// go-eth2-client knows, so supporting a new fork only takes adding its case
+// here. Lists that don't exist yet in a fork, e.g. the withdrawals before
+// capella, are returned empty, while other missing fields are an error.
+package forkdata
+
+import (
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/pkg/errors"
+)
+
+// Forks with a case in every accessor, in order. Fulu is the last one in the
+// go-eth2-client version in use.
+var Forks = []spec.DataVersion{
+	spec.DataVersionPhase0,
+	spec.DataVersionAltair,
+	spec.DataVersionBellatrix,
+	spec.DataVersionCapella,
+	spec.DataVersionDeneb,
+	spec.DataVersionElectra,
+	spec.DataVersionFulu,
+}
+
+var (
+	ErrEmptyBlock = errors.New("beacon block has no data of a known fork")
+	ErrEmptyState = errors.New("beacon state has no data of a known fork")
+)
+
+// Returns the fork of the block from the data it has, since blocks built by
+// hand, e.g. in tests, may not set the version
+func BlockFork(block *spec.VersionedSignedBeaconBlock) (spec.DataVersion, error) {
+	switch {
+	case block.Phase0 != nil:
+		return spec.DataVersionPhase0, nil
+	case block.Altair != nil:
+		return spec.DataVersionAltair, nil
+	case block.Bellatrix != nil:
+		return spec.DataVersionBellatrix, nil
+	case block.Capella != nil:
+		return spec.DataVersionCapella, nil
+	case block.Deneb != nil:
+		return spec.DataVersionDeneb, nil
+	case block.Electra != nil:
+		return spec.DataVersionElectra, nil
+	case block.Fulu != nil:
+		return spec.DataVersionFulu, nil
+	}
+	return spec.DataVersionUnknown, ErrEmptyBlock
+}
+
+// Returns the fork of the state from the data it has
+func StateFork(state *spec.VersionedBeaconState) (spec.DataVersion, error) {
+	switch {
+	case state.Phase0 != nil:
+		return spec.DataVersionPhase0, nil
+	case state.Altair != nil:
+		return spec.DataVersionAltair, nil
+	case state.Bellatrix != nil:
+		return spec.DataVersionBellatrix, nil
+	case state.Capella != nil:
+		return spec.DataVersionCapella, nil
+	case state.Deneb != nil:
+		return spec.DataVersionDeneb, nil
+	case state.Electra != nil:
+		return spec.DataVersionElectra, nil
+	case state.Fulu != nil:
+		return spec.DataVersionFulu, nil
+	}
+	return spec.DataVersionUnknown, ErrEmptyState
+}
+
+// Returns an error if the metrics can't be computed from the state, which
+// needs the participation flags that exist since altair
+func CheckState(state *spec.VersionedBeaconState) error {
+	fork, err := StateFork(state)
+	if err != nil {
+		return err
+	}
+	if fork == spec.DataVersionPhase0 {
+		return errors.New("phase0 beacon states are not supported")
+	}
+	return nil
+}
+
+func notInFork(what string, fork spec.DataVersion) error {
+	return errors.Errorf("%s has no %s", fork, what)
+}
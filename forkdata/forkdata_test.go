@@ -0,0 +1,454 @@
+package forkdata
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/altair"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/deneb"
+	"github.com/attestantio/go-eth2-client/spec/electra"
+	"github.com/attestantio/go-eth2-client/spec/fulu"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/holiman/uint256"
+	bitfield "github.com/prysmaticlabs/go-bitfield"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	graffiti     = [32]byte{'p', 'o', 'o', 'l'}
+	feeRecipient = bellatrix.ExecutionAddress{0xfe}
+	baseFee      = uint256.NewInt(7_000_000_000)
+)
+
+var testBody = struct {
+	proposerSlashings []*phase0.ProposerSlashing
+	attesterSlashings []*phase0.AttesterSlashing
+	attestations      []*phase0.Attestation
+	exits             []*phase0.SignedVoluntaryExit
+	blsChanges        []*capella.SignedBLSToExecutionChange
+	withdrawals       []*capella.Withdrawal
+	transactions      []bellatrix.Transaction
+}{
+	proposerSlashings: []*phase0.ProposerSlashing{{
+		SignedHeader1: &phase0.SignedBeaconBlockHeader{Message: &phase0.BeaconBlockHeader{ProposerIndex: 11}},
+	}},
+	attesterSlashings: []*phase0.AttesterSlashing{{
+		Attestation1: &phase0.IndexedAttestation{AttestingIndices: []uint64{1, 2}},
+		Attestation2: &phase0.IndexedAttestation{AttestingIndices: []uint64{2, 3}},
+	}},
+	attestations: []*phase0.Attestation{{
+		AggregationBits: bitfield.Bitlist{0x03},
+		Data:            &phase0.AttestationData{Slot: 99, Index: 4},
+	}},
+	exits: []*phase0.SignedVoluntaryExit{{
+		Message: &phase0.VoluntaryExit{ValidatorIndex: 12},
+	}},
+	blsChanges: []*capella.SignedBLSToExecutionChange{{
+		Message: &capella.BLSToExecutionChange{ValidatorIndex: 13},
+	}},
+	withdrawals: []*capella.Withdrawal{{
+		ValidatorIndex: 14, Amount: 32,
+	}},
+	transactions: []bellatrix.Transaction{{0x02}},
+}
+
+// Builds a block of the given fork with the same content, as far as the fork
+// has it
+func testBlock(fork spec.DataVersion) *spec.VersionedSignedBeaconBlock {
+	electraSlashings := []*electra.AttesterSlashing{{
+		Attestation1: &electra.IndexedAttestation{AttestingIndices: []uint64{1, 2}},
+		Attestation2: &electra.IndexedAttestation{AttestingIndices: []uint64{2, 3}},
+	}}
+	committeeBits := bitfield.NewBitvector64()
+	committeeBits.SetBitAt(4, true)
+	electraAttestations := []*electra.Attestation{{
+		AggregationBits: bitfield.Bitlist{0x03},
+		Data:            &phase0.AttestationData{Slot: 99},
+		CommitteeBits:   committeeBits,
+	}}
+	requests := &electra.ExecutionRequests{
+		Deposits: []*electra.DepositRequest{{Amount: 32_000_000_000}},
+	}
+	bellatrixPayload := &bellatrix.ExecutionPayload{
+		BlockNumber:   100,
+		GasUsed:       21000,
+		FeeRecipient:  feeRecipient,
+		Transactions:  testBody.transactions,
+		BaseFeePerGas: reverse(baseFee.Bytes32()),
+	}
+	capellaPayload := &capella.ExecutionPayload{
+		BlockNumber:   100,
+		GasUsed:       21000,
+		FeeRecipient:  feeRecipient,
+		Transactions:  testBody.transactions,
+		BaseFeePerGas: reverse(baseFee.Bytes32()),
+		Withdrawals:   testBody.withdrawals,
+	}
+	denebPayload := &deneb.ExecutionPayload{
+		BlockNumber:   100,
+		GasUsed:       21000,
+		FeeRecipient:  feeRecipient,
+		Transactions:  testBody.transactions,
+		BaseFeePerGas: baseFee,
+		Withdrawals:   testBody.withdrawals,
+		BlobGasUsed:   131072,
+		ExcessBlobGas: 393216,
+	}
+	electraBlock := &electra.SignedBeaconBlock{
+		Message: &electra.BeaconBlock{
+			ProposerIndex: 7,
+			Body: &electra.BeaconBlockBody{
+				Graffiti:              graffiti,
+				ProposerSlashings:     testBody.proposerSlashings,
+				AttesterSlashings:     electraSlashings,
+				Attestations:          electraAttestations,
+				VoluntaryExits:        testBody.exits,
+				BLSToExecutionChanges: testBody.blsChanges,
+				ExecutionPayload:      denebPayload,
+				ExecutionRequests:     requests,
+			},
+		},
+	}
+
+	switch fork {
+	case spec.DataVersionPhase0:
+		return &spec.VersionedSignedBeaconBlock{Phase0: &phase0.SignedBeaconBlock{
+			Message: &phase0.BeaconBlock{
+				ProposerIndex: 7,
+				Body: &phase0.BeaconBlockBody{
+					Graffiti:          graffiti,
+					ProposerSlashings: testBody.proposerSlashings,
+					AttesterSlashings: testBody.attesterSlashings,
+					Attestations:      testBody.attestations,
+					VoluntaryExits:    testBody.exits,
+				},
+			},
+		}}
+	case spec.DataVersionAltair:
+		return &spec.VersionedSignedBeaconBlock{Altair: &altair.SignedBeaconBlock{
+			Message: &altair.BeaconBlock{
+				ProposerIndex: 7,
+				Body: &altair.BeaconBlockBody{
+					Graffiti:          graffiti,
+					ProposerSlashings: testBody.proposerSlashings,
+					AttesterSlashings: testBody.attesterSlashings,
+					Attestations:      testBody.attestations,
+					VoluntaryExits:    testBody.exits,
+				},
+			},
+		}}
+	case spec.DataVersionBellatrix:
+		return &spec.VersionedSignedBeaconBlock{Bellatrix: &bellatrix.SignedBeaconBlock{
+			Message: &bellatrix.BeaconBlock{
+				ProposerIndex: 7,
+				Body: &bellatrix.BeaconBlockBody{
+					Graffiti:          graffiti,
+					ProposerSlashings: testBody.proposerSlashings,
+					AttesterSlashings: testBody.attesterSlashings,
+					Attestations:      testBody.attestations,
+					VoluntaryExits:    testBody.exits,
+					ExecutionPayload:  bellatrixPayload,
+				},
+			},
+		}}
+	case spec.DataVersionCapella:
+		return &spec.VersionedSignedBeaconBlock{Capella: &capella.SignedBeaconBlock{
+			Message: &capella.BeaconBlock{
+				ProposerIndex: 7,
+				Body: &capella.BeaconBlockBody{
+					Graffiti:              graffiti,
+					ProposerSlashings:     testBody.proposerSlashings,
+					AttesterSlashings:     testBody.attesterSlashings,
+					Attestations:          testBody.attestations,
+					VoluntaryExits:        testBody.exits,
+					BLSToExecutionChanges: testBody.blsChanges,
+					ExecutionPayload:      capellaPayload,
+				},
+			},
+		}}
+	case spec.DataVersionDeneb:
+		return &spec.VersionedSignedBeaconBlock{Deneb: &deneb.SignedBeaconBlock{
+			Message: &deneb.BeaconBlock{
+				ProposerIndex: 7,
+				Body: &deneb.BeaconBlockBody{
+					Graffiti:              graffiti,
+					ProposerSlashings:     testBody.proposerSlashings,
+					AttesterSlashings:     testBody.attesterSlashings,
+					Attestations:          testBody.attestations,
+					VoluntaryExits:        testBody.exits,
+					BLSToExecutionChanges: testBody.blsChanges,
+					ExecutionPayload:      denebPayload,
+				},
+			},
+		}}
+	case spec.DataVersionElectra:
+		return &spec.VersionedSignedBeaconBlock{Electra: electraBlock}
+	case spec.DataVersionFulu:
+		return &spec.VersionedSignedBeaconBlock{Fulu: electraBlock}
+	}
+	return nil
+}
+
+func testState(fork spec.DataVersion) *spec.VersionedBeaconState {
+	validators := []*phase0.Validator{{EffectiveBalance: 32_000_000_000}}
+	balances := []phase0.Gwei{32_000_000_001}
+	participation := []altair.ParticipationFlags{7}
+	syncCommittee := &altair.SyncCommittee{Pubkeys: []phase0.BLSPubKey{{0x01}}}
+	pendingDeposits := []*electra.PendingDeposit{{Amount: 1_000_000_000}}
+	pendingWithdrawals := []*electra.PendingPartialWithdrawal{{ValidatorIndex: 3}}
+	pendingConsolidations := []*electra.PendingConsolidation{{SourceIndex: 4, TargetIndex: 5}}
+
+	switch fork {
+	case spec.DataVersionPhase0:
+		return &spec.VersionedBeaconState{Phase0: &phase0.BeaconState{
+			Slot: 320, Validators: validators, Balances: balances,
+		}}
+	case spec.DataVersionAltair:
+		return &spec.VersionedBeaconState{Altair: &altair.BeaconState{
+			Slot: 320, Validators: validators, Balances: balances,
+			PreviousEpochParticipation: participation, CurrentSyncCommittee: syncCommittee,
+		}}
+	case spec.DataVersionBellatrix:
+		return &spec.VersionedBeaconState{Bellatrix: &bellatrix.BeaconState{
+			Slot: 320, Validators: validators, Balances: balances,
+			PreviousEpochParticipation: participation, CurrentSyncCommittee: syncCommittee,
+			LatestExecutionPayloadHeader: &bellatrix.ExecutionPayloadHeader{Timestamp: 1700000000},
+		}}
+	case spec.DataVersionCapella:
+		return &spec.VersionedBeaconState{Capella: &capella.BeaconState{
+			Slot: 320, Validators: validators, Balances: balances,
+			PreviousEpochParticipation: participation, CurrentSyncCommittee: syncCommittee,
+			LatestExecutionPayloadHeader: &capella.ExecutionPayloadHeader{Timestamp: 1700000000},
+		}}
+	case spec.DataVersionDeneb:
+		return &spec.VersionedBeaconState{Deneb: &deneb.BeaconState{
+			Slot: 320, Validators: validators, Balances: balances,
+			PreviousEpochParticipation: participation, CurrentSyncCommittee: syncCommittee,
+			LatestExecutionPayloadHeader: &deneb.ExecutionPayloadHeader{Timestamp: 1700000000},
+		}}
+	case spec.DataVersionElectra:
+		return &spec.VersionedBeaconState{Electra: &electra.BeaconState{
+			Slot: 320, Validators: validators, Balances: balances,
+			PreviousEpochParticipation: participation, CurrentSyncCommittee: syncCommittee,
+			LatestExecutionPayloadHeader: &deneb.ExecutionPayloadHeader{Timestamp: 1700000000},
+			PendingDeposits:              pendingDeposits,
+			PendingPartialWithdrawals:    pendingWithdrawals,
+			PendingConsolidations:        pendingConsolidations,
+			DepositBalanceToConsume:      5,
+			EarliestExitEpoch:            300,
+		}}
+	case spec.DataVersionFulu:
+		return &spec.VersionedBeaconState{Fulu: &fulu.BeaconState{
+			Slot: 320, Validators: validators, Balances: balances,
+			PreviousEpochParticipation: participation, CurrentSyncCommittee: syncCommittee,
+			LatestExecutionPayloadHeader: &deneb.ExecutionPayloadHeader{Timestamp: 1700000000},
+			PendingDeposits:              pendingDeposits,
+			PendingPartialWithdrawals:    pendingWithdrawals,
+			PendingConsolidations:        pendingConsolidations,
+			DepositBalanceToConsume:      5,
+			EarliestExitEpoch:            300,
+		}}
+	}
+	return nil
+}
+
+func Test_Forks(t *testing.T) {
+	// Fails when go-eth2-client gets a fork after the last one here, which
+	// then needs a case in every accessor
+	last := Forks[len(Forks)-1]
+	require.Equal(t, "unknown", spec.DataVersion(int(last)+1).String())
+
+	for _, fork := range Forks {
+		blockFork, err := BlockFork(testBlock(fork))
+		require.NoError(t, err)
+		require.Equal(t, fork, blockFork)
+
+		stateFork, err := StateFork(testState(fork))
+		require.NoError(t, err)
+		require.Equal(t, fork, stateFork)
+	}
+}
+
+func Test_BlockAccessors(t *testing.T) {
+	for _, fork := range Forks {
+		t.Run(fork.String(), func(t *testing.T) {
+			block := testBlock(fork)
+
+			proposer, err := ProposerIndex(block)
+			require.NoError(t, err)
+			require.Equal(t, uint64(7), proposer)
+
+			blockGraffiti, err := Graffiti(block)
+			require.NoError(t, err)
+			require.Equal(t, graffiti, blockGraffiti)
+
+			proposerSlashings, err := ProposerSlashings(block)
+			require.NoError(t, err)
+			require.Len(t, proposerSlashings, 1)
+
+			slashingIndexes, err := AttesterSlashingIndexes(block)
+			require.NoError(t, err)
+			require.Equal(t, [][2][]uint64{{{1, 2}, {2, 3}}}, slashingIndexes)
+
+			attestations, err := Attestations(block)
+			require.NoError(t, err)
+			require.Len(t, attestations, 1)
+			require.Equal(t, phase0.Slot(99), attestations[0].Data.Slot)
+			require.Equal(t, []uint64{4}, attestations[0].CommitteeIndexes)
+
+			exits, err := VoluntaryExits(block)
+			require.NoError(t, err)
+			require.Equal(t, testBody.exits, exits)
+
+			blsChanges, err := BLSToExecutionChanges(block)
+			require.NoError(t, err)
+			withdrawals, err := Withdrawals(block)
+			require.NoError(t, err)
+			if fork >= spec.DataVersionCapella {
+				require.Equal(t, testBody.blsChanges, blsChanges)
+				require.Equal(t, testBody.withdrawals, withdrawals)
+			} else {
+				require.Empty(t, blsChanges)
+				require.Empty(t, withdrawals)
+			}
+
+			hasRequests, err := HasExecutionRequests(block)
+			require.NoError(t, err)
+			require.Equal(t, fork >= spec.DataVersionElectra, hasRequests)
+			requests, err := ExecutionRequests(block)
+			require.NoError(t, err)
+			if hasRequests {
+				require.Len(t, requests.Deposits, 1)
+			} else {
+				require.Empty(t, requests.Deposits)
+			}
+
+			transactions, err := Transactions(block)
+			require.NoError(t, err)
+			blockNumber, blockNumberErr := BlockNumber(block)
+			recipient, recipientErr := FeeRecipient(block)
+			blockBaseFee, baseFeeErr := BaseFeePerGas(block)
+			gasUsed, gasUsedErr := GasUsed(block)
+			if fork < spec.DataVersionBellatrix {
+				require.Empty(t, transactions)
+				require.ErrorContains(t, blockNumberErr, fork.String()+" has no block number")
+				require.Error(t, recipientErr)
+				require.Error(t, baseFeeErr)
+				require.Error(t, gasUsedErr)
+				return
+			}
+			require.Equal(t, testBody.transactions, transactions)
+			require.NoError(t, blockNumberErr)
+			require.Equal(t, uint64(100), blockNumber)
+			require.NoError(t, recipientErr)
+			require.Equal(t, feeRecipient, recipient)
+			require.NoError(t, baseFeeErr)
+			require.Equal(t, big.NewInt(7_000_000_000), new(big.Int).SetBytes(blockBaseFee[:]))
+			require.NoError(t, gasUsedErr)
+			require.Equal(t, uint64(21000), gasUsed)
+
+			blobGasUsed, excessBlobGas, err := BlobGas(block)
+			if fork < spec.DataVersionDeneb {
+				require.ErrorContains(t, err, fork.String()+" has no blob gas")
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, uint64(131072), blobGasUsed)
+			require.Equal(t, uint64(393216), excessBlobGas)
+		})
+	}
+}
+
+func Test_StateAccessors(t *testing.T) {
+	for _, fork := range Forks {
+		t.Run(fork.String(), func(t *testing.T) {
+			state := testState(fork)
+
+			slot, err := Slot(state)
+			require.NoError(t, err)
+			require.Equal(t, uint64(320), slot)
+
+			validators, err := Validators(state)
+			require.NoError(t, err)
+			require.Len(t, validators, 1)
+
+			balances, err := Balances(state)
+			require.NoError(t, err)
+			require.Equal(t, []phase0.Gwei{32_000_000_001}, balances)
+
+			participation, err := PreviousEpochParticipation(state)
+			syncCommittee, syncErr := CurrentSyncCommittee(state)
+			if fork == spec.DataVersionPhase0 {
+				require.ErrorContains(t, err, "phase0 has no participation flags")
+				require.Error(t, syncErr)
+				require.Error(t, CheckState(state))
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, []altair.ParticipationFlags{7}, participation)
+				require.NoError(t, syncErr)
+				require.Len(t, syncCommittee, 1)
+				require.NoError(t, CheckState(state))
+			}
+
+			timestamp, err := Timestamp(state)
+			if fork < spec.DataVersionBellatrix {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, uint64(1700000000), timestamp)
+			}
+
+			deposits, err := PendingDeposits(state)
+			require.NoError(t, err)
+			withdrawals, err := PendingPartialWithdrawals(state)
+			require.NoError(t, err)
+			consolidations, err := PendingConsolidations(state)
+			require.NoError(t, err)
+			balanceToConsume, balanceErr := DepositBalanceToConsume(state)
+			exitEpoch, exitErr := EarliestExitEpoch(state)
+			if fork < spec.DataVersionElectra {
+				require.Empty(t, deposits)
+				require.Empty(t, withdrawals)
+				require.Empty(t, consolidations)
+				require.Error(t, balanceErr)
+				require.Error(t, exitErr)
+				return
+			}
+			require.Len(t, deposits, 1)
+			require.Len(t, withdrawals, 1)
+			require.Len(t, consolidations, 1)
+			require.NoError(t, balanceErr)
+			require.Equal(t, uint64(5), balanceToConsume)
+			require.NoError(t, exitErr)
+			require.Equal(t, uint64(300), exitEpoch)
+		})
+	}
+}
+
+func Test_EmptyBlockAndState(t *testing.T) {
+	block := &spec.VersionedSignedBeaconBlock{Version: spec.DataVersionDeneb}
+	_, err := BlockFork(block)
+	require.ErrorIs(t, err, ErrEmptyBlock)
+	_, err = ProposerIndex(block)
+	require.ErrorIs(t, err, ErrEmptyBlock)
+	_, err = Withdrawals(block)
+	require.ErrorIs(t, err, ErrEmptyBlock)
+	_, err = ExecutionRequests(block)
+	require.ErrorIs(t, err, ErrEmptyBlock)
+	_, err = GasUsed(block)
+	require.ErrorIs(t, err, ErrEmptyBlock)
+	_, _, err = BlobGas(block)
+	require.ErrorIs(t, err, ErrEmptyBlock)
+
+	state := &spec.VersionedBeaconState{Version: spec.DataVersionElectra}
+	require.ErrorIs(t, CheckState(state), ErrEmptyState)
+	_, err = Balances(state)
+	require.ErrorIs(t, err, ErrEmptyState)
+	_, err = PendingDeposits(state)
+	require.ErrorIs(t, err, ErrEmptyState)
+	_, err = EarliestExitEpoch(state)
+	require.ErrorIs(t, err, ErrEmptyState)
+}
@@ -0,0 +1,198 @@
+package forkdata
+
+import (
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/altair"
+	"github.com/attestantio/go-eth2-client/spec/electra"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+)
+
+func Slot(state *spec.VersionedBeaconState) (uint64, error) {
+	switch {
+	case state.Phase0 != nil:
+		return uint64(state.Phase0.Slot), nil
+	case state.Altair != nil:
+		return uint64(state.Altair.Slot), nil
+	case state.Bellatrix != nil:
+		return uint64(state.Bellatrix.Slot), nil
+	case state.Capella != nil:
+		return uint64(state.Capella.Slot), nil
+	case state.Deneb != nil:
+		return uint64(state.Deneb.Slot), nil
+	case state.Electra != nil:
+		return uint64(state.Electra.Slot), nil
+	case state.Fulu != nil:
+		return uint64(state.Fulu.Slot), nil
+	}
+	return 0, ErrEmptyState
+}
+
+func Validators(state *spec.VersionedBeaconState) ([]*phase0.Validator, error) {
+	switch {
+	case state.Phase0 != nil:
+		return state.Phase0.Validators, nil
+	case state.Altair != nil:
+		return state.Altair.Validators, nil
+	case state.Bellatrix != nil:
+		return state.Bellatrix.Validators, nil
+	case state.Capella != nil:
+		return state.Capella.Validators, nil
+	case state.Deneb != nil:
+		return state.Deneb.Validators, nil
+	case state.Electra != nil:
+		return state.Electra.Validators, nil
+	case state.Fulu != nil:
+		return state.Fulu.Validators, nil
+	}
+	return nil, ErrEmptyState
+}
+
+func Balances(state *spec.VersionedBeaconState) ([]phase0.Gwei, error) {
+	switch {
+	case state.Phase0 != nil:
+		return state.Phase0.Balances, nil
+	case state.Altair != nil:
+		return state.Altair.Balances, nil
+	case state.Bellatrix != nil:
+		return state.Bellatrix.Balances, nil
+	case state.Capella != nil:
+		return state.Capella.Balances, nil
+	case state.Deneb != nil:
+		return state.Deneb.Balances, nil
+	case state.Electra != nil:
+		return state.Electra.Balances, nil
+	case state.Fulu != nil:
+		return state.Fulu.Balances, nil
+	}
+	return nil, ErrEmptyState
+}
+
+// Participation flags exist since altair. Phase0 states have the pending
+// attestations instead.
+func PreviousEpochParticipation(state *spec.VersionedBeaconState) ([]altair.ParticipationFlags, error) {
+	switch {
+	case state.Phase0 != nil:
+		return nil, notInFork("participation flags", spec.DataVersionPhase0)
+	case state.Altair != nil:
+		return state.Altair.PreviousEpochParticipation, nil
+	case state.Bellatrix != nil:
+		return state.Bellatrix.PreviousEpochParticipation, nil
+	case state.Capella != nil:
+		return state.Capella.PreviousEpochParticipation, nil
+	case state.Deneb != nil:
+		return state.Deneb.PreviousEpochParticipation, nil
+	case state.Electra != nil:
+		return state.Electra.PreviousEpochParticipation, nil
+	case state.Fulu != nil:
+		return state.Fulu.PreviousEpochParticipation, nil
+	}
+	return nil, ErrEmptyState
+}
+
+// Sync committees exist since altair
+func CurrentSyncCommittee(state *spec.VersionedBeaconState) ([]phase0.BLSPubKey, error) {
+	switch {
+	case state.Phase0 != nil:
+		return nil, notInFork("sync committee", spec.DataVersionPhase0)
+	case state.Altair != nil:
+		return state.Altair.CurrentSyncCommittee.Pubkeys, nil
+	case state.Bellatrix != nil:
+		return state.Bellatrix.CurrentSyncCommittee.Pubkeys, nil
+	case state.Capella != nil:
+		return state.Capella.CurrentSyncCommittee.Pubkeys, nil
+	case state.Deneb != nil:
+		return state.Deneb.CurrentSyncCommittee.Pubkeys, nil
+	case state.Electra != nil:
+		return state.Electra.CurrentSyncCommittee.Pubkeys, nil
+	case state.Fulu != nil:
+		return state.Fulu.CurrentSyncCommittee.Pubkeys, nil
+	}
+	return nil, ErrEmptyState
+}
+
+// Returns the timestamp of the latest execution payload, which exists since
+// bellatrix
+func Timestamp(state *spec.VersionedBeaconState) (uint64, error) {
+	switch {
+	case state.Phase0 != nil:
+		return 0, notInFork("execution payload", spec.DataVersionPhase0)
+	case state.Altair != nil:
+		return 0, notInFork("execution payload", spec.DataVersionAltair)
+	case state.Bellatrix != nil:
+		return state.Bellatrix.LatestExecutionPayloadHeader.Timestamp, nil
+	case state.Capella != nil:
+		return state.Capella.LatestExecutionPayloadHeader.Timestamp, nil
+	case state.Deneb != nil:
+		return state.Deneb.LatestExecutionPayloadHeader.Timestamp, nil
+	case state.Electra != nil:
+		return state.Electra.LatestExecutionPayloadHeader.Timestamp, nil
+	case state.Fulu != nil:
+		return state.Fulu.LatestExecutionPayloadHeader.Timestamp, nil
+	}
+	return 0, ErrEmptyState
+}
+
+// The queues of the deposits, partial withdrawals and consolidations exist
+// since electra, so they are empty before
+func PendingDeposits(state *spec.VersionedBeaconState) ([]*electra.PendingDeposit, error) {
+	switch {
+	case state.Phase0 != nil, state.Altair != nil, state.Bellatrix != nil, state.Capella != nil, state.Deneb != nil:
+		return []*electra.PendingDeposit{}, nil
+	case state.Electra != nil:
+		return state.Electra.PendingDeposits, nil
+	case state.Fulu != nil:
+		return state.Fulu.PendingDeposits, nil
+	}
+	return nil, ErrEmptyState
+}
+
+func PendingPartialWithdrawals(state *spec.VersionedBeaconState) ([]*electra.PendingPartialWithdrawal, error) {
+	switch {
+	case state.Phase0 != nil, state.Altair != nil, state.Bellatrix != nil, state.Capella != nil, state.Deneb != nil:
+		return []*electra.PendingPartialWithdrawal{}, nil
+	case state.Electra != nil:
+		return state.Electra.PendingPartialWithdrawals, nil
+	case state.Fulu != nil:
+		return state.Fulu.PendingPartialWithdrawals, nil
+	}
+	return nil, ErrEmptyState
+}
+
+func PendingConsolidations(state *spec.VersionedBeaconState) ([]*electra.PendingConsolidation, error) {
+	switch {
+	case state.Phase0 != nil, state.Altair != nil, state.Bellatrix != nil, state.Capella != nil, state.Deneb != nil:
+		return []*electra.PendingConsolidation{}, nil
+	case state.Electra != nil:
+		return state.Electra.PendingConsolidations, nil
+	case state.Fulu != nil:
+		return state.Fulu.PendingConsolidations, nil
+	}
+	return nil, ErrEmptyState
+}
+
+// The churn of the deposits exists since electra
+func DepositBalanceToConsume(state *spec.VersionedBeaconState) (uint64, error) {
+	switch {
+	case state.Phase0 != nil, state.Altair != nil, state.Bellatrix != nil, state.Capella != nil, state.Deneb != nil:
+		return 0, errors.New("no deposit balance to consume before electra")
+	case state.Electra != nil:
+		return uint64(state.Electra.DepositBalanceToConsume), nil
+	case state.Fulu != nil:
+		return uint64(state.Fulu.DepositBalanceToConsume), nil
+	}
+	return 0, ErrEmptyState
+}
+
+// The churn of the exits exists since electra
+func EarliestExitEpoch(state *spec.VersionedBeaconState) (uint64, error) {
+	switch {
+	case state.Phase0 != nil, state.Altair != nil, state.Bellatrix != nil, state.Capella != nil, state.Deneb != nil:
+		return 0, errors.New("no earliest exit epoch before electra")
+	case state.Electra != nil:
+		return uint64(state.Electra.EarliestExitEpoch), nil
+	case state.Fulu != nil:
+		return uint64(state.Fulu.EarliestExitEpoch), nil
+	}
+	return 0, ErrEmptyState
+}
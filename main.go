@@ -9,29 +9,60 @@ import (
 	"strings"
 	"syscall"
 
+	"github.com/bilinearlabs/eth-metrics/auth"
 	"github.com/bilinearlabs/eth-metrics/config"
 	"github.com/bilinearlabs/eth-metrics/metrics"
 	"github.com/bilinearlabs/eth-metrics/price"
+	"github.com/bilinearlabs/eth-metrics/report"
+	"github.com/bilinearlabs/eth-metrics/telegram"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 )
 
 var db *sql.DB
 
 func main() {
-	config, err := config.NewCliConfig()
+	cfg, err := config.NewCliConfig()
 	if err != nil {
 		log.Fatal(err)
 	}
+	if err := config.SetupLogging(cfg.LogFormat); err != nil {
+		log.Fatal(err)
+	}
 
-	logLevel, err := log.ParseLevel(config.Verbosity)
+	logLevel, err := log.ParseLevel(cfg.Verbosity)
 	if err != nil {
 		log.Fatal(err)
 	}
 	log.SetLevel(logLevel)
+	config.LogConfig(cfg)
+
+	switch cfg.Command {
+	case config.CommandBackfill:
+		err = backfill(cfg)
+	case config.CommandInspect:
+		err = inspect(cfg, os.Stdout)
+	case config.CommandExport:
+		err = exportRows(cfg, os.Stdout)
+	case config.CommandReprocess:
+		err = reprocess(cfg)
+	case config.CommandStatement:
+		err = statement(cfg, os.Stdout)
+	case config.CommandTop:
+		err = showTop(cfg, os.Stdout)
+	default:
+		run(cfg)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
 
+// Processes the new epochs as they come and serves the api until stopped
+func run(config *config.Config) {
 	metrics, err := metrics.NewMetrics(
 		context.Background(),
 		config)
@@ -40,7 +71,32 @@ func main() {
 		log.Fatal(err)
 	}
 
-	price, err := price.NewPrice(config.DatabasePath, config)
+	// In dry-run mode the prices are not stored either
+	priceDatabasePath := config.DatabasePath
+	if config.DryRun {
+		priceDatabasePath = ""
+	}
+	price, err := price.NewPrice(priceDatabasePath, config)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	reporter, err := report.NewReporter(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	bot, err := telegram.NewBot(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	authn, err := auth.NewAuthenticator(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tlsConfig, err := auth.NewServerTLSConfig(config.TLSCert, config.TLSKey, config.TLSClientCA)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -58,7 +114,17 @@ func main() {
 
 	gin.SetMode(gin.ReleaseMode)
 
-	r.POST("/query", func(c *gin.Context) {
+	// With api keys, json web tokens or tenants, reading the metrics requires
+	// a credential too. The metrics of all the pools are not exposed to the
+	// tenants, which only get the rows and duties of their pools.
+	operatorRead := r.Group("")
+	read := r.Group("")
+	if authn.ReadRequired() {
+		operatorRead.Use(authenticate(authn), operatorOnly())
+		read.Use(authenticate(authn))
+	}
+
+	operatorRead.POST("/query", func(c *gin.Context) {
 		var query struct {
 			SQL string `json:"sql"`
 		}
@@ -82,15 +148,41 @@ func main() {
 		c.JSON(http.StatusOK, gin.H{"data": rows})
 	})
 
-	// Run the server in a goroutine
+	operatorRead.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	read.GET("/api/v1/duties", upcomingDutiesHandler(metrics))
+	read.GET("/api/v1/pools/:pool/rows/:table", poolRowsHandler(metrics))
+	registerAdminRoutes(r, metrics, authn)
+
+	// Run the server in a goroutine, on $PORT or 8080 as gin does
+	server := &http.Server{Addr: ":8080", Handler: r, TLSConfig: tlsConfig}
+	if port := os.Getenv("PORT"); port != "" {
+		server.Addr = ":" + port
+	}
 	go func() {
-		if err := r.Run(); err != nil {
+		var err error
+		if tlsConfig != nil {
+			log.Info("Serving https on ", server.Addr)
+			// The certificate is given by the tls config
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			log.Info("Serving http on ", server.Addr)
+			err = server.ListenAndServe()
+		}
+		if err != nil {
 			log.Fatal("Failed to run server: ", err)
 		}
 	}()
 
 	go price.Run()
-	metrics.Run()
+	if reporter.Enabled() {
+		go reporter.Run()
+	}
+	if bot.Enabled() {
+		go bot.Run()
+	}
+	if err := metrics.Run(); err != nil {
+		log.Fatal(err)
+	}
 
 	// Wait for signal.
 	sigCh := make(chan os.Signal, 1)
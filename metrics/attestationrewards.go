@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/api"
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/http"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+)
+
+type AttestationRewards struct {
+	consensus *http.Service
+	config    *config.Config
+}
+
+func NewAttestationRewards(
+	consensus *http.Service,
+	config *config.Config,
+) (*AttestationRewards, error) {
+	return &AttestationRewards{
+		consensus: consensus,
+		config:    config,
+	}, nil
+}
+
+// Returns the attestation rewards of the given validators for epoch-1, which are
+// the ones reflected in the participation flags of the beacon state of the epoch
+func (r *AttestationRewards) GetAttestationRewards(
+	epoch uint64,
+	validatorIndexes []uint64) (*v1.AttestationRewards, error) {
+
+	if len(validatorIndexes) == 0 {
+		return &v1.AttestationRewards{}, nil
+	}
+	log.WithField("Epoch", epoch-1).Info("Fetching attestation rewards")
+
+	indexes := make([]phase0.ValidatorIndex, len(validatorIndexes))
+	for i, valIdx := range validatorIndexes {
+		indexes[i] = phase0.ValidatorIndex(valIdx)
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(r.config.StateTimeout))
+	defer cancel()
+	rewards, err := r.consensus.AttestationRewards(ctxTimeout, &api.AttestationRewardsOpts{
+		Epoch:   phase0.Epoch(epoch - 1),
+		Indices: indexes,
+		Common: api.CommonOpts{
+			Timeout: time.Second * time.Duration(r.config.StateTimeout),
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting attestation rewards")
+	}
+	return rewards.Data, nil
+}
+
+// Aggregates the attestation rewards of the given validators. The ideal rewards
+// depend on the effective balance, which is taken from the given validators.
+func GetPoolAttestationRewards(
+	activeValidatorIndexes []uint64,
+	validators []*phase0.Validator,
+	rewards *v1.AttestationRewards) schemas.AttestationRewards {
+
+	poolRewards := schemas.AttestationRewards{}
+	if rewards == nil {
+		return poolRewards
+	}
+
+	idealByEffectiveBalance := make(map[phase0.Gwei]v1.IdealAttestationRewards)
+	for _, ideal := range rewards.IdealRewards {
+		idealByEffectiveBalance[ideal.EffectiveBalance] = ideal
+	}
+
+	validatorRewards := make(map[uint64]v1.ValidatorAttestationRewards)
+	for _, reward := range rewards.TotalRewards {
+		validatorRewards[uint64(reward.ValidatorIndex)] = reward
+	}
+
+	for _, valIdx := range activeValidatorIndexes {
+		reward, ok := validatorRewards[valIdx]
+		if !ok {
+			continue
+		}
+		poolRewards.Head += int64(reward.Head)
+		poolRewards.Target += reward.Target
+		poolRewards.Source += reward.Source
+		poolRewards.Inactivity += int64(reward.Inactivity)
+		if reward.InclusionDelay != nil {
+			poolRewards.InclusionDelay += int64(*reward.InclusionDelay)
+		}
+
+		if valIdx >= uint64(len(validators)) {
+			continue
+		}
+		ideal, ok := idealByEffectiveBalance[validators[valIdx].EffectiveBalance]
+		if !ok {
+			continue
+		}
+		poolRewards.Ideal += int64(ideal.Head + ideal.Target + ideal.Source)
+		if ideal.InclusionDelay != nil {
+			poolRewards.Ideal += int64(*ideal.InclusionDelay)
+		}
+	}
+	return poolRewards
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"testing"
+
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GetPoolAttestationRewards(t *testing.T) {
+	validators := []*phase0.Validator{
+		{EffectiveBalance: 32000000000},
+		{EffectiveBalance: 32000000000},
+		{EffectiveBalance: 31000000000},
+		{EffectiveBalance: 32000000000},
+	}
+	rewards := &v1.AttestationRewards{
+		IdealRewards: []v1.IdealAttestationRewards{
+			{EffectiveBalance: 31000000000, Head: 9, Target: 18, Source: 9},
+			{EffectiveBalance: 32000000000, Head: 10, Target: 20, Source: 10},
+		},
+		TotalRewards: []v1.ValidatorAttestationRewards{
+			{ValidatorIndex: 0, Head: 10, Target: 20, Source: 10},
+			{ValidatorIndex: 1, Head: 0, Target: -20, Source: 10},
+			{ValidatorIndex: 2, Head: 0, Target: -18, Source: -9, Inactivity: 5},
+			// Not part of the pool
+			{ValidatorIndex: 3, Head: 10, Target: 20, Source: 10},
+		},
+	}
+
+	poolRewards := GetPoolAttestationRewards([]uint64{0, 1, 2}, validators, rewards)
+	require.Equal(t, schemas.AttestationRewards{
+		Head:       10,
+		Target:     -18,
+		Source:     11,
+		Inactivity: 5,
+		Ideal:      40 + 40 + 36,
+	}, poolRewards)
+
+	require.Equal(t, schemas.AttestationRewards{}, GetPoolAttestationRewards([]uint64{0}, validators, nil))
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Waits of the loop when polling for a new epoch, and after an error
+const (
+	pollInterval    = 5 * time.Second
+	minRetryBackoff = 5 * time.Second
+	maxRetryBackoff = 5 * time.Minute
+)
+
+// Share of a wait that is randomly added or subtracted, so that instances
+// sharing a provider don't send their requests at the same time
+const jitterFactor = 0.2
+
+// Wait before retrying after an error, which doubles while the errors go on,
+// up to a maximum, so that a failing or rate limited node is not flooded
+type Backoff struct {
+	min     time.Duration
+	max     time.Duration
+	current time.Duration
+	// Overridden by the tests
+	random func() float64
+}
+
+func NewBackoff(minWait time.Duration, maxWait time.Duration) *Backoff {
+	return &Backoff{min: minWait, max: maxWait, current: minWait, random: rand.Float64}
+}
+
+// Returns the wait after an error with jitter, and doubles the next one
+func (b *Backoff) Next() time.Duration {
+	wait := Jitter(b.current, b.random())
+	b.current = min(2*b.current, b.max)
+	return wait
+}
+
+// Goes back to the minimum wait, meant to be called once a request succeeds
+func (b *Backoff) Reset() {
+	b.current = b.min
+}
+
+// Returns the duration changed by up to the jitter factor, from r in [0, 1)
+func Jitter(d time.Duration, r float64) time.Duration {
+	return time.Duration(float64(d) * (1 + jitterFactor*(2*r-1)))
+}
+
+// Returns the wait before checking again for a new epoch
+func pollWait() time.Duration {
+	return Jitter(pollInterval, rand.Float64())
+}
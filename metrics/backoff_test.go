@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Backoff(t *testing.T) {
+	backoff := NewBackoff(5*time.Second, 30*time.Second)
+	// Without jitter
+	backoff.random = func() float64 { return 0.5 }
+
+	require.Equal(t, 5*time.Second, backoff.Next())
+	require.Equal(t, 10*time.Second, backoff.Next())
+	require.Equal(t, 20*time.Second, backoff.Next())
+	require.Equal(t, 30*time.Second, backoff.Next())
+	require.Equal(t, 30*time.Second, backoff.Next())
+
+	backoff.Reset()
+	require.Equal(t, 5*time.Second, backoff.Next())
+}
+
+func Test_Jitter(t *testing.T) {
+	require.Equal(t, 8*time.Second, Jitter(10*time.Second, 0))
+	require.Equal(t, 10*time.Second, Jitter(10*time.Second, 0.5))
+	require.InDelta(t, float64(12*time.Second), float64(Jitter(10*time.Second, 0.999999)), float64(time.Millisecond))
+}
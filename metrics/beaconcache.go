@@ -0,0 +1,268 @@
+package metrics
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+)
+
+// Responses of slots that are not finalized yet are only reused for a short
+// time, e.g. by the modules of the same epoch, since a reorg can change them
+const beaconCacheUnfinalizedTTL = time.Minute
+
+var beaconCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "ethmetrics_beacon_cache_requests_total",
+	Help: "Cacheable requests to the beacon node, by whether they were served from the cache",
+}, []string{"result"})
+
+// Cacheable endpoints, with the block id, slot or epoch of the response as
+// first group
+var beaconCacheEndpoints = []struct {
+	path    *regexp.Regexp
+	byEpoch bool
+}{
+	{path: regexp.MustCompile(`^/eth/v[12]/beacon/blocks/([^/]+)$`)},
+	{path: regexp.MustCompile(`^/eth/v1/beacon/headers/([^/]+)$`)},
+	{path: regexp.MustCompile(`^/eth/v1/beacon/rewards/blocks/([^/]+)$`)},
+	{path: regexp.MustCompile(`^/eth/v1/beacon/states/([0-9]+)/committees$`)},
+	{path: regexp.MustCompile(`^/eth/v1/validator/duties/proposer/([0-9]+)$`), byEpoch: true},
+}
+
+type beaconCacheEntry struct {
+	key        string
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	// Zero if the response can't change anymore
+	expires time.Time
+}
+
+// Caches the responses of the beacon node to the requests of blocks, headers,
+// block rewards, committees and proposer duties, keyed by endpoint and path,
+// so that modules fetching the same blocks don't fetch them twice. Responses
+// of finalized slots are also stored in a directory if set, so that they are
+// not fetched again after a restart, e.g. in the middle of a backfill. Safe
+// for concurrent use.
+type BeaconCache struct {
+	next     http.RoundTripper
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+	dir      string
+
+	// Unknown until set, so only block roots are final
+	finalizedEpoch uint64
+	slotsInEpoch   uint64
+}
+
+func NewBeaconCache(next http.RoundTripper, capacity int, dir string) (*BeaconCache, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, errors.Wrap(err, "could not create beacon cache dir")
+		}
+	}
+	return &BeaconCache{
+		next:     next,
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+		dir:      dir,
+	}, nil
+}
+
+// Sets the last finalized epoch, whose responses are kept without expiring
+func (c *BeaconCache) SetFinalizedEpoch(finalizedEpoch uint64, slotsInEpoch uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.finalizedEpoch = finalizedEpoch
+	c.slotsInEpoch = slotsInEpoch
+}
+
+func (c *BeaconCache) RoundTrip(req *http.Request) (*http.Response, error) {
+	final, cacheable := c.isFinal(req)
+	if !cacheable || (c.capacity <= 0 && c.dir == "") {
+		return c.next.RoundTrip(req)
+	}
+
+	key := req.URL.Host + req.URL.Path + "?" + req.URL.RawQuery + "|" + req.Header.Get("Accept")
+	if entry, ok := c.get(key, final); ok {
+		beaconCacheRequests.WithLabelValues("hit").Inc()
+		return entry.response(req), nil
+	}
+	beaconCacheRequests.WithLabelValues("miss").Inc()
+
+	resp, err := c.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &beaconCacheEntry{key: key, StatusCode: resp.StatusCode, Header: resp.Header.Clone(), Body: body}
+	if !final {
+		entry.expires = time.Now().Add(beaconCacheUnfinalizedTTL)
+	}
+	c.put(entry)
+	return entry.response(req), nil
+}
+
+// Returns if the request is cacheable and if its response can't change
+// anymore, which is the case of blocks by root and of finalized slots
+func (c *BeaconCache) isFinal(req *http.Request) (bool, bool) {
+	if req.Method != http.MethodGet {
+		return false, false
+	}
+
+	var id string
+	var byEpoch bool
+	for _, endpoint := range beaconCacheEndpoints {
+		if match := endpoint.path.FindStringSubmatch(req.URL.Path); match != nil {
+			id, byEpoch = match[1], endpoint.byEpoch
+			break
+		}
+	}
+	if id == "" && req.URL.Path == "/eth/v1/beacon/headers" {
+		id = req.URL.Query().Get("slot")
+	}
+	if strings.HasPrefix(id, "0x") {
+		return true, true
+	}
+	// head, finalized, etc. are not cached
+	number, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return false, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.slotsInEpoch == 0 {
+		return false, true
+	}
+	if byEpoch {
+		return number < c.finalizedEpoch, true
+	}
+	return number <= c.finalizedEpoch*c.slotsInEpoch, true
+}
+
+func (c *BeaconCache) get(key string, final bool) (*beaconCacheEntry, bool) {
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*beaconCacheEntry)
+		if entry.expires.IsZero() || time.Now().Before(entry.expires) {
+			c.order.MoveToFront(elem)
+			c.mu.Unlock()
+			return entry, true
+		}
+		c.order.Remove(elem)
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+
+	if c.dir == "" || !final {
+		return nil, false
+	}
+	entry, err := c.readFromDisk(key)
+	if err != nil {
+		if !os.IsNotExist(errors.Cause(err)) {
+			log.Warn("could not read beacon response from disk cache: ", err)
+		}
+		return nil, false
+	}
+	c.putInMemory(entry)
+	return entry, true
+}
+
+func (c *BeaconCache) put(entry *beaconCacheEntry) {
+	if c.dir != "" && entry.expires.IsZero() {
+		if err := c.writeToDisk(entry); err != nil {
+			log.Warn("could not write beacon response to disk cache: ", err)
+		}
+	}
+	c.putInMemory(entry)
+}
+
+func (c *BeaconCache) putInMemory(entry *beaconCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.capacity <= 0 {
+		return
+	}
+
+	if elem, ok := c.entries[entry.key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[entry.key] = c.order.PushFront(entry)
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*beaconCacheEntry).key)
+	}
+}
+
+func (c *BeaconCache) path(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(hash[:])+".json")
+}
+
+func (c *BeaconCache) readFromDisk(key string) (*beaconCacheEntry, error) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, err
+	}
+	entry := &beaconCacheEntry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil, errors.Wrap(err, "could not decode cached response")
+	}
+	entry.key = key
+	return entry, nil
+}
+
+// Written to a temporary file first, so that an interrupted write doesn't
+// leave a truncated response
+func (c *BeaconCache) writeToDisk(entry *beaconCacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	tmp := c.path(entry.key) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path(entry.key))
+}
+
+func (e *beaconCacheEntry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_BeaconCache(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.RequestURI()]++
+		mu.Unlock()
+		if r.URL.Path == "/eth/v2/beacon/blocks/404" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Eth-Consensus-Version", "electra")
+		w.Write([]byte("response of " + r.URL.RequestURI()))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	cache, err := NewBeaconCache(http.DefaultTransport, 2, dir)
+	require.NoError(t, err)
+	client := &http.Client{Transport: cache}
+
+	get := func(path string) (int, string) {
+		resp, err := client.Get(server.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	// Fetched once, with the same headers
+	for i := 0; i < 2; i++ {
+		status, body := get("/eth/v2/beacon/blocks/100")
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, "response of /eth/v2/beacon/blocks/100", body)
+	}
+	resp, err := client.Get(server.URL + "/eth/v2/beacon/blocks/100")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, "electra", resp.Header.Get("Eth-Consensus-Version"))
+	require.Equal(t, 1, requests["/eth/v2/beacon/blocks/100"])
+
+	// Not cached: errors, the head, states and other endpoints
+	for i := 0; i < 2; i++ {
+		status, _ := get("/eth/v2/beacon/blocks/404")
+		require.Equal(t, http.StatusNotFound, status)
+		get("/eth/v2/beacon/blocks/head")
+		get("/eth/v1/beacon/states/head/finality_checkpoints")
+	}
+	require.Equal(t, 2, requests["/eth/v2/beacon/blocks/404"])
+	require.Equal(t, 2, requests["/eth/v2/beacon/blocks/head"])
+	require.Equal(t, 2, requests["/eth/v1/beacon/states/head/finality_checkpoints"])
+
+	// Only the finalized responses survive a restart
+	cache.SetFinalizedEpoch(10, 32)
+	get("/eth/v1/beacon/headers?slot=320")
+	get("/eth/v1/beacon/headers?slot=321")
+	get("/eth/v1/validator/duties/proposer/9")
+	get("/eth/v1/validator/duties/proposer/10")
+
+	cache, err = NewBeaconCache(http.DefaultTransport, 2, dir)
+	require.NoError(t, err)
+	cache.SetFinalizedEpoch(10, 32)
+	client = &http.Client{Transport: cache}
+	get("/eth/v1/beacon/headers?slot=320")
+	get("/eth/v1/beacon/headers?slot=321")
+	get("/eth/v1/validator/duties/proposer/9")
+	get("/eth/v1/validator/duties/proposer/10")
+	require.Equal(t, 1, requests["/eth/v1/beacon/headers?slot=320"])
+	require.Equal(t, 2, requests["/eth/v1/beacon/headers?slot=321"])
+	require.Equal(t, 1, requests["/eth/v1/validator/duties/proposer/9"])
+	require.Equal(t, 2, requests["/eth/v1/validator/duties/proposer/10"])
+
+	// The least recently used response is evicted from memory
+	cache, err = NewBeaconCache(http.DefaultTransport, 2, "")
+	require.NoError(t, err)
+	client = &http.Client{Transport: cache}
+	get("/eth/v1/beacon/rewards/blocks/1")
+	get("/eth/v1/beacon/rewards/blocks/2")
+	get("/eth/v1/beacon/rewards/blocks/3")
+	get("/eth/v1/beacon/rewards/blocks/1")
+	get("/eth/v1/beacon/rewards/blocks/3")
+	require.Equal(t, 2, requests["/eth/v1/beacon/rewards/blocks/1"])
+	require.Equal(t, 1, requests["/eth/v1/beacon/rewards/blocks/3"])
+}
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	beaconRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ethmetrics_beacon_requests_total",
+		Help: "Requests made to the beacon node by endpoint, not counting the ones served from the beacon cache",
+	}, []string{"endpoint"})
+	beaconRequestErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ethmetrics_beacon_request_errors_total",
+		Help: "Requests to the beacon node by endpoint that failed, were rate limited or returned a 5xx status",
+	}, []string{"endpoint"})
+	beaconRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ethmetrics_beacon_request_duration_seconds",
+		Help:    "Latency of the requests to the beacon node by endpoint, until the response headers",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"endpoint"})
+)
+
+// Slots, epochs, roots and validator indexes in the paths of the beacon api
+var beaconPathIdRegex = regexp.MustCompile(`^([0-9]+|0x[0-9a-fA-F]+)$`)
+
+// Returns the path of the request with its ids replaced by {id}, e.g.
+// /eth/v2/beacon/blocks/{id}, so that the endpoints are a bounded set of labels
+func BeaconEndpoint(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if beaconPathIdRegex.MatchString(segment) {
+			segments[i] = "{id}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// Counts the requests to the beacon node, their errors and latencies by
+// endpoint
+type beaconRequestMetrics struct {
+	next http.RoundTripper
+}
+
+func NewBeaconRequestMetrics(next http.RoundTripper) http.RoundTripper {
+	return &beaconRequestMetrics{next: next}
+}
+
+func (m *beaconRequestMetrics) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := BeaconEndpoint(req.URL.Path)
+	start := time.Now()
+	resp, err := m.next.RoundTrip(req)
+	beaconRequestDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+	beaconRequestsTotal.WithLabelValues(endpoint).Inc()
+	// Not found is expected, e.g. for the block of a missed slot
+	if err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		beaconRequestErrorsTotal.WithLabelValues(endpoint).Inc()
+	}
+	return resp, err
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_BeaconEndpoint(t *testing.T) {
+	require.Equal(t, "/eth/v2/beacon/blocks/{id}", BeaconEndpoint("/eth/v2/beacon/blocks/9000000"))
+	require.Equal(t, "/eth/v1/beacon/blocks/{id}/root", BeaconEndpoint("/eth/v1/beacon/blocks/0xab12/root"))
+	require.Equal(t, "/eth/v1/beacon/states/head/finality_checkpoints", BeaconEndpoint("/eth/v1/beacon/states/head/finality_checkpoints"))
+	require.Equal(t, "/eth/v1/validator/duties/proposer/{id}", BeaconEndpoint("/eth/v1/validator/duties/proposer/280000"))
+	require.Equal(t, "/eth/v1/node/syncing", BeaconEndpoint("/eth/v1/node/syncing"))
+}
+
+func Test_BeaconRequestMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/eth/v2/beacon/blocks/100":
+			w.WriteHeader(http.StatusNotFound)
+		case "/eth/v2/beacon/blocks/101":
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/eth/v2/beacon/blocks/102":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	endpoint := "/eth/v2/beacon/blocks/{id}"
+	requests := testutil.ToFloat64(beaconRequestsTotal.WithLabelValues(endpoint))
+	errors := testutil.ToFloat64(beaconRequestErrorsTotal.WithLabelValues(endpoint))
+
+	client := &http.Client{Transport: NewBeaconRequestMetrics(http.DefaultTransport)}
+	for _, slot := range []string{"99", "100", "101", "102"} {
+		resp, err := client.Get(server.URL + "/eth/v2/beacon/blocks/" + slot)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	require.Equal(t, requests+4, testutil.ToFloat64(beaconRequestsTotal.WithLabelValues(endpoint)))
+	// Not found is not an error
+	require.Equal(t, errors+2, testutil.ToFloat64(beaconRequestErrorsTotal.WithLabelValues(endpoint)))
+}
@@ -8,7 +8,7 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/attestantio/go-eth2-client/api"
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
 	"github.com/attestantio/go-eth2-client/http"
 	"github.com/attestantio/go-eth2-client/spec"
 	"github.com/attestantio/go-eth2-client/spec/altair"
@@ -18,6 +18,9 @@ import (
 
 	"github.com/bilinearlabs/eth-metrics/config"
 	"github.com/bilinearlabs/eth-metrics/db"
+	"github.com/bilinearlabs/eth-metrics/forkdata"
+	"github.com/bilinearlabs/eth-metrics/price"
+	"github.com/bilinearlabs/eth-metrics/publish"
 	"github.com/bilinearlabs/eth-metrics/schemas"
 
 	log "github.com/sirupsen/logrus"
@@ -27,24 +30,36 @@ type BeaconState struct {
 	consensus         *http.Service
 	networkParameters *NetworkParameters
 	database          *db.Database
+	clickHouse        *publish.ClickHouse
 	config            *config.Config
 	slotsInEpoch      uint64
+	stateCache        *StateCache
+	stateDownloader   *StateDownloader
 }
 
 func NewBeaconState(
 	httpClient *http.Service,
 	networkParameters *NetworkParameters,
 	database *db.Database,
+	clickHouse *publish.ClickHouse,
 	config *config.Config,
 	slotsInEpoch uint64,
+	stateCache *StateCache,
 ) (*BeaconState, error) {
 
 	return &BeaconState{
 		consensus:         httpClient,
 		networkParameters: networkParameters,
 		database:          database,
+		clickHouse:        clickHouse,
 		config:            config,
 		slotsInEpoch:      slotsInEpoch,
+		stateCache:        stateCache,
+		stateDownloader: NewStateDownloader(
+			config.Eth2Address,
+			BeaconNodeHeaders(config),
+			time.Second*time.Duration(config.StateTimeout),
+			config.StateRetries),
 	}, nil
 }
 
@@ -55,59 +70,94 @@ func (p *BeaconState) Run(
 	prevBeaconState *spec.VersionedBeaconState,
 	valKeyToIndex map[string]uint64,
 	relayRewards *big.Int,
+	mevPartial bool,
 	validatorIndexToWithdrawalAmount map[uint64]*big.Int,
 	proposerTips map[uint64]*big.Int,
-	validatorIndexToProcessedConsolidation map[uint64][]*electra.PendingConsolidation) error {
+	validatorIndexToProcessedConsolidation map[uint64][]*electra.PendingConsolidation,
+	validatorIndexToDepositBalanceChange map[uint64]*big.Int,
+	validatorIndexToSyncCommitteeReward map[uint64]*big.Int,
+	validatorIndexToProposerReward map[uint64]*big.Int,
+	validatorIndexToInclusionDelay map[uint64]uint64,
+	nOfSlashedValidators uint64,
+	attestationRewards *v1.AttestationRewards,
+	ethPriceUsd float32,
+	processingMode schemas.ProcessingMode) (schemas.ValidatorPerformanceMetrics, error) {
 
 	if currentBeaconState == nil || prevBeaconState == nil {
-		return errors.New("current or previous beacon state is nil")
+		return schemas.ValidatorPerformanceMetrics{}, errors.New("current or previous beacon state is nil")
 	}
 	if len(validatorKeys) == 0 {
-		return errors.New("no validator keys provided")
+		return schemas.ValidatorPerformanceMetrics{}, errors.New("no validator keys provided")
 	}
 
 	currentSlot, err := currentBeaconState.Slot()
 	if err != nil {
-		return errors.Wrap(err, "error getting slot from current beacon state")
+		return schemas.ValidatorPerformanceMetrics{}, errors.Wrap(err, "error getting slot from current beacon state")
 	}
 
 	prevSlot, err := prevBeaconState.Slot()
 	if err != nil {
-		return errors.Wrap(err, "error getting slot from previous beacon state")
+		return schemas.ValidatorPerformanceMetrics{}, errors.Wrap(err, "error getting slot from previous beacon state")
 	}
 
 	// Distance shall be the slots in an epoch
 	if currentSlot != (prevSlot + phase0.Slot(p.slotsInEpoch)) {
-		return errors.New(fmt.Sprintf("slot mismatch between current and previous beacon state: %d vs %d",
+		return schemas.ValidatorPerformanceMetrics{}, errors.New(fmt.Sprintf("slot mismatch between current and previous beacon state: %d vs %d",
 			currentSlot, prevSlot))
 	}
 
 	validatorIndexes := GetIndexesFromKeys(validatorKeys, valKeyToIndex)
 	activeValidatorIndexes := p.GetActiveIndexes(validatorIndexes, currentBeaconState)
 
+	// Full withdrawals are of validators that already exited, which are not
+	// active, so only the partial ones are added back to the balances
+	partialWithdrawals, fullWithdrawals := ClassifyWithdrawals(
+		uint64(currentSlot)/p.slotsInEpoch,
+		validatorIndexToWithdrawalAmount,
+		GetValidators(currentBeaconState))
+
+	// Sync committee and proposer rewards are reported on their own
+	reportedRewards := MergeRewards(validatorIndexToSyncCommitteeReward, validatorIndexToProposerReward)
+
 	// TODO: Redundant parameters already in the class
 	metrics, err := p.PopulateParticipationAndBalance(
 		poolName,
 		activeValidatorIndexes,
 		currentBeaconState,
 		prevBeaconState,
-		validatorIndexToWithdrawalAmount,
-		validatorIndexToProcessedConsolidation)
+		partialWithdrawals,
+		validatorIndexToProcessedConsolidation,
+		validatorIndexToDepositBalanceChange,
+		reportedRewards)
 
 	if err != nil {
-		return errors.Wrap(err, "error populating participation and balance")
+		return schemas.ValidatorPerformanceMetrics{}, errors.Wrap(err, "error populating participation and balance")
 	}
 
 	metrics.NOfActiveValidators = uint64(len(activeValidatorIndexes))
 	metrics.MEVRewards = relayRewards
-
-	aggregatedProposerTips := big.NewInt(0)
-	for _, activeValidatorIndex := range activeValidatorIndexes {
-		if tip, ok := proposerTips[activeValidatorIndex]; ok {
-			aggregatedProposerTips.Add(aggregatedProposerTips, tip)
-		}
-	}
-	metrics.ProposerTips = aggregatedProposerTips
+	metrics.MEVPartial = mevPartial
+	metrics.NOfSlashedValidators = nOfSlashedValidators
+	metrics.ProcessingMode = processingMode
+	metrics.EthPriceUsd = ethPriceUsd
+	metrics.EarnedUsd = price.ToUsd(metrics.EarnedBalance, 9, ethPriceUsd)
+	metrics.MEVRewardsUsd = price.ToUsd(metrics.MEVRewards, 18, ethPriceUsd)
+
+	metrics.ProposerTips = GetPoolProposerTips(activeValidatorIndexes, proposerTips)
+	metrics.AvgInclusionDelay, metrics.MaxInclusionDelay = GetAvgAndMaxInclusionDelay(
+		activeValidatorIndexes,
+		validatorIndexToInclusionDelay)
+	metrics.AttestationRewards = GetPoolAttestationRewards(
+		activeValidatorIndexes,
+		GetValidators(currentBeaconState),
+		attestationRewards)
+	metrics.SyncCommitteeRewards = GetPoolRewards(activeValidatorIndexes, validatorIndexToSyncCommitteeReward)
+	metrics.ProposerRewards = GetPoolRewards(activeValidatorIndexes, validatorIndexToProposerReward)
+	// Of all the validators, since fully withdrawn ones are no longer active
+	metrics.PartialWithdrawals, metrics.FullWithdrawals, metrics.NOfFullWithdrawals = GetPoolWithdrawals(
+		validatorIndexes,
+		partialWithdrawals,
+		fullWithdrawals)
 
 	syncCommitteeKeys := BLSPubKeyToByte(GetCurrentSyncCommittee(currentBeaconState))
 	syncCommitteeIndexes := GetIndexesFromKeys(syncCommitteeKeys, valKeyToIndex)
@@ -116,21 +166,48 @@ func (p *BeaconState) Run(
 	// Temporal to debug:
 	p.ParticipationDebug(activeValidatorIndexes, currentBeaconState)
 
-	log.Info("The pool:", poolName, " contains ", len(validatorKeys), " keys (may be hardcoded)")
-	log.Info("The pool:", poolName, " contains ", len(validatorIndexes), " validators detected in the beacon state")
-	log.Info("The pool:", poolName, " contains ", len(activeValidatorIndexes), " active validators detected in the beacon state")
-	log.Info("Pool: ", poolName, " sync committee validators ", poolSyncIndexes)
+	poolLog := log.WithFields(log.Fields{"PoolName": poolName, "Epoch": uint64(currentSlot) / p.slotsInEpoch})
+	poolLog.Info("The pool contains ", len(validatorKeys), " keys (may be hardcoded)")
+	poolLog.Info("The pool contains ", len(validatorIndexes), " validators detected in the beacon state")
+	poolLog.Info("The pool contains ", len(activeValidatorIndexes), " active validators detected in the beacon state")
+	poolLog.Info("Pool sync committee validators ", poolSyncIndexes)
 
 	logMetrics(metrics, poolName)
 
 	if p.database != nil {
 		err := p.database.StoreValidatorPerformance(metrics)
 		if err != nil {
-			return errors.Wrap(err, "could not store validator performance")
+			return schemas.ValidatorPerformanceMetrics{}, errors.Wrap(err, "could not store validator performance")
+		}
+		err = p.database.StoreMissedAttestations(metrics.Epoch, poolName, metrics.MissedAttestations)
+		if err != nil {
+			return schemas.ValidatorPerformanceMetrics{}, errors.Wrap(err, "could not store missed attestations")
+		}
+	}
+
+	if p.config.PerValidatorMetrics && (p.database != nil || p.clickHouse.Enabled()) {
+		validatorMetrics := p.GetValidatorMetrics(
+			poolName,
+			activeValidatorIndexes,
+			currentBeaconState,
+			prevBeaconState,
+			partialWithdrawals,
+			validatorIndexToProcessedConsolidation,
+			validatorIndexToDepositBalanceChange,
+			reportedRewards)
+
+		// Too many rows for the database with large pools
+		if p.clickHouse.Enabled() {
+			p.clickHouse.AddValidatorMetrics(validatorMetrics)
+		} else {
+			err := p.database.StoreValidatorMetrics(validatorMetrics)
+			if err != nil {
+				return schemas.ValidatorPerformanceMetrics{}, errors.Wrap(err, "could not store per validator metrics")
+			}
 		}
 	}
 
-	return nil
+	return metrics, nil
 }
 
 // TODO: Very naive approach
@@ -183,7 +260,9 @@ func (p *BeaconState) PopulateParticipationAndBalance(
 	beaconState *spec.VersionedBeaconState,
 	prevBeaconState *spec.VersionedBeaconState,
 	validatorIndexToWithdrawalAmount map[uint64]*big.Int,
-	validatorIndexToProcessedConsolidation map[uint64][]*electra.PendingConsolidation) (schemas.ValidatorPerformanceMetrics, error) {
+	validatorIndexToProcessedConsolidation map[uint64][]*electra.PendingConsolidation,
+	validatorIndexToDepositBalanceChange map[uint64]*big.Int,
+	validatorIndexToReportedReward map[uint64]*big.Int) (schemas.ValidatorPerformanceMetrics, error) {
 
 	metrics := schemas.ValidatorPerformanceMetrics{
 		EarnedBalance:    big.NewInt(0),
@@ -217,7 +296,9 @@ func (p *BeaconState) PopulateParticipationAndBalance(
 		prevBeaconState,
 		beaconState,
 		validatorIndexToWithdrawalAmount,
-		validatorIndexToProcessedConsolidation)
+		validatorIndexToProcessedConsolidation,
+		validatorIndexToDepositBalanceChange,
+		validatorIndexToReportedReward)
 
 	if err != nil {
 		return schemas.ValidatorPerformanceMetrics{}, err
@@ -227,9 +308,8 @@ func (p *BeaconState) PopulateParticipationAndBalance(
 	metrics.EarnedBalance = earnedBalance
 	metrics.LosedBalance = lostBalance
 	metrics.PoolName = poolName
-	metrics.Time = time.Unix(int64(GetTimestamp(beaconState)), 0)
-
 	metrics.Epoch = GetSlot(beaconState) / p.networkParameters.slotsInEpoch
+	metrics.Time = p.networkParameters.Clock().EpochStart(metrics.Epoch)
 
 	metrics.NOfTotalVotes = uint64(len(activeValidatorIndexes)) * 3
 	metrics.NOfIncorrectSource = nOfIncorrectSource
@@ -240,19 +320,41 @@ func (p *BeaconState) PopulateParticipationAndBalance(
 	//metrics.EarnedBalance = earned
 	//metrics.LosedBalance = losed
 	metrics.IndexesMissedAtt = indexesMissedAtt
+	metrics.MissedAttestations = p.GetMissedAttestations(poolName, activeValidatorIndexes, beaconState)
 	//metrics.LostBalanceKeys = lostKeys
 	metrics.TotalBalance = currentBalance
 	metrics.EffectiveBalance = currentEffectiveBalance
 	metrics.TotalRewards = rewards
 	metrics.DeltaEpochBalance = deltaEpochBalance
+	metrics.EffectiveBalanceChange = new(big.Int).Sub(currentEffectiveBalance, prevEffectiveBalance)
+	metrics.NOfCompoundingValidators = GetNumberOfCompounding(activeValidatorIndexes, beaconState)
 
 	return metrics, nil
 }
 
 // TODO: Get slashed validators
 
-func (p *BeaconState) GetBeaconState(epoch uint64) (*spec.VersionedBeaconState, error) {
-	log.Info("Fetching beacon state for epoch: ", epoch)
+// Removes the cached states used to process the epoch, its own and the one of
+// the previous epoch, since they may be of the blocks before a reorg
+func (p *BeaconState) InvalidateStates(epoch uint64) {
+	if p.stateCache == nil {
+		return
+	}
+	p.stateCache.Invalidate(epoch)
+	if epoch > 0 {
+		p.stateCache.Invalidate(epoch - 1)
+	}
+}
+
+func (p *BeaconState) GetBeaconState(ctx context.Context, epoch uint64) (*spec.VersionedBeaconState, error) {
+	if p.stateCache != nil {
+		if state, ok := p.stateCache.Get(epoch); ok {
+			log.WithField("Epoch", epoch).Info("Got beacon state from cache")
+			return state, nil
+		}
+	}
+
+	log.WithField("Epoch", epoch).Info("Fetching beacon state")
 	// Its important to get the beacon state from the last slot of each epoch
 	// to allow all attestations to be included
 	// If epoch=1, slot = epoch*32 = 32, which is the first slot of epoch 1
@@ -260,23 +362,19 @@ func (p *BeaconState) GetBeaconState(epoch uint64) (*spec.VersionedBeaconState,
 	// goes to the last slot of the previous epoch
 	slotStr := strconv.FormatUint((epoch+1)*p.networkParameters.slotsInEpoch-1, 10)
 
-	ctxTimeout, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(p.config.StateTimeout))
-	defer cancel()
-	opts := api.BeaconStateOpts{
-		State: slotStr,
-		// Override http client timeout
-		Common: api.CommonOpts{
-			Timeout: time.Second * time.Duration(p.config.StateTimeout),
-		},
-	}
-	beaconState, err := p.consensus.BeaconState(
-		ctxTimeout,
-		&opts)
+	beaconState, err := p.stateDownloader.Download(ctx, slotStr)
 	if err != nil {
 		return nil, err
 	}
-	log.Info("Got beacon state for epoch:", GetSlot(beaconState.Data)/p.networkParameters.slotsInEpoch)
-	return beaconState.Data, nil
+	if err := forkdata.CheckState(beaconState); err != nil {
+		return nil, err
+	}
+	log.WithField("Epoch", GetSlot(beaconState)/p.networkParameters.slotsInEpoch).Info("Got beacon state")
+
+	if p.stateCache != nil {
+		return p.stateCache.Put(epoch, beaconState), nil
+	}
+	return beaconState, nil
 }
 
 func GetTotalBalanceAndEffective(
@@ -302,6 +400,65 @@ func GetTotalBalanceAndEffective(
 	return totalBalances, effectiveBalance
 }
 
+// Returns how many of the given validators have 0x02 withdrawal credentials,
+// which allow an effective balance above 32 ETH
+func GetNumberOfCompounding(
+	activeValidatorIndexes []uint64,
+	beaconState *spec.VersionedBeaconState) uint64 {
+
+	validators := GetValidators(beaconState)
+	var nOfCompounding uint64
+	for _, valIdx := range activeValidatorIndexes {
+		if valIdx < uint64(len(validators)) && isCompounding(validators[valIdx]) {
+			nOfCompounding++
+		}
+	}
+	return nOfCompounding
+}
+
+// Sums the rewards of the given validators
+func GetPoolRewards(
+	activeValidatorIndexes []uint64,
+	rewards map[uint64]*big.Int) int64 {
+
+	poolRewards := big.NewInt(0)
+	for _, valIdx := range activeValidatorIndexes {
+		if reward, ok := rewards[valIdx]; ok {
+			poolRewards.Add(poolRewards, reward)
+		}
+	}
+	return poolRewards.Int64()
+}
+
+// Sums the tips, in wei, of the blocks without MEV rewards proposed by the given
+// validators. Tips can exceed an int64, unlike the rewards in gwei.
+func GetPoolProposerTips(
+	activeValidatorIndexes []uint64,
+	proposerTips map[uint64]*big.Int) *big.Int {
+
+	poolTips := big.NewInt(0)
+	for _, valIdx := range activeValidatorIndexes {
+		if tip, ok := proposerTips[valIdx]; ok {
+			poolTips.Add(poolTips, tip)
+		}
+	}
+	return poolTips
+}
+
+// Adds up the rewards of each validator
+func MergeRewards(rewards ...map[uint64]*big.Int) map[uint64]*big.Int {
+	merged := make(map[uint64]*big.Int)
+	for _, validatorRewards := range rewards {
+		for valIdx, reward := range validatorRewards {
+			if _, ok := merged[valIdx]; !ok {
+				merged[valIdx] = big.NewInt(0)
+			}
+			merged[valIdx].Add(merged[valIdx], reward)
+		}
+	}
+	return merged
+}
+
 // Returns the indexes of the validator keys. Note that the indexes
 // may belong to active, inactive or even slashed keys.
 func GetIndexesFromKeys(
@@ -315,7 +472,8 @@ func GetIndexesFromKeys(
 		if valIndex, ok := valKeyToIndex[hex.EncodeToString(key)]; ok {
 			indexes = append(indexes, valIndex)
 		} else {
-			log.Warn("Index for key: ", hex.EncodeToString(key), " not found in beacon state")
+			// Reported by the periodic check of the tracked keys
+			log.Debug("Index for key: ", hex.EncodeToString(key), " not found in beacon state")
 		}
 	}
 
@@ -346,7 +504,9 @@ func (p *BeaconState) GetValidatorsWithLessBalance(
 	prevBeaconState *spec.VersionedBeaconState,
 	currentBeaconState *spec.VersionedBeaconState,
 	validatorIndexToWithdrawalAmount map[uint64]*big.Int,
-	validatorIndexToProcessedConsolidation map[uint64][]*electra.PendingConsolidation) ([]uint64, *big.Int, *big.Int, error) {
+	validatorIndexToProcessedConsolidation map[uint64][]*electra.PendingConsolidation,
+	validatorIndexToDepositBalanceChange map[uint64]*big.Int,
+	validatorIndexToReportedReward map[uint64]*big.Int) ([]uint64, *big.Int, *big.Int, error) {
 
 	prevEpoch := GetSlot(prevBeaconState) / p.networkParameters.slotsInEpoch
 	currEpoch := GetSlot(currentBeaconState) / p.networkParameters.slotsInEpoch
@@ -372,21 +532,15 @@ func (p *BeaconState) GetValidatorsWithLessBalance(
 			continue
 		}
 
-		prevEpochValBalance := big.NewInt(0).SetUint64(prevBalances[valIdx])
-		currentEpochValBalance := big.NewInt(0).SetUint64(currBalances[valIdx])
-		// Check if there is a withdrawal amount and add it to the balance
-		if valWithdrawalAmount, ok := validatorIndexToWithdrawalAmount[valIdx]; ok {
-			currentEpochValBalance.Add(currentEpochValBalance, valWithdrawalAmount)
-		}
-		// Check if there are consolidations and substract source effective balance
-		if consolidations, ok := validatorIndexToProcessedConsolidation[valIdx]; ok {
-			for _, consolidation := range consolidations {
-				sourceBalance := big.NewInt(0).SetUint64(uint64(prevValidators[consolidation.SourceIndex].EffectiveBalance))
-				currentEpochValBalance.Sub(currentEpochValBalance, sourceBalance)
-			}
-		}
-
-		delta := big.NewInt(0).Sub(currentEpochValBalance, prevEpochValBalance)
+		delta := GetValidatorBalanceDelta(
+			valIdx,
+			prevBalances,
+			currBalances,
+			prevValidators,
+			validatorIndexToWithdrawalAmount,
+			validatorIndexToProcessedConsolidation,
+			validatorIndexToDepositBalanceChange,
+			validatorIndexToReportedReward)
 
 		if delta.Cmp(big.NewInt(0)) == -1 {
 			indexesWithLessBalance = append(indexesWithLessBalance, valIdx)
@@ -399,6 +553,99 @@ func (p *BeaconState) GetValidatorsWithLessBalance(
 	return indexesWithLessBalance, earnedBalance, lostBalance, nil
 }
 
+// Returns the balance difference of a validator between two consecutive epochs,
+// adding back the withdrawn amount and removing the consolidated balances, the
+// balance moved by deposits and the rewards that are reported on their own
+func GetValidatorBalanceDelta(
+	valIdx uint64,
+	prevBalances []uint64,
+	currBalances []uint64,
+	prevValidators []*phase0.Validator,
+	validatorIndexToWithdrawalAmount map[uint64]*big.Int,
+	validatorIndexToProcessedConsolidation map[uint64][]*electra.PendingConsolidation,
+	validatorIndexToDepositBalanceChange map[uint64]*big.Int,
+	validatorIndexToReportedReward map[uint64]*big.Int) *big.Int {
+
+	prevEpochValBalance := big.NewInt(0).SetUint64(prevBalances[valIdx])
+	currentEpochValBalance := big.NewInt(0).SetUint64(currBalances[valIdx])
+	// Check if there is a withdrawal amount and add it to the balance
+	if valWithdrawalAmount, ok := validatorIndexToWithdrawalAmount[valIdx]; ok {
+		currentEpochValBalance.Add(currentEpochValBalance, valWithdrawalAmount)
+	}
+	// Check if there are consolidations and substract the moved balance, which is
+	// the source effective balance unless the source has less balance
+	if consolidations, ok := validatorIndexToProcessedConsolidation[valIdx]; ok {
+		for _, consolidation := range consolidations {
+			sourceBalance := big.NewInt(0).SetUint64(min(
+				uint64(prevValidators[consolidation.SourceIndex].EffectiveBalance),
+				prevBalances[consolidation.SourceIndex]))
+			currentEpochValBalance.Sub(currentEpochValBalance, sourceBalance)
+		}
+	}
+	// Deposits are not rewards
+	if depositBalanceChange, ok := validatorIndexToDepositBalanceChange[valIdx]; ok {
+		currentEpochValBalance.Sub(currentEpochValBalance, depositBalanceChange)
+	}
+	// Sync committee and proposer rewards are reported on their own
+	if reportedReward, ok := validatorIndexToReportedReward[valIdx]; ok {
+		currentEpochValBalance.Sub(currentEpochValBalance, reportedReward)
+	}
+
+	return big.NewInt(0).Sub(currentEpochValBalance, prevEpochValBalance)
+}
+
+// Builds one row per active validator of the pool, with its balance delta
+// and the participation flags of the previous epoch
+func (p *BeaconState) GetValidatorMetrics(
+	poolName string,
+	activeValidatorIndexes []uint64,
+	currentBeaconState *spec.VersionedBeaconState,
+	prevBeaconState *spec.VersionedBeaconState,
+	validatorIndexToWithdrawalAmount map[uint64]*big.Int,
+	validatorIndexToProcessedConsolidation map[uint64][]*electra.PendingConsolidation,
+	validatorIndexToDepositBalanceChange map[uint64]*big.Int,
+	validatorIndexToReportedReward map[uint64]*big.Int) []schemas.ValidatorMetrics {
+
+	epoch := GetSlot(currentBeaconState) / p.networkParameters.slotsInEpoch
+	timestamp := p.networkParameters.Clock().EpochStart(epoch)
+	validators := GetValidators(currentBeaconState)
+	prevValidators := GetValidators(prevBeaconState)
+	currBalances := GetBalances(currentBeaconState)
+	prevBalances := GetBalances(prevBeaconState)
+	previousEpochParticipation := GetPreviousEpochParticipation(currentBeaconState)
+
+	validatorMetrics := make([]schemas.ValidatorMetrics, 0, len(activeValidatorIndexes))
+	for _, valIdx := range activeValidatorIndexes {
+		if valIdx >= uint64(len(prevBalances)) {
+			log.Warn("validator index goes beyond the beacon state indexes")
+			continue
+		}
+
+		flags := uint8(previousEpochParticipation[valIdx])
+		validatorMetrics = append(validatorMetrics, schemas.ValidatorMetrics{
+			Time:     timestamp,
+			PoolName: poolName,
+			Epoch:    epoch,
+			ValIndex: valIdx,
+			PubKey:   "0x" + hex.EncodeToString(validators[valIdx].PublicKey[:]),
+			BalanceDelta: GetValidatorBalanceDelta(
+				valIdx,
+				prevBalances,
+				currBalances,
+				prevValidators,
+				validatorIndexToWithdrawalAmount,
+				validatorIndexToProcessedConsolidation,
+				validatorIndexToDepositBalanceChange,
+				validatorIndexToReportedReward),
+			MissedSource:        !isBitSet(flags, 0),
+			MissedTarget:        !isBitSet(flags, 1),
+			MissedHead:          !isBitSet(flags, 2),
+			AttestationIncluded: flags != 0,
+		})
+	}
+	return validatorMetrics
+}
+
 func (p *BeaconState) ParticipationDebug(
 	activeValidatorIndexes []uint64,
 	beaconState *spec.VersionedBeaconState) {
@@ -485,6 +732,69 @@ func (p *BeaconState) GetParticipation(
 	return nIncorrectSource, nIncorrectTarget, nIncorrectHead, indexesMissedAtt
 }
 
+// Returns the votes that each validator missed, with the same rules as
+// GetParticipation, so that the validators behind the counts can be found
+func (p *BeaconState) GetMissedAttestations(
+	poolName string,
+	activeValidatorIndexes []uint64,
+	beaconState *spec.VersionedBeaconState) []schemas.MissedAttestation {
+
+	missed := make([]schemas.MissedAttestation, 0)
+
+	validators := GetValidators(beaconState)
+	previousEpochParticipation := GetPreviousEpochParticipation(beaconState)
+	beaconStateEpoch := GetSlot(beaconState) / p.networkParameters.slotsInEpoch
+
+	for _, valIndx := range activeValidatorIndexes {
+		if validators[valIndx].Slashed || uint64(validators[valIndx].ActivationEpoch) > beaconStateEpoch {
+			continue
+		}
+		epochAttestations := uint8(previousEpochParticipation[valIndx])
+		missTypes := make([]schemas.MissType, 0)
+		if epochAttestations&0b111 == 0 {
+			missTypes = append(missTypes, schemas.MissedAllVotes)
+		} else {
+			for bit, missType := range []schemas.MissType{schemas.MissedSource, schemas.MissedTarget, schemas.MissedHead} {
+				if !isBitSet(epochAttestations, bit) {
+					missTypes = append(missTypes, missType)
+				}
+			}
+		}
+		for _, missType := range missTypes {
+			missed = append(missed, schemas.MissedAttestation{
+				Epoch:    beaconStateEpoch,
+				PoolName: poolName,
+				ValIndex: valIndx,
+				MissType: missType,
+			})
+		}
+	}
+	return missed
+}
+
+// Returns the validators that were slashed between the previous and the current state
+func GetSlashedIndexes(
+	validatorIndexes []uint64,
+	prevBeaconState *spec.VersionedBeaconState,
+	currentBeaconState *spec.VersionedBeaconState) []uint64 {
+
+	slashedIndexes := make([]uint64, 0)
+	prevValidators := GetValidators(prevBeaconState)
+	currValidators := GetValidators(currentBeaconState)
+
+	for _, valIdx := range validatorIndexes {
+		if valIdx >= uint64(len(currValidators)) || !currValidators[valIdx].Slashed {
+			continue
+		}
+		// New validators can't be slashed in the previous state
+		if valIdx < uint64(len(prevValidators)) && prevValidators[valIdx].Slashed {
+			continue
+		}
+		slashedIndexes = append(slashedIndexes, valIdx)
+	}
+	return slashedIndexes
+}
+
 // Check if bit n (0..7) is set where 0 is the LSB in little endian
 func isBitSet(input uint8, n int) bool {
 	return (input & (1 << n)) > uint8(0)
@@ -555,52 +865,42 @@ func logMetrics(
 		"ValidadorKeyLessBalance":     metrics.IndexesLessBalance,
 		"DeltaEpochBalance":           metrics.DeltaEpochBalance,
 		"epochMEVRewards":             metrics.MEVRewards,
+		"avgInclusionDelay":           metrics.AvgInclusionDelay,
+		"maxInclusionDelay":           metrics.MaxInclusionDelay,
+		"nOfSlashedValidators":        metrics.NOfSlashedValidators,
+		"headRewards":                 metrics.AttestationRewards.Head,
+		"targetRewards":               metrics.AttestationRewards.Target,
+		"sourceRewards":               metrics.AttestationRewards.Source,
+		"inactivityPenalties":         metrics.AttestationRewards.Inactivity,
+		"idealAttestationRewards":     metrics.AttestationRewards.Ideal,
+		"syncCommitteeRewards":        metrics.SyncCommitteeRewards,
+		"proposerRewards":             metrics.ProposerRewards,
+		"effectiveBalanceChange":      metrics.EffectiveBalanceChange,
+		"nOfCompoundingValidators":    metrics.NOfCompoundingValidators,
+		"partialWithdrawals":          metrics.PartialWithdrawals,
+		"fullWithdrawals":             metrics.FullWithdrawals,
+		"ethPriceUsd":                 metrics.EthPriceUsd,
+		"earnedUsd":                   metrics.EarnedUsd,
+		"mevRewardsUsd":               metrics.MEVRewardsUsd,
 	}).Info(poolName + " Stats:")
 }
 
-// Wrappers on top of the beacon state to fetch some fields regardless of Altair or Bellatrix
-// Note that this is needed because both block types do not implement the same interface, since
-// the state differs accross versions.
-// Note also that this functions only make sense for the beacon state fields that are common
-// to all the versioned beacon states.
+// Fields of the beacon state used all over the metrics. The states are checked
+// with forkdata.CheckState when fetched, so forkdata can't fail on them and an
+// error means a bug, which is logged instead of stopping the processing.
+func stateField[T any](value T, err error) T {
+	if err != nil {
+		log.Error("could not read beacon state: ", err)
+	}
+	return value
+}
+
 func GetValidators(beaconState *spec.VersionedBeaconState) []*phase0.Validator {
-	var validators []*phase0.Validator
-	if beaconState.Altair != nil {
-		validators = beaconState.Altair.Validators
-	} else if beaconState.Bellatrix != nil {
-		validators = beaconState.Bellatrix.Validators
-	} else if beaconState.Capella != nil {
-		validators = beaconState.Capella.Validators
-	} else if beaconState.Deneb != nil {
-		validators = beaconState.Deneb.Validators
-	} else if beaconState.Electra != nil {
-		validators = beaconState.Electra.Validators
-	} else if beaconState.Fulu != nil {
-		validators = beaconState.Fulu.Validators
-	} else {
-		log.Fatal("Beacon state was empty")
-	}
-	return validators
+	return stateField(forkdata.Validators(beaconState))
 }
 
 func GetBalances(beaconState *spec.VersionedBeaconState) []uint64 {
-	var tmpBalances []phase0.Gwei
-	if beaconState.Altair != nil {
-		tmpBalances = beaconState.Altair.Balances
-	} else if beaconState.Bellatrix != nil {
-		tmpBalances = beaconState.Bellatrix.Balances
-	} else if beaconState.Capella != nil {
-		tmpBalances = beaconState.Capella.Balances
-	} else if beaconState.Deneb != nil {
-		tmpBalances = beaconState.Deneb.Balances
-	} else if beaconState.Electra != nil {
-		tmpBalances = beaconState.Electra.Balances
-	} else if beaconState.Fulu != nil {
-		tmpBalances = beaconState.Fulu.Balances
-	} else {
-		log.Fatal("Beacon state was empty")
-	}
-
+	tmpBalances := stateField(forkdata.Balances(beaconState))
 	balances := make([]uint64, len(tmpBalances))
 	for i := range tmpBalances {
 		balances[i] = uint64(tmpBalances[i])
@@ -609,91 +909,37 @@ func GetBalances(beaconState *spec.VersionedBeaconState) []uint64 {
 }
 
 func GetPreviousEpochParticipation(beaconState *spec.VersionedBeaconState) []altair.ParticipationFlags {
-	var previousEpochParticipation []altair.ParticipationFlags
-	if beaconState.Altair != nil {
-		previousEpochParticipation = beaconState.Altair.PreviousEpochParticipation
-	} else if beaconState.Bellatrix != nil {
-		previousEpochParticipation = beaconState.Bellatrix.PreviousEpochParticipation
-	} else if beaconState.Capella != nil {
-		previousEpochParticipation = beaconState.Capella.PreviousEpochParticipation
-	} else if beaconState.Deneb != nil {
-		previousEpochParticipation = beaconState.Deneb.PreviousEpochParticipation
-	} else if beaconState.Electra != nil {
-		previousEpochParticipation = beaconState.Electra.PreviousEpochParticipation
-	} else if beaconState.Fulu != nil {
-		previousEpochParticipation = beaconState.Fulu.PreviousEpochParticipation
-	} else {
-		log.Fatal("Beacon state was empty")
-	}
-	return previousEpochParticipation
+	return stateField(forkdata.PreviousEpochParticipation(beaconState))
 }
 
 func GetSlot(beaconState *spec.VersionedBeaconState) uint64 {
-	var slot uint64
-	if beaconState.Altair != nil {
-		slot = uint64(beaconState.Altair.Slot)
-	} else if beaconState.Bellatrix != nil {
-		slot = uint64(beaconState.Bellatrix.Slot)
-	} else if beaconState.Capella != nil {
-		slot = uint64(beaconState.Capella.Slot)
-	} else if beaconState.Deneb != nil {
-		slot = uint64(beaconState.Deneb.Slot)
-	} else if beaconState.Electra != nil {
-		slot = uint64(beaconState.Electra.Slot)
-	} else if beaconState.Fulu != nil {
-		slot = uint64(beaconState.Fulu.Slot)
-	} else {
-		log.Fatal("Beacon state was empty")
-	}
-	return slot
+	return stateField(forkdata.Slot(beaconState))
 }
 
 func GetTimestamp(beaconState *spec.VersionedBeaconState) uint64 {
-	var timestamp uint64
-	if beaconState.Bellatrix != nil {
-		timestamp = uint64(beaconState.Bellatrix.LatestExecutionPayloadHeader.Timestamp)
-	} else if beaconState.Capella != nil {
-		timestamp = uint64(beaconState.Capella.LatestExecutionPayloadHeader.Timestamp)
-	} else if beaconState.Deneb != nil {
-		timestamp = uint64(beaconState.Deneb.LatestExecutionPayloadHeader.Timestamp)
-	} else if beaconState.Electra != nil {
-		timestamp = uint64(beaconState.Electra.LatestExecutionPayloadHeader.Timestamp)
-	} else if beaconState.Fulu != nil {
-		timestamp = uint64(beaconState.Fulu.LatestExecutionPayloadHeader.Timestamp)
-	} else {
-		log.Fatal("Could not get timestamp from beacon state")
-	}
-	return timestamp
+	return stateField(forkdata.Timestamp(beaconState))
 }
 
 func GetCurrentSyncCommittee(beaconState *spec.VersionedBeaconState) []phase0.BLSPubKey {
-	var pubKeys []phase0.BLSPubKey
-	if beaconState.Altair != nil {
-		pubKeys = beaconState.Altair.CurrentSyncCommittee.Pubkeys
-	} else if beaconState.Bellatrix != nil {
-		pubKeys = beaconState.Bellatrix.CurrentSyncCommittee.Pubkeys
-	} else if beaconState.Capella != nil {
-		pubKeys = beaconState.Capella.CurrentSyncCommittee.Pubkeys
-	} else if beaconState.Deneb != nil {
-		pubKeys = beaconState.Deneb.CurrentSyncCommittee.Pubkeys
-	} else if beaconState.Electra != nil {
-		pubKeys = beaconState.Electra.CurrentSyncCommittee.Pubkeys
-	} else if beaconState.Fulu != nil {
-		pubKeys = beaconState.Fulu.CurrentSyncCommittee.Pubkeys
-	} else {
-		log.Fatal("Beacon state was empty")
-	}
-	return pubKeys
+	return stateField(forkdata.CurrentSyncCommittee(beaconState))
 }
 
 func GetPendingConsolidations(beaconState *spec.VersionedBeaconState) []*electra.PendingConsolidation {
-	var pendingConsolidations []*electra.PendingConsolidation
-	if beaconState.Electra != nil {
-		pendingConsolidations = beaconState.Electra.PendingConsolidations
-	} else if beaconState.Fulu != nil {
-		pendingConsolidations = beaconState.Fulu.PendingConsolidations
-	} else {
-		log.Fatal("Beacon state was empty")
-	}
-	return pendingConsolidations
+	return stateField(forkdata.PendingConsolidations(beaconState))
+}
+
+func GetPendingDeposits(beaconState *spec.VersionedBeaconState) []*electra.PendingDeposit {
+	return stateField(forkdata.PendingDeposits(beaconState))
+}
+
+func GetPendingPartialWithdrawals(beaconState *spec.VersionedBeaconState) []*electra.PendingPartialWithdrawal {
+	return stateField(forkdata.PendingPartialWithdrawals(beaconState))
+}
+
+func GetDepositBalanceToConsume(beaconState *spec.VersionedBeaconState) uint64 {
+	return stateField(forkdata.DepositBalanceToConsume(beaconState))
+}
+
+func GetEarliestExitEpoch(beaconState *spec.VersionedBeaconState) uint64 {
+	return stateField(forkdata.EarliestExitEpoch(beaconState))
 }
@@ -7,8 +7,10 @@ import (
 
 	"github.com/attestantio/go-eth2-client/spec"
 	"github.com/attestantio/go-eth2-client/spec/altair"
+	"github.com/attestantio/go-eth2-client/spec/deneb"
 	"github.com/attestantio/go-eth2-client/spec/electra"
 	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bilinearlabs/eth-metrics/schemas"
 
 	"github.com/stretchr/testify/require"
 )
@@ -90,6 +92,8 @@ func Test_GetValidatorsWithLessBalance(t *testing.T) {
 		currentBeaconState,
 		map[uint64]*big.Int{},
 		map[uint64][]*electra.PendingConsolidation{},
+		map[uint64]*big.Int{},
+		map[uint64]*big.Int{},
 	)
 
 	require.NoError(t, err)
@@ -122,7 +126,9 @@ func Test_GetValidatorsWithLessBalance_NonConsecutive(t *testing.T) {
 		prevBeaconState,
 		currentBeaconState,
 		map[uint64]*big.Int{},
-		map[uint64][]*electra.PendingConsolidation{})
+		map[uint64][]*electra.PendingConsolidation{},
+		map[uint64]*big.Int{},
+		map[uint64]*big.Int{})
 
 	require.Error(t, err)
 }
@@ -180,6 +186,16 @@ func Test_GetParticipation(t *testing.T) {
 	require.Equal(t, uint64(2), target)
 	require.Equal(t, uint64(4), head)
 	require.Equal(t, []uint64{3, 4}, indexesMissedAtt)
+
+	missed := b.GetMissedAttestations("pool", validatorIndexes, beaconState)
+	require.Equal(t, []schemas.MissedAttestation{
+		{Epoch: 0, PoolName: "pool", ValIndex: 1, MissType: schemas.MissedHead},
+		{Epoch: 0, PoolName: "pool", ValIndex: 2, MissType: schemas.MissedHead},
+		{Epoch: 0, PoolName: "pool", ValIndex: 3, MissType: schemas.MissedSource},
+		{Epoch: 0, PoolName: "pool", ValIndex: 3, MissType: schemas.MissedTarget},
+		{Epoch: 0, PoolName: "pool", ValIndex: 4, MissType: schemas.MissedAllVotes},
+		{Epoch: 0, PoolName: "pool", ValIndex: 5, MissType: schemas.MissedHead},
+	}, missed)
 }
 
 func Test_PopulateKeysToIndexesMap(t *testing.T) {
@@ -305,3 +321,142 @@ func Test_GetProcessedConsolidations_NilPendingConsolidations(t *testing.T) {
 	require.Error(t, err)
 	require.Nil(t, processedConsolidations)
 }
+
+func Test_GetValidatorMetrics(t *testing.T) {
+	b := &BeaconState{
+		networkParameters: &NetworkParameters{
+			slotsInEpoch: 32,
+		},
+	}
+
+	prevBeaconState := &spec.VersionedBeaconState{
+		Electra: &electra.BeaconState{
+			Slot:       34 * 32,
+			Balances:   []phase0.Gwei{1000, 9000, 2000},
+			Validators: []*phase0.Validator{{PublicKey: validator_0}, {PublicKey: validator_1}, {PublicKey: validator_2}},
+		},
+	}
+
+	currentBeaconState := &spec.VersionedBeaconState{
+		Electra: &electra.BeaconState{
+			Slot:       35 * 32,
+			Balances:   []phase0.Gwei{900, 9500, 1000},
+			Validators: []*phase0.Validator{{PublicKey: validator_0}, {PublicKey: validator_1}, {PublicKey: validator_2}},
+			PreviousEpochParticipation: []altair.ParticipationFlags{
+				0b00000111,
+				0b00000011,
+				0b00000000,
+			},
+			LatestExecutionPayloadHeader: &deneb.ExecutionPayloadHeader{
+				Timestamp: 1673308800,
+			},
+		},
+	}
+
+	validatorMetrics := b.GetValidatorMetrics(
+		"pool",
+		[]uint64{0, 1, 2},
+		currentBeaconState,
+		prevBeaconState,
+		map[uint64]*big.Int{2: big.NewInt(1000)},
+		map[uint64][]*electra.PendingConsolidation{},
+		map[uint64]*big.Int{},
+		map[uint64]*big.Int{},
+	)
+
+	require.Equal(t, 3, len(validatorMetrics))
+
+	require.Equal(t, uint64(35), validatorMetrics[0].Epoch)
+	require.Equal(t, "pool", validatorMetrics[0].PoolName)
+	require.Equal(t, "0x"+hex.EncodeToString(validator_0[:]), validatorMetrics[0].PubKey)
+	require.Equal(t, big.NewInt(-100), validatorMetrics[0].BalanceDelta)
+	require.False(t, validatorMetrics[0].MissedSource)
+	require.False(t, validatorMetrics[0].MissedTarget)
+	require.False(t, validatorMetrics[0].MissedHead)
+	require.True(t, validatorMetrics[0].AttestationIncluded)
+
+	require.Equal(t, big.NewInt(500), validatorMetrics[1].BalanceDelta)
+	require.True(t, validatorMetrics[1].MissedHead)
+	require.True(t, validatorMetrics[1].AttestationIncluded)
+
+	// Withdrawal is added back to the balance
+	require.Equal(t, int64(0), validatorMetrics[2].BalanceDelta.Int64())
+	require.True(t, validatorMetrics[2].MissedSource)
+	require.True(t, validatorMetrics[2].MissedTarget)
+	require.True(t, validatorMetrics[2].MissedHead)
+	require.False(t, validatorMetrics[2].AttestationIncluded)
+}
+
+func Test_GetSlashedIndexes(t *testing.T) {
+	prevBeaconState := &spec.VersionedBeaconState{
+		Electra: &electra.BeaconState{
+			Validators: []*phase0.Validator{
+				{Slashed: false},
+				{Slashed: true},
+				{Slashed: false},
+			},
+		},
+	}
+	currentBeaconState := &spec.VersionedBeaconState{
+		Electra: &electra.BeaconState{
+			Validators: []*phase0.Validator{
+				{Slashed: true},
+				{Slashed: true},
+				{Slashed: false},
+				{Slashed: true},
+			},
+		},
+	}
+
+	// 1 was already slashed, 2 is not slashed and 3 is not in the previous state
+	slashedIndexes := GetSlashedIndexes([]uint64{0, 1, 2, 3}, prevBeaconState, currentBeaconState)
+	require.Equal(t, []uint64{0, 3}, slashedIndexes)
+}
+
+func Test_MergeRewards(t *testing.T) {
+	merged := MergeRewards(
+		map[uint64]*big.Int{1: big.NewInt(100), 2: big.NewInt(-20)},
+		map[uint64]*big.Int{1: big.NewInt(5), 3: big.NewInt(7)},
+		nil)
+	require.Equal(t, map[uint64]*big.Int{
+		1: big.NewInt(105),
+		2: big.NewInt(-20),
+		3: big.NewInt(7),
+	}, merged)
+}
+
+func Test_GetPoolProposerTips(t *testing.T) {
+	// Above an int64
+	tip, _ := new(big.Int).SetString("10000000000000000000", 10)
+	proposerTips := map[uint64]*big.Int{
+		1: tip,
+		2: big.NewInt(5),
+		// Proposer of another pool
+		9: big.NewInt(1000),
+	}
+	expected, _ := new(big.Int).SetString("10000000000000000005", 10)
+	require.Equal(t, expected, GetPoolProposerTips([]uint64{1, 2, 3}, proposerTips))
+	require.Equal(t, big.NewInt(0), GetPoolProposerTips([]uint64{3}, proposerTips))
+
+	// Not modified
+	require.Equal(t, big.NewInt(5), proposerTips[2])
+}
+
+func Test_GetNumberOfCompounding(t *testing.T) {
+	compounding := make([]byte, 32)
+	compounding[0] = 0x02
+	eth1 := make([]byte, 32)
+	eth1[0] = 0x01
+	beaconState := &spec.VersionedBeaconState{
+		Altair: &altair.BeaconState{
+			Validators: []*phase0.Validator{
+				{WithdrawalCredentials: compounding},
+				{WithdrawalCredentials: eth1},
+				{WithdrawalCredentials: compounding},
+				{WithdrawalCredentials: compounding},
+			},
+		},
+	}
+	// Validator 3 is not part of the pool and 5 is not in the state
+	require.Equal(t, uint64(2), GetNumberOfCompounding([]uint64{0, 1, 2, 5}, beaconState))
+}
@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"math/big"
+
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/bilinearlabs/eth-metrics/forkdata"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+)
+
+// Blob parameters of EIP-4844 and EIP-7691
+const (
+	gasPerBlob       = 131072
+	minBlobBaseFee   = 1
+	denebMaxBlobs    = 6
+	electraMaxBlobs  = 9
+	denebBlobFeeFrac = 3338477
+	// Fulu starts with the electra parameters, the blob parameter only forks
+	// that raise them later are not known
+	electraBlobFeeFrac = 5007716
+)
+
+// Returns the blobs of the block and the fee burned for them, false if the block
+// is before deneb
+func (b *BlockData) ExtractBlobs(beaconBlock *spec.VersionedSignedBeaconBlock, epoch uint64, slot uint64) (schemas.BlockBlobs, bool, error) {
+	fork, err := forkdata.BlockFork(beaconBlock)
+	if err != nil {
+		return schemas.BlockBlobs{}, false, err
+	}
+	if fork < spec.DataVersionDeneb {
+		return schemas.BlockBlobs{}, false, nil
+	}
+	blobGasUsed, excessBlobGas, err := forkdata.BlobGas(beaconBlock)
+	if err != nil {
+		return schemas.BlockBlobs{}, false, err
+	}
+	proposerIndex, err := forkdata.ProposerIndex(beaconBlock)
+	if err != nil {
+		return schemas.BlockBlobs{}, false, err
+	}
+	maxBlobs, updateFraction := uint64(electraMaxBlobs), uint64(electraBlobFeeFrac)
+	if fork == spec.DataVersionDeneb {
+		maxBlobs, updateFraction = denebMaxBlobs, denebBlobFeeFrac
+	}
+	blobBaseFee := GetBlobBaseFee(excessBlobGas, updateFraction)
+	return schemas.BlockBlobs{
+		Epoch:         epoch,
+		Slot:          slot,
+		ProposerIndex: proposerIndex,
+		NOfBlobs:      blobGasUsed / gasPerBlob,
+		BlobGasUsed:   blobGasUsed,
+		MaxBlobGas:    maxBlobs * gasPerBlob,
+		ExcessBlobGas: excessBlobGas,
+		BlobBaseFee:   blobBaseFee,
+		BlobFee:       new(big.Int).Mul(new(big.Int).SetUint64(blobGasUsed), blobBaseFee),
+	}, true, nil
+}
+
+// Base fee per blob gas in wei, as the fake_exponential of EIP-4844
+func GetBlobBaseFee(excessBlobGas uint64, updateFraction uint64) *big.Int {
+	numerator := new(big.Int).SetUint64(excessBlobGas)
+	denominator := new(big.Int).SetUint64(updateFraction)
+	output := big.NewInt(0)
+	accum := new(big.Int).Mul(big.NewInt(minBlobBaseFee), denominator)
+	for i := int64(1); accum.Sign() > 0; i++ {
+		output.Add(output, accum)
+		accum.Mul(accum, numerator)
+		accum.Div(accum, new(big.Int).Mul(denominator, big.NewInt(i)))
+	}
+	return output.Div(output, denominator)
+}
+
+// Adds the blobs of the blocks of the epoch to the network stats
+func AddBlobStats(networkStats *schemas.NetworkStats, blobs map[uint64]schemas.BlockBlobs) {
+	networkStats.BlobBaseFee = big.NewInt(0)
+	lastSlot := uint64(0)
+	maxBlobGas := uint64(0)
+	for slot, blockBlobs := range blobs {
+		networkStats.NOfBlobs += blockBlobs.NOfBlobs
+		networkStats.BlobGasUsed += blockBlobs.BlobGasUsed
+		maxBlobGas += blockBlobs.MaxBlobGas
+		if slot >= lastSlot {
+			lastSlot = slot
+			networkStats.BlobBaseFee = blockBlobs.BlobBaseFee
+		}
+	}
+	if maxBlobGas > 0 {
+		networkStats.BlobUtilization = float64(networkStats.BlobGasUsed) / float64(maxBlobGas)
+	}
+}
+
+// Returns the blobs of the blocks proposed by the pool
+func GetPoolBlobs(
+	poolName string,
+	proposed []schemas.Duty,
+	blobs map[uint64]schemas.BlockBlobs) []schemas.BlockBlobs {
+
+	poolBlobs := make([]schemas.BlockBlobs, 0)
+	for _, duty := range proposed {
+		blockBlobs, ok := blobs[duty.Slot]
+		if !ok {
+			continue
+		}
+		blockBlobs.PoolName = poolName
+		poolBlobs = append(poolBlobs, blockBlobs)
+	}
+	return poolBlobs
+}
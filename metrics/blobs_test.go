@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/deneb"
+	"github.com/attestantio/go-eth2-client/spec/electra"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GetBlobBaseFee(t *testing.T) {
+	require.Equal(t, big.NewInt(1), GetBlobBaseFee(0, electraBlobFeeFrac))
+	require.Equal(t, big.NewInt(2), GetBlobBaseFee(electraBlobFeeFrac, electraBlobFeeFrac))
+	require.Equal(t, big.NewInt(470442149), GetBlobBaseFee(100000000, electraBlobFeeFrac))
+	require.Equal(t, big.NewInt(10203769476395), GetBlobBaseFee(100000000, denebBlobFeeFrac))
+}
+
+func Test_ExtractBlobs(t *testing.T) {
+	bd := &BlockData{}
+
+	block := &spec.VersionedSignedBeaconBlock{
+		Electra: &electra.SignedBeaconBlock{
+			Message: &electra.BeaconBlock{
+				ProposerIndex: 7,
+				Body: &electra.BeaconBlockBody{
+					ExecutionPayload: &deneb.ExecutionPayload{
+						BlobGasUsed:   3 * gasPerBlob,
+						ExcessBlobGas: 100000000,
+					},
+				},
+			},
+		},
+	}
+
+	blobs, ok, err := bd.ExtractBlobs(block, 10, 320)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, uint64(10), blobs.Epoch)
+	require.Equal(t, uint64(320), blobs.Slot)
+	require.Equal(t, uint64(7), blobs.ProposerIndex)
+	require.Equal(t, uint64(3), blobs.NOfBlobs)
+	require.Equal(t, uint64(9*gasPerBlob), blobs.MaxBlobGas)
+	require.Equal(t, big.NewInt(470442149), blobs.BlobBaseFee)
+	require.Equal(t, new(big.Int).Mul(big.NewInt(3*gasPerBlob), big.NewInt(470442149)), blobs.BlobFee)
+
+	// No blobs before deneb
+	block = &spec.VersionedSignedBeaconBlock{
+		Capella: &capella.SignedBeaconBlock{
+			Message: &capella.BeaconBlock{
+				Body: &capella.BeaconBlockBody{
+					ExecutionPayload: &capella.ExecutionPayload{Transactions: []bellatrix.Transaction{}},
+				},
+			},
+		},
+	}
+	_, ok, err = bd.ExtractBlobs(block, 10, 320)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func Test_AddBlobStats(t *testing.T) {
+	blobs := map[uint64]schemas.BlockBlobs{
+		320: {Slot: 320, NOfBlobs: 3, BlobGasUsed: 3 * gasPerBlob, MaxBlobGas: 9 * gasPerBlob, BlobBaseFee: big.NewInt(10)},
+		321: {Slot: 321, NOfBlobs: 6, BlobGasUsed: 6 * gasPerBlob, MaxBlobGas: 9 * gasPerBlob, BlobBaseFee: big.NewInt(12)},
+	}
+
+	networkStats := schemas.NetworkStats{}
+	AddBlobStats(&networkStats, blobs)
+	require.Equal(t, uint64(9), networkStats.NOfBlobs)
+	require.Equal(t, uint64(9*gasPerBlob), networkStats.BlobGasUsed)
+	require.Equal(t, 0.5, networkStats.BlobUtilization)
+	require.Equal(t, big.NewInt(12), networkStats.BlobBaseFee)
+
+	// Epoch without blocks since deneb
+	networkStats = schemas.NetworkStats{}
+	AddBlobStats(&networkStats, map[uint64]schemas.BlockBlobs{})
+	require.Equal(t, uint64(0), networkStats.NOfBlobs)
+	require.Equal(t, 0.0, networkStats.BlobUtilization)
+	require.Zero(t, networkStats.BlobBaseFee.Sign())
+}
+
+func Test_GetPoolBlobs(t *testing.T) {
+	proposed := []schemas.Duty{
+		{ValIndex: 1, Slot: 320},
+		// Before deneb
+		{ValIndex: 2, Slot: 321},
+	}
+	blobs := map[uint64]schemas.BlockBlobs{
+		320: {Slot: 320, ProposerIndex: 1, NOfBlobs: 3},
+		322: {Slot: 322, ProposerIndex: 5, NOfBlobs: 6},
+	}
+
+	poolBlobs := GetPoolBlobs("pool_a", proposed, blobs)
+	require.Equal(t, 1, len(poolBlobs))
+	require.Equal(t, uint64(320), poolBlobs[0].Slot)
+	require.Equal(t, "pool_a", poolBlobs[0].PoolName)
+	require.Equal(t, uint64(3), poolBlobs[0].NOfBlobs)
+}
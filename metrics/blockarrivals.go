@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/api"
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/bilinearlabs/eth-metrics/epochtime"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Epochs whose block arrivals are kept, enough for the epochs being processed
+// and the ones processed again after a reorg
+const blockArrivalEpochs = 8
+
+// First time the beacon node saw the block of each slot, taken from its event
+// stream. Only the latest slots are kept.
+type BlockArrivals struct {
+	mu       sync.Mutex
+	seen     map[uint64]time.Time
+	maxSlots uint64
+}
+
+func NewBlockArrivals(maxSlots uint64) *BlockArrivals {
+	return &BlockArrivals{
+		seen:     make(map[uint64]time.Time),
+		maxSlots: maxSlots,
+	}
+}
+
+// Records the time the block of the slot was seen, unless it was seen before,
+// e.g. a block of the same slot from a fork
+func (b *BlockArrivals) Record(slot uint64, seenAt time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.seen[slot]; !ok {
+		b.seen[slot] = seenAt
+	}
+	for s := range b.seen {
+		if s+b.maxSlots < slot {
+			delete(b.seen, s)
+		}
+	}
+}
+
+// Returns the time the block of the slot was first seen. Blocks are only known
+// while running, so the ones of backfilled epochs are not.
+func (b *BlockArrivals) Get(slot uint64) (time.Time, bool) {
+	if b == nil {
+		return time.Time{}, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	seenAt, ok := b.seen[slot]
+	return seenAt, ok
+}
+
+// Subscribes to the blocks imported by the beacon node. The client reconnects
+// by itself if the stream drops.
+func (a *Metrics) WatchBlockArrivals(ctx context.Context) error {
+	err := a.httpClient.Events(ctx, &api.EventsOpts{
+		Topics: []string{"block"},
+		BlockHandler: func(ctx context.Context, event *v1.BlockEvent) {
+			a.blockArrivals.Record(uint64(event.Slot), time.Now())
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "error subscribing to block events")
+	}
+	return nil
+}
+
+// Returns when the blocks proposed by the pool were first seen relative to the
+// start of their slot, flagging as late the ones seen from lateBlockMs on.
+// Blocks that were not seen are skipped.
+func GetProposalTimings(
+	epoch uint64,
+	poolName string,
+	proposed []schemas.Duty,
+	arrivals *BlockArrivals,
+	clock *epochtime.Clock,
+	lateBlockMs uint64) []schemas.ProposalTiming {
+
+	timings := make([]schemas.ProposalTiming, 0)
+	for _, duty := range proposed {
+		seenAt, ok := arrivals.Get(duty.Slot)
+		if !ok {
+			continue
+		}
+		delayMs := seenAt.Sub(clock.SlotStart(duty.Slot)).Milliseconds()
+		timings = append(timings, schemas.ProposalTiming{
+			Epoch:         epoch,
+			Slot:          duty.Slot,
+			ProposerIndex: duty.ValIndex,
+			PoolName:      poolName,
+			SeenAt:        seenAt,
+			DelayMs:       delayMs,
+			Late:          delayMs >= int64(lateBlockMs),
+		})
+	}
+	return timings
+}
+
+func logProposalTimings(timings []schemas.ProposalTiming) {
+	for _, timing := range timings {
+		logger := log.WithFields(log.Fields{
+			"Epoch":    timing.Epoch,
+			"Slot":     timing.Slot,
+			"ValIndex": timing.ProposerIndex,
+			"PoolName": timing.PoolName,
+			"DelayMs":  timing.DelayMs,
+		})
+		if timing.Late {
+			logger.Warn("Late block proposal")
+		} else {
+			logger.Debug("Block proposal seen")
+		}
+	}
+}
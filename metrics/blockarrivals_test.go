@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bilinearlabs/eth-metrics/epochtime"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_BlockArrivals(t *testing.T) {
+	arrivals := NewBlockArrivals(10)
+	first := time.Unix(1000, 0)
+
+	arrivals.Record(100, first)
+	// Only the first time is kept
+	arrivals.Record(100, first.Add(time.Second))
+	seenAt, ok := arrivals.Get(100)
+	require.True(t, ok)
+	require.Equal(t, first, seenAt)
+
+	_, ok = arrivals.Get(101)
+	require.False(t, ok)
+
+	// Older slots are dropped
+	arrivals.Record(111, first)
+	_, ok = arrivals.Get(100)
+	require.False(t, ok)
+	_, ok = arrivals.Get(111)
+	require.True(t, ok)
+
+	// Not watching the blocks
+	var noArrivals *BlockArrivals
+	_, ok = noArrivals.Get(111)
+	require.False(t, ok)
+}
+
+func Test_GetProposalTimings(t *testing.T) {
+	clock := epochtime.New(time.Unix(0, 0), 12*time.Second, 32)
+	arrivals := NewBlockArrivals(64)
+	arrivals.Record(320, clock.SlotStart(320).Add(900*time.Millisecond))
+	arrivals.Record(321, clock.SlotStart(321).Add(4200*time.Millisecond))
+
+	proposed := []schemas.Duty{
+		{ValIndex: 1, Slot: 320},
+		{ValIndex: 2, Slot: 321},
+		// Not seen
+		{ValIndex: 3, Slot: 322},
+	}
+
+	timings := GetProposalTimings(10, "pool_a", proposed, arrivals, clock, 4000)
+	require.Equal(t, []schemas.ProposalTiming{
+		{Epoch: 10, Slot: 320, ProposerIndex: 1, PoolName: "pool_a", SeenAt: clock.SlotStart(320).Add(900 * time.Millisecond), DelayMs: 900, Late: false},
+		{Epoch: 10, Slot: 321, ProposerIndex: 2, PoolName: "pool_a", SeenAt: clock.SlotStart(321).Add(4200 * time.Millisecond), DelayMs: 4200, Late: true},
+	}, timings)
+
+	require.Empty(t, GetProposalTimings(10, "pool_a", proposed, nil, clock, 4000))
+}
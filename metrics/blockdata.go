@@ -3,20 +3,22 @@ package metrics
 import (
 	"context"
 	"math/big"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/attestantio/go-eth2-client/api"
 	"github.com/attestantio/go-eth2-client/http"
 	"github.com/attestantio/go-eth2-client/spec"
 	"github.com/attestantio/go-eth2-client/spec/bellatrix"
-	"github.com/attestantio/go-eth2-client/spec/capella"
 	"github.com/avast/retry-go/v4"
 	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/bilinearlabs/eth-metrics/execution"
+	"github.com/bilinearlabs/eth-metrics/forkdata"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
@@ -25,19 +27,45 @@ import (
 type EpochBlockData struct {
 	Withdrawals  map[uint64]*big.Int
 	ProposerTips map[uint64]*big.Int
+	SlotTips     map[uint64]*big.Int
+	Slashings    []schemas.Slashing
+	Exits        []schemas.VoluntaryExit
+	BLSChanges   []schemas.BLSChange
+	Deposits     []schemas.Deposit
+	// Execution layer requests of the blocks, since electra
+	WithdrawalRequests    []schemas.WithdrawalRequest
+	ConsolidationRequests []schemas.ConsolidationRequest
+	// Graffiti of the blocks, by slot
+	SlotGraffiti map[uint64]string
+	// Fee recipient of the blocks built by their proposer, by proposer index
+	FeeRecipients map[uint64]string
+	// Fee recipient of the blocks without MEV rewards, by slot
+	SlotFeeRecipients map[uint64]string
+	// Blobs of the blocks since deneb, by slot
+	Blobs map[uint64]schemas.BlockBlobs
+	// Wei received by the fee recipient reported by the relays, by slot of the
+	// MEV blocks. Requires the execution client.
+	MEVPayouts map[uint64]*big.Int
 }
 
+// JSON-RPC error code of methods not implemented by the client
+const rpcMethodNotFound = -32601
+
 type BlockData struct {
 	consensusClient   *http.Service
-	executionClient   *ethclient.Client
+	executionClient   *execution.Client
 	networkParameters *NetworkParameters
 	config            *config.Config
 	retryOpts         []retry.Option
+	// Set once the execution client fails with method not found
+	noBlockReceipts bool
+	// Fetched on the first block before electra
+	depositContract *common.Address
 }
 
 func NewBlockData(
 	consensusClient *http.Service,
-	executionClient *ethclient.Client,
+	executionClient *execution.Client,
 	networkParameters *NetworkParameters,
 	config *config.Config,
 ) (*BlockData, error) {
@@ -53,48 +81,141 @@ func NewBlockData(
 	}, nil
 }
 
-func (b *BlockData) GetEpochBlockData(epoch uint64, slotsWithMEVRewards map[uint64]struct{}) (*EpochBlockData, error) {
-	log.Info("Fetching block data for epoch: ", epoch)
+// Parses the blocks of the epoch, by slot as returned by EpochBlocks
+func (b *BlockData) GetEpochBlockData(
+	epoch uint64,
+	blocks map[uint64]*spec.VersionedSignedBeaconBlock,
+	slotsWithMEVRewards map[uint64]schemas.RelayPayload) (*EpochBlockData, error) {
+
+	log.WithField("Epoch", epoch).Info("Parsing block data")
 
 	data := &EpochBlockData{
-		Withdrawals:  make(map[uint64]*big.Int),
-		ProposerTips: make(map[uint64]*big.Int),
+		Withdrawals:           make(map[uint64]*big.Int),
+		ProposerTips:          make(map[uint64]*big.Int),
+		SlotTips:              make(map[uint64]*big.Int),
+		Slashings:             make([]schemas.Slashing, 0),
+		Exits:                 make([]schemas.VoluntaryExit, 0),
+		BLSChanges:            make([]schemas.BLSChange, 0),
+		Deposits:              make([]schemas.Deposit, 0),
+		WithdrawalRequests:    make([]schemas.WithdrawalRequest, 0),
+		ConsolidationRequests: make([]schemas.ConsolidationRequest, 0),
+		SlotGraffiti:          make(map[uint64]string),
+		FeeRecipients:         make(map[uint64]string),
+		SlotFeeRecipients:     make(map[uint64]string),
+		Blobs:                 make(map[uint64]schemas.BlockBlobs),
+		MEVPayouts:            make(map[uint64]*big.Int),
 	}
 
+	// Blocks with deposits in the deposit contract logs, by block number
+	depositLogBlocks := make(map[uint64]uint64)
+
 	firstSlot := epoch * b.networkParameters.slotsInEpoch
 	for slot := firstSlot; slot < firstSlot+b.networkParameters.slotsInEpoch; slot++ {
-		slotStr := strconv.FormatUint(slot, 10)
-		opts := api.SignedBeaconBlockOpts{
-			Block: slotStr,
+		block, ok := blocks[slot]
+		if !ok {
+			continue
 		}
 
-		beaconBlock, err := b.consensusClient.SignedBeaconBlock(
-			context.Background(),
-			&opts,
-		)
+		if err := b.ExtractWithdrawals(block, data.Withdrawals); err != nil {
+			return nil, errors.Wrap(err, "error getting withdrawals")
+		}
+		graffiti, err := forkdata.Graffiti(block)
 		if err != nil {
-			// This error is expected in skipped or orphaned blocks
-			if !strings.Contains(err.Error(), "NOT_FOUND") {
-				return nil, errors.Wrap(err, "error getting signed beacon block")
+			return nil, errors.Wrap(err, "error getting graffiti")
+		}
+		data.SlotGraffiti[slot] = strings.TrimRight(string(graffiti[:]), "\x00")
+		slashings, err := b.ExtractSlashings(block, epoch, slot)
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting slashings")
+		}
+		data.Slashings = append(data.Slashings, slashings...)
+		exits, err := b.ExtractVoluntaryExits(block, epoch, slot)
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting voluntary exits")
+		}
+		data.Exits = append(data.Exits, exits...)
+		blsChanges, err := b.ExtractBLSChanges(block, epoch, slot)
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting bls changes")
+		}
+		data.BLSChanges = append(data.BLSChanges, blsChanges...)
+		hasRequests, err := forkdata.HasExecutionRequests(block)
+		if err != nil {
+			return nil, err
+		}
+		if hasRequests {
+			deposits, err := b.ExtractDeposits(block, epoch, slot, b.networkParameters.slotTime(slot))
+			if err != nil {
+				return nil, errors.Wrap(err, "error getting deposit requests")
 			}
-			log.Warn("block not found for slot: ", slot)
-			continue
+			data.Deposits = append(data.Deposits, deposits...)
+			withdrawalRequests, err := b.ExtractWithdrawalRequests(block, epoch, slot)
+			if err != nil {
+				return nil, errors.Wrap(err, "error getting withdrawal requests")
+			}
+			data.WithdrawalRequests = append(data.WithdrawalRequests, withdrawalRequests...)
+			consolidationRequests, err := b.ExtractConsolidationRequests(block, epoch, slot)
+			if err != nil {
+				return nil, errors.Wrap(err, "error getting consolidation requests")
+			}
+			data.ConsolidationRequests = append(data.ConsolidationRequests, consolidationRequests...)
+		} else if blockNumber, err := forkdata.BlockNumber(block); err == nil {
+			// Blocks before bellatrix have no execution payload
+			depositLogBlocks[blockNumber] = slot
+		}
+		blobs, ok, err := b.ExtractBlobs(block, epoch, slot)
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting blobs")
+		}
+		if ok {
+			data.Blobs[slot] = blobs
 		}
 
-		block := beaconBlock.Data
-
-		b.ExtractWithdrawals(block, data.Withdrawals)
+		// Best effort, the payout of the slot is not checked if it fails
+		if payload, ok := slotsWithMEVRewards[slot]; ok && b.executionClient != nil {
+			payout, err := b.GetMEVPayout(block, payload)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"Slot":   slot,
+					"Relays": payload.Relays,
+				}).Warn("could not get the mev payout, skipping it: ", err)
+			} else {
+				data.MEVPayouts[slot] = payout
+			}
+		}
 
 		// Extract transaction fees if block has no MEV rewards
 		if _, ok := slotsWithMEVRewards[slot]; !ok {
-			blockNumber := b.GetBlockNumber(block)
+			proposerIndex, err := forkdata.ProposerIndex(block)
+			if err != nil {
+				return nil, err
+			}
+			// In MEV blocks the fee recipient is the builder
+			feeRecipient, err := forkdata.FeeRecipient(block)
+			if err != nil {
+				return nil, err
+			}
+			data.FeeRecipients[proposerIndex] = hexutil.Encode(feeRecipient[:])
+			data.SlotFeeRecipients[slot] = hexutil.Encode(feeRecipient[:])
+
+			// Tips require the execution client, which is optional
+			if b.executionClient == nil {
+				continue
+			}
+			blockNumber, err := forkdata.BlockNumber(block)
+			if err != nil {
+				return nil, err
+			}
 
 			header, err := b.getBlockHeader(blockNumber)
 			if err != nil {
 				return nil, errors.Wrap(err, "error getting block header and receipts")
 			}
-			rawTxs := b.GetBlockTransactions(block)
-			receipts, err := b.getBlockReceipts(rawTxs)
+			rawTxs, err := forkdata.Transactions(block)
+			if err != nil {
+				return nil, err
+			}
+			receipts, err := b.getBlockReceipts(blockNumber, rawTxs)
 			if err != nil {
 				return nil, errors.Wrap(err, "error getting block receipts")
 			}
@@ -103,19 +224,31 @@ func (b *BlockData) GetEpochBlockData(epoch uint64, slotsWithMEVRewards map[uint
 			if err != nil {
 				return nil, errors.Wrap(err, "error getting proposer tip")
 			}
-			proposerIndex := b.GetProposerIndex(block)
 			if _, ok := data.ProposerTips[proposerIndex]; !ok {
 				data.ProposerTips[proposerIndex] = big.NewInt(0)
 			}
 			data.ProposerTips[proposerIndex].Add(data.ProposerTips[proposerIndex], proposerTip)
+			data.SlotTips[slot] = proposerTip
 		}
 	}
 
+	// Deposits before electra require the execution client, which is optional
+	if len(depositLogBlocks) > 0 && b.executionClient != nil {
+		deposits, err := b.getDepositLogs(epoch, depositLogBlocks)
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting deposit logs")
+		}
+		data.Deposits = append(data.Deposits, deposits...)
+	}
+
 	return data, nil
 }
 
-func (b *BlockData) ExtractWithdrawals(beaconBlock *spec.VersionedSignedBeaconBlock, withdrawals map[uint64]*big.Int) {
-	blockWithdrawals := b.GetBlockWithdrawals(beaconBlock)
+func (b *BlockData) ExtractWithdrawals(beaconBlock *spec.VersionedSignedBeaconBlock, withdrawals map[uint64]*big.Int) error {
+	blockWithdrawals, err := forkdata.Withdrawals(beaconBlock)
+	if err != nil {
+		return err
+	}
 	for _, withdrawal := range blockWithdrawals {
 		idx := uint64(withdrawal.ValidatorIndex)
 		if _, ok := withdrawals[idx]; !ok {
@@ -123,6 +256,178 @@ func (b *BlockData) ExtractWithdrawals(beaconBlock *spec.VersionedSignedBeaconBl
 		}
 		withdrawals[idx].Add(withdrawals[idx], big.NewInt(int64(withdrawal.Amount)))
 	}
+	return nil
+}
+
+// Returns the validators slashed by the proposer and attester slashings
+// included in the block
+func (b *BlockData) ExtractSlashings(beaconBlock *spec.VersionedSignedBeaconBlock, epoch uint64, slot uint64) ([]schemas.Slashing, error) {
+	proposerSlashings, err := forkdata.ProposerSlashings(beaconBlock)
+	if err != nil {
+		return nil, err
+	}
+	attesterSlashed, err := GetAttesterSlashedIndexes(beaconBlock)
+	if err != nil {
+		return nil, err
+	}
+	slashings := make([]schemas.Slashing, 0)
+	for _, proposerSlashing := range proposerSlashings {
+		slashings = append(slashings, schemas.Slashing{
+			Epoch:        epoch,
+			Slot:         slot,
+			ValIndex:     uint64(proposerSlashing.SignedHeader1.Message.ProposerIndex),
+			SlashingType: schemas.ProposerSlashing,
+		})
+	}
+	for _, valIdx := range attesterSlashed {
+		slashings = append(slashings, schemas.Slashing{
+			Epoch:        epoch,
+			Slot:         slot,
+			ValIndex:     valIdx,
+			SlashingType: schemas.AttesterSlashing,
+		})
+	}
+	return slashings, nil
+}
+
+// Returns the indexes slashed by the attester slashings of the block, which
+// are the ones present in both conflicting attestations
+func GetAttesterSlashedIndexes(beaconBlock *spec.VersionedSignedBeaconBlock) ([]uint64, error) {
+	attestingIndices, err := forkdata.AttesterSlashingIndexes(beaconBlock)
+	if err != nil {
+		return nil, err
+	}
+	slashedIndexes := make([]uint64, 0)
+	for _, indices := range attestingIndices {
+		inFirst := make(map[uint64]struct{}, len(indices[0]))
+		for _, idx := range indices[0] {
+			inFirst[idx] = struct{}{}
+		}
+		for _, idx := range indices[1] {
+			if _, ok := inFirst[idx]; ok {
+				slashedIndexes = append(slashedIndexes, idx)
+			}
+		}
+	}
+	return slashedIndexes, nil
+}
+
+// Returns the voluntary exits included in the block
+func (b *BlockData) ExtractVoluntaryExits(beaconBlock *spec.VersionedSignedBeaconBlock, epoch uint64, slot uint64) ([]schemas.VoluntaryExit, error) {
+	blockExits, err := forkdata.VoluntaryExits(beaconBlock)
+	if err != nil {
+		return nil, err
+	}
+	exits := make([]schemas.VoluntaryExit, 0)
+	for _, exit := range blockExits {
+		exits = append(exits, schemas.VoluntaryExit{
+			Epoch:     epoch,
+			Slot:      slot,
+			ValIndex:  uint64(exit.Message.ValidatorIndex),
+			ExitEpoch: uint64(exit.Message.Epoch),
+		})
+	}
+	return exits, nil
+}
+
+// Returns the BLS to execution changes included in the block
+func (b *BlockData) ExtractBLSChanges(beaconBlock *spec.VersionedSignedBeaconBlock, epoch uint64, slot uint64) ([]schemas.BLSChange, error) {
+	blockChanges, err := forkdata.BLSToExecutionChanges(beaconBlock)
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]schemas.BLSChange, 0)
+	for _, change := range blockChanges {
+		changes = append(changes, schemas.BLSChange{
+			Epoch:            epoch,
+			Slot:             slot,
+			ValIndex:         uint64(change.Message.ValidatorIndex),
+			ExecutionAddress: hexutil.Encode(change.Message.ToExecutionAddress[:]),
+		})
+	}
+	return changes, nil
+}
+
+// Returns the deposit requests included in the block, since electra
+func (b *BlockData) ExtractDeposits(beaconBlock *spec.VersionedSignedBeaconBlock, epoch uint64, slot uint64, timestamp uint64) ([]schemas.Deposit, error) {
+	blockNumber, err := forkdata.BlockNumber(beaconBlock)
+	if err != nil {
+		return nil, err
+	}
+	requests, err := forkdata.ExecutionRequests(beaconBlock)
+	if err != nil {
+		return nil, err
+	}
+	deposits := make([]schemas.Deposit, 0)
+	for _, request := range requests.Deposits {
+		deposits = append(deposits, schemas.Deposit{
+			Epoch:                 epoch,
+			Slot:                  slot,
+			BlockNumber:           blockNumber,
+			Timestamp:             timestamp,
+			DepositIndex:          request.Index,
+			Pubkey:                hexutil.Encode(request.Pubkey[:]),
+			WithdrawalCredentials: hexutil.Encode(request.WithdrawalCredentials),
+			Amount:                uint64(request.Amount),
+		})
+	}
+	return deposits, nil
+}
+
+// Returns the withdrawal requests included in the block, since electra
+func (b *BlockData) ExtractWithdrawalRequests(beaconBlock *spec.VersionedSignedBeaconBlock, epoch uint64, slot uint64) ([]schemas.WithdrawalRequest, error) {
+	requests, err := forkdata.ExecutionRequests(beaconBlock)
+	if err != nil {
+		return nil, err
+	}
+	withdrawalRequests := make([]schemas.WithdrawalRequest, 0)
+	if len(requests.Withdrawals) == 0 {
+		return withdrawalRequests, nil
+	}
+	blockNumber, err := forkdata.BlockNumber(beaconBlock)
+	if err != nil {
+		return nil, err
+	}
+	for i, request := range requests.Withdrawals {
+		withdrawalRequests = append(withdrawalRequests, schemas.WithdrawalRequest{
+			Epoch:         epoch,
+			Slot:          slot,
+			BlockNumber:   blockNumber,
+			RequestIndex:  uint64(i),
+			SourceAddress: hexutil.Encode(request.SourceAddress[:]),
+			Pubkey:        hexutil.Encode(request.ValidatorPubkey[:]),
+			Amount:        uint64(request.Amount),
+		})
+	}
+	return withdrawalRequests, nil
+}
+
+// Returns the consolidation requests included in the block, since electra
+func (b *BlockData) ExtractConsolidationRequests(beaconBlock *spec.VersionedSignedBeaconBlock, epoch uint64, slot uint64) ([]schemas.ConsolidationRequest, error) {
+	requests, err := forkdata.ExecutionRequests(beaconBlock)
+	if err != nil {
+		return nil, err
+	}
+	consolidationRequests := make([]schemas.ConsolidationRequest, 0)
+	if len(requests.Consolidations) == 0 {
+		return consolidationRequests, nil
+	}
+	blockNumber, err := forkdata.BlockNumber(beaconBlock)
+	if err != nil {
+		return nil, err
+	}
+	for i, request := range requests.Consolidations {
+		consolidationRequests = append(consolidationRequests, schemas.ConsolidationRequest{
+			Epoch:         epoch,
+			Slot:          slot,
+			BlockNumber:   blockNumber,
+			RequestIndex:  uint64(i),
+			SourceAddress: hexutil.Encode(request.SourceAddress[:]),
+			SourcePubkey:  hexutil.Encode(request.SourcePubkey[:]),
+			TargetPubkey:  hexutil.Encode(request.TargetPubkey[:]),
+		})
+	}
+	return consolidationRequests, nil
 }
 
 func (b *BlockData) GetProposerTip(
@@ -130,9 +435,18 @@ func (b *BlockData) GetProposerTip(
 	header *types.Header,
 	receipts []*types.Receipt,
 ) (*big.Int, error) {
-	rawTxs := b.GetBlockTransactions(beaconBlock)
-
-	baseFeePerGasBytes := b.GetBaseFeePerGas(beaconBlock)
+	rawTxs, err := forkdata.Transactions(beaconBlock)
+	if err != nil {
+		return nil, err
+	}
+	baseFeePerGasBytes, err := forkdata.BaseFeePerGas(beaconBlock)
+	if err != nil {
+		return nil, err
+	}
+	gasUsed, err := forkdata.GasUsed(beaconBlock)
+	if err != nil {
+		return nil, err
+	}
 	baseFeePerGas := new(big.Int).SetBytes(baseFeePerGasBytes[:])
 
 	tips := big.NewInt(0)
@@ -172,11 +486,89 @@ func (b *BlockData) GetProposerTip(
 		tips.Add(tips, tipFee)
 	}
 
-	burnt := new(big.Int).Mul(big.NewInt(int64(b.GetGasUsed(beaconBlock))), baseFeePerGas)
+	burnt := new(big.Int).Mul(big.NewInt(int64(gasUsed)), baseFeePerGas)
 	proposerReward := new(big.Int).Sub(tips, burnt)
 	return proposerReward, nil
 }
 
+// Returns the wei received in the MEV block by the fee recipient reported by
+// the relays. Builders usually pay it with a transaction at the end of the
+// block, and some set it as the fee recipient of the block so that it gets the
+// tips instead. Payouts made from a contract are only seen in its balance, so
+// if the transactions fall short, its balance is compared with the previous
+// block, which requires the state of both blocks in the execution client.
+func (b *BlockData) GetMEVPayout(beaconBlock *spec.VersionedSignedBeaconBlock, payload schemas.RelayPayload) (*big.Int, error) {
+	rawTxs, err := forkdata.Transactions(beaconBlock)
+	if err != nil {
+		return nil, err
+	}
+	blockNumber, err := forkdata.BlockNumber(beaconBlock)
+	if err != nil {
+		return nil, err
+	}
+	feeRecipient, err := forkdata.FeeRecipient(beaconBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	recipient := common.HexToAddress(payload.ProposerFeeRecipient)
+	paid := big.NewInt(0)
+	for _, rawTx := range rawTxs {
+		var tx types.Transaction
+		if err := tx.UnmarshalBinary(rawTx); err != nil {
+			return nil, errors.Wrap(err, "error unmarshalling transaction")
+		}
+		if tx.To() != nil && *tx.To() == recipient {
+			paid.Add(paid, tx.Value())
+		}
+	}
+
+	if common.Address(feeRecipient) == recipient {
+		header, err := b.getBlockHeader(blockNumber)
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting block header")
+		}
+		receipts, err := b.getBlockReceipts(blockNumber, rawTxs)
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting block receipts")
+		}
+		tip, err := b.GetProposerTip(beaconBlock, header, receipts)
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting proposer tip")
+		}
+		paid.Add(paid, tip)
+	}
+
+	if payload.Value == nil || paid.Cmp(payload.Value) >= 0 {
+		return paid, nil
+	}
+	balanceChange, err := b.getBalanceChange(recipient, blockNumber)
+	if err != nil {
+		log.WithField("Slot", payload.Slot).Warn("could not get the balance change of the fee recipient, checking the payout with its transactions only: ", err)
+		return paid, nil
+	}
+	if balanceChange.Cmp(paid) > 0 {
+		return balanceChange, nil
+	}
+	return paid, nil
+}
+
+// Returns the change of the balance of the account in the block
+func (b *BlockData) getBalanceChange(account common.Address, blockNumber uint64) (*big.Int, error) {
+	if blockNumber == 0 {
+		return nil, errors.New("genesis block has no previous balance")
+	}
+	balance, err := b.executionClient.BalanceAt(context.Background(), account, new(big.Int).SetUint64(blockNumber))
+	if err != nil {
+		return nil, err
+	}
+	prevBalance, err := b.executionClient.BalanceAt(context.Background(), account, new(big.Int).SetUint64(blockNumber-1))
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).Sub(balance, prevBalance), nil
+}
+
 func (b *BlockData) getBlockHeader(
 	blockNumber uint64,
 ) (*types.Header, error) {
@@ -200,9 +592,30 @@ func (b *BlockData) getBlockHeader(
 	return header, nil
 }
 
-func (b *BlockData) getBlockReceipts(rawTxs []bellatrix.Transaction) ([]*types.Receipt, error) {
+// Gets all the receipts of the block with eth_getBlockReceipts, falling back to
+// a request per transaction if the execution client does not support it
+func (b *BlockData) getBlockReceipts(blockNumber uint64, rawTxs []bellatrix.Transaction) ([]*types.Receipt, error) {
+	if !b.noBlockReceipts {
+		receipts, err := b.executionClient.BlockReceipts(
+			context.Background(),
+			rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(blockNumber)))
+		if err == nil && len(receipts) == len(rawTxs) {
+			return receipts, nil
+		}
+		if rpcErr, ok := err.(rpc.Error); ok && rpcErr.ErrorCode() == rpcMethodNotFound {
+			log.Warn("Execution client does not support eth_getBlockReceipts, getting receipts per transaction")
+			b.noBlockReceipts = true
+		} else if err != nil {
+			log.Warnf("error getting receipts of block %d: %s. Getting them per transaction", blockNumber, err)
+		} else {
+			log.Warnf("got %d receipts for %d transactions of block %d. Getting them per transaction", len(receipts), len(rawTxs), blockNumber)
+		}
+	}
+	return b.getTransactionReceipts(rawTxs)
+}
+
+func (b *BlockData) getTransactionReceipts(rawTxs []bellatrix.Transaction) ([]*types.Receipt, error) {
 	receipts := make([]*types.Receipt, len(rawTxs))
-	var err error
 
 	var g errgroup.Group
 	var mu sync.Mutex
@@ -212,8 +625,7 @@ func (b *BlockData) getBlockReceipts(rawTxs []bellatrix.Transaction) ([]*types.R
 	for i, rawTx := range rawTxs {
 		g.Go(func() error {
 			var tx types.Transaction
-			err = tx.UnmarshalBinary(rawTx)
-			if err != nil {
+			if err := tx.UnmarshalBinary(rawTx); err != nil {
 				return errors.Wrap(err, "error unmarshalling transaction")
 			}
 			receipt, err := b.getTransactionReceipt(&tx)
@@ -250,132 +662,3 @@ func (b *BlockData) getTransactionReceipt(tx *types.Transaction) (*types.Receipt
 
 	return receipt, nil
 }
-
-func (b *BlockData) GetBlockWithdrawals(beaconBlock *spec.VersionedSignedBeaconBlock) []*capella.Withdrawal {
-	var withdrawals []*capella.Withdrawal
-	if beaconBlock.Altair != nil {
-		withdrawals = []*capella.Withdrawal{}
-	} else if beaconBlock.Bellatrix != nil {
-		withdrawals = []*capella.Withdrawal{}
-	} else if beaconBlock.Capella != nil {
-		withdrawals = beaconBlock.Capella.Message.Body.ExecutionPayload.Withdrawals
-	} else if beaconBlock.Deneb != nil {
-		withdrawals = beaconBlock.Deneb.Message.Body.ExecutionPayload.Withdrawals
-	} else if beaconBlock.Electra != nil {
-		withdrawals = beaconBlock.Electra.Message.Body.ExecutionPayload.Withdrawals
-	} else if beaconBlock.Fulu != nil {
-		withdrawals = beaconBlock.Fulu.Message.Body.ExecutionPayload.Withdrawals
-	} else {
-		log.Fatal("Beacon block was empty")
-	}
-	return withdrawals
-}
-
-func (b *BlockData) GetBlockTransactions(beaconBlock *spec.VersionedSignedBeaconBlock) []bellatrix.Transaction {
-	var transactions []bellatrix.Transaction
-	if beaconBlock.Altair != nil {
-		transactions = []bellatrix.Transaction{}
-	} else if beaconBlock.Bellatrix != nil {
-		transactions = beaconBlock.Bellatrix.Message.Body.ExecutionPayload.Transactions
-	} else if beaconBlock.Capella != nil {
-		transactions = beaconBlock.Capella.Message.Body.ExecutionPayload.Transactions
-	} else if beaconBlock.Deneb != nil {
-		transactions = beaconBlock.Deneb.Message.Body.ExecutionPayload.Transactions
-	} else if beaconBlock.Electra != nil {
-		transactions = beaconBlock.Electra.Message.Body.ExecutionPayload.Transactions
-	} else if beaconBlock.Fulu != nil {
-		transactions = beaconBlock.Fulu.Message.Body.ExecutionPayload.Transactions
-	} else {
-		log.Fatal("Beacon block was empty")
-	}
-	return transactions
-}
-
-func (b *BlockData) GetBlockNumber(beaconBlock *spec.VersionedSignedBeaconBlock) uint64 {
-	var blockNumber uint64
-	if beaconBlock.Altair != nil {
-		log.Fatal("Altair block has no block number")
-	} else if beaconBlock.Bellatrix != nil {
-		blockNumber = beaconBlock.Bellatrix.Message.Body.ExecutionPayload.BlockNumber
-	} else if beaconBlock.Capella != nil {
-		blockNumber = beaconBlock.Capella.Message.Body.ExecutionPayload.BlockNumber
-	} else if beaconBlock.Deneb != nil {
-		blockNumber = beaconBlock.Deneb.Message.Body.ExecutionPayload.BlockNumber
-	} else if beaconBlock.Electra != nil {
-		blockNumber = beaconBlock.Electra.Message.Body.ExecutionPayload.BlockNumber
-	} else if beaconBlock.Fulu != nil {
-		blockNumber = beaconBlock.Fulu.Message.Body.ExecutionPayload.BlockNumber
-	} else {
-		log.Fatal("Beacon block was empty")
-	}
-	return blockNumber
-}
-
-// Returns base fee per gas in big endian
-func (b *BlockData) GetBaseFeePerGas(beaconBlock *spec.VersionedSignedBeaconBlock) [32]byte {
-	var baseFeePerGas [32]byte
-
-	if beaconBlock.Altair != nil {
-		log.Fatal("Altair block has no base fee per gas")
-	} else if beaconBlock.Bellatrix != nil {
-		baseFeePerGasLE := beaconBlock.Bellatrix.Message.Body.ExecutionPayload.BaseFeePerGas
-		for i := range 32 {
-			baseFeePerGas[i] = baseFeePerGasLE[32-1-i]
-		}
-	} else if beaconBlock.Capella != nil {
-		baseFeePerGasLE := beaconBlock.Capella.Message.Body.ExecutionPayload.BaseFeePerGas
-		for i := range 32 {
-			baseFeePerGas[i] = baseFeePerGasLE[32-1-i]
-		}
-	} else if beaconBlock.Deneb != nil {
-		baseFeePerGas = beaconBlock.Deneb.Message.Body.ExecutionPayload.BaseFeePerGas.Bytes32()
-	} else if beaconBlock.Electra != nil {
-		baseFeePerGas = beaconBlock.Electra.Message.Body.ExecutionPayload.BaseFeePerGas.Bytes32()
-	} else if beaconBlock.Fulu != nil {
-		baseFeePerGas = beaconBlock.Fulu.Message.Body.ExecutionPayload.BaseFeePerGas.Bytes32()
-	} else {
-		log.Fatal("Beacon block was empty")
-	}
-	return baseFeePerGas
-}
-
-func (b *BlockData) GetGasUsed(beaconBlock *spec.VersionedSignedBeaconBlock) uint64 {
-	var gasUsed uint64
-
-	if beaconBlock.Altair != nil {
-		log.Fatal("Altair block has no gas used")
-	} else if beaconBlock.Bellatrix != nil {
-		gasUsed = beaconBlock.Bellatrix.Message.Body.ExecutionPayload.GasUsed
-	} else if beaconBlock.Capella != nil {
-		gasUsed = beaconBlock.Capella.Message.Body.ExecutionPayload.GasUsed
-	} else if beaconBlock.Deneb != nil {
-		gasUsed = beaconBlock.Deneb.Message.Body.ExecutionPayload.GasUsed
-	} else if beaconBlock.Electra != nil {
-		gasUsed = beaconBlock.Electra.Message.Body.ExecutionPayload.GasUsed
-	} else if beaconBlock.Fulu != nil {
-		gasUsed = beaconBlock.Fulu.Message.Body.ExecutionPayload.GasUsed
-	} else {
-		log.Fatal("Beacon block was empty")
-	}
-	return gasUsed
-}
-
-func (b *BlockData) GetProposerIndex(beaconBlock *spec.VersionedSignedBeaconBlock) uint64 {
-	var proposerIndex uint64
-	if beaconBlock.Altair != nil {
-		proposerIndex = uint64(beaconBlock.Altair.Message.ProposerIndex)
-	} else if beaconBlock.Bellatrix != nil {
-		proposerIndex = uint64(beaconBlock.Bellatrix.Message.ProposerIndex)
-	} else if beaconBlock.Capella != nil {
-		proposerIndex = uint64(beaconBlock.Capella.Message.ProposerIndex)
-	} else if beaconBlock.Deneb != nil {
-		proposerIndex = uint64(beaconBlock.Deneb.Message.ProposerIndex)
-	} else if beaconBlock.Electra != nil {
-		proposerIndex = uint64(beaconBlock.Electra.Message.ProposerIndex)
-	} else if beaconBlock.Fulu != nil {
-		proposerIndex = uint64(beaconBlock.Fulu.Message.ProposerIndex)
-	} else {
-		log.Fatal("Beacon block was empty")
-	}
-	return proposerIndex
-}
@@ -5,11 +5,24 @@ import (
 	"fmt"
 	"io"
 	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/deneb"
+	"github.com/attestantio/go-eth2-client/spec/electra"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bilinearlabs/eth-metrics/execution"
+	"github.com/bilinearlabs/eth-metrics/forkdata"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
@@ -48,7 +61,7 @@ func Test_ExtractWithdrawals(t *testing.T) {
 	}
 
 	withdrawals := make(map[uint64]*big.Int)
-	bd.ExtractWithdrawals(blockData.BeaconBlock, withdrawals)
+	assert.NoError(t, bd.ExtractWithdrawals(blockData.BeaconBlock, withdrawals))
 	assert.Equal(t, withdrawals, map[uint64]*big.Int{
 		416729: big.NewInt(1701196),
 		416730: big.NewInt(1731482),
@@ -97,3 +110,338 @@ func LoadBlockData(slot uint64) (*MockBlockData, error) {
 
 	return &blockData, nil
 }
+
+func Test_ExtractSlashings(t *testing.T) {
+	bd := &BlockData{}
+
+	block := &spec.VersionedSignedBeaconBlock{
+		Electra: &electra.SignedBeaconBlock{
+			Message: &electra.BeaconBlock{
+				Body: &electra.BeaconBlockBody{
+					ProposerSlashings: []*phase0.ProposerSlashing{
+						{
+							SignedHeader1: &phase0.SignedBeaconBlockHeader{Message: &phase0.BeaconBlockHeader{ProposerIndex: 7}},
+							SignedHeader2: &phase0.SignedBeaconBlockHeader{Message: &phase0.BeaconBlockHeader{ProposerIndex: 7}},
+						},
+					},
+					AttesterSlashings: []*electra.AttesterSlashing{
+						{
+							Attestation1: &electra.IndexedAttestation{AttestingIndices: []uint64{1, 2, 3}},
+							Attestation2: &electra.IndexedAttestation{AttestingIndices: []uint64{3, 4, 1}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	slashings, err := bd.ExtractSlashings(block, 10, 320)
+	assert.NoError(t, err)
+	assert.Equal(t, []schemas.Slashing{
+		{Epoch: 10, Slot: 320, ValIndex: 7, SlashingType: schemas.ProposerSlashing},
+		{Epoch: 10, Slot: 320, ValIndex: 3, SlashingType: schemas.AttesterSlashing},
+		{Epoch: 10, Slot: 320, ValIndex: 1, SlashingType: schemas.AttesterSlashing},
+	}, slashings)
+}
+
+func Test_ExtractVoluntaryExitsAndBLSChanges(t *testing.T) {
+	bd := &BlockData{}
+
+	block := &spec.VersionedSignedBeaconBlock{
+		Electra: &electra.SignedBeaconBlock{
+			Message: &electra.BeaconBlock{
+				Body: &electra.BeaconBlockBody{
+					VoluntaryExits: []*phase0.SignedVoluntaryExit{
+						{Message: &phase0.VoluntaryExit{Epoch: 9, ValidatorIndex: 7}},
+					},
+					BLSToExecutionChanges: []*capella.SignedBLSToExecutionChange{
+						{Message: &capella.BLSToExecutionChange{ValidatorIndex: 8, ToExecutionAddress: bellatrix.ExecutionAddress{0xab}}},
+					},
+				},
+			},
+		},
+	}
+
+	exits, err := bd.ExtractVoluntaryExits(block, 10, 320)
+	assert.NoError(t, err)
+	assert.Equal(t, []schemas.VoluntaryExit{{Epoch: 10, Slot: 320, ValIndex: 7, ExitEpoch: 9}}, exits)
+
+	changes, err := bd.ExtractBLSChanges(block, 10, 320)
+	assert.NoError(t, err)
+	assert.Equal(t, []schemas.BLSChange{
+		{Epoch: 10, Slot: 320, ValIndex: 8, ExecutionAddress: "0xab00000000000000000000000000000000000000"},
+	}, changes)
+
+	// No changes before capella
+	bellatrixBlock := &spec.VersionedSignedBeaconBlock{Bellatrix: &bellatrix.SignedBeaconBlock{}}
+	changes, err = bd.ExtractBLSChanges(bellatrixBlock, 10, 320)
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+
+	_, err = bd.ExtractVoluntaryExits(&spec.VersionedSignedBeaconBlock{}, 10, 320)
+	assert.ErrorIs(t, err, forkdata.ErrEmptyBlock)
+}
+
+func Test_ExtractDeposits(t *testing.T) {
+	bd := &BlockData{}
+
+	block := &spec.VersionedSignedBeaconBlock{
+		Electra: &electra.SignedBeaconBlock{
+			Message: &electra.BeaconBlock{
+				Body: &electra.BeaconBlockBody{
+					ExecutionPayload: &deneb.ExecutionPayload{BlockNumber: 100},
+					ExecutionRequests: &electra.ExecutionRequests{
+						Deposits: []*electra.DepositRequest{
+							{Pubkey: phase0.BLSPubKey{0xaa}, WithdrawalCredentials: []byte{0x01, 0x02}, Amount: 32000000000, Index: 5},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	deposits, err := bd.ExtractDeposits(block, 10, 320, 1700000000)
+	assert.NoError(t, err)
+	assert.Equal(t, []schemas.Deposit{{
+		Epoch:                 10,
+		Slot:                  320,
+		BlockNumber:           100,
+		Timestamp:             1700000000,
+		DepositIndex:          5,
+		Pubkey:                "0xaa" + strings.Repeat("00", 47),
+		WithdrawalCredentials: "0x0102",
+		Amount:                32000000000,
+	}}, deposits)
+}
+
+func Test_ExtractExecutionRequests(t *testing.T) {
+	bd := &BlockData{}
+
+	block := &spec.VersionedSignedBeaconBlock{
+		Electra: &electra.SignedBeaconBlock{
+			Message: &electra.BeaconBlock{
+				Body: &electra.BeaconBlockBody{
+					ExecutionPayload: &deneb.ExecutionPayload{BlockNumber: 100},
+					ExecutionRequests: &electra.ExecutionRequests{
+						Withdrawals: []*electra.WithdrawalRequest{
+							{SourceAddress: bellatrix.ExecutionAddress{0xab}, ValidatorPubkey: phase0.BLSPubKey{0xaa}, Amount: 1000000000},
+							{SourceAddress: bellatrix.ExecutionAddress{0xab}, ValidatorPubkey: phase0.BLSPubKey{0xbb}},
+						},
+						Consolidations: []*electra.ConsolidationRequest{
+							{SourceAddress: bellatrix.ExecutionAddress{0xab}, SourcePubkey: phase0.BLSPubKey{0xaa}, TargetPubkey: phase0.BLSPubKey{0xcc}},
+						},
+					},
+				},
+			},
+		},
+	}
+	address := "0xab" + strings.Repeat("00", 19)
+
+	withdrawalRequests, err := bd.ExtractWithdrawalRequests(block, 10, 320)
+	assert.NoError(t, err)
+	assert.Equal(t, []schemas.WithdrawalRequest{
+		{Epoch: 10, Slot: 320, BlockNumber: 100, RequestIndex: 0, SourceAddress: address, Pubkey: "0xaa" + strings.Repeat("00", 47), Amount: 1000000000},
+		{Epoch: 10, Slot: 320, BlockNumber: 100, RequestIndex: 1, SourceAddress: address, Pubkey: "0xbb" + strings.Repeat("00", 47)},
+	}, withdrawalRequests)
+	assert.False(t, withdrawalRequests[0].IsExit())
+	assert.True(t, withdrawalRequests[1].IsExit())
+
+	consolidationRequests, err := bd.ExtractConsolidationRequests(block, 10, 320)
+	assert.NoError(t, err)
+	assert.Equal(t, []schemas.ConsolidationRequest{{
+		Epoch:         10,
+		Slot:          320,
+		BlockNumber:   100,
+		SourceAddress: address,
+		SourcePubkey:  "0xaa" + strings.Repeat("00", 47),
+		TargetPubkey:  "0xcc" + strings.Repeat("00", 47),
+	}}, consolidationRequests)
+	assert.False(t, consolidationRequests[0].IsSwitchToCompounding())
+
+	// No requests before electra
+	denebBlock := &spec.VersionedSignedBeaconBlock{Deneb: &deneb.SignedBeaconBlock{}}
+	withdrawalRequests, err = bd.ExtractWithdrawalRequests(denebBlock, 10, 320)
+	assert.NoError(t, err)
+	assert.Empty(t, withdrawalRequests)
+	consolidationRequests, err = bd.ExtractConsolidationRequests(denebBlock, 10, 320)
+	assert.NoError(t, err)
+	assert.Empty(t, consolidationRequests)
+}
+
+// Execution client that serves the receipts of the given transactions, and
+// eth_getBlockReceipts only if blockReceipts is set
+func newExecutionServer(t *testing.T, txs []*types.Transaction, blockReceipts bool, calls map[string]int) *httptest.Server {
+	receipts := make(map[string]*types.Receipt)
+	for i, tx := range txs {
+		receipts[tx.Hash().Hex()] = &types.Receipt{
+			Status:            types.ReceiptStatusSuccessful,
+			CumulativeGasUsed: uint64(21000 * (i + 1)),
+			GasUsed:           21000,
+			TxHash:            tx.Hash(),
+			Logs:              []*types.Log{},
+		}
+	}
+
+	var mu sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Id     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("error decoding request: %s", err)
+		}
+		mu.Lock()
+		calls[req.Method]++
+		mu.Unlock()
+
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.Id}
+		switch {
+		case req.Method == "eth_getBlockReceipts" && blockReceipts:
+			blockReceipts := make([]*types.Receipt, 0, len(txs))
+			for _, tx := range txs {
+				blockReceipts = append(blockReceipts, receipts[tx.Hash().Hex()])
+			}
+			resp["result"] = blockReceipts
+		case req.Method == "eth_getTransactionReceipt":
+			var hash string
+			if err := json.Unmarshal(req.Params[0], &hash); err != nil {
+				t.Fatalf("error decoding hash: %s", err)
+			}
+			resp["result"] = receipts[hash]
+		default:
+			resp["error"] = map[string]interface{}{"code": rpcMethodNotFound, "message": "the method does not exist"}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+func Test_GetBlockReceipts(t *testing.T) {
+	txs := make([]*types.Transaction, 3)
+	rawTxs := make([]bellatrix.Transaction, 3)
+	for i := range txs {
+		txs[i] = types.NewTx(&types.LegacyTx{Nonce: uint64(i), Gas: 21000, GasPrice: big.NewInt(1)})
+		rawTx, err := txs[i].MarshalBinary()
+		assert.NoError(t, err)
+		rawTxs[i] = rawTx
+	}
+
+	for _, blockReceipts := range []bool{true, false} {
+		calls := make(map[string]int)
+		server := newExecutionServer(t, txs, blockReceipts, calls)
+		defer server.Close()
+		executionClient, err := execution.NewClient([]string{server.URL}, execution.Auth{})
+		assert.NoError(t, err)
+
+		bd := &BlockData{executionClient: executionClient}
+		for range 2 {
+			receipts, err := bd.getBlockReceipts(100, rawTxs)
+			assert.NoError(t, err)
+			assert.Equal(t, len(txs), len(receipts))
+			for i, receipt := range receipts {
+				assert.Equal(t, txs[i].Hash(), receipt.TxHash)
+			}
+		}
+
+		if blockReceipts {
+			assert.Equal(t, map[string]int{"eth_getBlockReceipts": 2}, calls)
+			assert.False(t, bd.noBlockReceipts)
+		} else {
+			// Not tried again once the client reported it as not supported
+			assert.Equal(t, map[string]int{"eth_getBlockReceipts": 1, "eth_getTransactionReceipt": 6}, calls)
+			assert.True(t, bd.noBlockReceipts)
+		}
+	}
+}
+
+func Test_GetMEVPayout(t *testing.T) {
+	builder := common.HexToAddress("0x00000000000000000000000000000000000000b1")
+	recipient := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	txs := []*types.Transaction{
+		types.NewTx(&types.LegacyTx{Nonce: 0, Gas: 21000, GasPrice: big.NewInt(1), To: &builder, Value: big.NewInt(5)}),
+		types.NewTx(&types.LegacyTx{Nonce: 1, Gas: 21000, GasPrice: big.NewInt(1), To: &recipient, Value: big.NewInt(600)}),
+		types.NewTx(&types.LegacyTx{Nonce: 2, Gas: 21000, GasPrice: big.NewInt(1), To: &recipient, Value: big.NewInt(400)}),
+	}
+	rawTxs := make([]bellatrix.Transaction, 0, len(txs))
+	for _, tx := range txs {
+		rawTx, err := tx.MarshalBinary()
+		assert.NoError(t, err)
+		rawTxs = append(rawTxs, rawTx)
+	}
+	block := &spec.VersionedSignedBeaconBlock{
+		Deneb: &deneb.SignedBeaconBlock{
+			Message: &deneb.BeaconBlock{
+				Slot: 100,
+				Body: &deneb.BeaconBlockBody{
+					ExecutionPayload: &deneb.ExecutionPayload{
+						BlockNumber:  1000,
+						FeeRecipient: bellatrix.ExecutionAddress(builder),
+						Transactions: rawTxs,
+					},
+				},
+			},
+		},
+	}
+
+	calls := make(map[string]int)
+	server := newExecutionServer(t, txs, true, calls)
+	defer server.Close()
+	executionClient, err := execution.NewClient([]string{server.URL}, execution.Auth{})
+	assert.NoError(t, err)
+	bd := &BlockData{executionClient: executionClient}
+
+	// Paid in full by the builder transactions
+	payload := schemas.RelayPayload{Slot: 100, ProposerFeeRecipient: recipient.Hex(), Value: big.NewInt(1000)}
+	paid, err := bd.GetMEVPayout(block, payload)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(1000), paid)
+	assert.Empty(t, calls)
+
+	// Underpaid, the balance change is tried but the client can't tell it
+	payload.Value = big.NewInt(1500)
+	paid, err = bd.GetMEVPayout(block, payload)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(1000), paid)
+	assert.Equal(t, 1, calls["eth_getBalance"])
+}
+
+func Test_GetEpochBlockData_MEVPayoutError(t *testing.T) {
+	builder := common.HexToAddress("0x00000000000000000000000000000000000000b1")
+	recipient := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	block := &spec.VersionedSignedBeaconBlock{
+		Version: spec.DataVersionElectra,
+		Electra: &electra.SignedBeaconBlock{
+			Message: &electra.BeaconBlock{
+				Slot:          100,
+				ProposerIndex: 7,
+				Body: &electra.BeaconBlockBody{
+					// Checking the payout fails on the invalid transaction
+					ExecutionPayload: &deneb.ExecutionPayload{
+						BlockNumber:  1000,
+						FeeRecipient: bellatrix.ExecutionAddress(builder),
+						Transactions: []bellatrix.Transaction{{0xff}},
+					},
+					ExecutionRequests: &electra.ExecutionRequests{},
+				},
+			},
+		},
+	}
+
+	calls := make(map[string]int)
+	server := newExecutionServer(t, nil, true, calls)
+	defer server.Close()
+	executionClient, err := execution.NewClient([]string{server.URL}, execution.Auth{})
+	assert.NoError(t, err)
+	bd := &BlockData{
+		executionClient:   executionClient,
+		networkParameters: &NetworkParameters{slotsInEpoch: 32},
+	}
+
+	payload := schemas.RelayPayload{Slot: 100, Relays: []string{"relay"}, ProposerFeeRecipient: recipient.Hex(), Value: big.NewInt(1000)}
+	data, err := bd.GetEpochBlockData(3, map[uint64]*spec.VersionedSignedBeaconBlock{100: block}, map[uint64]schemas.RelayPayload{100: payload})
+	assert.NoError(t, err)
+	assert.Empty(t, data.MEVPayouts)
+	assert.Equal(t, uint64(7), data.Blobs[100].ProposerIndex)
+}
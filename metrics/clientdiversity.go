@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/bilinearlabs/eth-metrics/schemas"
+)
+
+const unknownClient = "unknown"
+
+// Client codes of the graffiti appended by the consensus clients, which is the
+// execution client code and commit followed by the consensus client code and
+// commit, e.g. GE1234LH5678, or shorter if the graffiti is already in use
+var executionClientCodes = map[string]string{
+	"BU": "besu",
+	"EG": "erigon",
+	"EJ": "ethereumjs",
+	"GE": "geth",
+	"NB": "nimbus",
+	"NM": "nethermind",
+	"RH": "reth",
+}
+
+var consensusClientCodes = map[string]string{
+	"GR": "grandine",
+	"LH": "lighthouse",
+	"LS": "lodestar",
+	"NB": "nimbus",
+	"PM": "prysm",
+	"TK": "teku",
+}
+
+var clientVersionGraffiti = regexp.MustCompile(`^(?:([A-Z]{2})[0-9a-fA-F]{0,4})?([A-Z]{2})[0-9a-fA-F]{0,4}$`)
+
+// Clients that built a block
+type blockClients struct {
+	consensus string
+	execution string
+}
+
+// Guesses the consensus and execution clients of a block from its graffiti,
+// either from the client version codes or from the names of the clients, which
+// is what older versions and some operators use. Unknown if not found.
+func ParseGraffiti(graffiti string) (string, string) {
+	consensus, execution := unknownClient, unknownClient
+	for _, word := range strings.Fields(graffiti) {
+		matches := clientVersionGraffiti.FindStringSubmatch(word)
+		if matches == nil {
+			continue
+		}
+		cl, ok := consensusClientCodes[matches[2]]
+		if !ok {
+			continue
+		}
+		consensus = cl
+		if el, ok := executionClientCodes[matches[1]]; ok {
+			execution = el
+		}
+		return consensus, execution
+	}
+
+	// Client names as whole words, e.g. "Lighthouse/v5.1.0" but not "together"
+	words := strings.FieldsFunc(strings.ToLower(graffiti), func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < '0' || r > '9')
+	})
+	for _, word := range words {
+		for _, name := range consensusClientCodes {
+			if word == name && consensus == unknownClient {
+				consensus = name
+			}
+		}
+		for _, name := range executionClientCodes {
+			// Nimbus is both, the name alone is taken as the consensus client
+			if word == name && execution == unknownClient && name != "nimbus" {
+				execution = name
+			}
+		}
+	}
+	return consensus, execution
+}
+
+// Returns the clients of the blocks of the epoch, by slot
+func GetBlockClients(slotGraffiti map[uint64]string) map[uint64]blockClients {
+	clients := make(map[uint64]blockClients, len(slotGraffiti))
+	for slot, graffiti := range slotGraffiti {
+		consensus, execution := ParseGraffiti(graffiti)
+		clients[slot] = blockClients{consensus: consensus, execution: execution}
+	}
+	return clients
+}
+
+// Counts the blocks proposed by the pool by the pair of clients that built
+// them, sorted by client
+func GetClientDiversity(
+	epoch uint64,
+	poolName string,
+	proposed []schemas.Duty,
+	clients map[uint64]blockClients) []schemas.ClientDiversity {
+
+	counts := make(map[blockClients]uint64)
+	for _, duty := range proposed {
+		blockClient, ok := clients[duty.Slot]
+		if !ok {
+			blockClient = blockClients{consensus: unknownClient, execution: unknownClient}
+		}
+		counts[blockClient]++
+	}
+
+	diversity := make([]schemas.ClientDiversity, 0, len(counts))
+	for blockClient, nOfBlocks := range counts {
+		diversity = append(diversity, schemas.ClientDiversity{
+			Epoch:           epoch,
+			PoolName:        poolName,
+			ConsensusClient: blockClient.consensus,
+			ExecutionClient: blockClient.execution,
+			NOfBlocks:       nOfBlocks,
+		})
+	}
+	slices.SortFunc(diversity, func(a, b schemas.ClientDiversity) int {
+		if c := strings.Compare(a.ConsensusClient, b.ConsensusClient); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ExecutionClient, b.ExecutionClient)
+	})
+	return diversity
+}
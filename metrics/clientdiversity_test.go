@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseGraffiti(t *testing.T) {
+	tests := []struct {
+		graffiti  string
+		consensus string
+		execution string
+	}{
+		{"GE1234LH5678", "lighthouse", "geth"},
+		{"my pool NMb1c2TKa1b2", "teku", "nethermind"},
+		{"RHPM", "prysm", "reth"},
+		// Consensus client that could not reach the execution client
+		{"LS12ab", "lodestar", "unknown"},
+		{"Lighthouse/v5.1.0-abcdef", "lighthouse", "unknown"},
+		{"teku-besu", "teku", "besu"},
+		{"Nimbus", "nimbus", "unknown"},
+		// Names inside other words and codes of unknown clients are ignored
+		{"together we stake", "unknown", "unknown"},
+		{"XX1234YY5678", "unknown", "unknown"},
+		{"", "unknown", "unknown"},
+	}
+	for _, test := range tests {
+		consensus, execution := ParseGraffiti(test.graffiti)
+		require.Equal(t, test.consensus, consensus, test.graffiti)
+		require.Equal(t, test.execution, execution, test.graffiti)
+	}
+}
+
+func Test_GetClientDiversity(t *testing.T) {
+	clients := GetBlockClients(map[uint64]string{
+		320: "GE1234LH5678",
+		321: "NM12PM34",
+		322: "GELH",
+	})
+	proposed := []schemas.Duty{{Slot: 320}, {Slot: 321}, {Slot: 322}, {Slot: 323}}
+
+	require.Equal(t, []schemas.ClientDiversity{
+		{Epoch: 10, PoolName: "pool_a", ConsensusClient: "lighthouse", ExecutionClient: "geth", NOfBlocks: 2},
+		{Epoch: 10, PoolName: "pool_a", ConsensusClient: "prysm", ExecutionClient: "nethermind", NOfBlocks: 1},
+		// Block not found in the block data
+		{Epoch: 10, PoolName: "pool_a", ConsensusClient: "unknown", ExecutionClient: "unknown", NOfBlocks: 1},
+	}, GetClientDiversity(10, "pool_a", proposed, clients))
+}
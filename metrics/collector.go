@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/pkg/errors"
+)
+
+// Metrics of a pool in an epoch
+type PoolResult struct {
+	Performance            schemas.ValidatorPerformanceMetrics
+	Proposals              schemas.ProposalDutiesMetrics
+	RelayRewards           *big.Int
+	ValidatorStatus        schemas.ValidatorStatus
+	FeeRecipientViolations []schemas.FeeRecipientViolation
+	Blobs                  []schemas.BlockBlobs
+	MEVPayouts             []schemas.MEVPayout
+	MissedMEV              []schemas.MissedMEV
+	ProposalTimings        []schemas.ProposalTiming
+	Effectiveness          schemas.Effectiveness
+}
+
+// Metrics of the pools in an epoch. Pools that could not be processed are in
+// Errors instead of Pools.
+type EpochResult struct {
+	Epoch  uint64
+	Pools  map[string]*PoolResult
+	Errors map[string]error
+}
+
+// Computes the metrics of the configured pools for a given epoch, to use
+// eth-metrics as a library instead of running the loop. The database is
+// optional: if config.DatabasePath is set the metrics are also stored there.
+// Not safe for concurrent use.
+type Collector struct {
+	metrics *Metrics
+	// Reused if the next epoch is collected, to avoid fetching it again
+	prevEpoch       uint64
+	prevBeaconState *spec.VersionedBeaconState
+}
+
+func NewCollector(ctx context.Context, cfg *config.Config) (*Collector, error) {
+	metrics, err := NewMetrics(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := metrics.setup(); err != nil {
+		return nil, errors.Wrap(err, "error setting up the metrics")
+	}
+	return &Collector{metrics: metrics}, nil
+}
+
+// Fetches the data of the epoch from the beacon node and relays and returns
+// the metrics of each pool. Canceling the context stops the beacon state
+// download.
+func (c *Collector) CollectEpoch(ctx context.Context, epoch uint64) (*EpochResult, error) {
+	if epoch == 0 {
+		return nil, errors.New("epoch 0 has no previous epoch to compare with")
+	}
+	var prevBeaconState *spec.VersionedBeaconState
+	if c.prevBeaconState != nil && c.prevEpoch == epoch-1 {
+		prevBeaconState = c.prevBeaconState
+	}
+
+	currentBeaconState, result, err := c.metrics.processEpoch(ctx, epoch, prevBeaconState, nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	c.prevEpoch = epoch
+	c.prevBeaconState = currentBeaconState
+	return result, nil
+}
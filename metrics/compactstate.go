@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"bytes"
+
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/altair"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/deneb"
+	"github.com/attestantio/go-eth2-client/spec/electra"
+	"github.com/attestantio/go-eth2-client/spec/fulu"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+)
+
+// Returns a beacon state of the same fork with only the fields read by the
+// metrics: validators, balances, previous epoch participation, sync committee,
+// timestamp and the electra deposit, partial withdrawal and consolidation
+// queues. The rest, e.g.
+// roots, randao mixes or inactivity scores, can then be garbage collected.
+// The validators that didn't change since the previous compacted state are
+// shared with it, which is most of the memory when two states are held.
+func CompactBeaconState(state *spec.VersionedBeaconState, prev *spec.VersionedBeaconState) *spec.VersionedBeaconState {
+	validators := GetValidators(state)
+	if prev != nil {
+		validators = shareValidators(validators, GetValidators(prev))
+	}
+
+	compact := &spec.VersionedBeaconState{Version: state.Version}
+	if s := state.Altair; s != nil {
+		compact.Altair = &altair.BeaconState{
+			Slot:                       s.Slot,
+			Validators:                 validators,
+			Balances:                   s.Balances,
+			PreviousEpochParticipation: s.PreviousEpochParticipation,
+			CurrentSyncCommittee:       s.CurrentSyncCommittee,
+		}
+	} else if s := state.Bellatrix; s != nil {
+		compact.Bellatrix = &bellatrix.BeaconState{
+			Slot:                         s.Slot,
+			Validators:                   validators,
+			Balances:                     s.Balances,
+			PreviousEpochParticipation:   s.PreviousEpochParticipation,
+			CurrentSyncCommittee:         s.CurrentSyncCommittee,
+			LatestExecutionPayloadHeader: s.LatestExecutionPayloadHeader,
+		}
+	} else if s := state.Capella; s != nil {
+		compact.Capella = &capella.BeaconState{
+			Slot:                         s.Slot,
+			Validators:                   validators,
+			Balances:                     s.Balances,
+			PreviousEpochParticipation:   s.PreviousEpochParticipation,
+			CurrentSyncCommittee:         s.CurrentSyncCommittee,
+			LatestExecutionPayloadHeader: s.LatestExecutionPayloadHeader,
+		}
+	} else if s := state.Deneb; s != nil {
+		compact.Deneb = &deneb.BeaconState{
+			Slot:                         s.Slot,
+			Validators:                   validators,
+			Balances:                     s.Balances,
+			PreviousEpochParticipation:   s.PreviousEpochParticipation,
+			CurrentSyncCommittee:         s.CurrentSyncCommittee,
+			LatestExecutionPayloadHeader: s.LatestExecutionPayloadHeader,
+		}
+	} else if s := state.Electra; s != nil {
+		compact.Electra = &electra.BeaconState{
+			Slot:                         s.Slot,
+			Validators:                   validators,
+			Balances:                     s.Balances,
+			PreviousEpochParticipation:   s.PreviousEpochParticipation,
+			CurrentSyncCommittee:         s.CurrentSyncCommittee,
+			LatestExecutionPayloadHeader: s.LatestExecutionPayloadHeader,
+			DepositBalanceToConsume:      s.DepositBalanceToConsume,
+			EarliestExitEpoch:            s.EarliestExitEpoch,
+			PendingDeposits:              s.PendingDeposits,
+			PendingPartialWithdrawals:    s.PendingPartialWithdrawals,
+			PendingConsolidations:        s.PendingConsolidations,
+		}
+	} else if s := state.Fulu; s != nil {
+		compact.Fulu = &fulu.BeaconState{
+			Slot:                         s.Slot,
+			Validators:                   validators,
+			Balances:                     s.Balances,
+			PreviousEpochParticipation:   s.PreviousEpochParticipation,
+			CurrentSyncCommittee:         s.CurrentSyncCommittee,
+			LatestExecutionPayloadHeader: s.LatestExecutionPayloadHeader,
+			DepositBalanceToConsume:      s.DepositBalanceToConsume,
+			EarliestExitEpoch:            s.EarliestExitEpoch,
+			PendingDeposits:              s.PendingDeposits,
+			PendingPartialWithdrawals:    s.PendingPartialWithdrawals,
+			PendingConsolidations:        s.PendingConsolidations,
+		}
+	} else {
+		return state
+	}
+	return compact
+}
+
+// Returns the validators, reusing the ones of the previous state that are
+// equal. The validators are never modified, so they can be shared.
+func shareValidators(validators []*phase0.Validator, prevValidators []*phase0.Validator) []*phase0.Validator {
+	shared := make([]*phase0.Validator, len(validators))
+	for i, validator := range validators {
+		shared[i] = validator
+		if i < len(prevValidators) && equalValidators(validator, prevValidators[i]) {
+			shared[i] = prevValidators[i]
+		}
+	}
+	return shared
+}
+
+func equalValidators(a *phase0.Validator, b *phase0.Validator) bool {
+	return a.PublicKey == b.PublicKey &&
+		bytes.Equal(a.WithdrawalCredentials, b.WithdrawalCredentials) &&
+		a.EffectiveBalance == b.EffectiveBalance &&
+		a.Slashed == b.Slashed &&
+		a.ActivationEligibilityEpoch == b.ActivationEligibilityEpoch &&
+		a.ActivationEpoch == b.ActivationEpoch &&
+		a.ExitEpoch == b.ExitEpoch &&
+		a.WithdrawableEpoch == b.WithdrawableEpoch
+}
@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"context"
+	"encoding/binary"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/api"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/avast/retry-go/v4"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Event emitted by the deposit contract for every deposit. Since electra the
+// blocks include the same data as deposit requests.
+const depositContractAbi = `[
+	{"name":"DepositEvent","type":"event","anonymous":false,"inputs":[{"name":"pubkey","type":"bytes","indexed":false},{"name":"withdrawal_credentials","type":"bytes","indexed":false},{"name":"amount","type":"bytes","indexed":false},{"name":"signature","type":"bytes","indexed":false},{"name":"index","type":"bytes","indexed":false}]}
+]`
+
+var depositContract, _ = abi.JSON(strings.NewReader(depositContractAbi))
+
+// Decodes a DepositEvent log of the deposit contract. The amount and the index
+// are encoded as little endian
+func ParseDepositLog(depositLog types.Log) (schemas.Deposit, error) {
+	values, err := depositContract.Unpack("DepositEvent", depositLog.Data)
+	if err != nil {
+		return schemas.Deposit{}, errors.Wrap(err, "error decoding deposit event")
+	}
+	pubkey := values[0].([]byte)
+	withdrawalCredentials := values[1].([]byte)
+	amount := values[2].([]byte)
+	index := values[4].([]byte)
+	if len(amount) != 8 || len(index) != 8 {
+		return schemas.Deposit{}, errors.New("invalid amount or index in deposit event")
+	}
+	return schemas.Deposit{
+		BlockNumber:           depositLog.BlockNumber,
+		DepositIndex:          binary.LittleEndian.Uint64(index),
+		Pubkey:                hexutil.Encode(pubkey),
+		WithdrawalCredentials: hexutil.Encode(withdrawalCredentials),
+		Amount:                binary.LittleEndian.Uint64(amount),
+	}, nil
+}
+
+// Returns the deposits of the blocks before electra, which have no deposit
+// requests, from the logs of the deposit contract. Blocks are given as block
+// number to slot.
+func (b *BlockData) getDepositLogs(epoch uint64, blocks map[uint64]uint64) ([]schemas.Deposit, error) {
+	address, err := b.getDepositContract()
+	if err != nil {
+		return nil, err
+	}
+
+	var fromBlock, toBlock uint64
+	for blockNumber := range blocks {
+		if fromBlock == 0 || blockNumber < fromBlock {
+			fromBlock = blockNumber
+		}
+		toBlock = max(toBlock, blockNumber)
+	}
+
+	var logs []types.Log
+	err = retry.Do(func() error {
+		logs, err = b.executionClient.FilterLogs(context.Background(), ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(fromBlock),
+			ToBlock:   new(big.Int).SetUint64(toBlock),
+			Addresses: []common.Address{address},
+			Topics:    [][]common.Hash{{depositContract.Events["DepositEvent"].ID}},
+		})
+		if err != nil {
+			log.Warnf("error getting deposit logs of blocks %d to %d: %s. Retrying...", fromBlock, toBlock, err)
+			return errors.Wrap(err, "error getting deposit logs")
+		}
+		return nil
+	}, b.retryOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	deposits := make([]schemas.Deposit, 0, len(logs))
+	for _, depositLog := range logs {
+		slot, ok := blocks[depositLog.BlockNumber]
+		if !ok || depositLog.Removed {
+			continue
+		}
+		deposit, err := ParseDepositLog(depositLog)
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing deposit log of block "+depositLog.BlockHash.String())
+		}
+		deposit.Epoch = epoch
+		deposit.Slot = slot
+		deposit.Timestamp = b.networkParameters.slotTime(slot)
+		deposits = append(deposits, deposit)
+	}
+	return deposits, nil
+}
+
+// Address of the deposit contract of the network, fetched once from the
+// beacon node
+func (b *BlockData) getDepositContract() (common.Address, error) {
+	if b.depositContract != nil {
+		return *b.depositContract, nil
+	}
+	ctxTimeout, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	contract, err := b.consensusClient.DepositContract(ctxTimeout, &api.DepositContractOpts{})
+	if err != nil {
+		return common.Address{}, errors.Wrap(err, "error getting deposit contract")
+	}
+	address := common.BytesToAddress(contract.Data.Address)
+	b.depositContract = &address
+	return address, nil
+}
+
+// Returns the keys of the given validators that are activated in the epoch
+func GetActivatedKeys(epoch uint64, validatorIndexes []uint64, validators []*phase0.Validator) []string {
+	activated := make([]string, 0)
+	for _, valIdx := range validatorIndexes {
+		if valIdx >= uint64(len(validators)) {
+			continue
+		}
+		if uint64(validators[valIdx].ActivationEpoch) == epoch {
+			activated = append(activated, hexutil.Encode(validators[valIdx].PublicKey[:]))
+		}
+	}
+	return activated
+}
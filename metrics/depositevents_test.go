@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseDepositLog(t *testing.T) {
+	pubkey := make([]byte, 48)
+	pubkey[0] = 0xaa
+	withdrawalCredentials := make([]byte, 32)
+	withdrawalCredentials[0] = 0x02
+	amount := binary.LittleEndian.AppendUint64(nil, 32000000000)
+	index := binary.LittleEndian.AppendUint64(nil, 1234)
+
+	data, err := depositContract.Events["DepositEvent"].Inputs.Pack(pubkey, withdrawalCredentials, amount, make([]byte, 96), index)
+	require.NoError(t, err)
+
+	deposit, err := ParseDepositLog(types.Log{BlockNumber: 100, Data: data})
+	require.NoError(t, err)
+	require.Equal(t, schemas.Deposit{
+		BlockNumber:           100,
+		DepositIndex:          1234,
+		Pubkey:                "0xaa" + strings.Repeat("00", 47),
+		WithdrawalCredentials: "0x02" + strings.Repeat("00", 31),
+		Amount:                32000000000,
+	}, deposit)
+
+	// Amount of the wrong size
+	data, err = depositContract.Events["DepositEvent"].Inputs.Pack(pubkey, withdrawalCredentials, []byte{1}, make([]byte, 96), index)
+	require.NoError(t, err)
+	_, err = ParseDepositLog(types.Log{Data: data})
+	require.Error(t, err)
+}
+
+func Test_GetActivatedKeys(t *testing.T) {
+	validators := []*phase0.Validator{
+		{PublicKey: phase0.BLSPubKey{1}, ActivationEpoch: 10},
+		{PublicKey: phase0.BLSPubKey{2}, ActivationEpoch: 9},
+		{PublicKey: phase0.BLSPubKey{3}, ActivationEpoch: 10},
+		{PublicKey: phase0.BLSPubKey{4}, ActivationEpoch: farFutureEpoch},
+	}
+	// Validator 2 is not tracked and 7 is not in the state
+	activated := GetActivatedKeys(10, []uint64{0, 1, 3, 7}, validators)
+	require.Equal(t, []string{"0x01" + strings.Repeat("00", 47)}, activated)
+}
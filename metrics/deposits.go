@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/electra"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+)
+
+const compoundingWithdrawalPrefix = 0x02
+
+// Returns the balance moved in or out of each validator by pending deposits
+// between two consecutive states, which must not be counted as rewards. Applied
+// deposits (e.g. top ups of compounding validators) increase the balance, while
+// switching to 0x02 credentials moves the balance above 32 ETH out of the
+// validator and into the deposit queue until it is applied again.
+func GetDepositBalanceChanges(
+	prevBeaconState *spec.VersionedBeaconState,
+	currentBeaconState *spec.VersionedBeaconState,
+	valKeyToIndex map[string]uint64,
+) (map[uint64]*big.Int, error) {
+	balanceChanges := make(map[uint64]*big.Int)
+
+	prevPendingDeposits := GetPendingDeposits(prevBeaconState)
+	currPendingDeposits := GetPendingDeposits(currentBeaconState)
+	if prevPendingDeposits == nil || currPendingDeposits == nil {
+		return balanceChanges, nil
+	}
+
+	addChange := func(valIdx uint64, amount *big.Int) {
+		if _, ok := balanceChanges[valIdx]; !ok {
+			balanceChanges[valIdx] = big.NewInt(0)
+		}
+		balanceChanges[valIdx].Add(balanceChanges[valIdx], amount)
+	}
+
+	nOfRemoved := GetNumberOfRemovedDeposits(prevPendingDeposits, currPendingDeposits)
+	if nOfRemoved < 0 {
+		return nil, errors.New("pending deposits queue does not match the previous one")
+	}
+
+	// Deposits removed from the front of the queue were applied, unless the
+	// validator was exiting, in which case they are postponed to the end
+	prevValidators := GetValidators(prevBeaconState)
+	for _, deposit := range prevPendingDeposits[:nOfRemoved] {
+		valIdx, ok := valKeyToIndex[hex.EncodeToString(deposit.Pubkey[:])]
+		if !ok || valIdx >= uint64(len(prevValidators)) {
+			continue
+		}
+		if prevValidators[valIdx].ExitEpoch != farFutureEpoch {
+			continue
+		}
+		addChange(valIdx, big.NewInt(0).SetUint64(uint64(deposit.Amount)))
+	}
+
+	// The excess balance is queued with the genesis slot when switching to
+	// compounding credentials
+	currValidators := GetValidators(currentBeaconState)
+	for _, deposit := range currPendingDeposits[len(prevPendingDeposits)-nOfRemoved:] {
+		if deposit.Slot != 0 {
+			continue
+		}
+		valIdx, ok := valKeyToIndex[hex.EncodeToString(deposit.Pubkey[:])]
+		if !ok || valIdx >= uint64(len(prevValidators)) || valIdx >= uint64(len(currValidators)) {
+			continue
+		}
+		if !isCompounding(prevValidators[valIdx]) && isCompounding(currValidators[valIdx]) {
+			addChange(valIdx, big.NewInt(0).Neg(big.NewInt(0).SetUint64(uint64(deposit.Amount))))
+		}
+	}
+	return balanceChanges, nil
+}
+
+// Number of deposits processed from the front of the queue, found as the first
+// position from which the remaining previous deposits are the start of the
+// current queue. Returns -1 if there is none.
+func GetNumberOfRemovedDeposits(
+	prevPendingDeposits []*electra.PendingDeposit,
+	currPendingDeposits []*electra.PendingDeposit) int {
+
+	for removed := 0; removed <= len(prevPendingDeposits); removed++ {
+		remaining := prevPendingDeposits[removed:]
+		if len(remaining) > len(currPendingDeposits) {
+			continue
+		}
+		matches := true
+		for i, deposit := range remaining {
+			if !isSameDeposit(deposit, currPendingDeposits[i]) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return removed
+		}
+	}
+	return -1
+}
+
+func isSameDeposit(a *electra.PendingDeposit, b *electra.PendingDeposit) bool {
+	return a.Pubkey == b.Pubkey &&
+		a.Amount == b.Amount &&
+		a.Slot == b.Slot &&
+		a.Signature == b.Signature &&
+		bytes.Equal(a.WithdrawalCredentials, b.WithdrawalCredentials)
+}
+
+func isCompounding(validator *phase0.Validator) bool {
+	return len(validator.WithdrawalCredentials) > 0 &&
+		validator.WithdrawalCredentials[0] == compoundingWithdrawalPrefix
+}
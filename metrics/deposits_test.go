@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/electra"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GetNumberOfRemovedDeposits(t *testing.T) {
+	d0 := &electra.PendingDeposit{Pubkey: phase0.BLSPubKey{0x01}, Amount: 1, Slot: 10}
+	d1 := &electra.PendingDeposit{Pubkey: phase0.BLSPubKey{0x02}, Amount: 2, Slot: 11}
+	d2 := &electra.PendingDeposit{Pubkey: phase0.BLSPubKey{0x03}, Amount: 3, Slot: 12}
+	d3 := &electra.PendingDeposit{Pubkey: phase0.BLSPubKey{0x04}, Amount: 4, Slot: 13}
+
+	require.Equal(t, 0, GetNumberOfRemovedDeposits([]*electra.PendingDeposit{d0, d1}, []*electra.PendingDeposit{d0, d1, d2}))
+	require.Equal(t, 1, GetNumberOfRemovedDeposits([]*electra.PendingDeposit{d0, d1}, []*electra.PendingDeposit{d1, d2}))
+	require.Equal(t, 2, GetNumberOfRemovedDeposits([]*electra.PendingDeposit{d0, d1}, []*electra.PendingDeposit{d2, d3}))
+	require.Equal(t, 2, GetNumberOfRemovedDeposits([]*electra.PendingDeposit{d0, d1}, []*electra.PendingDeposit{}))
+	require.Equal(t, 0, GetNumberOfRemovedDeposits([]*electra.PendingDeposit{}, []*electra.PendingDeposit{d0}))
+}
+
+func Test_GetDepositBalanceChanges(t *testing.T) {
+	eth1Credentials := make([]byte, 32)
+	eth1Credentials[0] = 0x01
+	compoundingCredentials := make([]byte, 32)
+	compoundingCredentials[0] = 0x02
+
+	pubKeys := []phase0.BLSPubKey{{0x01}, {0x02}, {0x03}, {0x04}}
+	prevValidators := []*phase0.Validator{
+		{PublicKey: pubKeys[0], WithdrawalCredentials: compoundingCredentials, ExitEpoch: farFutureEpoch},
+		{PublicKey: pubKeys[1], WithdrawalCredentials: eth1Credentials, ExitEpoch: farFutureEpoch},
+		// Exiting, deposits are postponed
+		{PublicKey: pubKeys[2], WithdrawalCredentials: compoundingCredentials, ExitEpoch: 40},
+		{PublicKey: pubKeys[3], WithdrawalCredentials: eth1Credentials, ExitEpoch: farFutureEpoch},
+	}
+	// Validator 1 switches to compounding credentials
+	currValidators := []*phase0.Validator{
+		prevValidators[0],
+		{PublicKey: pubKeys[1], WithdrawalCredentials: compoundingCredentials, ExitEpoch: farFutureEpoch},
+		prevValidators[2],
+		prevValidators[3],
+	}
+
+	topUp := &electra.PendingDeposit{Pubkey: pubKeys[0], Amount: 10000000000, Slot: 1000}
+	postponed := &electra.PendingDeposit{Pubkey: pubKeys[2], Amount: 5000000000, Slot: 1001}
+	notProcessed := &electra.PendingDeposit{Pubkey: pubKeys[3], Amount: 1000000000, Slot: 1002}
+	excess := &electra.PendingDeposit{Pubkey: pubKeys[1], Amount: 1500000000, Slot: 0}
+
+	prevBeaconState := &spec.VersionedBeaconState{
+		Electra: &electra.BeaconState{
+			Slot:            34*32 + 31,
+			Validators:      prevValidators,
+			PendingDeposits: []*electra.PendingDeposit{topUp, postponed, notProcessed},
+		},
+	}
+	currentBeaconState := &spec.VersionedBeaconState{
+		Electra: &electra.BeaconState{
+			Slot:            35*32 + 31,
+			Validators:      currValidators,
+			PendingDeposits: []*electra.PendingDeposit{notProcessed, postponed, excess},
+		},
+	}
+
+	valKeyToIndex := make(map[string]uint64)
+	for i, pubKey := range pubKeys {
+		valKeyToIndex[hex.EncodeToString(pubKey[:])] = uint64(i)
+	}
+
+	changes, err := GetDepositBalanceChanges(prevBeaconState, currentBeaconState, valKeyToIndex)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(changes))
+	require.Equal(t, int64(10000000000), changes[0].Int64())
+	require.Equal(t, int64(-1500000000), changes[1].Int64())
+
+	// The top up is not counted as rewards, nor the excess queued as a loss
+	prevBalances := []uint64{32000000000, 33500000000, 32000000000, 32000000000}
+	currBalances := []uint64{42000000010, 32000000010, 32000000000, 32000000000}
+	for valIdx, expected := range []int64{10, 10} {
+		delta := GetValidatorBalanceDelta(
+			uint64(valIdx),
+			prevBalances,
+			currBalances,
+			prevValidators,
+			map[uint64]*big.Int{},
+			map[uint64][]*electra.PendingConsolidation{},
+			changes,
+			map[uint64]*big.Int{})
+		require.Equal(t, expected, delta.Int64())
+	}
+}
+
+func Test_GetValidatorBalanceDelta_Consolidation(t *testing.T) {
+	prevValidators := []*phase0.Validator{
+		{EffectiveBalance: 2048000000000},
+		// Source with less balance than its effective balance
+		{EffectiveBalance: 32000000000},
+	}
+	prevBalances := []uint64{2048000000000, 31900000000}
+	currBalances := []uint64{2079900000020, 0}
+
+	delta := GetValidatorBalanceDelta(
+		0,
+		prevBalances,
+		currBalances,
+		prevValidators,
+		map[uint64]*big.Int{},
+		map[uint64][]*electra.PendingConsolidation{0: {{SourceIndex: 1, TargetIndex: 0}}},
+		map[uint64]*big.Int{},
+		map[uint64]*big.Int{})
+	require.Equal(t, int64(20), delta.Int64())
+}
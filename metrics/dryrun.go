@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/bilinearlabs/eth-metrics/config"
+)
+
+// Metrics of an epoch as printed in dry-run mode, one json line per epoch
+type dryRunEpoch struct {
+	Epoch  uint64                 `json:"epoch"`
+	Pools  map[string]*PoolResult `json:"pools"`
+	Errors map[string]string      `json:"errors,omitempty"`
+}
+
+// Writes the metrics of the epoch as a json line
+func WriteEpochResult(w io.Writer, result *EpochResult) error {
+	epoch := dryRunEpoch{
+		Epoch: result.Epoch,
+		Pools: result.Pools,
+	}
+	if len(result.Errors) > 0 {
+		epoch.Errors = make(map[string]string, len(result.Errors))
+		for poolName, err := range result.Errors {
+			epoch.Errors[poolName] = err.Error()
+		}
+	}
+	return json.NewEncoder(w).Encode(epoch)
+}
+
+// Config of the clickhouse, publisher, sinks and alert webhooks, which are
+// disabled in dry-run mode so that nothing leaves the process
+func (a *Metrics) outputConfig() *config.Config {
+	if !a.config.DryRun {
+		return a.config
+	}
+	cfg := *a.config
+	cfg.ClickHouseUrl = ""
+	cfg.PublishBroker = ""
+	cfg.Sinks = nil
+	cfg.AlertWebhook = ""
+	cfg.AlertSlackWebhook = ""
+	cfg.AlertDiscordWebhook = ""
+	cfg.AlertRoutes = nil
+	cfg.TelegramToken = ""
+	cfg.Tenants = make([]config.Tenant, len(a.config.Tenants))
+	for i, tenant := range a.config.Tenants {
+		cfg.Tenants[i] = config.Tenant{Id: tenant.Id, Token: tenant.Token, Pools: tenant.Pools}
+	}
+	return &cfg
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WriteEpochResult(t *testing.T) {
+	var out bytes.Buffer
+	for epoch := uint64(100); epoch < 102; epoch++ {
+		err := WriteEpochResult(&out, &EpochResult{
+			Epoch: epoch,
+			Pools: map[string]*PoolResult{
+				"pool_a": {
+					Performance:  schemas.ValidatorPerformanceMetrics{Epoch: epoch, PoolName: "pool_a", NOfActiveValidators: 2},
+					RelayRewards: big.NewInt(5),
+				},
+			},
+			Errors: map[string]error{"pool_b": errors.New("no state")},
+		})
+		require.NoError(t, err)
+	}
+
+	// One json line per epoch
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+	var printed struct {
+		Epoch uint64
+		Pools map[string]struct {
+			Performance  struct{ NOfActiveValidators uint64 }
+			RelayRewards *big.Int
+		}
+		Errors map[string]string
+	}
+	require.NoError(t, json.Unmarshal(lines[1], &printed))
+	require.Equal(t, uint64(101), printed.Epoch)
+	require.Equal(t, uint64(2), printed.Pools["pool_a"].Performance.NOfActiveValidators)
+	require.Equal(t, big.NewInt(5), printed.Pools["pool_a"].RelayRewards)
+	require.Equal(t, map[string]string{"pool_b": "no state"}, printed.Errors)
+}
+
+func Test_OutputConfig(t *testing.T) {
+	cfg := &config.Config{
+		PublishBroker: "nats://localhost:4222",
+		Sinks:         []string{"influxdb://localhost:8086/db"},
+		AlertWebhook:  "http://localhost/alerts",
+		AlertRoutes:   []string{"*:critical:generic"},
+		TelegramToken: "123:abc",
+		ClickHouseUrl: "clickhouse://localhost",
+		PoolNames:     []string{"pool_a"},
+	}
+	a := &Metrics{config: cfg}
+	require.Same(t, cfg, a.outputConfig())
+
+	// Nothing leaves the process in dry-run mode
+	cfg.DryRun = true
+	outputs := a.outputConfig()
+	require.Empty(t, outputs.PublishBroker)
+	require.Empty(t, outputs.Sinks)
+	require.Empty(t, outputs.AlertWebhook)
+	// Routes to the disabled webhooks would not be valid
+	require.Empty(t, outputs.AlertRoutes)
+	require.Empty(t, outputs.TelegramToken)
+	require.Empty(t, outputs.ClickHouseUrl)
+	require.Equal(t, cfg.PoolNames, outputs.PoolNames)
+	require.Equal(t, "nats://localhost:4222", cfg.PublishBroker)
+}
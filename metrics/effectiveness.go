@@ -0,0 +1,235 @@
+package metrics
+
+import (
+	"encoding/hex"
+	"maps"
+	"slices"
+
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	log "github.com/sirupsen/logrus"
+)
+
+// Weights of the rewards of each duty out of 64, see the Altair spec
+// https://github.com/ethereum/consensus-specs/blob/master/specs/altair/beacon-chain.md#incentivization-weights
+const (
+	timelySourceWeight = 14
+	timelyTargetWeight = 26
+	timelyHeadWeight   = 14
+	syncRewardWeight   = 2
+	proposerWeight     = 8
+)
+
+// Duties of a pool or of the network in an epoch, and how many were missed
+type EpochDuties struct {
+	Validators      uint64
+	IncorrectSource uint64
+	IncorrectTarget uint64
+	IncorrectHead   uint64
+	// 0 if unknown, e.g. if the inclusion-delay module is disabled
+	AvgInclusionDelay float64
+	ScheduledBlocks   uint64
+	ProposedBlocks    uint64
+	SyncDuties        uint64
+	MissedSyncDuties  uint64
+}
+
+// Returns the share of correct source, target and head votes of the given
+// validators, weighted as their rewards
+func GetAttestationRate(validators uint64, incorrectSource uint64, incorrectTarget uint64, incorrectHead uint64) float64 {
+	incorrect := timelySourceWeight*incorrectSource +
+		timelyTargetWeight*incorrectTarget +
+		timelyHeadWeight*incorrectHead
+	return 1 - float64(incorrect)/float64((timelySourceWeight+timelyTargetWeight+timelyHeadWeight)*validators)
+}
+
+// Rates the duties from 0 to 100, similarly to rated.network. The correct votes
+// are scaled by one over the inclusion delay, and each kind of duty is weighted
+// as its rewards, counting only the kinds that there were duties of, so that a
+// pool without proposals or sync committee duties is not rated lower.
+func GetEffectiveness(duties EpochDuties) schemas.Effectiveness {
+	var effectiveness schemas.Effectiveness
+	var weighted, weights float64
+
+	if duties.Validators > 0 {
+		attestation := GetAttestationRate(duties.Validators, duties.IncorrectSource, duties.IncorrectTarget, duties.IncorrectHead)
+		effectiveness.Attestation = &attestation
+
+		attester := attestation
+		// The delay is at least one slot
+		if duties.AvgInclusionDelay >= 1 {
+			inclusion := 1 / duties.AvgInclusionDelay
+			effectiveness.Inclusion = &inclusion
+			attester *= inclusion
+		}
+		weighted += (timelySourceWeight + timelyTargetWeight + timelyHeadWeight) * attester
+		weights += timelySourceWeight + timelyTargetWeight + timelyHeadWeight
+	}
+	if duties.ScheduledBlocks > 0 {
+		proposal := float64(duties.ProposedBlocks) / float64(duties.ScheduledBlocks)
+		effectiveness.Proposal = &proposal
+		weighted += proposerWeight * proposal
+		weights += proposerWeight
+	}
+	if duties.SyncDuties > 0 {
+		syncCommittee := 1 - float64(duties.MissedSyncDuties)/float64(duties.SyncDuties)
+		effectiveness.SyncCommittee = &syncCommittee
+		weighted += syncRewardWeight * syncCommittee
+		weights += syncRewardWeight
+	}
+	if weights > 0 {
+		score := 100 * weighted / weights
+		effectiveness.Score = &score
+	}
+	return effectiveness
+}
+
+// Returns the slots of the epoch that the given validators had to sign as
+// members of the sync committee, once per seat, and how many of them were not
+// signed according to the sync aggregates of the blocks. Slots without block
+// are not counted. All the members are counted if validatorIndexes is nil.
+func GetSyncCommitteeDuties(
+	epoch uint64,
+	slotsInEpoch uint64,
+	beaconState *spec.VersionedBeaconState,
+	blocks map[uint64]*spec.VersionedSignedBeaconBlock,
+	valKeyToIndex map[string]uint64,
+	validatorIndexes map[uint64]bool) (uint64, uint64) {
+
+	seats := make([]uint64, 0)
+	for seat, key := range GetCurrentSyncCommittee(beaconState) {
+		valIdx, ok := valKeyToIndex[hex.EncodeToString(key[:])]
+		if validatorIndexes == nil || (ok && validatorIndexes[valIdx]) {
+			seats = append(seats, uint64(seat))
+		}
+	}
+	if len(seats) == 0 {
+		return 0, 0
+	}
+
+	var duties, missed uint64
+	for slot := epoch * slotsInEpoch; slot < (epoch+1)*slotsInEpoch; slot++ {
+		block, ok := blocks[slot]
+		if !ok {
+			continue
+		}
+		syncAggregate, err := block.SyncAggregate()
+		if err != nil {
+			// Blocks before Altair have no sync committee
+			continue
+		}
+		for _, seat := range seats {
+			duties++
+			if !syncAggregate.SyncCommitteeBits.BitAt(seat) {
+				missed++
+			}
+		}
+	}
+	return duties, missed
+}
+
+// Rates the duties of the pool in the epoch, from its metrics and proposals
+func (a *Metrics) getPoolEffectiveness(
+	data *epochData,
+	poolName string,
+	validatorIndexes []uint64,
+	poolMetrics *schemas.ValidatorPerformanceMetrics,
+	poolProposals *schemas.ProposalDutiesMetrics) schemas.Effectiveness {
+
+	poolIndexes := make(map[uint64]bool, len(validatorIndexes))
+	for _, valIdx := range validatorIndexes {
+		poolIndexes[valIdx] = true
+	}
+	duties := EpochDuties{
+		Validators:        poolMetrics.NOfValidatingKeys,
+		IncorrectSource:   poolMetrics.NOfIncorrectSource,
+		IncorrectTarget:   poolMetrics.NOfIncorrectTarget,
+		IncorrectHead:     poolMetrics.NOfIncorrectHead,
+		AvgInclusionDelay: poolMetrics.AvgInclusionDelay,
+		ScheduledBlocks:   uint64(len(poolProposals.Scheduled)),
+		ProposedBlocks:    uint64(len(poolProposals.Proposed)),
+	}
+	duties.SyncDuties, duties.MissedSyncDuties = GetSyncCommitteeDuties(
+		data.epoch,
+		a.networkParameters.slotsInEpoch,
+		data.currentBeaconState,
+		data.blocks,
+		data.valKeyToIndex,
+		poolIndexes)
+
+	effectiveness := GetEffectiveness(duties)
+	effectiveness.Time = poolMetrics.Time
+	effectiveness.Epoch = data.epoch
+	effectiveness.PoolName = poolName
+	if data.networkStats != nil {
+		SetNetworkDeltas(&effectiveness, poolMetrics, data.networkStats, data.networkEffectiveness)
+	}
+	return effectiveness
+}
+
+// Sets how far the pool is above the network, or below if negative. Only the
+// score needs the effectiveness of the network, which may be nil.
+func SetNetworkDeltas(
+	effectiveness *schemas.Effectiveness,
+	poolMetrics *schemas.ValidatorPerformanceMetrics,
+	networkStats *schemas.NetworkStats,
+	networkEffectiveness *schemas.Effectiveness) {
+
+	delta := func(pool *float64, network float64) *float64 {
+		if pool == nil {
+			return nil
+		}
+		d := *pool - network
+		return &d
+	}
+	if validators := poolMetrics.NOfValidatingKeys; validators > 0 && networkStats.NOfActiveValidators > 0 {
+		source := 1 - float64(poolMetrics.NOfIncorrectSource)/float64(validators)
+		target := 1 - float64(poolMetrics.NOfIncorrectTarget)/float64(validators)
+		head := 1 - float64(poolMetrics.NOfIncorrectHead)/float64(validators)
+		effectiveness.SourceParticipationDelta = delta(&source, networkStats.SourceParticipation)
+		effectiveness.TargetParticipationDelta = delta(&target, networkStats.TargetParticipation)
+		effectiveness.HeadParticipationDelta = delta(&head, networkStats.HeadParticipation)
+		effectiveness.AttestationDelta = delta(effectiveness.Attestation, networkStats.AttestationEffectiveness)
+	}
+	if networkStats.NOfScheduledBlocks > 0 {
+		effectiveness.ProposalDelta = delta(effectiveness.Proposal, networkStats.ProposalRate)
+	}
+	if networkEffectiveness != nil && networkEffectiveness.Score != nil {
+		effectiveness.ScoreDelta = delta(effectiveness.Score, *networkEffectiveness.Score)
+	}
+}
+
+// Rates the whole network in the epoch, from the network stats, to compare
+// the pools with. Nil if the network stats were not computed, e.g. in light
+// mode, since the light beacon state only contains the tracked validators.
+func (a *Metrics) getNetworkEffectiveness(data *epochData) *schemas.Effectiveness {
+	if data.networkStats == nil {
+		return nil
+	}
+
+	duties := EpochDuties{
+		Validators:      data.networkStats.NOfActiveValidators,
+		IncorrectSource: data.networkStats.NOfIncorrectSource,
+		IncorrectTarget: data.networkStats.NOfIncorrectTarget,
+		IncorrectHead:   data.networkStats.NOfIncorrectHead,
+		ScheduledBlocks: data.networkStats.NOfScheduledBlocks,
+		ProposedBlocks:  data.networkStats.NOfProposedBlocks,
+	}
+	// All the validators that attested
+	duties.AvgInclusionDelay, _ = GetAvgAndMaxInclusionDelay(slices.Collect(maps.Keys(data.inclusionDelays)), data.inclusionDelays)
+	duties.SyncDuties, duties.MissedSyncDuties = GetSyncCommitteeDuties(
+		data.epoch,
+		a.networkParameters.slotsInEpoch,
+		data.currentBeaconState,
+		data.blocks,
+		data.valKeyToIndex,
+		nil)
+
+	effectiveness := GetEffectiveness(duties)
+	effectiveness.Time = data.networkStats.Time
+	effectiveness.Epoch = data.epoch
+	if effectiveness.Score != nil {
+		log.WithField("Epoch", data.epoch).Info("Network effectiveness: ", *effectiveness.Score)
+	}
+	return &effectiveness
+}
@@ -0,0 +1,155 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/altair"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/prysmaticlabs/go-bitfield"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GetEffectiveness(t *testing.T) {
+	// Perfect duties
+	effectiveness := GetEffectiveness(EpochDuties{
+		Validators:        10,
+		AvgInclusionDelay: 1,
+		ScheduledBlocks:   1,
+		ProposedBlocks:    1,
+		SyncDuties:        32,
+	})
+	require.Equal(t, float64(1), *effectiveness.Attestation)
+	require.Equal(t, float64(1), *effectiveness.Inclusion)
+	require.Equal(t, float64(1), *effectiveness.Proposal)
+	require.Equal(t, float64(1), *effectiveness.SyncCommittee)
+	require.Equal(t, float64(100), *effectiveness.Score)
+
+	// Only attestations, the other duties are not counted
+	effectiveness = GetEffectiveness(EpochDuties{
+		Validators:        10,
+		IncorrectSource:   1,
+		IncorrectTarget:   1,
+		IncorrectHead:     2,
+		AvgInclusionDelay: 1.25,
+	})
+	require.InDelta(t, 1-(14+26+2*14)/540.0, *effectiveness.Attestation, 1e-9)
+	require.InDelta(t, 0.8, *effectiveness.Inclusion, 1e-9)
+	require.Nil(t, effectiveness.Proposal)
+	require.Nil(t, effectiveness.SyncCommittee)
+	require.InDelta(t, 100*0.8*(1-68/540.0), *effectiveness.Score, 1e-9)
+
+	// A missed block weights as its rewards, and the inclusion delay is
+	// ignored if unknown
+	effectiveness = GetEffectiveness(EpochDuties{
+		Validators:       10,
+		ScheduledBlocks:  1,
+		SyncDuties:       32,
+		MissedSyncDuties: 8,
+	})
+	require.Nil(t, effectiveness.Inclusion)
+	require.Equal(t, float64(0), *effectiveness.Proposal)
+	require.Equal(t, 0.75, *effectiveness.SyncCommittee)
+	require.InDelta(t, 100*(54+2*0.75)/64, *effectiveness.Score, 1e-9)
+
+	// No duties
+	effectiveness = GetEffectiveness(EpochDuties{})
+	require.Nil(t, effectiveness.Attestation)
+	require.Nil(t, effectiveness.Score)
+}
+
+func Test_GetSyncCommitteeDuties(t *testing.T) {
+	key := func(b byte) phase0.BLSPubKey {
+		var k phase0.BLSPubKey
+		k[0] = b
+		return k
+	}
+	beaconState := &spec.VersionedBeaconState{
+		Altair: &altair.BeaconState{
+			CurrentSyncCommittee: &altair.SyncCommittee{
+				// Validator 1 has two seats
+				Pubkeys: []phase0.BLSPubKey{key(1), key(2), key(1), key(3)},
+			},
+		},
+	}
+	valKeyToIndex := map[string]uint64{
+		"01" + strings.Repeat("00", 47): 1,
+		"02" + strings.Repeat("00", 47): 2,
+		"03" + strings.Repeat("00", 47): 3,
+	}
+	block := func(signedSeats ...uint64) *spec.VersionedSignedBeaconBlock {
+		bits := bitfield.NewBitvector512()
+		for _, seat := range signedSeats {
+			bits.SetBitAt(seat, true)
+		}
+		return &spec.VersionedSignedBeaconBlock{
+			Version: spec.DataVersionAltair,
+			Altair: &altair.SignedBeaconBlock{
+				Message: &altair.BeaconBlock{
+					Body: &altair.BeaconBlockBody{
+						SyncAggregate: &altair.SyncAggregate{SyncCommitteeBits: bits},
+					},
+				},
+			},
+		}
+	}
+	// Slot 65 is skipped, and the block of slot 10 is of another epoch
+	blocks := map[uint64]*spec.VersionedSignedBeaconBlock{
+		10: block(),
+		64: block(0, 1, 2, 3),
+		66: block(1, 2),
+		67: block(3),
+	}
+
+	duties, missed := GetSyncCommitteeDuties(2, 32, beaconState, blocks, valKeyToIndex, map[uint64]bool{1: true})
+	require.Equal(t, uint64(6), duties)
+	require.Equal(t, uint64(3), missed)
+
+	duties, missed = GetSyncCommitteeDuties(2, 32, beaconState, blocks, valKeyToIndex, nil)
+	require.Equal(t, uint64(12), duties)
+	require.Equal(t, uint64(5), missed)
+
+	duties, missed = GetSyncCommitteeDuties(2, 32, beaconState, blocks, valKeyToIndex, map[uint64]bool{4: true})
+	require.Equal(t, uint64(0), duties)
+	require.Equal(t, uint64(0), missed)
+}
+
+func Test_SetNetworkDeltas(t *testing.T) {
+	poolMetrics := &schemas.ValidatorPerformanceMetrics{
+		NOfValidatingKeys:  10,
+		NOfIncorrectSource: 1,
+		NOfIncorrectHead:   2,
+	}
+	effectiveness := GetEffectiveness(EpochDuties{
+		Validators:      10,
+		IncorrectSource: 1,
+		IncorrectHead:   2,
+	})
+	networkStats := &schemas.NetworkStats{
+		NOfActiveValidators:      1000,
+		SourceParticipation:      0.95,
+		TargetParticipation:      0.95,
+		HeadParticipation:        0.9,
+		AttestationEffectiveness: 0.9,
+		NOfScheduledBlocks:       32,
+		ProposalRate:             0.97,
+	}
+	networkScore := 90.0
+	SetNetworkDeltas(&effectiveness, poolMetrics, networkStats, &schemas.Effectiveness{Score: &networkScore})
+	require.InDelta(t, -0.05, *effectiveness.SourceParticipationDelta, 1e-9)
+	require.InDelta(t, 0.05, *effectiveness.TargetParticipationDelta, 1e-9)
+	require.InDelta(t, -0.1, *effectiveness.HeadParticipationDelta, 1e-9)
+	require.InDelta(t, *effectiveness.Attestation-0.9, *effectiveness.AttestationDelta, 1e-9)
+	require.InDelta(t, *effectiveness.Score-90, *effectiveness.ScoreDelta, 1e-9)
+	// The pool had no proposals
+	require.Nil(t, effectiveness.ProposalDelta)
+
+	// Without the network effectiveness, only the score is unknown
+	effectiveness = GetEffectiveness(EpochDuties{Validators: 10, ScheduledBlocks: 1, ProposedBlocks: 1})
+	SetNetworkDeltas(&effectiveness, poolMetrics, networkStats, nil)
+	require.InDelta(t, 0.03, *effectiveness.ProposalDelta, 1e-9)
+	require.NotNil(t, effectiveness.AttestationDelta)
+	require.Nil(t, effectiveness.ScoreDelta)
+}
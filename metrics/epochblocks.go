@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/attestantio/go-eth2-client/api"
+	"github.com/attestantio/go-eth2-client/http"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Fetches the signed blocks of each epoch once, so that the withdrawals, tips,
+// proposer indexes, operations and attestations are all parsed from the same
+// blocks. The blocks of the previous epoch are kept, since the attestations of
+// an epoch are included in the blocks of the next one.
+type EpochBlocks struct {
+	networkParameters *NetworkParameters
+	fetch             func(ctx context.Context, slot uint64) (*spec.VersionedSignedBeaconBlock, error)
+
+	mu sync.Mutex
+	// Blocks by epoch and slot, without the skipped slots
+	epochs map[uint64]map[uint64]*spec.VersionedSignedBeaconBlock
+}
+
+func NewEpochBlocks(
+	consensus *http.Service,
+	networkParameters *NetworkParameters,
+) (*EpochBlocks, error) {
+	return &EpochBlocks{
+		networkParameters: networkParameters,
+		fetch: func(ctx context.Context, slot uint64) (*spec.VersionedSignedBeaconBlock, error) {
+			beaconBlock, err := consensus.SignedBeaconBlock(ctx, &api.SignedBeaconBlockOpts{
+				Block: strconv.FormatUint(slot, 10),
+			})
+			if err != nil {
+				return nil, err
+			}
+			return beaconBlock.Data, nil
+		},
+		epochs: make(map[uint64]map[uint64]*spec.VersionedSignedBeaconBlock),
+	}, nil
+}
+
+// Returns the blocks of the epoch by slot. Skipped or orphaned slots are not
+// in the map.
+func (e *EpochBlocks) GetEpochBlocks(ctx context.Context, epoch uint64) (map[uint64]*spec.VersionedSignedBeaconBlock, error) {
+	e.mu.Lock()
+	blocks, ok := e.epochs[epoch]
+	e.mu.Unlock()
+	if ok {
+		return blocks, nil
+	}
+
+	log.WithField("Epoch", epoch).Info("Fetching epoch blocks")
+	blocks = make(map[uint64]*spec.VersionedSignedBeaconBlock)
+	firstSlot := epoch * e.networkParameters.slotsInEpoch
+	for slot := firstSlot; slot < firstSlot+e.networkParameters.slotsInEpoch; slot++ {
+		block, err := e.fetch(ctx, slot)
+		if err != nil {
+			// This error is expected in skipped or orphaned blocks
+			if !strings.Contains(err.Error(), "NOT_FOUND") {
+				return nil, errors.Wrap(err, "error getting signed beacon block")
+			}
+			log.WithField("Slot", slot).Warn("block not found")
+			continue
+		}
+		blocks[slot] = block
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// Only the neighbouring epochs can be requested again
+	for cached := range e.epochs {
+		if cached+1 < epoch || cached > epoch+1 {
+			delete(e.epochs, cached)
+		}
+	}
+	e.epochs[epoch] = blocks
+	return blocks, nil
+}
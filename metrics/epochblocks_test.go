@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GetEpochBlocks(t *testing.T) {
+	fetched := make(map[uint64]int)
+	epochBlocks := &EpochBlocks{
+		networkParameters: &NetworkParameters{slotsInEpoch: 4},
+		fetch: func(ctx context.Context, slot uint64) (*spec.VersionedSignedBeaconBlock, error) {
+			fetched[slot]++
+			switch slot {
+			case 9:
+				return nil, errors.New("GET failed with status 404: {\"code\":404,\"message\":\"NOT_FOUND: beacon block\"}")
+			case 20:
+				return nil, errors.New("connection refused")
+			}
+			return &spec.VersionedSignedBeaconBlock{Version: spec.DataVersionElectra}, nil
+		},
+		epochs: make(map[uint64]map[uint64]*spec.VersionedSignedBeaconBlock),
+	}
+
+	// Skipped slots are not returned
+	blocks, err := epochBlocks.GetEpochBlocks(context.Background(), 2)
+	require.NoError(t, err)
+	require.Len(t, blocks, 3)
+	require.NotContains(t, blocks, uint64(9))
+
+	// Each block is fetched once, and the previous epoch is kept
+	_, err = epochBlocks.GetEpochBlocks(context.Background(), 3)
+	require.NoError(t, err)
+	blocks, err = epochBlocks.GetEpochBlocks(context.Background(), 2)
+	require.NoError(t, err)
+	require.Len(t, blocks, 3)
+	for slot := uint64(8); slot < 16; slot++ {
+		require.Equal(t, 1, fetched[slot])
+	}
+
+	// Older epochs are dropped
+	_, err = epochBlocks.GetEpochBlocks(context.Background(), 4)
+	require.NoError(t, err)
+	require.NotContains(t, epochBlocks.epochs, uint64(2))
+	require.Contains(t, epochBlocks.epochs, uint64(3))
+
+	// Other errors fail the epoch, which is not kept
+	_, err = epochBlocks.GetEpochBlocks(context.Background(), 5)
+	require.ErrorContains(t, err, "connection refused")
+	require.NotContains(t, epochBlocks.epochs, uint64(5))
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/bilinearlabs/eth-metrics/db"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Columns that depend on when the epoch is processed and not on the
+// computation, since the current price is used when no price was sampled
+var comparePriceColumns = []string{"f_eth_price_usd", "f_earned_usd", "f_mev_rewards_usd"}
+
+// A stored value that differs from the recomputed one, printed as a json line
+type epochDifference struct {
+	Epoch    uint64      `json:"epoch"`
+	Table    string      `json:"table"`
+	Row      string      `json:"row"`
+	Column   string      `json:"column"`
+	Stored   interface{} `json:"stored"`
+	Computed interface{} `json:"computed"`
+}
+
+// Processes the given range of epochs, both included, in dry-run mode. With
+// --compare-db the epochs are written to a temporary database instead, and
+// the values that differ from the ones stored in the given database are
+// printed, e.g. to validate a change to the computation.
+func (a *Metrics) DebugEpochs(fromEpoch uint64, toEpoch uint64) error {
+	var storedDb *db.Database
+	output := a.dryRunOutput
+	if a.config.CompareDatabasePath != "" {
+		if _, err := os.Stat(a.config.CompareDatabasePath); err != nil {
+			return errors.Wrap(err, "could not find database to compare with")
+		}
+		var err error
+		storedDb, err = db.NewWithOptions(a.config.CompareDatabasePath, db.OptionsFromConfig(a.config))
+		if err != nil {
+			return errors.Wrap(err, "could not open database to compare with")
+		}
+
+		dir, err := os.MkdirTemp("", "eth-metrics-debug")
+		if err != nil {
+			return errors.Wrap(err, "could not create temporary database")
+		}
+		defer os.RemoveAll(dir)
+		a.db, err = db.NewWithOptions(filepath.Join(dir, "debug.db"), db.OptionsFromConfig(a.config))
+		if err != nil {
+			return errors.Wrap(err, "could not create temporary database")
+		}
+		if err := a.db.CreateTables(); err != nil {
+			return errors.Wrap(err, "could not create temporary database tables")
+		}
+		// Only the differences are printed
+		a.dryRunOutput = io.Discard
+	}
+	if err := a.setup(); err != nil {
+		return err
+	}
+	defer a.clickHouse.Close()
+
+	encoder := json.NewEncoder(output)
+	var prevBeaconState *spec.VersionedBeaconState
+	for epoch := fromEpoch; epoch <= toEpoch; epoch++ {
+		log.WithField("Epoch", epoch).Warn("Debugging mode, calculating metrics")
+		currentBeaconState, _, err := a.processEpoch(context.Background(), epoch, prevBeaconState, nil)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("error processing epoch %d", epoch))
+		}
+		prevBeaconState = currentBeaconState
+
+		if storedDb == nil {
+			continue
+		}
+		differences, err := compareEpoch(storedDb, a.db, epoch)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("error comparing epoch %d", epoch))
+		}
+		for _, difference := range differences {
+			if err := encoder.Encode(difference); err != nil {
+				return err
+			}
+		}
+		log.WithField("Epoch", epoch).Info(len(differences), " values differ from the stored ones")
+	}
+	return nil
+}
+
+// Returns the values of the epoch that differ between the stored and the
+// computed rows, sorted by table, row and column
+func compareEpoch(storedDb *db.Database, computedDb *db.Database, epoch uint64) ([]epochDifference, error) {
+	stored, err := snapshotEpoch(storedDb, epoch, "")
+	if err != nil {
+		return nil, err
+	}
+	computed, err := snapshotEpoch(computedDb, epoch, "")
+	if err != nil {
+		return nil, err
+	}
+
+	differences := make([]epochDifference, 0)
+	for _, change := range diffSnapshots(stored, computed) {
+		if slices.Contains(comparePriceColumns, change.column) {
+			continue
+		}
+		differences = append(differences, epochDifference{
+			Epoch:    epoch,
+			Table:    change.table,
+			Row:      change.row,
+			Column:   change.column,
+			Stored:   change.old,
+			Computed: change.new,
+		})
+	}
+	return differences, nil
+}
@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/bilinearlabs/eth-metrics/db"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CompareEpoch(t *testing.T) {
+	newDb := func(name string) *db.Database {
+		database, err := db.New(filepath.Join(t.TempDir(), name))
+		require.NoError(t, err)
+		require.NoError(t, database.CreateTables())
+		return database
+	}
+	storedDb := newDb("stored.db")
+	computedDb := newDb("computed.db")
+
+	require.NoError(t, storedDb.StoreProposalDuties(100, "pool_a", 4, 3, 1, 0, 1))
+	require.NoError(t, computedDb.StoreProposalDuties(100, "pool_a", 4, 4, 0, 0, 1))
+	// Other epochs are not compared
+	require.NoError(t, storedDb.StoreProposalDuties(101, "pool_a", 4, 0, 4, 0, 0))
+
+	differences, err := compareEpoch(storedDb, computedDb, 100)
+	require.NoError(t, err)
+	require.Len(t, differences, 3)
+	for _, difference := range differences {
+		require.Equal(t, uint64(100), difference.Epoch)
+		require.Equal(t, "t_proposal_duties", difference.Table)
+	}
+	require.Equal(t, "f_n_missed_empty", differences[0].Column)
+	require.EqualValues(t, 1, differences[0].Stored)
+	require.EqualValues(t, 0, differences[0].Computed)
+
+	differences, err = compareEpoch(storedDb, storedDb, 100)
+	require.NoError(t, err)
+	require.Empty(t, differences)
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"sort"
+
+	"github.com/bilinearlabs/eth-metrics/schemas"
+)
+
+// Returns the blocks proposed by the pool that pay to an unexpected fee
+// recipient. In MEV blocks the fee recipient of the block is the builder, so
+// the recipient of the payout reported by the relay is checked instead. Blocks
+// whose fee recipient is not known are skipped.
+func GetFeeRecipientViolations(
+	epoch uint64,
+	poolName string,
+	proposed []schemas.Duty,
+	relayPayloads map[uint64]schemas.RelayPayload,
+	slotFeeRecipients map[uint64]string,
+	expectedFeeRecipients map[string]bool) []schemas.FeeRecipientViolation {
+
+	violations := make([]schemas.FeeRecipientViolation, 0)
+	if len(expectedFeeRecipients) == 0 {
+		return violations
+	}
+
+	expected := make([]string, 0, len(expectedFeeRecipients))
+	for address := range expectedFeeRecipients {
+		expected = append(expected, address)
+	}
+	sort.Strings(expected)
+
+	for _, duty := range proposed {
+		feeRecipient := ""
+		mev := false
+		if payload, ok := relayPayloads[duty.Slot]; ok {
+			feeRecipient = payload.ProposerFeeRecipient
+			mev = true
+		} else if recipient, ok := slotFeeRecipients[duty.Slot]; ok {
+			feeRecipient = recipient
+		}
+		if feeRecipient == "" || expectedFeeRecipients[feeRecipient] {
+			continue
+		}
+		violations = append(violations, schemas.FeeRecipientViolation{
+			Epoch:         epoch,
+			Slot:          duty.Slot,
+			ProposerIndex: duty.ValIndex,
+			PoolName:      poolName,
+			FeeRecipient:  feeRecipient,
+			Expected:      expected,
+			MEV:           mev,
+		})
+	}
+	return violations
+}
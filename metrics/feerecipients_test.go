@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GetFeeRecipientViolations(t *testing.T) {
+	proposed := []schemas.Duty{
+		{ValIndex: 1, Slot: 100},
+		{ValIndex: 2, Slot: 101},
+		{ValIndex: 3, Slot: 102},
+		{ValIndex: 4, Slot: 103},
+		// Unknown fee recipient
+		{ValIndex: 5, Slot: 104},
+	}
+	relayPayloads := map[uint64]schemas.RelayPayload{
+		100: {Slot: 100, ProposerFeeRecipient: "0xpool"},
+		101: {Slot: 101, ProposerFeeRecipient: "0xthief"},
+	}
+	slotFeeRecipients := map[uint64]string{
+		102: "0xother",
+		103: "0xthief",
+	}
+	expected := map[string]bool{"0xpool": true, "0xother": true}
+
+	violations := GetFeeRecipientViolations(10, "pool_a", proposed, relayPayloads, slotFeeRecipients, expected)
+	require.Equal(t, []schemas.FeeRecipientViolation{
+		{Epoch: 10, Slot: 101, ProposerIndex: 2, PoolName: "pool_a", FeeRecipient: "0xthief", Expected: []string{"0xother", "0xpool"}, MEV: true},
+		{Epoch: 10, Slot: 103, ProposerIndex: 4, PoolName: "pool_a", FeeRecipient: "0xthief", Expected: []string{"0xother", "0xpool"}, MEV: false},
+	}, violations)
+
+	// Nothing is expected for the pool
+	require.Empty(t, GetFeeRecipientViolations(10, "pool_b", proposed, relayPayloads, slotFeeRecipients, nil))
+}
@@ -0,0 +1,236 @@
+package metrics
+
+import (
+	"context"
+	"slices"
+	"strconv"
+
+	"github.com/attestantio/go-eth2-client/api"
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/http"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bilinearlabs/eth-metrics/forkdata"
+	"github.com/pkg/errors"
+	"github.com/prysmaticlabs/go-bitfield"
+	log "github.com/sirupsen/logrus"
+)
+
+// Attestation fields that are needed to know which validators attested,
+// regardless of the fork. Before Electra there is only one committee index
+// per attestation, after it the committees are set in the committee bits.
+type blockAttestation struct {
+	slot             uint64
+	targetEpoch      uint64
+	committeeIndexes []uint64
+	aggregationBits  bitfield.Bitlist
+	// Hash tree root of the attestation data, to tell apart conflicting votes
+	dataRoot phase0.Root
+}
+
+type InclusionDelay struct {
+	consensus         *http.Service
+	networkParameters *NetworkParameters
+}
+
+func NewInclusionDelay(
+	consensus *http.Service,
+	networkParameters *NetworkParameters,
+) (*InclusionDelay, error) {
+	return &InclusionDelay{
+		consensus:         consensus,
+		networkParameters: networkParameters,
+	}, nil
+}
+
+// Returns the inclusion delay in slots of the attestations of each validator
+// for epoch-1, which are the ones reflected in the participation flags of the
+// beacon state of the given epoch. Attestations can be included in the same
+// epoch or in the next one, so the blocks of both are scanned, by slot as
+// returned by EpochBlocks. Also returns the validators that signed
+// conflicting attestations for epoch-1.
+func (d *InclusionDelay) GetEpochInclusionDelays(epoch uint64, blocks map[uint64]*spec.VersionedSignedBeaconBlock) (map[uint64]uint64, []uint64, error) {
+	attestedEpoch := epoch - 1
+	log.WithField("Epoch", attestedEpoch).Info("Fetching inclusion delays for attestations")
+
+	committees, err := d.getCommittees(attestedEpoch)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error getting beacon committees")
+	}
+
+	blockAttestations := make(map[uint64][]blockAttestation)
+	firstSlot := attestedEpoch * d.networkParameters.slotsInEpoch
+	for slot := firstSlot; slot < firstSlot+2*d.networkParameters.slotsInEpoch; slot++ {
+		if block, ok := blocks[slot]; ok {
+			attestations, err := GetBlockAttestations(block)
+			if err != nil {
+				return nil, nil, errors.Wrap(err, "error getting block attestations")
+			}
+			blockAttestations[slot] = attestations
+		}
+	}
+
+	return GetInclusionDelays(attestedEpoch, blockAttestations, committees),
+		GetConflictingVotes(attestedEpoch, blockAttestations, committees), nil
+}
+
+func (d *InclusionDelay) getCommittees(epoch uint64) (map[uint64]map[uint64][]uint64, error) {
+	phase0Epoch := phase0.Epoch(epoch)
+	// State at the last slot of the epoch
+	stateSlot := strconv.FormatUint((epoch+1)*d.networkParameters.slotsInEpoch-1, 10)
+	resp, err := d.consensus.BeaconCommittees(context.Background(), &api.BeaconCommitteesOpts{
+		State: stateSlot,
+		Epoch: &phase0Epoch,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return CommitteesBySlot(resp.Data), nil
+}
+
+// Indexes the committees by slot and committee index
+func CommitteesBySlot(beaconCommittees []*v1.BeaconCommittee) map[uint64]map[uint64][]uint64 {
+	committees := make(map[uint64]map[uint64][]uint64)
+	for _, committee := range beaconCommittees {
+		slot := uint64(committee.Slot)
+		if _, ok := committees[slot]; !ok {
+			committees[slot] = make(map[uint64][]uint64)
+		}
+		validators := make([]uint64, len(committee.Validators))
+		for i, valIdx := range committee.Validators {
+			validators[i] = uint64(valIdx)
+		}
+		committees[slot][uint64(committee.Index)] = validators
+	}
+	return committees
+}
+
+// Returns the minimum inclusion delay of every validator that attested in the
+// given epoch. The delay is the distance between the slot of the block that
+// included the attestation and the slot being attested.
+func GetInclusionDelays(
+	attestedEpoch uint64,
+	blockAttestations map[uint64][]blockAttestation,
+	committees map[uint64]map[uint64][]uint64) map[uint64]uint64 {
+
+	inclusionDelays := make(map[uint64]uint64)
+
+	for blockSlot, attestations := range blockAttestations {
+		for _, att := range attestations {
+			if att.targetEpoch != attestedEpoch || blockSlot <= att.slot {
+				continue
+			}
+			delay := blockSlot - att.slot
+
+			for _, valIdx := range getAttestingIndexes(att, committees) {
+				if prevDelay, ok := inclusionDelays[valIdx]; !ok || delay < prevDelay {
+					inclusionDelays[valIdx] = delay
+				}
+			}
+		}
+	}
+	return inclusionDelays
+}
+
+// Returns the validators included in attestations with different data for the
+// given epoch, i.e. double votes, which happen when the same key is validating
+// in more than one machine. The same vote can be included more than once.
+func GetConflictingVotes(
+	attestedEpoch uint64,
+	blockAttestations map[uint64][]blockAttestation,
+	committees map[uint64]map[uint64][]uint64) []uint64 {
+
+	votes := make(map[uint64]phase0.Root)
+	conflicting := make(map[uint64]bool)
+	for _, attestations := range blockAttestations {
+		for _, att := range attestations {
+			if att.targetEpoch != attestedEpoch {
+				continue
+			}
+			for _, valIdx := range getAttestingIndexes(att, committees) {
+				if vote, ok := votes[valIdx]; ok && vote != att.dataRoot {
+					conflicting[valIdx] = true
+				}
+				votes[valIdx] = att.dataRoot
+			}
+		}
+	}
+
+	conflictingIndexes := make([]uint64, 0, len(conflicting))
+	for valIdx := range conflicting {
+		conflictingIndexes = append(conflictingIndexes, valIdx)
+	}
+	slices.Sort(conflictingIndexes)
+	return conflictingIndexes
+}
+
+// Returns the validators whose bit is set in the aggregation bits of the
+// attestation, which span all its committees one after the other
+func getAttestingIndexes(att blockAttestation, committees map[uint64]map[uint64][]uint64) []uint64 {
+	indexes := make([]uint64, 0)
+	offset := uint64(0)
+	for _, committeeIndex := range att.committeeIndexes {
+		committee, ok := committees[att.slot][committeeIndex]
+		if !ok {
+			log.WithField("Slot", att.slot).Warn("committee ", committeeIndex, " not found")
+			break
+		}
+		for i, valIdx := range committee {
+			if att.aggregationBits.BitAt(offset + uint64(i)) {
+				indexes = append(indexes, valIdx)
+			}
+		}
+		offset += uint64(len(committee))
+	}
+	return indexes
+}
+
+// Returns the average and max inclusion delay of the given validators. Validators
+// without any included attestation are not taken into account.
+func GetAvgAndMaxInclusionDelay(
+	validatorIndexes []uint64,
+	inclusionDelays map[uint64]uint64) (float64, uint64) {
+
+	var total, max, count uint64
+	for _, valIdx := range validatorIndexes {
+		delay, ok := inclusionDelays[valIdx]
+		if !ok {
+			continue
+		}
+		total += delay
+		count++
+		if delay > max {
+			max = delay
+		}
+	}
+	if count == 0 {
+		return 0, 0
+	}
+	return float64(total) / float64(count), max
+}
+
+func GetBlockAttestations(beaconBlock *spec.VersionedSignedBeaconBlock) ([]blockAttestation, error) {
+	blockAtts, err := forkdata.Attestations(beaconBlock)
+	if err != nil {
+		return nil, err
+	}
+	attestations := make([]blockAttestation, 0, len(blockAtts))
+	for _, att := range blockAtts {
+		attestations = append(attestations, blockAttestation{
+			slot:             uint64(att.Data.Slot),
+			targetEpoch:      uint64(att.Data.Target.Epoch),
+			committeeIndexes: att.CommitteeIndexes,
+			aggregationBits:  att.AggregationBits,
+			dataRoot:         attestationDataRoot(att.Data),
+		})
+	}
+	return attestations, nil
+}
+
+func attestationDataRoot(data *phase0.AttestationData) phase0.Root {
+	root, err := data.HashTreeRoot()
+	if err != nil {
+		log.Warn("could not hash attestation data: ", err)
+	}
+	return root
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"testing"
+
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/electra"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/prysmaticlabs/go-bitfield"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GetInclusionDelays(t *testing.T) {
+	committees := CommitteesBySlot([]*v1.BeaconCommittee{
+		{Slot: 32, Index: 0, Validators: []phase0.ValidatorIndex{10, 11, 12}},
+		{Slot: 32, Index: 1, Validators: []phase0.ValidatorIndex{20, 21}},
+		{Slot: 33, Index: 0, Validators: []phase0.ValidatorIndex{30, 31}},
+	})
+
+	// Bits: 10, 12 (committee 0) and 21 (committee 1)
+	bitsSlot32 := bitfield.NewBitlist(5)
+	bitsSlot32.SetBitAt(0, true)
+	bitsSlot32.SetBitAt(2, true)
+	bitsSlot32.SetBitAt(4, true)
+
+	// Same validator 10 included again later, min delay is kept
+	bitsSlot32Late := bitfield.NewBitlist(3)
+	bitsSlot32Late.SetBitAt(0, true)
+	bitsSlot32Late.SetBitAt(1, true)
+
+	bitsSlot33 := bitfield.NewBitlist(2)
+	bitsSlot33.SetBitAt(1, true)
+
+	blockAttestations := map[uint64][]blockAttestation{
+		33: {
+			{slot: 32, targetEpoch: 1, committeeIndexes: []uint64{0, 1}, aggregationBits: bitsSlot32},
+		},
+		36: {
+			{slot: 32, targetEpoch: 1, committeeIndexes: []uint64{0}, aggregationBits: bitsSlot32Late},
+			{slot: 33, targetEpoch: 1, committeeIndexes: []uint64{0}, aggregationBits: bitsSlot33},
+			// Attestation for another epoch is ignored
+			{slot: 5, targetEpoch: 0, committeeIndexes: []uint64{0}, aggregationBits: bitsSlot33},
+		},
+	}
+
+	delays := GetInclusionDelays(1, blockAttestations, committees)
+	require.Equal(t, map[uint64]uint64{
+		10: 1,
+		11: 4,
+		12: 1,
+		21: 1,
+		31: 3,
+	}, delays)
+
+	avg, max := GetAvgAndMaxInclusionDelay([]uint64{10, 11, 31, 99}, delays)
+	require.Equal(t, float64(8)/3, avg)
+	require.Equal(t, uint64(4), max)
+
+	avg, max = GetAvgAndMaxInclusionDelay([]uint64{99}, delays)
+	require.Equal(t, float64(0), avg)
+	require.Equal(t, uint64(0), max)
+}
+
+func Test_GetConflictingVotes(t *testing.T) {
+	committees := CommitteesBySlot([]*v1.BeaconCommittee{
+		{Slot: 32, Index: 0, Validators: []phase0.ValidatorIndex{10, 11, 12}},
+	})
+
+	// Validators 10 and 11 vote for a head, 11 and 12 for another one
+	bitsHeadA := bitfield.NewBitlist(3)
+	bitsHeadA.SetBitAt(0, true)
+	bitsHeadA.SetBitAt(1, true)
+	bitsHeadB := bitfield.NewBitlist(3)
+	bitsHeadB.SetBitAt(1, true)
+	bitsHeadB.SetBitAt(2, true)
+
+	blockAttestations := map[uint64][]blockAttestation{
+		33: {
+			{slot: 32, targetEpoch: 1, committeeIndexes: []uint64{0}, aggregationBits: bitsHeadA, dataRoot: phase0.Root{1}},
+		},
+		34: {
+			// The same vote included again is not a conflict
+			{slot: 32, targetEpoch: 1, committeeIndexes: []uint64{0}, aggregationBits: bitsHeadA, dataRoot: phase0.Root{1}},
+			{slot: 32, targetEpoch: 1, committeeIndexes: []uint64{0}, aggregationBits: bitsHeadB, dataRoot: phase0.Root{2}},
+		},
+	}
+
+	require.Equal(t, []uint64{11}, GetConflictingVotes(1, blockAttestations, committees))
+	require.Equal(t, []uint64{}, GetConflictingVotes(2, blockAttestations, committees))
+}
+
+func Test_GetBlockAttestations_Electra(t *testing.T) {
+	committeeBits := bitfield.NewBitvector64()
+	committeeBits.SetBitAt(2, true)
+	committeeBits.SetBitAt(5, true)
+
+	block := &spec.VersionedSignedBeaconBlock{
+		Electra: &electra.SignedBeaconBlock{
+			Message: &electra.BeaconBlock{
+				Body: &electra.BeaconBlockBody{
+					Attestations: []*electra.Attestation{
+						{
+							AggregationBits: bitfield.NewBitlist(4),
+							CommitteeBits:   committeeBits,
+							Data: &phase0.AttestationData{
+								Slot:   64,
+								Target: &phase0.Checkpoint{Epoch: 2},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	attestations, err := GetBlockAttestations(block)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(attestations))
+	require.Equal(t, uint64(64), attestations[0].slot)
+	require.Equal(t, uint64(2), attestations[0].targetEpoch)
+	require.Equal(t, []uint64{2, 5}, attestations[0].committeeIndexes)
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"encoding/hex"
+	"slices"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Returns the tracked keys that are not in the beacon state and the ones that
+// exited by the epoch, which are not counted as validating, sorted by pool
+func GetKeyIssues(
+	epoch uint64,
+	poolKeys map[string][][]byte,
+	valKeyToIndex map[string]uint64,
+	validators []*phase0.Validator) []schemas.KeyIssue {
+
+	poolNames := make([]string, 0, len(poolKeys))
+	for poolName := range poolKeys {
+		poolNames = append(poolNames, poolName)
+	}
+	slices.Sort(poolNames)
+
+	issues := make([]schemas.KeyIssue, 0)
+	for _, poolName := range poolNames {
+		for _, key := range poolKeys[poolName] {
+			issue := schemas.KeyIssue{Epoch: epoch, PoolName: poolName, PubKey: hexutil.Encode(key)}
+			valIdx, ok := valKeyToIndex[hex.EncodeToString(key)]
+			if !ok || valIdx >= uint64(len(validators)) || validators[valIdx] == nil {
+				issue.Kind = schemas.KeyNotFound
+				issues = append(issues, issue)
+				continue
+			}
+			if uint64(validators[valIdx].ExitEpoch) <= epoch {
+				issue.Kind = schemas.KeyExited
+				issue.ValIndex = &valIdx
+				issues = append(issues, issue)
+			}
+		}
+	}
+	return issues
+}
+
+// Reports the tracked keys that are not found or exited, in the first epoch
+// that is processed and then every --key-check-epochs
+func (a *Metrics) checkTrackedKeys(
+	epoch uint64,
+	poolKeys map[string][][]byte,
+	valKeyToIndex map[string]uint64,
+	validators []*phase0.Validator) error {
+
+	if a.config.KeyCheckEpochs == 0 || (a.keysChecked && epoch < a.lastKeyCheck+a.config.KeyCheckEpochs) {
+		return nil
+	}
+
+	issues := GetKeyIssues(epoch, poolKeys, valKeyToIndex, validators)
+	for _, issue := range issues {
+		log.WithFields(log.Fields{
+			"PoolName": issue.PoolName,
+			"Issue":    issue.Kind,
+		}).Warn("Tracked key not counted as validating: ", issue.PubKey)
+	}
+	log.WithField("Epoch", epoch).Info("Checked the tracked keys, keys not found or exited: ", len(issues))
+
+	if a.db != nil {
+		if err := a.db.StoreKeyIssues(issues); err != nil {
+			return errors.Wrap(err, "could not store key issues")
+		}
+	}
+	a.keysChecked = true
+	a.lastKeyCheck = epoch
+	return nil
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/bilinearlabs/eth-metrics/db"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GetKeyIssues(t *testing.T) {
+	key := func(b byte) []byte {
+		k := make([]byte, 48)
+		k[0] = b
+		return k
+	}
+	poolKeys := map[string][][]byte{
+		"pool_b": {key(3)},
+		"pool_a": {key(1), key(2), key(4)},
+	}
+	valKeyToIndex := map[string]uint64{
+		"01" + strings.Repeat("00", 47): 0,
+		"02" + strings.Repeat("00", 47): 1,
+		"03" + strings.Repeat("00", 47): 2,
+	}
+	validators := []*phase0.Validator{
+		{ExitEpoch: phase0.Epoch(^uint64(0))},
+		// Exited in the epoch
+		{ExitEpoch: 100},
+		// Exiting
+		{ExitEpoch: 101},
+	}
+
+	exitedIndex := uint64(1)
+	require.Equal(t, []schemas.KeyIssue{
+		{Epoch: 100, PoolName: "pool_a", PubKey: "0x02" + strings.Repeat("00", 47), Kind: schemas.KeyExited, ValIndex: &exitedIndex},
+		{Epoch: 100, PoolName: "pool_a", PubKey: "0x04" + strings.Repeat("00", 47), Kind: schemas.KeyNotFound},
+	}, GetKeyIssues(100, poolKeys, valKeyToIndex, validators))
+}
+
+func Test_CheckTrackedKeys(t *testing.T) {
+	database, err := db.New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.CreateTables())
+
+	m := &Metrics{db: database, config: &config.Config{KeyCheckEpochs: 10}}
+	key := make([]byte, 48)
+	poolKeys := map[string][][]byte{"pool_a": {key}}
+
+	// Checked in the first epoch, and again after 10 epochs
+	require.NoError(t, m.checkTrackedKeys(100, poolKeys, map[string]uint64{}, nil))
+	issues, err := database.GetKeyIssues()
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+
+	valKeyToIndex := map[string]uint64{strings.Repeat("00", 48): 0}
+	validators := []*phase0.Validator{{ExitEpoch: phase0.Epoch(^uint64(0))}}
+	require.NoError(t, m.checkTrackedKeys(109, poolKeys, valKeyToIndex, validators))
+	issues, err = database.GetKeyIssues()
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+
+	require.NoError(t, m.checkTrackedKeys(110, poolKeys, valKeyToIndex, validators))
+	issues, err = database.GetKeyIssues()
+	require.NoError(t, err)
+	require.Empty(t, issues)
+}
@@ -0,0 +1,292 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/api"
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/altair"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/deneb"
+	"github.com/attestantio/go-eth2-client/spec/electra"
+	"github.com/attestantio/go-eth2-client/spec/fulu"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Builds a beacon state that only contains the given validators, using the
+// validators and attestation rewards endpoints instead of downloading the full
+// state. Only the fields used by the pool metrics are populated, so it can't be
+// used for the network stats.
+func (p *BeaconState) GetLightBeaconState(ctx context.Context, epoch uint64, validatorKeys [][]byte) (*spec.VersionedBeaconState, error) {
+	log.WithField("Epoch", epoch).Info("Fetching light beacon state")
+	slot := (epoch+1)*p.networkParameters.slotsInEpoch - 1
+	slotStr := strconv.FormatUint(slot, 10)
+	fork := p.networkParameters.forkAt(epoch)
+	// Participation is taken from the flags, which exist since altair
+	if fork == spec.DataVersionPhase0 {
+		return nil, errors.New("phase0 beacon states are not supported")
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, time.Second*time.Duration(p.config.StateTimeout))
+	defer cancel()
+	common := api.CommonOpts{
+		Timeout: time.Second * time.Duration(p.config.StateTimeout),
+	}
+
+	pubKeys := make([]phase0.BLSPubKey, 0, len(validatorKeys))
+	for _, key := range validatorKeys {
+		var pubKey phase0.BLSPubKey
+		copy(pubKey[:], key)
+		pubKeys = append(pubKeys, pubKey)
+	}
+	// Without keys the endpoint would return all validators from the full state
+	if len(pubKeys) == 0 {
+		return nil, errors.New("no validator keys to fetch")
+	}
+
+	validators, err := p.consensus.Validators(ctxTimeout, &api.ValidatorsOpts{
+		State:   slotStr,
+		PubKeys: pubKeys,
+		Common:  common,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting validators")
+	}
+
+	// The queues exist since electra, and are empty before
+	pendingConsolidations := []*electra.PendingConsolidation{}
+	pendingDeposits := []*electra.PendingDeposit{}
+	pendingPartialWithdrawals := []*electra.PendingPartialWithdrawal{}
+	if fork >= spec.DataVersionElectra {
+		consolidations, err := p.consensus.PendingConsolidations(ctxTimeout, &api.PendingConsolidationsOpts{
+			State:  slotStr,
+			Common: common,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting pending consolidations")
+		}
+		pendingConsolidations = consolidations.Data
+
+		// Needed to discount deposits from the balance deltas
+		deposits, err := p.consensus.PendingDeposits(ctxTimeout, &api.PendingDepositsOpts{
+			State:  slotStr,
+			Common: common,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting pending deposits")
+		}
+		pendingDeposits = deposits.Data
+
+		// Needed to follow the partial withdrawals requested by the validators
+		partialWithdrawals, err := p.consensus.PendingPartialWithdrawals(ctxTimeout, &api.PendingPartialWithdrawalsOpts{
+			State:  slotStr,
+			Common: common,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting pending partial withdrawals")
+		}
+		pendingPartialWithdrawals = partialWithdrawals.Data
+	}
+
+	// Consolidation sources are needed to discount their balance from the target
+	sourceIndexes := make([]phase0.ValidatorIndex, 0)
+	for _, consolidation := range pendingConsolidations {
+		_, trackedTarget := validators.Data[consolidation.TargetIndex]
+		_, knownSource := validators.Data[consolidation.SourceIndex]
+		if trackedTarget && !knownSource {
+			sourceIndexes = append(sourceIndexes, consolidation.SourceIndex)
+		}
+	}
+	if len(sourceIndexes) > 0 {
+		sources, err := p.consensus.Validators(ctxTimeout, &api.ValidatorsOpts{
+			State:   slotStr,
+			Indices: sourceIndexes,
+			Common:  common,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting consolidation source validators")
+		}
+		for index, validator := range sources.Data {
+			validators.Data[index] = validator
+		}
+	}
+
+	trackedIndexes := make([]phase0.ValidatorIndex, 0, len(validators.Data))
+	for index := range validators.Data {
+		trackedIndexes = append(trackedIndexes, index)
+	}
+
+	// Participation of the previous epoch, as in the full beacon state
+	var attestationRewards []v1.ValidatorAttestationRewards
+	if epoch > 0 && len(trackedIndexes) > 0 {
+		rewards, err := p.consensus.AttestationRewards(ctxTimeout, &api.AttestationRewardsOpts{
+			Epoch:   phase0.Epoch(epoch - 1),
+			Indices: trackedIndexes,
+			Common:  common,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting attestation rewards")
+		}
+		attestationRewards = rewards.Data.TotalRewards
+	}
+
+	syncCommittee, err := p.consensus.SyncCommittee(ctxTimeout, &api.SyncCommitteeOpts{
+		State:  slotStr,
+		Common: common,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting sync committee")
+	}
+
+	timestamp := p.networkParameters.slotTime(slot)
+	return BuildLightBeaconState(
+		fork,
+		slot,
+		timestamp,
+		validators.Data,
+		attestationRewards,
+		syncCommittee.Data.Validators,
+		pendingConsolidations,
+		pendingDeposits,
+		pendingPartialWithdrawals)
+}
+
+// Creates a beacon state of the given fork where only the given validators are
+// set. The rest of the registry is filled with empty inactive validators so
+// that the indexes match the ones of the full state. The queues are only set
+// since electra, which has them.
+func BuildLightBeaconState(
+	fork spec.DataVersion,
+	slot uint64,
+	timestamp uint64,
+	validators map[phase0.ValidatorIndex]*v1.Validator,
+	attestationRewards []v1.ValidatorAttestationRewards,
+	syncCommittee []phase0.ValidatorIndex,
+	pendingConsolidations []*electra.PendingConsolidation,
+	pendingDeposits []*electra.PendingDeposit,
+	pendingPartialWithdrawals []*electra.PendingPartialWithdrawal) (*spec.VersionedBeaconState, error) {
+
+	nOfValidators := uint64(0)
+	for index := range validators {
+		if uint64(index)+1 > nOfValidators {
+			nOfValidators = uint64(index) + 1
+		}
+	}
+
+	emptyValidator := &phase0.Validator{}
+	stateValidators := make([]*phase0.Validator, nOfValidators)
+	balances := make([]phase0.Gwei, nOfValidators)
+	participation := make([]altair.ParticipationFlags, nOfValidators)
+	for i := range stateValidators {
+		stateValidators[i] = emptyValidator
+	}
+	for index, validator := range validators {
+		stateValidators[index] = validator.Validator
+		balances[index] = validator.Balance
+	}
+	for _, reward := range attestationRewards {
+		if uint64(reward.ValidatorIndex) < nOfValidators {
+			participation[reward.ValidatorIndex] = ParticipationFromRewards(reward)
+		}
+	}
+
+	// Keys of untracked sync committee members are unknown and left empty
+	syncCommitteeKeys := make([]phase0.BLSPubKey, len(syncCommittee))
+	for i, index := range syncCommittee {
+		if validator, ok := validators[index]; ok {
+			syncCommitteeKeys[i] = validator.Validator.PublicKey
+		}
+	}
+
+	state := &spec.VersionedBeaconState{Version: fork}
+	syncCommitteeState := &altair.SyncCommittee{Pubkeys: syncCommitteeKeys}
+	switch fork {
+	case spec.DataVersionAltair:
+		state.Altair = &altair.BeaconState{
+			Slot:                       phase0.Slot(slot),
+			Validators:                 stateValidators,
+			Balances:                   balances,
+			PreviousEpochParticipation: participation,
+			CurrentSyncCommittee:       syncCommitteeState,
+		}
+	case spec.DataVersionBellatrix:
+		state.Bellatrix = &bellatrix.BeaconState{
+			Slot:                         phase0.Slot(slot),
+			Validators:                   stateValidators,
+			Balances:                     balances,
+			PreviousEpochParticipation:   participation,
+			CurrentSyncCommittee:         syncCommitteeState,
+			LatestExecutionPayloadHeader: &bellatrix.ExecutionPayloadHeader{Timestamp: timestamp},
+		}
+	case spec.DataVersionCapella:
+		state.Capella = &capella.BeaconState{
+			Slot:                         phase0.Slot(slot),
+			Validators:                   stateValidators,
+			Balances:                     balances,
+			PreviousEpochParticipation:   participation,
+			CurrentSyncCommittee:         syncCommitteeState,
+			LatestExecutionPayloadHeader: &capella.ExecutionPayloadHeader{Timestamp: timestamp},
+		}
+	case spec.DataVersionDeneb:
+		state.Deneb = &deneb.BeaconState{
+			Slot:                         phase0.Slot(slot),
+			Validators:                   stateValidators,
+			Balances:                     balances,
+			PreviousEpochParticipation:   participation,
+			CurrentSyncCommittee:         syncCommitteeState,
+			LatestExecutionPayloadHeader: &deneb.ExecutionPayloadHeader{Timestamp: timestamp},
+		}
+	case spec.DataVersionElectra:
+		state.Electra = &electra.BeaconState{
+			Slot:                         phase0.Slot(slot),
+			Validators:                   stateValidators,
+			Balances:                     balances,
+			PreviousEpochParticipation:   participation,
+			CurrentSyncCommittee:         syncCommitteeState,
+			LatestExecutionPayloadHeader: &deneb.ExecutionPayloadHeader{Timestamp: timestamp},
+			PendingConsolidations:        pendingConsolidations,
+			PendingDeposits:              pendingDeposits,
+			PendingPartialWithdrawals:    pendingPartialWithdrawals,
+		}
+	case spec.DataVersionFulu:
+		state.Fulu = &fulu.BeaconState{
+			Slot:                         phase0.Slot(slot),
+			Validators:                   stateValidators,
+			Balances:                     balances,
+			PreviousEpochParticipation:   participation,
+			CurrentSyncCommittee:         syncCommitteeState,
+			LatestExecutionPayloadHeader: &deneb.ExecutionPayloadHeader{Timestamp: timestamp},
+			PendingConsolidations:        pendingConsolidations,
+			PendingDeposits:              pendingDeposits,
+			PendingPartialWithdrawals:    pendingPartialWithdrawals,
+		}
+	default:
+		return nil, errors.Errorf("%s beacon states are not supported", fork)
+	}
+	return state, nil
+}
+
+// Converts the attestation rewards of a validator into participation flags.
+// Missing the source or target is penalized, so a non negative reward means
+// it was correct. Missing the head is not penalized, so only a positive reward
+// means it was correct. Note that during an inactivity leak there are no
+// rewards, so head votes can't be told apart.
+func ParticipationFromRewards(reward v1.ValidatorAttestationRewards) altair.ParticipationFlags {
+	var flags altair.ParticipationFlags
+	if reward.Source >= 0 {
+		flags |= 1 << 0
+	}
+	if reward.Target >= 0 {
+		flags |= 1 << 1
+	}
+	if reward.Head > 0 {
+		flags |= 1 << 2
+	}
+	return flags
+}
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"encoding/hex"
+	"testing"
+
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/altair"
+	"github.com/attestantio/go-eth2-client/spec/electra"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParticipationFromRewards(t *testing.T) {
+	require.Equal(t, altair.ParticipationFlags(7), ParticipationFromRewards(v1.ValidatorAttestationRewards{
+		Head: 100, Target: 200, Source: 100,
+	}))
+	// Inactivity leak, correct source and target but no rewards
+	require.Equal(t, altair.ParticipationFlags(3), ParticipationFromRewards(v1.ValidatorAttestationRewards{
+		Head: 0, Target: 0, Source: 0,
+	}))
+	require.Equal(t, altair.ParticipationFlags(1), ParticipationFromRewards(v1.ValidatorAttestationRewards{
+		Head: 0, Target: -200, Source: 100,
+	}))
+	require.Equal(t, altair.ParticipationFlags(0), ParticipationFromRewards(v1.ValidatorAttestationRewards{
+		Head: 0, Target: -200, Source: -100,
+	}))
+}
+
+func Test_BuildLightBeaconState(t *testing.T) {
+	validators := map[phase0.ValidatorIndex]*v1.Validator{
+		2: {
+			Index:   2,
+			Balance: 32000000001,
+			Validator: &phase0.Validator{
+				PublicKey:        phase0.BLSPubKey{0x02},
+				EffectiveBalance: 32000000000,
+				ExitEpoch:        100,
+			},
+		},
+		5: {
+			Index:   5,
+			Balance: 31000000000,
+			Validator: &phase0.Validator{
+				PublicKey:        phase0.BLSPubKey{0x05},
+				EffectiveBalance: 31000000000,
+				ExitEpoch:        100,
+			},
+		},
+	}
+	rewards := []v1.ValidatorAttestationRewards{
+		{ValidatorIndex: 2, Head: 10, Target: 10, Source: 10},
+		{ValidatorIndex: 5, Head: 0, Target: -10, Source: -10},
+	}
+	consolidations := []*electra.PendingConsolidation{{SourceIndex: 5, TargetIndex: 2}}
+	deposits := []*electra.PendingDeposit{{Pubkey: phase0.BLSPubKey{0x05}, Amount: 1000000000}}
+	partialWithdrawals := []*electra.PendingPartialWithdrawal{{ValidatorIndex: 2, Amount: 1000000000, WithdrawableEpoch: 100}}
+
+	state, err := BuildLightBeaconState(spec.DataVersionElectra, 95, 1000, validators, rewards, []phase0.ValidatorIndex{5, 7}, consolidations, deposits, partialWithdrawals)
+	require.NoError(t, err)
+	require.Equal(t, spec.DataVersionElectra, state.Version)
+
+	require.Equal(t, uint64(95), GetSlot(state))
+	require.Equal(t, uint64(1000), GetTimestamp(state))
+	require.Equal(t, []uint64{0, 0, 32000000001, 0, 0, 31000000000}, GetBalances(state))
+	require.Equal(t, []altair.ParticipationFlags{0, 0, 7, 0, 0, 0}, GetPreviousEpochParticipation(state))
+	require.Equal(t, []phase0.BLSPubKey{{0x05}, {}}, GetCurrentSyncCommittee(state))
+	require.Equal(t, consolidations, GetPendingConsolidations(state))
+	require.Equal(t, deposits, GetPendingDeposits(state))
+	require.Equal(t, partialWithdrawals, GetPendingPartialWithdrawals(state))
+
+	// Untracked validators are never active
+	bs := &BeaconState{networkParameters: &NetworkParameters{slotsInEpoch: 32}}
+	require.Equal(t, []uint64{2, 5}, bs.GetActiveIndexes([]uint64{0, 1, 2, 5}, state))
+
+	pubKey := phase0.BLSPubKey{0x05}
+	valKeyToIndex := PopulateKeysToIndexesMap(state)
+	require.Equal(t, uint64(5), valKeyToIndex[hex.EncodeToString(pubKey[:])])
+
+	// States of the forks before electra have no queues
+	state, err = BuildLightBeaconState(spec.DataVersionDeneb, 95, 1000, validators, rewards, []phase0.ValidatorIndex{5, 7}, nil, nil, nil)
+	require.NoError(t, err)
+	require.NotNil(t, state.Deneb)
+	require.Equal(t, uint64(1000), GetTimestamp(state))
+	require.Equal(t, []uint64{0, 0, 32000000001, 0, 0, 31000000000}, GetBalances(state))
+	require.Empty(t, GetPendingConsolidations(state))
+
+	_, err = BuildLightBeaconState(spec.DataVersionPhase0, 95, 1000, validators, rewards, nil, nil, nil, nil)
+	require.Error(t, err)
+}
+
+func Test_ForkAt(t *testing.T) {
+	forkEpochs, err := SpecForkEpochs(map[string]any{
+		"ALTAIR_FORK_EPOCH":  uint64(10),
+		"DENEB_FORK_EPOCH":   uint64(30),
+		"ELECTRA_FORK_EPOCH": uint64(40),
+		// Not scheduled
+		"FULU_FORK_EPOCH": uint64(18446744073709551615),
+	})
+	require.NoError(t, err)
+	networkParameters := &NetworkParameters{slotsInEpoch: 32, forkEpochs: forkEpochs}
+
+	require.Equal(t, spec.DataVersionPhase0, networkParameters.forkAt(9))
+	require.Equal(t, spec.DataVersionAltair, networkParameters.forkAt(10))
+	require.Equal(t, spec.DataVersionDeneb, networkParameters.forkAt(39))
+	require.Equal(t, spec.DataVersionElectra, networkParameters.forkAt(40))
+	require.Equal(t, spec.DataVersionElectra, networkParameters.forkAt(1000000))
+
+	_, err = SpecForkEpochs(map[string]any{"ELECTRA_FORK_EPOCH": "soon"})
+	require.Error(t, err)
+}
@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"strings"
+	"time"
+
+	"github.com/bilinearlabs/eth-metrics/alerts"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/pkg/errors"
+)
+
+// Alerts of the duties that a pool in maintenance is expected to miss. The
+// rest, e.g. a slashing, are sent anyway.
+var maintenanceAlertKinds = map[alerts.AlertKind]bool{
+	alerts.MissedProposal:     true,
+	alerts.MissedAttestations: true,
+}
+
+// Parses the windows of the config, as pool:start/end with the times in RFC
+// 3339, e.g. pool_a:2024-05-14T09:00:00Z/2024-05-14T11:00:00Z
+func ParseMaintenanceWindows(values []string) ([]schemas.MaintenanceWindow, error) {
+	windows := make([]schemas.MaintenanceWindow, 0, len(values))
+	for _, value := range values {
+		poolName, period, found := strings.Cut(value, ":")
+		startValue, endValue, foundEnd := strings.Cut(period, "/")
+		if !found || !foundEnd {
+			return nil, errors.New("invalid maintenance window, expected pool:start/end: " + value)
+		}
+		start, err := time.Parse(time.RFC3339, startValue)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid start of maintenance window "+value)
+		}
+		end, err := time.Parse(time.RFC3339, endValue)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid end of maintenance window "+value)
+		}
+		window := schemas.MaintenanceWindow{PoolName: poolName, Start: start.UTC(), End: end.UTC()}
+		if err := validateMaintenanceWindow(window); err != nil {
+			return nil, err
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+func validateMaintenanceWindow(window schemas.MaintenanceWindow) error {
+	if window.PoolName == "" {
+		return errors.New("pool name can't be empty, use * for all pools")
+	}
+	if !window.End.After(window.Start) {
+		return errors.New("maintenance window must end after it starts")
+	}
+	return nil
+}
+
+// Returns if a window of the pool, or of all pools with *, overlaps the period
+// from start to end, the latter excluded
+func InMaintenance(windows []schemas.MaintenanceWindow, poolName string, start time.Time, end time.Time) bool {
+	for _, window := range windows {
+		if (window.PoolName == poolName || window.PoolName == "*") &&
+			window.Start.Before(end) && window.End.After(start) {
+			return true
+		}
+	}
+	return false
+}
+
+// Loads the windows of the config and the ones stored with the admin api
+func (a *Metrics) loadMaintenanceWindows() error {
+	windows, err := ParseMaintenanceWindows(a.config.MaintenanceWindows)
+	if err != nil {
+		return err
+	}
+	if a.db != nil {
+		stored, err := a.db.GetMaintenanceWindows()
+		if err != nil {
+			return err
+		}
+		windows = append(windows, stored...)
+	}
+	a.maintenanceWindows = windows
+	return nil
+}
+
+// Returns the windows of the config and the added ones, including the past
+// ones, since epochs in them may be processed again
+func (a *Metrics) MaintenanceWindows() []schemas.MaintenanceWindow {
+	a.maintenanceMu.Lock()
+	defer a.maintenanceMu.Unlock()
+	return append([]schemas.MaintenanceWindow{}, a.maintenanceWindows...)
+}
+
+// Adds a window with the next id, stored so that it is kept after a restart
+func (a *Metrics) AddMaintenanceWindow(window schemas.MaintenanceWindow) (schemas.MaintenanceWindow, error) {
+	window.Start = window.Start.UTC()
+	window.End = window.End.UTC()
+	if err := validateMaintenanceWindow(window); err != nil {
+		return window, err
+	}
+
+	a.maintenanceMu.Lock()
+	defer a.maintenanceMu.Unlock()
+	window.Id = 1
+	for _, w := range a.maintenanceWindows {
+		window.Id = max(window.Id, w.Id+1)
+	}
+	if a.db != nil {
+		if err := a.db.Detached().StoreMaintenanceWindow(window); err != nil {
+			return window, errors.Wrap(err, "could not store maintenance window")
+		}
+	}
+	a.maintenanceWindows = append(a.maintenanceWindows, window)
+	return window, nil
+}
+
+// Removes a window added with AddMaintenanceWindow. Returns if it existed. The
+// epochs that were already processed keep their tags.
+func (a *Metrics) RemoveMaintenanceWindow(id uint64) (bool, error) {
+	a.maintenanceMu.Lock()
+	defer a.maintenanceMu.Unlock()
+	for i, window := range a.maintenanceWindows {
+		// Windows of the config have no id
+		if id == 0 || window.Id != id {
+			continue
+		}
+		if a.db != nil {
+			if _, err := a.db.Detached().DeleteMaintenanceWindow(id); err != nil {
+				return false, errors.Wrap(err, "could not delete maintenance window")
+			}
+		}
+		a.maintenanceWindows = append(a.maintenanceWindows[:i], a.maintenanceWindows[i+1:]...)
+		return true, nil
+	}
+	return false, nil
+}
+
+// Returns if the pool is in maintenance during any part of the epoch
+func (a *Metrics) inMaintenance(poolName string, epoch uint64) bool {
+	clock := a.networkParameters.Clock()
+	a.maintenanceMu.Lock()
+	defer a.maintenanceMu.Unlock()
+	return InMaintenance(a.maintenanceWindows, poolName, clock.EpochStart(epoch), clock.EpochStart(epoch+1))
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/bilinearlabs/eth-metrics/db"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseMaintenanceWindows(t *testing.T) {
+	windows, err := ParseMaintenanceWindows([]string{
+		"pool_a:2024-05-14T09:00:00Z/2024-05-14T11:00:00Z",
+		"*:2024-05-14T12:00:00+02:00/2024-05-14T13:00:00+02:00",
+	})
+	require.NoError(t, err)
+	require.Equal(t, []schemas.MaintenanceWindow{
+		{PoolName: "pool_a", Start: time.Date(2024, 5, 14, 9, 0, 0, 0, time.UTC), End: time.Date(2024, 5, 14, 11, 0, 0, 0, time.UTC)},
+		{PoolName: "*", Start: time.Date(2024, 5, 14, 10, 0, 0, 0, time.UTC), End: time.Date(2024, 5, 14, 11, 0, 0, 0, time.UTC)},
+	}, windows)
+
+	for _, value := range []string{
+		"pool_a",
+		"pool_a:2024-05-14T09:00:00Z",
+		":2024-05-14T09:00:00Z/2024-05-14T11:00:00Z",
+		"pool_a:2024-05-14/2024-05-15",
+		// Ends before it starts
+		"pool_a:2024-05-14T11:00:00Z/2024-05-14T09:00:00Z",
+	} {
+		_, err = ParseMaintenanceWindows([]string{value})
+		require.Error(t, err, value)
+	}
+}
+
+func Test_InMaintenance(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	windows := []schemas.MaintenanceWindow{
+		{PoolName: "pool_a", Start: start, End: start.Add(time.Hour)},
+		{PoolName: "*", Start: start.Add(24 * time.Hour), End: start.Add(25 * time.Hour)},
+	}
+	epochDuration := 384 * time.Second
+
+	// Epochs that overlap the window, even partially
+	require.True(t, InMaintenance(windows, "pool_a", start.Add(-time.Minute), start.Add(-time.Minute+epochDuration)))
+	require.True(t, InMaintenance(windows, "pool_a", start.Add(59*time.Minute), start.Add(59*time.Minute+epochDuration)))
+	require.False(t, InMaintenance(windows, "pool_a", start.Add(-epochDuration), start))
+	require.False(t, InMaintenance(windows, "pool_a", start.Add(time.Hour), start.Add(time.Hour+epochDuration)))
+	require.False(t, InMaintenance(windows, "pool_b", start, start.Add(epochDuration)))
+
+	// Of all pools
+	require.True(t, InMaintenance(windows, "pool_b", start.Add(24*time.Hour), start.Add(24*time.Hour+epochDuration)))
+}
+
+func Test_MaintenanceWindows(t *testing.T) {
+	database, err := db.New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.CreateTables())
+
+	cfg := &config.Config{MaintenanceWindows: []string{"pool_a:2024-05-14T09:00:00Z/2024-05-14T11:00:00Z"}}
+	m := &Metrics{db: database, config: cfg}
+	require.NoError(t, m.loadMaintenanceWindows())
+	require.Len(t, m.MaintenanceWindows(), 1)
+
+	start := time.Date(2024, 5, 15, 9, 0, 0, 0, time.UTC)
+	window, err := m.AddMaintenanceWindow(schemas.MaintenanceWindow{PoolName: "pool_b", Start: start, End: start.Add(time.Hour)})
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), window.Id)
+	window, err = m.AddMaintenanceWindow(schemas.MaintenanceWindow{PoolName: "pool_b", Start: start, End: start.Add(2 * time.Hour)})
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), window.Id)
+	_, err = m.AddMaintenanceWindow(schemas.MaintenanceWindow{Start: start, End: start.Add(time.Hour)})
+	require.Error(t, err)
+
+	// The windows of the config have no id and can't be removed
+	removed, err := m.RemoveMaintenanceWindow(0)
+	require.NoError(t, err)
+	require.False(t, removed)
+	removed, err = m.RemoveMaintenanceWindow(1)
+	require.NoError(t, err)
+	require.True(t, removed)
+	removed, err = m.RemoveMaintenanceWindow(1)
+	require.NoError(t, err)
+	require.False(t, removed)
+
+	// The added ones are kept after a restart
+	m = &Metrics{db: database, config: cfg}
+	require.NoError(t, m.loadMaintenanceWindows())
+	windows := m.MaintenanceWindows()
+	require.Len(t, windows, 2)
+	require.Equal(t, "pool_a", windows[0].PoolName)
+	require.Equal(t, uint64(2), windows[1].Id)
+	require.True(t, start.Add(2*time.Hour).Equal(windows[1].End))
+}
@@ -0,0 +1,358 @@
+package metrics
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Returned when joining a pool would move a validator out of a pool of another
+// tenant
+var ErrKeyOfOtherTenant = errors.New("validator key of a pool of another tenant")
+
+// Validator of a pool whose membership changed at some epoch
+type poolKey struct {
+	poolName string
+	pubKey   string
+}
+
+// Queues validators to join or leave a pool from the given epoch on, e.g. from
+// the admin api. Since an epoch may be being processed, they are stored and
+// applied before processing the next one. Returns the queued changes.
+func (a *Metrics) ChangePoolMembership(
+	poolName string,
+	pubKeys []string,
+	action schemas.MembershipAction,
+	epoch uint64) ([]schemas.PoolMembershipChange, error) {
+
+	if poolName == "" {
+		return nil, errors.New("pool name can't be empty")
+	}
+	if action != schemas.MembershipJoin && action != schemas.MembershipLeave {
+		return nil, errors.New("unknown membership action: " + string(action))
+	}
+	if len(pubKeys) == 0 {
+		return nil, errors.New("no validator keys given")
+	}
+
+	a.membershipMu.Lock()
+	defer a.membershipMu.Unlock()
+	changes := make([]schemas.PoolMembershipChange, 0, len(pubKeys))
+	for _, pubKey := range pubKeys {
+		key, err := hexutil.Decode(strings.TrimSpace(pubKey))
+		if err != nil || len(key) != 48 {
+			return nil, errors.New("invalid validator key: " + pubKey)
+		}
+		keyStr := hexutil.Encode(key)
+		// Pool as of the last epoch, since the tracked ones change while
+		// processing it
+		currentPool, tracked := a.syncedKeyToPool[keyStr]
+		if tracked && action == schemas.MembershipJoin && !a.canMoveKey(currentPool, poolName) {
+			return nil, errors.Wrap(ErrKeyOfOtherTenant, pubKey)
+		}
+		changes = append(changes, schemas.PoolMembershipChange{
+			Epoch:    epoch,
+			PoolName: poolName,
+			PubKey:   keyStr,
+			Action:   action,
+			Source:   schemas.MembershipSourceApi,
+			Time:     time.Now(),
+		})
+	}
+
+	a.pendingMembership = append(a.pendingMembership, changes...)
+	return changes, nil
+}
+
+// Loads the stored changes of pool membership, so that the validators added at
+// runtime are tracked after a restart
+func (a *Metrics) loadPoolMembership() error {
+	a.membership = make(map[poolKey][]schemas.PoolMembershipChange)
+	if a.db == nil {
+		return nil
+	}
+	changes, err := a.db.GetPoolMembershipChanges()
+	if err != nil {
+		return err
+	}
+	a.addPoolMembershipChanges(changes)
+	a.applyPoolMembership()
+	return nil
+}
+
+// Stores and applies the changes of pool membership queued since the last
+// call, and records the validators that joined, left or moved between pools in
+// the validators files and sources, from the epoch on. Returns if the changes
+// made with the admin api changed the tracked validators.
+func (a *Metrics) UpdatePoolMembership(epoch uint64) (bool, error) {
+	// Before the changes of the api, so that the validators they move leave
+	// the pools they were in
+	if err := a.recordConfigMembership(epoch); err != nil {
+		return false, err
+	}
+
+	a.membershipMu.Lock()
+	changes := a.pendingMembership
+	a.pendingMembership = nil
+	a.membershipMu.Unlock()
+	if len(changes) == 0 {
+		return false, nil
+	}
+
+	if err := a.storePoolMembershipChanges(changes); err != nil {
+		// Kept for the next call
+		a.membershipMu.Lock()
+		a.pendingMembership = append(changes, a.pendingMembership...)
+		a.membershipMu.Unlock()
+		return false, err
+	}
+	a.applyPoolMembership()
+	log.Info("Applied ", len(changes), " changes of pool membership")
+	return true, a.recordConfigMembership(epoch)
+}
+
+func (a *Metrics) recordConfigMembership(epoch uint64) error {
+	if err := a.storePoolMembershipChanges(a.configMembershipChanges(epoch)); err != nil {
+		return err
+	}
+	a.membershipMu.Lock()
+	a.syncedKeyToPool = maps.Clone(a.validatorKeyToPool)
+	a.membershipMu.Unlock()
+	return nil
+}
+
+func (a *Metrics) storePoolMembershipChanges(changes []schemas.PoolMembershipChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	if a.db != nil {
+		if err := a.db.StorePoolMembershipChanges(changes); err != nil {
+			return errors.Wrap(err, "could not store pool membership changes")
+		}
+	}
+	a.addPoolMembershipChanges(changes)
+	return nil
+}
+
+func (a *Metrics) addPoolMembershipChanges(changes []schemas.PoolMembershipChange) {
+	for _, change := range changes {
+		key := poolKey{change.PoolName, change.PubKey}
+		// A change in the same epoch replaces the previous one, as when stored
+		keyChanges := slices.DeleteFunc(a.membership[key], func(c schemas.PoolMembershipChange) bool {
+			return c.Epoch == change.Epoch
+		})
+		keyChanges = append(keyChanges, change)
+		slices.SortFunc(keyChanges, func(x, y schemas.PoolMembershipChange) int {
+			return cmp.Compare(x.Epoch, y.Epoch)
+		})
+		a.membership[key] = keyChanges
+	}
+}
+
+// Returns the changes of membership of the validators of the files and sources
+// since the last call, since they don't tell when a validator joined or left a
+// pool. Validators seen for the first time are members since genesis, and the
+// ones moved to another pool or removed later leave at the epoch. Validators
+// missing on startup don't leave, since the ones found in the beacon state or
+// in the blocks are only added again once seen.
+func (a *Metrics) configMembershipChanges(epoch uint64) []schemas.PoolMembershipChange {
+	now := time.Now()
+	newChange := func(poolName string, pubKey string, action schemas.MembershipAction, epoch uint64) schemas.PoolMembershipChange {
+		return schemas.PoolMembershipChange{
+			Epoch:    epoch,
+			PoolName: poolName,
+			PubKey:   pubKey,
+			Action:   action,
+			Source:   schemas.MembershipSourceConfig,
+			Time:     now,
+		}
+	}
+
+	// Pools whose last change of each validator is joining them
+	memberOf := make(map[string][]string)
+	hasChanges := make(map[string]bool)
+	for key, keyChanges := range a.membership {
+		hasChanges[key.pubKey] = true
+		if keyChanges[len(keyChanges)-1].Action == schemas.MembershipJoin {
+			memberOf[key.pubKey] = append(memberOf[key.pubKey], key.poolName)
+		}
+	}
+
+	changes := make([]schemas.PoolMembershipChange, 0)
+	for pubKey, poolName := range a.validatorKeyToPool {
+		joinEpoch := epoch
+		if slices.Contains(memberOf[pubKey], poolName) {
+			keyChanges := a.membership[poolKey{poolName, pubKey}]
+			joinEpoch = keyChanges[len(keyChanges)-1].Epoch
+		} else {
+			if !hasChanges[pubKey] {
+				joinEpoch = 0
+			}
+			changes = append(changes, newChange(poolName, pubKey, schemas.MembershipJoin, joinEpoch))
+		}
+		// Moved, e.g. to another file or with the admin api
+		for _, otherPool := range memberOf[pubKey] {
+			if otherPool == poolName {
+				continue
+			}
+			keyChanges := a.membership[poolKey{otherPool, pubKey}]
+			leaveEpoch := max(joinEpoch, keyChanges[len(keyChanges)-1].Epoch)
+			changes = append(changes, newChange(otherPool, pubKey, schemas.MembershipLeave, leaveEpoch))
+		}
+	}
+	for pubKey := range a.syncedKeyToPool {
+		if _, ok := a.validatorKeyToPool[pubKey]; ok {
+			continue
+		}
+		for _, poolName := range memberOf[pubKey] {
+			changes = append(changes, newChange(poolName, pubKey, schemas.MembershipLeave, epoch))
+		}
+	}
+	return changes
+}
+
+// Returns the last change of the validator in the pool made with the admin api
+func (a *Metrics) lastApiChange(key poolKey) (schemas.PoolMembershipChange, bool) {
+	changes := a.membership[key]
+	for i := len(changes) - 1; i >= 0; i-- {
+		if changes[i].Source == schemas.MembershipSourceApi {
+			return changes[i], true
+		}
+	}
+	return schemas.PoolMembershipChange{}, false
+}
+
+// Applies the changes made with the admin api on top of the validators files
+// and sources. Validators whose last change is joining a pool are moved to it,
+// and the ones whose last change is leaving the pool they are in are removed,
+// as they are only members in the epochs before leaving. Also called after
+// reloading the key files, so that they don't undo the changes.
+func (a *Metrics) applyPoolMembership() {
+	lastChanges := make([]schemas.PoolMembershipChange, 0)
+	for key := range a.membership {
+		if change, ok := a.lastApiChange(key); ok {
+			lastChanges = append(lastChanges, change)
+		}
+	}
+	// The last join wins if a validator joined several pools
+	slices.SortFunc(lastChanges, func(x, y schemas.PoolMembershipChange) int {
+		return cmp.Or(cmp.Compare(x.Epoch, y.Epoch), cmp.Compare(x.PoolName, y.PoolName))
+	})
+
+	for _, change := range lastChanges {
+		poolName, tracked := a.validatorKeyToPool[change.PubKey]
+		if change.Action == schemas.MembershipLeave {
+			if tracked && poolName == change.PoolName {
+				a.removeValidatorFromPool(change.PubKey)
+			}
+			continue
+		}
+		if tracked {
+			if poolName == change.PoolName {
+				continue
+			}
+			if !a.canMoveKey(poolName, change.PoolName) {
+				log.WithFields(log.Fields{
+					"PoolName": change.PoolName,
+					"PubKey":   change.PubKey,
+				}).Warn("Validator not moved, it is in pool ", poolName, " of another tenant")
+				continue
+			}
+			a.removeValidatorFromPool(change.PubKey)
+		}
+		pubKey, err := hexutil.Decode(change.PubKey)
+		if err != nil {
+			log.Warn("could not decode key of pool membership: ", change.PubKey)
+			continue
+		}
+		a.validatorKeysPerPool[change.PoolName] = append(a.validatorKeysPerPool[change.PoolName], pubKey)
+		a.validatorKeyToPool[change.PubKey] = change.PoolName
+	}
+}
+
+// Returns if a validator can join the pool from the one it is in. The pools of a
+// tenant can only take the validators of its own pools, while the ones of the
+// operator can take any.
+func (a *Metrics) canMoveKey(fromPool string, toPool string) bool {
+	toTenant := a.PoolTenant(toPool)
+	return toTenant == "" || a.PoolTenant(fromPool) == toTenant
+}
+
+func (a *Metrics) removeValidatorFromPool(pubKey string) {
+	poolName := a.validatorKeyToPool[pubKey]
+	a.validatorKeysPerPool[poolName] = slices.DeleteFunc(a.validatorKeysPerPool[poolName], func(k []byte) bool {
+		return hexutil.Encode(k) == pubKey
+	})
+	delete(a.validatorKeyToPool, pubKey)
+}
+
+// Returns if the validator left the pool with the admin api, so that it is not
+// added again when found in the beacon state or in a block
+func (a *Metrics) leftPool(pubKey string, poolName string) bool {
+	change, ok := a.lastApiChange(poolKey{poolName, pubKey})
+	return ok && change.Action == schemas.MembershipLeave
+}
+
+// Returns if the validator is a member of the pool in the epoch according to
+// its changes of membership, and false if it has none
+func isPoolMemberAt(changes []schemas.PoolMembershipChange, epoch uint64) (bool, bool) {
+	if len(changes) == 0 {
+		return false, false
+	}
+	member := changes[0].Action == schemas.MembershipLeave
+	for _, change := range changes {
+		if change.Epoch > epoch {
+			break
+		}
+		member = change.Action == schemas.MembershipJoin
+	}
+	return member, true
+}
+
+// Returns the keys of each pool in the epoch. Validators that joined a pool
+// later or left it before are not in it, and the ones that left it later are.
+// Validators that are no longer tracked without leaving, e.g. the ones found
+// in the beacon state before a restart, are only in it once found again.
+func (a *Metrics) poolKeysAt(epoch uint64) map[string][][]byte {
+	if len(a.membership) == 0 {
+		return a.validatorKeysPerPool
+	}
+
+	poolKeys := make(map[string][][]byte, len(a.validatorKeysPerPool))
+	seen := make(map[poolKey]bool)
+	for poolName, keys := range a.validatorKeysPerPool {
+		poolKeys[poolName] = make([][]byte, 0, len(keys))
+		for _, key := range keys {
+			pk := poolKey{poolName, hexutil.Encode(key)}
+			if member, known := isPoolMemberAt(a.membership[pk], epoch); known {
+				seen[pk] = true
+				if !member {
+					continue
+				}
+			}
+			poolKeys[poolName] = append(poolKeys[poolName], key)
+		}
+	}
+	// Validators that left the pool or moved to another one after the epoch
+	for pk, changes := range a.membership {
+		if changes[len(changes)-1].Action != schemas.MembershipLeave || seen[pk] {
+			continue
+		}
+		if member, _ := isPoolMemberAt(changes, epoch); !member {
+			continue
+		}
+		key, err := hexutil.Decode(pk.pubKey)
+		if err != nil {
+			continue
+		}
+		poolKeys[pk.poolName] = append(poolKeys[pk.poolName], key)
+	}
+	return poolKeys
+}
@@ -0,0 +1,211 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/bilinearlabs/eth-metrics/db"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ChangePoolMembership(t *testing.T) {
+	key1 := "0x947265fae1dc387b143a913a6a5f6a4b5b5db897661b38de728dad62a4ac3a9a4232116338bb35a85944fe6263faac61"
+	key2 := "0x8253556022b09877b0de3de5a0e4b3c254be36f200dcd6ec2a285ce0758b83aae049a54ec3a73f5fa80d2ad84cfea3cf"
+	key1Bytes, err := hexutil.Decode(key1)
+	require.NoError(t, err)
+
+	database, err := db.New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.CreateTables())
+
+	m := &Metrics{
+		db:                   database,
+		validatorKeysPerPool: map[string][][]byte{"pool_a": {key1Bytes}},
+		validatorKeyToPool:   map[string]string{key1: "pool_a"},
+	}
+	require.NoError(t, m.loadPoolMembership())
+
+	_, err = m.ChangePoolMembership("pool_b", []string{"0x1234"}, schemas.MembershipJoin, 10)
+	require.ErrorContains(t, err, "invalid validator key")
+	_, err = m.ChangePoolMembership("pool_b", []string{key1}, "move", 10)
+	require.Error(t, err)
+
+	// Queued until stored
+	changes, err := m.ChangePoolMembership("pool_b", []string{key1, key2}, schemas.MembershipJoin, 10)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	require.Equal(t, "pool_a", m.validatorKeyToPool[key1])
+
+	changed, err := m.UpdatePoolMembership(10)
+	require.NoError(t, err)
+	require.True(t, changed)
+	changed, err = m.UpdatePoolMembership(10)
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	// Moved from pool_a from epoch 10 on
+	require.Equal(t, "pool_b", m.validatorKeyToPool[key1])
+	require.Equal(t, "pool_b", m.validatorKeyToPool[key2])
+	require.Empty(t, m.validatorKeysPerPool["pool_a"])
+	require.Len(t, m.poolKeysAt(10)["pool_b"], 2)
+	require.Equal(t, [][]byte{key1Bytes}, m.poolKeysAt(9)["pool_a"])
+	require.Empty(t, m.poolKeysAt(9)["pool_b"])
+
+	// Left from epoch 20 on
+	_, err = m.ChangePoolMembership("pool_b", []string{key2}, schemas.MembershipLeave, 20)
+	require.NoError(t, err)
+	_, err = m.UpdatePoolMembership(15)
+	require.NoError(t, err)
+	require.Len(t, m.poolKeysAt(19)["pool_b"], 2)
+	require.Equal(t, [][]byte{key1Bytes}, m.poolKeysAt(20)["pool_b"])
+
+	// Loaded again after a restart
+	m = &Metrics{
+		db:                   database,
+		validatorKeysPerPool: map[string][][]byte{"pool_a": {key1Bytes}},
+		validatorKeyToPool:   map[string]string{key1: "pool_a"},
+	}
+	require.NoError(t, m.loadPoolMembership())
+	require.Equal(t, "pool_b", m.validatorKeyToPool[key1])
+	require.Equal(t, [][]byte{key1Bytes}, m.poolKeysAt(20)["pool_b"])
+	require.Equal(t, [][]byte{key1Bytes}, m.poolKeysAt(5)["pool_a"])
+}
+
+func Test_ChangePoolMembership_Tenants(t *testing.T) {
+	key1 := "0x947265fae1dc387b143a913a6a5f6a4b5b5db897661b38de728dad62a4ac3a9a4232116338bb35a85944fe6263faac61"
+	key2 := "0x8253556022b09877b0de3de5a0e4b3c254be36f200dcd6ec2a285ce0758b83aae049a54ec3a73f5fa80d2ad84cfea3cf"
+	key3 := "0xa1d1ad0714035353258038e964ae9675dc0252ee22cea896825c01458e1807bfad2f9969338798548d9858a571f7425c"
+	key1Bytes, err := hexutil.Decode(key1)
+	require.NoError(t, err)
+	key2Bytes, err := hexutil.Decode(key2)
+	require.NoError(t, err)
+	key3Bytes, err := hexutil.Decode(key3)
+	require.NoError(t, err)
+
+	database, err := db.New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.CreateTables())
+
+	m := &Metrics{
+		db:                   database,
+		validatorKeysPerPool: map[string][][]byte{"pool_a": {key1Bytes}, "pool_c": {key2Bytes}},
+		validatorKeyToPool:   map[string]string{key1: "pool_a", key2: "pool_c"},
+		poolTenants:          map[string]string{"pool_b": "tenant_1", "pool_c": "tenant_2", "pool_d": "tenant_2"},
+	}
+	require.NoError(t, m.loadPoolMembership())
+	_, err = m.UpdatePoolMembership(10)
+	require.NoError(t, err)
+
+	// Tenants can't take the validators of the operator or of other tenants
+	_, err = m.ChangePoolMembership("pool_b", []string{key1}, schemas.MembershipJoin, 10)
+	require.ErrorIs(t, err, ErrKeyOfOtherTenant)
+	_, err = m.ChangePoolMembership("pool_b", []string{key2}, schemas.MembershipJoin, 10)
+	require.ErrorIs(t, err, ErrKeyOfOtherTenant)
+
+	// But can move their own, and the operator any of them
+	_, err = m.ChangePoolMembership("pool_d", []string{key2}, schemas.MembershipJoin, 10)
+	require.NoError(t, err)
+	_, err = m.ChangePoolMembership("pool_a", []string{key2}, schemas.MembershipJoin, 11)
+	require.NoError(t, err)
+
+	// Not moved if it was added to a pool of another tenant before applying
+	_, err = m.ChangePoolMembership("pool_b", []string{key3}, schemas.MembershipJoin, 12)
+	require.NoError(t, err)
+	m.validatorKeysPerPool["pool_c"] = append(m.validatorKeysPerPool["pool_c"], key3Bytes)
+	m.validatorKeyToPool[key3] = "pool_c"
+
+	_, err = m.UpdatePoolMembership(12)
+	require.NoError(t, err)
+	require.Equal(t, "pool_a", m.validatorKeyToPool[key1])
+	require.Equal(t, "pool_a", m.validatorKeyToPool[key2])
+	require.Equal(t, "pool_c", m.validatorKeyToPool[key3])
+}
+
+func Test_UpdatePoolMembership_Config(t *testing.T) {
+	key1 := "0x947265fae1dc387b143a913a6a5f6a4b5b5db897661b38de728dad62a4ac3a9a4232116338bb35a85944fe6263faac61"
+	key2 := "0x8253556022b09877b0de3de5a0e4b3c254be36f200dcd6ec2a285ce0758b83aae049a54ec3a73f5fa80d2ad84cfea3cf"
+	key1Bytes, err := hexutil.Decode(key1)
+	require.NoError(t, err)
+	key2Bytes, err := hexutil.Decode(key2)
+	require.NoError(t, err)
+
+	database, err := db.New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.CreateTables())
+
+	m := &Metrics{
+		db:                   database,
+		validatorKeysPerPool: map[string][][]byte{"pool_a": {key1Bytes, key2Bytes}},
+		validatorKeyToPool:   map[string]string{key1: "pool_a", key2: "pool_a"},
+	}
+	require.NoError(t, m.loadPoolMembership())
+
+	// Members since genesis when first seen
+	changed, err := m.UpdatePoolMembership(100)
+	require.NoError(t, err)
+	require.False(t, changed)
+	require.Len(t, m.poolKeysAt(0)["pool_a"], 2)
+
+	// key1 moved to pool_b and key2 removed from the files in epoch 110
+	m.validatorKeysPerPool = map[string][][]byte{"pool_b": {key1Bytes}}
+	m.validatorKeyToPool = map[string]string{key1: "pool_b"}
+	_, err = m.UpdatePoolMembership(110)
+	require.NoError(t, err)
+
+	require.Len(t, m.poolKeysAt(109)["pool_a"], 2)
+	require.Empty(t, m.poolKeysAt(109)["pool_b"])
+	require.Empty(t, m.poolKeysAt(110)["pool_a"])
+	require.Equal(t, [][]byte{key1Bytes}, m.poolKeysAt(110)["pool_b"])
+
+	changes, err := database.GetPoolMembershipChanges()
+	require.NoError(t, err)
+	require.Len(t, changes, 5)
+	for _, change := range changes {
+		require.Equal(t, schemas.MembershipSourceConfig, change.Source)
+	}
+
+	// Nothing changes after a restart, and the history is kept
+	m = &Metrics{
+		db:                   database,
+		validatorKeysPerPool: map[string][][]byte{"pool_b": {key1Bytes}},
+		validatorKeyToPool:   map[string]string{key1: "pool_b"},
+	}
+	require.NoError(t, m.loadPoolMembership())
+	_, err = m.UpdatePoolMembership(120)
+	require.NoError(t, err)
+	require.Len(t, m.poolKeysAt(105)["pool_a"], 2)
+	changes, err = database.GetPoolMembershipChanges()
+	require.NoError(t, err)
+	require.Len(t, changes, 5)
+
+	// Not added again by the sources after leaving with the api
+	_, err = m.ChangePoolMembership("pool_b", []string{key1}, schemas.MembershipLeave, 130)
+	require.NoError(t, err)
+	changed, err = m.UpdatePoolMembership(130)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Empty(t, m.validatorKeyToPool)
+	require.False(t, m.addValidatorToPool(key1Bytes, "pool_b"))
+	require.Equal(t, [][]byte{key1Bytes}, m.poolKeysAt(129)["pool_b"])
+	require.Empty(t, m.poolKeysAt(130)["pool_b"])
+}
+
+func Test_IsPoolMemberAt(t *testing.T) {
+	_, known := isPoolMemberAt(nil, 10)
+	require.False(t, known)
+
+	changes := []schemas.PoolMembershipChange{
+		{Epoch: 10, Action: schemas.MembershipJoin},
+		{Epoch: 20, Action: schemas.MembershipLeave},
+	}
+	for epoch, expected := range map[uint64]bool{9: false, 10: true, 19: true, 20: false} {
+		member, known := isPoolMemberAt(changes, epoch)
+		require.True(t, known)
+		require.Equal(t, expected, member, epoch)
+	}
+
+	// Members before leaving, if they never joined at runtime
+	member, _ := isPoolMemberAt(changes[1:], 5)
+	require.True(t, member)
+}
@@ -3,34 +3,143 @@ package metrics
 import (
 	"context"
 	"encoding/base64"
+	"fmt"
+	"io"
 	"math/big"
+	nethttp "net/http"
 	"os"
 	"path/filepath"
-	"strconv"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
-	nethttp "net/http"
-
 	"github.com/attestantio/go-eth2-client/api"
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
 	"github.com/attestantio/go-eth2-client/http"
 	"github.com/attestantio/go-eth2-client/spec"
-	"github.com/ethereum/go-ethereum/common/hexutil"
-	"github.com/ethereum/go-ethereum/ethclient"
-	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/rs/zerolog"
 
+	"github.com/bilinearlabs/eth-metrics/alerts"
 	"github.com/bilinearlabs/eth-metrics/config"
 	"github.com/bilinearlabs/eth-metrics/db"
+	"github.com/bilinearlabs/eth-metrics/epochtime"
+	"github.com/bilinearlabs/eth-metrics/execution"
+	"github.com/bilinearlabs/eth-metrics/forkdata"
+	"github.com/bilinearlabs/eth-metrics/networks"
 	"github.com/bilinearlabs/eth-metrics/pools"
+	"github.com/bilinearlabs/eth-metrics/price"
+	"github.com/bilinearlabs/eth-metrics/publish"
+	"github.com/bilinearlabs/eth-metrics/schemas"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	log "github.com/sirupsen/logrus"
 )
 
+// Times a pool that fails in an epoch is processed before giving up
+const maxPoolAttempts = 3
+
+// Buckets from 1 second to ~8 minutes, since fetching a full beacon state can
+// take minutes
+var processingBuckets = prometheus.ExponentialBuckets(1, 2, 10)
+
+var (
+	epochProcessingDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "ethmetrics_epoch_processing_duration_seconds",
+		Help:    "Duration of the processing of each epoch",
+		Buckets: processingBuckets,
+	})
+	beaconStateDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ethmetrics_beacon_state_duration_seconds",
+		Help:    "Duration of getting the beacon state of an epoch, full or light",
+		Buckets: processingBuckets,
+	}, []string{"mode"})
+	headEpoch = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ethmetrics_head_epoch",
+		Help: "Epoch of the head of the consensus client",
+	})
+	lastProcessedEpoch = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ethmetrics_last_processed_epoch",
+		Help: "Latest epoch that was processed",
+	})
+	processingLag = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ethmetrics_processing_lag_epochs",
+		Help: "Head epoch minus the last processed epoch, including the follow distance",
+	})
+)
+
+// The price is sampled every 30 minutes, so older samples are from a gap
+const ethPriceMaxAge = time.Hour
+
+// Returns the headers sent to the beacon node, with the credentials or the
+// bearer token if provided, and the --eth2-header ones, which take precedence
+func BeaconNodeHeaders(config *config.Config) map[string]string {
+	headers := map[string]string{}
+	if config.Credentials != "" {
+		headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(config.Credentials))
+	}
+	if config.Eth2BearerToken != "" {
+		headers["Authorization"] = "Bearer " + config.Eth2BearerToken
+	}
+	for name, value := range config.Eth2Headers {
+		headers[name] = value
+	}
+	return headers
+}
+
+// Returns an error if the beacon node is not on the --network, so that the
+// keys and relays of a network are not mixed with the chain of another one
+func CheckGenesis(config *config.Config, genesis *v1.Genesis) error {
+	network, err := networks.Get(config.Network)
+	if err != nil {
+		return err
+	}
+	return network.CheckGenesis(genesis.GenesisTime, genesis.GenesisValidatorsRoot)
+}
+
+// Records the time elapsed since start, meant to be deferred
+func observeDuration(histogram prometheus.Observer, start time.Time) {
+	histogram.Observe(time.Since(start).Seconds())
+}
+
 type NetworkParameters struct {
 	genesisSeconds uint64
 	slotsInEpoch   uint64
 	secondsPerSlot uint64
+	// Epochs that a sync committee serves for
+	epochsPerSyncPeriod uint64
+	// Epoch of each fork after phase0
+	forkEpochs map[spec.DataVersion]uint64
+}
+
+// Returns the fork of the epoch
+func (p *NetworkParameters) forkAt(epoch uint64) spec.DataVersion {
+	fork := spec.DataVersionPhase0
+	for _, next := range forkdata.Forks[1:] {
+		if forkEpoch, ok := p.forkEpochs[next]; ok && forkEpoch <= epoch {
+			fork = next
+		}
+	}
+	return fork
+}
+
+// Converts the epochs and slots of the network to time
+func (p *NetworkParameters) Clock() *epochtime.Clock {
+	return epochtime.New(
+		time.Unix(int64(p.genesisSeconds), 0),
+		time.Duration(p.secondsPerSlot)*time.Second,
+		p.slotsInEpoch)
+}
+
+// Unix time in seconds of the start of the slot
+func (p *NetworkParameters) slotTime(slot uint64) uint64 {
+	return uint64(p.Clock().SlotStart(slot).Unix())
+}
+
+// Unix time in seconds of the start of the epoch
+func (p *NetworkParameters) epochTime(epoch uint64) uint64 {
+	return p.slotTime(epoch * p.slotsInEpoch)
 }
 
 type Metrics struct {
@@ -38,14 +147,68 @@ type Metrics struct {
 	config               *config.Config
 	db                   *db.Database
 	httpClient           *http.Service
-	executionClient      *ethclient.Client
+	beaconCache          *BeaconCache
+	stateCache           *StateCache
+	executionClient      *execution.Client
 	validatorKeysPerPool map[string][][]byte
 	validatorKeyToPool   map[string]string
-	beaconState          *BeaconState
-	proposalDuties       *ProposalDuties
-	relayRewards         *RelayRewards
-	networkStats         *NetworkStats
-	blockData            *BlockData
+	// Validators given by index whose keys are taken from the beacon state
+	validatorIndexesPerPool map[string][]uint64
+	addressToPool           map[string]string
+	feeRecipients           map[string]map[string]bool
+	poolGroups              map[string]schemas.PoolGroup
+	keysFoundByAddress      map[string]string
+	keyFilesModTime         time.Time
+	remoteValidatorsFile    *pools.RemoteValidatorsFile
+	validatorSources        []pools.ValidatorSource
+	beaconState             *BeaconState
+	proposalDuties          *ProposalDuties
+	relayRewards            *RelayRewards
+	networkStats            *NetworkStats
+	networkQueues           *NetworkQueues
+	epochBlocks             *EpochBlocks
+	blockData               *BlockData
+	inclusionDelay          *InclusionDelay
+	attestationRewards      *AttestationRewards
+	syncCommitteeRewards    *SyncCommitteeRewards
+	priceProvider           price.Provider
+	alerts                  *alerts.Alerts
+	publisher               *publish.Publisher
+	sinks                   *publish.Sinks
+	clickHouse              *publish.ClickHouse
+	// Only set when running the loop
+	blockArrivals *BlockArrivals
+	// Where the metrics are printed in dry-run mode
+	dryRunOutput io.Writer
+
+	// Changes of pool membership by validator, sorted by epoch, and the ones
+	// queued to be applied before the next epoch
+	membership        map[poolKey][]schemas.PoolMembershipChange
+	membershipMu      sync.Mutex
+	pendingMembership []schemas.PoolMembershipChange
+	// Pool of each validator when the membership was last recorded
+	syncedKeyToPool map[string]string
+
+	// Pool of each validator in the last processed epoch, whose upcoming
+	// duties are forecast, and the ones that were alerted by their last slot
+	upcomingMu      sync.Mutex
+	forecastIndexes map[uint64]string
+	upcomingDuties  []schemas.UpcomingDuty
+	alertedDuties   map[string]uint64
+
+	// Windows in which the missed duties of a pool are tagged and not alerted
+	maintenanceMu      sync.Mutex
+	maintenanceWindows []schemas.MaintenanceWindow
+	// Targets of the SLA by pool, * for the rest
+	slaTargets map[string]SLATargets
+
+	// Epoch of the last check of the tracked keys against the beacon state
+	keysChecked  bool
+	lastKeyCheck uint64
+
+	// Tenant of each pool in multi-tenant mode, and the sinks of each tenant
+	poolTenants map[string]string
+	tenantSinks map[string]*publish.Sinks
 }
 
 func NewMetrics(
@@ -55,8 +218,10 @@ func NewMetrics(
 	var database *db.Database
 	var err error
 
-	if config.DatabasePath != "" {
-		database, err = db.New(config.DatabasePath)
+	if config.DryRun {
+		log.Warn("Dry run, the metrics are printed to stdout and not stored, published or alerted")
+	} else if config.DatabasePath != "" {
+		database, err = db.NewWithOptions(config.DatabasePath, db.OptionsFromConfig(config))
 		if err != nil {
 			return nil, errors.Wrap(err, "could not create postgresql")
 		}
@@ -68,44 +233,68 @@ func NewMetrics(
 
 	var validatorKeysPerPool map[string][][]byte
 	var validatorKeyToPool map[string]string
+	var validatorIndexesPerPool map[string][]uint64
+	var remoteValidatorsFile *pools.RemoteValidatorsFile
+	var keyFilesModTime time.Time
 
-	if config.ValidatorsFile != "" {
-		validatorKeysPerPool, validatorKeyToPool, err = pools.ReadValidatorsFile(config.ValidatorsFile)
+	if pools.IsRemoteFile(config.ValidatorsFile) {
+		remoteValidatorsFile, err = pools.NewRemoteValidatorsFile(config.ValidatorsFile, config.ValidatorsFileAuth, config.DuplicateKeys)
+		if err != nil {
+			return nil, err
+		}
+		validatorKeysPerPool, validatorKeyToPool, validatorIndexesPerPool, _, err = remoteValidatorsFile.Fetch()
 		if err != nil {
 			return nil, errors.Wrap(err, "error reading validators file")
 		}
 	} else {
-		// TODO check if mantain reading from txt files
-		validatorKeysPerPool = make(map[string][][]byte)
-		validatorKeyToPool = make(map[string]string)
-		for _, poolName := range config.PoolNames {
-			if strings.HasSuffix(poolName, ".txt") {
-				pubKeysDeposited, err := pools.ReadCustomValidatorsFile(poolName)
-				if err != nil {
-					log.Fatal(err)
-				}
-				validatorKeysPerPool[poolName] = pubKeysDeposited
-				for _, key := range pubKeysDeposited {
-					keyStr := hexutil.Encode(key)
-					validatorKeyToPool[keyStr] = poolName
-				}
-				log.Info("File: ", poolName, " contains ", len(pubKeysDeposited), " keys")
-			}
+		validatorKeysPerPool, validatorKeyToPool, validatorIndexesPerPool, err = LoadValidatorKeys(config)
+		if err != nil {
+			return nil, err
+		}
+		keyFilesModTime, err = getLatestModTime(getValidatorKeyFiles(config))
+		if err != nil {
+			return nil, errors.Wrap(err, "error checking validator key files")
 		}
 	}
 
-	// Add header with credentials if provided
-	encodedCredentials := base64.StdEncoding.EncodeToString([]byte(config.Credentials))
-	cred := map[string]string{}
-	if config.Credentials != "" {
-		cred["Authorization"] = "Basic " + encodedCredentials
+	if err := checkValidatorIndexes(config, validatorIndexesPerPool); err != nil {
+		return nil, err
+	}
+
+	addressToPool, err := pools.ParsePoolAddresses(config.PoolAddresses)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing pool addresses")
+	}
+	// Finding the validators of an address requires the whole validator registry
+	if len(addressToPool) > 0 && config.LightMode {
+		return nil, errors.New("pools defined by address are not supported in light mode")
+	}
+
+	feeRecipients, err := pools.ParseExpectedFeeRecipients(config.FeeRecipients)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing expected fee recipients")
+	}
+
+	poolGroups, err := pools.ParsePoolGroups(config.PoolGroups)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing pool groups")
+	}
+	if len(poolGroups) > 0 && config.DryRun {
+		log.Warn("Pool groups are rolled up in the database, so they are not printed in dry-run mode")
+	}
+
+	// The requests served from the cache are not counted
+	beaconCache, err := NewBeaconCache(NewBeaconRequestMetrics(nethttp.DefaultTransport), config.BeaconCacheSize, config.BeaconCacheDir)
+	if err != nil {
+		return nil, err
 	}
 
 	client, err := http.New(context.Background(),
 		http.WithTimeout(60*time.Second),
 		http.WithAddress(config.Eth2Address),
 		http.WithLogLevel(zerolog.WarnLevel),
-		http.WithExtraHeaders(cred),
+		http.WithExtraHeaders(BeaconNodeHeaders(config)),
+		http.WithHTTPClient(&nethttp.Client{Transport: beaconCache, Timeout: 60 * time.Second}),
 	)
 	if err != nil {
 		return nil, err
@@ -117,73 +306,211 @@ func NewMetrics(
 	if err != nil {
 		return nil, errors.Wrap(err, "error getting genesis info")
 	}
+	if err := CheckGenesis(config, genesis.Data); err != nil {
+		return nil, err
+	}
 
 	spec, err := httpClient.Spec(context.Background(), &api.SpecOpts{})
 	if err != nil {
 		return nil, errors.Wrap(err, "error getting spec info")
 	}
 
-	slotsPerEpochInterface, found := spec.Data["SLOTS_PER_EPOCH"]
-	if !found {
-		return nil, errors.New("SLOTS_PER_EPOCH not found in spec")
+	slotsPerEpoch, err := SpecUint64(spec.Data, "SLOTS_PER_EPOCH")
+	if err != nil {
+		return nil, err
+	}
+	if slotsPerEpoch == 0 {
+		return nil, errors.New("SLOTS_PER_EPOCH can't be 0")
 	}
 
-	secondsPerSlotInterface, found := spec.Data["SECONDS_PER_SLOT"]
-	if !found {
-		return nil, errors.New("SECONDS_PER_SLOT not found in spec")
+	slotDuration, err := SpecSlotDuration(spec.Data)
+	if err != nil {
+		return nil, err
 	}
+	secondsPerSlot := uint64(slotDuration.Seconds())
 
-	slotsPerEpoch := slotsPerEpochInterface.(uint64)
+	epochsPerSyncPeriod, err := SpecUint64(spec.Data, "EPOCHS_PER_SYNC_COMMITTEE_PERIOD")
+	if err != nil {
+		return nil, err
+	}
 
-	secondsPerSlot := uint64(secondsPerSlotInterface.(time.Duration).Seconds())
+	forkEpochs, err := SpecForkEpochs(spec.Data)
+	if err != nil {
+		return nil, err
+	}
 
 	log.Info("Genesis time: ", genesis.Data.GenesisTime.Unix())
 	log.Info("Slots per epoch: ", slotsPerEpoch)
 	log.Info("Seconds per slot: ", secondsPerSlot)
 
-	rcpClient, err := rpc.DialOptions(
-		context.Background(),
-		config.Eth1Address,
-		rpc.WithHTTPAuth(func(h nethttp.Header) error {
-			h.Set("Authorization", "Basic "+encodedCredentials)
-			return nil
-		}),
-		rpc.WithHTTPClient(&nethttp.Client{Timeout: 60 * time.Second}),
-	)
-	if err != nil {
-		return nil, errors.Wrap(err, "error dialing execution client")
+	// Without execution client the proposer tips of blocks without MEV rewards
+	// are not computed, but everything else is
+	var executionClient *execution.Client
+	if len(config.Eth1Addresses) > 0 {
+		auth, err := execution.AuthFromConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		executionClient, err = execution.NewClient(config.Eth1Addresses, auth)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		log.Warn("No execution client configured, proposer tips of blocks without MEV rewards are not computed")
 	}
 
-	executionClient := ethclient.NewClient(rcpClient)
+	validatorSources, err := NewValidatorSources(config, executionClient)
+	if err != nil {
+		return nil, err
+	}
 
 	networkParameters := &NetworkParameters{
-		genesisSeconds: uint64(genesis.Data.GenesisTime.Unix()),
-		slotsInEpoch:   slotsPerEpoch,
-		secondsPerSlot: secondsPerSlot,
-	}
-
-	return &Metrics{
-		networkParameters:    networkParameters,
-		db:                   database,
-		httpClient:           httpClient,
-		executionClient:      executionClient,
-		config:               config,
-		validatorKeysPerPool: validatorKeysPerPool,
-		validatorKeyToPool:   validatorKeyToPool,
-	}, nil
+		genesisSeconds:      uint64(genesis.Data.GenesisTime.Unix()),
+		slotsInEpoch:        slotsPerEpoch,
+		secondsPerSlot:      secondsPerSlot,
+		epochsPerSyncPeriod: epochsPerSyncPeriod,
+		forkEpochs:          forkEpochs,
+	}
+
+	metrics := &Metrics{
+		networkParameters:       networkParameters,
+		db:                      database,
+		httpClient:              httpClient,
+		beaconCache:             beaconCache,
+		executionClient:         executionClient,
+		dryRunOutput:            os.Stdout,
+		config:                  config,
+		validatorKeysPerPool:    validatorKeysPerPool,
+		validatorKeyToPool:      validatorKeyToPool,
+		validatorIndexesPerPool: validatorIndexesPerPool,
+		addressToPool:           addressToPool,
+		feeRecipients:           feeRecipients,
+		poolGroups:              poolGroups,
+		keysFoundByAddress:      make(map[string]string),
+		keyFilesModTime:         keyFilesModTime,
+		remoteValidatorsFile:    remoteValidatorsFile,
+		validatorSources:        validatorSources,
+	}
+	// Created here so that the api can silence alerts before the setup
+	metrics.alerts, err = alerts.NewAlerts(metrics.outputConfig(), networkParameters.Clock())
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing alert routes and silences")
+	}
+	if err := metrics.loadPoolMembership(); err != nil {
+		return nil, errors.Wrap(err, "error loading pool membership")
+	}
+	if err := metrics.loadMaintenanceWindows(); err != nil {
+		return nil, errors.Wrap(err, "error loading maintenance windows")
+	}
+	if err := metrics.loadTenants(); err != nil {
+		return nil, errors.Wrap(err, "error storing tenant pools")
+	}
+	metrics.slaTargets, err = ParseSLATargets(config.SLATargets)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing sla targets")
+	}
+	return metrics, nil
+}
+
+func (a *Metrics) Run() error {
+	if a.config.EpochDebug != "" {
+		go func() {
+			if err := a.DebugEpochs(a.config.EpochDebugFrom, a.config.EpochDebugTo); err != nil {
+				log.Fatal(err)
+			}
+			log.Warn("Running in debug mode, exiting ok.")
+			os.Exit(0)
+		}()
+		return nil
+	}
+	if err := a.setup(); err != nil {
+		return err
+	}
+	a.blockArrivals = NewBlockArrivals(blockArrivalEpochs * a.networkParameters.slotsInEpoch)
+	if err := a.WatchBlockArrivals(context.Background()); err != nil {
+		log.Warn("Proposal timings are not available: ", err)
+	}
+	go a.Loop()
+	go a.ForecastDuties()
+	return nil
 }
 
-func (a *Metrics) Run() {
+// Processes the given range of epochs, both included, and returns. Epochs that
+// were already processed are processed again. The progress is stored, so that
+// a backfill of the same range that was interrupted resumes where it stopped.
+func (a *Metrics) Backfill(fromEpoch uint64, toEpoch uint64) error {
+	if err := a.setup(); err != nil {
+		return err
+	}
+	defer a.clickHouse.Close()
+
+	// So that the responses of the finalized epochs are kept in the cache
+	if _, err := a.GetFinalizedEpoch(); err != nil {
+		log.Warn("Could not get the finalized epoch: ", err)
+	}
+
+	firstEpoch := fromEpoch
+	if a.db != nil && !a.config.BackfillRestart {
+		lastEpoch, found, err := a.db.GetBackfillProgress(fromEpoch, toEpoch)
+		if err != nil {
+			return errors.Wrap(err, "error getting backfill progress")
+		}
+		if found {
+			log.Info("Resuming backfill after epoch ", lastEpoch)
+			firstEpoch = lastEpoch + 1
+		}
+	}
+
+	var prevBeaconState *spec.VersionedBeaconState
+	for epoch := firstEpoch; epoch <= toEpoch; epoch++ {
+		log.WithField("Epoch", epoch).Info("Backfilling epoch")
+		currentBeaconState, err := a.ProcessEpoch(epoch, prevBeaconState)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("error processing epoch %d", epoch))
+		}
+		prevBeaconState = currentBeaconState
+
+		if a.db != nil {
+			if err := a.db.StoreBackfillProgress(fromEpoch, toEpoch, epoch); err != nil {
+				return errors.Wrap(err, "error storing backfill progress")
+			}
+		}
+	}
+	log.Info("Backfilled epochs ", fromEpoch, " to ", toEpoch)
+	return nil
+}
+
+// Creates the components used to process the epochs
+func (a *Metrics) setup() error {
+	// States on disk are only read again to backfill epochs, which also need
+	// the state of the epoch before
+	diskEpochs := a.config.BackfillEpochs
+	for _, backfillEpochs := range a.config.PoolBackfillEpochs {
+		diskEpochs = max(diskEpochs, backfillEpochs)
+	}
+	stateCache, err := NewStateCache(a.config.StateCacheSize, a.config.StateCacheDir, diskEpochs+1, a.config.CompactState)
+	if err != nil {
+		return errors.Wrap(err, "could not create state cache")
+	}
+	a.stateCache = stateCache
+
+	ch, err := publish.NewClickHouse(a.outputConfig())
+	if err != nil {
+		return errors.Wrap(err, "could not create clickhouse")
+	}
+	a.clickHouse = ch
+
 	bc, err := NewBeaconState(
 		a.httpClient,
 		a.networkParameters,
 		a.db,
+		a.clickHouse,
 		a.config,
 		a.networkParameters.slotsInEpoch,
+		stateCache,
 	)
 	if err != nil {
-		log.Fatal(err)
-		// TODO: Add return here.
+		return errors.Wrap(err, "could not create beacon state")
 	}
 	a.beaconState = bc
 
@@ -191,46 +518,121 @@ func (a *Metrics) Run() {
 		a.httpClient,
 		a.networkParameters,
 		a.db,
+		a.clickHouse,
 		a.config,
 	)
 
 	if err != nil {
-		log.Fatal(err)
+		return errors.Wrap(err, "could not create proposal duties")
 	}
+	pd.httpClient.Transport = a.beaconCache
 	a.proposalDuties = pd
 
-	rr, err := NewRelayRewards(a.networkParameters, a.validatorKeyToPool, a.config)
+	rr, err := NewRelayRewards(a.networkParameters, a.validatorKeyToPool, a.db, a.config)
 	if err != nil {
-		log.Fatal(err)
+		return errors.Wrap(err, "could not create relay rewards")
 	}
 	a.relayRewards = rr
 
-	ns, err := NewNetworkStats(a.db)
+	ns, err := NewNetworkStats(a.networkParameters, a.db)
 	if err != nil {
-		log.Fatal(err)
+		return errors.Wrap(err, "could not create network stats")
 	}
 	a.networkStats = ns
 
+	nq, err := NewNetworkQueues(a.httpClient, a.networkParameters, a.db)
+	if err != nil {
+		return errors.Wrap(err, "could not create network queues")
+	}
+	a.networkQueues = nq
+
+	eb, err := NewEpochBlocks(a.httpClient, a.networkParameters)
+	if err != nil {
+		return errors.Wrap(err, "could not create epoch blocks")
+	}
+	a.epochBlocks = eb
+
 	bd, err := NewBlockData(a.httpClient, a.executionClient, a.networkParameters, a.config)
 	if err != nil {
-		log.Fatal(err)
+		return errors.Wrap(err, "could not create block data")
 	}
 	a.blockData = bd
 
+	id, err := NewInclusionDelay(a.httpClient, a.networkParameters)
+	if err != nil {
+		return errors.Wrap(err, "could not create inclusion delay")
+	}
+	a.inclusionDelay = id
+
+	ar, err := NewAttestationRewards(a.httpClient, a.config)
+	if err != nil {
+		return errors.Wrap(err, "could not create attestation rewards")
+	}
+	a.attestationRewards = ar
+
+	sr, err := NewSyncCommitteeRewards(a.httpClient, a.config)
+	if err != nil {
+		return errors.Wrap(err, "could not create sync committee rewards")
+	}
+	a.syncCommitteeRewards = sr
+
+	pp, err := price.NewProvider(a.config)
+	if err != nil {
+		return errors.Wrap(err, "could not create price provider")
+	}
+	a.priceProvider = pp
+
+	pub, err := publish.NewPublisher(a.outputConfig())
+	if err != nil {
+		return errors.Wrap(err, "could not create publisher")
+	}
+	a.publisher = pub
+
+	sinks, err := publish.NewSinks(a.outputConfig())
+	if err != nil {
+		return errors.Wrap(err, "could not create sinks")
+	}
+	a.sinks = sinks
+
+	if err := a.setupTenantSinks(); err != nil {
+		return err
+	}
+
 	for _, poolName := range a.config.PoolNames {
 		// Check that the validator keys are correct
-		_, _, err := a.GetValidatorKeys(poolName)
-		if err != nil {
-			log.Fatal(err)
+		if _, _, err := a.GetValidatorKeys(poolName); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
+// Epochs processed long after they started, e.g. after an outage, get the
+// price sampled closest before their start if there is one, instead of the
+// current price
+func (a *Metrics) getEthPrice(epoch uint64) (float32, error) {
+	epochStart := a.networkParameters.Clock().EpochStart(epoch)
+	if a.db != nil && time.Since(epochStart) > ethPriceMaxAge {
+		ethPriceUsd, found, err := a.db.GetEthPriceAt(epochStart, ethPriceMaxAge)
+		if err != nil {
+			log.Warn("could not get the stored eth price: ", err)
+		} else if found {
+			return ethPriceUsd, nil
+		}
 	}
-	go a.Loop()
+	return a.priceProvider.GetPriceUsd()
 }
 
 func (a *Metrics) Loop() {
 	var prevEpoch uint64 = uint64(0)
 	var prevBeaconState *spec.VersionedBeaconState = nil
+	var lastFinalizedEpoch uint64 = uint64(0)
+	// Not reset by the backfilling, unlike prevEpoch
+	var lastProcessed uint64 = uint64(0)
+	// Logged once, since the distance to finality only changes without finality
+	warnedFollowDistance := false
+	// Grows while the requests or the processing keep failing
+	retry := NewBackoff(minRetryBackoff, maxRetryBackoff)
 	// TODO: Refactor and hoist some stuff out to a function
 	for {
 		// Before doing anything, check if we are in the next epoch
@@ -242,48 +644,107 @@ func (a *Metrics) Loop() {
 		headSlot, err := a.httpClient.NodeSyncing(context.Background(), &opts)
 		if err != nil {
 			log.Error("Could not get node sync status:", err)
-			time.Sleep(5 * time.Second)
+			time.Sleep(retry.Next())
 			continue
 		}
 
 		if headSlot.Data.IsSyncing {
 			log.Error("Node is not in sync")
-			time.Sleep(5 * time.Second)
+			time.Sleep(retry.Next())
 			continue
 		}
 
-		// Leave some maring of 2 epochs
-		currentEpoch := uint64(headSlot.Data.HeadSlot)/uint64(a.networkParameters.slotsInEpoch) - 2
+		headEpochUint64 := uint64(headSlot.Data.HeadSlot) / uint64(a.networkParameters.slotsInEpoch)
+		headEpoch.Set(float64(headEpochUint64))
+		if lastProcessed > 0 {
+			processingLag.Set(float64(headEpochUint64) - float64(lastProcessed))
+		}
 
-		// If a debug epoch is set, overwrite the slot. Will compute just metrics for that epoch
-		if a.config.EpochDebug != "" {
-			epochDebugUint64, err := strconv.ParseUint(a.config.EpochDebug, 10, 64)
+		// Leave some margin of epochs to the head
+		if headEpochUint64 < a.config.FollowDistance {
+			time.Sleep(pollWait())
+			continue
+		}
+		currentEpoch := headEpochUint64 - a.config.FollowDistance
+
+		if a.config.FinalizedOnly {
+			finalizedEpoch, err := a.GetFinalizedEpoch()
 			if err != nil {
-				log.Fatal(err)
+				log.Error(err)
+				time.Sleep(retry.Next())
+				continue
+			}
+			// Nothing is final yet, e.g. right after genesis
+			if finalizedEpoch == 0 {
+				time.Sleep(pollWait())
+				continue
 			}
-			log.Warn("Debugging mode, calculating metrics for epoch: ", a.config.EpochDebug)
-			currentEpoch = epochDebugUint64
+			// The finalized checkpoint is the first slot of the epoch, so the
+			// previous epoch is the last one that can't change
+			currentEpoch = finalizedEpoch - 1
 		}
 
 		if prevEpoch >= currentEpoch {
-			// do nothing
-			time.Sleep(5 * time.Second)
+			// Up to date, so the node is healthy
+			retry.Reset()
+			time.Sleep(pollWait())
 			continue
 		}
 
-		missingEpochs, err := a.db.GetMissingEpochs(currentEpoch, a.config.BackfillEpochs)
+		// Pick up the validators added to the key files without restarting
+		reloaded, err := a.ReloadValidatorKeys()
+		if err != nil {
+			log.Error("Could not reload validator keys, keeping the previous ones: ", err)
+		}
+		added := a.AddSourceValidators()
+		changed, err := a.UpdatePoolMembership(currentEpoch)
+		if err != nil {
+			log.Error(err)
+		}
+		added = added || changed
+		// The light state of the previous epoch lacks the new validators
+		if (reloaded || added) && a.config.LightMode {
+			prevBeaconState = nil
+		}
+
+		// Correct the epochs that changed due to a reorg before being finalized
+		if !a.config.FinalizedOnly && a.db != nil {
+			lastFinalizedEpoch, err = a.CheckReorgs(lastFinalizedEpoch)
+			if err != nil {
+				log.Error(err)
+			}
+			// With more distance than finality there is no data to gain over
+			// --finalized-only, which processes the epochs as soon as they are final
+			if !warnedFollowDistance && lastFinalizedEpoch > 0 && currentEpoch+1 < lastFinalizedEpoch {
+				log.Warn("Epochs are already finalized ", lastFinalizedEpoch-1-currentEpoch,
+					" epochs before being processed, use a lower --follow-distance or --finalized-only to get them sooner")
+				warnedFollowDistance = true
+			}
+		} else if !a.config.FinalizedOnly && a.config.StateCacheDir != "" {
+			// So that the states of the finalized epochs are written to disk
+			if _, err := a.GetFinalizedEpoch(); err != nil {
+				log.Warn("Could not get the finalized epoch: ", err)
+			}
+		}
+
+		missingEpochs, err := a.getMissingEpochs(currentEpoch)
 		if err != nil {
 			log.Error(err)
-			time.Sleep(5 * time.Second)
+			time.Sleep(retry.Next())
 			continue
 		}
 
-		if len(missingEpochs) > 0 {
-			log.Info("Backfilling epochs: ", missingEpochs)
+		epochs := make([]uint64, 0, len(missingEpochs))
+		for epoch := range missingEpochs {
+			epochs = append(epochs, epoch)
+		}
+		slices.Sort(epochs)
+		if len(epochs) > 0 {
+			log.Info("Backfilling epochs: ", epochs)
 		}
 
-		// Do backfilling.
-		for _, epoch := range missingEpochs {
+		// Do backfilling, only of the pools that lack each epoch
+		for _, epoch := range epochs {
 			if prevBeaconState != nil {
 				prevSlot, err := prevBeaconState.Slot()
 				prevEpoch = uint64(prevSlot) % a.networkParameters.slotsInEpoch
@@ -295,132 +756,1017 @@ func (a *Metrics) Loop() {
 					prevBeaconState = nil
 				}
 			}
-			currentBeaconState, err := a.ProcessEpoch(epoch, prevBeaconState)
+			currentBeaconState, _, err := a.processEpoch(context.Background(), epoch, prevBeaconState, missingEpochs[epoch])
 			if err != nil {
 				log.Error(err)
-				time.Sleep(5 * time.Second)
+				time.Sleep(retry.Next())
 				continue
 			}
 			prevBeaconState = currentBeaconState
 		}
 
+		a.retryFailedPools()
+
 		currentBeaconState, err := a.ProcessEpoch(currentEpoch, prevBeaconState)
 		if err != nil {
 			log.Error(err)
-			time.Sleep(5 * time.Second)
+			time.Sleep(retry.Next())
 			continue
 		}
+		retry.Reset()
 
 		prevBeaconState = currentBeaconState
 		prevEpoch = currentEpoch
+		lastProcessed = currentEpoch
+		lastProcessedEpoch.Set(float64(lastProcessed))
+		processingLag.Set(float64(headEpochUint64) - float64(lastProcessed))
 
-		if a.config.EpochDebug != "" {
-			log.Warn("Running in debug mode, exiting ok.")
-			os.Exit(0)
+		if a.db != nil && a.config.RetentionEpochs > 0 && currentEpoch > a.config.RetentionEpochs {
+			cutoff := currentEpoch - a.config.RetentionEpochs
+			if err := a.db.PruneEpochs(cutoff, cutoff*a.networkParameters.slotsInEpoch); err != nil {
+				log.WithField("Epoch", cutoff).Error("Could not prune old epochs: ", err)
+			}
 		}
 	}
 }
 
+// Returns the epochs that lack some of the pools, with the pools that lack
+// them. Each pool is backfilled up to its --pool-backfill-epochs if set, so
+// that a pool added later gets its history and not only the next epochs.
+func (a *Metrics) getMissingEpochs(currentEpoch uint64) (map[uint64][]string, error) {
+	if a.db == nil {
+		return map[uint64][]string{}, nil
+	}
+	poolsPerDepth := make(map[uint64][]string)
+	for _, poolName := range a.poolNames() {
+		depth, ok := a.config.PoolBackfillEpochs[poolName]
+		if !ok {
+			depth = a.config.BackfillEpochs
+		}
+		poolsPerDepth[depth] = append(poolsPerDepth[depth], poolName)
+	}
+
+	missingEpochs := make(map[uint64][]string)
+	for depth, poolNames := range poolsPerDepth {
+		poolsMissingEpochs, err := a.db.GetMissingEpochs(currentEpoch, depth, poolNames)
+		if err != nil {
+			return nil, err
+		}
+
+		missingPerPool := make(map[string]uint64)
+		for epoch, missingPools := range poolsMissingEpochs {
+			missingEpochs[epoch] = append(missingEpochs[epoch], missingPools...)
+			for _, poolName := range missingPools {
+				missingPerPool[poolName]++
+			}
+		}
+		for poolName, missing := range missingPerPool {
+			if missing == depth && depth > 1 {
+				log.WithField("PoolName", poolName).Info("Backfilling the last ", depth, " epochs of a new pool")
+			}
+		}
+	}
+	for epoch := range missingEpochs {
+		slices.Sort(missingEpochs[epoch])
+	}
+	return missingEpochs, nil
+}
+
+// Processes again the pools that failed in previous passes, each one up to
+// maxPoolAttempts times
+func (a *Metrics) retryFailedPools() {
+	if a.db == nil {
+		return
+	}
+	failedPools, err := a.db.GetFailedPools(maxPoolAttempts)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	epochs := make([]uint64, 0, len(failedPools))
+	for epoch := range failedPools {
+		epochs = append(epochs, epoch)
+	}
+	slices.Sort(epochs)
+	for _, epoch := range epochs {
+		log.WithField("Epoch", epoch).Info("Retrying failed pools: ", failedPools[epoch])
+		if _, _, err := a.processEpoch(context.Background(), epoch, nil, failedPools[epoch]); err != nil {
+			log.WithField("Epoch", epoch).Error("Could not retry failed pools: ", err)
+		}
+	}
+}
+
+func (a *Metrics) Alerts() *alerts.Alerts {
+	return a.alerts
+}
+
+// Epoch of the wall clock, which may not be processed yet
+func (a *Metrics) CurrentEpoch() uint64 {
+	return a.networkParameters.Clock().CurrentEpoch()
+}
+
+// Returns the rows of the pool in the table between the given epochs, both
+// included. Read outside the transaction of the epoch being processed.
+func (a *Metrics) PoolRows(
+	table string,
+	fromEpoch uint64,
+	toEpoch uint64,
+	poolName string) ([]string, [][]interface{}, error) {
+
+	if a.db == nil {
+		return nil, nil, errors.New("no database to read the rows from")
+	}
+	return a.db.Detached().GetEpochRows(table, fromEpoch, toEpoch, poolName)
+}
+
 func (a *Metrics) ProcessEpoch(
 	currentEpoch uint64,
 	prevBeaconState *spec.VersionedBeaconState) (*spec.VersionedBeaconState, error) {
-	// Fetch proposal duties, meaning who shall propose each block within this epoch
-	duties, err := a.proposalDuties.GetProposalDuties(currentEpoch)
-	if err != nil {
-		return nil, errors.Wrap(err, "error getting proposal duties")
+	currentBeaconState, _, err := a.processEpoch(context.Background(), currentEpoch, prevBeaconState, nil)
+	return currentBeaconState, err
+}
+
+// Processes the epoch only for the given pools, or for all of them if nil, and
+// returns the metrics of each pool
+func (a *Metrics) processEpoch(
+	ctx context.Context,
+	currentEpoch uint64,
+	prevBeaconState *spec.VersionedBeaconState,
+	poolNames []string) (*spec.VersionedBeaconState, *EpochResult, error) {
+	processingMode := schemas.ProcessingModeHead
+	if a.config.FinalizedOnly {
+		processingMode = schemas.ProcessingModeFinalized
 	}
+	return a.processEpochInMode(ctx, currentEpoch, prevBeaconState, poolNames, processingMode)
+}
 
-	// Fetch who actually proposed the blocks in this epoch
-	proposed, err := a.proposalDuties.GetProposedBlocks(currentEpoch)
-	if err != nil {
-		return nil, errors.Wrap(err, "error getting proposed blocks")
+// Like processEpoch, storing the rows with the given processing mode, e.g.
+// finalized when an epoch is processed again once it is final
+func (a *Metrics) processEpochInMode(
+	ctx context.Context,
+	currentEpoch uint64,
+	prevBeaconState *spec.VersionedBeaconState,
+	poolNames []string,
+	processingMode schemas.ProcessingMode) (*spec.VersionedBeaconState, *EpochResult, error) {
+	defer observeDuration(epochProcessingDuration, time.Now())
+
+	data := &epochData{
+		ctx:                 ctx,
+		epoch:               currentEpoch,
+		processingMode:      processingMode,
+		prevBeaconState:     prevBeaconState,
+		relayRewardsPerPool: make(map[string]*big.Int),
+		slotsWithMEVRewards: make(map[uint64]schemas.RelayPayload),
+		epochBlockData: &EpochBlockData{
+			Withdrawals:       make(map[uint64]*big.Int),
+			ProposerTips:      make(map[uint64]*big.Int),
+			SlotTips:          make(map[uint64]*big.Int),
+			FeeRecipients:     make(map[uint64]string),
+			SlotFeeRecipients: make(map[uint64]string),
+			Blobs:             make(map[uint64]schemas.BlockBlobs),
+		},
+		inclusionDelays:      make(map[uint64]uint64),
+		attestationRewards:   &v1.AttestationRewards{},
+		syncCommitteeRewards: make(map[uint64]*big.Int),
+		blockRewards:         make(map[uint64]*v1.BlockRewards),
+		proposerRewards:      make(map[uint64]*big.Int),
+	}
+	if err := a.runEpochModules(a.epochModules(), data); err != nil {
+		return nil, nil, err
+	}
+
+	// The rows of the epoch are stored all or none, so that an epoch that
+	// fails or is interrupted is processed again instead of kept incomplete
+	if a.db != nil {
+		if err := a.db.BeginEpoch(); err != nil {
+			return nil, nil, err
+		}
+		defer a.db.RollbackEpoch()
+	}
+	currentBeaconState := data.currentBeaconState
+	prevBeaconState = data.prevBeaconState
+	epochBlockData := data.epochBlockData
+
+	a.AddValidatorsByFeeRecipient(currentBeaconState, epochBlockData.FeeRecipients, data.slotsWithMEVRewards)
+
+	// Validators added or removed at runtime are only in the pools of the
+	// epochs they were members in
+	poolKeys := a.poolKeysAt(currentEpoch)
+	poolValidatorIndexes := make(map[string][]uint64)
+	validatorIndexToPool := make(map[uint64]string)
+	for poolName, pubKeys := range poolKeys {
+		poolValidatorIndexes[poolName] = GetIndexesFromKeys(pubKeys, data.valKeyToIndex)
+		for _, valIdx := range poolValidatorIndexes[poolName] {
+			validatorIndexToPool[valIdx] = poolName
+		}
+	}
+	a.setForecastIndexes(validatorIndexToPool)
+	if err := a.checkTrackedKeys(currentEpoch, poolKeys, data.valKeyToIndex, GetValidators(currentBeaconState)); err != nil {
+		return nil, nil, errors.Wrap(err, "error checking tracked keys")
 	}
 
-	// Summarize duties + proposed in a struct
-	proposalMetrics, err := a.proposalDuties.GetProposalMetrics(duties, proposed)
+	// The price is not critical, metrics are stored without it if not available
+	ethPriceUsd, err := a.getEthPrice(currentEpoch)
 	if err != nil {
-		return nil, errors.Wrap(err, "error getting proposal metrics")
+		log.Warn("could not get eth price: ", err)
 	}
 
-	currentBeaconState, err := a.beaconState.GetBeaconState(currentEpoch)
+	nOfSlashedPerPool, err := a.storeSlashings(epochBlockData.Slashings, validatorIndexToPool)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error storing slashings")
+	}
+	exits, err := a.storeVoluntaryExits(epochBlockData.Exits, validatorIndexToPool)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error storing voluntary exits")
+	}
+	blsChanges, err := a.storeBLSChanges(epochBlockData.BLSChanges, validatorIndexToPool)
 	if err != nil {
-		return nil, errors.Wrap(err, "error fetching beacon state")
+		return nil, nil, errors.Wrap(err, "error storing bls changes")
+	}
+	if err := a.storeDeposits(epochBlockData.Deposits); err != nil {
+		return nil, nil, errors.Wrap(err, "error storing deposits")
+	}
+	withdrawalRequests, err := a.storeWithdrawalRequests(epochBlockData.WithdrawalRequests)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error storing withdrawal requests")
+	}
+	consolidationRequests, err := a.storeConsolidationRequests(epochBlockData.ConsolidationRequests)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error storing consolidation requests")
+	}
+	for _, alert := range GetDoppelgangerAlerts(currentEpoch, data.conflictingVotes, epochBlockData.Slashings, validatorIndexToPool) {
+		a.sendAlert(alert)
+	}
+	if a.config.AlertExits {
+		for _, alert := range GetExitAlerts(exits, blsChanges) {
+			a.sendAlert(alert)
+		}
+		for _, alert := range GetExecutionRequestAlerts(withdrawalRequests, consolidationRequests) {
+			a.sendAlert(alert)
+		}
+	}
+
+	data.networkEffectiveness = a.getNetworkEffectiveness(data)
+	if data.networkEffectiveness != nil && a.db != nil {
+		if err := a.db.StoreEffectiveness(*data.networkEffectiveness); err != nil {
+			return nil, nil, errors.Wrap(err, "could not store network effectiveness")
+		}
+	}
+
+	result := &EpochResult{
+		Epoch:  currentEpoch,
+		Pools:  make(map[string]*PoolResult),
+		Errors: make(map[string]error),
 	}
 
-	// if no prev beacon state is known, fetch it
-	if prevBeaconState == nil {
-		prevBeaconState, err = a.beaconState.GetBeaconState(currentEpoch - 1)
+	// Iterate all pools and calculate metrics using the fetched data. A pool
+	// that fails doesn't stop the others, and is retried in the next pass
+	for poolName, pubKeys := range poolKeys {
+		if poolNames != nil && !slices.Contains(poolNames, poolName) {
+			continue
+		}
+		status := schemas.ProcessingStatus{Epoch: currentEpoch, PoolName: poolName}
+		if a.db != nil {
+			if err := a.db.BeginPool(); err != nil {
+				return nil, nil, err
+			}
+		}
+		poolResult, err := a.processPool(data, poolName, pubKeys, poolValidatorIndexes[poolName], nOfSlashedPerPool[poolName], ethPriceUsd)
 		if err != nil {
-			return nil, errors.Wrap(err, "error fetching previous beacon state")
+			log.WithFields(log.Fields{"Epoch": currentEpoch, "PoolName": poolName}).Error("Could not process pool: ", err)
+			status.Error = err.Error()
+			result.Errors[poolName] = err
+		} else {
+			result.Pools[poolName] = poolResult
+		}
+		// The rows written before the pool failed would be rolled up and
+		// counted as if the epoch was processed
+		if a.db != nil {
+			if err == nil {
+				err = a.db.ReleasePool()
+			} else {
+				err = a.db.RollbackPool()
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		if a.db != nil {
+			if err := a.db.StoreProcessingStatus(status); err != nil {
+				log.WithField("PoolName", poolName).Error("Could not store processing status: ", err)
+			}
 		}
+		if a.db != nil && status.Error == "" {
+			modules := append([]string{db.ModuleSummary}, data.completedModules...)
+			if err := a.db.StoreEpochProcessed(currentEpoch, poolName, modules); err != nil {
+				return nil, nil, errors.Wrap(err, "could not store processed epoch")
+			}
+		}
+	}
+
+	if err := a.rollUpPoolGroups(currentEpoch); err != nil {
+		return nil, nil, errors.Wrap(err, "error rolling up pool groups")
+	}
+	if err := a.updatePoolSLA(currentEpoch); err != nil {
+		return nil, nil, errors.Wrap(err, "error updating pool sla")
 	}
 
-	// Map to quickly convert public keys to index
-	valKeyToIndex := PopulateKeysToIndexesMap(currentBeaconState)
+	if a.db != nil {
+		err = a.db.StoreEpochBlockRoots(currentEpoch, GetBlockRoots(data.proposed))
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "could not store epoch block roots")
+		}
+		if err := a.db.CommitEpoch(); err != nil {
+			return nil, nil, err
+		}
+	}
 
-	processedConsolidations, err := GetProcessedConsolidations(prevBeaconState, currentBeaconState)
-	if err != nil {
-		return nil, errors.Wrap(err, "error getting processed consolidations")
+	if a.publisher.Enabled() || a.sinks.Enabled() || len(a.tenantSinks) > 0 {
+		events := make([]publish.Event, 0, len(result.Pools))
+		for _, poolResult := range result.Pools {
+			events = append(events, publish.NewEvent(poolResult.Performance, poolResult.Proposals))
+		}
+		epochTime := a.networkParameters.Clock().EpochStart(currentEpoch)
+		a.publisher.Publish(events)
+		a.sinks.Write(epochTime, events)
+		a.writeTenantSinks(epochTime, events)
 	}
+	a.clickHouse.Flush()
 
-	relayRewardsPerPool, slotsWithMEVRewards, err := a.relayRewards.GetRelayRewards(currentEpoch)
+	if a.config.DryRun {
+		if err := WriteEpochResult(a.dryRunOutput, result); err != nil {
+			return nil, nil, errors.Wrap(err, "could not print epoch metrics")
+		}
+	}
+
+	return currentBeaconState, result, nil
+}
+
+// Calculates and stores the metrics of a pool using the data of the epoch
+func (a *Metrics) processPool(
+	data *epochData,
+	poolName string,
+	pubKeys [][]byte,
+	validatorIndexes []uint64,
+	nOfSlashedValidators uint64,
+	ethPriceUsd float32) (*PoolResult, error) {
+
+	currentEpoch := data.epoch
+	currentBeaconState := data.currentBeaconState
+	prevBeaconState := data.prevBeaconState
+	epochBlockData := data.epochBlockData
+
+	relayRewards := big.NewInt(0)
+	if reward, ok := data.relayRewardsPerPool[poolName]; ok {
+		relayRewards.Add(relayRewards, reward)
+	}
+	poolMetrics, err := a.beaconState.Run(
+		pubKeys,
+		poolName,
+		currentBeaconState,
+		prevBeaconState,
+		data.valKeyToIndex,
+		relayRewards,
+		data.mevPartial,
+		epochBlockData.Withdrawals,
+		epochBlockData.ProposerTips,
+		data.processedConsolidations,
+		data.depositBalanceChanges,
+		data.syncCommitteeRewards,
+		data.proposerRewards,
+		data.inclusionDelays,
+		nOfSlashedValidators,
+		data.attestationRewards,
+		ethPriceUsd,
+		data.processingMode,
+	)
 	if err != nil {
-		return nil, errors.Wrap(err, "error getting relay rewards")
+		return nil, errors.Wrap(err, "error running beacon state")
+	}
+
+	validatorStatus := GetValidatorStatus(currentEpoch, poolName, validatorIndexes, GetValidators(currentBeaconState))
+	logValidatorStatus(validatorStatus)
+	if a.db != nil {
+		err = a.db.StoreValidatorStatus(validatorStatus)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not store validator status")
+		}
+	}
+
+	pendingPartialWithdrawals, partialWithdrawals := GetPoolPartialWithdrawals(
+		currentEpoch,
+		poolName,
+		validatorIndexes,
+		currentBeaconState,
+		data.processedPartialWithdrawals,
+		epochBlockData.Withdrawals)
+	logPartialWithdrawals(pendingPartialWithdrawals, partialWithdrawals)
+	if a.db != nil {
+		if err := a.db.StorePendingPartialWithdrawals(pendingPartialWithdrawals); err != nil {
+			return nil, errors.Wrap(err, "could not store pending partial withdrawals")
+		}
+		for _, partialWithdrawal := range partialWithdrawals {
+			if err := a.db.StorePartialWithdrawal(partialWithdrawal); err != nil {
+				return nil, errors.Wrap(err, "could not store partial withdrawal")
+			}
+		}
+	}
+
+	// Deposits to the validators activated in the epoch get the time they took
+	if a.db != nil {
+		activationTime := a.networkParameters.epochTime(currentEpoch)
+		for _, pubkey := range GetActivatedKeys(currentEpoch, validatorIndexes, GetValidators(currentBeaconState)) {
+			err = a.db.StoreDepositActivation(pubkey, currentEpoch, activationTime)
+			if err != nil {
+				return nil, errors.Wrap(err, "could not store deposit activation")
+			}
+		}
 	}
 
-	// Get withdrawals and proposer tips from all blocks of the epoch
-	epochBlockData, err := a.blockData.GetEpochBlockData(currentEpoch, slotsWithMEVRewards)
+	poolProposals, err := a.proposalDuties.RunProposalMetrics(validatorIndexes, poolName, &data.proposalMetrics, data.missedReasons, data.slotsWithMEVRewards, epochBlockData.SlotFeeRecipients)
 	if err != nil {
-		return nil, errors.Wrap(err, "error getting epoch block data")
+		return nil, errors.Wrap(err, "error running proposal metrics")
+	}
+
+	if data.blockClients != nil && a.db != nil {
+		for _, diversity := range GetClientDiversity(currentEpoch, poolName, poolProposals.Proposed, data.blockClients) {
+			err = a.db.StoreClientDiversity(diversity)
+			if err != nil {
+				return nil, errors.Wrap(err, "could not store client diversity")
+			}
+		}
 	}
-	validatorIndexToWithdrawalAmount := epochBlockData.Withdrawals
-	proposerTips := epochBlockData.ProposerTips
 
-	err = a.networkStats.Run(currentEpoch, currentBeaconState)
+	err = a.proposalDuties.RunProposals(poolName, poolProposals, data.slotsWithMEVRewards, epochBlockData.SlotTips, epochBlockData.SlotFeeRecipients, data.blockRewards)
 	if err != nil {
-		return nil, errors.Wrap(err, "error getting network stats")
+		return nil, errors.Wrap(err, "error running proposals")
 	}
 
-	// Iterate all pools and calculate metrics using the fetched data
-	for poolName, pubKeys := range a.validatorKeysPerPool {
-		validatorIndexes := GetIndexesFromKeys(pubKeys, valKeyToIndex)
+	feeRecipientViolations := GetFeeRecipientViolations(
+		currentEpoch,
+		poolName,
+		poolProposals.Proposed,
+		data.slotsWithMEVRewards,
+		epochBlockData.SlotFeeRecipients,
+		a.feeRecipients[poolName])
+	if a.db != nil {
+		for _, violation := range feeRecipientViolations {
+			err = a.db.StoreFeeRecipientViolation(violation)
+			if err != nil {
+				return nil, errors.Wrap(err, "could not store fee recipient violation")
+			}
+		}
+	}
 
-		relayRewards := big.NewInt(0)
-		if reward, ok := relayRewardsPerPool[poolName]; ok {
-			relayRewards.Add(relayRewards, reward)
+	poolBlobs := GetPoolBlobs(poolName, poolProposals.Proposed, epochBlockData.Blobs)
+	if a.db != nil {
+		for _, blobs := range poolBlobs {
+			err = a.db.StoreBlockBlobs(blobs)
+			if err != nil {
+				return nil, errors.Wrap(err, "could not store block blobs")
+			}
 		}
-		err = a.beaconState.Run(
-			pubKeys,
-			poolName,
-			currentBeaconState,
-			prevBeaconState,
-			valKeyToIndex,
-			relayRewards,
-			validatorIndexToWithdrawalAmount,
-			proposerTips,
-			processedConsolidations,
-		)
-		if err != nil {
-			return nil, errors.Wrap(err, "error running beacon state")
+	}
+
+	proposalTimings := GetProposalTimings(
+		currentEpoch,
+		poolName,
+		poolProposals.Proposed,
+		a.blockArrivals,
+		a.networkParameters.Clock(),
+		a.config.LateBlockMs)
+	logProposalTimings(proposalTimings)
+	if a.db != nil {
+		for _, timing := range proposalTimings {
+			if err := a.db.StoreProposalTiming(timing); err != nil {
+				return nil, errors.Wrap(err, "could not store proposal timing")
+			}
+		}
+	}
+
+	vanillaBlocks := make([]schemas.Duty, 0)
+	for _, duty := range poolProposals.Proposed {
+		if IsVanillaBlock(duty.Slot, poolName, data.slotsWithMEVRewards, epochBlockData.SlotFeeRecipients, a.proposalDuties.addressToPool) {
+			vanillaBlocks = append(vanillaBlocks, duty)
+		}
+	}
+	missedMEV := GetMissedMEV(currentEpoch, poolName, vanillaBlocks, data.bestBids, epochBlockData.SlotTips)
+	logMissedMEV(missedMEV)
+	if a.db != nil {
+		for _, missed := range missedMEV {
+			if err := a.db.StoreMissedMEV(missed); err != nil {
+				return nil, errors.Wrap(err, "could not store missed mev")
+			}
+		}
+	}
+
+	mevPayouts := GetMEVPayouts(
+		currentEpoch,
+		poolName,
+		poolProposals.Proposed,
+		data.slotsWithMEVRewards,
+		epochBlockData.MEVPayouts)
+	logMEVPayouts(mevPayouts)
+	if a.db != nil {
+		for _, payout := range mevPayouts {
+			if err := a.db.StoreMEVPayout(payout); err != nil {
+				return nil, errors.Wrap(err, "could not store mev payout")
+			}
+		}
+	}
+
+	effectiveness := a.getPoolEffectiveness(data, poolName, validatorIndexes, &poolMetrics, poolProposals)
+	if a.db != nil {
+		if err := a.db.StoreEffectiveness(effectiveness); err != nil {
+			return nil, errors.Wrap(err, "could not store effectiveness")
 		}
+	}
 
-		err = a.proposalDuties.RunProposalMetrics(validatorIndexes, poolName, &proposalMetrics)
+	maintenance := a.inMaintenance(poolName, currentEpoch)
+	if a.db != nil {
+		if err := a.db.TagMaintenance(currentEpoch, poolName, maintenance); err != nil {
+			return nil, errors.Wrap(err, "could not tag maintenance")
+		}
+	}
+
+	slashedIndexes := GetSlashedIndexes(validatorIndexes, prevBeaconState, currentBeaconState)
+	a.checkAlerts(poolName, currentEpoch, maintenance, &poolMetrics, poolProposals, slashedIndexes, feeRecipientViolations, mevPayouts, proposalTimings)
+	return &PoolResult{
+		Performance:            poolMetrics,
+		Proposals:              *poolProposals,
+		RelayRewards:           relayRewards,
+		ValidatorStatus:        validatorStatus,
+		FeeRecipientViolations: feeRecipientViolations,
+		Blobs:                  poolBlobs,
+		MEVPayouts:             mevPayouts,
+		MissedMEV:              missedMEV,
+		ProposalTimings:        proposalTimings,
+		Effectiveness:          effectiveness,
+	}, nil
+}
+
+// Rolls up the metrics of the pools of each group in the epoch. Groups are
+// rolled up from the stored rows, so a group whose pools were processed in
+// different passes, e.g. when a failed pool is retried, is rolled up once the
+// last one is stored.
+func (a *Metrics) rollUpPoolGroups(epoch uint64) error {
+	if a.db == nil {
+		return nil
+	}
+	for _, group := range a.poolGroups {
+		if _, isPool := a.validatorKeysPerPool[group.Name]; isPool {
+			log.WithField("PoolGroup", group.Name).Warn("Pool group with the name of a pool, skipping it")
+			continue
+		}
+		stored, err := a.db.StorePoolGroupMetrics(epoch, group)
 		if err != nil {
-			return nil, errors.Wrap(err, "error running proposal metrics")
+			return errors.Wrap(err, "could not store metrics of pool group "+group.Name)
+		}
+		if !stored {
+			log.WithFields(log.Fields{"Epoch": epoch, "PoolGroup": group.Name}).Debug("Not all the pools of the group were processed, not rolling it up")
+		}
+	}
+	return nil
+}
+
+// Fetches the full beacon state of the epoch, or only the tracked validators
+// when running in light mode
+func (a *Metrics) getBeaconState(ctx context.Context, epoch uint64) (*spec.VersionedBeaconState, error) {
+	if !a.config.LightMode {
+		defer observeDuration(beaconStateDuration.WithLabelValues("full"), time.Now())
+		return a.beaconState.GetBeaconState(ctx, epoch)
+	}
+	defer observeDuration(beaconStateDuration.WithLabelValues("light"), time.Now())
+	validatorKeys := make([][]byte, 0)
+	for _, pubKeys := range a.poolKeysAt(epoch) {
+		validatorKeys = append(validatorKeys, pubKeys...)
+	}
+	return a.beaconState.GetLightBeaconState(ctx, epoch, validatorKeys)
+}
+
+// Attributes the slashings included in the epoch blocks to the pools and stores
+// them. Slashings of untracked validators are stored without pool. Returns the
+// number of slashed validators per pool.
+func (a *Metrics) storeSlashings(
+	slashings []schemas.Slashing,
+	validatorIndexToPool map[uint64]string) (map[string]uint64, error) {
+
+	nOfSlashedPerPool := make(map[string]uint64)
+	for _, slashing := range slashings {
+		slashing.PoolName = validatorIndexToPool[slashing.ValIndex]
+		if slashing.PoolName != "" {
+			nOfSlashedPerPool[slashing.PoolName]++
+		}
+
+		log.WithFields(log.Fields{
+			"Epoch":        slashing.Epoch,
+			"Slot":         slashing.Slot,
+			"ValIndex":     slashing.ValIndex,
+			"PoolName":     slashing.PoolName,
+			"SlashingType": slashing.SlashingType,
+		}).Warn("Slashing included in block")
+
+		if a.db != nil {
+			if err := a.db.StoreSlashing(slashing); err != nil {
+				return nil, errors.Wrap(err, "could not store slashing")
+			}
+		}
+	}
+	return nOfSlashedPerPool, nil
+}
+
+// Attributes the voluntary exits included in the epoch blocks to the pools and
+// stores them. Exits of untracked validators are stored without pool. Returns
+// the exits of the tracked validators.
+func (a *Metrics) storeVoluntaryExits(
+	exits []schemas.VoluntaryExit,
+	validatorIndexToPool map[uint64]string) ([]schemas.VoluntaryExit, error) {
+
+	trackedExits := make([]schemas.VoluntaryExit, 0)
+	for _, exit := range exits {
+		exit.PoolName = validatorIndexToPool[exit.ValIndex]
+		if exit.PoolName != "" {
+			trackedExits = append(trackedExits, exit)
+			log.WithFields(log.Fields{
+				"Epoch":    exit.Epoch,
+				"Slot":     exit.Slot,
+				"ValIndex": exit.ValIndex,
+				"PoolName": exit.PoolName,
+			}).Warn("Voluntary exit included in block")
+		}
+
+		if a.db != nil {
+			if err := a.db.StoreVoluntaryExit(exit); err != nil {
+				return nil, errors.Wrap(err, "could not store voluntary exit")
+			}
+		}
+	}
+	return trackedExits, nil
+}
+
+// Attributes the BLS to execution changes included in the epoch blocks to the
+// pools and stores them. Changes of untracked validators are stored without
+// pool. Returns the changes of the tracked validators.
+func (a *Metrics) storeBLSChanges(
+	changes []schemas.BLSChange,
+	validatorIndexToPool map[uint64]string) ([]schemas.BLSChange, error) {
+
+	trackedChanges := make([]schemas.BLSChange, 0)
+	for _, change := range changes {
+		change.PoolName = validatorIndexToPool[change.ValIndex]
+		if change.PoolName != "" {
+			trackedChanges = append(trackedChanges, change)
+			log.WithFields(log.Fields{
+				"Epoch":            change.Epoch,
+				"Slot":             change.Slot,
+				"ValIndex":         change.ValIndex,
+				"PoolName":         change.PoolName,
+				"ExecutionAddress": change.ExecutionAddress,
+			}).Warn("BLS to execution change included in block")
+		}
+
+		if a.db != nil {
+			if err := a.db.StoreBLSChange(change); err != nil {
+				return nil, errors.Wrap(err, "could not store bls change")
+			}
+		}
+	}
+	return trackedChanges, nil
+}
+
+// Stores the deposits of the epoch blocks to tracked validators with their pool
+func (a *Metrics) storeDeposits(deposits []schemas.Deposit) error {
+	for _, deposit := range deposits {
+		deposit.PoolName = a.validatorKeyToPool[deposit.Pubkey]
+		if deposit.PoolName == "" {
+			continue
+		}
+		log.WithFields(log.Fields{
+			"Epoch":    deposit.Epoch,
+			"Slot":     deposit.Slot,
+			"Pubkey":   deposit.Pubkey,
+			"PoolName": deposit.PoolName,
+			"Amount":   deposit.Amount,
+		}).Info("Deposit included in block")
+
+		if a.db != nil {
+			if err := a.db.StoreDeposit(deposit); err != nil {
+				return errors.Wrap(err, "could not store deposit")
+			}
+		}
+	}
+	return nil
+}
+
+// Stores the withdrawal requests of the epoch blocks to tracked validators with
+// their pool, and returns them
+func (a *Metrics) storeWithdrawalRequests(requests []schemas.WithdrawalRequest) ([]schemas.WithdrawalRequest, error) {
+	trackedRequests := make([]schemas.WithdrawalRequest, 0)
+	for _, request := range requests {
+		request.PoolName = a.validatorKeyToPool[request.Pubkey]
+		if request.PoolName == "" {
+			continue
+		}
+		trackedRequests = append(trackedRequests, request)
+		log.WithFields(log.Fields{
+			"Epoch":         request.Epoch,
+			"Slot":          request.Slot,
+			"Pubkey":        request.Pubkey,
+			"PoolName":      request.PoolName,
+			"SourceAddress": request.SourceAddress,
+			"Amount":        request.Amount,
+		}).Warn("Withdrawal request included in block")
+
+		if a.db != nil {
+			if err := a.db.StoreWithdrawalRequest(request); err != nil {
+				return nil, errors.Wrap(err, "could not store withdrawal request")
+			}
+		}
+	}
+	return trackedRequests, nil
+}
+
+// Stores the consolidation requests of the epoch blocks from or to tracked
+// validators, with the pool of the source or else of the target, and returns
+// them
+func (a *Metrics) storeConsolidationRequests(requests []schemas.ConsolidationRequest) ([]schemas.ConsolidationRequest, error) {
+	trackedRequests := make([]schemas.ConsolidationRequest, 0)
+	for _, request := range requests {
+		request.PoolName = a.validatorKeyToPool[request.SourcePubkey]
+		if request.PoolName == "" {
+			request.PoolName = a.validatorKeyToPool[request.TargetPubkey]
+		}
+		if request.PoolName == "" {
+			continue
+		}
+		trackedRequests = append(trackedRequests, request)
+		log.WithFields(log.Fields{
+			"Epoch":         request.Epoch,
+			"Slot":          request.Slot,
+			"SourcePubkey":  request.SourcePubkey,
+			"TargetPubkey":  request.TargetPubkey,
+			"PoolName":      request.PoolName,
+			"SourceAddress": request.SourceAddress,
+		}).Warn("Consolidation request included in block")
+
+		if a.db != nil {
+			if err := a.db.StoreConsolidationRequest(request); err != nil {
+				return nil, errors.Wrap(err, "could not store consolidation request")
+			}
+		}
+	}
+	return trackedRequests, nil
+}
+
+// Returns an alert per tracked validator that signed conflicting attestations
+// in the previous epoch or that is in an attester slashing included in the
+// epoch blocks, since both point to the key validating in more than one machine
+func GetDoppelgangerAlerts(
+	epoch uint64,
+	conflictingVotes []uint64,
+	slashings []schemas.Slashing,
+	validatorIndexToPool map[uint64]string) []alerts.Alert {
+
+	doppelgangerAlerts := make([]alerts.Alert, 0)
+	for _, valIdx := range conflictingVotes {
+		poolName, ok := validatorIndexToPool[valIdx]
+		if !ok {
+			continue
+		}
+		doppelgangerAlerts = append(doppelgangerAlerts, alerts.Alert{
+			Kind:     alerts.Doppelganger,
+			Epoch:    epoch,
+			PoolName: poolName,
+			Message:  fmt.Sprintf("validator %d signed conflicting attestations for epoch %d, its key may be running in more than one machine", valIdx, epoch-1),
+		})
+	}
+	for _, slashing := range slashings {
+		poolName, ok := validatorIndexToPool[slashing.ValIndex]
+		if !ok || slashing.SlashingType != schemas.AttesterSlashing {
+			continue
+		}
+		doppelgangerAlerts = append(doppelgangerAlerts, alerts.Alert{
+			Kind:     alerts.Doppelganger,
+			Epoch:    epoch,
+			PoolName: poolName,
+			Message:  fmt.Sprintf("validator %d is in an attester slashing included at slot %d, its key may be running in more than one machine", slashing.ValIndex, slashing.Slot),
+		})
+	}
+	return doppelgangerAlerts
+}
+
+// Returns an alert per voluntary exit and BLS to execution change of the
+// tracked validators
+func GetExitAlerts(exits []schemas.VoluntaryExit, blsChanges []schemas.BLSChange) []alerts.Alert {
+	exitAlerts := make([]alerts.Alert, 0)
+	for _, exit := range exits {
+		exitAlerts = append(exitAlerts, alerts.Alert{
+			Kind:     alerts.VoluntaryExit,
+			Epoch:    exit.Epoch,
+			PoolName: exit.PoolName,
+			Message:  fmt.Sprintf("voluntary exit of validator %d included at slot %d", exit.ValIndex, exit.Slot),
+		})
+	}
+	for _, change := range blsChanges {
+		exitAlerts = append(exitAlerts, alerts.Alert{
+			Kind:     alerts.BLSChange,
+			Epoch:    change.Epoch,
+			PoolName: change.PoolName,
+			Message: fmt.Sprintf("withdrawal credentials of validator %d changed to %s at slot %d",
+				change.ValIndex, change.ExecutionAddress, change.Slot),
+		})
+	}
+	return exitAlerts
+}
+
+// Returns an alert per withdrawal and consolidation request of the tracked
+// validators. The requests are only checked by the contracts, so the chain may
+// still skip them, e.g. if the validator has pending deposits.
+func GetExecutionRequestAlerts(
+	withdrawalRequests []schemas.WithdrawalRequest,
+	consolidationRequests []schemas.ConsolidationRequest) []alerts.Alert {
+
+	requestAlerts := make([]alerts.Alert, 0)
+	for _, request := range withdrawalRequests {
+		message := fmt.Sprintf("withdrawal of %d gwei of validator %s requested by %s at slot %d",
+			request.Amount, request.Pubkey, request.SourceAddress, request.Slot)
+		if request.IsExit() {
+			message = fmt.Sprintf("exit of validator %s requested by %s at slot %d",
+				request.Pubkey, request.SourceAddress, request.Slot)
+		}
+		requestAlerts = append(requestAlerts, alerts.Alert{
+			Kind:     alerts.WithdrawalRequest,
+			Epoch:    request.Epoch,
+			PoolName: request.PoolName,
+			Message:  message,
+		})
+	}
+	for _, request := range consolidationRequests {
+		message := fmt.Sprintf("consolidation of validator %s into %s requested by %s at slot %d",
+			request.SourcePubkey, request.TargetPubkey, request.SourceAddress, request.Slot)
+		if request.IsSwitchToCompounding() {
+			message = fmt.Sprintf("switch of validator %s to compounding credentials requested by %s at slot %d",
+				request.SourcePubkey, request.SourceAddress, request.Slot)
+		}
+		requestAlerts = append(requestAlerts, alerts.Alert{
+			Kind:     alerts.ConsolidationRequest,
+			Epoch:    request.Epoch,
+			PoolName: request.PoolName,
+			Message:  message,
+		})
+	}
+	return requestAlerts
+}
+
+// Fires the alerts for the pool if any of the configured conditions is met.
+// Missed duties are not alerted if the pool is in maintenance.
+func (a *Metrics) checkAlerts(
+	poolName string,
+	epoch uint64,
+	maintenance bool,
+	poolMetrics *schemas.ValidatorPerformanceMetrics,
+	poolProposals *schemas.ProposalDutiesMetrics,
+	slashedIndexes []uint64,
+	feeRecipientViolations []schemas.FeeRecipientViolation,
+	mevPayouts []schemas.MEVPayout,
+	proposalTimings []schemas.ProposalTiming) {
+
+	for _, alert := range GetAlerts(
+		poolName,
+		epoch,
+		poolMetrics,
+		poolProposals,
+		slashedIndexes,
+		feeRecipientViolations,
+		mevPayouts,
+		proposalTimings,
+		a.config.AlertMissedAttestationsThreshold) {
+		if maintenance && maintenanceAlertKinds[alert.Kind] {
+			log.WithFields(log.Fields{
+				"PoolName": poolName,
+				"Kind":     alert.Kind,
+			}).Info("Alert not sent, pool in maintenance: ", alert.Message)
+			continue
+		}
+		a.sendAlert(alert)
+	}
+}
+
+// Sends the alert and stores it, so that the recent ones can be shown. Stored
+// outside the transaction of the epoch, since it was sent even if the epoch is
+// rolled back, and the forecast of the duties sends alerts concurrently.
+func (a *Metrics) sendAlert(alert alerts.Alert) {
+	alert = a.alerts.Send(alert)
+	if a.db == nil {
+		return
+	}
+	if err := a.db.Detached().StoreAlert(schemas.SentAlert{
+		Time:     alert.Time,
+		Epoch:    alert.Epoch,
+		PoolName: alert.PoolName,
+		Kind:     string(alert.Kind),
+		Severity: string(alert.Severity),
+		Message:  alert.Message,
+	}); err != nil {
+		log.Error("could not store alert: ", err)
+	}
+}
+
+func GetAlerts(
+	poolName string,
+	epoch uint64,
+	poolMetrics *schemas.ValidatorPerformanceMetrics,
+	poolProposals *schemas.ProposalDutiesMetrics,
+	slashedIndexes []uint64,
+	feeRecipientViolations []schemas.FeeRecipientViolation,
+	mevPayouts []schemas.MEVPayout,
+	proposalTimings []schemas.ProposalTiming,
+	missedAttestationsThreshold float64) []alerts.Alert {
+
+	poolAlerts := make([]alerts.Alert, 0)
+
+	for _, missed := range poolProposals.Missed {
+		message := fmt.Sprintf("validator %d missed its block proposal at slot %d", missed.ValIndex, missed.Slot)
+		if missed.MissedReason != "" {
+			message += fmt.Sprintf(" (%s)", missed.MissedReason)
+		}
+		poolAlerts = append(poolAlerts, alerts.Alert{
+			Kind:     alerts.MissedProposal,
+			Epoch:    epoch,
+			PoolName: poolName,
+			Message:  message,
+		})
+	}
+
+	if poolMetrics.NOfValidatingKeys > 0 {
+		missedPercent := float64(poolMetrics.NOfIncorrectSource) / float64(poolMetrics.NOfValidatingKeys) * 100
+		if missedPercent > missedAttestationsThreshold {
+			poolAlerts = append(poolAlerts, alerts.Alert{
+				Kind:     alerts.MissedAttestations,
+				Epoch:    epoch,
+				PoolName: poolName,
+				Message: fmt.Sprintf("%.2f%% of the validators missed their attestation (%d of %d), threshold is %.2f%%",
+					missedPercent, poolMetrics.NOfIncorrectSource, poolMetrics.NOfValidatingKeys, missedAttestationsThreshold),
+			})
+		}
+	}
+
+	for _, slashedIndex := range slashedIndexes {
+		poolAlerts = append(poolAlerts, alerts.Alert{
+			Kind:     alerts.ValidatorSlashed,
+			Epoch:    epoch,
+			PoolName: poolName,
+			Message:  fmt.Sprintf("validator %d was slashed", slashedIndex),
+		})
+	}
+
+	for _, violation := range feeRecipientViolations {
+		poolAlerts = append(poolAlerts, alerts.Alert{
+			Kind:     alerts.WrongFeeRecipient,
+			Epoch:    epoch,
+			PoolName: poolName,
+			Message: fmt.Sprintf("validator %d proposed the block at slot %d paying to %s, expected %s",
+				violation.ProposerIndex, violation.Slot, violation.FeeRecipient, strings.Join(violation.Expected, " or ")),
+		})
+	}
+
+	for _, payout := range mevPayouts {
+		if payout.Shortfall.Sign() <= 0 {
+			continue
+		}
+		poolAlerts = append(poolAlerts, alerts.Alert{
+			Kind:     alerts.MEVUnderpaid,
+			Epoch:    epoch,
+			PoolName: poolName,
+			Message: fmt.Sprintf("validator %d proposed the MEV block at slot %d and %s received %s wei, %s reported by %s",
+				payout.ProposerIndex, payout.Slot, payout.FeeRecipient, payout.Paid, payout.Reported, strings.Join(payout.Relays, ", ")),
+		})
+	}
+
+	for _, timing := range proposalTimings {
+		if !timing.Late {
+			continue
 		}
+		poolAlerts = append(poolAlerts, alerts.Alert{
+			Kind:     alerts.LateProposal,
+			Epoch:    epoch,
+			PoolName: poolName,
+			Message: fmt.Sprintf("validator %d proposed the block at slot %d late, seen %d ms after the start of the slot",
+				timing.ProposerIndex, timing.Slot, timing.DelayMs),
+		})
 	}
 
-	return currentBeaconState, nil
+	return poolAlerts
 }
 
 func (a *Metrics) GetValidatorKeys(poolName string) (string, [][]byte, error) {
 	var pubKeysDeposited [][]byte
 	var err error
-	if strings.HasSuffix(poolName, ".txt") {
-		// Vanila file, one key per line
-		pubKeysDeposited, err = pools.ReadCustomValidatorsFile(poolName)
+	if pools.IsPoolKeysFile(poolName) {
+		// Vanila file with one key per line, deposit data or keystores
+		pubKeysDeposited, err = pools.ReadPoolKeysFile(poolName)
 		if err != nil {
-			log.Fatal(err)
+			return "", nil, errors.Wrap(err, "could not read keys of pool "+poolName)
 		}
 		// trim the file path and extension
 		poolName = filepath.Base(poolName)
@@ -429,7 +1775,7 @@ func (a *Metrics) GetValidatorKeys(poolName string) (string, [][]byte, error) {
 		// ethsta.com format
 		pubKeysDeposited, err = pools.ReadEthstaValidatorsFile(poolName)
 		if err != nil {
-			log.Fatal(err)
+			return "", nil, errors.Wrap(err, "could not read validators of pool "+poolName)
 		}
 		// trim the file path and extension
 		poolName = filepath.Base(poolName)
@@ -1 +1,207 @@
 package metrics
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/bilinearlabs/eth-metrics/alerts"
+	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/bilinearlabs/eth-metrics/db"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GetAlerts(t *testing.T) {
+	poolMetrics := &schemas.ValidatorPerformanceMetrics{
+		NOfValidatingKeys:  100,
+		NOfIncorrectSource: 10,
+	}
+	poolProposals := &schemas.ProposalDutiesMetrics{
+		Missed: []schemas.Duty{
+			{ValIndex: 5, Slot: 320},
+		},
+	}
+
+	violations := []schemas.FeeRecipientViolation{
+		{Slot: 321, ProposerIndex: 6, FeeRecipient: "0xbad", Expected: []string{"0xa", "0xb"}},
+	}
+
+	payouts := []schemas.MEVPayout{
+		{Slot: 322, ProposerIndex: 8, FeeRecipient: "0xa", Relays: []string{"relay_a"},
+			Reported: big.NewInt(100), Paid: big.NewInt(40), Shortfall: big.NewInt(60)},
+		{Slot: 323, ProposerIndex: 9, FeeRecipient: "0xa", Relays: []string{"relay_a"},
+			Reported: big.NewInt(100), Paid: big.NewInt(100), Shortfall: big.NewInt(0)},
+	}
+
+	timings := []schemas.ProposalTiming{
+		{Slot: 324, ProposerIndex: 10, DelayMs: 4500, Late: true},
+		{Slot: 325, ProposerIndex: 11, DelayMs: 800},
+	}
+
+	poolAlerts := GetAlerts("pool_a", 10, poolMetrics, poolProposals, []uint64{7}, violations, payouts, timings, 5)
+	require.Equal(t, 6, len(poolAlerts))
+	require.Equal(t, alerts.MissedProposal, poolAlerts[0].Kind)
+	require.Equal(t, "validator 5 missed its block proposal at slot 320", poolAlerts[0].Message)
+	require.Equal(t, alerts.MissedAttestations, poolAlerts[1].Kind)
+	require.Equal(t, alerts.ValidatorSlashed, poolAlerts[2].Kind)
+	require.Equal(t, "validator 7 was slashed", poolAlerts[2].Message)
+	require.Equal(t, alerts.WrongFeeRecipient, poolAlerts[3].Kind)
+	require.Equal(t, "validator 6 proposed the block at slot 321 paying to 0xbad, expected 0xa or 0xb", poolAlerts[3].Message)
+	require.Equal(t, alerts.MEVUnderpaid, poolAlerts[4].Kind)
+	require.Equal(t, "validator 8 proposed the MEV block at slot 322 and 0xa received 40 wei, 100 reported by relay_a", poolAlerts[4].Message)
+	require.Equal(t, alerts.LateProposal, poolAlerts[5].Kind)
+	require.Equal(t, "validator 10 proposed the block at slot 324 late, seen 4500 ms after the start of the slot", poolAlerts[5].Message)
+
+	// Below the threshold and nothing missed
+	poolAlerts = GetAlerts("pool_a", 10, poolMetrics, &schemas.ProposalDutiesMetrics{}, []uint64{}, nil, nil, nil, 15)
+	require.Equal(t, 0, len(poolAlerts))
+}
+
+func Test_storeSlashings(t *testing.T) {
+	m := &Metrics{}
+
+	nOfSlashedPerPool, err := m.storeSlashings([]schemas.Slashing{
+		{Epoch: 10, Slot: 320, ValIndex: 7, SlashingType: schemas.ProposerSlashing},
+		{Epoch: 10, Slot: 321, ValIndex: 8, SlashingType: schemas.AttesterSlashing},
+		{Epoch: 10, Slot: 321, ValIndex: 9, SlashingType: schemas.AttesterSlashing},
+		{Epoch: 10, Slot: 321, ValIndex: 100, SlashingType: schemas.AttesterSlashing},
+	}, map[uint64]string{7: "pool_a", 8: "pool_a", 9: "pool_b"})
+
+	require.NoError(t, err)
+	require.Equal(t, map[string]uint64{"pool_a": 2, "pool_b": 1}, nOfSlashedPerPool)
+}
+
+func Test_GetExitAlerts(t *testing.T) {
+	m := &Metrics{}
+	validatorIndexToPool := map[uint64]string{7: "pool_a", 8: "pool_b"}
+
+	exits, err := m.storeVoluntaryExits([]schemas.VoluntaryExit{
+		{Epoch: 10, Slot: 320, ValIndex: 7, ExitEpoch: 9},
+		{Epoch: 10, Slot: 320, ValIndex: 100, ExitEpoch: 9},
+	}, validatorIndexToPool)
+	require.NoError(t, err)
+	require.Equal(t, []schemas.VoluntaryExit{{Epoch: 10, Slot: 320, ValIndex: 7, PoolName: "pool_a", ExitEpoch: 9}}, exits)
+
+	changes, err := m.storeBLSChanges([]schemas.BLSChange{
+		{Epoch: 10, Slot: 321, ValIndex: 8, ExecutionAddress: "0xab"},
+	}, validatorIndexToPool)
+	require.NoError(t, err)
+
+	exitAlerts := GetExitAlerts(exits, changes)
+	require.Equal(t, 2, len(exitAlerts))
+	require.Equal(t, alerts.VoluntaryExit, exitAlerts[0].Kind)
+	require.Equal(t, "pool_a", exitAlerts[0].PoolName)
+	require.Equal(t, "voluntary exit of validator 7 included at slot 320", exitAlerts[0].Message)
+	require.Equal(t, alerts.BLSChange, exitAlerts[1].Kind)
+	require.Equal(t, "pool_b", exitAlerts[1].PoolName)
+	require.Equal(t, "withdrawal credentials of validator 8 changed to 0xab at slot 321", exitAlerts[1].Message)
+}
+
+func Test_GetExecutionRequestAlerts(t *testing.T) {
+	m := &Metrics{validatorKeyToPool: map[string]string{"0xaa": "pool_a", "0xcc": "pool_b"}}
+
+	withdrawalRequests, err := m.storeWithdrawalRequests([]schemas.WithdrawalRequest{
+		{Epoch: 10, Slot: 320, SourceAddress: "0xab", Pubkey: "0xaa", Amount: 1000000000},
+		{Epoch: 10, Slot: 320, RequestIndex: 1, SourceAddress: "0xab", Pubkey: "0xaa"},
+		{Epoch: 10, Slot: 320, RequestIndex: 2, SourceAddress: "0xab", Pubkey: "0xdd"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(withdrawalRequests))
+	require.Equal(t, "pool_a", withdrawalRequests[0].PoolName)
+
+	// Attributed to the pool of the target if the source is not tracked
+	consolidationRequests, err := m.storeConsolidationRequests([]schemas.ConsolidationRequest{
+		{Epoch: 10, Slot: 321, SourceAddress: "0xab", SourcePubkey: "0xdd", TargetPubkey: "0xcc"},
+		{Epoch: 10, Slot: 321, RequestIndex: 1, SourceAddress: "0xab", SourcePubkey: "0xaa", TargetPubkey: "0xaa"},
+		{Epoch: 10, Slot: 321, RequestIndex: 2, SourceAddress: "0xab", SourcePubkey: "0xdd", TargetPubkey: "0xee"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(consolidationRequests))
+	require.Equal(t, "pool_b", consolidationRequests[0].PoolName)
+	require.Equal(t, "pool_a", consolidationRequests[1].PoolName)
+
+	requestAlerts := GetExecutionRequestAlerts(withdrawalRequests, consolidationRequests)
+	require.Equal(t, 4, len(requestAlerts))
+	require.Equal(t, alerts.WithdrawalRequest, requestAlerts[0].Kind)
+	require.Equal(t, "withdrawal of 1000000000 gwei of validator 0xaa requested by 0xab at slot 320", requestAlerts[0].Message)
+	require.Equal(t, "exit of validator 0xaa requested by 0xab at slot 320", requestAlerts[1].Message)
+	require.Equal(t, alerts.ConsolidationRequest, requestAlerts[2].Kind)
+	require.Equal(t, "pool_b", requestAlerts[2].PoolName)
+	require.Equal(t, "consolidation of validator 0xdd into 0xcc requested by 0xab at slot 321", requestAlerts[2].Message)
+	require.Equal(t, "switch of validator 0xaa to compounding credentials requested by 0xab at slot 321", requestAlerts[3].Message)
+}
+
+func Test_GetDoppelgangerAlerts(t *testing.T) {
+	validatorIndexToPool := map[uint64]string{7: "pool_a", 8: "pool_b"}
+	slashings := []schemas.Slashing{
+		{Epoch: 10, Slot: 321, ValIndex: 8, SlashingType: schemas.AttesterSlashing},
+		// Proposer slashings and untracked validators are ignored
+		{Epoch: 10, Slot: 321, ValIndex: 7, SlashingType: schemas.ProposerSlashing},
+		{Epoch: 10, Slot: 321, ValIndex: 100, SlashingType: schemas.AttesterSlashing},
+	}
+
+	doppelgangerAlerts := GetDoppelgangerAlerts(10, []uint64{7, 99}, slashings, validatorIndexToPool)
+	require.Equal(t, 2, len(doppelgangerAlerts))
+	require.Equal(t, alerts.Doppelganger, doppelgangerAlerts[0].Kind)
+	require.Equal(t, "pool_a", doppelgangerAlerts[0].PoolName)
+	require.Equal(t, "validator 7 signed conflicting attestations for epoch 9, its key may be running in more than one machine", doppelgangerAlerts[0].Message)
+	require.Equal(t, "pool_b", doppelgangerAlerts[1].PoolName)
+	require.Equal(t, "validator 8 is in an attester slashing included at slot 321, its key may be running in more than one machine", doppelgangerAlerts[1].Message)
+}
+
+func Test_getMissingEpochs(t *testing.T) {
+	database, err := db.New(filepath.Join(t.TempDir(), "db.db"))
+	require.NoError(t, err)
+	require.NoError(t, database.CreateTables())
+	for epoch := uint64(97); epoch <= 100; epoch++ {
+		require.NoError(t, database.StoreEpochProcessed(epoch, "old", []string{db.ModuleSummary}))
+	}
+
+	m := &Metrics{
+		db: database,
+		config: &config.Config{
+			BackfillEpochs:     2,
+			PoolBackfillEpochs: map[string]uint64{"deep": 4},
+		},
+		validatorKeysPerPool: map[string][][]byte{"old": nil, "new": nil, "deep": nil},
+	}
+
+	// The new pools are backfilled up to their depth
+	missingEpochs, err := m.getMissingEpochs(100)
+	require.NoError(t, err)
+	require.Equal(t, map[uint64][]string{
+		97:  {"deep"},
+		98:  {"deep"},
+		99:  {"deep", "new"},
+		100: {"deep", "new"},
+	}, missingEpochs)
+}
+
+func Test_BeaconNodeHeaders(t *testing.T) {
+	require.Empty(t, BeaconNodeHeaders(&config.Config{}))
+	require.Equal(t, map[string]string{"Authorization": "Basic dXNlcjpwYXNz"}, BeaconNodeHeaders(&config.Config{Credentials: "user:pass"}))
+	require.Equal(t, map[string]string{"Authorization": "Bearer token", "X-Api-Key": "key"}, BeaconNodeHeaders(&config.Config{
+		Eth2BearerToken: "token",
+		Eth2Headers:     map[string]string{"X-Api-Key": "key"},
+	}))
+	// The headers take precedence, e.g. for a provider with another scheme
+	require.Equal(t, map[string]string{"Authorization": "Token key"}, BeaconNodeHeaders(&config.Config{
+		Eth2BearerToken: "token",
+		Eth2Headers:     map[string]string{"Authorization": "Token key"},
+	}))
+}
+
+func Test_GetValidatorKeys(t *testing.T) {
+	m := &Metrics{}
+	poolName, keys, err := m.GetValidatorKeys("pool_a")
+	require.NoError(t, err)
+	require.Equal(t, "pool_a", poolName)
+	require.Empty(t, keys)
+
+	// Returned instead of exiting
+	_, _, err = m.GetValidatorKeys(filepath.Join(t.TempDir(), "missing.txt"))
+	require.ErrorContains(t, err, "could not read keys of pool")
+	_, _, err = m.GetValidatorKeys(filepath.Join(t.TempDir(), "missing.csv"))
+	require.ErrorContains(t, err, "could not read validators of pool")
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"math/big"
+
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	log "github.com/sirupsen/logrus"
+)
+
+// Returns the payouts of the MEV blocks proposed by the pool, comparing the
+// value reported by the relays with what the fee recipient received. Blocks
+// whose payout was not checked, e.g. without execution client, are skipped.
+func GetMEVPayouts(
+	epoch uint64,
+	poolName string,
+	proposed []schemas.Duty,
+	relayPayloads map[uint64]schemas.RelayPayload,
+	paid map[uint64]*big.Int) []schemas.MEVPayout {
+
+	payouts := make([]schemas.MEVPayout, 0)
+	for _, duty := range proposed {
+		payload, ok := relayPayloads[duty.Slot]
+		if !ok || payload.Value == nil {
+			continue
+		}
+		paidWei, ok := paid[duty.Slot]
+		if !ok || paidWei == nil {
+			continue
+		}
+		shortfall := new(big.Int).Sub(payload.Value, paidWei)
+		if shortfall.Sign() < 0 {
+			shortfall.SetUint64(0)
+		}
+		payouts = append(payouts, schemas.MEVPayout{
+			Epoch:         epoch,
+			Slot:          duty.Slot,
+			ProposerIndex: duty.ValIndex,
+			PoolName:      poolName,
+			FeeRecipient:  payload.ProposerFeeRecipient,
+			Relays:        payload.Relays,
+			BuilderPubKey: payload.BuilderPubKey,
+			Reported:      payload.Value,
+			Paid:          paidWei,
+			Shortfall:     shortfall,
+		})
+	}
+	return payouts
+}
+
+func logMEVPayouts(payouts []schemas.MEVPayout) {
+	for _, payout := range payouts {
+		logger := log.WithFields(log.Fields{
+			"Epoch":         payout.Epoch,
+			"Slot":          payout.Slot,
+			"ValIndex":      payout.ProposerIndex,
+			"PoolName":      payout.PoolName,
+			"FeeRecipient":  payout.FeeRecipient,
+			"Relays":        payout.Relays,
+			"BuilderPubKey": payout.BuilderPubKey,
+			"ReportedWei":   payout.Reported,
+			"PaidWei":       payout.Paid,
+		})
+		if payout.Shortfall.Sign() > 0 {
+			logger.Warn("MEV payout is less than reported by the relays")
+		} else {
+			logger.Debug("MEV payout matches the relays")
+		}
+	}
+}
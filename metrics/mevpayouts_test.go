@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GetMEVPayouts(t *testing.T) {
+	proposed := []schemas.Duty{
+		{ValIndex: 1, Slot: 100},
+		{ValIndex: 2, Slot: 101},
+		{ValIndex: 3, Slot: 102},
+		// Vanilla block
+		{ValIndex: 4, Slot: 103},
+		// Payout not checked
+		{ValIndex: 5, Slot: 104},
+	}
+	relayPayloads := map[uint64]schemas.RelayPayload{
+		100: {Slot: 100, Relays: []string{"relay_a"}, BuilderPubKey: "0xbuilder", ProposerFeeRecipient: "0xpool", Value: big.NewInt(1000)},
+		101: {Slot: 101, Relays: []string{"relay_a", "relay_b"}, BuilderPubKey: "0xbuilder", ProposerFeeRecipient: "0xpool", Value: big.NewInt(1000)},
+		102: {Slot: 102, Relays: []string{"relay_b"}, BuilderPubKey: "0xbuilder", ProposerFeeRecipient: "0xpool", Value: big.NewInt(1000)},
+		104: {Slot: 104, Relays: []string{"relay_b"}, BuilderPubKey: "0xbuilder", ProposerFeeRecipient: "0xpool", Value: big.NewInt(1000)},
+	}
+	paid := map[uint64]*big.Int{
+		100: big.NewInt(1000),
+		101: big.NewInt(400),
+		// Paid more than reported
+		102: big.NewInt(1200),
+		103: big.NewInt(50),
+	}
+
+	payouts := GetMEVPayouts(10, "pool_a", proposed, relayPayloads, paid)
+	require.Equal(t, 3, len(payouts))
+
+	require.Equal(t, uint64(100), payouts[0].Slot)
+	require.Equal(t, uint64(1), payouts[0].ProposerIndex)
+	require.Equal(t, "pool_a", payouts[0].PoolName)
+	require.Equal(t, "0xpool", payouts[0].FeeRecipient)
+	require.Equal(t, "0xbuilder", payouts[0].BuilderPubKey)
+	require.Zero(t, payouts[0].Shortfall.Sign())
+
+	require.Equal(t, uint64(101), payouts[1].Slot)
+	require.Equal(t, []string{"relay_a", "relay_b"}, payouts[1].Relays)
+	require.Equal(t, big.NewInt(1000), payouts[1].Reported)
+	require.Equal(t, big.NewInt(400), payouts[1].Paid)
+	require.Equal(t, big.NewInt(600), payouts[1].Shortfall)
+
+	require.Equal(t, uint64(102), payouts[2].Slot)
+	require.Zero(t, payouts[2].Shortfall.Sign())
+}
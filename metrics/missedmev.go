@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"math/big"
+
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	log "github.com/sirupsen/logrus"
+)
+
+// Returns the MEV missed by the vanilla blocks of the pool, that is, the best
+// bid the relays had for the slot minus the tips the block paid. Blocks without
+// bids or whose tips are not known, e.g. without execution client, are skipped.
+func GetMissedMEV(
+	epoch uint64,
+	poolName string,
+	vanillaBlocks []schemas.Duty,
+	bestBids map[uint64]*schemas.RelayBid,
+	slotTips map[uint64]*big.Int) []schemas.MissedMEV {
+
+	missedMEV := make([]schemas.MissedMEV, 0)
+	for _, duty := range vanillaBlocks {
+		bestBid, ok := bestBids[duty.Slot]
+		if !ok || bestBid == nil {
+			continue
+		}
+		tip, ok := slotTips[duty.Slot]
+		if !ok || tip == nil {
+			continue
+		}
+		missed := new(big.Int).Sub(bestBid.Value, tip)
+		if missed.Sign() < 0 {
+			missed.SetUint64(0)
+		}
+		missedMEV = append(missedMEV, schemas.MissedMEV{
+			Epoch:         epoch,
+			Slot:          duty.Slot,
+			ProposerIndex: duty.ValIndex,
+			PoolName:      poolName,
+			Relay:         bestBid.Relay,
+			BuilderPubKey: bestBid.BuilderPubKey,
+			BestBid:       bestBid.Value,
+			Tip:           tip,
+			Missed:        missed,
+		})
+	}
+	return missedMEV
+}
+
+func logMissedMEV(missedMEV []schemas.MissedMEV) {
+	for _, missed := range missedMEV {
+		log.WithFields(log.Fields{
+			"Epoch":      missed.Epoch,
+			"Slot":       missed.Slot,
+			"ValIndex":   missed.ProposerIndex,
+			"PoolName":   missed.PoolName,
+			"Relay":      missed.Relay,
+			"BestBidWei": missed.BestBid,
+			"TipWei":     missed.Tip,
+			"MissedWei":  missed.Missed,
+		}).Info("MEV missed by vanilla block")
+	}
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GetMissedMEV(t *testing.T) {
+	vanillaBlocks := []schemas.Duty{
+		{ValIndex: 1, Slot: 100},
+		// Tips higher than the best bid
+		{ValIndex: 2, Slot: 101},
+		// No bids
+		{ValIndex: 3, Slot: 102},
+		// Tip not known
+		{ValIndex: 4, Slot: 103},
+	}
+	bestBids := map[uint64]*schemas.RelayBid{
+		100: {Slot: 100, Relay: "relay_a", BuilderPubKey: "0xbuilder", Value: big.NewInt(1000)},
+		101: {Slot: 101, Relay: "relay_b", BuilderPubKey: "0xbuilder", Value: big.NewInt(1000)},
+		103: {Slot: 103, Relay: "relay_b", BuilderPubKey: "0xbuilder", Value: big.NewInt(1000)},
+	}
+	slotTips := map[uint64]*big.Int{
+		100: big.NewInt(300),
+		101: big.NewInt(1500),
+		102: big.NewInt(300),
+	}
+
+	missedMEV := GetMissedMEV(10, "pool_a", vanillaBlocks, bestBids, slotTips)
+	require.Equal(t, 2, len(missedMEV))
+
+	require.Equal(t, uint64(10), missedMEV[0].Epoch)
+	require.Equal(t, uint64(100), missedMEV[0].Slot)
+	require.Equal(t, uint64(1), missedMEV[0].ProposerIndex)
+	require.Equal(t, "pool_a", missedMEV[0].PoolName)
+	require.Equal(t, "relay_a", missedMEV[0].Relay)
+	require.Equal(t, "0xbuilder", missedMEV[0].BuilderPubKey)
+	require.Equal(t, big.NewInt(1000), missedMEV[0].BestBid)
+	require.Equal(t, big.NewInt(300), missedMEV[0].Tip)
+	require.Equal(t, big.NewInt(700), missedMEV[0].Missed)
+
+	require.Equal(t, uint64(101), missedMEV[1].Slot)
+	require.Zero(t, missedMEV[1].Missed.Sign())
+}
@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/attestantio/go-eth2-client/api"
+	"github.com/attestantio/go-eth2-client/http"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/bilinearlabs/eth-metrics/db"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Spec values used to compute the churn as in get_activation_exit_churn_limit
+type churnParameters struct {
+	churnLimitQuotient                  uint64
+	minPerEpochChurnLimit               uint64
+	maxPerEpochActivationExitChurnLimit uint64
+	effectiveBalanceIncrement           uint64
+	maxPendingDepositsPerEpoch          uint64
+	maxSeedLookahead                    uint64
+}
+
+type NetworkQueues struct {
+	networkParameters *NetworkParameters
+	database          *db.Database
+	churn             churnParameters
+}
+
+func NewNetworkQueues(
+	consensus *http.Service,
+	networkParameters *NetworkParameters,
+	database *db.Database,
+) (*NetworkQueues, error) {
+	specResponse, err := consensus.Spec(context.Background(), &api.SpecOpts{})
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting spec info")
+	}
+
+	churn := churnParameters{}
+	for key, field := range map[string]*uint64{
+		"CHURN_LIMIT_QUOTIENT":                      &churn.churnLimitQuotient,
+		"MIN_PER_EPOCH_CHURN_LIMIT_ELECTRA":         &churn.minPerEpochChurnLimit,
+		"MAX_PER_EPOCH_ACTIVATION_EXIT_CHURN_LIMIT": &churn.maxPerEpochActivationExitChurnLimit,
+		"EFFECTIVE_BALANCE_INCREMENT":               &churn.effectiveBalanceIncrement,
+		"MAX_PENDING_DEPOSITS_PER_EPOCH":            &churn.maxPendingDepositsPerEpoch,
+		"MAX_SEED_LOOKAHEAD":                        &churn.maxSeedLookahead,
+	} {
+		*field, err = SpecUint64(specResponse.Data, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &NetworkQueues{
+		networkParameters: networkParameters,
+		database:          database,
+		churn:             churn,
+	}, nil
+}
+
+func (n *NetworkQueues) Run(
+	currentEpoch uint64,
+	currentBeaconState *spec.VersionedBeaconState,
+) error {
+	if currentBeaconState == nil {
+		return errors.New("current beacon state is nil")
+	}
+
+	networkQueues := n.GetNetworkQueues(currentEpoch, currentBeaconState)
+
+	if n.database != nil {
+		err := n.database.StoreNetworkQueues(networkQueues)
+		if err != nil {
+			return errors.Wrap(err, "could not store network queues")
+		}
+	}
+
+	return nil
+}
+
+// Estimates the wait of a validator joining the activation or exit queue at the
+// given epoch. The entry wait is the time to process the pending deposits plus
+// the activation delay, ignoring the wait for the deposit to be finalized.
+func (n *NetworkQueues) GetNetworkQueues(
+	currentEpoch uint64,
+	beaconState *spec.VersionedBeaconState,
+) schemas.NetworkQueues {
+	networkQueues := schemas.NetworkQueues{
+		Time:  n.networkParameters.Clock().EpochStart(currentEpoch),
+		Epoch: currentEpoch,
+	}
+
+	totalActiveBalance := uint64(0)
+	for _, val := range GetValidators(beaconState) {
+		if uint64(val.ActivationEpoch) <= currentEpoch && currentEpoch < uint64(val.ExitEpoch) {
+			totalActiveBalance += uint64(val.EffectiveBalance)
+		}
+		if val.ExitEpoch != farFutureEpoch && currentEpoch < uint64(val.ExitEpoch) {
+			networkQueues.NOfExitingValidators++
+			networkQueues.ExitingBalanceGwei += uint64(val.EffectiveBalance)
+		}
+	}
+	networkQueues.ChurnLimitGwei = n.GetActivationExitChurnLimit(totalActiveBalance)
+
+	for _, deposit := range GetPendingDeposits(beaconState) {
+		networkQueues.NOfPendingDeposits++
+		networkQueues.PendingDepositsGwei += uint64(deposit.Amount)
+	}
+
+	// Deposits are limited both by balance and by number per epoch
+	activationDelay := 1 + n.churn.maxSeedLookahead
+	toConsume := networkQueues.PendingDepositsGwei - min(networkQueues.PendingDepositsGwei, GetDepositBalanceToConsume(beaconState))
+	entryWait := ceilDiv(toConsume, networkQueues.ChurnLimitGwei)
+	entryWait = max(entryWait, ceilDiv(networkQueues.NOfPendingDeposits, n.churn.maxPendingDepositsPerEpoch))
+	networkQueues.EntryWaitEpochs = entryWait + activationDelay
+
+	// Exits are assigned the earliest exit epoch with available churn, which
+	// can't be before the activation exit epoch
+	earliestExitEpoch := max(GetEarliestExitEpoch(beaconState), currentEpoch+activationDelay)
+	networkQueues.ExitWaitEpochs = earliestExitEpoch - currentEpoch
+
+	log.WithFields(log.Fields{
+		"Epoch":               networkQueues.Epoch,
+		"ChurnLimitGwei":      networkQueues.ChurnLimitGwei,
+		"PendingDeposits":     networkQueues.NOfPendingDeposits,
+		"PendingDepositsGwei": networkQueues.PendingDepositsGwei,
+		"ExitingValidators":   networkQueues.NOfExitingValidators,
+		"ExitingBalanceGwei":  networkQueues.ExitingBalanceGwei,
+		"EntryWaitEpochs":     networkQueues.EntryWaitEpochs,
+		"ExitWaitEpochs":      networkQueues.ExitWaitEpochs,
+	}).Info("Network queues:")
+
+	return networkQueues
+}
+
+// Balance that can be activated or exited per epoch, in gwei
+func (n *NetworkQueues) GetActivationExitChurnLimit(totalActiveBalance uint64) uint64 {
+	totalActiveBalance = max(totalActiveBalance, n.churn.effectiveBalanceIncrement)
+	churn := max(n.churn.minPerEpochChurnLimit, totalActiveBalance/n.churn.churnLimitQuotient)
+	churn = churn - churn%n.churn.effectiveBalanceIncrement
+	return min(n.churn.maxPerEpochActivationExitChurnLimit, churn)
+}
+
+func ceilDiv(a uint64, b uint64) uint64 {
+	if b == 0 {
+		return 0
+	}
+	return (a + b - 1) / b
+}
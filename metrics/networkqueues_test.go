@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/deneb"
+	"github.com/attestantio/go-eth2-client/spec/electra"
+	"github.com/attestantio/go-eth2-client/spec/fulu"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+)
+
+var mainnetChurnParameters = churnParameters{
+	churnLimitQuotient:                  65536,
+	minPerEpochChurnLimit:               128000000000,
+	maxPerEpochActivationExitChurnLimit: 256000000000,
+	effectiveBalanceIncrement:           1000000000,
+	maxPendingDepositsPerEpoch:          16,
+	maxSeedLookahead:                    4,
+}
+
+func Test_GetActivationExitChurnLimit(t *testing.T) {
+	networkQueues := &NetworkQueues{networkParameters: &NetworkParameters{slotsInEpoch: 32, secondsPerSlot: 12}, churn: mainnetChurnParameters}
+
+	// Small networks use the minimum churn
+	require.Equal(t, uint64(128000000000), networkQueues.GetActivationExitChurnLimit(0))
+	require.Equal(t, uint64(128000000000), networkQueues.GetActivationExitChurnLimit(1000000*1000000000))
+	// Rounded down to the effective balance increment
+	require.Equal(t, uint64(152000000000), networkQueues.GetActivationExitChurnLimit(10000000*1000000000))
+	// Capped
+	require.Equal(t, uint64(256000000000), networkQueues.GetActivationExitChurnLimit(40000000*1000000000))
+}
+
+func Test_GetNetworkQueues(t *testing.T) {
+	networkQueues := &NetworkQueues{networkParameters: &NetworkParameters{slotsInEpoch: 32, secondsPerSlot: 12}, churn: mainnetChurnParameters}
+
+	beaconState := &spec.VersionedBeaconState{
+		Fulu: &fulu.BeaconState{
+			Validators: []*phase0.Validator{
+				{EffectiveBalance: 32000000000, ActivationEpoch: 0, ExitEpoch: farFutureEpoch},
+				{EffectiveBalance: 32000000000, ActivationEpoch: 0, ExitEpoch: farFutureEpoch},
+				// Exiting
+				{EffectiveBalance: 2048000000000, ActivationEpoch: 0, ExitEpoch: 105},
+				// Already exited
+				{EffectiveBalance: 0, ActivationEpoch: 0, ExitEpoch: 50},
+			},
+			PendingDeposits: []*electra.PendingDeposit{
+				{Amount: 32000000000},
+				{Amount: 32000000000},
+				{Amount: 100000000000},
+			},
+			DepositBalanceToConsume: 10000000000,
+			EarliestExitEpoch:       110,
+			LatestExecutionPayloadHeader: &deneb.ExecutionPayloadHeader{
+				Timestamp: 1673308800,
+			},
+		},
+	}
+
+	queues := networkQueues.GetNetworkQueues(100, beaconState)
+	require.Equal(t, uint64(100), queues.Epoch)
+	require.Equal(t, uint64(128000000000), queues.ChurnLimitGwei)
+	require.Equal(t, uint64(3), queues.NOfPendingDeposits)
+	require.Equal(t, uint64(164000000000), queues.PendingDepositsGwei)
+	require.Equal(t, uint64(1), queues.NOfExitingValidators)
+	require.Equal(t, uint64(2048000000000), queues.ExitingBalanceGwei)
+	// 154 ETH to consume take 2 epochs, plus the activation delay
+	require.Equal(t, uint64(2+5), queues.EntryWaitEpochs)
+	require.Equal(t, uint64(10), queues.ExitWaitEpochs)
+
+	// The exit epoch can't be before the activation exit epoch
+	beaconState.Fulu.EarliestExitEpoch = 0
+	queues = networkQueues.GetNetworkQueues(100, beaconState)
+	require.Equal(t, uint64(5), queues.ExitWaitEpochs)
+}
@@ -1,8 +1,6 @@
 package metrics
 
 import (
-	"time"
-
 	"github.com/attestantio/go-eth2-client/spec"
 	"github.com/bilinearlabs/eth-metrics/db"
 	"github.com/bilinearlabs/eth-metrics/schemas"
@@ -11,58 +9,77 @@ import (
 )
 
 type NetworkStats struct {
-	database *db.Database
+	networkParameters *NetworkParameters
+	database          *db.Database
 }
 
 func NewNetworkStats(
+	networkParameters *NetworkParameters,
 	database *db.Database,
 ) (*NetworkStats, error) {
 	return &NetworkStats{
-		database: database,
+		networkParameters: networkParameters,
+		database:          database,
 	}, nil
 }
 
+// Stores and returns the stats of the network, so that the pools can be
+// compared with it
 func (n *NetworkStats) Run(
 	currentEpoch uint64,
 	currentBeaconState *spec.VersionedBeaconState,
-) error {
+	proposalMetrics *schemas.ProposalDutiesMetrics,
+	blobs map[uint64]schemas.BlockBlobs,
+) (*schemas.NetworkStats, error) {
 	if n.database == nil {
-		return errors.New("database is nil")
+		return nil, errors.New("database is nil")
 	}
 
 	if currentBeaconState == nil {
-		return errors.New("current beacon state is nil")
+		return nil, errors.New("current beacon state is nil")
 	}
 
-	networkStats, err := n.GetNetworkStats(currentEpoch, currentBeaconState)
+	networkStats, err := n.GetNetworkStats(currentEpoch, currentBeaconState, proposalMetrics)
 	if err != nil {
-		return errors.Wrap(err, "error getting network stats")
+		return nil, errors.Wrap(err, "error getting network stats")
 	}
+	AddBlobStats(&networkStats, blobs)
+	log.WithFields(log.Fields{
+		"Blobs":           networkStats.NOfBlobs,
+		"BlobGasUsed":     networkStats.BlobGasUsed,
+		"BlobUtilization": networkStats.BlobUtilization,
+		"BlobBaseFeeWei":  networkStats.BlobBaseFee,
+	}).Info("Network blob stats:")
 
 	if n.database != nil {
 		err = n.database.StoreNetworkMetrics(networkStats)
 		if err != nil {
-			return errors.Wrap(err, "could not store network stats")
+			return nil, errors.Wrap(err, "could not store network stats")
 		}
 	}
 
-	return nil
+	return &networkStats, nil
 }
 
+// Returns the validators of the network by status, their participation in the
+// previous epoch and the blocks proposed in the epoch. Votes are counted as in
+// GetParticipation, so that they can be compared with the ones of the pools.
 func (n *NetworkStats) GetNetworkStats(
 	currentEpoch uint64,
 	beaconState *spec.VersionedBeaconState,
+	proposalMetrics *schemas.ProposalDutiesMetrics,
 ) (schemas.NetworkStats, error) {
 	networkStats := schemas.NetworkStats{
-		Time:                 time.Unix(int64(GetTimestamp(beaconState)), 0),
+		Time:                 n.networkParameters.Clock().EpochStart(currentEpoch),
 		Epoch:                currentEpoch,
 		NOfActiveValidators:  0,
 		NOfExitedValidators:  0,
 		NOfSlashedValidators: 0,
 	}
 	validators := GetValidators(beaconState)
+	previousEpochParticipation := GetPreviousEpochParticipation(beaconState)
 
-	for _, val := range validators {
+	for valIdx, val := range validators {
 		if val.Slashed {
 			networkStats.NOfSlashedValidators++
 		}
@@ -70,14 +87,48 @@ func (n *NetworkStats) GetNetworkStats(
 			networkStats.NOfExitedValidators++
 		} else if uint64(val.ActivationEpoch) <= currentEpoch {
 			networkStats.NOfActiveValidators++
+			if val.Slashed || valIdx >= len(previousEpochParticipation) {
+				continue
+			}
+			epochAttestations := uint8(previousEpochParticipation[valIdx])
+			if !isBitSet(epochAttestations, 0) {
+				networkStats.NOfIncorrectSource++
+			}
+			if !isBitSet(epochAttestations, 1) {
+				networkStats.NOfIncorrectTarget++
+			}
+			if !isBitSet(epochAttestations, 2) {
+				networkStats.NOfIncorrectHead++
+			}
 		}
 	}
 
+	if networkStats.NOfActiveValidators > 0 {
+		active := float64(networkStats.NOfActiveValidators)
+		networkStats.SourceParticipation = 1 - float64(networkStats.NOfIncorrectSource)/active
+		networkStats.TargetParticipation = 1 - float64(networkStats.NOfIncorrectTarget)/active
+		networkStats.HeadParticipation = 1 - float64(networkStats.NOfIncorrectHead)/active
+		networkStats.AttestationEffectiveness = GetAttestationRate(
+			networkStats.NOfActiveValidators,
+			networkStats.NOfIncorrectSource,
+			networkStats.NOfIncorrectTarget,
+			networkStats.NOfIncorrectHead)
+	}
+	if proposalMetrics != nil && len(proposalMetrics.Scheduled) > 0 {
+		networkStats.NOfScheduledBlocks = uint64(len(proposalMetrics.Scheduled))
+		networkStats.NOfProposedBlocks = uint64(len(proposalMetrics.Proposed))
+		networkStats.ProposalRate = float64(networkStats.NOfProposedBlocks) / float64(networkStats.NOfScheduledBlocks)
+	}
+
 	log.WithFields(log.Fields{
 		"Total Validators":         len(validators),
 		"Total Slashed Validators": networkStats.NOfSlashedValidators,
 		"Total Exited Validators":  networkStats.NOfExitedValidators,
 		"Total Active Validators":  networkStats.NOfActiveValidators,
+		"Source Participation":     networkStats.SourceParticipation,
+		"Target Participation":     networkStats.TargetParticipation,
+		"Head Participation":       networkStats.HeadParticipation,
+		"Proposal Rate":            networkStats.ProposalRate,
 	}).Info("Network stats:")
 
 	return networkStats, nil
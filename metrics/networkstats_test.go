@@ -2,17 +2,20 @@ package metrics
 
 import (
 	"testing"
+	"time"
 
 	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/altair"
 	"github.com/attestantio/go-eth2-client/spec/deneb"
 	"github.com/attestantio/go-eth2-client/spec/fulu"
 	"github.com/attestantio/go-eth2-client/spec/phase0"
 	"github.com/bilinearlabs/eth-metrics/db"
+	"github.com/bilinearlabs/eth-metrics/schemas"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestGetNetworkStats_Success(t *testing.T) {
-	networkStats, err := NewNetworkStats(&db.Database{})
+	networkStats, err := NewNetworkStats(&NetworkParameters{genesisSeconds: 1606824023, slotsInEpoch: 32, secondsPerSlot: 12}, &db.Database{})
 	if err != nil {
 		t.Fatalf("Error creating network stats: %v", err)
 	}
@@ -47,10 +50,50 @@ func TestGetNetworkStats_Success(t *testing.T) {
 			},
 		},
 	}
-	networkStatsResult, err := networkStats.GetNetworkStats(1, beaconState)
+	networkStatsResult, err := networkStats.GetNetworkStats(1, beaconState, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, uint64(1), networkStatsResult.NOfSlashedValidators)
 	assert.Equal(t, uint64(2), networkStatsResult.NOfExitedValidators)
 	assert.Equal(t, uint64(1), networkStatsResult.NOfActiveValidators)
+	// The start of the epoch, not the time of its last block
+	assert.Equal(t, time.Unix(1606824023+32*12, 0).UTC(), networkStatsResult.Time)
 	assert.NotNil(t, networkStatsResult)
 }
+
+func TestGetNetworkStats_Participation(t *testing.T) {
+	networkStats, err := NewNetworkStats(&NetworkParameters{genesisSeconds: 1606824023, slotsInEpoch: 32, secondsPerSlot: 12}, &db.Database{})
+	assert.NoError(t, err)
+
+	// 4 active validators: one with all votes, one without head, one without
+	// any vote and one slashed, whose votes are not counted as missed
+	beaconState := &spec.VersionedBeaconState{
+		Fulu: &fulu.BeaconState{
+			Validators: []*phase0.Validator{
+				{ExitEpoch: 100},
+				{ExitEpoch: 100},
+				{ExitEpoch: 100},
+				{ExitEpoch: 100, Slashed: true},
+				{ExitEpoch: 100, ActivationEpoch: 50},
+			},
+			PreviousEpochParticipation: []altair.ParticipationFlags{0b111, 0b011, 0, 0, 0},
+			LatestExecutionPayloadHeader: &deneb.ExecutionPayloadHeader{
+				Timestamp: 1673308800,
+			},
+		},
+	}
+	proposalMetrics := &schemas.ProposalDutiesMetrics{
+		Scheduled: make([]schemas.Duty, 32),
+		Proposed:  make([]schemas.Duty, 31),
+	}
+	stats, err := networkStats.GetNetworkStats(1, beaconState, proposalMetrics)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), stats.NOfActiveValidators)
+	assert.Equal(t, uint64(1), stats.NOfIncorrectSource)
+	assert.Equal(t, uint64(2), stats.NOfIncorrectHead)
+	assert.Equal(t, 0.75, stats.SourceParticipation)
+	assert.Equal(t, 0.75, stats.TargetParticipation)
+	assert.Equal(t, 0.5, stats.HeadParticipation)
+	assert.InDelta(t, 1-(54+14)/(54*4.0), stats.AttestationEffectiveness, 1e-9)
+	assert.Equal(t, uint64(31), stats.NOfProposedBlocks)
+	assert.InDelta(t, 31/32.0, stats.ProposalRate, 1e-9)
+}
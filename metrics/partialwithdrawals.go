@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"math/big"
+	"slices"
+
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/electra"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Returns the partial withdrawals requested under EIP-7002 that were processed
+// between two consecutive states, by validator index. The queue is processed
+// from the front, so they are the ones removed from it. Processed requests are
+// withdrawn or skipped, which is told by the withdrawals of the blocks.
+func GetProcessedPartialWithdrawals(
+	prevBeaconState *spec.VersionedBeaconState,
+	currentBeaconState *spec.VersionedBeaconState,
+) (map[uint64][]*electra.PendingPartialWithdrawal, error) {
+	processed := make(map[uint64][]*electra.PendingPartialWithdrawal)
+
+	prevPending := GetPendingPartialWithdrawals(prevBeaconState)
+	currPending := GetPendingPartialWithdrawals(currentBeaconState)
+	if prevPending == nil || currPending == nil {
+		return processed, nil
+	}
+
+	nOfRemoved := GetNumberOfRemovedPartialWithdrawals(prevPending, currPending)
+	if nOfRemoved < 0 {
+		return nil, errors.New("pending partial withdrawals queue does not match the previous one")
+	}
+	for _, withdrawal := range prevPending[:nOfRemoved] {
+		valIdx := uint64(withdrawal.ValidatorIndex)
+		processed[valIdx] = append(processed[valIdx], withdrawal)
+	}
+	return processed, nil
+}
+
+// Number of partial withdrawals processed from the front of the queue, as in
+// GetNumberOfRemovedDeposits. Returns -1 if there is none.
+func GetNumberOfRemovedPartialWithdrawals(
+	prevPending []*electra.PendingPartialWithdrawal,
+	currPending []*electra.PendingPartialWithdrawal) int {
+
+	for removed := 0; removed <= len(prevPending); removed++ {
+		remaining := prevPending[removed:]
+		if len(remaining) > len(currPending) {
+			continue
+		}
+		if slices.EqualFunc(remaining, currPending[:len(remaining)], func(a, b *electra.PendingPartialWithdrawal) bool {
+			return *a == *b
+		}) {
+			return removed
+		}
+	}
+	return -1
+}
+
+// Returns the partial withdrawals of the pool that are waiting in the queue of
+// the state, and the ones processed in the epoch with the amount the blocks
+// withdrew for them
+func GetPoolPartialWithdrawals(
+	epoch uint64,
+	poolName string,
+	validatorIndexes []uint64,
+	beaconState *spec.VersionedBeaconState,
+	processed map[uint64][]*electra.PendingPartialWithdrawal,
+	withdrawals map[uint64]*big.Int,
+) (schemas.PendingPartialWithdrawals, []schemas.PartialWithdrawal) {
+	tracked := make(map[uint64]bool, len(validatorIndexes))
+	for _, valIdx := range validatorIndexes {
+		tracked[valIdx] = true
+	}
+
+	pending := schemas.PendingPartialWithdrawals{Epoch: epoch, PoolName: poolName}
+	for _, withdrawal := range GetPendingPartialWithdrawals(beaconState) {
+		if !tracked[uint64(withdrawal.ValidatorIndex)] {
+			continue
+		}
+		pending.NOfPending++
+		pending.PendingGwei += uint64(withdrawal.Amount)
+		pending.LastWithdrawableEpoch = max(pending.LastWithdrawableEpoch, uint64(withdrawal.WithdrawableEpoch))
+	}
+
+	partialWithdrawals := make([]schemas.PartialWithdrawal, 0)
+	for _, valIdx := range validatorIndexes {
+		requests, ok := processed[valIdx]
+		if !ok {
+			continue
+		}
+		partialWithdrawal := schemas.PartialWithdrawal{
+			Epoch:       epoch,
+			ValIndex:    valIdx,
+			PoolName:    poolName,
+			NOfRequests: uint64(len(requests)),
+		}
+		for _, request := range requests {
+			partialWithdrawal.RequestedGwei += uint64(request.Amount)
+			partialWithdrawal.WithdrawableEpoch = max(partialWithdrawal.WithdrawableEpoch, uint64(request.WithdrawableEpoch))
+		}
+		// Withdrawals of 0x02 validators are only the requested ones, unless
+		// the balance exceeds the max effective balance
+		if withdrawn, ok := withdrawals[valIdx]; ok && withdrawn.IsUint64() {
+			partialWithdrawal.WithdrawnGwei = min(withdrawn.Uint64(), partialWithdrawal.RequestedGwei)
+		}
+		partialWithdrawals = append(partialWithdrawals, partialWithdrawal)
+	}
+	return pending, partialWithdrawals
+}
+
+func logPartialWithdrawals(pending schemas.PendingPartialWithdrawals, partialWithdrawals []schemas.PartialWithdrawal) {
+	if pending.NOfPending > 0 {
+		log.WithFields(log.Fields{
+			"Epoch":                 pending.Epoch,
+			"PoolName":              pending.PoolName,
+			"NOfPending":            pending.NOfPending,
+			"PendingGwei":           pending.PendingGwei,
+			"LastWithdrawableEpoch": pending.LastWithdrawableEpoch,
+		}).Info("Pending partial withdrawals")
+	}
+	for _, withdrawal := range partialWithdrawals {
+		logger := log.WithFields(log.Fields{
+			"Epoch":         withdrawal.Epoch,
+			"PoolName":      withdrawal.PoolName,
+			"ValIndex":      withdrawal.ValIndex,
+			"RequestedGwei": withdrawal.RequestedGwei,
+			"WithdrawnGwei": withdrawal.WithdrawnGwei,
+		})
+		if withdrawal.WithdrawnGwei < withdrawal.RequestedGwei {
+			logger.Warn("Partial withdrawal processed for less than requested")
+		} else {
+			logger.Info("Partial withdrawal processed")
+		}
+	}
+}
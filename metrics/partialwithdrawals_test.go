@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/deneb"
+	"github.com/attestantio/go-eth2-client/spec/electra"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/stretchr/testify/require"
+)
+
+func electraStateWithPartialWithdrawals(pending []*electra.PendingPartialWithdrawal) *spec.VersionedBeaconState {
+	return &spec.VersionedBeaconState{Electra: &electra.BeaconState{PendingPartialWithdrawals: pending}}
+}
+
+func Test_GetProcessedPartialWithdrawals(t *testing.T) {
+	w1 := &electra.PendingPartialWithdrawal{ValidatorIndex: 1, Amount: 1000000000, WithdrawableEpoch: 10}
+	w2 := &electra.PendingPartialWithdrawal{ValidatorIndex: 2, Amount: 2000000000, WithdrawableEpoch: 10}
+	w3 := &electra.PendingPartialWithdrawal{ValidatorIndex: 1, Amount: 3000000000, WithdrawableEpoch: 11}
+	w4 := &electra.PendingPartialWithdrawal{ValidatorIndex: 3, Amount: 4000000000, WithdrawableEpoch: 12}
+
+	// Two processed from the front and one requested at the end
+	processed, err := GetProcessedPartialWithdrawals(
+		electraStateWithPartialWithdrawals([]*electra.PendingPartialWithdrawal{w1, w2, w3}),
+		electraStateWithPartialWithdrawals([]*electra.PendingPartialWithdrawal{{ValidatorIndex: 1, Amount: 3000000000, WithdrawableEpoch: 11}, w4}))
+	require.NoError(t, err)
+	require.Equal(t, map[uint64][]*electra.PendingPartialWithdrawal{1: {w1}, 2: {w2}}, processed)
+
+	// Same withdrawal requested again after the previous one was processed
+	require.Equal(t, 1, GetNumberOfRemovedPartialWithdrawals(
+		[]*electra.PendingPartialWithdrawal{w1},
+		[]*electra.PendingPartialWithdrawal{{ValidatorIndex: 1, Amount: 1000000000, WithdrawableEpoch: 12}}))
+	require.Equal(t, 0, GetNumberOfRemovedPartialWithdrawals(
+		[]*electra.PendingPartialWithdrawal{w1},
+		[]*electra.PendingPartialWithdrawal{w1, w2}))
+}
+
+func Test_GetPoolPartialWithdrawals(t *testing.T) {
+	state := electraStateWithPartialWithdrawals([]*electra.PendingPartialWithdrawal{
+		{ValidatorIndex: 1, Amount: 1000000000, WithdrawableEpoch: 20},
+		{ValidatorIndex: 9, Amount: 5000000000, WithdrawableEpoch: 20},
+		{ValidatorIndex: 2, Amount: 2000000000, WithdrawableEpoch: 21},
+	})
+	processed := map[uint64][]*electra.PendingPartialWithdrawal{
+		1: {
+			{ValidatorIndex: 1, Amount: 1000000000, WithdrawableEpoch: 10},
+			{ValidatorIndex: 1, Amount: 2000000000, WithdrawableEpoch: 11},
+		},
+		2: {{ValidatorIndex: 2, Amount: 1000000000, WithdrawableEpoch: 11}},
+		3: {{ValidatorIndex: 3, Amount: 1000000000, WithdrawableEpoch: 11}},
+		9: {{ValidatorIndex: 9, Amount: 1000000000, WithdrawableEpoch: 11}},
+	}
+	// Validator 2 only had 0.5 ETH above 32 ETH, and 3 was skipped
+	withdrawals := map[uint64]*big.Int{
+		1: big.NewInt(3000000000),
+		2: big.NewInt(500000000),
+		5: big.NewInt(20000000),
+	}
+
+	pending, partialWithdrawals := GetPoolPartialWithdrawals(12, "pool_a", []uint64{1, 2, 3}, state, processed, withdrawals)
+	require.Equal(t, schemas.PendingPartialWithdrawals{
+		Epoch:                 12,
+		PoolName:              "pool_a",
+		NOfPending:            2,
+		PendingGwei:           3000000000,
+		LastWithdrawableEpoch: 21,
+	}, pending)
+	require.Equal(t, []schemas.PartialWithdrawal{
+		{Epoch: 12, ValIndex: 1, PoolName: "pool_a", NOfRequests: 2, WithdrawableEpoch: 11, RequestedGwei: 3000000000, WithdrawnGwei: 3000000000},
+		{Epoch: 12, ValIndex: 2, PoolName: "pool_a", NOfRequests: 1, WithdrawableEpoch: 11, RequestedGwei: 1000000000, WithdrawnGwei: 500000000},
+		{Epoch: 12, ValIndex: 3, PoolName: "pool_a", NOfRequests: 1, WithdrawableEpoch: 11, RequestedGwei: 1000000000, WithdrawnGwei: 0},
+	}, partialWithdrawals)
+
+	// Nothing before electra
+	pending, partialWithdrawals = GetPoolPartialWithdrawals(12, "pool_a", []uint64{1}, &spec.VersionedBeaconState{Deneb: &deneb.BeaconState{}}, nil, withdrawals)
+	require.Equal(t, uint64(0), pending.NOfPending)
+	require.Empty(t, partialWithdrawals)
+}
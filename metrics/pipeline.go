@@ -0,0 +1,496 @@
+package metrics
+
+import (
+	"context"
+	"maps"
+	"math/big"
+	"slices"
+	"sync"
+	"time"
+
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/electra"
+	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	moduleBeaconState    = "beacon-state"
+	moduleProposalDuties = "proposal-duties"
+	moduleEpochBlocks    = "epoch-blocks"
+	moduleMissedDuties   = "missed-duties"
+)
+
+var (
+	moduleDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ethmetrics_module_duration_seconds",
+		Help:    "Duration of each module of the epoch processing",
+		Buckets: processingBuckets,
+	}, []string{"module"})
+	moduleErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ethmetrics_module_errors_total",
+		Help: "Epochs processed without the data of a module because it failed",
+	}, []string{"module"})
+)
+
+// Data fetched by the modules for an epoch. Each module only writes its own
+// fields, which the modules depending on it read once it is done. The fields
+// of disabled or failed optional modules are left empty.
+type epochData struct {
+	ctx   context.Context
+	epoch uint64
+	// Stored with the rows of the pools
+	processingMode schemas.ProcessingMode
+
+	// Modules that ran without errors, sorted
+	completedModules []string
+
+	// beacon-state
+	currentBeaconState      *spec.VersionedBeaconState
+	prevBeaconState         *spec.VersionedBeaconState
+	valKeyToIndex           map[string]uint64
+	processedConsolidations map[uint64][]*electra.PendingConsolidation
+	// Partial withdrawal requests that left the queue, by validator index
+	processedPartialWithdrawals map[uint64][]*electra.PendingPartialWithdrawal
+	depositBalanceChanges       map[uint64]*big.Int
+
+	// proposal-duties
+	proposed        []*v1.BeaconBlockHeader
+	proposalMetrics schemas.ProposalDutiesMetrics
+
+	// missed-duties, by slot
+	missedReasons map[uint64]schemas.MissedReason
+
+	// epoch-blocks, by slot
+	blocks map[uint64]*spec.VersionedSignedBeaconBlock
+
+	// relay-rewards
+	relayRewardsPerPool map[string]*big.Int
+	slotsWithMEVRewards map[uint64]schemas.RelayPayload
+	mevPartial          bool
+
+	// block-data
+	epochBlockData *EpochBlockData
+
+	// inclusion-delay, and the validators with double votes
+	inclusionDelays  map[uint64]uint64
+	conflictingVotes []uint64
+
+	// attestation-rewards
+	attestationRewards *v1.AttestationRewards
+
+	// sync-committee-rewards
+	syncCommitteeRewards map[uint64]*big.Int
+
+	// proposer-rewards, by slot and by proposer
+	blockRewards    map[uint64]*v1.BlockRewards
+	proposerRewards map[uint64]*big.Int
+
+	// client-diversity, by slot. Nil if not run
+	blockClients map[uint64]blockClients
+
+	// missed-mev, best relay bid of the vanilla blocks of the tracked
+	// validators, by slot
+	bestBids map[uint64]*schemas.RelayBid
+
+	// network-stats, nil if not run
+	networkStats *schemas.NetworkStats
+	// Rated from the network stats before the pools, to compare them with it
+	networkEffectiveness *schemas.Effectiveness
+}
+
+// Step of the epoch processing, run once the modules it reads from are done
+type epochModule struct {
+	name   string
+	inputs []string
+	// If an optional module fails, the epoch is processed without its data,
+	// e.g. without MEV rewards if the relays are down
+	optional bool
+	run      func(data *epochData) error
+}
+
+func (a *Metrics) epochModules() []epochModule {
+	return []epochModule{
+		{
+			name: moduleBeaconState,
+			run:  a.runBeaconStateModule,
+		},
+		{
+			name: moduleProposalDuties,
+			run:  a.runProposalDutiesModule,
+		},
+		{
+			// Headers of the missed slots are fetched here, since the pools
+			// are processed while the database is locked
+			name:   moduleMissedDuties,
+			inputs: []string{moduleProposalDuties},
+			run:    a.runMissedDutiesModule,
+		},
+		{
+			// Blocks are fetched once for all the modules parsing them
+			name: moduleEpochBlocks,
+			run:  a.runEpochBlocksModule,
+		},
+		{
+			// Validators of pools defined by withdrawal address or by index are found in the state
+			name:     config.ModuleRelayRewards,
+			inputs:   []string{moduleBeaconState},
+			optional: a.config.RelayFailureMode == config.RelayFailureSkip,
+			run:      a.runRelayRewardsModule,
+		},
+		{
+			// Withdrawals affect the balances, so it is not optional
+			name:   config.ModuleBlockData,
+			inputs: []string{moduleEpochBlocks, config.ModuleRelayRewards},
+			run:    a.runBlockDataModule,
+		},
+		{
+			name:     config.ModuleInclusionDelay,
+			inputs:   []string{moduleEpochBlocks},
+			optional: true,
+			run:      a.runInclusionDelayModule,
+		},
+		{
+			name:     config.ModuleAttestationRewards,
+			inputs:   []string{moduleBeaconState},
+			optional: true,
+			run:      a.runAttestationRewardsModule,
+		},
+		{
+			// Blocks are taken from the proposal duties
+			name:     config.ModuleSyncCommitteeRewards,
+			inputs:   []string{moduleBeaconState, moduleProposalDuties},
+			optional: true,
+			run:      a.runSyncCommitteeModule,
+		},
+		{
+			name:     config.ModuleProposerRewards,
+			inputs:   []string{moduleBeaconState, moduleProposalDuties},
+			optional: true,
+			run:      a.runProposerRewardsModule,
+		},
+		{
+			// Clients are guessed from the graffiti of the blocks
+			name:     config.ModuleClientDiversity,
+			inputs:   []string{config.ModuleBlockData},
+			optional: true,
+			run:      a.runClientDiversityModule,
+		},
+		{
+			// Blocks whose payload no relay delivered are the vanilla ones
+			name:     config.ModuleMissedMEV,
+			inputs:   []string{moduleBeaconState, moduleProposalDuties, config.ModuleRelayRewards},
+			optional: true,
+			run:      a.runMissedMEVModule,
+		},
+		{
+			// Blob usage is taken from the blocks
+			name:     config.ModuleNetworkStats,
+			inputs:   []string{moduleBeaconState, moduleProposalDuties, config.ModuleBlockData},
+			optional: true,
+			run:      a.runNetworkStatsModule,
+		},
+	}
+}
+
+// Runs the enabled modules concurrently, each one as soon as its inputs are
+// done, filling the epoch data. Fails if a module that is not optional fails.
+func (a *Metrics) runEpochModules(modules []epochModule, data *epochData) error {
+	done := make(map[string]chan struct{})
+	for _, module := range modules {
+		done[module.name] = make(chan struct{})
+	}
+
+	var mu sync.Mutex
+	failed := make(map[string]bool)
+	var errs []error
+
+	var wg sync.WaitGroup
+	for _, module := range modules {
+		wg.Go(func() {
+			defer close(done[module.name])
+			for _, input := range module.inputs {
+				<-done[input]
+			}
+
+			mu.Lock()
+			skip := slices.ContainsFunc(module.inputs, func(input string) bool { return failed[input] })
+			mu.Unlock()
+			if skip {
+				mu.Lock()
+				failed[module.name] = true
+				mu.Unlock()
+				return
+			}
+			if slices.Contains(a.config.DisabledModules, module.name) {
+				return
+			}
+
+			start := time.Now()
+			err := module.run(data)
+			moduleDuration.WithLabelValues(module.name).Observe(time.Since(start).Seconds())
+			if err == nil {
+				mu.Lock()
+				data.completedModules = append(data.completedModules, module.name)
+				mu.Unlock()
+				return
+			}
+			if module.optional {
+				moduleErrors.WithLabelValues(module.name).Inc()
+				log.WithField("Epoch", data.epoch).Error("Processing epoch without ", module.name, ": ", err)
+				return
+			}
+			mu.Lock()
+			failed[module.name] = true
+			errs = append(errs, err)
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+	slices.Sort(data.completedModules)
+
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+func (a *Metrics) runBeaconStateModule(data *epochData) error {
+	var err error
+	data.currentBeaconState, err = a.getBeaconState(data.ctx, data.epoch)
+	if err != nil {
+		return errors.Wrap(err, "error fetching beacon state")
+	}
+
+	// if no prev beacon state is known, fetch it
+	if data.prevBeaconState == nil {
+		data.prevBeaconState, err = a.getBeaconState(data.ctx, data.epoch-1)
+		if err != nil {
+			return errors.Wrap(err, "error fetching previous beacon state")
+		}
+	}
+
+	a.AddValidatorsByWithdrawalAddress(data.currentBeaconState)
+	a.AddValidatorsByIndex(data.currentBeaconState)
+
+	// Map to quickly convert public keys to index
+	data.valKeyToIndex = PopulateKeysToIndexesMap(data.currentBeaconState)
+
+	data.processedConsolidations, err = GetProcessedConsolidations(data.prevBeaconState, data.currentBeaconState)
+	if err != nil {
+		return errors.Wrap(err, "error getting processed consolidations")
+	}
+
+	data.processedPartialWithdrawals, err = GetProcessedPartialWithdrawals(data.prevBeaconState, data.currentBeaconState)
+	if err != nil {
+		return errors.Wrap(err, "error getting processed partial withdrawals")
+	}
+
+	data.depositBalanceChanges, err = GetDepositBalanceChanges(data.prevBeaconState, data.currentBeaconState, data.valKeyToIndex)
+	if err != nil {
+		return errors.Wrap(err, "error getting deposit balance changes")
+	}
+	return nil
+}
+
+func (a *Metrics) runProposalDutiesModule(data *epochData) error {
+	// Fetch proposal duties, meaning who shall propose each block within this epoch
+	duties, err := a.proposalDuties.GetProposalDuties(data.epoch)
+	if err != nil {
+		return errors.Wrap(err, "error getting proposal duties")
+	}
+
+	// Fetch who actually proposed the blocks in this epoch
+	data.proposed, err = a.proposalDuties.GetProposedBlocks(data.epoch)
+	if err != nil {
+		return errors.Wrap(err, "error getting proposed blocks")
+	}
+
+	// Summarize duties + proposed in a struct
+	data.proposalMetrics, err = a.proposalDuties.GetProposalMetrics(duties, data.proposed)
+	if err != nil {
+		return errors.Wrap(err, "error getting proposal metrics")
+	}
+	return nil
+}
+
+func (a *Metrics) runMissedDutiesModule(data *epochData) error {
+	missedReasons, err := a.proposalDuties.ClassifyMissedDuties(&data.proposalMetrics)
+	if err != nil {
+		return errors.Wrap(err, "could not classify missed duties")
+	}
+	data.missedReasons = missedReasons
+	return nil
+}
+
+func (a *Metrics) runEpochBlocksModule(data *epochData) error {
+	if slices.Contains(a.config.DisabledModules, config.ModuleBlockData) &&
+		slices.Contains(a.config.DisabledModules, config.ModuleInclusionDelay) {
+		return nil
+	}
+	blocks, err := a.epochBlocks.GetEpochBlocks(data.ctx, data.epoch)
+	if err != nil {
+		return errors.Wrap(err, "error getting epoch blocks")
+	}
+	data.blocks = blocks
+	return nil
+}
+
+func (a *Metrics) runRelayRewardsModule(data *epochData) error {
+	relayRewardsPerPool, slotsWithMEVRewards, err := a.relayRewards.GetRelayRewards(data.epoch)
+	// Stored even if some relay failed, since outages are what they reveal
+	for _, relayStats := range a.relayRewards.GetRelayStats() {
+		if relayStats.Skipped {
+			data.mevPartial = true
+		}
+		if a.db != nil {
+			if err := a.db.StoreRelayStats(relayStats); err != nil {
+				log.Error("Could not store relay stats: ", err)
+			}
+		}
+	}
+	if err != nil {
+		data.mevPartial = true
+		return errors.Wrap(err, "error getting relay rewards")
+	}
+	data.relayRewardsPerPool = relayRewardsPerPool
+	data.slotsWithMEVRewards = slotsWithMEVRewards
+	return nil
+}
+
+func (a *Metrics) runMissedMEVModule(data *epochData) error {
+	if slices.Contains(a.config.DisabledModules, config.ModuleRelayRewards) {
+		return nil
+	}
+	// A payload of a skipped relay would be taken as a vanilla block
+	if data.mevPartial {
+		return errors.New("the relay payloads of the epoch are partial")
+	}
+
+	tracked := a.trackedIndexes(data.valKeyToIndex)
+	bestBids := make(map[uint64]*schemas.RelayBid)
+	for _, block := range data.proposed {
+		if block == nil || block.Header == nil || block.Header.Message == nil {
+			continue
+		}
+		if !tracked[uint64(block.Header.Message.ProposerIndex)] {
+			continue
+		}
+		slot := uint64(block.Header.Message.Slot)
+		if _, ok := data.slotsWithMEVRewards[slot]; ok {
+			continue
+		}
+		bestBid, err := a.relayRewards.GetBestBid(slot)
+		if err != nil {
+			return errors.Wrap(err, "error getting best bid")
+		}
+		if bestBid != nil {
+			bestBids[slot] = bestBid
+		}
+	}
+	data.bestBids = bestBids
+	return nil
+}
+
+func (a *Metrics) runBlockDataModule(data *epochData) error {
+	// Get withdrawals and proposer tips from all blocks of the epoch
+	epochBlockData, err := a.blockData.GetEpochBlockData(data.epoch, data.blocks, data.slotsWithMEVRewards)
+	if err != nil {
+		return errors.Wrap(err, "error getting epoch block data")
+	}
+	data.epochBlockData = epochBlockData
+	return nil
+}
+
+func (a *Metrics) runInclusionDelayModule(data *epochData) error {
+	// Usually kept since the previous epoch was processed
+	prevBlocks, err := a.epochBlocks.GetEpochBlocks(data.ctx, data.epoch-1)
+	if err != nil {
+		return errors.Wrap(err, "error getting previous epoch blocks")
+	}
+	blocks := make(map[uint64]*spec.VersionedSignedBeaconBlock, len(prevBlocks)+len(data.blocks))
+	maps.Copy(blocks, prevBlocks)
+	maps.Copy(blocks, data.blocks)
+
+	inclusionDelays, conflictingVotes, err := a.inclusionDelay.GetEpochInclusionDelays(data.epoch, blocks)
+	if err != nil {
+		return errors.Wrap(err, "error getting inclusion delays")
+	}
+	data.inclusionDelays = inclusionDelays
+	data.conflictingVotes = conflictingVotes
+	return nil
+}
+
+func (a *Metrics) runClientDiversityModule(data *epochData) error {
+	data.blockClients = GetBlockClients(data.epochBlockData.SlotGraffiti)
+	return nil
+}
+
+// Indexes of the validators of all pools. Validators found by fee recipient in
+// this epoch are only included from the next one.
+func (a *Metrics) trackedIndexes(valKeyToIndex map[string]uint64) map[uint64]bool {
+	trackedSet := make(map[uint64]bool)
+	for _, pubKeys := range a.validatorKeysPerPool {
+		for _, valIdx := range GetIndexesFromKeys(pubKeys, valKeyToIndex) {
+			trackedSet[valIdx] = true
+		}
+	}
+	return trackedSet
+}
+
+func (a *Metrics) runAttestationRewardsModule(data *epochData) error {
+	trackedSet := a.trackedIndexes(data.valKeyToIndex)
+	trackedIndexes := make([]uint64, 0, len(trackedSet))
+	for valIdx := range trackedSet {
+		trackedIndexes = append(trackedIndexes, valIdx)
+	}
+	attestationRewards, err := a.attestationRewards.GetAttestationRewards(data.epoch, trackedIndexes)
+	if err != nil {
+		return errors.Wrap(err, "error getting attestation rewards")
+	}
+	data.attestationRewards = attestationRewards
+	return nil
+}
+
+func (a *Metrics) runSyncCommitteeModule(data *epochData) error {
+	// Only 512 validators are in the committee, so most epochs no tracked
+	// validator is and nothing is fetched
+	members := GetSyncCommitteeMembers(data.currentBeaconState, data.valKeyToIndex, a.trackedIndexes(data.valKeyToIndex))
+	syncCommitteeRewards, err := a.syncCommitteeRewards.GetSyncCommitteeRewards(data.epoch, data.proposed, members)
+	if err != nil {
+		return errors.Wrap(err, "error getting sync committee rewards")
+	}
+	data.syncCommitteeRewards = syncCommitteeRewards
+	return nil
+}
+
+func (a *Metrics) runProposerRewardsModule(data *epochData) error {
+	blockRewards, err := a.proposalDuties.GetBlockRewards(data.epoch, data.proposed, a.trackedIndexes(data.valKeyToIndex))
+	if err != nil {
+		return errors.Wrap(err, "error getting proposer rewards")
+	}
+	data.blockRewards = blockRewards
+	data.proposerRewards = GetProposerRewards(blockRewards)
+	return nil
+}
+
+func (a *Metrics) runNetworkStatsModule(data *epochData) error {
+	// The light beacon state only contains the tracked validators
+	if a.config.LightMode {
+		return nil
+	}
+	networkStats, err := a.networkStats.Run(data.epoch, data.currentBeaconState, &data.proposalMetrics, data.epochBlockData.Blobs)
+	if err != nil {
+		return errors.Wrap(err, "error getting network stats")
+	}
+	data.networkStats = networkStats
+	if err := a.networkQueues.Run(data.epoch, data.currentBeaconState); err != nil {
+		return errors.Wrap(err, "error getting network queues")
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RunEpochModules(t *testing.T) {
+	var mu sync.Mutex
+	var ran []string
+	module := func(name string, inputs []string, optional bool, err error) epochModule {
+		return epochModule{
+			name:     name,
+			inputs:   inputs,
+			optional: optional,
+			run: func(data *epochData) error {
+				mu.Lock()
+				defer mu.Unlock()
+				ran = append(ran, name)
+				return err
+			},
+		}
+	}
+
+	metrics := &Metrics{config: &config.Config{DisabledModules: []string{"disabled"}}}
+	modules := []epochModule{
+		module("state", nil, false, nil),
+		module("relays", []string{"state"}, true, errors.New("relays down")),
+		module("blocks", []string{"relays"}, false, nil),
+		module("disabled", nil, true, nil),
+		module("after-disabled", []string{"disabled"}, true, nil),
+	}
+
+	// A failed optional module doesn't stop the others
+	data := &epochData{}
+	err := metrics.runEpochModules(modules, data)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"state", "relays", "blocks", "after-disabled"}, ran)
+	require.Equal(t, "state", ran[0])
+	require.Equal(t, []string{"after-disabled", "blocks", "state"}, data.completedModules)
+
+	// A failed module that is not optional fails the epoch and skips its dependents
+	ran = nil
+	modules[0] = module("state", nil, false, errors.New("no state"))
+	err = metrics.runEpochModules(modules, &epochData{})
+	require.EqualError(t, err, "no state")
+	require.ElementsMatch(t, []string{"state", "after-disabled"}, ran)
+}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/bilinearlabs/eth-metrics/pools"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	log "github.com/sirupsen/logrus"
+)
+
+// Adds to the pools defined by address the validators of the beacon state with
+// a matching withdrawal address, so that new keys are tracked as they appear
+func (a *Metrics) AddValidatorsByWithdrawalAddress(beaconState *spec.VersionedBeaconState) {
+	if len(a.addressToPool) == 0 {
+		return
+	}
+	for _, validator := range GetValidators(beaconState) {
+		address, ok := pools.GetWithdrawalAddress(validator.WithdrawalCredentials)
+		if !ok {
+			continue
+		}
+		if poolName, ok := a.addressToPool[address]; ok {
+			a.addValidatorToPool(validator.PublicKey[:], poolName)
+		}
+	}
+}
+
+// Adds to the pools defined by address the proposers whose fee recipient
+// matches. Fee recipients are not in the beacon state, so these validators
+// are only found once they propose a block.
+func (a *Metrics) AddValidatorsByFeeRecipient(
+	beaconState *spec.VersionedBeaconState,
+	feeRecipients map[uint64]string,
+	relayPayloads map[uint64]schemas.RelayPayload) {
+
+	if len(a.addressToPool) == 0 {
+		return
+	}
+	validators := GetValidators(beaconState)
+	for proposerIndex, feeRecipient := range feeRecipients {
+		poolName, ok := a.addressToPool[feeRecipient]
+		if !ok || proposerIndex >= uint64(len(validators)) {
+			continue
+		}
+		a.addValidatorToPool(validators[proposerIndex].PublicKey[:], poolName)
+	}
+	for _, payload := range relayPayloads {
+		poolName, ok := a.addressToPool[payload.ProposerFeeRecipient]
+		if !ok {
+			continue
+		}
+		pubKey, err := hexutil.Decode(payload.ProposerPubKey)
+		if err != nil {
+			log.Warn("could not decode proposer key: ", payload.ProposerPubKey)
+			continue
+		}
+		a.addValidatorToPool(pubKey, poolName)
+	}
+}
+
+// Tracks the validator in the pool, unless it is already tracked. These keys
+// are kept when the key files are reloaded. Returns if it was added.
+func (a *Metrics) addValidatorToPool(pubKey []byte, poolName string) bool {
+	keyStr := hexutil.Encode(pubKey)
+	if _, ok := a.validatorKeyToPool[keyStr]; ok {
+		return false
+	}
+	if a.leftPool(keyStr, poolName) {
+		return false
+	}
+	key := make([]byte, len(pubKey))
+	copy(key, pubKey)
+	a.validatorKeysPerPool[poolName] = append(a.validatorKeysPerPool[poolName], key)
+	a.validatorKeyToPool[keyStr] = poolName
+	if a.keysFoundByAddress == nil {
+		a.keysFoundByAddress = make(map[string]string)
+	}
+	a.keysFoundByAddress[keyStr] = poolName
+	log.WithField("PoolName", poolName).Info("Validator ", keyStr, " added to pool")
+	return true
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/electra"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AddValidatorsByAddress(t *testing.T) {
+	credentials := func(prefix byte, address byte) []byte {
+		c := make([]byte, 32)
+		c[0] = prefix
+		c[31] = address
+		return c
+	}
+	beaconState := &spec.VersionedBeaconState{
+		Electra: &electra.BeaconState{
+			Validators: []*phase0.Validator{
+				{PublicKey: phase0.BLSPubKey{0x00}, WithdrawalCredentials: credentials(0x01, 0xaa)},
+				{PublicKey: phase0.BLSPubKey{0x01}, WithdrawalCredentials: credentials(0x02, 0xaa)},
+				// BLS credentials are ignored even if the bytes match
+				{PublicKey: phase0.BLSPubKey{0x02}, WithdrawalCredentials: credentials(0x00, 0xaa)},
+				// Already tracked in another pool
+				{PublicKey: phase0.BLSPubKey{0x03}, WithdrawalCredentials: credentials(0x01, 0xaa)},
+				{PublicKey: phase0.BLSPubKey{0x04}, WithdrawalCredentials: credentials(0x01, 0xbb)},
+				{PublicKey: phase0.BLSPubKey{0x05}, WithdrawalCredentials: credentials(0x01, 0xbb)},
+			},
+		},
+	}
+	trackedKey := phase0.BLSPubKey{0x03}
+	m := &Metrics{
+		validatorKeysPerPool: map[string][][]byte{"pool_b": {trackedKey[:]}},
+		validatorKeyToPool:   map[string]string{hexutil.Encode(trackedKey[:]): "pool_b"},
+		addressToPool: map[string]string{
+			"0x00000000000000000000000000000000000000aa": "pool_a",
+			"0x00000000000000000000000000000000000000fe": "pool_c",
+		},
+	}
+
+	m.AddValidatorsByWithdrawalAddress(beaconState)
+	require.Equal(t, 2, len(m.validatorKeysPerPool["pool_a"]))
+	require.Equal(t, 1, len(m.validatorKeysPerPool["pool_b"]))
+
+	// Scanning again does not duplicate the keys
+	m.AddValidatorsByWithdrawalAddress(beaconState)
+	require.Equal(t, 2, len(m.validatorKeysPerPool["pool_a"]))
+
+	key5 := phase0.BLSPubKey{0x05}
+	m.AddValidatorsByFeeRecipient(
+		beaconState,
+		map[uint64]string{4: "0x00000000000000000000000000000000000000fe"},
+		map[uint64]schemas.RelayPayload{
+			100: {ProposerPubKey: hexutil.Encode(key5[:]), ProposerFeeRecipient: "0x00000000000000000000000000000000000000fe"},
+		})
+	require.Equal(t, 2, len(m.validatorKeysPerPool["pool_c"]))
+	require.Equal(t, "pool_c", m.validatorKeyToPool[hexutil.Encode(key5[:])])
+}
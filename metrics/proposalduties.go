@@ -2,8 +2,14 @@ package metrics
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
 	"strconv"
 	"strings"
+	"time"
+
+	nethttp "net/http"
 
 	apiOther "github.com/attestantio/go-eth2-client/api"
 	api "github.com/attestantio/go-eth2-client/api/v1"
@@ -11,6 +17,8 @@ import (
 	"github.com/attestantio/go-eth2-client/spec/phase0"
 	"github.com/bilinearlabs/eth-metrics/config"
 	"github.com/bilinearlabs/eth-metrics/db"
+	"github.com/bilinearlabs/eth-metrics/pools"
+	"github.com/bilinearlabs/eth-metrics/publish"
 
 	"github.com/bilinearlabs/eth-metrics/schemas"
 	"github.com/pkg/errors"
@@ -21,47 +29,277 @@ type ProposalDuties struct {
 	consensus         *http.Service
 	networkParameters *NetworkParameters
 	database          *db.Database
+	clickHouse        *publish.ClickHouse
 	config            *config.Config
+	httpClient        *nethttp.Client
+	addressToPool     map[string]string
 }
 
 func NewProposalDuties(
 	consensus *http.Service,
 	networkParameters *NetworkParameters,
 	database *db.Database,
+	clickHouse *publish.ClickHouse,
 	config *config.Config) (*ProposalDuties, error) {
 
+	addressToPool, err := pools.ParsePoolAddresses(config.PoolAddresses)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing pool addresses")
+	}
 	return &ProposalDuties{
 		consensus:         consensus,
 		networkParameters: networkParameters,
 		database:          database,
+		clickHouse:        clickHouse,
 		config:            config,
+		httpClient:        &nethttp.Client{Timeout: 60 * time.Second},
+		addressToPool:     addressToPool,
 	}, nil
 }
 
 func (p *ProposalDuties) RunProposalMetrics(
 	activeKeys []uint64,
 	poolName string,
-	metrics *schemas.ProposalDutiesMetrics) error {
+	metrics *schemas.ProposalDutiesMetrics,
+	missedReasons map[uint64]schemas.MissedReason,
+	relayPayloads map[uint64]schemas.RelayPayload,
+	slotFeeRecipients map[uint64]string) (*schemas.ProposalDutiesMetrics, error) {
 
 	poolProposals := getPoolProposalDuties(
 		metrics,
 		poolName,
 		activeKeys)
 
+	for i, missed := range poolProposals.Missed {
+		reason, ok := missedReasons[missed.Slot]
+		if !ok {
+			return nil, errors.New("missed duty of slot " + strconv.FormatUint(missed.Slot, 10) + " was not classified")
+		}
+		poolProposals.Missed[i].MissedReason = reason
+	}
+
 	logProposalDuties(poolProposals, poolName)
 
+	var vanillaBlocks uint64
+	for _, proposed := range poolProposals.Proposed {
+		if IsVanillaBlock(proposed.Slot, poolName, relayPayloads, slotFeeRecipients, p.addressToPool) {
+			log.WithFields(log.Fields{
+				"PoolName": poolName,
+				"Slot":     proposed.Slot,
+			}).Warn("Block was not built via MEV-Boost")
+			vanillaBlocks++
+		}
+	}
+
 	if p.database != nil {
-		err := p.database.StoreProposalDuties(metrics.Epoch, poolName, uint64(len(poolProposals.Scheduled)), uint64(len(poolProposals.Proposed)))
+		var missedEmpty, missedOrphaned uint64
+		for _, missed := range poolProposals.Missed {
+			if missed.MissedReason == schemas.MissedOrphaned {
+				missedOrphaned++
+			} else {
+				missedEmpty++
+			}
+		}
+		err := p.database.StoreProposalDuties(
+			metrics.Epoch,
+			poolName,
+			uint64(len(poolProposals.Scheduled)),
+			uint64(len(poolProposals.Proposed)),
+			missedEmpty,
+			missedOrphaned,
+			vanillaBlocks)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not store proposal duties")
+		}
+	}
+	return poolProposals, nil
+
+}
+
+// Returns the reason of every missed duty of the epoch, by slot. The canonical
+// header endpoint only returns the block in the canonical chain, so all the
+// headers known by the node for the slot are fetched to tell orphaned blocks
+// apart from empty slots
+func (p *ProposalDuties) ClassifyMissedDuties(metrics *schemas.ProposalDutiesMetrics) (map[uint64]schemas.MissedReason, error) {
+	missedReasons := make(map[uint64]schemas.MissedReason)
+	for _, missed := range getMissedDuties(metrics.Scheduled, metrics.Proposed) {
+		headers, err := p.getSlotHeaders(missed.Slot)
 		if err != nil {
-			return errors.Wrap(err, "could not store proposal duties")
+			return nil, errors.Wrap(err, "error getting headers for slot "+strconv.FormatUint(missed.Slot, 10))
+		}
+		missedReasons[missed.Slot] = ClassifyMissedDuty(missed, headers)
+	}
+	return missedReasons, nil
+}
+
+func ClassifyMissedDuty(missed schemas.Duty, headers []*api.BeaconBlockHeader) schemas.MissedReason {
+	for _, header := range headers {
+		if header.Canonical || header.Header == nil || header.Header.Message == nil {
+			continue
+		}
+		if uint64(header.Header.Message.Slot) == missed.Slot &&
+			uint64(header.Header.Message.ProposerIndex) == missed.ValIndex {
+			return schemas.MissedOrphaned
+		}
+	}
+	return schemas.MissedEmptySlot
+}
+
+// Not available in the consensus client library, so the endpoint is called directly
+func (p *ProposalDuties) getSlotHeaders(slot uint64) ([]*api.BeaconBlockHeader, error) {
+	url := fmt.Sprintf("%s/eth/v1/beacon/headers?slot=%d", strings.TrimSuffix(p.config.Eth2Address, "/"), slot)
+	req, err := nethttp.NewRequest(nethttp.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range BeaconNodeHeaders(p.config) {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Some clients return not found when there are no headers for the slot
+	if resp.StatusCode == nethttp.StatusNotFound {
+		return []*api.BeaconBlockHeader{}, nil
+	}
+	if resp.StatusCode != nethttp.StatusOK {
+		return nil, errors.New(fmt.Sprintf("non-200 status: %d", resp.StatusCode))
+	}
+
+	var headers struct {
+		Data []*api.BeaconBlockHeader `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&headers); err != nil {
+		return nil, errors.Wrap(err, "error decoding headers")
+	}
+	return headers.Data, nil
+}
+
+// Stores a record with the rewards of every block proposed by the pool, so
+// that payouts can be reconciled per proposal. They are written to ClickHouse
+// instead of the database if configured.
+func (p *ProposalDuties) RunProposals(
+	poolName string,
+	poolProposals *schemas.ProposalDutiesMetrics,
+	relayPayloads map[uint64]schemas.RelayPayload,
+	slotTips map[uint64]*big.Int,
+	slotFeeRecipients map[uint64]string,
+	blockRewards map[uint64]*api.BlockRewards) error {
+
+	if p.database == nil && !p.clickHouse.Enabled() {
+		return nil
+	}
+
+	for _, duty := range poolProposals.Proposed {
+		rewards, found := blockRewards[duty.Slot]
+		var consensusReward uint64
+		if found {
+			consensusReward = uint64(rewards.Total)
+		}
+
+		proposal := GetProposal(
+			poolProposals.Epoch,
+			duty,
+			poolName,
+			consensusReward,
+			relayPayloads,
+			slotTips,
+			slotFeeRecipients,
+			IsVanillaBlock(duty.Slot, poolName, relayPayloads, slotFeeRecipients, p.addressToPool))
+		if found {
+			proposal.AttestationsReward = new(big.Int).SetUint64(uint64(rewards.Attestations))
+			proposal.SyncAggregateReward = new(big.Int).SetUint64(uint64(rewards.SyncAggregate))
+			proposal.SlashingsReward = new(big.Int).SetUint64(uint64(rewards.ProposerSlashings + rewards.AttesterSlashings))
+		} else {
+			// Unknown if the proposer-rewards module failed or is disabled
+			proposal.ConsensusReward = nil
+		}
+
+		if p.clickHouse.Enabled() {
+			p.clickHouse.AddProposal(proposal)
+			continue
+		}
+		err := p.database.StoreProposal(proposal)
+		if err != nil {
+			return errors.Wrap(err, "could not store proposal")
 		}
 	}
 	return nil
+}
+
+func GetProposal(
+	epoch uint64,
+	duty schemas.Duty,
+	poolName string,
+	consensusReward uint64,
+	relayPayloads map[uint64]schemas.RelayPayload,
+	slotTips map[uint64]*big.Int,
+	slotFeeRecipients map[uint64]string,
+	vanilla bool) schemas.Proposal {
+
+	proposal := schemas.Proposal{
+		Epoch:           epoch,
+		Slot:            duty.Slot,
+		ProposerIndex:   duty.ValIndex,
+		PoolName:        poolName,
+		ConsensusReward: new(big.Int).SetUint64(consensusReward),
+		ExecutionTip:    big.NewInt(0),
+		MEVReward:       big.NewInt(0),
+		Relays:          []string{},
+		Vanilla:         vanilla,
+	}
+	if payload, ok := relayPayloads[duty.Slot]; ok {
+		proposal.MEVReward = payload.Value
+		proposal.Relays = payload.Relays
+		proposal.BuilderPubKey = payload.BuilderPubKey
+		proposal.FeeRecipient = payload.ProposerFeeRecipient
+	}
+	if tip, ok := slotTips[duty.Slot]; ok {
+		proposal.ExecutionTip = tip
+	}
+	if feeRecipient, ok := slotFeeRecipients[duty.Slot]; ok {
+		proposal.FeeRecipient = feeRecipient
+	}
+	return proposal
+}
+
+// Blocks whose payload was delivered by a relay were built via MEV-Boost. The
+// rest are vanilla, unless the pool has fee recipients defined by --pool-address
+// and the block pays to another address, which happens when a builder made it
+// through a relay that is not monitored.
+func IsVanillaBlock(
+	slot uint64,
+	poolName string,
+	relayPayloads map[uint64]schemas.RelayPayload,
+	slotFeeRecipients map[uint64]string,
+	addressToPool map[string]string) bool {
 
+	if _, ok := relayPayloads[slot]; ok {
+		return false
+	}
+	feeRecipient, ok := slotFeeRecipients[slot]
+	if !ok {
+		return true
+	}
+	hasAddresses := false
+	for _, pool := range addressToPool {
+		if pool == poolName {
+			hasAddresses = true
+			break
+		}
+	}
+	if !hasAddresses {
+		return true
+	}
+	return addressToPool[feeRecipient] == poolName
 }
 
 func (p *ProposalDuties) GetProposalDuties(epoch uint64) ([]*api.ProposerDuty, error) {
-	log.Info("Fetching proposal duties for epoch: ", epoch)
+	log.WithField("Epoch", epoch).Info("Fetching proposal duties")
 
 	// Empty indexes to force fetching all duties
 	indexes := make([]phase0.ValidatorIndex, 0)
@@ -83,7 +321,7 @@ func (p *ProposalDuties) GetProposalDuties(epoch uint64) ([]*api.ProposerDuty, e
 }
 
 func (p *ProposalDuties) GetProposedBlocks(epoch uint64) ([]*api.BeaconBlockHeader, error) {
-	log.Info("Fetching proposed blocks for epoch: ", epoch)
+	log.WithField("Epoch", epoch).Info("Fetching proposed blocks")
 
 	epochBlockHeaders := make([]*api.BeaconBlockHeader, 0)
 	slotsInEpoch := uint64(p.networkParameters.slotsInEpoch)
@@ -91,7 +329,7 @@ func (p *ProposalDuties) GetProposedBlocks(epoch uint64) ([]*api.BeaconBlockHead
 	for i := uint64(0); i < slotsInEpoch; i++ {
 		slot := epoch*slotsInEpoch + uint64(i)
 		slotStr := strconv.FormatUint(slot, 10)
-		log.Debug("Fetching block for slot:" + slotStr)
+		log.WithField("Slot", slotStr).Debug("Fetching block")
 
 		opts := apiOther.BeaconBlockHeaderOpts{
 			Block: slotStr,
@@ -103,7 +341,7 @@ func (p *ProposalDuties) GetProposedBlocks(epoch uint64) ([]*api.BeaconBlockHead
 			if !strings.Contains(err.Error(), "NOT_FOUND") {
 				return epochBlockHeaders, errors.Wrap(err, "error getting beacon block header")
 			}
-			log.Warn("Block at slot " + slotStr + " was not found")
+			log.WithField("Slot", slotStr).Warn("Block was not found")
 			continue
 		}
 		epochBlockHeaders = append(epochBlockHeaders, blockHeader.Data)
@@ -112,6 +350,47 @@ func (p *ProposalDuties) GetProposedBlocks(epoch uint64) ([]*api.BeaconBlockHead
 	return epochBlockHeaders, nil
 }
 
+// Returns the consensus rewards of the given blocks proposed by the given
+// validators, by slot
+func (p *ProposalDuties) GetBlockRewards(
+	epoch uint64,
+	proposed []*api.BeaconBlockHeader,
+	validatorIndexes map[uint64]bool) (map[uint64]*api.BlockRewards, error) {
+
+	blockRewards := make(map[uint64]*api.BlockRewards)
+	for _, block := range proposed {
+		if block == nil || block.Header == nil || block.Header.Message == nil {
+			continue
+		}
+		if !validatorIndexes[uint64(block.Header.Message.ProposerIndex)] {
+			continue
+		}
+		slot := uint64(block.Header.Message.Slot)
+		rewards, err := p.consensus.BlockRewards(context.Background(), &apiOther.BlockRewardsOpts{
+			Block: fmt.Sprintf("%#x", block.Root),
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("error getting block rewards of slot %d", slot))
+		}
+		blockRewards[slot] = rewards.Data
+	}
+	log.WithField("Epoch", epoch).Info("Got consensus rewards of ", len(blockRewards), " blocks proposed by tracked validators")
+	return blockRewards, nil
+}
+
+// Sums the consensus rewards of the blocks by proposer
+func GetProposerRewards(blockRewards map[uint64]*api.BlockRewards) map[uint64]*big.Int {
+	proposerRewards := make(map[uint64]*big.Int)
+	for _, rewards := range blockRewards {
+		valIdx := uint64(rewards.ProposerIndex)
+		if _, ok := proposerRewards[valIdx]; !ok {
+			proposerRewards[valIdx] = big.NewInt(0)
+		}
+		proposerRewards[valIdx].Add(proposerRewards[valIdx], new(big.Int).SetUint64(uint64(rewards.Total)))
+	}
+	return proposerRewards
+}
+
 func (p *ProposalDuties) GetProposalMetrics(
 	proposalDuties []*api.ProposerDuty,
 	proposedBlocks []*api.BeaconBlockHeader) (schemas.ProposalDutiesMetrics, error) {
@@ -250,6 +529,7 @@ func logProposalDuties(
 			"Slot":        d.Slot,
 			"Epoch":       poolDuties.Epoch,
 			"TotalMissed": len(poolDuties.Missed),
+			"Reason":      d.MissedReason,
 		}).Info("Missed Duty")
 	}
 }
@@ -4,8 +4,14 @@ package metrics
 
 import (
 	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
+	api "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/bilinearlabs/eth-metrics/config"
 	"github.com/bilinearlabs/eth-metrics/schemas"
 	"github.com/stretchr/testify/require"
 )
@@ -82,6 +88,110 @@ func Test_getMissedDuties(t *testing.T) {
 
 //log "github.com/sirupsen/logrus"
 
+func Test_GetProposal(t *testing.T) {
+	relayPayloads := map[uint64]schemas.RelayPayload{
+		100: {
+			Slot:          100,
+			Relays:        []string{"https://relay_a", "https://relay_b"},
+			BuilderPubKey: "0xbuilder",
+			Value:         big.NewInt(5000),
+		},
+	}
+	slotTips := map[uint64]*big.Int{
+		101: big.NewInt(300),
+	}
+
+	slotFeeRecipients := map[uint64]string{
+		101: "0xfee",
+	}
+
+	proposal := GetProposal(3, schemas.Duty{ValIndex: 10, Slot: 100}, "pool_a", 40, relayPayloads, slotTips, slotFeeRecipients, false)
+	require.Equal(t, schemas.Proposal{
+		Epoch:           3,
+		Slot:            100,
+		ProposerIndex:   10,
+		PoolName:        "pool_a",
+		ConsensusReward: big.NewInt(40),
+		ExecutionTip:    big.NewInt(0),
+		MEVReward:       big.NewInt(5000),
+		Relays:          []string{"https://relay_a", "https://relay_b"},
+		BuilderPubKey:   "0xbuilder",
+	}, proposal)
+
+	proposal = GetProposal(3, schemas.Duty{ValIndex: 11, Slot: 101}, "pool_a", 41, relayPayloads, slotTips, slotFeeRecipients, true)
+	require.Equal(t, big.NewInt(300), proposal.ExecutionTip)
+	require.Equal(t, big.NewInt(0), proposal.MEVReward)
+	require.Empty(t, proposal.Relays)
+	require.Equal(t, "", proposal.BuilderPubKey)
+	require.Equal(t, "0xfee", proposal.FeeRecipient)
+	require.True(t, proposal.Vanilla)
+}
+
+func Test_IsVanillaBlock(t *testing.T) {
+	relayPayloads := map[uint64]schemas.RelayPayload{
+		100: {Slot: 100, ProposerFeeRecipient: "0xpool"},
+	}
+	slotFeeRecipients := map[uint64]string{
+		101: "0xpool",
+		102: "0xbuilder",
+	}
+	addressToPool := map[string]string{
+		"0xpool": "pool_a",
+	}
+
+	// Delivered by a relay
+	require.False(t, IsVanillaBlock(100, "pool_a", relayPayloads, slotFeeRecipients, addressToPool))
+	// Paying to the pool
+	require.True(t, IsVanillaBlock(101, "pool_a", relayPayloads, slotFeeRecipients, addressToPool))
+	// Paying to a builder, from a relay that is not monitored
+	require.False(t, IsVanillaBlock(102, "pool_a", relayPayloads, slotFeeRecipients, addressToPool))
+	// Without known fee recipients only the relays are used
+	require.True(t, IsVanillaBlock(102, "pool_b", relayPayloads, slotFeeRecipients, addressToPool))
+}
+
+func Test_ClassifyMissedDuties(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/eth/v1/beacon/headers", r.URL.Path)
+		switch r.URL.Query().Get("slot") {
+		case "100":
+			// Orphaned block of the scheduled proposer
+			w.Write([]byte(`{"data":[{"root":"0x0000000000000000000000000000000000000000000000000000000000000001","canonical":false,` +
+				`"header":{"message":{"slot":"100","proposer_index":"10",` +
+				`"parent_root":"0x0000000000000000000000000000000000000000000000000000000000000000",` +
+				`"state_root":"0x0000000000000000000000000000000000000000000000000000000000000000",` +
+				`"body_root":"0x0000000000000000000000000000000000000000000000000000000000000000"},` +
+				`"signature":"0x` + strings.Repeat("00", 96) + `"}}]}`))
+		case "101":
+			w.Write([]byte(`{"data":[]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p, err := NewProposalDuties(nil, &NetworkParameters{slotsInEpoch: 32}, nil, nil, &config.Config{Eth2Address: server.URL})
+	require.NoError(t, err)
+
+	epochDuties := &schemas.ProposalDutiesMetrics{
+		Scheduled: []schemas.Duty{
+			{ValIndex: 9, Slot: 99},
+			{ValIndex: 10, Slot: 100},
+			{ValIndex: 11, Slot: 101},
+			{ValIndex: 12, Slot: 102},
+		},
+		Proposed: []schemas.Duty{
+			{ValIndex: 9, Slot: 99},
+		},
+	}
+	missedReasons, err := p.ClassifyMissedDuties(epochDuties)
+	require.NoError(t, err)
+	require.Equal(t, map[uint64]schemas.MissedReason{
+		100: schemas.MissedOrphaned,
+		101: schemas.MissedEmptySlot,
+		102: schemas.MissedEmptySlot,
+	}, missedReasons)
+}
+
 /*
 // Validators p1-p7 have active duties
 var p1 = ToBytes48([]byte{1})
@@ -229,3 +339,16 @@ func Test_getMissedDuties(t *testing.T) {
 	require.Equal(t, missedDuties[1].Slot, ethTypes.Slot(3000))
 }
 */
+
+func Test_GetProposerRewards(t *testing.T) {
+	blockRewards := map[uint64]*api.BlockRewards{
+		100: {ProposerIndex: 10, Total: 40000000, Attestations: 39000000, SyncAggregate: 1000000},
+		101: {ProposerIndex: 11, Total: 30000000},
+		// Same proposer twice in the epoch
+		102: {ProposerIndex: 10, Total: 5000000},
+	}
+	require.Equal(t, map[uint64]*big.Int{
+		10: big.NewInt(45000000),
+		11: big.NewInt(30000000),
+	}, GetProposerRewards(blockRewards))
+}
@@ -1,120 +1,184 @@
 package metrics
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"math/big"
 	"net/http"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/avast/retry-go/v4"
 	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/bilinearlabs/eth-metrics/db"
+	"github.com/bilinearlabs/eth-metrics/pools"
+	"github.com/bilinearlabs/eth-metrics/schemas"
 	"github.com/flashbots/mev-boost-relay/common"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
 )
 
-var RELAY_SERVERS = []string{
-	"https://relay-analytics.ultrasound.money",
-	"https://titanrelay.xyz",
-	"https://bloxroute.max-profit.blxrbdn.com",
-	"https://bloxroute.regulated.blxrbdn.com",
-	"https://boost-relay.flashbots.net",
-	"https://aestus.live",
-	"https://agnostic-relay.net",
-	"https://relay.ethgas.com",
-	"https://relay.btcs.com",
+var (
+	relayRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ethmetrics_relay_requests_total",
+		Help: "Requests made to each relay to get the delivered payloads",
+	}, []string{"relay"})
+	relayRequestErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ethmetrics_relay_request_errors_total",
+		Help: "Requests to each relay that failed or returned a non-200 status",
+	}, []string{"relay"})
+	relayRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ethmetrics_relay_request_duration_seconds",
+		Help:    "Latency of the requests made to each relay",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"relay"})
+	relayPayloadsDeliveredTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ethmetrics_relay_payloads_delivered_total",
+		Help: "Payloads delivered by each relay to the tracked validators",
+	}, []string{"relay"})
+	relayPayloadsValueEth = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ethmetrics_relay_payloads_value_eth_total",
+		Help: "Value of the payloads delivered by each relay to the tracked validators",
+	}, []string{"relay"})
+	relayRewardsDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "ethmetrics_relay_rewards_duration_seconds",
+		Help:    "Duration of getting the payloads of an epoch from all the relays",
+		Buckets: processingBuckets,
+	})
+)
+
+// Relays may not have the payloads of the latest slots yet, so only older
+// responses are cached
+const relayCacheMinAgeEpochs = 2
+
+// Payloads requested per cursor query, enough for a whole epoch
+const relayPageSize = 100
+
+type relayResult struct {
+	slot    uint64
+	pool    string
+	reward  *big.Int
+	relay   string
+	payload common.BidTraceV2JSON
 }
 
 type RelayRewards struct {
 	httpClient         *http.Client
 	networkParameters  *NetworkParameters
 	validatorKeyToPool map[string]string
+	addressToPool      map[string]string
+	database           *db.Database
 	config             *config.Config
 	retryOpts          []retry.Option
+	limiters           map[string]*rate.Limiter
+	// Stats of the last epoch, also updated when some relay fails
+	relayStats   map[string]*schemas.RelayStats
+	relayStatsMu sync.Mutex
 }
 
 func NewRelayRewards(
 	networkParameters *NetworkParameters,
 	validatorKeyToPool map[string]string,
+	database *db.Database,
 	config *config.Config) (*RelayRewards, error) {
+	addressToPool, err := pools.ParsePoolAddresses(config.PoolAddresses)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing pool addresses")
+	}
+
+	// Token bucket per relay, so that backfills don't get the ip banned
+	limit := rate.Inf
+	if config.RelayQps > 0 {
+		limit = rate.Limit(config.RelayQps)
+	}
+	limiters := make(map[string]*rate.Limiter)
+	for _, relay := range config.Relays {
+		limiters[relay] = rate.NewLimiter(limit, 1)
+	}
+
 	return &RelayRewards{
 		httpClient:         &http.Client{Timeout: 60 * time.Second},
 		networkParameters:  networkParameters,
 		validatorKeyToPool: validatorKeyToPool,
+		addressToPool:      addressToPool,
+		database:           database,
 		config:             config,
 		retryOpts: []retry.Option{
 			retry.Attempts(5),
 			retry.Delay(5 * time.Second),
 		},
+		limiters: limiters,
 	}, nil
 }
 
+// Returns the MEV rewards per pool and the payloads delivered to the tracked
+// validators, indexed by slot
 func (r *RelayRewards) GetRelayRewards(
 	epoch uint64,
-) (map[string]*big.Int, map[uint64]struct{}, error) {
-	slotsInEpoch := r.networkParameters.slotsInEpoch
+) (map[string]*big.Int, map[uint64]schemas.RelayPayload, error) {
+	defer observeDuration(relayRewardsDuration, time.Now())
 	poolRewards := make(map[string]*big.Int)
-	slotsWithRewards := make(map[uint64]struct{})
+	slotsWithRewards := make(map[uint64]schemas.RelayPayload)
+	r.resetRelayStats(epoch)
 
-	results := make(chan struct {
-		slot   uint64
-		pool   string
-		reward *big.Int
-	})
+	results := make(chan relayResult)
 	var g errgroup.Group
 	var consumerWg sync.WaitGroup
 
-	// Create per-relay semaphores (limit to 1 concurrent request per relay)
-	relaySem := make(map[string]chan struct{})
-	for _, relay := range RELAY_SERVERS {
-		relaySem[relay] = make(chan struct{}, 1)
-	}
-
 	// Consumer
 	consumerWg.Go(func() {
 		for result := range results {
+			r.recordPayload(result.relay, result.reward)
+			// The same payload reported by several relays is earned once
+			if payload, ok := slotsWithRewards[result.slot]; ok {
+				if payload.Value.Cmp(result.reward) != 0 {
+					log.WithFields(log.Fields{"Slot": result.slot, "Relay": result.relay}).Warn(
+						"Relays report different values for the payload, keeping ", payload.Value, " instead of ", result.reward)
+				}
+				payload.Relays = append(payload.Relays, result.relay)
+				slotsWithRewards[result.slot] = payload
+				continue
+			}
 			if _, ok := poolRewards[result.pool]; !ok {
 				poolRewards[result.pool] = big.NewInt(0)
 			}
 			poolRewards[result.pool] = new(big.Int).Add(poolRewards[result.pool], result.reward)
-			slotsWithRewards[result.slot] = struct{}{}
+			slotsWithRewards[result.slot] = schemas.RelayPayload{
+				Slot:                 result.slot,
+				Relays:               []string{result.relay},
+				BuilderPubKey:        result.payload.BuilderPubkey,
+				ProposerPubKey:       result.payload.ProposerPubkey,
+				ProposerFeeRecipient: strings.ToLower(result.payload.ProposerFeeRecipient),
+				Value:                result.reward,
+			}
 		}
 	})
 
-	for i := range slotsInEpoch {
-		slot := epoch*slotsInEpoch + i
-		for _, relayServer := range RELAY_SERVERS {
-			g.Go(func() error {
-				// Acquire semaphore for this relay (blocks if another request is in progress)
-				relaySem[relayServer] <- struct{}{}
-				defer func() { <-relaySem[relayServer] }()
-
-				payloads, err := r.getRewards(relayServer, slot)
-				if err != nil {
-					return errors.Wrap(err, fmt.Sprintf("error getting rewards from %s", relayServer))
-				}
-				for _, payload := range payloads {
-					pool, ok := r.validatorKeyToPool[payload.ProposerPubkey]
-					if !ok {
-						continue
-					}
-					value, ok := big.NewInt(0).SetString(payload.Value, 10)
-					if !ok {
-						return errors.New(fmt.Sprintf("failed to parse value: %s", payload.Value))
-					}
-					results <- struct {
-						slot   uint64
-						pool   string
-						reward *big.Int
-					}{slot, pool, value}
-				}
+	// One goroutine per relay, so that each relay gets one request at a time
+	for _, relayServer := range r.config.Relays {
+		g.Go(func() error {
+			relayResults, err := r.getRelayResults(relayServer, epoch)
+			if err != nil && r.config.RelayFailureMode == config.RelayFailureSkip {
+				log.WithField("Epoch", epoch).Warn("Skipping relay ", relayServer, ", the MEV rewards of the epoch are partial: ", err)
+				r.recordSkipped(relayServer)
 				return nil
-			})
-		}
+			}
+			if err != nil {
+				return err
+			}
+			for _, result := range relayResults {
+				results <- result
+			}
+			return nil
+		})
 	}
 	if err := g.Wait(); err != nil {
 		close(results)
@@ -127,18 +191,209 @@ func (r *RelayRewards) GetRelayRewards(
 	return poolRewards, slotsWithRewards, nil
 }
 
-func (r *RelayRewards) getRewards(relayServer string, slot uint64) ([]common.BidTraceV2JSON, error) {
+// Returns the payloads delivered by the relay to the tracked validators. All of
+// them are parsed before any is used, so that a skipped relay adds none.
+func (r *RelayRewards) getRelayResults(relayServer string, epoch uint64) ([]relayResult, error) {
+	payloads, err := r.getEpochRewards(relayServer, epoch)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("error getting rewards from %s", relayServer))
+	}
+	relayResults := make([]relayResult, 0)
+	for _, payload := range payloads {
+		pool, ok := r.validatorKeyToPool[payload.ProposerPubkey]
+		if !ok {
+			// Validators of pools defined by fee recipient may not be known yet
+			pool, ok = r.addressToPool[strings.ToLower(payload.ProposerFeeRecipient)]
+		}
+		if !ok {
+			continue
+		}
+		value, ok := big.NewInt(0).SetString(payload.Value, 10)
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("failed to parse value: %s", payload.Value))
+		}
+		relayResults = append(relayResults, relayResult{payload.Slot, pool, value, relayServer, payload})
+	}
+	return relayResults, nil
+}
+
+// Returns the payloads delivered by the relay in the slots of the epoch. They
+// are requested with a cursor, which returns the payloads of the slots before
+// it, so that the whole epoch takes one or two requests instead of one per slot.
+// Relays not supporting it are requested per slot.
+func (r *RelayRewards) getEpochRewards(relayServer string, epoch uint64) ([]common.BidTraceV2JSON, error) {
+	slotsInEpoch := r.networkParameters.slotsInEpoch
+	firstSlot := epoch * slotsInEpoch
+	lastSlot := firstSlot + slotsInEpoch - 1
+
+	payloads, cached, err := r.getCachedRewards(relayServer, firstSlot, lastSlot)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting cached bidtraces")
+	}
+	if cached {
+		return payloads, nil
+	}
+
+	payloads = make([]common.BidTraceV2JSON, 0)
+	cursor := lastSlot
+	for {
+		page, status, err := r.getRewards(relayServer, fmt.Sprintf("cursor=%d&limit=%d", cursor, relayPageSize))
+		if status == http.StatusBadRequest {
+			log.Warn("Relay ", relayServer, " does not support cursor queries, requesting each slot")
+			return r.getEpochRewardsPerSlot(relayServer, firstSlot, lastSlot)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		minSlot := cursor + 1
+		for _, payload := range page {
+			minSlot = min(minSlot, payload.Slot)
+			if payload.Slot >= firstSlot && payload.Slot <= lastSlot {
+				payloads = append(payloads, payload)
+			}
+		}
+		// Stop once the page reaches the first slot, or if the relay ignored the
+		// cursor. Note that a cursor of 0 is ignored by the relays
+		if len(page) == 0 || minSlot <= firstSlot || minSlot > cursor || minSlot == 1 {
+			break
+		}
+		cursor = minSlot - 1
+	}
+
+	r.cacheRewards(relayServer, firstSlot, lastSlot, payloads)
+	return payloads, nil
+}
+
+func (r *RelayRewards) getEpochRewardsPerSlot(relayServer string, firstSlot uint64, lastSlot uint64) ([]common.BidTraceV2JSON, error) {
+	payloads := make([]common.BidTraceV2JSON, 0)
+	for slot := firstSlot; slot <= lastSlot; slot++ {
+		slotPayloads, _, err := r.getRewards(relayServer, fmt.Sprintf("slot=%d", slot))
+		if err != nil {
+			return nil, err
+		}
+		payloads = append(payloads, slotPayloads...)
+	}
+	r.cacheRewards(relayServer, firstSlot, lastSlot, payloads)
+	return payloads, nil
+}
+
+// Returns the cached payloads of the slots, if all of them are cached
+func (r *RelayRewards) getCachedRewards(relayServer string, firstSlot uint64, lastSlot uint64) ([]common.BidTraceV2JSON, bool, error) {
+	if r.database == nil {
+		return nil, false, nil
+	}
+	payloads := make([]common.BidTraceV2JSON, 0)
+	for slot := firstSlot; slot <= lastSlot; slot++ {
+		body, found, err := r.database.GetRelayBidTraces(relayServer, slot)
+		if err != nil {
+			return nil, false, err
+		}
+		if !found {
+			return nil, false, nil
+		}
+		slotPayloads, err := decodeBidTraces(body)
+		if err != nil {
+			return nil, false, err
+		}
+		payloads = append(payloads, slotPayloads...)
+	}
+	return payloads, true, nil
+}
+
+// Caches the payloads of each slot, including the slots without payloads
+func (r *RelayRewards) cacheRewards(relayServer string, firstSlot uint64, lastSlot uint64, payloads []common.BidTraceV2JSON) {
+	if r.database == nil || !r.isOldSlot(lastSlot) {
+		return
+	}
+	for slot := firstSlot; slot <= lastSlot; slot++ {
+		slotPayloads := make([]common.BidTraceV2JSON, 0)
+		for _, payload := range payloads {
+			if payload.Slot == slot {
+				slotPayloads = append(slotPayloads, payload)
+			}
+		}
+		body, err := json.Marshal(slotPayloads)
+		if err != nil {
+			log.Warn("Could not encode bidtraces of ", relayServer, ": ", err)
+			return
+		}
+		if err := r.database.StoreRelayBidTraces(relayServer, slot, body); err != nil {
+			log.Warn("Could not cache bidtraces of ", relayServer, ": ", err)
+			return
+		}
+	}
+}
+
+// Requests the payloads delivered by the relay matching the query. Returns the
+// http status of the last attempt, if any.
+func (r *RelayRewards) getRewards(relayServer string, query string) ([]common.BidTraceV2JSON, int, error) {
+	return r.getBidTraces(relayServer, "proposer_payload_delivered", query)
+}
+
+// Returns the bid received by the relays for the slot with the highest value,
+// or nil if there is none. Relays failing are skipped, so that the bids of the
+// rest are still used, unless all of them fail. Relays only keep the bids of
+// validators registered with them, and return a limited number per query.
+func (r *RelayRewards) GetBestBid(slot uint64) (*schemas.RelayBid, error) {
+	var bestBid *schemas.RelayBid
+	nOfFailed := 0
+	for _, relayServer := range r.config.Relays {
+		bids, _, err := r.getBidTraces(relayServer, "builder_blocks_received", fmt.Sprintf("slot=%d", slot))
+		if err != nil {
+			nOfFailed++
+			log.WithField("Slot", slot).Warn("Could not get the bids of ", relayServer, ": ", err)
+			continue
+		}
+		for _, bid := range bids {
+			value, ok := big.NewInt(0).SetString(bid.Value, 10)
+			if !ok {
+				log.WithField("Slot", slot).Warn("Skipping bid of ", relayServer, " with invalid value: ", bid.Value)
+				continue
+			}
+			if bestBid == nil || value.Cmp(bestBid.Value) > 0 {
+				bestBid = &schemas.RelayBid{
+					Slot:          slot,
+					Relay:         relayServer,
+					BuilderPubKey: bid.BuilderPubkey,
+					Value:         value,
+				}
+			}
+		}
+	}
+	if len(r.config.Relays) > 0 && nOfFailed == len(r.config.Relays) {
+		return nil, errors.New(fmt.Sprintf("could not get the bids of slot %d from any relay", slot))
+	}
+	return bestBid, nil
+}
+
+// Requests the bidtraces of the given endpoint of the data api of the relay
+func (r *RelayRewards) getBidTraces(relayServer string, endpoint string, query string) ([]common.BidTraceV2JSON, int, error) {
 	var body []byte
+	status := 0
 
 	err := retry.Do(func() error {
-		resp, err := r.httpClient.Get(fmt.Sprintf("%s/relay/v1/data/bidtraces/proposer_payload_delivered?slot=%d", relayServer, slot))
+		if limiter, ok := r.limiters[relayServer]; ok {
+			if err := limiter.Wait(context.Background()); err != nil {
+				return err
+			}
+		}
+		start := time.Now()
+		resp, err := r.httpClient.Get(fmt.Sprintf("%s/relay/v1/data/bidtraces/%s?%s", relayServer, endpoint, query))
 		if err != nil {
-			log.Warnf("error getting rewards from %s: %s. Slot: %d. Retrying...", relayServer, err, slot)
-			return errors.Wrap(err, "error getting rewards from "+relayServer)
+			r.recordRequest(relayServer, time.Since(start), false)
+			log.Warnf("error getting %s from %s: %s. Query: %s. Retrying...", endpoint, relayServer, err, query)
+			return errors.Wrap(err, "error getting "+endpoint+" from "+relayServer)
 		}
 		defer resp.Body.Close()
+		status = resp.StatusCode
+		r.recordRequest(relayServer, time.Since(start), resp.StatusCode == http.StatusOK)
+		// Not retried, the query is not supported by the relay
+		if resp.StatusCode == http.StatusBadRequest {
+			return retry.Unrecoverable(errors.New(fmt.Sprintf("bad request: %s", query)))
+		}
 		if resp.StatusCode != http.StatusOK {
-			log.Warnf("non-200 status from %s: %d. Slot: %d. Retrying...", relayServer, resp.StatusCode, slot)
+			log.Warnf("non-200 status from %s: %d. Query: %s. Retrying...", relayServer, resp.StatusCode, query)
 			return errors.New(fmt.Sprintf("non-200 status: %d", resp.StatusCode))
 		}
 		body, err = io.ReadAll(resp.Body)
@@ -148,13 +403,100 @@ func (r *RelayRewards) getRewards(relayServer string, slot uint64) ([]common.Bid
 		return nil
 	}, r.retryOpts...)
 	if err != nil {
-		return nil, errors.Wrap(err, "error getting rewards")
+		return nil, status, errors.Wrap(err, "error getting "+endpoint)
 	}
+	payloads, err := decodeBidTraces(body)
+	if err != nil {
+		return nil, status, err
+	}
+	return payloads, status, nil
+}
+
+func decodeBidTraces(body []byte) ([]common.BidTraceV2JSON, error) {
 	var payloads []common.BidTraceV2JSON
 
 	if err := json.Unmarshal(body, &payloads); err != nil {
-		return nil, errors.Wrap(err, "error decoding proposer payload delivered")
+		return nil, errors.Wrap(err, "error decoding bidtraces")
 	}
 
 	return payloads, nil
 }
+
+func (r *RelayRewards) isOldSlot(slot uint64) bool {
+	currentSlot := r.networkParameters.Clock().CurrentSlot()
+	return slot+relayCacheMinAgeEpochs*r.networkParameters.slotsInEpoch <= currentSlot
+}
+
+// Returns the stats of each relay for the last epoch whose rewards were
+// requested, sorted by relay
+func (r *RelayRewards) GetRelayStats() []schemas.RelayStats {
+	r.relayStatsMu.Lock()
+	defer r.relayStatsMu.Unlock()
+
+	relayStats := make([]schemas.RelayStats, 0, len(r.relayStats))
+	for _, stats := range r.relayStats {
+		relayStats = append(relayStats, *stats)
+	}
+	sort.Slice(relayStats, func(i, j int) bool {
+		return relayStats[i].Relay < relayStats[j].Relay
+	})
+	return relayStats
+}
+
+func (r *RelayRewards) resetRelayStats(epoch uint64) {
+	r.relayStatsMu.Lock()
+	defer r.relayStatsMu.Unlock()
+
+	r.relayStats = make(map[string]*schemas.RelayStats)
+	for _, relay := range r.config.Relays {
+		r.relayStats[relay] = &schemas.RelayStats{
+			Epoch:    epoch,
+			Relay:    relay,
+			ValueWei: big.NewInt(0),
+		}
+	}
+}
+
+func (r *RelayRewards) recordRequest(relay string, latency time.Duration, ok bool) {
+	relayRequestsTotal.WithLabelValues(relay).Inc()
+	relayRequestDuration.WithLabelValues(relay).Observe(latency.Seconds())
+	if !ok {
+		relayRequestErrorsTotal.WithLabelValues(relay).Inc()
+	}
+
+	r.relayStatsMu.Lock()
+	defer r.relayStatsMu.Unlock()
+	stats, found := r.relayStats[relay]
+	if !found {
+		return
+	}
+	// Running average, so that the latencies are not kept
+	stats.NOfRequests++
+	stats.AvgLatencyMs += (float64(latency.Milliseconds()) - stats.AvgLatencyMs) / float64(stats.NOfRequests)
+	if !ok {
+		stats.NOfErrors++
+	}
+}
+
+func (r *RelayRewards) recordSkipped(relay string) {
+	r.relayStatsMu.Lock()
+	defer r.relayStatsMu.Unlock()
+	if stats, found := r.relayStats[relay]; found {
+		stats.Skipped = true
+	}
+}
+
+func (r *RelayRewards) recordPayload(relay string, value *big.Int) {
+	relayPayloadsDeliveredTotal.WithLabelValues(relay).Inc()
+	valueEth, _ := new(big.Float).Quo(new(big.Float).SetInt(value), big.NewFloat(1e18)).Float64()
+	relayPayloadsValueEth.WithLabelValues(relay).Add(valueEth)
+
+	r.relayStatsMu.Lock()
+	defer r.relayStatsMu.Unlock()
+	stats, found := r.relayStats[relay]
+	if !found {
+		return
+	}
+	stats.NOfPayloads++
+	stats.ValueWei.Add(stats.ValueWei, value)
+}
@@ -5,37 +5,69 @@ import (
 	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/avast/retry-go/v4"
 	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/bilinearlabs/eth-metrics/db"
+	"github.com/bilinearlabs/eth-metrics/schemas"
 	"github.com/flashbots/mev-boost-relay/common"
 	"github.com/stretchr/testify/assert"
 )
 
-func TestGetRelayRewards_Success(t *testing.T) {
-	// Create a test server that returns valid rewards
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Verify the request path
+// Relay serving the given payloads by slot or by cursor, returning at most
+// maxLimit payloads per page. Cursor queries are rejected if maxLimit is 0.
+func newRelayServer(t *testing.T, payloads []common.BidTraceV2JSON, maxLimit int, nOfRequests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		assert.Contains(t, r.URL.Path, "/relay/v1/data/bidtraces/proposer_payload_delivered")
-
-		// Return mock rewards data
-		payloads := []common.BidTraceV2JSON{
-			{
-				ProposerPubkey: "0x1234567890abcdef",
-				Value:          "1000000000000000000",
-			},
-			{
-				ProposerPubkey: "0xabcdef1234567890",
-				Value:          "2000000000000000000",
-			},
+		if nOfRequests != nil {
+			*nOfRequests++
+		}
+		query := r.URL.Query()
+		response := make([]common.BidTraceV2JSON, 0)
+		if query.Get("slot") != "" {
+			slot, err := strconv.ParseUint(query.Get("slot"), 10, 64)
+			assert.NoError(t, err)
+			for _, payload := range payloads {
+				if payload.Slot == slot {
+					response = append(response, payload)
+				}
+			}
+		} else {
+			if maxLimit == 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			cursor, err := strconv.ParseUint(query.Get("cursor"), 10, 64)
+			assert.NoError(t, err)
+			limit, err := strconv.Atoi(query.Get("limit"))
+			assert.NoError(t, err)
+			// Sorted by descending slot
+			for i := len(payloads) - 1; i >= 0; i-- {
+				if payloads[i].Slot <= cursor && len(response) < min(limit, maxLimit) {
+					response = append(response, payloads[i])
+				}
+			}
 		}
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(payloads)
+		json.NewEncoder(w).Encode(response)
 	}))
+}
+
+func TestGetRelayRewards_Success(t *testing.T) {
+	nOfRequests := 0
+	server := newRelayServer(t, []common.BidTraceV2JSON{
+		{Slot: 0, ProposerPubkey: "0x1234567890abcdef", Value: "1000000000000000000"},
+		{Slot: 1, ProposerPubkey: "0xabcdef1234567890", Value: "2000000000000000000"},
+		// Next epoch
+		{Slot: 2, ProposerPubkey: "0x1234567890abcdef", Value: "3000000000000000000"},
+	}, 100, &nOfRequests)
 	defer server.Close()
 
-	RELAY_SERVERS = []string{server.URL}
+	relays := []string{server.URL}
 
 	networkParams := &NetworkParameters{
 		slotsInEpoch: 2,
@@ -44,9 +76,9 @@ func TestGetRelayRewards_Success(t *testing.T) {
 		"0x1234567890abcdef": "pool1",
 		"0xabcdef1234567890": "pool2",
 	}
-	cfg := &config.Config{}
+	cfg := &config.Config{Relays: relays}
 
-	relayRewards, err := NewRelayRewards(networkParams, validatorKeyToPool, cfg)
+	relayRewards, err := NewRelayRewards(networkParams, validatorKeyToPool, nil, cfg)
 	assert.NoError(t, err)
 
 	// Call GetRelayRewards
@@ -55,13 +87,64 @@ func TestGetRelayRewards_Success(t *testing.T) {
 	assert.NotNil(t, rewards)
 	assert.NotNil(t, slotsWithRewards)
 
-	// Verify rewards are aggregated correctly
-	// Each slot (2 slots) * each relay server (1 server) = 2 requests
-	// pool1: 2 * 1 ETH = 2 ETH
-	// pool2: 2 * 2 ETH = 4 ETH
-	assert.Equal(t, big.NewInt(2000000000000000000), rewards["pool1"])
-	assert.Equal(t, big.NewInt(4000000000000000000), rewards["pool2"])
+	// The whole epoch is covered by a single cursor query
+	assert.Equal(t, 1, nOfRequests)
+	assert.Equal(t, big.NewInt(1000000000000000000), rewards["pool1"])
+	assert.Equal(t, big.NewInt(2000000000000000000), rewards["pool2"])
 	assert.Len(t, slotsWithRewards, 2)
+	assert.Equal(t, "0xabcdef1234567890", slotsWithRewards[1].ProposerPubKey)
+}
+
+func TestGetRelayRewards_SeveralRelays(t *testing.T) {
+	payloads := []common.BidTraceV2JSON{
+		{Slot: 0, ProposerPubkey: "0x1234567890abcdef", Value: "1000000000000000000"},
+	}
+	relay1 := newRelayServer(t, payloads, 100, nil)
+	defer relay1.Close()
+	relay2 := newRelayServer(t, payloads, 100, nil)
+	defer relay2.Close()
+
+	networkParams := &NetworkParameters{
+		slotsInEpoch: 2,
+	}
+	validatorKeyToPool := map[string]string{
+		"0x1234567890abcdef": "pool1",
+	}
+	cfg := &config.Config{Relays: []string{relay1.URL, relay2.URL}}
+	relayRewards, err := NewRelayRewards(networkParams, validatorKeyToPool, nil, cfg)
+	assert.NoError(t, err)
+
+	// The payload delivered by both relays is counted once
+	rewards, slotsWithRewards, err := relayRewards.GetRelayRewards(0)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(1000000000000000000), rewards["pool1"])
+	assert.ElementsMatch(t, []string{relay1.URL, relay2.URL}, slotsWithRewards[0].Relays)
+	assert.Equal(t, big.NewInt(1000000000000000000), slotsWithRewards[0].Value)
+}
+
+func TestGetRelayRewards_Pagination(t *testing.T) {
+	payloads := make([]common.BidTraceV2JSON, 0)
+	for slot := uint64(2); slot < 10; slot++ {
+		payloads = append(payloads, common.BidTraceV2JSON{Slot: slot, ProposerPubkey: "0x1234567890abcdef", Value: "1"})
+	}
+	// Relay returning a single payload per page
+	nOfRequests := 0
+	server := newRelayServer(t, payloads, 1, &nOfRequests)
+	defer server.Close()
+
+	relays := []string{server.URL}
+
+	networkParams := &NetworkParameters{
+		slotsInEpoch: 4,
+	}
+	relayRewards, err := NewRelayRewards(networkParams, map[string]string{"0x1234567890abcdef": "pool1"}, nil, &config.Config{Relays: relays})
+	assert.NoError(t, err)
+
+	rewards, slotsWithRewards, err := relayRewards.GetRelayRewards(1)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(4), rewards["pool1"])
+	assert.Len(t, slotsWithRewards, 4)
+	assert.Equal(t, 4, nOfRequests)
 }
 
 func TestGetRelayRewards_HTTPError(t *testing.T) {
@@ -71,7 +154,7 @@ func TestGetRelayRewards_HTTPError(t *testing.T) {
 	}))
 	defer server.Close()
 
-	RELAY_SERVERS = []string{server.URL}
+	relays := []string{server.URL}
 
 	networkParams := &NetworkParameters{
 		slotsInEpoch: 1,
@@ -79,9 +162,9 @@ func TestGetRelayRewards_HTTPError(t *testing.T) {
 	validatorKeyToPool := map[string]string{
 		"0x1234567890abcdef": "pool1",
 	}
-	cfg := &config.Config{}
+	cfg := &config.Config{Relays: relays}
 
-	relayRewards, err := NewRelayRewards(networkParams, validatorKeyToPool, cfg)
+	relayRewards, err := NewRelayRewards(networkParams, validatorKeyToPool, nil, cfg)
 	assert.NoError(t, err)
 
 	relayRewards.retryOpts = []retry.Option{retry.Attempts(1)}
@@ -99,7 +182,7 @@ func TestGetRelayRewards_InvalidValue(t *testing.T) {
 	}))
 	defer server.Close()
 
-	RELAY_SERVERS = []string{server.URL}
+	relays := []string{server.URL}
 
 	networkParams := &NetworkParameters{
 		slotsInEpoch: 1,
@@ -107,9 +190,9 @@ func TestGetRelayRewards_InvalidValue(t *testing.T) {
 	validatorKeyToPool := map[string]string{
 		"0x1234567890abcdef": "pool1",
 	}
-	cfg := &config.Config{}
+	cfg := &config.Config{Relays: relays}
 
-	relayRewards, err := NewRelayRewards(networkParams, validatorKeyToPool, cfg)
+	relayRewards, err := NewRelayRewards(networkParams, validatorKeyToPool, nil, cfg)
 	assert.NoError(t, err)
 
 	relayRewards.retryOpts = []retry.Option{retry.Attempts(1)}
@@ -119,3 +202,200 @@ func TestGetRelayRewards_InvalidValue(t *testing.T) {
 	assert.Nil(t, rewards)
 	assert.Nil(t, slotsWithRewards)
 }
+
+func TestGetRelayStats(t *testing.T) {
+	healthy := newRelayServer(t, []common.BidTraceV2JSON{
+		{Slot: 20, ProposerPubkey: "0x1234567890abcdef", Value: "1000000000000000000"},
+		{Slot: 21, ProposerPubkey: "0x1234567890abcdef", Value: "1000000000000000000"},
+	}, 100, nil)
+	defer healthy.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	relays := []string{healthy.URL, down.URL}
+
+	networkParams := &NetworkParameters{
+		slotsInEpoch: 2,
+	}
+	validatorKeyToPool := map[string]string{
+		"0x1234567890abcdef": "pool1",
+	}
+	relayRewards, err := NewRelayRewards(networkParams, validatorKeyToPool, nil, &config.Config{Relays: relays})
+	assert.NoError(t, err)
+	relayRewards.retryOpts = []retry.Option{retry.Attempts(1)}
+
+	// Stats are kept even if a relay fails
+	_, _, err = relayRewards.GetRelayRewards(10)
+	assert.Error(t, err)
+
+	stats := make(map[string]schemas.RelayStats)
+	for _, relayStats := range relayRewards.GetRelayStats() {
+		stats[relayStats.Relay] = relayStats
+	}
+	assert.Len(t, stats, 2)
+	assert.Equal(t, uint64(10), stats[healthy.URL].Epoch)
+	assert.Equal(t, uint64(0), stats[healthy.URL].NOfErrors)
+	assert.Equal(t, uint64(1), stats[healthy.URL].NOfRequests)
+	assert.Equal(t, uint64(2), stats[healthy.URL].NOfPayloads)
+	assert.Equal(t, big.NewInt(2000000000000000000), stats[healthy.URL].ValueWei)
+	assert.Equal(t, uint64(0), stats[down.URL].NOfPayloads)
+	assert.Equal(t, stats[down.URL].NOfRequests, stats[down.URL].NOfErrors)
+	assert.NotZero(t, stats[down.URL].NOfErrors)
+}
+
+func TestGetRelayRewards_SkipFailedRelay(t *testing.T) {
+	healthy := newRelayServer(t, []common.BidTraceV2JSON{
+		{Slot: 20, ProposerPubkey: "0x1234567890abcdef", Value: "1000000000000000000"},
+	}, 100, nil)
+	defer healthy.Close()
+	// Fails after a payload of a tracked validator was read
+	invalid := newRelayServer(t, []common.BidTraceV2JSON{
+		{Slot: 20, ProposerPubkey: "0x1234567890abcdef", Value: "1000000000000000000"},
+		{Slot: 21, ProposerPubkey: "0x1234567890abcdef", Value: "invalid"},
+	}, 100, nil)
+	defer invalid.Close()
+
+	relays := []string{healthy.URL, invalid.URL}
+
+	networkParams := &NetworkParameters{
+		slotsInEpoch: 2,
+	}
+	validatorKeyToPool := map[string]string{
+		"0x1234567890abcdef": "pool1",
+	}
+	cfg := &config.Config{Relays: relays, RelayFailureMode: config.RelayFailureSkip}
+	relayRewards, err := NewRelayRewards(networkParams, validatorKeyToPool, nil, cfg)
+	assert.NoError(t, err)
+	relayRewards.retryOpts = []retry.Option{retry.Attempts(1)}
+
+	// Only the payloads of the healthy relay are counted
+	rewards, slotsWithRewards, err := relayRewards.GetRelayRewards(10)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(1000000000000000000), rewards["pool1"])
+	assert.Equal(t, []string{healthy.URL}, slotsWithRewards[20].Relays)
+
+	for _, relayStats := range relayRewards.GetRelayStats() {
+		assert.Equal(t, relayStats.Relay == invalid.URL, relayStats.Skipped)
+	}
+}
+
+func TestGetRelayRewards_Cache(t *testing.T) {
+	nOfRequests := 0
+	server := newRelayServer(t, []common.BidTraceV2JSON{
+		{Slot: 0, ProposerPubkey: "0x1234567890abcdef", Value: "1000000000000000000"},
+		{Slot: 1, ProposerPubkey: "0x1234567890abcdef", Value: "1000000000000000000"},
+		{Slot: 10, ProposerPubkey: "0x1234567890abcdef", Value: "1000000000000000000"},
+	}, 100, &nOfRequests)
+	defer server.Close()
+
+	relays := []string{server.URL}
+
+	database, err := db.New(filepath.Join(t.TempDir(), "db.db"))
+	assert.NoError(t, err)
+	assert.NoError(t, database.CreateTables())
+
+	// Slots 0 and 1 are old, slot 10 is too recent to be cached
+	networkParams := &NetworkParameters{
+		slotsInEpoch:   2,
+		secondsPerSlot: 12,
+		genesisSeconds: uint64(time.Now().Unix()) - 12*10,
+	}
+	validatorKeyToPool := map[string]string{
+		"0x1234567890abcdef": "pool1",
+	}
+	relayRewards, err := NewRelayRewards(networkParams, validatorKeyToPool, database, &config.Config{Relays: relays})
+	assert.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		rewards, _, err := relayRewards.GetRelayRewards(0)
+		assert.NoError(t, err)
+		assert.Equal(t, big.NewInt(2000000000000000000), rewards["pool1"])
+		_, _, err = relayRewards.GetRelayRewards(5)
+		assert.NoError(t, err)
+	}
+	// Epoch 0 is requested once, epoch 5 every time
+	assert.Equal(t, 1+2, nOfRequests)
+}
+
+func TestGetRelayRewards_RateLimit(t *testing.T) {
+	// Relay without cursor support, requested per slot
+	nOfRequests := 0
+	server := newRelayServer(t, []common.BidTraceV2JSON{
+		{Slot: 1, ProposerPubkey: "0x1234567890abcdef", Value: "1"},
+	}, 0, &nOfRequests)
+	defer server.Close()
+
+	relays := []string{server.URL}
+
+	networkParams := &NetworkParameters{
+		slotsInEpoch: 4,
+	}
+	relayRewards, err := NewRelayRewards(networkParams, map[string]string{"0x1234567890abcdef": "pool1"}, nil, &config.Config{Relays: relays, RelayQps: 20})
+	assert.NoError(t, err)
+
+	// The first request is allowed by the burst, the rest wait 50ms each
+	start := time.Now()
+	rewards, _, err := relayRewards.GetRelayRewards(0)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 190*time.Millisecond)
+	assert.Equal(t, big.NewInt(1), rewards["pool1"])
+	assert.Equal(t, 1+4, nOfRequests)
+}
+
+// Relay serving the given bids received from the builders by slot
+func newBidsRelayServer(t *testing.T, bids []common.BidTraceV2JSON) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Contains(t, r.URL.Path, "/relay/v1/data/bidtraces/builder_blocks_received")
+		slot, err := strconv.ParseUint(r.URL.Query().Get("slot"), 10, 64)
+		assert.NoError(t, err)
+		response := make([]common.BidTraceV2JSON, 0)
+		for _, bid := range bids {
+			if bid.Slot == slot {
+				response = append(response, bid)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+}
+
+func TestGetBestBid(t *testing.T) {
+	relayA := newBidsRelayServer(t, []common.BidTraceV2JSON{
+		{Slot: 20, BuilderPubkey: "0xa1", Value: "1000"},
+		{Slot: 20, BuilderPubkey: "0xa2", Value: "3000"},
+		{Slot: 21, BuilderPubkey: "0xa1", Value: "9000"},
+	})
+	defer relayA.Close()
+	relayB := newBidsRelayServer(t, []common.BidTraceV2JSON{
+		{Slot: 20, BuilderPubkey: "0xb1", Value: "2000"},
+		{Slot: 20, BuilderPubkey: "0xb2", Value: "invalid"},
+	})
+	defer relayB.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	relayRewards, err := NewRelayRewards(&NetworkParameters{slotsInEpoch: 2}, map[string]string{}, nil, &config.Config{Relays: []string{relayA.URL, relayB.URL, down.URL}})
+	assert.NoError(t, err)
+	relayRewards.retryOpts = []retry.Option{retry.Attempts(1)}
+
+	// The failing relay is skipped
+	bestBid, err := relayRewards.GetBestBid(20)
+	assert.NoError(t, err)
+	assert.Equal(t, &schemas.RelayBid{Slot: 20, Relay: relayA.URL, BuilderPubKey: "0xa2", Value: big.NewInt(3000)}, bestBid)
+
+	// No bids for the slot
+	bestBid, err = relayRewards.GetBestBid(22)
+	assert.NoError(t, err)
+	assert.Nil(t, bestBid)
+
+	// All the relays fail
+	relayRewards, err = NewRelayRewards(&NetworkParameters{slotsInEpoch: 2}, map[string]string{}, nil, &config.Config{Relays: []string{down.URL}})
+	assert.NoError(t, err)
+	relayRewards.retryOpts = []retry.Option{retry.Attempts(1)}
+	_, err = relayRewards.GetBestBid(20)
+	assert.Error(t, err)
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/attestantio/go-eth2-client/api"
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Epochs before the finalized one that are checked for reorgs on startup,
+// since the finalized epoch of the previous run is not known
+const reorgCheckStartupEpochs = 4
+
+// Identifies the canonical blocks of an epoch as slot:root, so that both a
+// different block and a block moved to another slot are detected
+func GetBlockRoots(headers []*v1.BeaconBlockHeader) []string {
+	blockRoots := make([]string, 0, len(headers))
+	for _, header := range headers {
+		if header == nil || header.Header == nil || header.Header.Message == nil {
+			continue
+		}
+		blockRoots = append(blockRoots, fmt.Sprintf("%d:%#x", header.Header.Message.Slot, header.Root))
+	}
+	return blockRoots
+}
+
+func (a *Metrics) GetFinalizedEpoch() (uint64, error) {
+	finality, err := a.httpClient.Finality(context.Background(), &api.FinalityOpts{State: "head"})
+	if err != nil {
+		return 0, errors.Wrap(err, "error getting finality checkpoints")
+	}
+	if a.beaconCache != nil {
+		a.beaconCache.SetFinalizedEpoch(uint64(finality.Data.Finalized.Epoch), a.networkParameters.slotsInEpoch)
+	}
+	if a.stateCache != nil {
+		a.stateCache.SetFinalizedEpoch(uint64(finality.Data.Finalized.Epoch))
+	}
+	return uint64(finality.Data.Finalized.Epoch), nil
+}
+
+// Compares the block roots used to compute the epochs that were finalized since
+// the last check with the canonical ones, and processes again the epochs that
+// changed so that their rows are updated. Returns the last finalized epoch.
+func (a *Metrics) CheckReorgs(lastFinalizedEpoch uint64) (uint64, error) {
+	finalizedEpoch, err := a.GetFinalizedEpoch()
+	if err != nil {
+		return lastFinalizedEpoch, err
+	}
+	if finalizedEpoch <= lastFinalizedEpoch {
+		return lastFinalizedEpoch, nil
+	}
+
+	firstEpoch := lastFinalizedEpoch + 1
+	if lastFinalizedEpoch == 0 && finalizedEpoch > reorgCheckStartupEpochs {
+		firstEpoch = finalizedEpoch - reorgCheckStartupEpochs
+	}
+
+	for epoch := firstEpoch; epoch <= finalizedEpoch; epoch++ {
+		storedRoots, found, err := a.db.GetEpochBlockRoots(epoch)
+		if err != nil {
+			return lastFinalizedEpoch, errors.Wrap(err, "error getting stored block roots")
+		}
+		// Not processed, nothing to correct
+		if !found {
+			continue
+		}
+
+		proposed, err := a.proposalDuties.GetProposedBlocks(epoch)
+		if err != nil {
+			return lastFinalizedEpoch, errors.Wrap(err, "error getting proposed blocks")
+		}
+		if slices.Equal(storedRoots, GetBlockRoots(proposed)) {
+			continue
+		}
+
+		log.WithField("Epoch", epoch).Warn("Block roots changed after the epoch was processed, processing it again")
+		a.beaconState.InvalidateStates(epoch)
+		// The epoch is final now, so its rows are too
+		if _, _, err := a.processEpochInMode(context.Background(), epoch, nil, nil, schemas.ProcessingModeFinalized); err != nil {
+			return lastFinalizedEpoch, errors.Wrap(err, "error processing reorged epoch")
+		}
+	}
+	return finalizedEpoch, nil
+}
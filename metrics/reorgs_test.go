@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/deneb"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GetBlockRoots(t *testing.T) {
+	headers := []*v1.BeaconBlockHeader{
+		{
+			Root:   phase0.Root{0x01},
+			Header: &phase0.SignedBeaconBlockHeader{Message: &phase0.BeaconBlockHeader{Slot: 100}},
+		},
+		nil,
+		{
+			Root:   phase0.Root{0x02},
+			Header: &phase0.SignedBeaconBlockHeader{Message: &phase0.BeaconBlockHeader{Slot: 102}},
+		},
+	}
+
+	require.Equal(t, []string{
+		"100:0x0100000000000000000000000000000000000000000000000000000000000000",
+		"102:0x0200000000000000000000000000000000000000000000000000000000000000",
+	}, GetBlockRoots(headers))
+	require.Equal(t, []string{}, GetBlockRoots(nil))
+}
+
+func Test_InvalidateStates(t *testing.T) {
+	// Serves the states after the reorg, with the slot requested
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slot := strings.TrimPrefix(r.URL.Path, "/eth/v2/debug/beacon/states/")
+		requested = append(requested, slot)
+		slotNumber, err := strconv.ParseUint(slot, 10, 64)
+		require.NoError(t, err)
+		state := &deneb.BeaconState{Slot: phase0.Slot(slotNumber), Balances: []phase0.Gwei{32000000000}}
+		fillDenebState(state)
+		data, err := state.MarshalSSZ()
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Eth-Consensus-Version", "deneb")
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	stateCache, err := NewStateCache(4, dir, 0, false)
+	require.NoError(t, err)
+	stateCache.SetFinalizedEpoch(12)
+	beaconState := &BeaconState{
+		networkParameters: &NetworkParameters{slotsInEpoch: 32},
+		stateCache:        stateCache,
+		stateDownloader:   NewStateDownloader(server.URL, map[string]string{}, 10*time.Second, 1),
+	}
+
+	// States of the blocks before the reorg, in memory and on disk
+	for _, epoch := range []uint64{9, 10, 11} {
+		state := &deneb.BeaconState{Slot: 1, Balances: []phase0.Gwei{31000000000}}
+		fillDenebState(state)
+		stateCache.Put(epoch, &spec.VersionedBeaconState{Version: spec.DataVersionDeneb, Deneb: state})
+	}
+
+	beaconState.InvalidateStates(10)
+	for _, epoch := range []uint64{9, 10} {
+		state, err := beaconState.GetBeaconState(context.Background(), epoch)
+		require.NoError(t, err)
+		require.Equal(t, phase0.Slot((epoch+1)*32-1), state.Deneb.Slot)
+	}
+	require.Equal(t, []string{"319", "351"}, requested)
+
+	// The next epoch is kept
+	state, err := beaconState.GetBeaconState(context.Background(), 11)
+	require.NoError(t, err)
+	require.Equal(t, phase0.Slot(1), state.Deneb.Slot)
+
+	// And the fresh states replaced the files
+	stateCache, err = NewStateCache(0, dir, 0, false)
+	require.NoError(t, err)
+	state, ok := stateCache.Get(10)
+	require.True(t, ok)
+	require.Equal(t, phase0.Slot(351), state.Deneb.Slot)
+}
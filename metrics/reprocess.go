@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/bilinearlabs/eth-metrics/db"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Columns identifying a row among the ones of an epoch, across all tables
+var rowKeyColumns = []string{"f_pool", "f_slot", "f_validator_index", "f_miss_type", "f_slashing_type", "f_relay", "f_request_index"}
+
+// Columns that change every time a row is written, so they are not compared
+var volatileColumns = []string{"f_timestamp", "f_attempts"}
+
+// Stored rows of an epoch by table and row key
+type epochSnapshot map[string]map[string]map[string]interface{}
+
+// A value that changed after processing an epoch again
+type rowChange struct {
+	table  string
+	row    string
+	column string
+	old    interface{}
+	new    interface{}
+}
+
+// Processes an epoch that was already stored again, only for the given pool if
+// set, and logs the values that changed. Rows are upserted, so it can be run
+// as many times as needed, e.g. after fixing a bug.
+func (a *Metrics) Reprocess(epoch uint64, poolName string) error {
+	if a.db == nil {
+		return errors.New("reprocessing requires a database")
+	}
+	if err := a.setup(); err != nil {
+		return err
+	}
+	defer a.clickHouse.Close()
+
+	var poolNames []string
+	if poolName != "" {
+		if _, ok := a.validatorKeysPerPool[poolName]; !ok {
+			return errors.New("unknown pool: " + poolName)
+		}
+		poolNames = []string{poolName}
+	}
+
+	before, err := snapshotEpoch(a.db, epoch, poolName)
+	if err != nil {
+		return err
+	}
+	if _, _, err := a.processEpoch(context.Background(), epoch, nil, poolNames); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("error processing epoch %d", epoch))
+	}
+	after, err := snapshotEpoch(a.db, epoch, poolName)
+	if err != nil {
+		return err
+	}
+
+	changes := diffSnapshots(before, after)
+	for _, change := range changes {
+		log.WithFields(log.Fields{"Epoch": epoch, "Table": change.table, "Row": change.row}).Info(
+			change.column, ": ", change.old, " -> ", change.new)
+	}
+	log.WithField("Epoch", epoch).Info("Reprocessed epoch, ", len(changes), " values changed")
+	return nil
+}
+
+func snapshotEpoch(database *db.Database, epoch uint64, poolName string) (epochSnapshot, error) {
+	snapshot := make(epochSnapshot)
+	for table, hasPool := range db.EpochTables {
+		if poolName != "" && !hasPool {
+			continue
+		}
+		columns, rows, err := database.GetEpochRows(table, epoch, epoch, poolName)
+		if err != nil {
+			return nil, err
+		}
+		snapshot[table] = make(map[string]map[string]interface{})
+		for _, row := range rows {
+			values := make(map[string]interface{})
+			keys := make([]string, 0)
+			for i, column := range columns {
+				values[column] = row[i]
+			}
+			for _, column := range rowKeyColumns {
+				if value, ok := values[column]; ok {
+					keys = append(keys, fmt.Sprintf("%s=%v", column, value))
+				}
+			}
+			snapshot[table][strings.Join(keys, ",")] = values
+		}
+	}
+	return snapshot, nil
+}
+
+// Returns the values that changed, were added or were removed, sorted by
+// table, row and column
+func diffSnapshots(before epochSnapshot, after epochSnapshot) []rowChange {
+	changes := make([]rowChange, 0)
+	for table, afterRows := range after {
+		beforeRows := before[table]
+		for row, afterValues := range afterRows {
+			beforeValues := beforeRows[row]
+			for column, value := range afterValues {
+				if slices.Contains(volatileColumns, column) {
+					continue
+				}
+				oldValue, found := beforeValues[column]
+				if !found || !reflect.DeepEqual(oldValue, value) {
+					changes = append(changes, rowChange{table, row, column, oldValue, value})
+				}
+			}
+		}
+		for row, beforeValues := range beforeRows {
+			if _, found := afterRows[row]; found {
+				continue
+			}
+			for column, value := range beforeValues {
+				if !slices.Contains(volatileColumns, column) {
+					changes = append(changes, rowChange{table, row, column, value, nil})
+				}
+			}
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].table != changes[j].table {
+			return changes[i].table < changes[j].table
+		}
+		if changes[i].row != changes[j].row {
+			return changes[i].row < changes[j].row
+		}
+		return changes[i].column < changes[j].column
+	})
+	return changes
+}
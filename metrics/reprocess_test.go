@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_DiffSnapshots(t *testing.T) {
+	before := epochSnapshot{
+		"t_pools_metrics_summary": {
+			"f_pool=pool_a": {"f_epoch": int64(100), "f_pool": "pool_a", "f_mev_rewards_wei": "0", "f_timestamp": "10:00"},
+			"f_pool=pool_b": {"f_epoch": int64(100), "f_pool": "pool_b", "f_mev_rewards_wei": "5", "f_timestamp": "10:00"},
+		},
+		"t_missed_attestations": {
+			"f_pool=pool_a,f_validator_index=1,f_miss_type=head": {"f_epoch": int64(100), "f_miss_type": "head"},
+		},
+	}
+	after := epochSnapshot{
+		"t_pools_metrics_summary": {
+			"f_pool=pool_a": {"f_epoch": int64(100), "f_pool": "pool_a", "f_mev_rewards_wei": "7", "f_timestamp": "11:00"},
+			"f_pool=pool_b": {"f_epoch": int64(100), "f_pool": "pool_b", "f_mev_rewards_wei": "5", "f_timestamp": "11:00"},
+		},
+		"t_missed_attestations": {},
+	}
+
+	// The write time is not a change
+	require.Equal(t, []rowChange{
+		{"t_missed_attestations", "f_pool=pool_a,f_validator_index=1,f_miss_type=head", "f_epoch", int64(100), nil},
+		{"t_missed_attestations", "f_pool=pool_a,f_validator_index=1,f_miss_type=head", "f_miss_type", "head", nil},
+		{"t_pools_metrics_summary", "f_pool=pool_a", "f_mev_rewards_wei", "0", "7"},
+	}, diffSnapshots(before, after))
+
+	require.Empty(t, diffSnapshots(after, after))
+}
@@ -0,0 +1,221 @@
+package metrics
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bilinearlabs/eth-metrics/alerts"
+	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/bilinearlabs/eth-metrics/epochtime"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/pkg/errors"
+)
+
+const dayFormat = "2006-01-02"
+
+// Targets of the SLA of a pool in percent, nil if not set
+type SLATargets struct {
+	Attestation *float64
+	Proposal    *float64
+}
+
+// Parses the targets of the config, as pool:metric:percent with metric
+// attestation or proposal. The ones of * apply to the pools without their own.
+func ParseSLATargets(values []string) (map[string]SLATargets, error) {
+	targets := make(map[string]SLATargets)
+	for _, value := range values {
+		parts := strings.Split(value, ":")
+		if len(parts) != 3 || parts[0] == "" {
+			return nil, errors.New("invalid sla target, expected pool:metric:percent: " + value)
+		}
+		percent, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil || percent <= 0 || percent > 100 {
+			return nil, errors.New("invalid percent of sla target: " + value)
+		}
+		poolTargets := targets[parts[0]]
+		switch parts[1] {
+		case "attestation":
+			poolTargets.Attestation = &percent
+		case "proposal":
+			poolTargets.Proposal = &percent
+		default:
+			return nil, errors.New("unknown sla metric, expected attestation or proposal: " + value)
+		}
+		targets[parts[0]] = poolTargets
+	}
+	return targets, nil
+}
+
+func GetSLATargets(targets map[string]SLATargets, poolName string) SLATargets {
+	poolTargets := targets[poolName]
+	if poolTargets.Attestation == nil {
+		poolTargets.Attestation = targets["*"].Attestation
+	}
+	if poolTargets.Proposal == nil {
+		poolTargets.Proposal = targets["*"].Proposal
+	}
+	return poolTargets
+}
+
+// Returns the first and last day of the period that contains the day
+func GetSLAPeriodDays(period string, day time.Time) (time.Time, time.Time) {
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case config.SLAPeriodWeek:
+		start := day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+		return start, start.AddDate(0, 0, 6)
+	case config.SLAPeriodMonth:
+		start := day.AddDate(0, 0, 1-day.Day())
+		return start, start.AddDate(0, 1, -1)
+	case config.SLAPeriodQuarter:
+		start := time.Date(day.Year(), day.Month()-(day.Month()-1)%3, 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 3, -1)
+	default:
+		return day, day
+	}
+}
+
+// Returns the epochs that start in the day, which may be none before genesis
+func GetEpochsOfDay(clock *epochtime.Clock, day time.Time) (uint64, uint64, bool) {
+	end := day.AddDate(0, 0, 1)
+	if !end.After(clock.Genesis()) {
+		return 0, 0, false
+	}
+	first := clock.EpochAt(day)
+	if clock.EpochStart(first).Before(day) {
+		first++
+	}
+	last := clock.EpochAt(end.Add(-time.Nanosecond))
+	return first, last, first <= last
+}
+
+// Sums the SLAs of the days into one per pool for the period
+func SumSLAs(period string, start string, days []schemas.PoolSLA) []schemas.PoolSLA {
+	slas := make([]schemas.PoolSLA, 0)
+	poolIndex := make(map[string]int)
+	for _, day := range days {
+		i, ok := poolIndex[day.PoolName]
+		if !ok {
+			i = len(slas)
+			poolIndex[day.PoolName] = i
+			slas = append(slas, schemas.PoolSLA{Period: period, Start: start, PoolName: day.PoolName})
+		}
+		slas[i].NOfEpochs += day.NOfEpochs
+		slas[i].NOfMaintenanceEpochs += day.NOfMaintenanceEpochs
+		slas[i].NOfAttestationDuties += day.NOfAttestationDuties
+		slas[i].NOfMissedAttestations += day.NOfMissedAttestations
+		slas[i].NOfScheduledBlocks += day.NOfScheduledBlocks
+		slas[i].NOfProposedBlocks += day.NOfProposedBlocks
+	}
+	return slas
+}
+
+// Sets the uptime and proposal success of the SLA, and if they are below the
+// targets
+func SetSLARates(sla *schemas.PoolSLA, targets SLATargets) {
+	sla.AttestationUptime, sla.ProposalSuccess = nil, nil
+	if sla.NOfAttestationDuties > 0 {
+		uptime := 100 * float64(sla.NOfAttestationDuties-sla.NOfMissedAttestations) / float64(sla.NOfAttestationDuties)
+		sla.AttestationUptime = &uptime
+	}
+	if sla.NOfScheduledBlocks > 0 {
+		success := 100 * float64(sla.NOfProposedBlocks) / float64(sla.NOfScheduledBlocks)
+		sla.ProposalSuccess = &success
+	}
+	sla.AttestationTarget, sla.ProposalTarget = targets.Attestation, targets.Proposal
+	sla.AttestationBreached = sla.AttestationUptime != nil && sla.AttestationTarget != nil && *sla.AttestationUptime < *sla.AttestationTarget
+	sla.ProposalBreached = sla.ProposalSuccess != nil && sla.ProposalTarget != nil && *sla.ProposalSuccess < *sla.ProposalTarget
+}
+
+// Returns an alert per SLA that fell below a target since it was last stored,
+// so that each breach is alerted once per period unless the SLA recovers
+func GetSLABreachAlerts(epoch uint64, previous []schemas.PoolSLA, current []schemas.PoolSLA) []alerts.Alert {
+	wasBreached := make(map[string]schemas.PoolSLA)
+	for _, sla := range previous {
+		wasBreached[sla.PoolName] = sla
+	}
+	slaAlerts := make([]alerts.Alert, 0)
+	for _, sla := range current {
+		prev := wasBreached[sla.PoolName]
+		if sla.AttestationBreached && !prev.AttestationBreached {
+			slaAlerts = append(slaAlerts, alerts.Alert{
+				Kind:     alerts.SLABreach,
+				Epoch:    epoch,
+				PoolName: sla.PoolName,
+				Message: fmt.Sprintf("attestation uptime of the %s starting %s is %.2f%%, below the target of %.2f%%",
+					sla.Period, sla.Start, *sla.AttestationUptime, *sla.AttestationTarget),
+			})
+		}
+		if sla.ProposalBreached && !prev.ProposalBreached {
+			slaAlerts = append(slaAlerts, alerts.Alert{
+				Kind:     alerts.SLABreach,
+				Epoch:    epoch,
+				PoolName: sla.PoolName,
+				Message: fmt.Sprintf("proposal success of the %s starting %s is %.2f%% (%d of %d blocks), below the target of %.2f%%",
+					sla.Period, sla.Start, *sla.ProposalSuccess, sla.NOfProposedBlocks, sla.NOfScheduledBlocks, *sla.ProposalTarget),
+			})
+		}
+	}
+	return slaAlerts
+}
+
+// Updates the SLA of the pools in the periods that contain the day of the
+// epoch, and alerts the new breaches. The days are always stored, since the
+// longer periods are summed from them.
+func (a *Metrics) updatePoolSLA(epoch uint64) error {
+	if a.db == nil {
+		return nil
+	}
+	clock := a.networkParameters.Clock()
+	epochStart := clock.EpochStart(epoch)
+	day := time.Date(epochStart.Year(), epochStart.Month(), epochStart.Day(), 0, 0, 0, 0, time.UTC)
+	firstEpoch, lastEpoch, ok := GetEpochsOfDay(clock, day)
+	if !ok {
+		return nil
+	}
+	days, err := a.db.GetPoolSLASums(firstEpoch, lastEpoch)
+	if err != nil {
+		return err
+	}
+	for i := range days {
+		days[i].Period = config.SLAPeriodDay
+		days[i].Start = day.Format(dayFormat)
+	}
+
+	for _, period := range config.SLAPeriods {
+		tracked := slices.Contains(a.config.SLAPeriods, period)
+		if !tracked && period != config.SLAPeriodDay {
+			continue
+		}
+
+		firstDay, lastDay := GetSLAPeriodDays(period, day)
+		start := firstDay.Format(dayFormat)
+		slas := days
+		if period != config.SLAPeriodDay {
+			periodDays, err := a.db.GetPoolSLAs(config.SLAPeriodDay, start, lastDay.Format(dayFormat))
+			if err != nil {
+				return err
+			}
+			slas = SumSLAs(period, start, periodDays)
+		}
+		previous, err := a.db.GetPoolSLAs(period, start, start)
+		if err != nil {
+			return err
+		}
+		for i := range slas {
+			SetSLARates(&slas[i], GetSLATargets(a.slaTargets, slas[i].PoolName))
+			if err := a.db.StorePoolSLA(slas[i]); err != nil {
+				return errors.Wrap(err, "could not store pool sla")
+			}
+		}
+		if tracked {
+			for _, alert := range GetSLABreachAlerts(epoch, previous, slas) {
+				a.sendAlert(alert)
+			}
+		}
+	}
+	return nil
+}
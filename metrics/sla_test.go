@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bilinearlabs/eth-metrics/alerts"
+	"github.com/bilinearlabs/eth-metrics/epochtime"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseSLATargets(t *testing.T) {
+	targets, err := ParseSLATargets([]string{"*:attestation:99.5", "*:proposal:95", "pool_a:attestation:98"})
+	require.NoError(t, err)
+
+	poolTargets := GetSLATargets(targets, "pool_a")
+	require.Equal(t, 98.0, *poolTargets.Attestation)
+	require.Equal(t, 95.0, *poolTargets.Proposal)
+	poolTargets = GetSLATargets(targets, "pool_b")
+	require.Equal(t, 99.5, *poolTargets.Attestation)
+
+	poolTargets = GetSLATargets(map[string]SLATargets{}, "pool_a")
+	require.Nil(t, poolTargets.Attestation)
+	require.Nil(t, poolTargets.Proposal)
+
+	for _, value := range []string{"pool_a:attestation", ":attestation:99", "pool_a:sync:99", "pool_a:attestation:101", "pool_a:attestation:x"} {
+		_, err = ParseSLATargets([]string{value})
+		require.Error(t, err, value)
+	}
+}
+
+func Test_GetSLAPeriodDays(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	// A Thursday
+	day := time.Date(2024, 5, 16, 13, 30, 0, 0, time.UTC)
+
+	start, end := GetSLAPeriodDays("day", day)
+	require.Equal(t, date(2024, 5, 16), start)
+	require.Equal(t, date(2024, 5, 16), end)
+	start, end = GetSLAPeriodDays("week", day)
+	require.Equal(t, date(2024, 5, 13), start)
+	require.Equal(t, date(2024, 5, 19), end)
+	start, end = GetSLAPeriodDays("month", day)
+	require.Equal(t, date(2024, 5, 1), start)
+	require.Equal(t, date(2024, 5, 31), end)
+	start, end = GetSLAPeriodDays("quarter", day)
+	require.Equal(t, date(2024, 4, 1), start)
+	require.Equal(t, date(2024, 6, 30), end)
+
+	// Sundays are the last day of the week
+	start, _ = GetSLAPeriodDays("week", date(2024, 5, 19))
+	require.Equal(t, date(2024, 5, 13), start)
+	start, end = GetSLAPeriodDays("quarter", date(2024, 12, 31))
+	require.Equal(t, date(2024, 10, 1), start)
+	require.Equal(t, date(2024, 12, 31), end)
+}
+
+func Test_GetEpochsOfDay(t *testing.T) {
+	// Mainnet genesis, at 12:00:23 UTC
+	clock := epochtime.New(time.Unix(1606824023, 0), 12*time.Second, 32)
+
+	// Epochs starting from the genesis
+	first, last, ok := GetEpochsOfDay(clock, time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC))
+	require.True(t, ok)
+	require.Equal(t, uint64(0), first)
+	require.Equal(t, uint64(112), last)
+
+	// The first epoch starting after midnight
+	first, last, ok = GetEpochsOfDay(clock, time.Date(2020, 12, 2, 0, 0, 0, 0, time.UTC))
+	require.True(t, ok)
+	require.Equal(t, uint64(113), first)
+	require.Equal(t, uint64(337), last)
+
+	_, _, ok = GetEpochsOfDay(clock, time.Date(2020, 11, 30, 0, 0, 0, 0, time.UTC))
+	require.False(t, ok)
+}
+
+func Test_SetSLARates(t *testing.T) {
+	days := []schemas.PoolSLA{
+		{PoolName: "pool_a", NOfEpochs: 225, NOfAttestationDuties: 1000, NOfMissedAttestations: 10, NOfScheduledBlocks: 1, NOfProposedBlocks: 1},
+		{PoolName: "pool_b", NOfEpochs: 225, NOfMaintenanceEpochs: 225},
+		{PoolName: "pool_a", NOfEpochs: 225, NOfMaintenanceEpochs: 10, NOfAttestationDuties: 1000, NOfScheduledBlocks: 1},
+	}
+	slas := SumSLAs("week", "2024-05-13", days)
+	require.Len(t, slas, 2)
+	require.Equal(t, schemas.PoolSLA{
+		Period:                "week",
+		Start:                 "2024-05-13",
+		PoolName:              "pool_a",
+		NOfEpochs:             450,
+		NOfMaintenanceEpochs:  10,
+		NOfAttestationDuties:  2000,
+		NOfMissedAttestations: 10,
+		NOfScheduledBlocks:    2,
+		NOfProposedBlocks:     1,
+	}, slas[0])
+
+	attestationTarget, proposalTarget := 99.9, 50.0
+	SetSLARates(&slas[0], SLATargets{Attestation: &attestationTarget, Proposal: &proposalTarget})
+	require.InDelta(t, 99.5, *slas[0].AttestationUptime, 1e-9)
+	require.Equal(t, 50.0, *slas[0].ProposalSuccess)
+	require.True(t, slas[0].AttestationBreached)
+	require.False(t, slas[0].ProposalBreached)
+
+	// Only maintenance, so no duties to rate
+	SetSLARates(&slas[1], SLATargets{Attestation: &attestationTarget})
+	require.Nil(t, slas[1].AttestationUptime)
+	require.Nil(t, slas[1].ProposalSuccess)
+	require.False(t, slas[1].AttestationBreached)
+}
+
+func Test_GetSLABreachAlerts(t *testing.T) {
+	uptime, target := 98.5, 99.0
+	current := []schemas.PoolSLA{{
+		Period:              "month",
+		Start:               "2024-05-01",
+		PoolName:            "pool_a",
+		AttestationUptime:   &uptime,
+		AttestationTarget:   &target,
+		AttestationBreached: true,
+	}}
+
+	slaAlerts := GetSLABreachAlerts(100, nil, current)
+	require.Equal(t, []alerts.Alert{{
+		Kind:     alerts.SLABreach,
+		Epoch:    100,
+		PoolName: "pool_a",
+		Message:  "attestation uptime of the month starting 2024-05-01 is 98.50%, below the target of 99.00%",
+	}}, slaAlerts)
+
+	// Alerted once while it is breached
+	require.Empty(t, GetSLABreachAlerts(101, current, current))
+}
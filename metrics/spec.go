@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/bilinearlabs/eth-metrics/forkdata"
+	"github.com/pkg/errors"
+)
+
+// Returns a numeric parameter of the spec. go-eth2-client parses the decimal
+// strings returned by the beacon nodes into uint64, but the durations are
+// parsed into time.Duration, and values that it doesn't recognize, or that a
+// mock server returns as JSON numbers, are left as they come.
+func SpecUint64(specData map[string]any, key string) (uint64, error) {
+	value, found := specData[key]
+	if !found {
+		return 0, errors.New(key + " not found in spec")
+	}
+	switch v := value.(type) {
+	case uint64:
+		return v, nil
+	case time.Duration:
+		return uint64(v / time.Second), nil
+	case int:
+		if v >= 0 {
+			return uint64(v), nil
+		}
+	case int64:
+		if v >= 0 {
+			return uint64(v), nil
+		}
+	case float64:
+		if v >= 0 && v <= math.MaxUint64 && v == math.Trunc(v) {
+			return uint64(v), nil
+		}
+	case json.Number:
+		if n, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+			return n, nil
+		}
+	case string:
+		s := strings.TrimSpace(v)
+		if hex, ok := strings.CutPrefix(s, "0x"); ok {
+			if n, err := strconv.ParseUint(hex, 16, 64); err == nil {
+				return n, nil
+			}
+		} else if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return n, nil
+		}
+	}
+	return 0, errors.Errorf("%s is not a number: %v", key, value)
+}
+
+// Returns a duration parameter of the spec, where numbers are seconds
+func SpecSeconds(specData map[string]any, key string) (time.Duration, error) {
+	value, found := specData[key]
+	if !found {
+		return 0, errors.New(key + " not found in spec")
+	}
+	switch v := value.(type) {
+	case time.Duration:
+		return v, nil
+	case string:
+		if d, err := time.ParseDuration(strings.TrimSpace(v)); err == nil && d >= 0 {
+			return d, nil
+		}
+	}
+	seconds, err := SpecUint64(specData, key)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// Returns the duration of a slot. Newer clients also return the duration in
+// milliseconds, which is used if SECONDS_PER_SLOT is missing.
+func SpecSlotDuration(specData map[string]any) (time.Duration, error) {
+	if _, found := specData["SECONDS_PER_SLOT"]; !found {
+		if _, found := specData["SLOT_DURATION_MS"]; found {
+			ms, err := SpecUint64(specData, "SLOT_DURATION_MS")
+			if err != nil {
+				return 0, err
+			}
+			return time.Duration(ms) * time.Millisecond, nil
+		}
+	}
+	return SpecSeconds(specData, "SECONDS_PER_SLOT")
+}
+
+// Returns the epoch of each fork after phase0 that the spec schedules, e.g.
+// ELECTRA_FORK_EPOCH. Forks the beacon node doesn't know are left out.
+func SpecForkEpochs(specData map[string]any) (map[spec.DataVersion]uint64, error) {
+	forkEpochs := make(map[spec.DataVersion]uint64)
+	for _, fork := range forkdata.Forks[1:] {
+		key := strings.ToUpper(fork.String()) + "_FORK_EPOCH"
+		if _, found := specData[key]; !found {
+			continue
+		}
+		epoch, err := SpecUint64(specData, key)
+		if err != nil {
+			return nil, err
+		}
+		forkEpochs[fork] = epoch
+	}
+	return forkEpochs, nil
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SpecParameters(t *testing.T) {
+	tests := []struct {
+		name          string
+		spec          string
+		slotsPerEpoch uint64
+		slotDuration  time.Duration
+	}{
+		// Beacon nodes return every value as a decimal string
+		{"lighthouse", `{"CONFIG_NAME":"mainnet","SLOTS_PER_EPOCH":"32","SECONDS_PER_SLOT":"12","CHURN_LIMIT_QUOTIENT":"65536"}`, 32, 12 * time.Second},
+		{"teku", `{"SLOTS_PER_EPOCH":"32","SECONDS_PER_SLOT":"12","GENESIS_FORK_VERSION":"0x00000000"}`, 32, 12 * time.Second},
+		{"nimbus", `{"PRESET_BASE":"gnosis","SLOTS_PER_EPOCH":"16","SECONDS_PER_SLOT":"5"}`, 16, 5 * time.Second},
+		{"prysm", `{"SLOTS_PER_EPOCH":"32","SECONDS_PER_SLOT":"12","SLOT_DURATION_MS":"12000"}`, 32, 12 * time.Second},
+		{"lodestar", `{"SLOTS_PER_EPOCH":"8","SLOT_DURATION_MS":"6000"}`, 8, 6 * time.Second},
+		// Mock servers often return numbers
+		{"mock", `{"SLOTS_PER_EPOCH":32,"SECONDS_PER_SLOT":12}`, 32, 12 * time.Second},
+		{"mock with durations", `{"SLOTS_PER_EPOCH":"0x20","SECONDS_PER_SLOT":"12s"}`, 32, 12 * time.Second},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			specData := make(map[string]any)
+			require.NoError(t, json.Unmarshal([]byte(test.spec), &specData))
+
+			slotsPerEpoch, err := SpecUint64(specData, "SLOTS_PER_EPOCH")
+			require.NoError(t, err)
+			require.Equal(t, test.slotsPerEpoch, slotsPerEpoch)
+			slotDuration, err := SpecSlotDuration(specData)
+			require.NoError(t, err)
+			require.Equal(t, test.slotDuration, slotDuration)
+		})
+	}
+
+	// As parsed by go-eth2-client
+	specData := map[string]any{"SLOTS_PER_EPOCH": uint64(32), "SECONDS_PER_SLOT": 12 * time.Second}
+	slotsPerEpoch, err := SpecUint64(specData, "SLOTS_PER_EPOCH")
+	require.NoError(t, err)
+	require.Equal(t, uint64(32), slotsPerEpoch)
+	slotDuration, err := SpecSlotDuration(specData)
+	require.NoError(t, err)
+	require.Equal(t, 12*time.Second, slotDuration)
+
+	// Errors instead of panics
+	_, err = SpecUint64(map[string]any{}, "SLOTS_PER_EPOCH")
+	require.EqualError(t, err, "SLOTS_PER_EPOCH not found in spec")
+	_, err = SpecUint64(map[string]any{"SLOTS_PER_EPOCH": "thirty-two"}, "SLOTS_PER_EPOCH")
+	require.EqualError(t, err, "SLOTS_PER_EPOCH is not a number: thirty-two")
+	_, err = SpecUint64(map[string]any{"SLOTS_PER_EPOCH": -1.0}, "SLOTS_PER_EPOCH")
+	require.Error(t, err)
+	_, err = SpecSlotDuration(map[string]any{"SECONDS_PER_SLOT": []byte{1}})
+	require.Error(t, err)
+}
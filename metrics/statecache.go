@@ -0,0 +1,288 @@
+package metrics
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/altair"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/deneb"
+	"github.com/attestantio/go-eth2-client/spec/electra"
+	"github.com/attestantio/go-eth2-client/spec/fulu"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Forks supported by the on-disk cache, used as file extension
+var stateCacheForks = []string{"altair", "bellatrix", "capella", "deneb", "electra", "fulu"}
+
+type stateCacheEntry struct {
+	epoch uint64
+	state *spec.VersionedBeaconState
+}
+
+// States kept on disk before the newest one, at least the state of the epoch
+// and of the previous one, which are needed to process it
+const stateCacheMinDiskEpochs = 2
+
+// Keeps the last fetched beacon states in memory, evicting the least recently
+// used one when full. Optionally, the states of finalized epochs are also
+// stored in a directory as ssz so that they survive restarts, the ones of the
+// last diskEpochs epochs, and states are compacted in memory, see
+// CompactBeaconState. Safe for concurrent use.
+type StateCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[uint64]*list.Element
+	dir      string
+	compact  bool
+	// Last compacted state, whose validators are shared with the next one
+	lastCompacted *spec.VersionedBeaconState
+
+	// Unknown until set, so no state is written to disk
+	finalizedEpoch uint64
+	diskEpochs     uint64
+	newestOnDisk   uint64
+}
+
+func NewStateCache(capacity int, dir string, diskEpochs uint64, compact bool) (*StateCache, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, errors.Wrap(err, "could not create state cache dir")
+		}
+	}
+	return &StateCache{
+		capacity:   capacity,
+		order:      list.New(),
+		entries:    make(map[uint64]*list.Element),
+		dir:        dir,
+		compact:    compact,
+		diskEpochs: max(diskEpochs, stateCacheMinDiskEpochs),
+	}, nil
+}
+
+// Sets the last finalized epoch. The states of the epochs before it can't
+// change anymore, so they are written to disk.
+func (c *StateCache) SetFinalizedEpoch(finalizedEpoch uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.finalizedEpoch = finalizedEpoch
+}
+
+func (c *StateCache) Get(epoch uint64) (*spec.VersionedBeaconState, bool) {
+	c.mu.Lock()
+	if elem, ok := c.entries[epoch]; ok {
+		c.order.MoveToFront(elem)
+		c.mu.Unlock()
+		return elem.Value.(*stateCacheEntry).state, true
+	}
+	c.mu.Unlock()
+
+	if c.dir == "" {
+		return nil, false
+	}
+
+	state, err := c.readFromDisk(epoch)
+	if err != nil {
+		if !os.IsNotExist(errors.Cause(err)) {
+			log.Warn("could not read beacon state from disk cache: ", err)
+		}
+		return nil, false
+	}
+	return c.putInMemory(epoch, state), true
+}
+
+// Stores the state and returns the one to use instead of it, which is the
+// compacted one if states are compacted. The full state is written to disk if
+// the epoch is finalized, since a reorg could change it otherwise, and the
+// states that are too old are removed.
+func (c *StateCache) Put(epoch uint64, state *spec.VersionedBeaconState) *spec.VersionedBeaconState {
+	c.mu.Lock()
+	finalized := epoch < c.finalizedEpoch
+	c.mu.Unlock()
+	if c.dir != "" && finalized {
+		if err := c.writeToDisk(epoch, state); err != nil {
+			log.Warn("could not write beacon state to disk cache: ", err)
+		}
+		c.pruneDisk(epoch)
+	}
+
+	return c.putInMemory(epoch, state)
+}
+
+// Removes the states on disk more than diskEpochs before the newest one
+func (c *StateCache) pruneDisk(epoch uint64) {
+	c.mu.Lock()
+	if epoch <= c.newestOnDisk {
+		c.mu.Unlock()
+		return
+	}
+	c.newestOnDisk = epoch
+	c.mu.Unlock()
+
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
+		log.Warn("could not list the disk cache of beacon states: ", err)
+		return
+	}
+	for _, file := range files {
+		var fileEpoch uint64
+		if _, err := fmt.Sscanf(file.Name(), "state_%d.", &fileEpoch); err != nil {
+			continue
+		}
+		if fileEpoch+c.diskEpochs <= epoch {
+			if err := os.Remove(filepath.Join(c.dir, file.Name())); err != nil {
+				log.Warn("could not remove beacon state from disk cache: ", err)
+			}
+		}
+	}
+}
+
+// Removes the state of the epoch from memory and disk, e.g. after a reorg of
+// its blocks, so that it is fetched again
+func (c *StateCache) Invalidate(epoch uint64) {
+	c.mu.Lock()
+	if elem, ok := c.entries[epoch]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, epoch)
+	}
+	c.mu.Unlock()
+
+	if c.dir == "" {
+		return
+	}
+	for _, fork := range stateCacheForks {
+		if err := os.Remove(c.statePath(epoch, fork)); err != nil && !os.IsNotExist(err) {
+			log.Warn("could not remove beacon state from disk cache: ", err)
+		}
+	}
+}
+
+func (c *StateCache) putInMemory(epoch uint64, state *spec.VersionedBeaconState) *spec.VersionedBeaconState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.compact {
+		state = CompactBeaconState(state, c.lastCompacted)
+		c.lastCompacted = state
+	}
+
+	if c.capacity <= 0 {
+		return state
+	}
+
+	if elem, ok := c.entries[epoch]; ok {
+		elem.Value.(*stateCacheEntry).state = state
+		c.order.MoveToFront(elem)
+		return state
+	}
+
+	c.entries[epoch] = c.order.PushFront(&stateCacheEntry{epoch: epoch, state: state})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*stateCacheEntry).epoch)
+	}
+	return state
+}
+
+func (c *StateCache) statePath(epoch uint64, fork string) string {
+	return filepath.Join(c.dir, fmt.Sprintf("state_%d.%s.ssz", epoch, fork))
+}
+
+func (c *StateCache) writeToDisk(epoch uint64, state *spec.VersionedBeaconState) error {
+	var data []byte
+	var fork string
+	var err error
+	if state.Altair != nil {
+		fork = "altair"
+		data, err = state.Altair.MarshalSSZ()
+	} else if state.Bellatrix != nil {
+		fork = "bellatrix"
+		data, err = state.Bellatrix.MarshalSSZ()
+	} else if state.Capella != nil {
+		fork = "capella"
+		data, err = state.Capella.MarshalSSZ()
+	} else if state.Deneb != nil {
+		fork = "deneb"
+		data, err = state.Deneb.MarshalSSZ()
+	} else if state.Electra != nil {
+		fork = "electra"
+		data, err = state.Electra.MarshalSSZ()
+	} else if state.Fulu != nil {
+		fork = "fulu"
+		data, err = state.Fulu.MarshalSSZ()
+	} else {
+		return errors.New("beacon state was empty")
+	}
+	if err != nil {
+		return errors.Wrap(err, "could not marshal beacon state")
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated state
+	path := c.statePath(epoch, fork)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (c *StateCache) readFromDisk(epoch uint64) (*spec.VersionedBeaconState, error) {
+	for _, fork := range stateCacheForks {
+		data, err := os.ReadFile(c.statePath(epoch, fork))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return unmarshalBeaconState(fork, data)
+	}
+	return nil, errors.Wrap(os.ErrNotExist, fmt.Sprintf("no cached state for epoch %d", epoch))
+}
+
+// Decodes a beacon state of the given fork, as named by stateCacheForks and the
+// Eth-Consensus-Version header of the beacon api
+func unmarshalBeaconState(fork string, data []byte) (*spec.VersionedBeaconState, error) {
+	state := &spec.VersionedBeaconState{}
+	var err error
+	switch fork {
+	case "altair":
+		state.Version = spec.DataVersionAltair
+		state.Altair = &altair.BeaconState{}
+		err = state.Altair.UnmarshalSSZ(data)
+	case "bellatrix":
+		state.Version = spec.DataVersionBellatrix
+		state.Bellatrix = &bellatrix.BeaconState{}
+		err = state.Bellatrix.UnmarshalSSZ(data)
+	case "capella":
+		state.Version = spec.DataVersionCapella
+		state.Capella = &capella.BeaconState{}
+		err = state.Capella.UnmarshalSSZ(data)
+	case "deneb":
+		state.Version = spec.DataVersionDeneb
+		state.Deneb = &deneb.BeaconState{}
+		err = state.Deneb.UnmarshalSSZ(data)
+	case "electra":
+		state.Version = spec.DataVersionElectra
+		state.Electra = &electra.BeaconState{}
+		err = state.Electra.UnmarshalSSZ(data)
+	case "fulu":
+		state.Version = spec.DataVersionFulu
+		state.Fulu = &fulu.BeaconState{}
+		err = state.Fulu.UnmarshalSSZ(data)
+	default:
+		return nil, errors.New("fork not supported: " + fork)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal beacon state")
+	}
+	return state, nil
+}
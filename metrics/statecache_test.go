@@ -0,0 +1,180 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/altair"
+	"github.com/attestantio/go-eth2-client/spec/deneb"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/holiman/uint256"
+	"github.com/prysmaticlabs/go-bitfield"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_StateCache_Evicts(t *testing.T) {
+	cache, err := NewStateCache(2, "", 0, false)
+	require.NoError(t, err)
+
+	state10 := &spec.VersionedBeaconState{Version: spec.DataVersionDeneb}
+	state11 := &spec.VersionedBeaconState{Version: spec.DataVersionDeneb}
+	state12 := &spec.VersionedBeaconState{Version: spec.DataVersionDeneb}
+
+	cache.Put(10, state10)
+	cache.Put(11, state11)
+
+	// Touch 10 so that 11 is the least recently used
+	got, ok := cache.Get(10)
+	require.True(t, ok)
+	require.Same(t, state10, got)
+
+	cache.Put(12, state12)
+
+	_, ok = cache.Get(11)
+	require.False(t, ok)
+
+	got, ok = cache.Get(10)
+	require.True(t, ok)
+	require.Same(t, state10, got)
+
+	got, ok = cache.Get(12)
+	require.True(t, ok)
+	require.Same(t, state12, got)
+}
+
+func Test_StateCache_Disabled(t *testing.T) {
+	cache, err := NewStateCache(0, "", 0, false)
+	require.NoError(t, err)
+
+	cache.Put(10, &spec.VersionedBeaconState{Version: spec.DataVersionDeneb})
+	_, ok := cache.Get(10)
+	require.False(t, ok)
+}
+
+func Test_StateCache_Disk(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewStateCache(0, dir, 0, false)
+	require.NoError(t, err)
+	cache.SetFinalizedEpoch(11)
+
+	state := &spec.VersionedBeaconState{
+		Version: spec.DataVersionDeneb,
+		Deneb: &deneb.BeaconState{
+			Slot:     320,
+			Balances: []phase0.Gwei{32000000000, 31000000000},
+		},
+	}
+	// Fill the fixed size fields so the state can be marshalled
+	fillDenebState(state.Deneb)
+	cache.Put(10, state)
+
+	_, ok := cache.Get(11)
+	require.False(t, ok)
+
+	// A new cache on the same dir reads the state back from disk
+	cache, err = NewStateCache(2, dir, 0, false)
+	require.NoError(t, err)
+	got, ok := cache.Get(10)
+	require.True(t, ok)
+	require.Equal(t, spec.DataVersionDeneb, got.Version)
+	require.Equal(t, phase0.Slot(320), got.Deneb.Slot)
+	require.Equal(t, state.Deneb.Balances, got.Deneb.Balances)
+}
+
+func Test_StateCache_DiskFinalized(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewStateCache(0, dir, 3, false)
+	require.NoError(t, err)
+	put := func(epoch uint64) {
+		state := &deneb.BeaconState{Slot: phase0.Slot(epoch * 32)}
+		fillDenebState(state)
+		cache.Put(epoch, &spec.VersionedBeaconState{Version: spec.DataVersionDeneb, Deneb: state})
+	}
+	onDisk := func() []uint64 {
+		epochs := make([]uint64, 0)
+		reader, err := NewStateCache(0, dir, 3, false)
+		require.NoError(t, err)
+		for epoch := uint64(0); epoch < 20; epoch++ {
+			if _, ok := reader.Get(epoch); ok {
+				epochs = append(epochs, epoch)
+			}
+		}
+		return epochs
+	}
+
+	// Nothing is written until the finalized epoch is known
+	put(10)
+	require.Empty(t, onDisk())
+
+	// Only the finalized epochs are written
+	cache.SetFinalizedEpoch(12)
+	put(10)
+	put(11)
+	put(12)
+	require.Equal(t, []uint64{10, 11}, onDisk())
+
+	// And the ones more than 3 epochs before the newest are removed
+	cache.SetFinalizedEpoch(20)
+	put(13)
+	require.Equal(t, []uint64{11, 13}, onDisk())
+	put(15)
+	require.Equal(t, []uint64{13, 15}, onDisk())
+}
+
+func fillDenebState(state *deneb.BeaconState) {
+	state.Fork = &phase0.Fork{}
+	state.LatestBlockHeader = &phase0.BeaconBlockHeader{}
+	state.BlockRoots = make([]phase0.Root, 8192)
+	state.StateRoots = make([]phase0.Root, 8192)
+	state.ETH1Data = &phase0.ETH1Data{BlockHash: make([]byte, 32)}
+	state.RANDAOMixes = make([]phase0.Root, 65536)
+	state.Slashings = make([]phase0.Gwei, 8192)
+	state.JustificationBits = bitfield.NewBitvector4()
+	state.PreviousJustifiedCheckpoint = &phase0.Checkpoint{}
+	state.CurrentJustifiedCheckpoint = &phase0.Checkpoint{}
+	state.FinalizedCheckpoint = &phase0.Checkpoint{}
+	state.CurrentSyncCommittee = &altair.SyncCommittee{Pubkeys: make([]phase0.BLSPubKey, 512)}
+	state.NextSyncCommittee = &altair.SyncCommittee{Pubkeys: make([]phase0.BLSPubKey, 512)}
+	state.LatestExecutionPayloadHeader = &deneb.ExecutionPayloadHeader{BaseFeePerGas: uint256.NewInt(0)}
+}
+
+func Test_StateCache_Compact(t *testing.T) {
+	cache, err := NewStateCache(2, "", 0, true)
+	require.NoError(t, err)
+
+	newState := func(effectiveBalance phase0.Gwei) *spec.VersionedBeaconState {
+		state := &spec.VersionedBeaconState{
+			Version: spec.DataVersionDeneb,
+			Deneb: &deneb.BeaconState{
+				Slot: 320,
+				Validators: []*phase0.Validator{
+					{EffectiveBalance: 32000000000},
+					{EffectiveBalance: effectiveBalance},
+				},
+				Balances:                   []phase0.Gwei{32000000000, 31000000000},
+				PreviousEpochParticipation: []altair.ParticipationFlags{7, 0},
+			},
+		}
+		fillDenebState(state.Deneb)
+		return state
+	}
+
+	state10 := newState(32000000000)
+	got := cache.Put(10, state10)
+	require.Equal(t, phase0.Slot(320), got.Deneb.Slot)
+	require.Equal(t, state10.Deneb.Balances, got.Deneb.Balances)
+	require.Equal(t, state10.Deneb.PreviousEpochParticipation, got.Deneb.PreviousEpochParticipation)
+	require.Same(t, state10.Deneb.LatestExecutionPayloadHeader, got.Deneb.LatestExecutionPayloadHeader)
+	require.Nil(t, got.Deneb.RANDAOMixes)
+	require.Nil(t, got.Deneb.NextSyncCommittee)
+
+	cached, ok := cache.Get(10)
+	require.True(t, ok)
+	require.Same(t, got, cached)
+
+	// Unchanged validators are shared with the previous state
+	got11 := cache.Put(11, newState(31000000000))
+	require.Same(t, got.Deneb.Validators[0], got11.Deneb.Validators[0])
+	require.NotSame(t, got.Deneb.Validators[1], got11.Deneb.Validators[1])
+	require.Equal(t, phase0.Gwei(31000000000), got11.Deneb.Validators[1].EffectiveBalance)
+}
@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/avast/retry-go/v4"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Downloads beacon states as ssz from the debug endpoint of the beacon node.
+// Mainnet states take hundreds of MB, so each attempt is given the state
+// timeout instead of the timeout of the other requests, and a download that
+// fails halfway is resumed from where it stopped if the node supports ranges.
+type StateDownloader struct {
+	httpClient *http.Client
+	address    string
+	headers    map[string]string
+	timeout    time.Duration
+	retryOpts  []retry.Option
+}
+
+func NewStateDownloader(
+	address string,
+	headers map[string]string,
+	timeout time.Duration,
+	attempts uint) *StateDownloader {
+	return &StateDownloader{
+		// No client timeout, each attempt is limited by its context
+		httpClient: &http.Client{Transport: NewBeaconRequestMetrics(http.DefaultTransport)},
+		address:    strings.TrimSuffix(address, "/"),
+		headers:    headers,
+		timeout:    timeout,
+		retryOpts: []retry.Option{
+			retry.Attempts(attempts),
+			retry.Delay(5 * time.Second),
+			retry.LastErrorOnly(true),
+		},
+	}
+}
+
+// Partially downloaded state, kept between attempts
+type stateDownload struct {
+	body bytes.Buffer
+	// Fork of the state and its total size, as sent in the first response
+	fork string
+	size int64
+	// Tells if the node accepts range requests, so that the download is resumed
+	resumable bool
+}
+
+// Downloads the beacon state at the given state id, e.g. a slot. Canceling the
+// context stops the download and the retries.
+func (d *StateDownloader) Download(ctx context.Context, stateId string) (*spec.VersionedBeaconState, error) {
+	download := &stateDownload{size: -1}
+	err := retry.Do(func() error {
+		err := d.downloadAttempt(ctx, stateId, download)
+		if err != nil {
+			log.WithField("State", stateId).Warnf("error downloading beacon state after %d bytes: %s. Retrying...",
+				download.body.Len(), err)
+		}
+		return err
+	}, append(d.retryOpts, retry.Context(ctx))...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error downloading beacon state "+stateId)
+	}
+	return unmarshalBeaconState(download.fork, download.body.Bytes())
+}
+
+func (d *StateDownloader) downloadAttempt(ctx context.Context, stateId string, download *stateDownload) error {
+	ctxTimeout, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctxTimeout, http.MethodGet,
+		fmt.Sprintf("%s/eth/v2/debug/beacon/states/%s", d.address, stateId), nil)
+	if err != nil {
+		return retry.Unrecoverable(errors.Wrap(err, "error creating request"))
+	}
+	for key, value := range d.headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Accept", "application/octet-stream")
+	offset := int64(download.body.Len())
+	if download.resumable && offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// Whole state, also if the range was ignored
+		download.body.Reset()
+		download.fork = resp.Header.Get("Eth-Consensus-Version")
+		download.size = resp.ContentLength
+		download.resumable = resp.Header.Get("Accept-Ranges") == "bytes" && resp.ContentLength > 0
+	case http.StatusPartialContent:
+		if !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+			download.body.Reset()
+			download.resumable = false
+			return errors.New("unexpected content range: " + resp.Header.Get("Content-Range"))
+		}
+	case http.StatusNotFound, http.StatusBadRequest:
+		return retry.Unrecoverable(errors.New(fmt.Sprintf("non-200 status: %d", resp.StatusCode)))
+	default:
+		return errors.New(fmt.Sprintf("non-200 status: %d", resp.StatusCode))
+	}
+	if resp.Header.Get("Content-Type") != "" && !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/octet-stream") {
+		return retry.Unrecoverable(errors.New("beacon node did not return ssz: " + resp.Header.Get("Content-Type")))
+	}
+	if download.fork == "" {
+		return retry.Unrecoverable(errors.New("missing Eth-Consensus-Version header"))
+	}
+
+	if _, err := io.Copy(&download.body, resp.Body); err != nil {
+		return errors.Wrap(err, "error reading beacon state")
+	}
+	if download.size >= 0 && int64(download.body.Len()) != download.size {
+		return errors.New(fmt.Sprintf("got %d bytes of %d", download.body.Len(), download.size))
+	}
+	return nil
+}
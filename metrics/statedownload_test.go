@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/deneb"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/avast/retry-go/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_StateDownloader_Resumes(t *testing.T) {
+	state := &deneb.BeaconState{
+		Slot:     319,
+		Balances: []phase0.Gwei{32000000000, 31000000000},
+	}
+	fillDenebState(state)
+	data, err := state.MarshalSSZ()
+	require.NoError(t, err)
+
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/eth/v2/debug/beacon/states/319", r.URL.Path)
+		require.Equal(t, "application/octet-stream", r.Header.Get("Accept"))
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Eth-Consensus-Version", "deneb")
+		w.Header().Set("Accept-Ranges", "bytes")
+
+		if r.Header.Get("Range") == "" {
+			// The connection is dropped after sending half of the state
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.WriteHeader(http.StatusOK)
+			w.Write(data[:len(data)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", len(data)/2, len(data)-1, len(data)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(data[len(data)/2:])
+	}))
+	defer server.Close()
+
+	downloader := NewStateDownloader(server.URL, map[string]string{}, 10*time.Second, 2)
+	downloader.retryOpts = []retry.Option{retry.Attempts(2), retry.Delay(0), retry.LastErrorOnly(true)}
+
+	got, err := downloader.Download(context.Background(), "319")
+	require.NoError(t, err)
+	require.Equal(t, []string{"", fmt.Sprintf("bytes=%d-", len(data)/2)}, ranges)
+	require.Equal(t, phase0.Slot(319), got.Deneb.Slot)
+	require.Equal(t, state.Balances, got.Deneb.Balances)
+}
+
+func Test_StateDownloader_NotFound(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	downloader := NewStateDownloader(server.URL, map[string]string{}, 10*time.Second, 3)
+	downloader.retryOpts = []retry.Option{retry.Attempts(3), retry.Delay(0), retry.LastErrorOnly(true)}
+
+	_, err := downloader.Download(context.Background(), "319")
+	require.Error(t, err)
+	require.Equal(t, 1, requests)
+}
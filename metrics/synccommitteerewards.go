@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"slices"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/api"
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/http"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bilinearlabs/eth-metrics/config"
+)
+
+type SyncCommitteeRewards struct {
+	consensus *http.Service
+	config    *config.Config
+}
+
+func NewSyncCommitteeRewards(
+	consensus *http.Service,
+	config *config.Config,
+) (*SyncCommitteeRewards, error) {
+	return &SyncCommitteeRewards{
+		consensus: consensus,
+		config:    config,
+	}, nil
+}
+
+// Returns the sync committee rewards of the given validators summed over the
+// given blocks, in gwei. Members that did not sign a block get a negative one.
+func (r *SyncCommitteeRewards) GetSyncCommitteeRewards(
+	epoch uint64,
+	blocks []*v1.BeaconBlockHeader,
+	validatorIndexes []uint64) (map[uint64]*big.Int, error) {
+
+	rewards := make(map[uint64]*big.Int)
+	if len(validatorIndexes) == 0 {
+		return rewards, nil
+	}
+	log.WithField("Epoch", epoch).Info("Fetching sync committee rewards of ", len(validatorIndexes), " validators")
+
+	indexes := make([]phase0.ValidatorIndex, len(validatorIndexes))
+	for i, valIdx := range validatorIndexes {
+		indexes[i] = phase0.ValidatorIndex(valIdx)
+	}
+
+	for _, block := range blocks {
+		if block == nil {
+			continue
+		}
+		ctxTimeout, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(r.config.StateTimeout))
+		blockRewards, err := r.consensus.SyncCommitteeRewards(ctxTimeout, &api.SyncCommitteeRewardsOpts{
+			Block:   fmt.Sprintf("%#x", block.Root),
+			Indices: indexes,
+			Common: api.CommonOpts{
+				Timeout: time.Second * time.Duration(r.config.StateTimeout),
+			},
+		})
+		cancel()
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("error getting sync committee rewards of block %#x", block.Root))
+		}
+		for _, reward := range blockRewards.Data {
+			valIdx := uint64(reward.ValidatorIndex)
+			if _, ok := rewards[valIdx]; !ok {
+				rewards[valIdx] = big.NewInt(0)
+			}
+			rewards[valIdx].Add(rewards[valIdx], big.NewInt(reward.Reward))
+		}
+	}
+	return rewards, nil
+}
+
+// Returns the given validators that are in the current sync committee, once
+// even if they have more than one seat
+func GetSyncCommitteeMembers(
+	beaconState *spec.VersionedBeaconState,
+	valKeyToIndex map[string]uint64,
+	validatorIndexes map[uint64]bool) []uint64 {
+
+	members := make([]uint64, 0)
+	for _, key := range GetCurrentSyncCommittee(beaconState) {
+		valIdx, ok := valKeyToIndex[hex.EncodeToString(key[:])]
+		if ok && validatorIndexes[valIdx] && !slices.Contains(members, valIdx) {
+			members = append(members, valIdx)
+		}
+	}
+	return members
+}
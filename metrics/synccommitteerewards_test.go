@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/altair"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GetSyncCommitteeMembers(t *testing.T) {
+	key := func(b byte) phase0.BLSPubKey {
+		var k phase0.BLSPubKey
+		k[0] = b
+		return k
+	}
+	beaconState := &spec.VersionedBeaconState{
+		Altair: &altair.BeaconState{
+			CurrentSyncCommittee: &altair.SyncCommittee{
+				// Validator 1 has two seats
+				Pubkeys: []phase0.BLSPubKey{key(1), key(2), key(1), key(3), key(9)},
+			},
+		},
+	}
+	valKeyToIndex := map[string]uint64{
+		"01" + strings.Repeat("00", 47): 1,
+		"02" + strings.Repeat("00", 47): 2,
+		"03" + strings.Repeat("00", 47): 3,
+	}
+
+	// Validator 2 is not tracked and the key 9 is unknown
+	members := GetSyncCommitteeMembers(beaconState, valKeyToIndex, map[uint64]bool{1: true, 3: true, 4: true})
+	require.Equal(t, []uint64{1, 3}, members)
+}
+
+func Test_GetPoolRewards(t *testing.T) {
+	rewards := map[uint64]*big.Int{
+		1: big.NewInt(500),
+		2: big.NewInt(-200),
+		// Not part of the pool
+		3: big.NewInt(500),
+	}
+	require.Equal(t, int64(300), GetPoolRewards([]uint64{1, 2, 4}, rewards))
+	require.Equal(t, int64(0), GetPoolRewards([]uint64{1}, map[uint64]*big.Int{}))
+}
+
+func Test_GetValidatorBalanceDelta_SyncCommittee(t *testing.T) {
+	prevValidators := []*phase0.Validator{{EffectiveBalance: 32000000000}}
+	delta := GetValidatorBalanceDelta(
+		0,
+		[]uint64{32000000000},
+		[]uint64{32000000450},
+		prevValidators,
+		map[uint64]*big.Int{},
+		nil,
+		map[uint64]*big.Int{},
+		map[uint64]*big.Int{0: big.NewInt(400)})
+	require.Equal(t, int64(50), delta.Int64())
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/bilinearlabs/eth-metrics/publish"
+	"github.com/pkg/errors"
+)
+
+// Sets the tenant of each pool from the tenants of the config, and stores it
+// so that the tables can be filtered by tenant
+func (a *Metrics) loadTenants() error {
+	a.poolTenants = make(map[string]string)
+	for _, tenant := range a.config.Tenants {
+		for _, poolName := range tenant.Pools {
+			a.poolTenants[poolName] = tenant.Id
+		}
+	}
+	if a.db == nil {
+		return nil
+	}
+	return a.db.StoreTenantPools(a.poolTenants)
+}
+
+// Creates the sinks of each tenant, that only get the metrics of its pools
+func (a *Metrics) setupTenantSinks() error {
+	a.tenantSinks = make(map[string]*publish.Sinks)
+	cfg := *a.outputConfig()
+	for _, tenant := range cfg.Tenants {
+		cfg.Sinks = tenant.Sinks
+		cfg.RemoteWriteUrl = ""
+		sinks, err := publish.NewSinks(&cfg)
+		if err != nil {
+			return errors.Wrap(err, "invalid sinks of tenant "+tenant.Id)
+		}
+		if sinks.Enabled() {
+			a.tenantSinks[tenant.Id] = sinks
+		}
+	}
+	return nil
+}
+
+// Returns the tenant of the pool, empty if it is of the operator
+func (a *Metrics) PoolTenant(poolName string) string {
+	return a.poolTenants[poolName]
+}
+
+// Returns the events of the pools of each tenant
+func GetTenantEvents(events []publish.Event, poolTenants map[string]string) map[string][]publish.Event {
+	tenantEvents := make(map[string][]publish.Event)
+	for _, event := range events {
+		if tenant, ok := poolTenants[event.PoolName]; ok {
+			tenantEvents[tenant] = append(tenantEvents[tenant], event)
+		}
+	}
+	return tenantEvents
+}
+
+// Writes the events of the pools of each tenant to its sinks
+func (a *Metrics) writeTenantSinks(epochTime time.Time, events []publish.Event) {
+	for tenant, tenantEvents := range GetTenantEvents(events, a.poolTenants) {
+		if sinks, ok := a.tenantSinks[tenant]; ok {
+			sinks.Write(epochTime, tenantEvents)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/bilinearlabs/eth-metrics/publish"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GetTenantEvents(t *testing.T) {
+	events := []publish.Event{
+		{Epoch: 10, PoolName: "pool_a"},
+		{Epoch: 10, PoolName: "pool_b"},
+		{Epoch: 10, PoolName: "pool_c"},
+		{Epoch: 10, PoolName: "pool_d"},
+	}
+	poolTenants := map[string]string{"pool_a": "tenant_a", "pool_b": "tenant_b", "pool_c": "tenant_a"}
+
+	// The pools of the operator are not written to the sinks of any tenant
+	require.Equal(t, map[string][]publish.Event{
+		"tenant_a": {{Epoch: 10, PoolName: "pool_a"}, {Epoch: 10, PoolName: "pool_c"}},
+		"tenant_b": {{Epoch: 10, PoolName: "pool_b"}},
+	}, GetTenantEvents(events, poolTenants))
+
+	require.Empty(t, GetTenantEvents(events, nil))
+}
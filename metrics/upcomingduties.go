@@ -0,0 +1,212 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/api"
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bilinearlabs/eth-metrics/alerts"
+	"github.com/bilinearlabs/eth-metrics/epochtime"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const timeFormat = "2006-01-02 15:04:05 MST"
+
+func (a *Metrics) setForecastIndexes(validatorIndexToPool map[uint64]string) {
+	a.upcomingMu.Lock()
+	defer a.upcomingMu.Unlock()
+	a.forecastIndexes = validatorIndexToPool
+}
+
+// Returns the proposals and sync committee memberships of the tracked
+// validators that did not end yet, optionally only of a pool
+func (a *Metrics) UpcomingDuties(poolName string) []schemas.UpcomingDuty {
+	currentSlot := a.networkParameters.Clock().CurrentSlot()
+	a.upcomingMu.Lock()
+	defer a.upcomingMu.Unlock()
+	duties := make([]schemas.UpcomingDuty, 0)
+	for _, duty := range a.upcomingDuties {
+		if duty.LastSlot >= currentSlot && (poolName == "" || duty.PoolName == poolName) {
+			duties = append(duties, duty)
+		}
+	}
+	return duties
+}
+
+// Fetches the duties of the tracked validators in the current and next epochs
+// at the start of every epoch, alerting the new ones if enabled. The validators
+// are known once an epoch is processed.
+func (a *Metrics) ForecastDuties() {
+	clock := a.networkParameters.Clock()
+	slotDuration := time.Duration(a.networkParameters.secondsPerSlot) * time.Second
+	for {
+		epoch := clock.CurrentEpoch()
+		forecast, err := a.forecastDuties(epoch)
+		if err != nil {
+			log.Error("could not forecast duties: ", err)
+		}
+		if !forecast {
+			time.Sleep(slotDuration)
+			continue
+		}
+		time.Sleep(time.Until(clock.EpochStart(epoch + 1)))
+	}
+}
+
+// Returns false if the duties are not known yet, or if they failed to be fetched
+func (a *Metrics) forecastDuties(epoch uint64) (bool, error) {
+	a.upcomingMu.Lock()
+	indexToPool := a.forecastIndexes
+	a.upcomingMu.Unlock()
+	if len(indexToPool) == 0 {
+		return false, nil
+	}
+	indexes := make([]phase0.ValidatorIndex, 0, len(indexToPool))
+	for valIdx := range indexToPool {
+		indexes = append(indexes, phase0.ValidatorIndex(valIdx))
+	}
+
+	clock := a.networkParameters.Clock()
+	duties := make([]schemas.UpcomingDuty, 0)
+	for _, e := range []uint64{epoch, epoch + 1} {
+		// Of all validators, the tracked ones are filtered below
+		proposerDuties, err := a.httpClient.ProposerDuties(context.Background(), &api.ProposerDutiesOpts{
+			Epoch: phase0.Epoch(e),
+		})
+		if err != nil {
+			return false, errors.Wrap(err, fmt.Sprintf("could not get proposer duties of epoch %d", e))
+		}
+		duties = append(duties, GetUpcomingProposals(proposerDuties.Data, indexToPool, clock)...)
+	}
+
+	epochsPerPeriod := a.networkParameters.epochsPerSyncPeriod
+	period := epoch / epochsPerPeriod
+	for _, p := range []uint64{period, period + 1} {
+		firstEpoch := max(p*epochsPerPeriod, epoch)
+		syncDuties, err := a.httpClient.SyncCommitteeDuties(context.Background(), &api.SyncCommitteeDutiesOpts{
+			Epoch:   phase0.Epoch(firstEpoch),
+			Indices: indexes,
+		})
+		if err != nil {
+			return false, errors.Wrap(err, fmt.Sprintf("could not get sync committee duties of epoch %d", firstEpoch))
+		}
+		duties = append(duties, GetUpcomingSyncCommittees(
+			syncDuties.Data,
+			indexToPool,
+			clock.FirstSlot(p*epochsPerPeriod),
+			clock.LastSlot((p+1)*epochsPerPeriod-1),
+			clock)...)
+	}
+
+	a.upcomingMu.Lock()
+	a.upcomingDuties = duties
+	if a.alertedDuties == nil {
+		a.alertedDuties = make(map[string]uint64)
+	}
+	dutyAlerts := GetUpcomingDutyAlerts(duties, a.alertedDuties, clock.CurrentSlot())
+	a.upcomingMu.Unlock()
+
+	log.WithField("Epoch", epoch).Info("Upcoming duties of the tracked validators: ", len(duties))
+	if a.config.AlertUpcomingDuties {
+		for _, alert := range dutyAlerts {
+			a.sendAlert(alert)
+		}
+	}
+	return true, nil
+}
+
+// Returns the proposals of the tracked validators
+func GetUpcomingProposals(
+	proposerDuties []*v1.ProposerDuty,
+	indexToPool map[uint64]string,
+	clock *epochtime.Clock) []schemas.UpcomingDuty {
+
+	duties := make([]schemas.UpcomingDuty, 0)
+	for _, duty := range proposerDuties {
+		poolName, ok := indexToPool[uint64(duty.ValidatorIndex)]
+		if !ok {
+			continue
+		}
+		duties = append(duties, schemas.UpcomingDuty{
+			Kind:     schemas.UpcomingProposal,
+			PoolName: poolName,
+			ValIndex: uint64(duty.ValidatorIndex),
+			Slot:     uint64(duty.Slot),
+			LastSlot: uint64(duty.Slot),
+			Time:     clock.SlotStart(uint64(duty.Slot)),
+		})
+	}
+	return duties
+}
+
+// Returns the sync committee memberships of the tracked validators in the
+// period between the given slots
+func GetUpcomingSyncCommittees(
+	syncDuties []*v1.SyncCommitteeDuty,
+	indexToPool map[uint64]string,
+	firstSlot uint64,
+	lastSlot uint64,
+	clock *epochtime.Clock) []schemas.UpcomingDuty {
+
+	duties := make([]schemas.UpcomingDuty, 0)
+	for _, duty := range syncDuties {
+		poolName, ok := indexToPool[uint64(duty.ValidatorIndex)]
+		if !ok {
+			continue
+		}
+		duties = append(duties, schemas.UpcomingDuty{
+			Kind:     schemas.UpcomingSyncCommittee,
+			PoolName: poolName,
+			ValIndex: uint64(duty.ValidatorIndex),
+			Slot:     firstSlot,
+			LastSlot: lastSlot,
+			Time:     clock.SlotStart(firstSlot),
+		})
+	}
+	return duties
+}
+
+// Returns an alert per duty that was not alerted yet, and records them in
+// alerted. Duties that ended before the current slot are forgotten.
+func GetUpcomingDutyAlerts(
+	duties []schemas.UpcomingDuty,
+	alerted map[string]uint64,
+	currentSlot uint64) []alerts.Alert {
+
+	for key, lastSlot := range alerted {
+		if lastSlot < currentSlot {
+			delete(alerted, key)
+		}
+	}
+
+	dutyAlerts := make([]alerts.Alert, 0)
+	for _, duty := range duties {
+		if duty.LastSlot < currentSlot {
+			continue
+		}
+		key := fmt.Sprintf("%s/%d/%d", duty.Kind, duty.ValIndex, duty.Slot)
+		if _, ok := alerted[key]; ok {
+			continue
+		}
+		alerted[key] = duty.LastSlot
+
+		alert := alerts.Alert{PoolName: duty.PoolName}
+		switch duty.Kind {
+		case schemas.UpcomingProposal:
+			alert.Kind = alerts.UpcomingProposal
+			alert.Message = fmt.Sprintf("validator %d proposes the block of slot %d at %s",
+				duty.ValIndex, duty.Slot, duty.Time.Format(timeFormat))
+		case schemas.UpcomingSyncCommittee:
+			alert.Kind = alerts.UpcomingSyncCommittee
+			alert.Message = fmt.Sprintf("validator %d is in the sync committee from slot %d, at %s, to slot %d",
+				duty.ValIndex, duty.Slot, duty.Time.Format(timeFormat), duty.LastSlot)
+		}
+		dutyAlerts = append(dutyAlerts, alert)
+	}
+	return dutyAlerts
+}
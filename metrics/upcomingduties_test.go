@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bilinearlabs/eth-metrics/alerts"
+	"github.com/bilinearlabs/eth-metrics/epochtime"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GetUpcomingProposals(t *testing.T) {
+	clock := epochtime.New(time.Unix(1606824023, 0), 12*time.Second, 32)
+	indexToPool := map[uint64]string{10: "pool1", 20: "pool2"}
+
+	duties := GetUpcomingProposals([]*v1.ProposerDuty{
+		{ValidatorIndex: 10, Slot: 3200},
+		{ValidatorIndex: 15, Slot: 3201},
+		{ValidatorIndex: 20, Slot: 3202},
+	}, indexToPool, clock)
+
+	require.Equal(t, []schemas.UpcomingDuty{
+		{Kind: schemas.UpcomingProposal, PoolName: "pool1", ValIndex: 10, Slot: 3200, LastSlot: 3200, Time: clock.SlotStart(3200)},
+		{Kind: schemas.UpcomingProposal, PoolName: "pool2", ValIndex: 20, Slot: 3202, LastSlot: 3202, Time: clock.SlotStart(3202)},
+	}, duties)
+	require.Equal(t, time.Unix(1606824023+3202*12, 0).UTC(), duties[1].Time)
+}
+
+func Test_GetUpcomingSyncCommittees(t *testing.T) {
+	clock := epochtime.New(time.Unix(1606824023, 0), 12*time.Second, 32)
+	indexToPool := map[uint64]string{10: "pool1"}
+
+	duties := GetUpcomingSyncCommittees([]*v1.SyncCommitteeDuty{
+		{ValidatorIndex: 10, ValidatorSyncCommitteeIndices: []phase0.CommitteeIndex{1}},
+		{ValidatorIndex: 11},
+	}, indexToPool, 8192, 16383, clock)
+
+	require.Equal(t, []schemas.UpcomingDuty{
+		{Kind: schemas.UpcomingSyncCommittee, PoolName: "pool1", ValIndex: 10, Slot: 8192, LastSlot: 16383, Time: clock.SlotStart(8192)},
+	}, duties)
+}
+
+func Test_GetUpcomingDutyAlerts(t *testing.T) {
+	clock := epochtime.New(time.Unix(0, 0), 12*time.Second, 32)
+	duties := []schemas.UpcomingDuty{
+		{Kind: schemas.UpcomingProposal, PoolName: "pool1", ValIndex: 10, Slot: 100, LastSlot: 100, Time: clock.SlotStart(100)},
+		{Kind: schemas.UpcomingSyncCommittee, PoolName: "pool2", ValIndex: 20, Slot: 64, LastSlot: 127, Time: clock.SlotStart(64)},
+		// Already done
+		{Kind: schemas.UpcomingProposal, PoolName: "pool1", ValIndex: 11, Slot: 80, LastSlot: 80, Time: clock.SlotStart(80)},
+	}
+	alerted := make(map[string]uint64)
+
+	dutyAlerts := GetUpcomingDutyAlerts(duties, alerted, 90)
+	require.Equal(t, []alerts.Alert{
+		{Kind: alerts.UpcomingProposal, PoolName: "pool1", Message: "validator 10 proposes the block of slot 100 at 1970-01-01 00:20:00 UTC"},
+		{Kind: alerts.UpcomingSyncCommittee, PoolName: "pool2", Message: "validator 20 is in the sync committee from slot 64, at 1970-01-01 00:12:48 UTC, to slot 127"},
+	}, dutyAlerts)
+
+	// Each duty is alerted once
+	require.Empty(t, GetUpcomingDutyAlerts(duties, alerted, 95))
+
+	// Ended duties are forgotten
+	GetUpcomingDutyAlerts(nil, alerted, 101)
+	require.Equal(t, map[string]uint64{"sync_committee/20/64": 127}, alerted)
+}
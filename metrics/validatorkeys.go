@@ -0,0 +1,282 @@
+package metrics
+
+import (
+	"os"
+	"slices"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/bilinearlabs/eth-metrics/execution"
+	"github.com/bilinearlabs/eth-metrics/pools"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Reads the validator keys of each pool from the validators file or, if not
+// set, from the files passed as pool names: .txt, deposit data .json or
+// directories with keystores. Validators given by index in the validators file
+// are returned apart, since their keys are taken from the beacon state.
+func LoadValidatorKeys(config *config.Config) (map[string][][]byte, map[string]string, map[string][]uint64, error) {
+	if config.ValidatorsFile != "" {
+		validatorKeysPerPool, validatorKeyToPool, validatorIndexesPerPool, err := pools.ReadValidatorsFile(config.ValidatorsFile, config.DuplicateKeys)
+		if err != nil {
+			return nil, nil, nil, errors.Wrap(err, "error reading validators file")
+		}
+		return validatorKeysPerPool, validatorKeyToPool, validatorIndexesPerPool, nil
+	}
+
+	// TODO check if mantain reading from txt files
+	validatorKeysPerPool := make(map[string][][]byte)
+	validatorKeyToPool := make(map[string]string)
+	for _, poolName := range config.PoolNames {
+		if pools.IsPoolKeysFile(poolName) {
+			pubKeysDeposited, err := pools.ReadPoolKeysFile(poolName)
+			if err != nil {
+				return nil, nil, nil, errors.Wrap(err, "error reading pool file")
+			}
+			validatorKeysPerPool[poolName] = pubKeysDeposited
+			for _, key := range pubKeysDeposited {
+				keyStr := hexutil.Encode(key)
+				validatorKeyToPool[keyStr] = poolName
+			}
+			log.WithField("PoolName", poolName).Info("File contains ", len(pubKeysDeposited), " keys")
+		}
+	}
+	return validatorKeysPerPool, validatorKeyToPool, make(map[string][]uint64), nil
+}
+
+// Validators given by index need the whole validator registry to find their
+// keys, so they can't be used in light mode
+func checkValidatorIndexes(config *config.Config, validatorIndexesPerPool map[string][]uint64) error {
+	if len(validatorIndexesPerPool) > 0 && config.LightMode {
+		return errors.New("validators given by index are not supported in light mode, add their public keys")
+	}
+	return nil
+}
+
+// Reloads the validator keys if any of the files changed since they were last
+// read. The maps are updated in place, since they are shared with the relay
+// rewards, and the keys found by address are kept. Returns if they changed.
+func (a *Metrics) ReloadValidatorKeys() (bool, error) {
+	var validatorKeysPerPool map[string][][]byte
+	var validatorKeyToPool map[string]string
+	var validatorIndexesPerPool map[string][]uint64
+
+	if a.remoteValidatorsFile != nil {
+		var changed bool
+		var err error
+		validatorKeysPerPool, validatorKeyToPool, validatorIndexesPerPool, changed, err = a.remoteValidatorsFile.Fetch()
+		if err != nil {
+			return false, err
+		}
+		if !changed {
+			return false, nil
+		}
+	} else {
+		modTime, err := getLatestModTime(getValidatorKeyFiles(a.config))
+		if err != nil {
+			return false, errors.Wrap(err, "error checking validator key files")
+		}
+		if !modTime.After(a.keyFilesModTime) {
+			return false, nil
+		}
+		validatorKeysPerPool, validatorKeyToPool, validatorIndexesPerPool, err = LoadValidatorKeys(a.config)
+		if err != nil {
+			return false, err
+		}
+		a.keyFilesModTime = modTime
+	}
+	if err := checkValidatorIndexes(a.config, validatorIndexesPerPool); err != nil {
+		return false, err
+	}
+
+	clear(a.validatorKeysPerPool)
+	clear(a.validatorKeyToPool)
+	for poolName, keys := range validatorKeysPerPool {
+		a.validatorKeysPerPool[poolName] = keys
+	}
+	for keyStr, poolName := range validatorKeyToPool {
+		a.validatorKeyToPool[keyStr] = poolName
+	}
+	for keyStr, poolName := range a.keysFoundByAddress {
+		if _, ok := a.validatorKeyToPool[keyStr]; ok {
+			continue
+		}
+		key, err := hexutil.Decode(keyStr)
+		if err != nil {
+			return false, errors.Wrap(err, "error decoding key found by address")
+		}
+		a.validatorKeysPerPool[poolName] = append(a.validatorKeysPerPool[poolName], key)
+		a.validatorKeyToPool[keyStr] = poolName
+	}
+	// Their keys are found again in the next beacon state
+	a.validatorIndexesPerPool = validatorIndexesPerPool
+	// The validators added at runtime stay in the pools they joined
+	a.applyPoolMembership()
+
+	log.Info("Reloaded validator keys, tracking ", len(a.validatorKeyToPool), " keys")
+	return true, nil
+}
+
+// Adds to the pools the validators given by index in the validators file, with
+// their keys taken from the beacon state. Indexes that are not in the state
+// yet are added once the validator is deposited. Like the keys of the file,
+// they are replaced when it is reloaded.
+func (a *Metrics) AddValidatorsByIndex(beaconState *spec.VersionedBeaconState) {
+	if len(a.validatorIndexesPerPool) == 0 {
+		return
+	}
+	validators := GetValidators(beaconState)
+	nOfAdded := 0
+	nOfPending := 0
+	for poolName, indexes := range a.validatorIndexesPerPool {
+		for _, index := range indexes {
+			if index >= uint64(len(validators)) {
+				nOfPending++
+				continue
+			}
+			keyStr := hexutil.Encode(validators[index].PublicKey[:])
+			if _, ok := a.validatorKeyToPool[keyStr]; ok {
+				continue
+			}
+			key := make([]byte, len(validators[index].PublicKey))
+			copy(key, validators[index].PublicKey[:])
+			a.validatorKeysPerPool[poolName] = append(a.validatorKeysPerPool[poolName], key)
+			a.validatorKeyToPool[keyStr] = poolName
+			nOfAdded++
+		}
+	}
+	if nOfAdded > 0 || nOfPending > 0 {
+		log.Info("Found the keys of ", nOfAdded, " validators given by index, ", nOfPending, " indexes are not in the beacon state yet")
+	}
+}
+
+// Creates the sources of validators that are found over time, from contracts
+// on the execution client or from external apis and files. The execution client
+// is nil if none is configured.
+func NewValidatorSources(config *config.Config, executionClient *execution.Client) ([]pools.ValidatorSource, error) {
+	validatorSources := make([]pools.ValidatorSource, 0)
+	if executionClient == nil && (len(config.RocketPoolNodes) > 0 || len(config.LidoOperators) > 0) {
+		return nil, errors.New("rocket pool and lido pools require an execution client, set --eth1address")
+	}
+	if len(config.RocketPoolNodes) > 0 {
+		nodeToPool, err := pools.ParsePoolAddresses(config.RocketPoolNodes)
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing rocket pool nodes")
+		}
+		rocketPool, err := pools.NewRocketPool(executionClient, config.Network, nodeToPool)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating rocket pool source")
+		}
+		validatorSources = append(validatorSources, rocketPool)
+	}
+	if len(config.LidoOperators) > 0 {
+		lidoOperators, err := pools.ParseLidoOperators(config.LidoOperators)
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing lido operators")
+		}
+		lido, err := pools.NewLido(executionClient, config.Network, lidoOperators)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating lido source")
+		}
+		validatorSources = append(validatorSources, lido)
+	}
+	if len(config.SSVOperators) > 0 {
+		operatorToPool, err := pools.ParseSSVOperators(config.SSVOperators)
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing ssv operators")
+		}
+		ssv, err := pools.NewSSV(config.SSVApiUrl, config.Network, operatorToPool)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating ssv source")
+		}
+		validatorSources = append(validatorSources, ssv)
+	}
+	if len(config.ExplorerEntities) > 0 {
+		entityToPool, err := pools.ParseExplorerEntities(config.ExplorerEntities)
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing explorer entities")
+		}
+		explorer, err := pools.NewExplorer(config.ExplorerApiUrl, config.ExplorerApiKey, config.ExplorerRefresh, entityToPool)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating explorer source")
+		}
+		validatorSources = append(validatorSources, explorer)
+	}
+	if len(config.ObolClusterLocks) > 0 {
+		clusterLockToPool, err := pools.ParseObolClusterLocks(config.ObolClusterLocks)
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing obol cluster locks")
+		}
+		obol, err := pools.NewObol(clusterLockToPool)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating obol source")
+		}
+		validatorSources = append(validatorSources, obol)
+	}
+	return validatorSources, nil
+}
+
+// Tracks the validators found by each source since the last call, e.g. new
+// Rocket Pool minipools or Lido deposited keys. Returns if any was added.
+func (a *Metrics) AddSourceValidators() bool {
+	added := false
+	for _, source := range a.validatorSources {
+		poolValidatorKeys, err := source.GetNewValidatorKeys()
+		// Keys read before an error are still added
+		added = a.addPoolValidatorKeys(poolValidatorKeys) || added
+		if err != nil {
+			log.Error("Could not get validators from ", source.Name(), ": ", err)
+		}
+	}
+	return added
+}
+
+func (a *Metrics) addPoolValidatorKeys(poolValidatorKeys map[string][][]byte) bool {
+	added := false
+	for poolName, keys := range poolValidatorKeys {
+		for _, key := range keys {
+			added = a.addValidatorToPool(key, poolName) || added
+		}
+	}
+	return added
+}
+
+// Names of the tracked pools, sorted
+func (a *Metrics) poolNames() []string {
+	poolNames := make([]string, 0, len(a.validatorKeysPerPool))
+	for poolName := range a.validatorKeysPerPool {
+		poolNames = append(poolNames, poolName)
+	}
+	slices.Sort(poolNames)
+	return poolNames
+}
+
+func getValidatorKeyFiles(config *config.Config) []string {
+	if config.ValidatorsFile != "" {
+		return []string{config.ValidatorsFile}
+	}
+	files := make([]string, 0)
+	for _, poolName := range config.PoolNames {
+		if pools.IsPoolKeysFile(poolName) {
+			files = append(files, poolName)
+		}
+	}
+	return files
+}
+
+func getLatestModTime(files []string) (time.Time, error) {
+	latest := time.Time{}
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
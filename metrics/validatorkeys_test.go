@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/electra"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ReloadValidatorKeys(t *testing.T) {
+	key1 := "0x947265fae1dc387b143a913a6a5f6a4b5b5db897661b38de728dad62a4ac3a9a4232116338bb35a85944fe6263faac61"
+	key2 := "0x8253556022b09877b0de3de5a0e4b3c254be36f200dcd6ec2a285ce0758b83aae049a54ec3a73f5fa80d2ad84cfea3cf"
+	keyByAddress := "0xb5dab3cfa45f981542b6f567aa09d602cd931d5017a4327159a12865728aaf58bb36029336249a5a289b7e991b5bbe0e"
+
+	poolFile := filepath.Join(t.TempDir(), "pool_a.txt")
+	require.NoError(t, os.WriteFile(poolFile, []byte(key1+"\n"), 0644))
+
+	cfg := &config.Config{PoolNames: []string{poolFile}}
+	validatorKeysPerPool, validatorKeyToPool, _, err := LoadValidatorKeys(cfg)
+	require.NoError(t, err)
+	modTime, err := getLatestModTime(getValidatorKeyFiles(cfg))
+	require.NoError(t, err)
+
+	m := &Metrics{
+		config:               cfg,
+		validatorKeysPerPool: validatorKeysPerPool,
+		validatorKeyToPool:   validatorKeyToPool,
+		keyFilesModTime:      modTime,
+	}
+	keyByAddressBytes, err := hexutil.Decode(keyByAddress)
+	require.NoError(t, err)
+	m.addValidatorToPool(keyByAddressBytes, "pool_b")
+
+	// Not modified
+	reloaded, err := m.ReloadValidatorKeys()
+	require.NoError(t, err)
+	require.False(t, reloaded)
+
+	// The maps are shared, so they must be updated in place
+	sharedKeyToPool := m.validatorKeyToPool
+
+	require.NoError(t, os.WriteFile(poolFile, []byte(key1+"\n"+key2+"\n"), 0644))
+	require.NoError(t, os.Chtimes(poolFile, time.Now(), modTime.Add(time.Second)))
+
+	reloaded, err = m.ReloadValidatorKeys()
+	require.NoError(t, err)
+	require.True(t, reloaded)
+	require.Equal(t, 2, len(m.validatorKeysPerPool[poolFile]))
+	require.Equal(t, 1, len(m.validatorKeysPerPool["pool_b"]))
+	require.Equal(t, poolFile, sharedKeyToPool[key2])
+	require.Equal(t, "pool_b", sharedKeyToPool[keyByAddress])
+
+	// A broken file keeps the previous keys
+	require.NoError(t, os.WriteFile(poolFile, []byte("0x1234\n"), 0644))
+	require.NoError(t, os.Chtimes(poolFile, time.Now(), modTime.Add(2*time.Second)))
+
+	reloaded, err = m.ReloadValidatorKeys()
+	require.Error(t, err)
+	require.False(t, reloaded)
+	require.Equal(t, 2, len(m.validatorKeysPerPool[poolFile]))
+}
+
+func Test_AddValidatorsByIndex(t *testing.T) {
+	beaconState := &spec.VersionedBeaconState{
+		Electra: &electra.BeaconState{
+			Validators: []*phase0.Validator{
+				{PublicKey: phase0.BLSPubKey{0x00}},
+				{PublicKey: phase0.BLSPubKey{0x01}},
+				{PublicKey: phase0.BLSPubKey{0x02}},
+			},
+		},
+	}
+	trackedKey := phase0.BLSPubKey{0x02}
+	m := &Metrics{
+		validatorKeysPerPool: map[string][][]byte{"pool_a": {}, "pool_b": {trackedKey[:]}},
+		validatorKeyToPool:   map[string]string{hexutil.Encode(trackedKey[:]): "pool_b"},
+		// 2 is already tracked by key and 5 is not deposited yet
+		validatorIndexesPerPool: map[string][]uint64{"pool_a": {0, 1, 2, 5}},
+	}
+
+	m.AddValidatorsByIndex(beaconState)
+	require.Len(t, m.validatorKeysPerPool["pool_a"], 2)
+	require.Len(t, m.validatorKeysPerPool["pool_b"], 1)
+	key1 := phase0.BLSPubKey{0x01}
+	require.Equal(t, "pool_a", m.validatorKeyToPool[hexutil.Encode(key1[:])])
+
+	// Resolving again does not duplicate the keys
+	m.AddValidatorsByIndex(beaconState)
+	require.Len(t, m.validatorKeysPerPool["pool_a"], 2)
+
+	beaconState.Electra.Validators = append(beaconState.Electra.Validators,
+		&phase0.Validator{PublicKey: phase0.BLSPubKey{0x03}},
+		&phase0.Validator{PublicKey: phase0.BLSPubKey{0x04}},
+		&phase0.Validator{PublicKey: phase0.BLSPubKey{0x05}})
+	m.AddValidatorsByIndex(beaconState)
+	require.Len(t, m.validatorKeysPerPool["pool_a"], 3)
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	log "github.com/sirupsen/logrus"
+)
+
+const farFutureEpoch = phase0.Epoch(0xffffffffffffffff)
+
+// Counts the validators of a pool in each stage of their lifecycle, following
+// the statuses of the beacon api. Validators waiting in the deposit queue are
+// not in the beacon state yet, so the activation wait only covers the ones
+// that already have an activation epoch.
+func GetValidatorStatus(
+	epoch uint64,
+	poolName string,
+	validatorIndexes []uint64,
+	validators []*phase0.Validator) schemas.ValidatorStatus {
+
+	status := schemas.ValidatorStatus{
+		Epoch:    epoch,
+		PoolName: poolName,
+	}
+	currentEpoch := phase0.Epoch(epoch)
+
+	for _, valIdx := range validatorIndexes {
+		if valIdx >= uint64(len(validators)) {
+			continue
+		}
+		val := validators[valIdx]
+
+		if val.Slashed {
+			status.NOfSlashed++
+		}
+
+		switch {
+		case val.ActivationEpoch > currentEpoch:
+			status.NOfPending++
+			if val.ActivationEpoch != farFutureEpoch {
+				status.ActivationWaitEpochs = max(status.ActivationWaitEpochs, uint64(val.ActivationEpoch-currentEpoch))
+			}
+		case val.ExitEpoch > currentEpoch:
+			if val.ExitEpoch == farFutureEpoch {
+				status.NOfActive++
+				continue
+			}
+			status.NOfExiting++
+			status.ExitWaitEpochs = max(status.ExitWaitEpochs, uint64(val.ExitEpoch-currentEpoch))
+		case val.WithdrawableEpoch > currentEpoch:
+			status.NOfExited++
+		default:
+			status.NOfWithdrawable++
+		}
+	}
+	return status
+}
+
+func logValidatorStatus(status schemas.ValidatorStatus) {
+	log.WithFields(log.Fields{
+		"PoolName":             status.PoolName,
+		"Epoch":                status.Epoch,
+		"Pending":              status.NOfPending,
+		"Active":               status.NOfActive,
+		"Exiting":              status.NOfExiting,
+		"Exited":               status.NOfExited,
+		"Withdrawable":         status.NOfWithdrawable,
+		"Slashed":              status.NOfSlashed,
+		"ActivationWaitEpochs": status.ActivationWaitEpochs,
+		"ExitWaitEpochs":       status.ExitWaitEpochs,
+	}).Info("Validator status:")
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GetValidatorStatus(t *testing.T) {
+	validators := []*phase0.Validator{
+		// Pending, not yet scheduled
+		{ActivationEpoch: farFutureEpoch, ExitEpoch: farFutureEpoch, WithdrawableEpoch: farFutureEpoch},
+		// Pending, activated in 3 epochs
+		{ActivationEpoch: 103, ExitEpoch: farFutureEpoch, WithdrawableEpoch: farFutureEpoch},
+		// Active
+		{ActivationEpoch: 10, ExitEpoch: farFutureEpoch, WithdrawableEpoch: farFutureEpoch},
+		// Exiting in 5 epochs
+		{ActivationEpoch: 10, ExitEpoch: 105, WithdrawableEpoch: 361},
+		// Slashed and exiting in 2 epochs
+		{ActivationEpoch: 10, ExitEpoch: 102, WithdrawableEpoch: 8294, Slashed: true},
+		// Exited
+		{ActivationEpoch: 10, ExitEpoch: 100, WithdrawableEpoch: 356},
+		// Withdrawable
+		{ActivationEpoch: 10, ExitEpoch: 20, WithdrawableEpoch: 76},
+		// Not part of the pool
+		{ActivationEpoch: 10, ExitEpoch: farFutureEpoch, WithdrawableEpoch: farFutureEpoch},
+	}
+
+	status := GetValidatorStatus(100, "pool", []uint64{0, 1, 2, 3, 4, 5, 6, 100}, validators)
+	require.Equal(t, schemas.ValidatorStatus{
+		Epoch:                100,
+		PoolName:             "pool",
+		NOfPending:           2,
+		NOfActive:            1,
+		NOfExiting:           2,
+		NOfExited:            1,
+		NOfWithdrawable:      1,
+		NOfSlashed:           1,
+		ActivationWaitEpochs: 3,
+		ExitWaitEpochs:       5,
+	}, status)
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"math/big"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+)
+
+// Splits the withdrawals of the epoch into partial ones, i.e. the skimming of
+// the balance above the max effective balance and the withdrawal requests, and
+// full ones, which withdraw the whole balance once the validator is withdrawable
+func ClassifyWithdrawals(
+	epoch uint64,
+	withdrawals map[uint64]*big.Int,
+	validators []*phase0.Validator) (map[uint64]*big.Int, map[uint64]*big.Int) {
+
+	partial := make(map[uint64]*big.Int)
+	full := make(map[uint64]*big.Int)
+	for valIdx, amount := range withdrawals {
+		if valIdx < uint64(len(validators)) && uint64(validators[valIdx].WithdrawableEpoch) <= epoch {
+			full[valIdx] = amount
+		} else {
+			partial[valIdx] = amount
+		}
+	}
+	return partial, full
+}
+
+// Returns the partial and full withdrawals of the given validators in gwei, and
+// how many of them were fully withdrawn
+func GetPoolWithdrawals(
+	validatorIndexes []uint64,
+	partial map[uint64]*big.Int,
+	full map[uint64]*big.Int) (*big.Int, *big.Int, uint64) {
+
+	partialAmount := big.NewInt(0)
+	fullAmount := big.NewInt(0)
+	var nOfFull uint64
+	for _, valIdx := range validatorIndexes {
+		if amount, ok := partial[valIdx]; ok {
+			partialAmount.Add(partialAmount, amount)
+		}
+		if amount, ok := full[valIdx]; ok {
+			fullAmount.Add(fullAmount, amount)
+			nOfFull++
+		}
+	}
+	return partialAmount, fullAmount, nOfFull
+}
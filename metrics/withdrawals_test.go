@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ClassifyWithdrawals(t *testing.T) {
+	validators := []*phase0.Validator{
+		{WithdrawableEpoch: farFutureEpoch},
+		// Withdrawable since this epoch
+		{WithdrawableEpoch: 100},
+		// Exited but not yet withdrawable
+		{WithdrawableEpoch: 110},
+	}
+	withdrawals := map[uint64]*big.Int{
+		0: big.NewInt(20000000),
+		1: big.NewInt(32000000000),
+		2: big.NewInt(10000000),
+	}
+
+	partial, full := ClassifyWithdrawals(100, withdrawals, validators)
+	require.Equal(t, map[uint64]*big.Int{0: big.NewInt(20000000), 2: big.NewInt(10000000)}, partial)
+	require.Equal(t, map[uint64]*big.Int{1: big.NewInt(32000000000)}, full)
+
+	partialAmount, fullAmount, nOfFull := GetPoolWithdrawals([]uint64{0, 1, 3}, partial, full)
+	require.Equal(t, big.NewInt(20000000), partialAmount)
+	require.Equal(t, big.NewInt(32000000000), fullAmount)
+	require.Equal(t, uint64(1), nOfFull)
+}
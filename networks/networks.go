@@ -0,0 +1,137 @@
+package networks
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+)
+
+// Parameters of a network known without a beacon node, used as defaults and
+// to check that the node is on the selected network
+type Network struct {
+	Name                  string
+	GenesisTime           time.Time
+	GenesisValidatorsRoot phase0.Root
+	SlotsPerEpoch         uint64
+	SecondsPerSlot        uint64
+	// Relays queried for the MEV rewards by default, none if no public relay
+	// serves the network
+	Relays []string
+	// Block explorer with the beaconcha.in api
+	ExplorerUrl string
+	// Its token has no price
+	Testnet bool
+}
+
+var (
+	Ethereum = Network{
+		Name:                  "ethereum",
+		GenesisTime:           time.Unix(1606824023, 0),
+		GenesisValidatorsRoot: root("0x4b363db94e286120d76eb905340fdd4e54bfe9f06bf33ff6cf5ad27f511bfe95"),
+		SlotsPerEpoch:         32,
+		SecondsPerSlot:        12,
+		Relays: []string{
+			"https://relay-analytics.ultrasound.money",
+			"https://titanrelay.xyz",
+			"https://bloxroute.max-profit.blxrbdn.com",
+			"https://bloxroute.regulated.blxrbdn.com",
+			"https://boost-relay.flashbots.net",
+			"https://aestus.live",
+			"https://agnostic-relay.net",
+			"https://relay.ethgas.com",
+			"https://relay.btcs.com",
+		},
+		ExplorerUrl: "https://beaconcha.in",
+	}
+	Gnosis = Network{
+		Name:                  "gnosis",
+		GenesisTime:           time.Unix(1638993340, 0),
+		GenesisValidatorsRoot: root("0xf5dcb5564e829aab27264b9becd5dfaa017085611224cb3036f573368dbb9d47"),
+		SlotsPerEpoch:         16,
+		SecondsPerSlot:        5,
+		ExplorerUrl:           "https://gnosischa.in",
+	}
+	Holesky = Network{
+		Name:                  "holesky",
+		GenesisTime:           time.Unix(1695902400, 0),
+		GenesisValidatorsRoot: root("0x9143aa7c615a7f7115e2b6aac319c03529df8242ae705fba9df39b79c59fa8b1"),
+		SlotsPerEpoch:         32,
+		SecondsPerSlot:        12,
+		Relays: []string{
+			"https://boost-relay-holesky.flashbots.net",
+			"https://holesky.titanrelay.xyz",
+			"https://holesky.aestus.live",
+		},
+		ExplorerUrl: "https://holesky.beaconcha.in",
+		Testnet:     true,
+	}
+	Hoodi = Network{
+		Name:                  "hoodi",
+		GenesisTime:           time.Unix(1742213400, 0),
+		GenesisValidatorsRoot: root("0x212f13fc4df078b6cb7db228f1c8307566dcecf900867401a92023d7ba99cb5f"),
+		SlotsPerEpoch:         32,
+		SecondsPerSlot:        12,
+		Relays: []string{
+			"https://boost-relay-hoodi.flashbots.net",
+			"https://hoodi.titanrelay.xyz",
+			"https://hoodi.aestus.live",
+		},
+		ExplorerUrl: "https://hoodi.beaconcha.in",
+		Testnet:     true,
+	}
+	Sepolia = Network{
+		Name:                  "sepolia",
+		GenesisTime:           time.Unix(1655733600, 0),
+		GenesisValidatorsRoot: root("0xd8ea171f3c94aea21ebc42a1ed61052acf3f9209c00e4efbaaddac09ed9b8078"),
+		SlotsPerEpoch:         32,
+		SecondsPerSlot:        12,
+		Relays: []string{
+			"https://boost-relay-sepolia.flashbots.net",
+		},
+		ExplorerUrl: "https://sepolia.beaconcha.in",
+		Testnet:     true,
+	}
+)
+
+var networks = []Network{Ethereum, Gnosis, Holesky, Hoodi, Sepolia}
+
+// Names of the supported networks, as passed to --network
+var Names = func() []string {
+	names := make([]string, 0, len(networks))
+	for _, network := range networks {
+		names = append(names, network.Name)
+	}
+	return names
+}()
+
+func root(hex string) phase0.Root {
+	return phase0.Root(common.HexToHash(hex))
+}
+
+// Returns the network with the given name
+func Get(name string) (Network, error) {
+	for _, network := range networks {
+		if network.Name == name {
+			return network, nil
+		}
+	}
+	return Network{}, errors.New("network not supported: " + name)
+}
+
+func (n Network) EpochDuration() time.Duration {
+	return time.Duration(n.SlotsPerEpoch*n.SecondsPerSlot) * time.Second
+}
+
+// Returns an error if the genesis of the beacon node is not the one of the
+// network, so that the keys of a network are not looked up in another one
+func (n Network) CheckGenesis(genesisTime time.Time, genesisValidatorsRoot phase0.Root) error {
+	if genesisValidatorsRoot != n.GenesisValidatorsRoot || !genesisTime.Equal(n.GenesisTime) {
+		return errors.New(fmt.Sprintf(
+			"beacon node is not on %s: genesis validators root %#x at %d, expected %#x at %d. Set --network to the network of the node",
+			n.Name, genesisValidatorsRoot, genesisTime.Unix(), n.GenesisValidatorsRoot, n.GenesisTime.Unix()))
+	}
+	return nil
+}
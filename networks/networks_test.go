@@ -0,0 +1,37 @@
+package networks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Get(t *testing.T) {
+	network, err := Get("hoodi")
+	require.NoError(t, err)
+	require.Equal(t, Hoodi.GenesisValidatorsRoot, network.GenesisValidatorsRoot)
+	require.Equal(t, 384*time.Second, network.EpochDuration())
+	require.True(t, network.Testnet)
+
+	gnosis, err := Get("gnosis")
+	require.NoError(t, err)
+	require.Equal(t, 80*time.Second, gnosis.EpochDuration())
+	require.Empty(t, gnosis.Relays)
+
+	_, err = Get("goerli")
+	require.ErrorContains(t, err, "network not supported: goerli")
+	require.Equal(t, []string{"ethereum", "gnosis", "holesky", "hoodi", "sepolia"}, Names)
+}
+
+func Test_CheckGenesis(t *testing.T) {
+	require.NoError(t, Ethereum.CheckGenesis(time.Unix(1606824023, 0).UTC(), Ethereum.GenesisValidatorsRoot))
+	require.NoError(t, Sepolia.CheckGenesis(Sepolia.GenesisTime, root("0xd8ea171f3c94aea21ebc42a1ed61052acf3f9209c00e4efbaaddac09ed9b8078")))
+
+	// A mainnet node with --network=hoodi
+	err := Hoodi.CheckGenesis(Ethereum.GenesisTime, Ethereum.GenesisValidatorsRoot)
+	require.ErrorContains(t, err, "beacon node is not on hoodi: genesis validators root 0x4b363db94e286120d76eb905340fdd4e54bfe9f06bf33ff6cf5ad27f511bfe95 at 1606824023")
+
+	// Same root at another time, e.g. a devnet
+	require.Error(t, Holesky.CheckGenesis(Holesky.GenesisTime.Add(time.Second), Holesky.GenesisValidatorsRoot))
+}
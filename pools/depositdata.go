@@ -0,0 +1,120 @@
+package pools
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Entry of a deposit_data-*.json file of staking-deposit-cli
+type depositData struct {
+	Pubkey string `json:"pubkey"`
+}
+
+// EIP-2335 keystore. Only the public key is read, the secret is left encrypted
+type keystore struct {
+	Pubkey string          `json:"pubkey"`
+	Crypto json.RawMessage `json:"crypto"`
+}
+
+// Tells if the pool name is a file or directory with its keys: a .txt with one
+// key per line, a deposit data .json or a directory with keystores
+func IsPoolKeysFile(poolName string) bool {
+	if strings.HasSuffix(poolName, ".txt") || strings.HasSuffix(poolName, ".json") {
+		return true
+	}
+	info, err := os.Stat(poolName)
+	return err == nil && info.IsDir()
+}
+
+// Reads the keys of a pool from any of the files accepted by IsPoolKeysFile
+func ReadPoolKeysFile(poolName string) ([][]byte, error) {
+	if strings.HasSuffix(poolName, ".txt") {
+		return ReadCustomValidatorsFile(poolName)
+	}
+	if strings.HasSuffix(poolName, ".json") {
+		return ReadDepositDataFile(poolName)
+	}
+	return ReadKeystoresDir(poolName)
+}
+
+func ReadDepositDataFile(path string) ([][]byte, error) {
+	log.Info("Reading validator keys from deposit data: ", path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var deposits []depositData
+	if err := json.Unmarshal(data, &deposits); err != nil {
+		return nil, errors.Wrap(err, "error decoding deposit data "+path)
+	}
+
+	keys := make([][]byte, 0, len(deposits))
+	for _, deposit := range deposits {
+		key, err := decodePubKey(deposit.Pubkey)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	log.Info("Done reading ", len(keys), " from ", path)
+	return keys, nil
+}
+
+// Reads the public keys of the keystores in a directory, like the validator_keys
+// directory created by staking-deposit-cli. Other json files, e.g. the deposit
+// data that is usually next to them, are skipped.
+func ReadKeystoresDir(dir string) ([][]byte, error) {
+	log.Info("Reading validator keys from keystores: ", dir)
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([][]byte, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var ks keystore
+		if err := json.Unmarshal(data, &ks); err != nil || ks.Crypto == nil {
+			continue
+		}
+		// The pubkey is optional in EIP-2335 and can't be derived without the password
+		if ks.Pubkey == "" {
+			return nil, errors.New("keystore without pubkey: " + path)
+		}
+		key, err := decodePubKey(ks.Pubkey)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid keystore "+path)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no keystores found in " + dir)
+	}
+	log.Info("Done reading ", len(keys), " from ", dir)
+	return keys, nil
+}
+
+// Decodes a public key with or without 0x prefix, as deposit data and keystores
+// have it without
+func decodePubKey(pubKey string) ([]byte, error) {
+	if !strings.HasPrefix(pubKey, "0x") {
+		pubKey = "0x" + pubKey
+	}
+	key, err := hexutil.Decode(pubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode key: "+pubKey)
+	}
+	if len(key) != pubKeyLength {
+		return nil, errors.New("length of key is incorrect: " + pubKey)
+	}
+	return key, nil
+}
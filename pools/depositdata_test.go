@@ -0,0 +1,54 @@
+package pools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ReadDepositDataFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deposit_data-1700000000.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[
+		{"pubkey": "947265fae1dc387b143a913a6a5f6a4b5b5db897661b38de728dad62a4ac3a9a4232116338bb35a85944fe6263faac61", "amount": 32000000000},
+		{"pubkey": "8253556022b09877b0de3de5a0e4b3c254be36f200dcd6ec2a285ce0758b83aae049a54ec3a73f5fa80d2ad84cfea3cf", "amount": 32000000000}
+	]`), 0644))
+
+	require.True(t, IsPoolKeysFile(path))
+	keys, err := ReadPoolKeysFile(path)
+	require.NoError(t, err)
+	require.Equal(t, expectedKeys[:2], keys)
+
+	require.NoError(t, os.WriteFile(path, []byte(`[{"pubkey": "0x1234"}]`), 0644))
+	_, err = ReadPoolKeysFile(path)
+	require.Error(t, err)
+}
+
+func Test_ReadKeystoresDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "keystore-m_12381_3600_0_0_0-1700000000.json"), []byte(`{
+		"crypto": {"kdf": {}, "checksum": {}, "cipher": {}},
+		"pubkey": "947265fae1dc387b143a913a6a5f6a4b5b5db897661b38de728dad62a4ac3a9a4232116338bb35a85944fe6263faac61",
+		"path": "m/12381/3600/0/0/0",
+		"version": 4
+	}`), 0644))
+	// Deposit data in the same directory is skipped
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "deposit_data-1700000000.json"), []byte(`[
+		{"pubkey": "947265fae1dc387b143a913a6a5f6a4b5b5db897661b38de728dad62a4ac3a9a4232116338bb35a85944fe6263faac61"}
+	]`), 0644))
+
+	require.True(t, IsPoolKeysFile(dir))
+	require.False(t, IsPoolKeysFile(filepath.Join(dir, "missing")))
+	keys, err := ReadPoolKeysFile(dir)
+	require.NoError(t, err)
+	require.Equal(t, expectedKeys[:1], keys)
+
+	// Without pubkey it can't be read without the password
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "keystore-m_12381_3600_1_0_0-1700000000.json"), []byte(`{"crypto": {}, "version": 4}`), 0644))
+	_, err = ReadPoolKeysFile(dir)
+	require.Error(t, err)
+
+	_, err = ReadPoolKeysFile(t.TempDir())
+	require.Error(t, err)
+}
@@ -0,0 +1,153 @@
+package pools
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Validators per page requested to the explorer. beaconcha.in allows up to 2000
+const explorerPageSize = 2000
+
+// Response of the beaconcha.in v1 api. data is an object instead of a list if
+// there is a single validator.
+type explorerResponse struct {
+	Status string          `json:"status"`
+	Data   json.RawMessage `json:"data"`
+}
+
+type explorerValidator struct {
+	PublicKey string `json:"publickey"`
+}
+
+// Finds the validators of well-known entities, e.g. exchanges, using a block
+// explorer api. {entity}, {limit} and {offset} are replaced in the api url.
+// Explorers are rate limited, so the validators are only requested again after
+// the refresh interval.
+type Explorer struct {
+	httpClient   *http.Client
+	apiUrl       string
+	apiKey       string
+	refresh      time.Duration
+	entityToPool map[string]string
+	lastFetch    time.Time
+}
+
+// Parses entities defined as pool:entity, e.g. pool_a:0xaddress
+func ParseExplorerEntities(explorerEntities []string) (map[string]string, error) {
+	entityToPool := make(map[string]string)
+	for _, explorerEntity := range explorerEntities {
+		poolName, entity, found := strings.Cut(explorerEntity, ":")
+		if !found || poolName == "" || entity == "" {
+			return nil, errors.New(fmt.Sprintf("explorer entity must be pool:entity: %s", explorerEntity))
+		}
+		if pool, ok := entityToPool[entity]; ok && pool != poolName {
+			return nil, errors.New(fmt.Sprintf("explorer entity %s used by pools %s and %s", entity, pool, poolName))
+		}
+		entityToPool[entity] = poolName
+	}
+	return entityToPool, nil
+}
+
+func NewExplorer(apiUrl string, apiKey string, refresh time.Duration, entityToPool map[string]string) (*Explorer, error) {
+	if !strings.Contains(apiUrl, "{entity}") {
+		return nil, errors.New("explorer api must contain {entity}: " + apiUrl)
+	}
+	return &Explorer{
+		httpClient:   &http.Client{Timeout: 60 * time.Second},
+		apiUrl:       apiUrl,
+		apiKey:       apiKey,
+		refresh:      refresh,
+		entityToPool: entityToPool,
+	}, nil
+}
+
+func (e *Explorer) Name() string {
+	return "explorer"
+}
+
+// Returns the keys of the validators of each entity, per pool, or none if they
+// were requested less than the refresh interval ago
+func (e *Explorer) GetNewValidatorKeys() (map[string][][]byte, error) {
+	poolValidatorKeys := make(map[string][][]byte)
+	if !e.lastFetch.IsZero() && time.Since(e.lastFetch) < e.refresh {
+		return poolValidatorKeys, nil
+	}
+	for entity, poolName := range e.entityToPool {
+		for offset := 0; ; offset += explorerPageSize {
+			validators, err := e.getValidators(entity, offset)
+			if err != nil {
+				return poolValidatorKeys, errors.Wrap(err, "error getting validators of explorer entity "+entity)
+			}
+			for _, validator := range validators {
+				pubKey, err := decodePubKey(validator.PublicKey)
+				if err != nil {
+					return poolValidatorKeys, err
+				}
+				poolValidatorKeys[poolName] = append(poolValidatorKeys[poolName], pubKey)
+			}
+			if len(validators) < explorerPageSize {
+				break
+			}
+		}
+	}
+	// Only once all of them were read, so a failure is retried in the next call
+	e.lastFetch = time.Now()
+	return poolValidatorKeys, nil
+}
+
+func (e *Explorer) getValidators(entity string, offset int) ([]explorerValidator, error) {
+	requestUrl := strings.NewReplacer(
+		"{entity}", url.PathEscape(entity),
+		"{limit}", fmt.Sprint(explorerPageSize),
+		"{offset}", fmt.Sprint(offset),
+	).Replace(e.apiUrl)
+	req, err := http.NewRequest(http.MethodGet, requestUrl, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating request")
+	}
+	if e.apiKey != "" {
+		req.Header.Set("apikey", e.apiKey)
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(fmt.Sprintf("non-200 status: %d", resp.StatusCode))
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading response body")
+	}
+
+	var response explorerResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, errors.Wrap(err, "error decoding validators")
+	}
+	if response.Status != "" && response.Status != "OK" {
+		return nil, errors.New("explorer api error: " + response.Status)
+	}
+	if len(response.Data) == 0 || string(response.Data) == "null" {
+		return nil, nil
+	}
+	var validators []explorerValidator
+	if response.Data[0] == '{' {
+		var validator explorerValidator
+		if err := json.Unmarshal(response.Data, &validator); err != nil {
+			return nil, errors.Wrap(err, "error decoding validator")
+		}
+		validators = append(validators, validator)
+	} else if err := json.Unmarshal(response.Data, &validators); err != nil {
+		return nil, errors.Wrap(err, "error decoding validators")
+	}
+	return validators, nil
+}
@@ -0,0 +1,55 @@
+package pools
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Explorer(t *testing.T) {
+	entityToPool, err := ParseExplorerEntities([]string{"pool_a:0xentity", "pool_b:single"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"0xentity": "pool_a", "single": "pool_b"}, entityToPool)
+	_, err = ParseExplorerEntities([]string{"pool_a"})
+	require.Error(t, err)
+	_, err = ParseExplorerEntities([]string{"pool_a:x", "pool_b:x"})
+	require.Error(t, err)
+
+	nOfRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nOfRequests++
+		require.Equal(t, "secret", r.Header.Get("apikey"))
+		require.Equal(t, "2000", r.URL.Query().Get("limit"))
+		switch r.URL.Path {
+		case "/validator/eth1/0xentity":
+			fmt.Fprintf(w, `{"status":"OK","data":[{"publickey":"%s","validatorindex":1},{"publickey":"%s","validatorindex":2}]}`, keyA, keyB)
+		case "/validator/eth1/single":
+			// A single validator is not returned as a list
+			fmt.Fprintf(w, `{"status":"OK","data":{"publickey":"%s","validatorindex":1}}`, keyB)
+		}
+	}))
+	defer server.Close()
+
+	explorer, err := NewExplorer(server.URL+"/validator/eth1/{entity}?limit={limit}&offset={offset}", "secret", time.Hour, entityToPool)
+	require.NoError(t, err)
+	keys, err := explorer.GetNewValidatorKeys()
+	require.NoError(t, err)
+	require.Equal(t, map[string][][]byte{
+		"pool_a": {hexutil.MustDecode(keyA), hexutil.MustDecode(keyB)},
+		"pool_b": {hexutil.MustDecode(keyB)},
+	}, keys)
+
+	// Not requested again until the refresh interval passes
+	keys, err = explorer.GetNewValidatorKeys()
+	require.NoError(t, err)
+	require.Empty(t, keys)
+	require.Equal(t, 2, nOfRequests)
+
+	_, err = NewExplorer(server.URL, "", time.Hour, entityToPool)
+	require.Error(t, err)
+}
@@ -0,0 +1,68 @@
+package pools
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/pkg/errors"
+)
+
+// Parses groups of pools given as group:member1,member2, where the members are
+// pools or other groups, e.g. an operator with a cluster per client, each one
+// with its pools. A group can be given more than once to add members to it.
+// Each pool or group can only be in one group, so that they form a hierarchy.
+func ParsePoolGroups(poolGroups []string) (map[string]schemas.PoolGroup, error) {
+	groups := make(map[string]schemas.PoolGroup)
+	parents := make(map[string]string)
+	for _, poolGroup := range poolGroups {
+		groupName, members, found := strings.Cut(poolGroup, ":")
+		if !found || groupName == "" || members == "" {
+			return nil, errors.New(fmt.Sprintf("pool group must be group:pool1,pool2: %s", poolGroup))
+		}
+		group := groups[groupName]
+		group.Name = groupName
+		for _, member := range strings.Split(members, ",") {
+			if member == "" || member == groupName {
+				return nil, errors.New(fmt.Sprintf("invalid member of pool group %s: %q", groupName, member))
+			}
+			if parent, ok := parents[member]; ok && parent != groupName {
+				return nil, errors.New(fmt.Sprintf("%s is in pool groups %s and %s", member, parent, groupName))
+			}
+			if !slices.Contains(group.Members, member) {
+				group.Members = append(group.Members, member)
+			}
+			parents[member] = groupName
+		}
+		groups[groupName] = group
+	}
+
+	for groupName, group := range groups {
+		// Following the parents from a group can't reach it again
+		depth := 0
+		for parent, ok := parents[groupName]; ok; parent, ok = parents[parent] {
+			if depth++; parent == groupName || depth > len(groups) {
+				return nil, errors.New("pool group " + groupName + " contains itself")
+			}
+		}
+		group.Parent = parents[groupName]
+		group.Pools = groupPools(groups, groupName)
+		groups[groupName] = group
+	}
+	return groups, nil
+}
+
+// Returns the pools of the group and of its nested groups, sorted
+func groupPools(groups map[string]schemas.PoolGroup, groupName string) []string {
+	pools := make([]string, 0)
+	for _, member := range groups[groupName].Members {
+		if _, isGroup := groups[member]; isGroup {
+			pools = append(pools, groupPools(groups, member)...)
+		} else {
+			pools = append(pools, member)
+		}
+	}
+	slices.Sort(pools)
+	return pools
+}
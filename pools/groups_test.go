@@ -0,0 +1,53 @@
+package pools
+
+import (
+	"testing"
+
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParsePoolGroups(t *testing.T) {
+	groups, err := ParsePoolGroups([]string{
+		"operator_x:x_cluster_a,x_cluster_b",
+		"x_cluster_a:x-lighthouse,x-teku",
+		"x_cluster_b:x-nimbus",
+		// Adds a member
+		"x_cluster_b:x-lodestar,x-nimbus",
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]schemas.PoolGroup{
+		"operator_x": {
+			Name:    "operator_x",
+			Members: []string{"x_cluster_a", "x_cluster_b"},
+			Pools:   []string{"x-lighthouse", "x-lodestar", "x-nimbus", "x-teku"},
+		},
+		"x_cluster_a": {
+			Name:    "x_cluster_a",
+			Parent:  "operator_x",
+			Members: []string{"x-lighthouse", "x-teku"},
+			Pools:   []string{"x-lighthouse", "x-teku"},
+		},
+		"x_cluster_b": {
+			Name:    "x_cluster_b",
+			Parent:  "operator_x",
+			Members: []string{"x-nimbus", "x-lodestar"},
+			Pools:   []string{"x-lodestar", "x-nimbus"},
+		},
+	}, groups)
+
+	groups, err = ParsePoolGroups(nil)
+	require.NoError(t, err)
+	require.Empty(t, groups)
+
+	_, err = ParsePoolGroups([]string{"operator_x"})
+	require.ErrorContains(t, err, "must be group:pool1,pool2")
+	_, err = ParsePoolGroups([]string{"operator_x:pool_a,"})
+	require.ErrorContains(t, err, "invalid member")
+	_, err = ParsePoolGroups([]string{"operator_x:pool_a", "operator_y:pool_a"})
+	require.ErrorContains(t, err, "pool_a is in pool groups operator_x and operator_y")
+	_, err = ParsePoolGroups([]string{"group_a:group_b", "group_b:group_c", "group_c:group_a"})
+	require.ErrorContains(t, err, "contains itself")
+	_, err = ParsePoolGroups([]string{"group_c:pool_a", "group_a:group_c,group_b", "group_b:group_a"})
+	require.ErrorContains(t, err, "contains itself")
+}
@@ -0,0 +1,216 @@
+package pools
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+)
+
+// Lido staking modules whose operators can be tracked
+const (
+	LidoCurated   = "curated"
+	LidoSimpleDVT = "sdvt"
+	LidoCSM       = "csm"
+)
+
+var lidoModuleAddresses = map[string]map[string]common.Address{
+	"ethereum": {
+		LidoCurated:   common.HexToAddress("0x55032650b14df07b85bF18A3a3eC8E0Af2e028d5"),
+		LidoSimpleDVT: common.HexToAddress("0xaE7B191A31f627b4eB1d4DaC64eab9976995b433"),
+		LidoCSM:       common.HexToAddress("0xdA7dE2ECdDfccC6c3AF10108Db212ACBBf9EA83F"),
+	},
+}
+
+// Curated and SimpleDVT modules share the NodeOperatorsRegistry contract
+const lidoRegistryAbi = `[
+	{"name":"getNodeOperator","type":"function","stateMutability":"view","inputs":[{"name":"_nodeOperatorId","type":"uint256"},{"name":"_fullInfo","type":"bool"}],"outputs":[{"name":"active","type":"bool"},{"name":"name","type":"string"},{"name":"rewardAddress","type":"address"},{"name":"totalVettedValidators","type":"uint64"},{"name":"totalExitedValidators","type":"uint64"},{"name":"totalAddedValidators","type":"uint64"},{"name":"totalDepositedValidators","type":"uint64"}]},
+	{"name":"getSigningKeys","type":"function","stateMutability":"view","inputs":[{"name":"_nodeOperatorId","type":"uint256"},{"name":"_offset","type":"uint256"},{"name":"_limit","type":"uint256"}],"outputs":[{"name":"pubkeys","type":"bytes"},{"name":"signatures","type":"bytes"},{"name":"used","type":"bool[]"}]}
+]`
+
+const lidoCSMAbi = `[
+	{"name":"getNodeOperatorTotalDepositedKeys","type":"function","stateMutability":"view","inputs":[{"name":"nodeOperatorId","type":"uint256"}],"outputs":[{"name":"totalDepositedKeys","type":"uint256"}]},
+	{"name":"getSigningKeys","type":"function","stateMutability":"view","inputs":[{"name":"nodeOperatorId","type":"uint256"},{"name":"startIndex","type":"uint256"},{"name":"keysCount","type":"uint256"}],"outputs":[{"name":"","type":"bytes"}]}
+]`
+
+// Keys read from the contracts per call
+const lidoKeysBatchSize = 100
+
+const pubKeyLength = 48
+
+type LidoOperator struct {
+	PoolName   string
+	Module     string
+	OperatorId uint64
+}
+
+// Finds the deposited keys of Lido node operators by querying the staking
+// module contracts on the execution client
+type Lido struct {
+	caller      ethereum.ContractCaller
+	registryAbi abi.ABI
+	csmAbi      abi.ABI
+	modules     map[string]common.Address
+	operators   []LidoOperator
+	// Keys already read from each operator. Deposited keys are never removed
+	nOfKeys map[LidoOperator]uint64
+}
+
+// Parses operators defined as pool:module:id, e.g. pool_a:curated:12
+func ParseLidoOperators(lidoOperators []string) ([]LidoOperator, error) {
+	operators := make([]LidoOperator, 0, len(lidoOperators))
+	for _, lidoOperator := range lidoOperators {
+		fields := strings.Split(lidoOperator, ":")
+		if len(fields) != 3 || fields[0] == "" {
+			return nil, errors.New(fmt.Sprintf("lido operator must be pool:module:id: %s", lidoOperator))
+		}
+		if fields[1] != LidoCurated && fields[1] != LidoSimpleDVT && fields[1] != LidoCSM {
+			return nil, errors.New(fmt.Sprintf("lido module must be %s|%s|%s: %s", LidoCurated, LidoSimpleDVT, LidoCSM, fields[1]))
+		}
+		operatorId, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("invalid lido operator id: %s", fields[2]))
+		}
+		operators = append(operators, LidoOperator{
+			PoolName:   fields[0],
+			Module:     fields[1],
+			OperatorId: operatorId,
+		})
+	}
+	return operators, nil
+}
+
+func NewLido(
+	caller ethereum.ContractCaller,
+	network string,
+	operators []LidoOperator) (*Lido, error) {
+
+	modules, ok := lidoModuleAddresses[network]
+	if !ok {
+		return nil, errors.New("lido is not supported in network: " + network)
+	}
+	registryAbi, err := abi.JSON(strings.NewReader(lidoRegistryAbi))
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing lido registry abi")
+	}
+	csmAbi, err := abi.JSON(strings.NewReader(lidoCSMAbi))
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing lido csm abi")
+	}
+	return &Lido{
+		caller:      caller,
+		registryAbi: registryAbi,
+		csmAbi:      csmAbi,
+		modules:     modules,
+		operators:   operators,
+		nOfKeys:     make(map[LidoOperator]uint64),
+	}, nil
+}
+
+// Returns the keys deposited since the last call, per pool. On error, the keys
+// read so far are also returned since they won't be read again.
+func (l *Lido) GetNewValidatorKeys() (map[string][][]byte, error) {
+	poolValidatorKeys := make(map[string][][]byte)
+	for _, operator := range l.operators {
+		deposited, err := l.getDepositedKeys(operator)
+		if err != nil {
+			return poolValidatorKeys, errors.Wrap(err, fmt.Sprintf("error getting deposited keys of lido %s operator %d", operator.Module, operator.OperatorId))
+		}
+
+		for offset := l.nOfKeys[operator]; offset < deposited; offset += lidoKeysBatchSize {
+			limit := min(lidoKeysBatchSize, deposited-offset)
+			pubKeys, err := l.getSigningKeys(operator, offset, limit)
+			if err != nil {
+				return poolValidatorKeys, errors.Wrap(err, fmt.Sprintf("error getting keys of lido %s operator %d", operator.Module, operator.OperatorId))
+			}
+			poolValidatorKeys[operator.PoolName] = append(poolValidatorKeys[operator.PoolName], pubKeys...)
+			l.nOfKeys[operator] = offset + limit
+		}
+	}
+	return poolValidatorKeys, nil
+}
+
+func (l *Lido) getDepositedKeys(operator LidoOperator) (uint64, error) {
+	operatorId := new(big.Int).SetUint64(operator.OperatorId)
+	if operator.Module == LidoCSM {
+		var deposited *big.Int
+		if err := l.call(l.csmAbi, operator.Module, &deposited, "getNodeOperatorTotalDepositedKeys", operatorId); err != nil {
+			return 0, err
+		}
+		return deposited.Uint64(), nil
+	}
+
+	var nodeOperator struct {
+		Active                   bool
+		Name                     string
+		RewardAddress            common.Address
+		TotalVettedValidators    uint64
+		TotalExitedValidators    uint64
+		TotalAddedValidators     uint64
+		TotalDepositedValidators uint64
+	}
+	if err := l.call(l.registryAbi, operator.Module, &nodeOperator, "getNodeOperator", operatorId, false); err != nil {
+		return 0, err
+	}
+	return nodeOperator.TotalDepositedValidators, nil
+}
+
+func (l *Lido) getSigningKeys(operator LidoOperator, offset uint64, limit uint64) ([][]byte, error) {
+	args := []any{
+		new(big.Int).SetUint64(operator.OperatorId),
+		new(big.Int).SetUint64(offset),
+		new(big.Int).SetUint64(limit),
+	}
+
+	var pubKeys []byte
+	if operator.Module == LidoCSM {
+		if err := l.call(l.csmAbi, operator.Module, &pubKeys, "getSigningKeys", args...); err != nil {
+			return nil, err
+		}
+	} else {
+		var signingKeys struct {
+			Pubkeys    []byte
+			Signatures []byte
+			Used       []bool
+		}
+		if err := l.call(l.registryAbi, operator.Module, &signingKeys, "getSigningKeys", args...); err != nil {
+			return nil, err
+		}
+		pubKeys = signingKeys.Pubkeys
+	}
+
+	if uint64(len(pubKeys)) != limit*pubKeyLength {
+		return nil, errors.New(fmt.Sprintf("unexpected length of keys: %d", len(pubKeys)))
+	}
+	keys := make([][]byte, 0, limit)
+	for i := 0; i < len(pubKeys); i += pubKeyLength {
+		keys = append(keys, pubKeys[i:i+pubKeyLength])
+	}
+	return keys, nil
+}
+
+func (l *Lido) call(contractAbi abi.ABI, module string, result any, method string, args ...any) error {
+	contract := l.modules[module]
+	data, err := contractAbi.Pack(method, args...)
+	if err != nil {
+		return err
+	}
+	output, err := l.caller.CallContract(context.Background(), ethereum.CallMsg{
+		To:   &contract,
+		Data: data,
+	}, nil)
+	if err != nil {
+		return err
+	}
+	return contractAbi.UnpackIntoInterface(result, method, output)
+}
+
+func (l *Lido) Name() string {
+	return "lido"
+}
@@ -0,0 +1,137 @@
+package pools
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+// Fake staking modules where each operator has the given keys, of which the
+// first deposited ones are deposited
+type fakeLido struct {
+	registryAbi abi.ABI
+	csmAbi      abi.ABI
+	keys        map[LidoOperator][][]byte
+	deposited   map[LidoOperator]uint64
+}
+
+func (f *fakeLido) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	module := ""
+	for name, address := range lidoModuleAddresses["ethereum"] {
+		if address == *call.To {
+			module = name
+		}
+	}
+	contractAbi := f.registryAbi
+	if module == LidoCSM {
+		contractAbi = f.csmAbi
+	}
+	method, err := contractAbi.MethodById(call.Data[:4])
+	if err != nil {
+		return nil, err
+	}
+	args, err := method.Inputs.Unpack(call.Data[4:])
+	if err != nil {
+		return nil, err
+	}
+	operator := LidoOperator{Module: module, OperatorId: args[0].(*big.Int).Uint64()}
+	switch method.Name {
+	case "getNodeOperator":
+		deposited := f.deposited[operator]
+		return method.Outputs.Pack(true, "operator", common.Address{}, deposited, uint64(0), uint64(len(f.keys[operator])), deposited)
+	case "getNodeOperatorTotalDepositedKeys":
+		return method.Outputs.Pack(new(big.Int).SetUint64(f.deposited[operator]))
+	case "getSigningKeys":
+		offset := args[1].(*big.Int).Uint64()
+		limit := args[2].(*big.Int).Uint64()
+		pubKeys := bytes.Join(f.keys[operator][offset:offset+limit], nil)
+		if module == LidoCSM {
+			return method.Outputs.Pack(pubKeys)
+		}
+		return method.Outputs.Pack(pubKeys, []byte{}, make([]bool, limit))
+	}
+	return nil, ethereum.NotFound
+}
+
+func lidoKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, pubKeyLength)
+}
+
+func Test_ParseLidoOperators(t *testing.T) {
+	operators, err := ParseLidoOperators([]string{"pool_a:curated:12", "pool_b:csm:3"})
+	require.NoError(t, err)
+	require.Equal(t, []LidoOperator{
+		{PoolName: "pool_a", Module: LidoCurated, OperatorId: 12},
+		{PoolName: "pool_b", Module: LidoCSM, OperatorId: 3},
+	}, operators)
+
+	_, err = ParseLidoOperators([]string{"pool_a:curated"})
+	require.Error(t, err)
+	_, err = ParseLidoOperators([]string{"pool_a:unknown:1"})
+	require.Error(t, err)
+	_, err = ParseLidoOperators([]string{"pool_a:sdvt:x"})
+	require.Error(t, err)
+}
+
+func Test_Lido(t *testing.T) {
+	registryAbi, err := abi.JSON(strings.NewReader(lidoRegistryAbi))
+	require.NoError(t, err)
+	csmAbi, err := abi.JSON(strings.NewReader(lidoCSMAbi))
+	require.NoError(t, err)
+
+	curated := LidoOperator{Module: LidoCurated, OperatorId: 12}
+	csm := LidoOperator{Module: LidoCSM, OperatorId: 3}
+	// Same id in another module
+	sdvt := LidoOperator{Module: LidoSimpleDVT, OperatorId: 12}
+
+	fake := &fakeLido{
+		registryAbi: registryAbi,
+		csmAbi:      csmAbi,
+		keys: map[LidoOperator][][]byte{
+			curated: {lidoKey(0xa0), lidoKey(0xa1), lidoKey(0xa2)},
+			csm:     {lidoKey(0xb0)},
+			sdvt:    {lidoKey(0xc0)},
+		},
+		deposited: map[LidoOperator]uint64{curated: 2, csm: 1, sdvt: 1},
+	}
+
+	lido, err := NewLido(fake, "ethereum", []LidoOperator{
+		{PoolName: "pool_a", Module: LidoCurated, OperatorId: 12},
+		{PoolName: "pool_a", Module: LidoSimpleDVT, OperatorId: 12},
+		{PoolName: "pool_b", Module: LidoCSM, OperatorId: 3},
+	})
+	require.NoError(t, err)
+
+	// Only deposited keys are returned
+	keys, err := lido.GetNewValidatorKeys()
+	require.NoError(t, err)
+	require.Equal(t, map[string][][]byte{
+		"pool_a": {lidoKey(0xa0), lidoKey(0xa1), lidoKey(0xc0)},
+		"pool_b": {lidoKey(0xb0)},
+	}, keys)
+
+	keys, err = lido.GetNewValidatorKeys()
+	require.NoError(t, err)
+	require.Equal(t, 0, len(keys))
+
+	// Keys are read in batches once deposited
+	for i := 0; i < lidoKeysBatchSize+10; i++ {
+		fake.keys[csm] = append(fake.keys[csm], lidoKey(byte(i)))
+	}
+	fake.deposited[csm] = uint64(len(fake.keys[csm]))
+	fake.deposited[curated] = 3
+	keys, err = lido.GetNewValidatorKeys()
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{lidoKey(0xa2)}, keys["pool_a"])
+	require.Equal(t, fake.keys[csm][1:], keys["pool_b"])
+
+	_, err = NewLido(fake, "gnosis", []LidoOperator{})
+	require.Error(t, err)
+}
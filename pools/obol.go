@@ -0,0 +1,84 @@
+package pools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+)
+
+type obolClusterLock struct {
+	DistributedValidators []struct {
+		DistributedPublicKey string `json:"distributed_public_key"`
+	} `json:"distributed_validators"`
+}
+
+// Reads the distributed validators of Obol clusters from their cluster-lock.json
+// files. The files are read every time so that updated locks are picked up.
+type Obol struct {
+	clusterLockToPool map[string]string
+}
+
+// Parses cluster locks defined as pool:path, e.g. pool_a:cluster-lock.json
+func ParseObolClusterLocks(obolClusterLocks []string) (map[string]string, error) {
+	clusterLockToPool := make(map[string]string)
+	for _, obolClusterLock := range obolClusterLocks {
+		poolName, path, found := strings.Cut(obolClusterLock, ":")
+		if !found || poolName == "" || path == "" {
+			return nil, errors.New(fmt.Sprintf("obol cluster lock must be pool:path: %s", obolClusterLock))
+		}
+		clusterLockToPool[path] = poolName
+	}
+	return clusterLockToPool, nil
+}
+
+func NewObol(clusterLockToPool map[string]string) (*Obol, error) {
+	for path := range clusterLockToPool {
+		if _, err := ReadObolClusterLock(path); err != nil {
+			return nil, err
+		}
+	}
+	return &Obol{
+		clusterLockToPool: clusterLockToPool,
+	}, nil
+}
+
+func (o *Obol) Name() string {
+	return "obol"
+}
+
+// Returns the keys of the distributed validators of each cluster, per pool
+func (o *Obol) GetNewValidatorKeys() (map[string][][]byte, error) {
+	poolValidatorKeys := make(map[string][][]byte)
+	for path, poolName := range o.clusterLockToPool {
+		keys, err := ReadObolClusterLock(path)
+		if err != nil {
+			return poolValidatorKeys, err
+		}
+		poolValidatorKeys[poolName] = append(poolValidatorKeys[poolName], keys...)
+	}
+	return poolValidatorKeys, nil
+}
+
+func ReadObolClusterLock(path string) ([][]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading cluster lock "+path)
+	}
+	var clusterLock obolClusterLock
+	if err := json.Unmarshal(data, &clusterLock); err != nil {
+		return nil, errors.Wrap(err, "error decoding cluster lock "+path)
+	}
+	keys := make([][]byte, 0, len(clusterLock.DistributedValidators))
+	for _, validator := range clusterLock.DistributedValidators {
+		key, err := hexutil.Decode(validator.DistributedPublicKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid distributed validator key: "+validator.DistributedPublicKey)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
@@ -0,0 +1,34 @@
+package pools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Obol(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cluster-lock.json")
+	clusterLock := fmt.Sprintf(`{"cluster_definition":{"name":"cluster"},"distributed_validators":[{"distributed_public_key":"%s","public_shares":[]},{"distributed_public_key":"%s","public_shares":[]}]}`, keyA, keyB)
+	require.NoError(t, os.WriteFile(path, []byte(clusterLock), 0644))
+
+	clusterLockToPool, err := ParseObolClusterLocks([]string{"pool_a:" + path})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{path: "pool_a"}, clusterLockToPool)
+	_, err = ParseObolClusterLocks([]string{"pool_a"})
+	require.Error(t, err)
+
+	obol, err := NewObol(clusterLockToPool)
+	require.NoError(t, err)
+	keys, err := obol.GetNewValidatorKeys()
+	require.NoError(t, err)
+	require.Equal(t, map[string][][]byte{
+		"pool_a": {hexutil.MustDecode(keyA), hexutil.MustDecode(keyB)},
+	}, keys)
+
+	_, err = NewObol(map[string]string{"missing.json": "pool_a"})
+	require.Error(t, err)
+}
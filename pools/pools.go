@@ -3,12 +3,17 @@ package pools
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 )
 
@@ -99,19 +104,81 @@ func ReadEthstaValidatorsFile(validatorKeysFile string) (validatorKeys [][]byte,
 	return validatorKeys, nil
 }
 
-func ReadValidatorsFile(validatorsFile string) (poolValidatorKeys map[string][][]byte, validatorKeyToPool map[string]string, err error) {
+// What to do with keys listed more than once in a validators file, either in
+// the same pool or in several pools
+const (
+	DuplicateKeysError     = "error"
+	DuplicateKeysWarn      = "warn"
+	DuplicateKeysFirstWins = "first-wins"
+)
+
+var DuplicateKeysPolicies = []string{DuplicateKeysError, DuplicateKeysWarn, DuplicateKeysFirstWins}
+
+// Keys listed more than once in a validators file
+type DuplicateKeysReport struct {
+	// Number of keys repeated within each pool
+	InPool map[string]int
+	// Pools of each key listed in more than one pool, in order of appearance
+	CrossPool map[string][]string
+}
+
+func (r *DuplicateKeysReport) Empty() bool {
+	return len(r.InPool) == 0 && len(r.CrossPool) == 0
+}
+
+// Summary with the number of repeated keys per pool and per group of pools
+func (r *DuplicateKeysReport) String() string {
+	lines := make([]string, 0)
+	for pool, n := range r.InPool {
+		lines = append(lines, fmt.Sprintf("%d keys repeated in pool %s", n, pool))
+	}
+	nOfKeysPerPools := make(map[string]int)
+	for _, keyPools := range r.CrossPool {
+		nOfKeysPerPools[strings.Join(keyPools, ", ")]++
+	}
+	for keyPools, n := range nOfKeysPerPools {
+		lines = append(lines, fmt.Sprintf("%d keys in pools %s", n, keyPools))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "; ")
+}
+
+func ReadValidatorsFile(validatorsFile string, duplicateKeysPolicy string) (poolValidatorKeys map[string][][]byte, validatorKeyToPool map[string]string, poolValidatorIndexes map[string][]uint64, err error) {
 	log.Info("Reading validators csv file: ", validatorsFile)
-	poolValidatorKeys = make(map[string][][]byte)
-	validatorKeyToPool = make(map[string]string)
 
 	file, err := os.Open(validatorsFile)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	defer file.Close()
 
+	return ParseValidators(file, validatorsFile, duplicateKeysPolicy)
+}
+
+// Parses a validators csv with the format Validator Index,Public Key,Entity (Pool Name),Sub-Pool
+// The public key can be left empty, e.g. 123456,,pool_a, to give only the
+// index, whose key is then resolved from the beacon state. Every pool is in
+// the keys, even if it only has indexes.
+// Keys repeated within a pool are counted once. Keys in several pools make the
+// parsing fail, are counted in all of them or only in the first one, depending
+// on the policy. The same applies to indexes.
+func ParseValidators(reader io.Reader, source string, duplicateKeysPolicy string) (poolValidatorKeys map[string][][]byte, validatorKeyToPool map[string]string, poolValidatorIndexes map[string][]uint64, err error) {
+	if !slices.Contains(DuplicateKeysPolicies, duplicateKeysPolicy) {
+		return nil, nil, nil, errors.New("duplicate keys policy not supported: " + duplicateKeysPolicy)
+	}
+	poolValidatorKeys = make(map[string][][]byte)
+	validatorKeyToPool = make(map[string]string)
+	poolValidatorIndexes = make(map[string][]uint64)
+	// Pools in which each key or index was found, in order
+	keyPools := make(map[string][]string)
+	report := DuplicateKeysReport{
+		InPool:    make(map[string]int),
+		CrossPool: make(map[string][]string),
+	}
+
 	numKeys := 0
-	scanner := bufio.NewScanner(file)
+	numIndexes := 0
+	scanner := bufio.NewScanner(reader)
 	for scanner.Scan() {
 		line := scanner.Text()
 		// Skip first line
@@ -120,33 +187,126 @@ func ReadValidatorsFile(validatorsFile string) (poolValidatorKeys map[string][][
 		}
 		fields := strings.Split(line, ",")
 		if len(fields) != 4 {
-			return poolValidatorKeys, validatorKeyToPool, errors.New("the format of the file is not the expected: Validator Index,Public Key,Entity (Pool Name),Sub-Pool")
+			return poolValidatorKeys, validatorKeyToPool, poolValidatorIndexes, errors.New("the format of the file is not the expected: Validator Index,Public Key,Entity (Pool Name),Sub-Pool")
 		}
 		entity := fields[2]
 		keyStr := fields[1]
 
-		if !strings.HasPrefix(keyStr, "0x") {
-			keyStr = "0x" + keyStr
+		var valKey []byte
+		var valIndex uint64
+		if keyStr == "" {
+			valIndex, err = strconv.ParseUint(fields[0], 10, 64)
+			if err != nil {
+				return poolValidatorKeys, validatorKeyToPool, poolValidatorIndexes, errors.New(fmt.Sprintf("a validator without key must have an index: %s", line))
+			}
+			numIndexes++
+			// Not a valid key, so it can't match one
+			keyStr = "index " + fields[0]
+		} else {
+			if !strings.HasPrefix(keyStr, "0x") {
+				keyStr = "0x" + keyStr
+			}
+			if len(keyStr) != 98 {
+				return poolValidatorKeys, validatorKeyToPool, poolValidatorIndexes, errors.New(fmt.Sprintf("length of key is incorrect: %d", len(keyStr)))
+			}
+			valKey, err = hexutil.Decode(keyStr)
+			if err != nil {
+				return poolValidatorKeys, validatorKeyToPool, poolValidatorIndexes, errors.Wrap(err, fmt.Sprintf("could not decode key: %s", keyStr))
+			}
+			numKeys++
+
+			// Files may use uppercase hex
+			keyStr = hexutil.Encode(valKey)
 		}
-		if len(keyStr) != 98 {
-			return poolValidatorKeys, validatorKeyToPool, errors.New(fmt.Sprintf("length of key is incorrect: %d", len(keyStr)))
+		if slices.Contains(keyPools[keyStr], entity) {
+			report.InPool[entity]++
+			continue
 		}
-		valKey, err := hexutil.Decode(keyStr)
-		if err != nil {
-			return poolValidatorKeys, validatorKeyToPool, errors.Wrap(err, fmt.Sprintf("could not decode key: %s", keyStr))
+		keyPools[keyStr] = append(keyPools[keyStr], entity)
+		if len(keyPools[keyStr]) > 1 {
+			report.CrossPool[keyStr] = keyPools[keyStr]
+			if duplicateKeysPolicy == DuplicateKeysFirstWins {
+				continue
+			}
 		}
+
 		if _, ok := poolValidatorKeys[entity]; !ok {
 			poolValidatorKeys[entity] = make([][]byte, 0)
 		}
+		if valKey == nil {
+			poolValidatorIndexes[entity] = append(poolValidatorIndexes[entity], valIndex)
+			continue
+		}
 		poolValidatorKeys[entity] = append(poolValidatorKeys[entity], valKey)
-		validatorKeyToPool[keyStr] = entity
-		numKeys++
+		if _, ok := validatorKeyToPool[keyStr]; !ok {
+			validatorKeyToPool[keyStr] = entity
+		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
+	}
+
+	if !report.Empty() {
+		if duplicateKeysPolicy == DuplicateKeysError {
+			return nil, nil, nil, errors.New("duplicate keys in " + source + ": " + report.String())
+		}
+		log.Warn("Duplicate keys in ", source, ", applying policy ", duplicateKeysPolicy, ": ", report.String())
+	}
+
+	log.Info("Done reading ", numKeys, " keys and ", numIndexes, " indexes from ", source)
+	return poolValidatorKeys, validatorKeyToPool, poolValidatorIndexes, nil
+}
+
+// Parses pools defined as pool:0xaddress, where the address is a withdrawal
+// address or a fee recipient. Returns the pool of each lowercase address.
+func ParsePoolAddresses(poolAddresses []string) (map[string]string, error) {
+	addressToPool := make(map[string]string)
+	for _, poolAddress := range poolAddresses {
+		poolName, address, found := strings.Cut(poolAddress, ":")
+		if !found || poolName == "" {
+			return nil, errors.New(fmt.Sprintf("pool address must be pool:0xaddress: %s", poolAddress))
+		}
+		if !common.IsHexAddress(address) || !strings.HasPrefix(address, "0x") {
+			return nil, errors.New(fmt.Sprintf("invalid address: %s", address))
+		}
+		address = strings.ToLower(address)
+		if pool, ok := addressToPool[address]; ok && pool != poolName {
+			return nil, errors.New(fmt.Sprintf("address %s used by pools %s and %s", address, pool, poolName))
+		}
+		addressToPool[address] = poolName
 	}
+	return addressToPool, nil
+}
 
-	log.Info("Done reading ", numKeys, " keys from ", validatorsFile)
-	return poolValidatorKeys, validatorKeyToPool, nil
+// Parses the fee recipients each pool is expected to use, given as
+// pool:0xaddress. A pool can have several, and pools can share them.
+func ParseExpectedFeeRecipients(expectedFeeRecipients []string) (map[string]map[string]bool, error) {
+	poolToFeeRecipients := make(map[string]map[string]bool)
+	for _, expected := range expectedFeeRecipients {
+		poolName, address, found := strings.Cut(expected, ":")
+		if !found || poolName == "" {
+			return nil, errors.New(fmt.Sprintf("expected fee recipient must be pool:0xaddress: %s", expected))
+		}
+		if !common.IsHexAddress(address) || !strings.HasPrefix(address, "0x") {
+			return nil, errors.New(fmt.Sprintf("invalid address: %s", address))
+		}
+		if _, ok := poolToFeeRecipients[poolName]; !ok {
+			poolToFeeRecipients[poolName] = make(map[string]bool)
+		}
+		poolToFeeRecipients[poolName][strings.ToLower(address)] = true
+	}
+	return poolToFeeRecipients, nil
+}
+
+// Returns the withdrawal address of 0x01 and 0x02 withdrawal credentials as a
+// lowercase hex string. BLS (0x00) credentials have no address.
+func GetWithdrawalAddress(withdrawalCredentials []byte) (string, bool) {
+	if len(withdrawalCredentials) != 32 {
+		return "", false
+	}
+	if withdrawalCredentials[0] != 0x01 && withdrawalCredentials[0] != 0x02 {
+		return "", false
+	}
+	return hexutil.Encode(withdrawalCredentials[12:]), true
 }
@@ -2,6 +2,7 @@ package pools
 
 import (
 	"os"
+	"strings"
 	"testing"
 
 	log "github.com/sirupsen/logrus"
@@ -115,3 +116,126 @@ func TestReadEthstaValidatorsFile(t *testing.T) {
 		require.Equal(t, expectedKeysEthsta[i], key)
 	}
 }
+
+func Test_ParseValidators_DuplicateKeys(t *testing.T) {
+	key1 := "0xaddc693f9090db30a9aae27c047a95245f60313f574fb32729dd06341db55c743e64ba0709ee74181750b6da5f234b44"
+	key2 := "0xa59af0999c83f66de6cab8d833169fe10bce102d466c60c97c4e927210ac56e687c53feac8937c905cec5e87fccd72ce"
+	// key1 twice in pool_a, the second time in uppercase, and key2 in pool_a and pool_b
+	csv := `Validator Index,Public Key,Entity (Pool Name),Sub-Pool
+1,` + key1 + `,pool_a,
+1,0x` + strings.ToUpper(key1[2:]) + `,pool_a,
+2,` + key2 + `,pool_a,
+2,` + key2 + `,pool_b,`
+
+	poolValidatorKeys, validatorKeyToPool, _, err := ParseValidators(strings.NewReader(csv), "test", DuplicateKeysWarn)
+	require.NoError(t, err)
+	require.Len(t, poolValidatorKeys["pool_a"], 2)
+	require.Len(t, poolValidatorKeys["pool_b"], 1)
+	require.Equal(t, map[string]string{key1: "pool_a", key2: "pool_a"}, validatorKeyToPool)
+
+	poolValidatorKeys, validatorKeyToPool, _, err = ParseValidators(strings.NewReader(csv), "test", DuplicateKeysFirstWins)
+	require.NoError(t, err)
+	require.Len(t, poolValidatorKeys["pool_a"], 2)
+	require.Len(t, poolValidatorKeys["pool_b"], 0)
+	require.Equal(t, map[string]string{key1: "pool_a", key2: "pool_a"}, validatorKeyToPool)
+
+	_, _, _, err = ParseValidators(strings.NewReader(csv), "test", DuplicateKeysError)
+	require.EqualError(t, err, "duplicate keys in test: 1 keys in pools pool_a, pool_b; 1 keys repeated in pool pool_a")
+
+	_, _, _, err = ParseValidators(strings.NewReader(csv), "test", "last-wins")
+	require.Error(t, err)
+}
+
+func Test_ParseValidators_Indexes(t *testing.T) {
+	key1 := "0xaddc693f9090db30a9aae27c047a95245f60313f574fb32729dd06341db55c743e64ba0709ee74181750b6da5f234b44"
+	// pool_b only has indexes, 789 is repeated in pool_b and in pool_a
+	csv := `Validator Index,Public Key,Entity (Pool Name),Sub-Pool
+1,` + key1 + `,pool_a,
+123,,pool_a,
+456,,pool_b,
+789,,pool_b,sub
+789,,pool_b,
+789,,pool_a,`
+
+	poolValidatorKeys, validatorKeyToPool, poolValidatorIndexes, err := ParseValidators(strings.NewReader(csv), "test", DuplicateKeysFirstWins)
+	require.NoError(t, err)
+	require.Len(t, poolValidatorKeys["pool_a"], 1)
+	require.Contains(t, poolValidatorKeys, "pool_b")
+	require.Empty(t, poolValidatorKeys["pool_b"])
+	require.Equal(t, map[string]string{key1: "pool_a"}, validatorKeyToPool)
+	require.Equal(t, map[string][]uint64{"pool_a": {123}, "pool_b": {456, 789}}, poolValidatorIndexes)
+
+	_, _, _, err = ParseValidators(strings.NewReader(csv), "test", DuplicateKeysError)
+	require.EqualError(t, err, "duplicate keys in test: 1 keys in pools pool_b, pool_a; 1 keys repeated in pool pool_b")
+
+	_, _, _, err = ParseValidators(strings.NewReader("abc,,pool_a,"), "test", DuplicateKeysWarn)
+	require.ErrorContains(t, err, "a validator without key must have an index")
+}
+
+func Test_ParsePoolAddresses(t *testing.T) {
+	addressToPool, err := ParsePoolAddresses([]string{
+		"pool_a:0xB9D7934878B5FB9610B3fE8A5e441e8fad7E293f",
+		"pool_a:0x388c818ca8b9251b393131c08a736a67ccb19297",
+		"pool_b:0x4675c7e5baafbffbca748158becba61ef3b0a263",
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"0xb9d7934878b5fb9610b3fe8a5e441e8fad7e293f": "pool_a",
+		"0x388c818ca8b9251b393131c08a736a67ccb19297": "pool_a",
+		"0x4675c7e5baafbffbca748158becba61ef3b0a263": "pool_b",
+	}, addressToPool)
+
+	_, err = ParsePoolAddresses([]string{"0x4675c7e5baafbffbca748158becba61ef3b0a263"})
+	require.Error(t, err)
+	_, err = ParsePoolAddresses([]string{"pool_a:0x4675c7e5"})
+	require.Error(t, err)
+	_, err = ParsePoolAddresses([]string{
+		"pool_a:0x4675c7e5baafbffbca748158becba61ef3b0a263",
+		"pool_b:0x4675c7e5baafbffbca748158becba61ef3b0a263",
+	})
+	require.Error(t, err)
+}
+
+func Test_ParseExpectedFeeRecipients(t *testing.T) {
+	feeRecipients, err := ParseExpectedFeeRecipients([]string{
+		"pool_a:0xB9D7934878B5FB9610B3fE8A5e441e8fad7E293f",
+		"pool_a:0x388c818ca8b9251b393131c08a736a67ccb19297",
+		"pool_b:0x388c818ca8b9251b393131c08a736a67ccb19297",
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]map[string]bool{
+		"pool_a": {
+			"0xb9d7934878b5fb9610b3fe8a5e441e8fad7e293f": true,
+			"0x388c818ca8b9251b393131c08a736a67ccb19297": true,
+		},
+		"pool_b": {
+			"0x388c818ca8b9251b393131c08a736a67ccb19297": true,
+		},
+	}, feeRecipients)
+
+	_, err = ParseExpectedFeeRecipients([]string{"0x4675c7e5baafbffbca748158becba61ef3b0a263"})
+	require.Error(t, err)
+	_, err = ParseExpectedFeeRecipients([]string{"pool_a:0x4675c7e5"})
+	require.Error(t, err)
+}
+
+func Test_GetWithdrawalAddress(t *testing.T) {
+	credentials := make([]byte, 32)
+	credentials[0] = 0x01
+	credentials[12] = 0xab
+	credentials[31] = 0xcd
+
+	address, ok := GetWithdrawalAddress(credentials)
+	require.True(t, ok)
+	require.Equal(t, "0xab000000000000000000000000000000000000cd", address)
+
+	credentials[0] = 0x02
+	address, ok = GetWithdrawalAddress(credentials)
+	require.True(t, ok)
+	require.Equal(t, "0xab000000000000000000000000000000000000cd", address)
+
+	// BLS credentials
+	credentials[0] = 0x00
+	_, ok = GetWithdrawalAddress(credentials)
+	require.False(t, ok)
+}
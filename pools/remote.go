@@ -0,0 +1,86 @@
+package pools
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Validators csv served over http, e.g. from an internal registry or a bucket.
+// The ETag and Last-Modified of the last response are sent back so that the
+// file is only downloaded again when it changes.
+type RemoteValidatorsFile struct {
+	httpClient    *http.Client
+	url           string
+	authorization string
+	etag          string
+	lastModified  string
+	// What to do with keys listed more than once, see ParseValidators
+	duplicateKeysPolicy string
+}
+
+func IsRemoteFile(path string) bool {
+	return strings.HasPrefix(path, "https://") || strings.HasPrefix(path, "http://")
+}
+
+func NewRemoteValidatorsFile(url string, authorization string, duplicateKeysPolicy string) (*RemoteValidatorsFile, error) {
+	if !IsRemoteFile(url) {
+		return nil, errors.New("not an http url: " + url)
+	}
+	if strings.HasPrefix(url, "http://") {
+		log.Warn("Validators file is fetched over plain http: ", url)
+	}
+	return &RemoteValidatorsFile{
+		httpClient:    &http.Client{Timeout: 60 * time.Second},
+		url:           url,
+		authorization: authorization,
+
+		duplicateKeysPolicy: duplicateKeysPolicy,
+	}, nil
+}
+
+// Downloads and parses the validators file. If it did not change since the
+// last call, no keys are returned and changed is false.
+func (r *RemoteValidatorsFile) Fetch() (poolValidatorKeys map[string][][]byte, validatorKeyToPool map[string]string, poolValidatorIndexes map[string][]uint64, changed bool, err error) {
+	req, err := http.NewRequest(http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, nil, nil, false, errors.Wrap(err, "error creating request")
+	}
+	if r.authorization != "" {
+		req.Header.Set("Authorization", r.authorization)
+	}
+	if r.etag != "" {
+		req.Header.Set("If-None-Match", r.etag)
+	}
+	if r.lastModified != "" {
+		req.Header.Set("If-Modified-Since", r.lastModified)
+	}
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, nil, false, errors.Wrap(err, "error fetching validators file")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, nil, nil, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, nil, false, errors.New(fmt.Sprintf("non-200 status fetching validators file: %d", resp.StatusCode))
+	}
+
+	log.Info("Reading validators csv file: ", r.url)
+	poolValidatorKeys, validatorKeyToPool, poolValidatorIndexes, err = ParseValidators(resp.Body, r.url, r.duplicateKeysPolicy)
+	if err != nil {
+		return nil, nil, nil, false, err
+	}
+
+	// Only remembered once parsed, so a broken file is fetched again
+	r.etag = resp.Header.Get("ETag")
+	r.lastModified = resp.Header.Get("Last-Modified")
+	return poolValidatorKeys, validatorKeyToPool, poolValidatorIndexes, true, nil
+}
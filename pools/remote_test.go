@@ -0,0 +1,61 @@
+package pools
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RemoteValidatorsFile(t *testing.T) {
+	csv := `Validator Index,Public Key,Entity (Pool Name),Sub-Pool
+123456,0xaddc693f9090db30a9aae27c047a95245f60313f574fb32729dd06341db55c743e64ba0709ee74181750b6da5f234b44,pool_a,subpool1
+789012,0xa59af0999c83f66de6cab8d833169fe10bce102d466c60c97c4e927210ac56e687c53feac8937c905cec5e87fccd72ce,pool_b,subpool2`
+	etag := `"v1"`
+	nOfDownloads := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		nOfDownloads++
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(csv))
+	}))
+	defer server.Close()
+
+	require.False(t, IsRemoteFile("pools.csv"))
+	require.True(t, IsRemoteFile(server.URL))
+
+	remote, err := NewRemoteValidatorsFile(server.URL, "Bearer secret", DuplicateKeysWarn)
+	require.NoError(t, err)
+
+	poolValidatorKeys, validatorKeyToPool, _, changed, err := remote.Fetch()
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, 1, len(poolValidatorKeys["pool_a"]))
+	require.Equal(t, "pool_b", validatorKeyToPool["0xa59af0999c83f66de6cab8d833169fe10bce102d466c60c97c4e927210ac56e687c53feac8937c905cec5e87fccd72ce"])
+
+	// Not downloaded again until the etag changes
+	_, _, _, changed, err = remote.Fetch()
+	require.NoError(t, err)
+	require.False(t, changed)
+	require.Equal(t, 1, nOfDownloads)
+
+	etag = `"v2"`
+	_, _, _, changed, err = remote.Fetch()
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, 2, nOfDownloads)
+
+	unauthorized, err := NewRemoteValidatorsFile(server.URL, "", DuplicateKeysWarn)
+	require.NoError(t, err)
+	_, _, _, _, err = unauthorized.Fetch()
+	require.Error(t, err)
+}
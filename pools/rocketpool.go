@@ -0,0 +1,125 @@
+package pools
+
+import (
+	"context"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// RocketStorage contract, which holds the addresses of the rest of contracts
+var rocketStorageAddresses = map[string]common.Address{
+	"ethereum": common.HexToAddress("0x1d8f8f00cfa6758d7bE78336684788Fb0ee0Fa46"),
+}
+
+const rocketPoolAbi = `[
+	{"name":"getAddress","type":"function","stateMutability":"view","inputs":[{"name":"_key","type":"bytes32"}],"outputs":[{"name":"","type":"address"}]},
+	{"name":"getNodeMinipoolCount","type":"function","stateMutability":"view","inputs":[{"name":"_nodeAddress","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"name":"getNodeMinipoolAt","type":"function","stateMutability":"view","inputs":[{"name":"_nodeAddress","type":"address"},{"name":"_index","type":"uint256"}],"outputs":[{"name":"","type":"address"}]},
+	{"name":"getMinipoolPubkey","type":"function","stateMutability":"view","inputs":[{"name":"_minipoolAddress","type":"address"}],"outputs":[{"name":"","type":"bytes"}]}
+]`
+
+// Finds the validators of Rocket Pool node operators by querying the minipool
+// manager contract on the execution client
+type RocketPool struct {
+	caller          ethereum.ContractCaller
+	abi             abi.ABI
+	minipoolManager common.Address
+	nodeToPool      map[common.Address]string
+	// Minipools already read from each node, which are never removed
+	nOfMinipools map[common.Address]uint64
+}
+
+// Creates the source for the node operators given as lowercase address to pool
+func NewRocketPool(
+	caller ethereum.ContractCaller,
+	network string,
+	nodeToPool map[string]string) (*RocketPool, error) {
+
+	rocketStorage, ok := rocketStorageAddresses[network]
+	if !ok {
+		return nil, errors.New("rocket pool is not supported in network: " + network)
+	}
+	parsedAbi, err := abi.JSON(strings.NewReader(rocketPoolAbi))
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing rocket pool abi")
+	}
+
+	r := &RocketPool{
+		caller:       caller,
+		abi:          parsedAbi,
+		nodeToPool:   make(map[common.Address]string),
+		nOfMinipools: make(map[common.Address]uint64),
+	}
+	for node, poolName := range nodeToPool {
+		r.nodeToPool[common.HexToAddress(node)] = poolName
+	}
+
+	// Contract addresses are stored under keccak256("contract.address" + name)
+	key := crypto.Keccak256Hash([]byte("contract.address" + "rocketMinipoolManager"))
+	var minipoolManager common.Address
+	if err := r.call(rocketStorage, &minipoolManager, "getAddress", key); err != nil {
+		return nil, errors.Wrap(err, "error getting rocket pool minipool manager")
+	}
+	if minipoolManager == (common.Address{}) {
+		return nil, errors.New("rocket pool minipool manager not found")
+	}
+	r.minipoolManager = minipoolManager
+	return r, nil
+}
+
+// Returns the keys of the minipools created since the last call, per pool. On
+// error, the keys read so far are also returned since they won't be read again.
+func (r *RocketPool) GetNewValidatorKeys() (map[string][][]byte, error) {
+	poolValidatorKeys := make(map[string][][]byte)
+	for node, poolName := range r.nodeToPool {
+		var count *big.Int
+		if err := r.call(r.minipoolManager, &count, "getNodeMinipoolCount", node); err != nil {
+			return poolValidatorKeys, errors.Wrap(err, "error getting minipool count of "+node.Hex())
+		}
+
+		for index := r.nOfMinipools[node]; index < count.Uint64(); index++ {
+			var minipool common.Address
+			if err := r.call(r.minipoolManager, &minipool, "getNodeMinipoolAt", node, new(big.Int).SetUint64(index)); err != nil {
+				return poolValidatorKeys, errors.Wrap(err, "error getting minipool of "+node.Hex())
+			}
+			var pubKey []byte
+			if err := r.call(r.minipoolManager, &pubKey, "getMinipoolPubkey", minipool); err != nil {
+				return poolValidatorKeys, errors.Wrap(err, "error getting pubkey of minipool "+minipool.Hex())
+			}
+			// Minipools waiting for a deposit have no key yet, read again later
+			if len(pubKey) == 0 {
+				log.Warn("Minipool ", minipool.Hex(), " has no validator key yet")
+				break
+			}
+			poolValidatorKeys[poolName] = append(poolValidatorKeys[poolName], pubKey)
+			r.nOfMinipools[node] = index + 1
+		}
+	}
+	return poolValidatorKeys, nil
+}
+
+func (r *RocketPool) call(contract common.Address, result any, method string, args ...any) error {
+	data, err := r.abi.Pack(method, args...)
+	if err != nil {
+		return err
+	}
+	output, err := r.caller.CallContract(context.Background(), ethereum.CallMsg{
+		To:   &contract,
+		Data: data,
+	}, nil)
+	if err != nil {
+		return err
+	}
+	return r.abi.UnpackIntoInterface(result, method, output)
+}
+
+func (r *RocketPool) Name() string {
+	return "rocket pool"
+}
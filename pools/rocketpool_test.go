@@ -0,0 +1,94 @@
+package pools
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+// Fake minipool manager where each node has the given minipool keys
+type fakeRocketPool struct {
+	abi     abi.ABI
+	manager common.Address
+	keys    map[common.Address][][]byte
+}
+
+func (f *fakeRocketPool) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	method, err := f.abi.MethodById(call.Data[:4])
+	if err != nil {
+		return nil, err
+	}
+	args, err := method.Inputs.Unpack(call.Data[4:])
+	if err != nil {
+		return nil, err
+	}
+	switch method.Name {
+	case "getAddress":
+		return method.Outputs.Pack(f.manager)
+	case "getNodeMinipoolCount":
+		return method.Outputs.Pack(big.NewInt(int64(len(f.keys[args[0].(common.Address)]))))
+	case "getNodeMinipoolAt":
+		// The minipool address encodes the node and the index
+		node := args[0].(common.Address)
+		minipool := common.BytesToAddress(append(node.Bytes()[:19], byte(args[1].(*big.Int).Uint64())))
+		return method.Outputs.Pack(minipool)
+	case "getMinipoolPubkey":
+		minipool := args[0].(common.Address)
+		for node, keys := range f.keys {
+			if common.BytesToAddress(append(node.Bytes()[:19], minipool[19])) == minipool {
+				return method.Outputs.Pack(keys[minipool[19]])
+			}
+		}
+	}
+	return nil, ethereum.NotFound
+}
+
+func Test_RocketPool(t *testing.T) {
+	parsedAbi, err := abi.JSON(strings.NewReader(rocketPoolAbi))
+	require.NoError(t, err)
+
+	nodeA := common.HexToAddress("0x00000000000000000000000000000000000a0000")
+	nodeB := common.HexToAddress("0x00000000000000000000000000000000000b0000")
+	fake := &fakeRocketPool{
+		abi:     parsedAbi,
+		manager: common.HexToAddress("0x6d010c43d4e96d74c422f2e27370af48711b49bf"),
+		keys: map[common.Address][][]byte{
+			nodeA: {{0xa0}, {0xa1}},
+			nodeB: {{0xb0}},
+		},
+	}
+
+	rocketPool, err := NewRocketPool(fake, "ethereum", map[string]string{
+		strings.ToLower(nodeA.Hex()): "pool_a",
+		strings.ToLower(nodeB.Hex()): "pool_b",
+	})
+	require.NoError(t, err)
+	require.Equal(t, fake.manager, rocketPool.minipoolManager)
+
+	keys, err := rocketPool.GetNewValidatorKeys()
+	require.NoError(t, err)
+	require.Equal(t, map[string][][]byte{
+		"pool_a": {{0xa0}, {0xa1}},
+		"pool_b": {{0xb0}},
+	}, keys)
+
+	// Only new minipools are returned, and the ones without key are retried
+	fake.keys[nodeA] = append(fake.keys[nodeA], []byte{}, []byte{0xa3})
+	keys, err = rocketPool.GetNewValidatorKeys()
+	require.NoError(t, err)
+	require.Equal(t, 0, len(keys))
+
+	fake.keys[nodeA][2] = []byte{0xa2}
+	keys, err = rocketPool.GetNewValidatorKeys()
+	require.NoError(t, err)
+	require.Equal(t, map[string][][]byte{"pool_a": {{0xa2}, {0xa3}}}, keys)
+
+	_, err = NewRocketPool(fake, "gnosis", map[string]string{})
+	require.Error(t, err)
+}
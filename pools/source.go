@@ -0,0 +1,10 @@
+package pools
+
+// Source of validator keys that are found over time, e.g. from contracts or
+// apis, instead of being listed upfront in the key files
+type ValidatorSource interface {
+	Name() string
+	// Returns the keys found since the last call per pool. Keys that were
+	// already returned may be returned again.
+	GetNewValidatorKeys() (map[string][][]byte, error)
+}
@@ -0,0 +1,126 @@
+package pools
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var ssvNetworks = map[string]string{
+	"ethereum": "mainnet",
+}
+
+// Validators per page requested to the api
+const ssvPageSize = 100
+
+type ssvValidatorsResponse struct {
+	Validators []struct {
+		PublicKey string `json:"public_key"`
+		IsDeleted bool   `json:"is_deleted"`
+	} `json:"validators"`
+	Pagination struct {
+		Pages int `json:"pages"`
+	} `json:"pagination"`
+}
+
+// Finds the validators run by SSV operators using the SSV api. Since
+// validators can be moved between clusters, all of them are read every time.
+type SSV struct {
+	httpClient     *http.Client
+	url            string
+	network        string
+	operatorToPool map[uint64]string
+}
+
+// Parses operators defined as pool:id, e.g. pool_a:1234
+func ParseSSVOperators(ssvOperators []string) (map[uint64]string, error) {
+	operatorToPool := make(map[uint64]string)
+	for _, ssvOperator := range ssvOperators {
+		poolName, id, found := strings.Cut(ssvOperator, ":")
+		if !found || poolName == "" {
+			return nil, errors.New(fmt.Sprintf("ssv operator must be pool:id: %s", ssvOperator))
+		}
+		operatorId, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("invalid ssv operator id: %s", id))
+		}
+		if pool, ok := operatorToPool[operatorId]; ok && pool != poolName {
+			return nil, errors.New(fmt.Sprintf("ssv operator %d used by pools %s and %s", operatorId, pool, poolName))
+		}
+		operatorToPool[operatorId] = poolName
+	}
+	return operatorToPool, nil
+}
+
+func NewSSV(url string, network string, operatorToPool map[uint64]string) (*SSV, error) {
+	ssvNetwork, ok := ssvNetworks[network]
+	if !ok {
+		return nil, errors.New("ssv is not supported in network: " + network)
+	}
+	return &SSV{
+		httpClient:     &http.Client{Timeout: 60 * time.Second},
+		url:            url,
+		network:        ssvNetwork,
+		operatorToPool: operatorToPool,
+	}, nil
+}
+
+func (s *SSV) Name() string {
+	return "ssv"
+}
+
+// Returns the keys of the validators of each operator, per pool. A validator
+// runs on several operators, so it can be returned for more than one pool.
+func (s *SSV) GetNewValidatorKeys() (map[string][][]byte, error) {
+	poolValidatorKeys := make(map[string][][]byte)
+	for operatorId, poolName := range s.operatorToPool {
+		for page := 1; ; page++ {
+			validators, err := s.getValidators(operatorId, page)
+			if err != nil {
+				return poolValidatorKeys, errors.Wrap(err, fmt.Sprintf("error getting validators of ssv operator %d", operatorId))
+			}
+			for _, validator := range validators.Validators {
+				if validator.IsDeleted {
+					continue
+				}
+				pubKey, err := hex.DecodeString(strings.TrimPrefix(validator.PublicKey, "0x"))
+				if err != nil {
+					return poolValidatorKeys, errors.Wrap(err, "invalid validator key: "+validator.PublicKey)
+				}
+				poolValidatorKeys[poolName] = append(poolValidatorKeys[poolName], pubKey)
+			}
+			if page >= validators.Pagination.Pages {
+				break
+			}
+		}
+	}
+	return poolValidatorKeys, nil
+}
+
+func (s *SSV) getValidators(operatorId uint64, page int) (*ssvValidatorsResponse, error) {
+	resp, err := s.httpClient.Get(fmt.Sprintf("%s/api/v4/%s/validators/in_operator/%d?page=%d&perPage=%d",
+		s.url, s.network, operatorId, page, ssvPageSize))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(fmt.Sprintf("non-200 status: %d", resp.StatusCode))
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading response body")
+	}
+	var validators ssvValidatorsResponse
+	if err := json.Unmarshal(body, &validators); err != nil {
+		return nil, errors.Wrap(err, "error decoding validators")
+	}
+	return &validators, nil
+}
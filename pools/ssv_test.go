@@ -0,0 +1,47 @@
+package pools
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+const keyA = "0xaddc693f9090db30a9aae27c047a95245f60313f574fb32729dd06341db55c743e64ba0709ee74181750b6da5f234b44"
+const keyB = "0xa59af0999c83f66de6cab8d833169fe10bce102d466c60c97c4e927210ac56e687c53feac8937c905cec5e87fccd72ce"
+
+func Test_SSV(t *testing.T) {
+	operatorToPool, err := ParseSSVOperators([]string{"pool_a:1234"})
+	require.NoError(t, err)
+	require.Equal(t, map[uint64]string{1234: "pool_a"}, operatorToPool)
+	_, err = ParseSSVOperators([]string{"pool_a:x"})
+	require.Error(t, err)
+	_, err = ParseSSVOperators([]string{"pool_a:1", "pool_b:1"})
+	require.Error(t, err)
+
+	// One validator per page, plus a removed one
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v4/mainnet/validators/in_operator/1234", r.URL.Path)
+		switch r.URL.Query().Get("page") {
+		case "1":
+			fmt.Fprintf(w, `{"validators":[{"public_key":"%s","is_deleted":false}],"pagination":{"pages":2}}`, keyA[2:])
+		case "2":
+			fmt.Fprintf(w, `{"validators":[{"public_key":"%s","is_deleted":false},{"public_key":"%s","is_deleted":true}],"pagination":{"pages":2}}`, keyB[2:], keyA[2:])
+		}
+	}))
+	defer server.Close()
+
+	ssv, err := NewSSV(server.URL, "ethereum", operatorToPool)
+	require.NoError(t, err)
+	keys, err := ssv.GetNewValidatorKeys()
+	require.NoError(t, err)
+	require.Equal(t, map[string][][]byte{
+		"pool_a": {hexutil.MustDecode(keyA), hexutil.MustDecode(keyB)},
+	}, keys)
+
+	_, err = NewSSV(server.URL, "gnosis", operatorToPool)
+	require.Error(t, err)
+}
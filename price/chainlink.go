@@ -0,0 +1,89 @@
+package price
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/bilinearlabs/eth-metrics/execution"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+)
+
+// Chainlink USD price feeds of the native token of each network
+var chainlinkFeeds = map[string]common.Address{
+	// ETH / USD
+	"ethereum": common.HexToAddress("0x5f4eC3Df9cbd43714FE2740f5E3616155c5b8419"),
+}
+
+var (
+	// latestRoundData()
+	latestRoundDataSelector = common.FromHex("0xfeaf968c")
+	// decimals()
+	decimalsSelector = common.FromHex("0x313ce567")
+)
+
+// Reads the price from the Chainlink feed contract using the execution client
+type Chainlink struct {
+	client *execution.Client
+	feed   common.Address
+}
+
+func NewChainlink(config *config.Config) (*Chainlink, error) {
+	feed, ok := chainlinkFeeds[config.Network]
+	if !ok {
+		return nil, errors.New("no chainlink feed for network: " + config.Network)
+	}
+
+	if len(config.Eth1Addresses) == 0 {
+		return nil, errors.New("chainlink price provider requires an execution client, set --eth1address")
+	}
+	auth, err := execution.AuthFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	client, err := execution.NewClient(config.Eth1Addresses, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Chainlink{
+		client: client,
+		feed:   feed,
+	}, nil
+}
+
+func (c *Chainlink) GetPriceUsd() (float32, error) {
+	decimals, err := c.call(decimalsSelector)
+	if err != nil {
+		return 0, errors.Wrap(err, "error getting feed decimals")
+	}
+	roundData, err := c.call(latestRoundDataSelector)
+	if err != nil {
+		return 0, errors.Wrap(err, "error getting latest round data")
+	}
+	return DecodeLatestRoundData(roundData, decimals)
+}
+
+func (c *Chainlink) call(data []byte) ([]byte, error) {
+	return c.client.CallContract(context.Background(), ethereum.CallMsg{
+		To:   &c.feed,
+		Data: data,
+	}, nil)
+}
+
+// Returns the answer of latestRoundData, which is the second of its five
+// 32 bytes words, scaled by the decimals of the feed
+func DecodeLatestRoundData(roundData []byte, decimals []byte) (float32, error) {
+	if len(roundData) != 5*32 || len(decimals) != 32 {
+		return 0, errors.New("unexpected chainlink response length")
+	}
+	answer := new(big.Int).SetBytes(roundData[32:64])
+	if answer.Sign() <= 0 || roundData[32]&0x80 != 0 {
+		return 0, errors.New("invalid chainlink answer")
+	}
+	unit := new(big.Int).Exp(big.NewInt(10), new(big.Int).SetBytes(decimals), nil)
+	price, _ := new(big.Float).Quo(new(big.Float).SetInt(answer), new(big.Float).SetInt(unit)).Float32()
+	return price, nil
+}
@@ -0,0 +1,42 @@
+package price
+
+import (
+	"github.com/pkg/errors"
+	gecko "github.com/superoo7/go-gecko/v3"
+)
+
+var vc = []string{"usd", "eurr"}
+
+type CoinGecko struct {
+	client *gecko.Client
+	id     string
+}
+
+func NewCoinGecko(network string) (*CoinGecko, error) {
+	id := ""
+	if network == "ethereum" {
+		id = "ethereum"
+	} else if network == "gnosis" {
+		id = "gnosis"
+	} else {
+		return nil, errors.New("network not supported: " + network)
+	}
+
+	return &CoinGecko{
+		client: gecko.NewClient(nil),
+		id:     id,
+	}, nil
+}
+
+func (c *CoinGecko) GetPriceUsd() (float32, error) {
+	sp, err := c.client.SimplePrice([]string{c.id}, vc)
+	if err != nil {
+		return 0, errors.Wrap(err, "error getting price from coingecko")
+	}
+
+	price, ok := (*sp)[c.id]["usd"]
+	if !ok {
+		return 0, errors.New("usd price not found for " + c.id)
+	}
+	return price, nil
+}
@@ -7,25 +7,24 @@ import (
 	"github.com/bilinearlabs/eth-metrics/db"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
-	gecko "github.com/superoo7/go-gecko/v3"
 )
 
-var vc = []string{"usd", "eurr"}
-
 type Price struct {
-	database  *db.Database
-	coingecko *gecko.Client
-	config    *config.Config
+	database *db.Database
+	provider Provider
+	config   *config.Config
 }
 
 func NewPrice(dbPath string, config *config.Config) (*Price, error) {
 
-	cg := gecko.NewClient(nil)
+	provider, err := NewProvider(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create price provider")
+	}
 
 	var database *db.Database
-	var err error
 	if dbPath != "" {
-		database, err = db.New(dbPath)
+		database, err = db.NewWithOptions(dbPath, db.OptionsFromConfig(config))
 		if err != nil {
 			return nil, errors.Wrap(err, "could not create postgresql")
 		}
@@ -36,30 +35,19 @@ func NewPrice(dbPath string, config *config.Config) (*Price, error) {
 	}
 
 	return &Price{
-		database:  database,
-		coingecko: cg,
-		config:    config,
+		database: database,
+		provider: provider,
+		config:   config,
 	}, nil
 }
 
 func (p *Price) GetEthPrice() {
-	id := ""
-	if p.config.Network == "ethereum" {
-		id = "ethereum"
-	} else if p.config.Network == "gnosis" {
-		id = "gnosis"
-	} else {
-		log.Fatal("Network not supported: ", p.config.Network)
-	}
-
-	sp, err := p.coingecko.SimplePrice([]string{id}, vc)
+	ethPriceUsd, err := p.provider.GetPriceUsd()
 	if err != nil {
 		log.Error(err)
+		return
 	}
 
-	eth := (*sp)[id]
-	ethPriceUsd := eth["usd"]
-
 	logPrice(ethPriceUsd)
 
 	if p.database != nil {
@@ -0,0 +1,54 @@
+package price
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ToUsd(t *testing.T) {
+	// 1.5 ETH in gwei and in wei
+	require.InDelta(t, 3000.0, ToUsd(big.NewInt(1500000000), 9, 2000), 0.001)
+	require.InDelta(t, 3000.0, ToUsd(big.NewInt(1500000000000000000), 18, 2000), 0.001)
+	// Negative balances, e.g. penalties
+	require.InDelta(t, -20.0, ToUsd(big.NewInt(-10000000), 9, 2000), 0.001)
+	require.Equal(t, 0.0, ToUsd(nil, 9, 2000))
+}
+
+func Test_DecodeLatestRoundData(t *testing.T) {
+	roundData := common.FromHex(
+		"0000000000000000000000000000000000000000000000060000000000001234" +
+			"0000000000000000000000000000000000000000000000000000003a35294400" +
+			"0000000000000000000000000000000000000000000000000000000065a00000" +
+			"0000000000000000000000000000000000000000000000000000000065a00000" +
+			"0000000000000000000000000000000000000000000000060000000000001234")
+	decimals := common.LeftPadBytes([]byte{8}, 32)
+
+	// 0x3a35294400 = 250000000000 with 8 decimals
+	price, err := DecodeLatestRoundData(roundData, decimals)
+	require.NoError(t, err)
+	require.Equal(t, float32(2500), price)
+
+	_, err = DecodeLatestRoundData(roundData[:64], decimals)
+	require.Error(t, err)
+
+	// Negative answer
+	negative := make([]byte, len(roundData))
+	copy(negative, roundData)
+	for i := 32; i < 64; i++ {
+		negative[i] = 0xff
+	}
+	_, err = DecodeLatestRoundData(negative, decimals)
+	require.Error(t, err)
+}
+
+func Test_NewProvider_Testnet(t *testing.T) {
+	provider, err := NewProvider(&config.Config{Network: "hoodi", PriceProvider: "chainlink"})
+	require.NoError(t, err)
+	price, err := provider.GetPriceUsd()
+	require.NoError(t, err)
+	require.Equal(t, float32(0), price)
+}
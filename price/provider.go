@@ -0,0 +1,47 @@
+package price
+
+import (
+	"math/big"
+
+	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/bilinearlabs/eth-metrics/networks"
+	"github.com/pkg/errors"
+)
+
+// Source of the price in USD of the native token of the network
+type Provider interface {
+	GetPriceUsd() (float32, error)
+}
+
+// Price of the tokens of the testnets, which have no value
+type testnetPrice struct{}
+
+func (testnetPrice) GetPriceUsd() (float32, error) {
+	return 0, nil
+}
+
+func NewProvider(config *config.Config) (Provider, error) {
+	if network, err := networks.Get(config.Network); err == nil && network.Testnet {
+		return testnetPrice{}, nil
+	}
+	switch config.PriceProvider {
+	case "coingecko":
+		return NewCoinGecko(config.Network)
+	case "chainlink":
+		return NewChainlink(config)
+	default:
+		return nil, errors.New("price provider not supported: " + config.PriceProvider)
+	}
+}
+
+// Converts an amount with the given decimals (9 for gwei, 18 for wei) to USD
+func ToUsd(amount *big.Int, decimals int, priceUsd float32) float64 {
+	if amount == nil {
+		return 0
+	}
+	unit := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil))
+	value := new(big.Float).Quo(new(big.Float).SetInt(amount), unit)
+	value.Mul(value, big.NewFloat(float64(priceUsd)))
+	usd, _ := value.Float64()
+	return usd
+}
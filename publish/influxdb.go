@@ -0,0 +1,114 @@
+package publish
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const influxDBTimeout = 30 * time.Second
+
+// Writes the metrics of the pools to InfluxDB with its v2 line protocol api, a
+// point per pool and epoch at the time of the epoch
+type influxDBSink struct {
+	url        string
+	token      string
+	httpClient *http.Client
+}
+
+// Accepts influxdb://token@host:port/bucket?org=org, or influxdbs:// for https
+func newInfluxDBSink(address *url.URL) (Sink, error) {
+	if address.Host == "" {
+		return nil, errors.New("missing influxdb host")
+	}
+	bucket := strings.Trim(address.Path, "/")
+	if bucket == "" {
+		return nil, errors.New("missing influxdb bucket")
+	}
+	scheme := "http"
+	if address.Scheme == "influxdbs" {
+		scheme = "https"
+	}
+	host := address.Host
+	if address.Port() == "" {
+		host = address.Hostname() + ":8086"
+	}
+
+	query := url.Values{}
+	query.Set("org", address.Query().Get("org"))
+	query.Set("bucket", bucket)
+	query.Set("precision", "s")
+	writeUrl := url.URL{Scheme: scheme, Host: host, Path: "/api/v2/write", RawQuery: query.Encode()}
+
+	var token string
+	if address.User != nil {
+		token = address.User.Username()
+	}
+	return &influxDBSink{
+		url:        writeUrl.String(),
+		token:      token,
+		httpClient: &http.Client{Timeout: influxDBTimeout},
+	}, nil
+}
+
+func (s *influxDBSink) Write(epochTime time.Time, events []Event) error {
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(EncodeLineProtocol(epochTime, events)))
+	if err != nil {
+		return errors.Wrap(err, "could not create influxdb request")
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("User-Agent", "eth-metrics")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Token "+s.token)
+	}
+	return postAndCheck(s.httpClient, req)
+}
+
+// Encodes the events as lines of the ethmetrics_pool measurement, tagged by pool
+// and with the same fields as the remote write metrics plus the epoch
+func EncodeLineProtocol(epochTime time.Time, events []Event) []byte {
+	var lines bytes.Buffer
+	for _, event := range events {
+		samples := eventSamples(event)
+		names := make([]string, 0, len(samples))
+		for name := range samples {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		lines.WriteString("ethmetrics_pool,pool=")
+		lines.WriteString(escapeLineProtocolTag(event.PoolName))
+		lines.WriteString(" epoch=")
+		lines.WriteString(strconv.FormatUint(event.Epoch, 10))
+		lines.WriteString("i")
+		for _, name := range names {
+			// Not representable in the line protocol
+			if math.IsNaN(samples[name]) || math.IsInf(samples[name], 0) {
+				continue
+			}
+			lines.WriteString(",")
+			lines.WriteString(name)
+			lines.WriteString("=")
+			lines.WriteString(strconv.FormatFloat(samples[name], 'f', -1, 64))
+		}
+		lines.WriteString(" ")
+		lines.WriteString(strconv.FormatInt(epochTime.Unix(), 10))
+		lines.WriteString("\n")
+	}
+	return lines.Bytes()
+}
+
+// Tag values can't be empty and escape commas, equal signs and spaces
+func escapeLineProtocolTag(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, "=", `\=`, " ", `\ `).Replace(value)
+}
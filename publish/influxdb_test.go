@@ -0,0 +1,73 @@
+package publish
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_InfluxDBSink(t *testing.T) {
+	var body string
+	var request *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = r
+		data, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		body = string(data)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	address, err := url.Parse("influxdb://secret@" + server.Listener.Addr().String() + "/metrics?org=team")
+	require.NoError(t, err)
+	sink, err := newInfluxDBSink(address)
+	require.NoError(t, err)
+
+	epochTime := time.Unix(1700000000, 0)
+	err = sink.Write(epochTime, []Event{
+		NewEvent(schemas.ValidatorPerformanceMetrics{
+			Epoch:               100,
+			PoolName:            "pool a",
+			NOfActiveValidators: 10,
+		}, schemas.ProposalDutiesMetrics{}),
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "/api/v2/write", request.URL.Path)
+	require.Equal(t, "team", request.URL.Query().Get("org"))
+	require.Equal(t, "metrics", request.URL.Query().Get("bucket"))
+	require.Equal(t, "s", request.URL.Query().Get("precision"))
+	require.Equal(t, "Token secret", request.Header.Get("Authorization"))
+
+	require.True(t, strings.HasPrefix(body, `ethmetrics_pool,pool=pool\ a epoch=100i,active_validators=10,`))
+	require.True(t, strings.HasSuffix(body, " 1700000000\n"))
+	require.Equal(t, 1, strings.Count(body, "\n"))
+}
+
+func Test_EncodeLineProtocol(t *testing.T) {
+	lines := string(EncodeLineProtocol(time.Unix(1700000000, 0), []Event{
+		{Epoch: 1, PoolName: "a,b=c", AvgInclusionDelay: 1.5, MEVRewards: "0", ProposerTips: "0"},
+		{Epoch: 1, PoolName: "", MEVRewards: "0", ProposerTips: "0"},
+	}))
+	require.Contains(t, lines, `ethmetrics_pool,pool=a\,b\=c epoch=1i,`)
+	require.Contains(t, lines, ",avg_inclusion_delay=1.5,")
+	require.Contains(t, lines, "ethmetrics_pool,pool=unknown epoch=1i,")
+}
+
+func Test_NewInfluxDBSink(t *testing.T) {
+	address, _ := url.Parse("influxdbs://token@influx.example.com/bucket?org=org")
+	sink, err := newInfluxDBSink(address)
+	require.NoError(t, err)
+	require.Equal(t, "https://influx.example.com:8086/api/v2/write?bucket=bucket&org=org&precision=s", sink.(*influxDBSink).url)
+
+	address, _ = url.Parse("influxdb:///bucket")
+	_, err = newInfluxDBSink(address)
+	require.ErrorContains(t, err, "missing influxdb host")
+}
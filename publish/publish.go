@@ -0,0 +1,159 @@
+package publish
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Metrics of a pool in an epoch, as published to the broker. Balances are in
+// gwei and MEV rewards and tips in wei, as decimal strings since they can
+// exceed an int64.
+type Event struct {
+	Epoch                  uint64  `json:"epoch"`
+	PoolName               string  `json:"pool"`
+	ProcessingMode         string  `json:"processing_mode"`
+	ActiveValidators       uint64  `json:"active_validators"`
+	TotalVotes             uint64  `json:"total_votes"`
+	IncorrectSource        uint64  `json:"incorrect_source"`
+	IncorrectTarget        uint64  `json:"incorrect_target"`
+	IncorrectHead          uint64  `json:"incorrect_head"`
+	EffectiveBalance       int64   `json:"effective_balance"`
+	EffectiveBalanceChange int64   `json:"effective_balance_change"`
+	CompoundingValidators  uint64  `json:"compounding_validators"`
+	PartialWithdrawals     int64   `json:"partial_withdrawals"`
+	FullWithdrawals        int64   `json:"full_withdrawals"`
+	EarnedBalance          int64   `json:"earned_balance"`
+	LostBalance            int64   `json:"lost_balance"`
+	MEVRewards             string  `json:"mev_rewards"`
+	MEVPartial             bool    `json:"mev_partial"`
+	ProposerTips           string  `json:"proposer_tips"`
+	SyncCommitteeRewards   int64   `json:"sync_committee_rewards"`
+	ProposerRewards        int64   `json:"proposer_rewards"`
+	AvgInclusionDelay      float64 `json:"avg_inclusion_delay"`
+	SlashedValidators      uint64  `json:"slashed_validators"`
+	ScheduledProposals     int     `json:"scheduled_proposals"`
+	ProposedBlocks         int     `json:"proposed_blocks"`
+	MissedProposals        int     `json:"missed_proposals"`
+	EthPriceUsd            float32 `json:"eth_price_usd"`
+	EarnedUsd              float64 `json:"earned_usd"`
+}
+
+func NewEvent(performance schemas.ValidatorPerformanceMetrics, proposals schemas.ProposalDutiesMetrics) Event {
+	event := Event{
+		Epoch:                 performance.Epoch,
+		PoolName:              performance.PoolName,
+		ProcessingMode:        string(performance.ProcessingMode),
+		ActiveValidators:      performance.NOfActiveValidators,
+		TotalVotes:            performance.NOfTotalVotes,
+		IncorrectSource:       performance.NOfIncorrectSource,
+		IncorrectTarget:       performance.NOfIncorrectTarget,
+		IncorrectHead:         performance.NOfIncorrectHead,
+		MEVRewards:            "0",
+		MEVPartial:            performance.MEVPartial,
+		ProposerTips:          "0",
+		SyncCommitteeRewards:  performance.SyncCommitteeRewards,
+		ProposerRewards:       performance.ProposerRewards,
+		CompoundingValidators: performance.NOfCompoundingValidators,
+		AvgInclusionDelay:     performance.AvgInclusionDelay,
+		SlashedValidators:     performance.NOfSlashedValidators,
+		ScheduledProposals:    len(proposals.Scheduled),
+		ProposedBlocks:        len(proposals.Proposed),
+		MissedProposals:       len(proposals.Missed),
+		EthPriceUsd:           performance.EthPriceUsd,
+		EarnedUsd:             performance.EarnedUsd,
+	}
+	if performance.EffectiveBalance != nil {
+		event.EffectiveBalance = performance.EffectiveBalance.Int64()
+	}
+	if performance.EffectiveBalanceChange != nil {
+		event.EffectiveBalanceChange = performance.EffectiveBalanceChange.Int64()
+	}
+	if performance.PartialWithdrawals != nil {
+		event.PartialWithdrawals = performance.PartialWithdrawals.Int64()
+	}
+	if performance.FullWithdrawals != nil {
+		event.FullWithdrawals = performance.FullWithdrawals.Int64()
+	}
+	if performance.EarnedBalance != nil {
+		event.EarnedBalance = performance.EarnedBalance.Int64()
+	}
+	if performance.LosedBalance != nil {
+		event.LostBalance = performance.LosedBalance.Int64()
+	}
+	if performance.MEVRewards != nil {
+		event.MEVRewards = performance.MEVRewards.String()
+	}
+	if performance.ProposerTips != nil {
+		event.ProposerTips = performance.ProposerTips.String()
+	}
+	return event
+}
+
+// Sends the payloads to a topic of a message broker
+type broker interface {
+	publish(topic string, payloads [][]byte) error
+}
+
+// Brokers by the scheme of --publish-broker. New brokers are added here.
+var brokers = map[string]func(address *url.URL) (broker, error){
+	"nats": newNatsBroker,
+}
+
+type Publisher struct {
+	broker broker
+	topic  string
+}
+
+func NewPublisher(config *config.Config) (*Publisher, error) {
+	if config.PublishBroker == "" {
+		return &Publisher{}, nil
+	}
+	address, err := url.Parse(config.PublishBroker)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid publish broker")
+	}
+	newBroker, ok := brokers[address.Scheme]
+	if !ok {
+		schemes := make([]string, 0, len(brokers))
+		for scheme := range brokers {
+			schemes = append(schemes, scheme+"://")
+		}
+		return nil, errors.New("publish broker not supported: " + config.PublishBroker + ", expected " + strings.Join(schemes, "|"))
+	}
+	b, err := newBroker(address)
+	if err != nil {
+		return nil, err
+	}
+	return &Publisher{broker: b, topic: config.PublishTopic}, nil
+}
+
+// Returns true if a broker is configured
+func (p *Publisher) Enabled() bool {
+	return p.broker != nil
+}
+
+// Publishes one json message per event. Errors are logged and not returned,
+// since a failing broker shall not stop the metrics processing
+func (p *Publisher) Publish(events []Event) {
+	if !p.Enabled() || len(events) == 0 {
+		return
+	}
+	payloads := make([][]byte, 0, len(events))
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			log.WithFields(log.Fields{"Epoch": event.Epoch, "PoolName": event.PoolName}).Error("could not marshal event: ", err)
+			continue
+		}
+		payloads = append(payloads, payload)
+	}
+	if err := p.broker.publish(p.topic, payloads); err != nil {
+		log.WithField("Epoch", events[0].Epoch).Error("could not publish ", len(payloads), " events: ", err)
+	}
+}
@@ -0,0 +1,110 @@
+package publish
+
+import (
+	"bufio"
+	"encoding/json"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/stretchr/testify/require"
+)
+
+// Accepts a single connection and returns the CONNECT options and the
+// published messages by subject
+func fakeNatsServer(t *testing.T) (string, chan string, chan map[string][]string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	connects := make(chan string, 1)
+	messages := make(chan map[string][]string, 1)
+
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("INFO {\"server_id\":\"test\"}\r\n"))
+
+		received := make(map[string][]string)
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			switch fields[0] {
+			case "CONNECT":
+				connects <- strings.TrimSpace(strings.TrimPrefix(line, "CONNECT"))
+			case "PUB":
+				size, _ := strconv.Atoi(fields[2])
+				payload := make([]byte, size+2)
+				reader.Read(payload)
+				received[fields[1]] = append(received[fields[1]], string(payload[:size]))
+			case "PING":
+				conn.Write([]byte("PONG\r\n"))
+				messages <- received
+				return
+			}
+		}
+	}()
+	return listener.Addr().String(), connects, messages
+}
+
+func Test_PublishNats(t *testing.T) {
+	address, connects, messages := fakeNatsServer(t)
+
+	p, err := NewPublisher(&config.Config{
+		PublishBroker: "nats://user:secret@" + address,
+		PublishTopic:  "metrics.pools",
+	})
+	require.NoError(t, err)
+	require.True(t, p.Enabled())
+
+	p.Publish([]Event{
+		NewEvent(schemas.ValidatorPerformanceMetrics{
+			Epoch:         100,
+			PoolName:      "pool_a",
+			EarnedBalance: big.NewInt(500),
+			MEVRewards:    new(big.Int).Mul(big.NewInt(1e18), big.NewInt(10)),
+		}, schemas.ProposalDutiesMetrics{
+			Scheduled: []schemas.Duty{{}, {}},
+			Proposed:  []schemas.Duty{{}},
+			Missed:    []schemas.Duty{{}},
+		}),
+		NewEvent(schemas.ValidatorPerformanceMetrics{Epoch: 100, PoolName: "pool_b"}, schemas.ProposalDutiesMetrics{}),
+	})
+
+	var options map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(<-connects), &options))
+	require.Equal(t, "user", options["user"])
+	require.Equal(t, "secret", options["pass"])
+
+	received := <-messages
+	require.Len(t, received["metrics.pools"], 2)
+	var event map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(received["metrics.pools"][0]), &event))
+	require.Equal(t, "pool_a", event["pool"])
+	require.Equal(t, float64(100), event["epoch"])
+	require.Equal(t, float64(500), event["earned_balance"])
+	require.Equal(t, "10000000000000000000", event["mev_rewards"])
+	require.Equal(t, float64(2), event["scheduled_proposals"])
+	require.Equal(t, float64(1), event["missed_proposals"])
+}
+
+func Test_NewPublisher(t *testing.T) {
+	p, err := NewPublisher(&config.Config{})
+	require.NoError(t, err)
+	require.False(t, p.Enabled())
+	// Does nothing
+	p.Publish([]Event{{Epoch: 1}})
+
+	_, err = NewPublisher(&config.Config{PublishBroker: "kafka://localhost:9092"})
+	require.ErrorContains(t, err, "publish broker not supported")
+}
@@ -0,0 +1,169 @@
+package publish
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const remoteWriteTimeout = 30 * time.Second
+
+// Pushes the metrics of the pools to a Prometheus remote write endpoint, e.g.
+// Mimir or VictoriaMetrics. Samples have the time of the epoch instead of the
+// time they are sent, so that backfilled epochs land at their time.
+type RemoteWriter struct {
+	url        string
+	user       *url.Userinfo
+	httpClient *http.Client
+}
+
+func NewRemoteWriter(remoteWriteUrl string) (*RemoteWriter, error) {
+	address, err := url.Parse(remoteWriteUrl)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid remote write url")
+	}
+	if address.Scheme != "http" && address.Scheme != "https" {
+		return nil, errors.New("remote write url must be http or https: " + address.Redacted())
+	}
+	// Credentials are sent as basic auth
+	user := address.User
+	address.User = nil
+	return &RemoteWriter{
+		url:        address.String(),
+		user:       user,
+		httpClient: &http.Client{Timeout: remoteWriteTimeout},
+	}, nil
+}
+
+// Writes a sample per metric and pool at the given time
+func (w *RemoteWriter) Write(epochTime time.Time, events []Event) error {
+	body := snappy.Encode(nil, EncodeWriteRequest(epochTime, events))
+
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "could not create remote write request")
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "eth-metrics")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if w.user != nil {
+		pass, _ := w.user.Password()
+		req.SetBasicAuth(w.user.Username(), pass)
+	}
+
+	return postAndCheck(w.httpClient, req)
+}
+
+// Sends the request and fails if the status is not 2xx, with the start of the
+// response body as the error
+func postAndCheck(httpClient *http.Client, req *http.Request) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "could not send request")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return errors.New(fmt.Sprintf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(message))))
+	}
+	return nil
+}
+
+// Values of the metrics of an event, by name without the ethmetrics_pool_
+// prefix. MEV rewards and tips are converted to eth, losing some precision.
+func eventSamples(event Event) map[string]float64 {
+	return map[string]float64{
+		"active_validators":             float64(event.ActiveValidators),
+		"total_votes":                   float64(event.TotalVotes),
+		"incorrect_source":              float64(event.IncorrectSource),
+		"incorrect_target":              float64(event.IncorrectTarget),
+		"incorrect_head":                float64(event.IncorrectHead),
+		"effective_balance_gwei":        float64(event.EffectiveBalance),
+		"effective_balance_change_gwei": float64(event.EffectiveBalanceChange),
+		"compounding_validators":        float64(event.CompoundingValidators),
+		"partial_withdrawals_gwei":      float64(event.PartialWithdrawals),
+		"full_withdrawals_gwei":         float64(event.FullWithdrawals),
+		"earned_balance_gwei":           float64(event.EarnedBalance),
+		"lost_balance_gwei":             float64(event.LostBalance),
+		"mev_rewards_eth":               weiToEth(event.MEVRewards),
+		"proposer_tips_eth":             weiToEth(event.ProposerTips),
+		"sync_committee_rewards_gwei":   float64(event.SyncCommitteeRewards),
+		"proposer_rewards_gwei":         float64(event.ProposerRewards),
+		"avg_inclusion_delay":           event.AvgInclusionDelay,
+		"slashed_validators":            float64(event.SlashedValidators),
+		"scheduled_proposals":           float64(event.ScheduledProposals),
+		"proposed_blocks":               float64(event.ProposedBlocks),
+		"missed_proposals":              float64(event.MissedProposals),
+		"earned_usd":                    event.EarnedUsd,
+	}
+}
+
+func weiToEth(wei string) float64 {
+	value, ok := new(big.Float).SetString(wei)
+	if !ok {
+		return 0
+	}
+	eth, _ := value.Quo(value, big.NewFloat(1e18)).Float64()
+	return eth
+}
+
+// Encodes the samples of the events as a remote write WriteRequest protobuf,
+// with a time series per metric and pool:
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label { string name = 1; string value = 2; }
+//	Sample { double value = 1; int64 timestamp = 2; }
+func EncodeWriteRequest(epochTime time.Time, events []Event) []byte {
+	var request []byte
+	for _, event := range events {
+		samples := eventSamples(event)
+		names := make([]string, 0, len(samples))
+		for name := range samples {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			// Labels must be sorted by name
+			var series []byte
+			series = protowire.AppendTag(series, 1, protowire.BytesType)
+			series = protowire.AppendBytes(series, encodeLabel("__name__", "ethmetrics_pool_"+name))
+			series = protowire.AppendTag(series, 1, protowire.BytesType)
+			series = protowire.AppendBytes(series, encodeLabel("pool", event.PoolName))
+
+			var sample []byte
+			sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+			sample = protowire.AppendFixed64(sample, math.Float64bits(samples[name]))
+			sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+			sample = protowire.AppendVarint(sample, uint64(epochTime.UnixMilli()))
+			series = protowire.AppendTag(series, 2, protowire.BytesType)
+			series = protowire.AppendBytes(series, sample)
+
+			request = protowire.AppendTag(request, 1, protowire.BytesType)
+			request = protowire.AppendBytes(request, series)
+		}
+	}
+	return request
+}
+
+func encodeLabel(name string, value string) []byte {
+	var label []byte
+	label = protowire.AppendTag(label, 1, protowire.BytesType)
+	label = protowire.AppendString(label, name)
+	label = protowire.AppendTag(label, 2, protowire.BytesType)
+	label = protowire.AppendString(label, value)
+	return label
+}
@@ -0,0 +1,114 @@
+package publish
+
+import (
+	"io"
+	"math"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Returns the fields of a protobuf message by number, as raw bytes or numbers
+func decodeFields(t *testing.T, message []byte) map[protowire.Number][]interface{} {
+	fields := make(map[protowire.Number][]interface{})
+	for len(message) > 0 {
+		number, wireType, n := protowire.ConsumeTag(message)
+		require.GreaterOrEqual(t, n, 0)
+		message = message[n:]
+		switch wireType {
+		case protowire.BytesType:
+			value, n := protowire.ConsumeBytes(message)
+			require.GreaterOrEqual(t, n, 0)
+			fields[number] = append(fields[number], value)
+			message = message[n:]
+		case protowire.Fixed64Type:
+			value, n := protowire.ConsumeFixed64(message)
+			require.GreaterOrEqual(t, n, 0)
+			fields[number] = append(fields[number], value)
+			message = message[n:]
+		case protowire.VarintType:
+			value, n := protowire.ConsumeVarint(message)
+			require.GreaterOrEqual(t, n, 0)
+			fields[number] = append(fields[number], value)
+			message = message[n:]
+		default:
+			t.Fatalf("unexpected wire type %d", wireType)
+		}
+	}
+	return fields
+}
+
+func Test_RemoteWrite(t *testing.T) {
+	var body []byte
+	var headers http.Header
+	var user, pass string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		user, pass, _ = r.BasicAuth()
+		compressed, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		body, err = snappy.Decode(nil, compressed)
+		require.NoError(t, err)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	w, err := NewRemoteWriter("http://user:secret@" + server.Listener.Addr().String() + "/api/v1/write")
+	require.NoError(t, err)
+
+	epochTime := time.Unix(1700000000, 0)
+	err = w.Write(epochTime, []Event{
+		NewEvent(schemas.ValidatorPerformanceMetrics{
+			Epoch:               100,
+			PoolName:            "pool_a",
+			NOfActiveValidators: 10,
+			MEVRewards:          new(big.Int).Mul(big.NewInt(1e18), big.NewInt(2)),
+		}, schemas.ProposalDutiesMetrics{}),
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "snappy", headers.Get("Content-Encoding"))
+	require.Equal(t, "0.1.0", headers.Get("X-Prometheus-Remote-Write-Version"))
+	require.Equal(t, "user", user)
+	require.Equal(t, "secret", pass)
+
+	// A series per metric with its name and pool as labels
+	samples := make(map[string]float64)
+	for _, series := range decodeFields(t, body)[1] {
+		fields := decodeFields(t, series.([]byte))
+		labels := make(map[string]string)
+		for _, label := range fields[1] {
+			labelFields := decodeFields(t, label.([]byte))
+			labels[string(labelFields[1][0].([]byte))] = string(labelFields[2][0].([]byte))
+		}
+		require.Equal(t, "pool_a", labels["pool"])
+		sample := decodeFields(t, fields[2][0].([]byte))
+		require.Equal(t, uint64(epochTime.UnixMilli()), sample[2][0])
+		samples[labels["__name__"]] = math.Float64frombits(sample[1][0].(uint64))
+	}
+	require.Equal(t, float64(10), samples["ethmetrics_pool_active_validators"])
+	require.Equal(t, float64(2), samples["ethmetrics_pool_mev_rewards_eth"])
+	require.Len(t, samples, len(eventSamples(Event{})))
+}
+
+func Test_RemoteWrite_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "out of order sample", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	w, err := NewRemoteWriter(server.URL)
+	require.NoError(t, err)
+	err = w.Write(time.Now(), []Event{{Epoch: 1, PoolName: "pool_a"}})
+	require.ErrorContains(t, err, "status 400: out of order sample")
+
+	_, err = NewRemoteWriter("nats://localhost:4222")
+	require.ErrorContains(t, err, "must be http or https")
+}
@@ -0,0 +1,85 @@
+package publish
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Time series database where the metrics of the pools are written once each
+// epoch is processed, besides the database of eth-metrics
+type Sink interface {
+	Write(epochTime time.Time, events []Event) error
+}
+
+// Sinks by the scheme of --sink. New sinks are added here.
+var sinkSchemes = map[string]func(address *url.URL) (Sink, error){
+	"influxdb":   newInfluxDBSink,
+	"influxdbs":  newInfluxDBSink,
+	"postgres":   newTimescaleDBSink,
+	"postgresql": newTimescaleDBSink,
+}
+
+type namedSink struct {
+	name string
+	sink Sink
+}
+
+type Sinks struct {
+	sinks []namedSink
+}
+
+// Creates the sinks of --sink and --remote-write-url
+func NewSinks(config *config.Config) (*Sinks, error) {
+	sinks := &Sinks{}
+	for _, sinkUrl := range config.Sinks {
+		address, err := url.Parse(sinkUrl)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid sink")
+		}
+		newSink, ok := sinkSchemes[address.Scheme]
+		if !ok {
+			schemes := make([]string, 0, len(sinkSchemes))
+			for scheme := range sinkSchemes {
+				schemes = append(schemes, scheme+"://")
+			}
+			return nil, errors.New("sink not supported: " + address.Redacted() + ", expected " + strings.Join(schemes, "|"))
+		}
+		sink, err := newSink(address)
+		if err != nil {
+			return nil, err
+		}
+		sinks.sinks = append(sinks.sinks, namedSink{name: address.Scheme, sink: sink})
+	}
+
+	if config.RemoteWriteUrl != "" {
+		remoteWriter, err := NewRemoteWriter(config.RemoteWriteUrl)
+		if err != nil {
+			return nil, err
+		}
+		sinks.sinks = append(sinks.sinks, namedSink{name: "remote-write", sink: remoteWriter})
+	}
+	return sinks, nil
+}
+
+// Returns true if at least one sink is configured
+func (s *Sinks) Enabled() bool {
+	return len(s.sinks) > 0
+}
+
+// Writes the events to every sink. Errors are logged and not returned, since a
+// failing sink shall not stop the metrics processing
+func (s *Sinks) Write(epochTime time.Time, events []Event) {
+	if len(events) == 0 {
+		return
+	}
+	for _, sink := range s.sinks {
+		if err := sink.sink.Write(epochTime, events); err != nil {
+			log.WithField("Epoch", events[0].Epoch).Error("could not write to ", sink.name, " sink: ", err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package report
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/bilinearlabs/eth-metrics/db"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LastPeriod(t *testing.T) {
+	// A tuesday
+	now := time.Date(2024, 1, 16, 10, 0, 0, 0, time.UTC)
+	start, end := lastPeriod(Daily, now)
+	require.Equal(t, "2024-01-15", start)
+	require.Equal(t, "2024-01-16", end)
+	start, end = lastPeriod(Weekly, now)
+	require.Equal(t, "2024-01-08", start)
+	require.Equal(t, "2024-01-15", end)
+
+	// Sundays are the end of the week
+	start, _ = lastPeriod(Weekly, time.Date(2024, 1, 21, 23, 0, 0, 0, time.UTC))
+	require.Equal(t, "2024-01-08", start)
+	start, _ = lastPeriod(Weekly, time.Date(2024, 1, 22, 0, 0, 0, 0, time.UTC))
+	require.Equal(t, "2024-01-15", start)
+}
+
+func Test_NewPoolSummary(t *testing.T) {
+	summary := NewPoolSummary(schemas.PoolPeriodMetrics{
+		PoolName:                 "pool_a",
+		NOfTotalVotes:            100,
+		NOfIncorrectSource:       2,
+		NOfIncorrectTarget:       3,
+		NOfIncorrectHead:         4,
+		EarnedBalanceGwei:        3000000000,
+		LostBalanceGwei:          -1000000000,
+		SyncCommitteeRewardsGwei: 500000000,
+		MEVRewardsWei:            1e18,
+		NOfScheduledBlocks:       3,
+		NOfProposedBlocks:        2,
+	})
+	require.InDelta(t, 0.98, summary.Uptime, 1e-9)
+	require.InDelta(t, 0.97, summary.Effectiveness, 1e-9)
+	require.Equal(t, uint64(1), summary.MissedBlocks)
+	require.Equal(t, int64(2000000000), summary.AttestationRewardsGwei)
+	require.InDelta(t, 3.5, summary.TotalRewardsEth(), 1e-9)
+
+	summary = NewPoolSummary(schemas.PoolPeriodMetrics{PoolName: "pool_b"})
+	require.True(t, math.IsNaN(summary.Uptime))
+	report := Report{Period: Daily, Start: "2024-01-15", Pools: []PoolSummary{summary}}
+	require.Contains(t, report.Markdown(), "| pool_b | 0 | - | - | 0 | 0 |")
+}
+
+func Test_SendDue(t *testing.T) {
+	database, err := db.New(filepath.Join(t.TempDir(), "db.db"))
+	require.NoError(t, err)
+	require.NoError(t, database.CreateTables())
+
+	store := func(epoch uint64, epochTime time.Time, proposed uint64) {
+		require.NoError(t, database.StoreValidatorPerformance(schemas.ValidatorPerformanceMetrics{
+			Time:                   epochTime,
+			Epoch:                  epoch,
+			PoolName:               "pool_a",
+			NOfActiveValidators:    10,
+			NOfTotalVotes:          10,
+			NOfIncorrectSource:     1,
+			EarnedBalance:          big.NewInt(2000000000),
+			LosedBalance:           big.NewInt(-1000000000),
+			EffectiveBalance:       big.NewInt(0),
+			MEVRewards:             big.NewInt(0),
+			ProposerTips:           big.NewInt(0),
+			EffectiveBalanceChange: big.NewInt(0),
+			PartialWithdrawals:     big.NewInt(0),
+			FullWithdrawals:        big.NewInt(0),
+		}))
+		require.NoError(t, database.StoreProposalDuties(epoch, "pool_a", 1, proposed, 0, 0, 0))
+	}
+	store(100, time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC), 1)
+	store(101, time.Date(2024, 1, 15, 10, 6, 24, 0, time.UTC), 0)
+
+	var slackText string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		slackText = payload["text"]
+	}))
+	defer server.Close()
+
+	var emails []string
+	dir := t.TempDir()
+	cfg := &config.Config{
+		Reports:            []string{Daily},
+		ReportDir:          dir,
+		ReportSlackWebhook: server.URL,
+		ReportEmailFrom:    "metrics@example.com",
+		ReportEmailTo:      []string{"ops@example.com"},
+	}
+	r := &Reporter{
+		config:     cfg,
+		database:   database,
+		httpClient: http.DefaultClient,
+		smtpUrl:    &url.URL{Scheme: "smtp", Host: "localhost:25"},
+		sendMail: func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+			require.Equal(t, "localhost:25", addr)
+			require.Equal(t, []string{"ops@example.com"}, to)
+			emails = append(emails, string(msg))
+			return nil
+		},
+	}
+
+	// The day is not complete until an epoch of the next day is stored
+	now := time.Date(2024, 1, 16, 0, 5, 0, 0, time.UTC)
+	require.NoError(t, r.SendDue(now))
+	require.Empty(t, emails)
+
+	store(102, time.Date(2024, 1, 16, 0, 1, 0, 0, time.UTC), 1)
+	require.NoError(t, r.SendDue(now))
+	require.Len(t, emails, 1)
+	require.Contains(t, emails[0], "Subject: [eth-metrics] Daily report of 2024-01-15\r\n")
+	row := "| pool_a | 10 | 90.00% | 96.67% | 1 | 1 | 2.0000 | 0.0000 | 0.0000 | 0.0000 | 0.0000 | 2.0000 | 0.00 |"
+	require.Contains(t, emails[0], row)
+	require.Contains(t, slackText, "*Daily report of 2024-01-15*\n```\n")
+	require.Contains(t, slackText, row)
+
+	markdown, err := os.ReadFile(filepath.Join(dir, "daily-2024-01-15.md"))
+	require.NoError(t, err)
+	require.Contains(t, string(markdown), "# Daily report of 2024-01-15\n")
+	require.Contains(t, string(markdown), row)
+
+	// Not sent twice
+	require.NoError(t, r.SendDue(now.Add(time.Hour)))
+	require.Len(t, emails, 1)
+}
@@ -18,9 +18,9 @@ type ValidatorPerformanceMetrics struct {
 	NOfValsWithLessBalance uint64 // TODO: Deprecate, same as array length
 	EarnedBalance          *big.Int
 	LosedBalance           *big.Int
-	MissedAttestationsKeys []string // TODO: Deprecate in favor of IndexesMissedAtt
 	LostBalanceKeys        []string // TODO: Depercate in favor of IndexesLessBalance
 	IndexesMissedAtt       []uint64
+	MissedAttestations     []MissedAttestation
 	IndexesLessBalance     []uint64
 	TotalBalance           *big.Int
 	EffectiveBalance       *big.Int
@@ -28,6 +28,64 @@ type ValidatorPerformanceMetrics struct {
 	DeltaEpochBalance      *big.Int
 	MEVRewards             *big.Int
 	ProposerTips           *big.Int
+	AvgInclusionDelay      float64
+	MaxInclusionDelay      uint64
+	NOfSlashedValidators   uint64
+	AttestationRewards     AttestationRewards
+	ProcessingMode         ProcessingMode
+	EthPriceUsd            float32
+	EarnedUsd              float64
+	MEVRewardsUsd          float64
+	// Some relay was skipped, so MEVRewards may be lower than the actual ones
+	MEVPartial bool
+	// Gwei earned or lost in the sync committee, not included in EarnedBalance
+	// and LosedBalance
+	SyncCommitteeRewards int64
+	// Gwei earned by proposing blocks, also not included in EarnedBalance
+	ProposerRewards int64
+	// Change of EffectiveBalance since the previous epoch, e.g. by deposits,
+	// consolidations or compounding rewards
+	EffectiveBalanceChange   *big.Int
+	NOfCompoundingValidators uint64
+	// Gwei withdrawn in the epoch, split into the skimming and withdrawal
+	// requests of active validators and the full withdrawals of exited ones
+	PartialWithdrawals *big.Int
+	FullWithdrawals    *big.Int
+	NOfFullWithdrawals uint64
+}
+
+// Tells whether the metrics were computed from finalized data or from data
+// close to the head, which can still change with a reorg
+type ProcessingMode string
+
+const (
+	ProcessingModeHead      ProcessingMode = "head"
+	ProcessingModeFinalized ProcessingMode = "finalized"
+)
+
+// Attestation rewards of a pool as reported by the beacon node, in gwei. Source
+// and target are negative when missed. Ideal is what the pool would have earned
+// with perfect participation.
+type AttestationRewards struct {
+	Head           int64
+	Target         int64
+	Source         int64
+	InclusionDelay int64
+	Inactivity     int64
+	Ideal          int64
+}
+
+type ValidatorMetrics struct {
+	Time                time.Time
+	PoolName            string
+	Epoch               uint64
+	ValIndex            uint64
+	PubKey              string
+	BalanceDelta        *big.Int
+	MissedSource        bool
+	MissedTarget        bool
+	MissedHead          bool
+	AttestationIncluded bool
 }
 
 type ValidatorStatusMetrics struct {
@@ -62,10 +120,106 @@ type ProposalDutiesMetrics struct {
 	Missed    []Duty
 }
 
+// Payload delivered by a relay to a tracked validator. If the same payload was
+// delivered by more than one relay, all of them are listed.
+type RelayPayload struct {
+	Slot                 uint64
+	Relays               []string
+	BuilderPubKey        string
+	ProposerPubKey       string
+	ProposerFeeRecipient string
+	Value                *big.Int
+}
+
+// Bid received by a relay from a builder for a slot, in wei
+type RelayBid struct {
+	Slot          uint64
+	Relay         string
+	BuilderPubKey string
+	Value         *big.Int
+}
+
+// MEV left on the table by a vanilla block of a tracked validator: the best bid
+// the relays had for the slot minus the tips the block paid, in wei. Missed is
+// 0 if the tips were higher.
+type MissedMEV struct {
+	Epoch         uint64
+	Slot          uint64
+	ProposerIndex uint64
+	PoolName      string
+	Relay         string
+	BuilderPubKey string
+	BestBid       *big.Int
+	Tip           *big.Int
+	Missed        *big.Int
+}
+
+// When the block of a tracked validator was first seen by the beacon node,
+// with the delay from the start of the slot. Late blocks risk being reorged.
+type ProposalTiming struct {
+	Epoch         uint64
+	Slot          uint64
+	ProposerIndex uint64
+	PoolName      string
+	SeenAt        time.Time
+	DelayMs       int64
+	Late          bool
+}
+
+// Rewards of a block proposed by a tracked validator. The consensus reward is
+// in gwei, the execution tip and MEV reward in wei. The tip is only computed
+// for blocks without MEV reward.
+type Proposal struct {
+	Epoch         uint64
+	Slot          uint64
+	ProposerIndex uint64
+	PoolName      string
+	// Consensus rewards in gwei, nil if unknown. Slashings include the proposer
+	// and attester slashings.
+	ConsensusReward     *big.Int
+	AttestationsReward  *big.Int
+	SyncAggregateReward *big.Int
+	SlashingsReward     *big.Int
+	ExecutionTip        *big.Int
+	MEVReward           *big.Int
+	Relays              []string
+	BuilderPubKey       string
+	FeeRecipient        string
+	// Built locally instead of via MEV-Boost
+	Vanilla bool
+}
+
+// Payout of a MEV block proposed by a tracked validator. Reported is the value
+// the relays reported for the payload and Paid what the fee recipient actually
+// received in the block, both in wei. Shortfall is what is missing, 0 if it
+// was paid in full.
+type MEVPayout struct {
+	Epoch         uint64
+	Slot          uint64
+	ProposerIndex uint64
+	PoolName      string
+	FeeRecipient  string
+	Relays        []string
+	BuilderPubKey string
+	Reported      *big.Int
+	Paid          *big.Int
+	Shortfall     *big.Int
+}
+
+type MissedReason string
+
+const (
+	// No block was seen for the slot
+	MissedEmptySlot MissedReason = "empty"
+	// The block was proposed but is not part of the canonical chain
+	MissedOrphaned MissedReason = "orphaned"
+)
+
 type Duty struct {
-	ValIndex uint64
-	Slot     uint64
-	Graffiti string
+	ValIndex     uint64
+	Slot         uint64
+	Graffiti     string
+	MissedReason MissedReason
 }
 
 type NetworkStats struct {
@@ -74,4 +228,460 @@ type NetworkStats struct {
 	NOfActiveValidators  uint64
 	NOfExitedValidators  uint64
 	NOfSlashedValidators uint64
+	// Votes missed by the active validators, counted as for the pools
+	NOfIncorrectSource uint64
+	NOfIncorrectTarget uint64
+	NOfIncorrectHead   uint64
+	// Share of the active validators with a correct vote
+	SourceParticipation float64
+	TargetParticipation float64
+	HeadParticipation   float64
+	// Correct votes weighted as their rewards, from 0 to 1
+	AttestationEffectiveness float64
+	NOfScheduledBlocks       uint64
+	NOfProposedBlocks        uint64
+	// Proposed blocks over the scheduled ones
+	ProposalRate float64
+	// Blobs of the blocks of the epoch, since deneb. The utilization is over the
+	// max blob gas of the blocks, and the base fee is the one of the last block,
+	// in wei
+	NOfBlobs        uint64
+	BlobGasUsed     uint64
+	BlobUtilization float64
+	BlobBaseFee     *big.Int
+}
+
+// Blobs included in a block and the fee burned for them, in wei. Blob fees are
+// paid by the blob transactions and burned, the proposer only gets their tips.
+type BlockBlobs struct {
+	Epoch         uint64
+	Slot          uint64
+	ProposerIndex uint64
+	PoolName      string
+	NOfBlobs      uint64
+	BlobGasUsed   uint64
+	MaxBlobGas    uint64
+	ExcessBlobGas uint64
+	BlobBaseFee   *big.Int
+	BlobFee       *big.Int
+}
+
+// Effectiveness of a pool, or of the whole network if PoolName is empty, in an
+// epoch. Each component is the share of its duties that were done well, from 0
+// to 1, and nil if there were none of them in the epoch.
+type Effectiveness struct {
+	Time     time.Time
+	Epoch    uint64
+	PoolName string
+	// Correct source, target and head votes, weighted as their rewards
+	Attestation *float64
+	// One over the average inclusion delay of the votes
+	Inclusion *float64
+	// Proposed blocks over the scheduled ones
+	Proposal *float64
+	// Slots signed by the sync committee members over their slots
+	SyncCommittee *float64
+	// Components weighted as their rewards, from 0 to 100
+	Score *float64
+	// Pool minus network, nil for the network itself or if the network or the
+	// pool had no such duties
+	SourceParticipationDelta *float64
+	TargetParticipationDelta *float64
+	HeadParticipationDelta   *float64
+	AttestationDelta         *float64
+	ProposalDelta            *float64
+	ScoreDelta               *float64
+}
+
+// Activation and exit queues of the network. Balances are in gwei and the
+// wait times are estimated in epochs for a validator joining the queue now
+// Block of a pool paying to a fee recipient that is not an expected one. For
+// MEV blocks, the recipient of the payout of the builder is checked.
+type FeeRecipientViolation struct {
+	Epoch         uint64
+	Slot          uint64
+	ProposerIndex uint64
+	PoolName      string
+	FeeRecipient  string
+	Expected      []string
+	MEV           bool
+}
+
+// Requests made to a relay while getting the rewards of an epoch, and the
+// payloads it delivered to the tracked validators
+type RelayStats struct {
+	Epoch        uint64
+	Relay        string
+	NOfRequests  uint64
+	NOfErrors    uint64
+	NOfPayloads  uint64
+	ValueWei     *big.Int
+	AvgLatencyMs float64
+	// The relay failed after the retries and its payloads are missing
+	Skipped bool
+}
+
+type NetworkQueues struct {
+	Time                 time.Time
+	Epoch                uint64
+	ChurnLimitGwei       uint64
+	NOfPendingDeposits   uint64
+	PendingDepositsGwei  uint64
+	NOfExitingValidators uint64
+	ExitingBalanceGwei   uint64
+	EntryWaitEpochs      uint64
+	ExitWaitEpochs       uint64
+}
+
+type SlashingType string
+
+const (
+	ProposerSlashing SlashingType = "proposer"
+	AttesterSlashing SlashingType = "attester"
+)
+
+// Vote of an attestation that was not included on time. If none of the votes
+// was, the attestation is recorded as missed instead of once per vote.
+type MissType string
+
+const (
+	MissedSource   MissType = "source"
+	MissedTarget   MissType = "target"
+	MissedHead     MissType = "head"
+	MissedAllVotes MissType = "missed"
+)
+
+type MissedAttestation struct {
+	Epoch    uint64
+	PoolName string
+	ValIndex uint64
+	MissType MissType
+}
+
+// Group of pools whose metrics are rolled up, e.g. an operator or a cluster
+type PoolGroup struct {
+	Name string
+	// Group that contains this one, empty at the top of the hierarchy
+	Parent string
+	// Pools and groups directly in the group
+	Members []string
+	// Pools of the group and of its nested groups
+	Pools []string
+}
+
+// Validator that joins or leaves a pool from the given epoch on, e.g. when it is
+// added or removed at runtime, so that earlier epochs keep the pool it had
+type PoolMembershipChange struct {
+	Epoch    uint64
+	PoolName string
+	PubKey   string
+	Action   MembershipAction
+	Source   MembershipSource
+	Time     time.Time
+}
+
+type MembershipAction string
+
+const (
+	MembershipJoin  MembershipAction = "join"
+	MembershipLeave MembershipAction = "leave"
+)
+
+// Payout statement of a pool over a range of epochs. Amounts are in wei, summed
+// exactly from the metrics of each epoch.
+type PoolStatement struct {
+	PoolName  string
+	FromEpoch uint64
+	ToEpoch   uint64
+	NOfEpochs uint64
+	// Attestation rewards before penalties, plus the net rewards of the sync
+	// committee and of the proposed blocks
+	ConsensusRewards   *big.Int
+	Penalties          *big.Int
+	ProposerTips       *big.Int
+	MEVRewards         *big.Int
+	PartialWithdrawals *big.Int
+	FullWithdrawals    *big.Int
+	// Some relay was skipped in some epoch, so MEVRewards may be lower
+	MEVPartial bool
+}
+
+// Rewards minus penalties, in wei
+func (s PoolStatement) Total() *big.Int {
+	total := new(big.Int).Sub(s.ConsensusRewards, s.Penalties)
+	total.Add(total, s.ProposerTips)
+	return total.Add(total, s.MEVRewards)
+}
+
+// Alert as stored when fired, even if it was silenced or repeated. Kind and
+// severity are those of the alerts package.
+type SentAlert struct {
+	Time     time.Time
+	Epoch    uint64
+	PoolName string
+	Kind     string
+	Severity string
+	Message  string
+}
+
+// Period in which the validators of a pool are expected to miss duties, e.g.
+// while its nodes are upgraded. Windows of the config have no id.
+type MaintenanceWindow struct {
+	Id       uint64    `json:"id"`
+	PoolName string    `json:"pool"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Comment  string    `json:"comment"`
+}
+
+// SLA of a pool over a day, week, month or quarter, from the epochs that were
+// not in maintenance. Rates are in percent, nil without duties, and targets are
+// nil if not set.
+type PoolSLA struct {
+	Period string
+	// First day of the period, e.g. 2024-01-15
+	Start                 string
+	PoolName              string
+	NOfEpochs             uint64
+	NOfMaintenanceEpochs  uint64
+	NOfAttestationDuties  uint64
+	NOfMissedAttestations uint64
+	NOfScheduledBlocks    uint64
+	NOfProposedBlocks     uint64
+	AttestationUptime     *float64
+	ProposalSuccess       *float64
+	AttestationTarget     *float64
+	ProposalTarget        *float64
+	AttestationBreached   bool
+	ProposalBreached      bool
+}
+
+type KeyIssueKind string
+
+const (
+	// Not in the beacon state, e.g. a typo or a key that was never deposited
+	KeyNotFound KeyIssueKind = "not_found"
+	// Exited, so it has no duties anymore
+	KeyExited KeyIssueKind = "exited"
+)
+
+// Tracked validator key that is not counted as validating
+type KeyIssue struct {
+	Epoch    uint64
+	PoolName string
+	PubKey   string
+	Kind     KeyIssueKind
+	// Nil if the key was not found
+	ValIndex *uint64
+}
+
+type UpcomingDutyKind string
+
+const (
+	UpcomingProposal      UpcomingDutyKind = "proposal"
+	UpcomingSyncCommittee UpcomingDutyKind = "sync_committee"
+)
+
+// Block proposal or sync committee membership of a tracked validator that is
+// yet to come or in progress
+type UpcomingDuty struct {
+	Kind     UpcomingDutyKind `json:"kind"`
+	PoolName string           `json:"pool"`
+	ValIndex uint64           `json:"validator_index"`
+	// Slot of the proposal, or first slot of the sync committee period
+	Slot uint64 `json:"slot"`
+	// Last slot of the sync committee period, the same as Slot for proposals
+	LastSlot uint64 `json:"last_slot"`
+	// Start of Slot
+	Time time.Time `json:"time"`
+}
+
+// Metrics of a pool in an epoch, to show the latest ones
+type PoolEpochSummary struct {
+	PoolName           string
+	Epoch              uint64
+	NOfValidatingKeys  uint64
+	NOfIncorrectSource uint64
+	NOfIncorrectTarget uint64
+	NOfIncorrectHead   uint64
+	// Rewards minus penalties of the attestations, the sync committee and the
+	// proposals
+	RewardsGwei        int64
+	NOfScheduledBlocks uint64
+	NOfProposedBlocks  uint64
+}
+
+// Metrics of a pool summed over a day or a week. Wei are floats, as summed by
+// the views.
+type PoolPeriodMetrics struct {
+	PoolName                 string
+	NOfEpochs                uint64
+	AvgActiveValidators      float64
+	NOfTotalVotes            uint64
+	NOfIncorrectSource       uint64
+	NOfIncorrectTarget       uint64
+	NOfIncorrectHead         uint64
+	EarnedBalanceGwei        int64
+	LostBalanceGwei          int64
+	SyncCommitteeRewardsGwei int64
+	ProposerRewardsGwei      int64
+	MEVRewardsWei            float64
+	ProposerTipsWei          float64
+	ValueUsd                 float64
+	NOfScheduledBlocks       uint64
+	NOfProposedBlocks        uint64
+}
+
+// Where a change of membership comes from. Changes made with the admin api
+// take precedence over the validators files and sources, whose changes are
+// only recorded.
+type MembershipSource string
+
+const (
+	MembershipSourceApi    MembershipSource = "api"
+	MembershipSourceConfig MembershipSource = "config"
+)
+
+// Result of processing the metrics of a pool in an epoch. Error is empty if it
+// succeeded
+type ProcessingStatus struct {
+	Epoch    uint64
+	PoolName string
+	Error    string
+}
+
+type Slashing struct {
+	Epoch        uint64
+	Slot         uint64
+	ValIndex     uint64
+	PoolName     string
+	SlashingType SlashingType
+}
+
+// Voluntary exit included in a block. ExitEpoch is the epoch signed in the
+// message, from which the exit is valid.
+type VoluntaryExit struct {
+	Epoch     uint64
+	Slot      uint64
+	ValIndex  uint64
+	PoolName  string
+	ExitEpoch uint64
+}
+
+// Deposit included in an execution block, from the deposit contract logs before
+// electra and from the deposit requests of the block since. Amount is in gwei
+// and Timestamp is the time of the block in seconds.
+type Deposit struct {
+	Epoch                 uint64
+	Slot                  uint64
+	BlockNumber           uint64
+	Timestamp             uint64
+	DepositIndex          uint64
+	PoolName              string
+	Pubkey                string
+	WithdrawalCredentials string
+	Amount                uint64
+}
+
+// Change of the withdrawal credentials of a validator from a BLS key to an
+// execution address, included in a block
+type BLSChange struct {
+	Epoch            uint64
+	Slot             uint64
+	ValIndex         uint64
+	PoolName         string
+	ExecutionAddress string
+}
+
+// Withdrawal request sent to the withdrawal request contract (EIP-7002) by the
+// address of the withdrawal credentials of a validator, included in a block
+// since electra. Amount is in gwei, and 0 requests the full exit of the
+// validator. RequestIndex is its position in the requests of the block.
+type WithdrawalRequest struct {
+	Epoch         uint64
+	Slot          uint64
+	BlockNumber   uint64
+	RequestIndex  uint64
+	PoolName      string
+	SourceAddress string
+	Pubkey        string
+	Amount        uint64
+}
+
+// Consolidation request sent to the consolidation request contract (EIP-7251)
+// by the address of the withdrawal credentials of the source validator,
+// included in a block since electra. The balance of the source is moved to the
+// target, or if both are the same, its credentials are switched to 0x02.
+type ConsolidationRequest struct {
+	Epoch         uint64
+	Slot          uint64
+	BlockNumber   uint64
+	RequestIndex  uint64
+	PoolName      string
+	SourceAddress string
+	SourcePubkey  string
+	TargetPubkey  string
+}
+
+// Partial withdrawals requested by a tracked validator that left the pending
+// queue in the epoch. WithdrawnGwei is what the blocks of the epoch withdrew
+// from the validator, up to the requested amount. It is less than requested if
+// the balance above 32 ETH was lower, and 0 if the requests were skipped, e.g.
+// because the validator is exiting.
+type PartialWithdrawal struct {
+	Epoch             uint64
+	ValIndex          uint64
+	PoolName          string
+	NOfRequests       uint64
+	WithdrawableEpoch uint64
+	RequestedGwei     uint64
+	WithdrawnGwei     uint64
+}
+
+// Partial withdrawals of the validators of a pool waiting in the queue at the
+// end of the epoch. LastWithdrawableEpoch is the epoch from which all of them
+// can be processed, 0 if there are none.
+type PendingPartialWithdrawals struct {
+	Epoch                 uint64
+	PoolName              string
+	NOfPending            uint64
+	PendingGwei           uint64
+	LastWithdrawableEpoch uint64
+}
+
+// Returns true if the request is a full exit of the validator
+func (r WithdrawalRequest) IsExit() bool {
+	return r.Amount == 0
+}
+
+// Returns true if the request switches the credentials of the validator to
+// 0x02 instead of consolidating two of them
+func (r ConsolidationRequest) IsSwitchToCompounding() bool {
+	return r.SourcePubkey == r.TargetPubkey
+}
+
+// Blocks proposed by a pool in an epoch by the clients that built them, as
+// guessed from their graffiti. Clients that can't be guessed are "unknown".
+type ClientDiversity struct {
+	Epoch           uint64
+	PoolName        string
+	ConsensusClient string
+	ExecutionClient string
+	NOfBlocks       uint64
+}
+
+// Number of validators of a pool in each stage of their lifecycle. Slashed
+// validators are also counted in the stage they are in. The wait times are the
+// epochs until the last scheduled activation and exit of the pool.
+type ValidatorStatus struct {
+	Epoch                uint64
+	PoolName             string
+	NOfPending           uint64
+	NOfActive            uint64
+	NOfExiting           uint64
+	NOfExited            uint64
+	NOfWithdrawable      uint64
+	NOfSlashed           uint64
+	ActivationWaitEpochs uint64
+	ExitWaitEpochs       uint64
 }
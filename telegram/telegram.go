@@ -0,0 +1,304 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/bilinearlabs/eth-metrics/db"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const ApiUrl = "https://api.telegram.org"
+
+// Epochs shown by /pool
+const poolEpochs = 10
+
+// Seconds that a request for updates waits for new messages
+const pollTimeout = 30
+
+const dayFormat = "2006-01-02"
+
+const help = `/status - last epoch of every pool
+/pool name - last 10 epochs of a pool
+/missed today - missed attestations and blocks of today, or of yesterday or a day as 2024-01-15`
+
+// Answers the commands sent to the bot in the configured chat, from the metrics
+// stored in the database. The alerts are sent to the chat by the alerts.
+type Bot struct {
+	config     *config.Config
+	database   *db.Database
+	httpClient *http.Client
+	apiUrl     string
+	now        func() time.Time
+}
+
+func NewBot(config *config.Config) (*Bot, error) {
+	bot := &Bot{
+		config:     config,
+		httpClient: &http.Client{Timeout: (pollTimeout + 10) * time.Second},
+		apiUrl:     ApiUrl,
+		now:        time.Now,
+	}
+	if config.TelegramToken == "" || config.DryRun {
+		return bot, nil
+	}
+	if config.DatabasePath == "" {
+		log.Warn("Telegram commands are not answered without --database-path, only the alerts are sent")
+		return bot, nil
+	}
+
+	database, err := db.NewWithOptions(config.DatabasePath, db.OptionsFromConfig(config))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open database for the telegram bot")
+	}
+	bot.database = database
+	return bot, nil
+}
+
+// Returns true if the bot answers commands
+func (b *Bot) Enabled() bool {
+	return b.database != nil
+}
+
+type chat struct {
+	Id int64 `json:"id"`
+}
+
+type message struct {
+	Chat chat   `json:"chat"`
+	Text string `json:"text"`
+}
+
+type update struct {
+	UpdateId int64    `json:"update_id"`
+	Message  *message `json:"message"`
+}
+
+type response struct {
+	Ok          bool            `json:"ok"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+}
+
+// Polls the messages sent to the bot and answers them until stopped
+func (b *Bot) Run() {
+	var offset int64
+	for {
+		var updates []update
+		err := b.call("getUpdates", map[string]interface{}{
+			"offset":          offset,
+			"timeout":         pollTimeout,
+			"allowed_updates": []string{"message"},
+		}, &updates)
+		if err != nil {
+			log.Error("could not get telegram updates: ", err)
+			time.Sleep(10 * time.Second)
+			continue
+		}
+		for _, u := range updates {
+			offset = u.UpdateId + 1
+			if u.Message == nil {
+				continue
+			}
+			if u.Message.Chat.Id != b.config.TelegramChatId {
+				log.WithField("ChatId", u.Message.Chat.Id).Warn("Ignoring telegram message of another chat")
+				continue
+			}
+			if err := b.SendMessage(b.Answer(u.Message.Text)); err != nil {
+				log.Error("could not answer telegram message: ", err)
+			}
+		}
+	}
+}
+
+// Returns the answer to a command, as telegram html
+func (b *Bot) Answer(text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return html.EscapeString(help)
+	}
+	// Commands in groups may be addressed as /status@bot_name
+	command, _, _ := strings.Cut(fields[0], "@")
+
+	var answer string
+	var err error
+	switch command {
+	case "/status":
+		answer, err = b.status()
+	case "/pool":
+		if len(fields) != 2 {
+			return "Usage: /pool name"
+		}
+		answer, err = b.pool(fields[1])
+	case "/missed":
+		day := "today"
+		if len(fields) > 1 {
+			day = fields[1]
+		}
+		answer, err = b.missed(day)
+	default:
+		return html.EscapeString(help)
+	}
+	if err != nil {
+		log.Error("could not answer telegram command ", command, ": ", err)
+		return html.EscapeString("Error: " + err.Error())
+	}
+	return answer
+}
+
+func (b *Bot) status() (string, error) {
+	metrics, err := b.database.GetRecentPoolMetrics("", 1)
+	if err != nil {
+		return "", err
+	}
+	if len(metrics) == 0 {
+		return "No metrics were stored yet.", nil
+	}
+
+	rows := [][]string{{"Pool", "Validators", "Source", "Blocks", "Rewards"}}
+	for _, m := range metrics {
+		rows = append(rows, []string{
+			m.PoolName,
+			fmt.Sprint(m.NOfValidatingKeys),
+			correct(m.NOfIncorrectSource, m.NOfValidatingKeys),
+			fmt.Sprintf("%d/%d", m.NOfProposedBlocks, m.NOfScheduledBlocks),
+			fmt.Sprintf("%.6f", float64(m.RewardsGwei)/1e9),
+		})
+	}
+	return fmt.Sprintf("<b>Epoch %d</b>, rewards in ETH\n", metrics[0].Epoch) + table(rows), nil
+}
+
+func (b *Bot) pool(poolName string) (string, error) {
+	metrics, err := b.database.GetRecentPoolMetrics(poolName, poolEpochs)
+	if err != nil {
+		return "", err
+	}
+	if len(metrics) == 0 {
+		return html.EscapeString("No metrics were stored of pool " + poolName), nil
+	}
+
+	rows := [][]string{{"Epoch", "Source", "Target", "Head", "Blocks", "Rewards"}}
+	for _, m := range metrics {
+		rows = append(rows, []string{
+			fmt.Sprint(m.Epoch),
+			correct(m.NOfIncorrectSource, m.NOfValidatingKeys),
+			correct(m.NOfIncorrectTarget, m.NOfValidatingKeys),
+			correct(m.NOfIncorrectHead, m.NOfValidatingKeys),
+			fmt.Sprintf("%d/%d", m.NOfProposedBlocks, m.NOfScheduledBlocks),
+			fmt.Sprintf("%.6f", float64(m.RewardsGwei)/1e9),
+		})
+	}
+	return fmt.Sprintf("<b>Pool %s</b>, %d validators, rewards in ETH\n", html.EscapeString(poolName), metrics[0].NOfValidatingKeys) + table(rows), nil
+}
+
+// Missed attestations and blocks of each pool in a day, from its daily metrics
+func (b *Bot) missed(day string) (string, error) {
+	today := b.now().UTC()
+	switch day {
+	case "today":
+		day = today.Format(dayFormat)
+	case "yesterday":
+		day = today.AddDate(0, 0, -1).Format(dayFormat)
+	default:
+		if _, err := time.Parse(dayFormat, day); err != nil {
+			return "Usage: /missed today|yesterday|2024-01-15", nil
+		}
+	}
+
+	metrics, err := b.database.GetPoolsMetricsOfPeriod("daily", day)
+	if err != nil {
+		return "", err
+	}
+	if len(metrics) == 0 {
+		return "No metrics were stored of " + day, nil
+	}
+
+	rows := [][]string{{"Pool", "Attestations", "Blocks"}}
+	for _, m := range metrics {
+		rows = append(rows, []string{
+			m.PoolName,
+			missedOf(m.NOfIncorrectSource, m.NOfTotalVotes),
+			missedOf(missedBlocks(m), m.NOfScheduledBlocks),
+		})
+	}
+	return "<b>Missed in " + day + "</b> (UTC)\n" + table(rows), nil
+}
+
+func missedBlocks(m schemas.PoolPeriodMetrics) uint64 {
+	if m.NOfScheduledBlocks > m.NOfProposedBlocks {
+		return m.NOfScheduledBlocks - m.NOfProposedBlocks
+	}
+	return 0
+}
+
+// Share of the duties that were not missed, e.g. 99.50%
+func correct(missed uint64, duties uint64) string {
+	if duties == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f%%", 100*(1-float64(missed)/float64(duties)))
+}
+
+// e.g. 3/600
+func missedOf(missed uint64, duties uint64) string {
+	return fmt.Sprintf("%d/%d", missed, duties)
+}
+
+// Aligned in a monospace block, since telegram doesn't render tables
+func table(rows [][]string) string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 1, ' ', tabwriter.AlignRight)
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t")+"\t")
+	}
+	w.Flush()
+	return "<pre>" + html.EscapeString(buf.String()) + "</pre>"
+}
+
+func (b *Bot) SendMessage(text string) error {
+	return b.call("sendMessage", map[string]interface{}{
+		"chat_id":    b.config.TelegramChatId,
+		"text":       text,
+		"parse_mode": "HTML",
+	}, nil)
+}
+
+// Calls a method of the bot api, decoding its result if not nil
+func (b *Bot) call(method string, request interface{}, result interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal request")
+	}
+	resp, err := b.httpClient.Post(b.apiUrl+"/bot"+b.config.TelegramToken+"/"+method, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// The url contains the token
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return errors.Wrap(err, "could not call "+method)
+	}
+	defer resp.Body.Close()
+
+	var r response
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return errors.Wrap(err, "could not decode response of "+method)
+	}
+	if !r.Ok {
+		return errors.New(method + " failed: " + r.Description)
+	}
+	if result == nil {
+		return nil
+	}
+	return errors.Wrap(json.Unmarshal(r.Result, result), "could not decode result of "+method)
+}
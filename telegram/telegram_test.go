@@ -0,0 +1,111 @@
+package telegram
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/bilinearlabs/eth-metrics/db"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBot(t *testing.T) *Bot {
+	database, err := db.New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.CreateTables())
+
+	// 2023-11-14 22:13:20 UTC
+	for epoch := uint64(100); epoch < 112; epoch++ {
+		require.NoError(t, database.StoreValidatorPerformance(schemas.ValidatorPerformanceMetrics{
+			Time:                 time.Unix(1700000000+int64(epoch-100)*384, 0),
+			Epoch:                epoch,
+			PoolName:             "pool_a",
+			NOfValidatingKeys:    200,
+			NOfTotalVotes:        200,
+			NOfIncorrectSource:   1,
+			NOfIncorrectHead:     2,
+			EarnedBalance:        big.NewInt(3000),
+			LosedBalance:         big.NewInt(-1000),
+			SyncCommitteeRewards: 500,
+			EffectiveBalance:     big.NewInt(0),
+		}))
+	}
+	require.NoError(t, database.StoreProposalDuties(111, "pool_a", 2, 1, 1, 0, 0))
+
+	return &Bot{
+		config:   &config.Config{TelegramChatId: 42},
+		database: database,
+		now:      func() time.Time { return time.Unix(1700000000, 0) },
+	}
+}
+
+func Test_Answer(t *testing.T) {
+	b := newTestBot(t)
+
+	require.Equal(t, "<b>Epoch 111</b>, rewards in ETH\n<pre>"+
+		"   Pool Validators Source Blocks  Rewards\n"+
+		" pool_a        200 99.50%    1/2 0.000003\n"+
+		"</pre>", b.Answer("/status"))
+	require.Equal(t, b.Answer("/status"), b.Answer("/status@eth_metrics_bot"))
+
+	answer := b.Answer("/pool pool_a")
+	require.Contains(t, answer, "<b>Pool pool_a</b>, 200 validators")
+	require.Contains(t, answer, "   111 99.50% 100.00% 99.00%    1/2 0.000003\n")
+	// The last 10 epochs
+	require.Contains(t, answer, "   102 ")
+	require.NotContains(t, answer, "   101 ")
+	require.Equal(t, "No metrics were stored of pool pool_&lt;b&gt;", b.Answer("/pool pool_<b>"))
+	require.Equal(t, "Usage: /pool name", b.Answer("/pool"))
+
+	require.Equal(t, "<b>Missed in 2023-11-14</b> (UTC)\n<pre>"+
+		"   Pool Attestations Blocks\n"+
+		" pool_a      12/2400    1/2\n"+
+		"</pre>", b.Answer("/missed today"))
+	require.Equal(t, b.Answer("/missed today"), b.Answer("/missed"))
+	require.Equal(t, "No metrics were stored of 2023-11-13", b.Answer("/missed yesterday"))
+	require.Equal(t, "Usage: /missed today|yesterday|2024-01-15", b.Answer("/missed tomorrow"))
+
+	require.Contains(t, b.Answer("/start"), "/status - last epoch of every pool")
+}
+
+func Test_SendMessage(t *testing.T) {
+	var path string
+	var request map[string]interface{}
+	ok := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": ok, "description": "Bad Request: chat not found"})
+	}))
+	defer server.Close()
+
+	b := &Bot{
+		config:     &config.Config{TelegramToken: "123:abc", TelegramChatId: 42},
+		httpClient: server.Client(),
+		apiUrl:     server.URL,
+	}
+	require.NoError(t, b.SendMessage("<b>hi</b>"))
+	require.Equal(t, "/bot123:abc/sendMessage", path)
+	require.Equal(t, float64(42), request["chat_id"])
+	require.Equal(t, "<b>hi</b>", request["text"])
+	require.Equal(t, "HTML", request["parse_mode"])
+
+	ok = false
+	require.ErrorContains(t, b.SendMessage("hi"), "chat not found")
+}
+
+func Test_NewBotDisabled(t *testing.T) {
+	b, err := NewBot(&config.Config{})
+	require.NoError(t, err)
+	require.False(t, b.Enabled())
+
+	// Only the alerts are sent
+	b, err = NewBot(&config.Config{TelegramToken: "123:abc", TelegramChatId: 42})
+	require.NoError(t, err)
+	require.False(t, b.Enabled())
+}
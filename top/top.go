@@ -0,0 +1,276 @@
+package top
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/api"
+	apiv1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/http"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bilinearlabs/eth-metrics/config"
+	"github.com/bilinearlabs/eth-metrics/db"
+	"github.com/bilinearlabs/eth-metrics/epochtime"
+	"github.com/bilinearlabs/eth-metrics/metrics"
+	"github.com/bilinearlabs/eth-metrics/pools"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// Alerts shown in the view
+const recentAlerts = 10
+
+// Clears the terminal and moves the cursor to the top left corner
+const clearScreen = "\033[H\033[2J"
+
+// Live view of the processing, the pools and their alerts in the terminal, for
+// operators logged into the machine. Metrics and alerts are read from the
+// database, and the upcoming duties from the beacon node.
+type Top struct {
+	config    *config.Config
+	database  *db.Database
+	consensus *http.Service
+	clock     *epochtime.Clock
+	keyToPool map[string]string
+	// Validators of the files given by index
+	indexToPool map[uint64]string
+	lastDuties  uint64
+	duties      []Duty
+}
+
+// Block proposal of a tracked validator
+type Duty struct {
+	Slot     uint64
+	ValIndex uint64
+	PoolName string
+}
+
+// What the view shows, as of a time
+type Snapshot struct {
+	Now           time.Time
+	Epoch         uint64
+	SlotInEpoch   uint64
+	SlotsPerEpoch uint64
+	// Metrics of the last processed epoch
+	Pools      []schemas.PoolEpochSummary
+	NextDuties []Duty
+	// Why the duties are unknown, if they are
+	DutiesError string
+	Alerts      []schemas.SentAlert
+}
+
+func New(config *config.Config, database *db.Database) (*Top, error) {
+	client, err := http.New(context.Background(),
+		http.WithTimeout(30*time.Second),
+		http.WithAddress(config.Eth2Address),
+		http.WithLogLevel(zerolog.WarnLevel),
+		http.WithExtraHeaders(metrics.BeaconNodeHeaders(config)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	consensus := client.(*http.Service)
+
+	genesis, err := consensus.Genesis(context.Background(), &api.GenesisOpts{})
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting genesis info")
+	}
+	if err := metrics.CheckGenesis(config, genesis.Data); err != nil {
+		return nil, err
+	}
+	spec, err := consensus.Spec(context.Background(), &api.SpecOpts{})
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting spec info")
+	}
+	slotsPerEpoch, err := metrics.SpecUint64(spec.Data, "SLOTS_PER_EPOCH")
+	if err != nil {
+		return nil, err
+	}
+	slotDuration, err := metrics.SpecSlotDuration(spec.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the validators of the files, the ones found by address are not
+	// known without the beacon state
+	var keyToPool map[string]string
+	var indexesPerPool map[string][]uint64
+	if pools.IsRemoteFile(config.ValidatorsFile) {
+		remote, err := pools.NewRemoteValidatorsFile(config.ValidatorsFile, config.ValidatorsFileAuth, config.DuplicateKeys)
+		if err != nil {
+			return nil, err
+		}
+		if _, keyToPool, indexesPerPool, _, err = remote.Fetch(); err != nil {
+			return nil, errors.Wrap(err, "error reading validators file")
+		}
+	} else if _, keyToPool, indexesPerPool, err = metrics.LoadValidatorKeys(config); err != nil {
+		return nil, err
+	}
+	indexToPool := make(map[uint64]string)
+	for poolName, indexes := range indexesPerPool {
+		for _, valIdx := range indexes {
+			indexToPool[valIdx] = poolName
+		}
+	}
+
+	return &Top{
+		config:      config,
+		database:    database,
+		consensus:   consensus,
+		clock:       epochtime.New(genesis.Data.GenesisTime, slotDuration, slotsPerEpoch),
+		keyToPool:   keyToPool,
+		indexToPool: indexToPool,
+	}, nil
+}
+
+// Draws the view every --refresh until stopped
+func (t *Top) Run(w io.Writer) error {
+	ticker := time.NewTicker(t.config.TopRefresh)
+	defer ticker.Stop()
+	for ; true; <-ticker.C {
+		snapshot, err := t.Snapshot(time.Now())
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, clearScreen+Render(snapshot)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Top) Snapshot(now time.Time) (*Snapshot, error) {
+	slot := t.clock.SlotAt(now)
+	snapshot := &Snapshot{
+		Now:           now,
+		Epoch:         t.clock.EpochOfSlot(slot),
+		SlotInEpoch:   slot - t.clock.FirstSlot(t.clock.EpochOfSlot(slot)),
+		SlotsPerEpoch: t.clock.SlotsPerEpoch(),
+	}
+
+	var err error
+	if snapshot.Pools, err = t.database.GetRecentPoolMetrics("", 1); err != nil {
+		return nil, err
+	}
+	if snapshot.Alerts, err = t.database.GetRecentAlerts(recentAlerts); err != nil {
+		return nil, err
+	}
+
+	// Duties don't change during the epoch, so they are fetched once
+	nextEpoch := snapshot.Epoch + 1
+	if t.lastDuties != nextEpoch {
+		duties, err := t.consensus.ProposerDuties(context.Background(), &api.ProposerDutiesOpts{Epoch: phase0.Epoch(nextEpoch)})
+		if err != nil {
+			snapshot.DutiesError = err.Error()
+			return snapshot, nil
+		}
+		t.duties = PoolDuties(duties.Data, t.keyToPool, t.indexToPool)
+		t.lastDuties = nextEpoch
+	}
+	snapshot.NextDuties = t.duties
+	return snapshot, nil
+}
+
+// Returns the proposals of the tracked validators, known by key or by index
+func PoolDuties(duties []*apiv1.ProposerDuty, keyToPool map[string]string, indexToPool map[uint64]string) []Duty {
+	poolDuties := make([]Duty, 0)
+	for _, duty := range duties {
+		poolName, ok := keyToPool[duty.PubKey.String()]
+		if !ok {
+			poolName, ok = indexToPool[uint64(duty.ValidatorIndex)]
+		}
+		if !ok {
+			continue
+		}
+		poolDuties = append(poolDuties, Duty{
+			Slot:     uint64(duty.Slot),
+			ValIndex: uint64(duty.ValidatorIndex),
+			PoolName: poolName,
+		})
+	}
+	return poolDuties
+}
+
+func Render(s *Snapshot) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "eth-metrics top, %s\n\n", s.Now.UTC().Format("2006-01-02 15:04:05 MST"))
+
+	// The current slot is in progress
+	const barWidth = 32
+	done := 0
+	if s.SlotsPerEpoch > 0 {
+		done = int(s.SlotInEpoch * barWidth / s.SlotsPerEpoch)
+	}
+	fmt.Fprintf(&b, "Epoch %d [%s%s] slot %d/%d\n",
+		s.Epoch, strings.Repeat("#", done), strings.Repeat(".", barWidth-done), s.SlotInEpoch+1, s.SlotsPerEpoch)
+	if len(s.Pools) == 0 {
+		b.WriteString("No epoch was processed yet\n")
+	} else {
+		last := s.Pools[0].Epoch
+		fmt.Fprintf(&b, "Last processed epoch %d, %d behind\n", last, int64(s.Epoch)-int64(last))
+	}
+
+	b.WriteString("\n")
+	rows := [][]string{{"POOL", "VALIDATORS", "SOURCE", "TARGET", "HEAD", "BLOCKS"}}
+	for _, p := range s.Pools {
+		rows = append(rows, []string{
+			p.PoolName,
+			fmt.Sprint(p.NOfValidatingKeys),
+			participation(p.NOfIncorrectSource, p.NOfValidatingKeys),
+			participation(p.NOfIncorrectTarget, p.NOfValidatingKeys),
+			participation(p.NOfIncorrectHead, p.NOfValidatingKeys),
+			fmt.Sprintf("%d/%d", p.NOfProposedBlocks, p.NOfScheduledBlocks),
+		})
+	}
+	b.WriteString(table(rows))
+
+	fmt.Fprintf(&b, "\nProposals in the next epoch %d\n", s.Epoch+1)
+	switch {
+	case s.DutiesError != "":
+		b.WriteString("Unknown: " + s.DutiesError + "\n")
+	case len(s.NextDuties) == 0:
+		b.WriteString("None\n")
+	default:
+		rows = [][]string{{"SLOT", "VALIDATOR", "POOL"}}
+		for _, d := range s.NextDuties {
+			rows = append(rows, []string{fmt.Sprint(d.Slot), fmt.Sprint(d.ValIndex), d.PoolName})
+		}
+		b.WriteString(table(rows))
+	}
+
+	b.WriteString("\nRecent alerts\n")
+	if len(s.Alerts) == 0 {
+		b.WriteString("None\n")
+	} else {
+		rows = [][]string{{"EPOCH", "SEVERITY", "POOL", "KIND", "MESSAGE"}}
+		for _, a := range s.Alerts {
+			rows = append(rows, []string{fmt.Sprint(a.Epoch), a.Severity, a.PoolName, a.Kind, a.Message})
+		}
+		b.WriteString(table(rows))
+	}
+	return b.String()
+}
+
+// Share of the validators that voted correctly, e.g. 99.50%
+func participation(incorrect uint64, validators uint64) string {
+	if validators == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f%%", 100*(1-float64(incorrect)/float64(validators)))
+}
+
+func table(rows [][]string) string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+	return buf.String()
+}
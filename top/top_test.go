@@ -0,0 +1,78 @@
+package top
+
+import (
+	"testing"
+	"time"
+
+	apiv1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/bilinearlabs/eth-metrics/schemas"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PoolDuties(t *testing.T) {
+	key := func(b byte) phase0.BLSPubKey {
+		var k phase0.BLSPubKey
+		k[0] = b
+		return k
+	}
+	duties := []*apiv1.ProposerDuty{
+		{PubKey: key(1), Slot: 64, ValidatorIndex: 10},
+		{PubKey: key(2), Slot: 65, ValidatorIndex: 20},
+		{PubKey: key(3), Slot: 66, ValidatorIndex: 30},
+	}
+	keyToPool := map[string]string{key(1).String(): "pool_a"}
+	indexToPool := map[uint64]string{30: "pool_b"}
+
+	require.Equal(t, []Duty{
+		{Slot: 64, ValIndex: 10, PoolName: "pool_a"},
+		{Slot: 66, ValIndex: 30, PoolName: "pool_b"},
+	}, PoolDuties(duties, keyToPool, indexToPool))
+}
+
+func Test_Render(t *testing.T) {
+	view := Render(&Snapshot{
+		Now:           time.Date(2024, 1, 15, 10, 4, 12, 0, time.UTC),
+		Epoch:         250123,
+		SlotInEpoch:   8,
+		SlotsPerEpoch: 32,
+		Pools: []schemas.PoolEpochSummary{{
+			PoolName:           "pool_a",
+			Epoch:              250120,
+			NOfValidatingKeys:  200,
+			NOfIncorrectSource: 1,
+			NOfIncorrectHead:   4,
+			NOfScheduledBlocks: 1,
+		}},
+		NextDuties: []Duty{{Slot: 8003968, ValIndex: 12345, PoolName: "pool_a"}},
+		Alerts: []schemas.SentAlert{{
+			Epoch:    250119,
+			PoolName: "pool_a",
+			Kind:     "missed_proposal",
+			Severity: "warning",
+			Message:  "validator 1 missed its block proposal at slot 8003800",
+		}},
+	})
+
+	require.Equal(t, `eth-metrics top, 2024-01-15 10:04:12 UTC
+
+Epoch 250123 [########........................] slot 9/32
+Last processed epoch 250120, 3 behind
+
+POOL    VALIDATORS  SOURCE  TARGET   HEAD    BLOCKS
+pool_a  200         99.50%  100.00%  98.00%  0/1
+
+Proposals in the next epoch 250124
+SLOT     VALIDATOR  POOL
+8003968  12345      pool_a
+
+Recent alerts
+EPOCH   SEVERITY  POOL    KIND             MESSAGE
+250119  warning   pool_a  missed_proposal  validator 1 missed its block proposal at slot 8003800
+`, view)
+
+	view = Render(&Snapshot{Epoch: 10, SlotsPerEpoch: 32, DutiesError: "timeout"})
+	require.Contains(t, view, "No epoch was processed yet\n")
+	require.Contains(t, view, "Proposals in the next epoch 11\nUnknown: timeout\n")
+	require.Contains(t, view, "Recent alerts\nNone\n")
+}